@@ -0,0 +1,152 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/Chang-Augenweide/QuickBot-Go/internal/ai"
+	"github.com/Chang-Augenweide/QuickBot-Go/internal/benchmark"
+	"github.com/Chang-Augenweide/QuickBot-Go/internal/config"
+)
+
+// defaultBenchmarkModels are used for providers other than the configured one
+var defaultBenchmarkModels = map[string]string{
+	"openai":    "gpt-4o",
+	"anthropic": "claude-3-5-sonnet-20241022",
+	"ollama":    "llama3",
+	"gemini":    "gemini-1.5-flash",
+	"mistral":   "mistral-small-latest",
+}
+
+// runBenchmark benchmarks AI providers against a prompt file
+func runBenchmark() {
+	if benchPrompts == "" {
+		log.Fatalf("benchmark requires --prompts")
+	}
+
+	cfg, err := config.LoadConfig(configPath)
+	if err != nil {
+		log.Fatalf("Failed to load configuration: %v", err)
+	}
+
+	prompts, err := readPrompts(benchPrompts)
+	if err != nil {
+		log.Fatalf("Failed to read prompts: %v", err)
+	}
+
+	var providers []ai.AIProvider
+	for _, spec := range strings.Split(benchProviders, ",") {
+		spec = strings.TrimSpace(spec)
+		if spec == "" {
+			continue
+		}
+		provider, err := newBenchmarkProvider(cfg, spec)
+		if err != nil {
+			log.Fatalf("Invalid provider %q: %v", spec, err)
+		}
+		providers = append(providers, provider)
+	}
+
+	log.Printf("Benchmarking %d providers with %d prompts, %d iterations each", len(providers), len(prompts), benchIterations)
+
+	b := &benchmark.Benchmarker{
+		Providers:  providers,
+		Prompts:    prompts,
+		Iterations: benchIterations,
+	}
+
+	results, err := b.Run(context.Background())
+	if err != nil {
+		log.Fatalf("Benchmark failed: %v", err)
+	}
+
+	summary := benchmark.Summarize(results)
+	printBenchmarkSummary(summary)
+
+	output := fmt.Sprintf("benchmark_%s.json", time.Now().Format("20060102_150405"))
+	data, err := json.MarshalIndent(map[string]interface{}{
+		"results": results,
+		"summary": summary,
+	}, "", "  ")
+	if err != nil {
+		log.Fatalf("Failed to marshal results: %v", err)
+	}
+	if err := os.WriteFile(output, data, 0644); err != nil {
+		log.Fatalf("Failed to write results: %v", err)
+	}
+	log.Printf("Results saved to %s", output)
+}
+
+// newBenchmarkProvider creates a provider from "name" or "name:model". The
+// configured provider uses the configured model and base URL.
+func newBenchmarkProvider(cfg *config.Config, spec string) (ai.AIProvider, error) {
+	name, model := spec, ""
+	if idx := strings.Index(spec, ":"); idx >= 0 {
+		name, model = spec[:idx], spec[idx+1:]
+	}
+
+	baseURL := ""
+	if name == cfg.AI.Provider {
+		baseURL = cfg.AI.BaseURL
+		if model == "" {
+			model = cfg.AI.Model
+		}
+	}
+	if model == "" {
+		model = defaultBenchmarkModels[name]
+	}
+
+	switch name {
+	case "openai":
+		return ai.NewOpenAIProvider(cfg.AI.APIKey, baseURL, model), nil
+	case "anthropic":
+		return ai.NewAnthropicProvider(cfg.AI.APIKey, model), nil
+	case "ollama":
+		if baseURL == "" {
+			baseURL = "http://localhost:11434"
+		}
+		return ai.NewOllamaProvider(baseURL, model), nil
+	case "gemini":
+		return ai.NewGeminiProvider(cfg.AI.APIKey, model, cfg.AI.GeminiProject, cfg.AI.GeminiLocation), nil
+	case "mistral":
+		return ai.NewMistralProvider(cfg.AI.APIKey, baseURL, model), nil
+	default:
+		return nil, fmt.Errorf("unknown provider")
+	}
+}
+
+// readPrompts reads one prompt per non-empty line
+func readPrompts(path string) ([]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var prompts []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		if line := strings.TrimSpace(scanner.Text()); line != "" {
+			prompts = append(prompts, line)
+		}
+	}
+	return prompts, scanner.Err()
+}
+
+// printBenchmarkSummary prints the summary as a table
+func printBenchmarkSummary(summary benchmark.BenchmarkSummary) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "PROVIDER\tRUNS\tERRORS\tMEAN (ms)\tP95 (ms)\tTOKENS/s")
+	for _, s := range summary.Providers {
+		fmt.Fprintf(w, "%s\t%d\t%d\t%.0f\t%d\t%.1f\n",
+			s.ProviderName, s.Runs, s.Errors, s.MeanMs, s.P95Ms, s.TokensPerSecond)
+	}
+	w.Flush()
+}
@@ -0,0 +1,210 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/Chang-Augenweide/QuickBot-Go/internal/agent"
+	"github.com/Chang-Augenweide/QuickBot-Go/internal/config"
+)
+
+// chatSessionID is the session used by the CLI chat mode
+const chatSessionID = "cli:local"
+
+// chatPrinter writes the CLI chat prompt and responses
+type chatPrinter struct {
+	out         io.Writer
+	typingDelay time.Duration
+	color       bool
+}
+
+// newChatPrinter creates a printer for out. Colors are only used when
+// enabled and out is a terminal.
+func newChatPrinter(out io.Writer, cfg config.CLIConfig) *chatPrinter {
+	color := false
+	if f, ok := out.(*os.File); ok && cfg.ColorOutput {
+		color = isTerminal(f)
+	}
+	return &chatPrinter{out: out, typingDelay: cfg.TypingDelay, color: color}
+}
+
+// prompt prints the input prompt. With colors on, the user's input is
+// echoed in blue until endInput resets the color.
+func (p *chatPrinter) prompt() {
+	if p.color {
+		fmt.Fprint(p.out, colorBlue+"> ")
+		return
+	}
+	fmt.Fprint(p.out, "> ")
+}
+
+// endInput resets the color after the user's input
+func (p *chatPrinter) endInput() {
+	if p.color {
+		fmt.Fprint(p.out, colorReset)
+	}
+}
+
+// write prints part of a response, one character at a time when a typing
+// delay is set
+func (p *chatPrinter) write(text string) {
+	if p.color {
+		fmt.Fprint(p.out, colorGreen)
+		defer fmt.Fprint(p.out, colorReset)
+	}
+
+	if p.typingDelay <= 0 {
+		fmt.Fprint(p.out, text)
+		return
+	}
+	for _, r := range text {
+		time.Sleep(p.typingDelay)
+		fmt.Fprint(p.out, string(r))
+	}
+}
+
+// response prints a full bot response
+func (p *chatPrinter) response(text string) {
+	p.write(text)
+	fmt.Fprintln(p.out)
+}
+
+// runChat runs an interactive chat with the agent on stdin and stdout
+func runChat() {
+	cfg, err := config.LoadConfig(configPath)
+	if err != nil {
+		log.Fatalf("Failed to load configuration: %v", err)
+	}
+	if typingDelay > 0 {
+		cfg.CLI.TypingDelay = typingDelay
+	}
+
+	memory, err := agent.NewMemory(cfg.Memory.Storage, cfg.Memory.MaxMessages)
+	if err != nil {
+		log.Fatalf("Failed to initialize memory: %v", err)
+	}
+	defer memory.Close()
+	memory.SetBotName(cfg.Bot.Name)
+
+	scheduler, err := agent.NewScheduler(cfg.Scheduler.Storage)
+	if err != nil {
+		log.Fatalf("Failed to initialize scheduler: %v", err)
+	}
+	defer scheduler.Stop()
+	if err := scheduler.SetDefaultTimezone(cfg.Bot.Timezone); err != nil {
+		log.Printf("⚠ %v, using local time", err)
+	}
+
+	quickBot, err := agent.NewAgent(cfg, memory, scheduler)
+	if err != nil {
+		log.Fatalf("Failed to initialize agent: %v", err)
+	}
+
+	if session, err := memory.GetSession(chatSessionID); err == nil && session == nil {
+		memory.CreateSession(chatSessionID, "CLI", "cli", "local")
+	}
+
+	printer := newChatPrinter(os.Stdout, cfg.CLI)
+	fmt.Printf("%s chat (type \"exit\" to quit)\n", cfg.Bot.Name)
+
+	scanner := bufio.NewScanner(os.Stdin)
+	for {
+		printer.prompt()
+		if !scanner.Scan() {
+			printer.endInput()
+			fmt.Println()
+			return
+		}
+		printer.endInput()
+
+		input := strings.TrimSpace(scanner.Text())
+		if input == "" {
+			continue
+		}
+		if input == "exit" || input == "quit" {
+			return
+		}
+
+		if err := chatOnce(quickBot, printer, cfg.CLI.StreamOutput, input); err != nil {
+			fmt.Printf("Error: %v\n", err)
+		}
+	}
+}
+
+// chatOnce sends one message to the agent and prints the response. When
+// streaming, tool output is printed while the tools run.
+func chatOnce(quickBot *agent.Agent, printer *chatPrinter, stream bool, input string) error {
+	if !stream {
+		response, err := quickBot.ProcessMessage(context.Background(), chatSessionID, input)
+		if err != nil {
+			return err
+		}
+		printer.response(response)
+		return nil
+	}
+
+	toolOut := make(chan string)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for chunk := range toolOut {
+			printer.write(chunk)
+		}
+	}()
+
+	response, err := quickBot.ProcessMessageWithStream(chatSessionID, input, toolOut)
+	<-done
+	if err != nil {
+		return err
+	}
+	printer.response(response)
+	return nil
+}
+
+// TestChatOutput checks that the chat printer prints full responses with
+// and without a typing delay, and only colors output when enabled
+func TestChatOutput() error {
+	response := "Hello from QuickBot, 你好!"
+
+	for _, delay := range []time.Duration{0, time.Millisecond} {
+		var out bytes.Buffer
+		printer := &chatPrinter{out: &out, typingDelay: delay}
+		printer.prompt()
+		printer.endInput()
+		printer.response(response)
+
+		if !strings.Contains(out.String(), response) {
+			return fmt.Errorf("typing delay %v: response missing from output %q", delay, out.String())
+		}
+		if strings.Contains(out.String(), "\033[") {
+			return fmt.Errorf("typing delay %v: color codes in uncolored output %q", delay, out.String())
+		}
+	}
+	log.Println("✓ Full response printed with and without typing delay")
+
+	// A buffer is not a terminal, so color_output alone does not color it
+	var out bytes.Buffer
+	newChatPrinter(&out, config.CLIConfig{ColorOutput: true}).response(response)
+	if strings.Contains(out.String(), "\033[") {
+		return fmt.Errorf("color codes written to a non-terminal: %q", out.String())
+	}
+
+	out.Reset()
+	printer := &chatPrinter{out: &out, color: true}
+	printer.prompt()
+	printer.endInput()
+	printer.response(response)
+	if !strings.Contains(out.String(), colorBlue) || !strings.Contains(out.String(), colorGreen+response+colorReset) {
+		return fmt.Errorf("colored output missing color codes: %q", out.String())
+	}
+	log.Println("✓ Colors only used when enabled")
+
+	return nil
+}
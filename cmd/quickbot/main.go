@@ -7,24 +7,52 @@ import (
 	"os"
 	"os/signal"
 	"path/filepath"
+	"strings"
 	"syscall"
 	"time"
 
 	"gopkg.in/yaml.v3"
 
 	"github.com/Chang-Augenweide/QuickBot-Go/internal/agent"
+	"github.com/Chang-Augenweide/QuickBot-Go/internal/ai"
+	"github.com/Chang-Augenweide/QuickBot-Go/internal/api"
+	"github.com/Chang-Augenweide/QuickBot-Go/internal/broker"
 	"github.com/Chang-Augenweide/QuickBot-Go/internal/config"
+	"github.com/Chang-Augenweide/QuickBot-Go/internal/events"
+	"github.com/Chang-Augenweide/QuickBot-Go/internal/workflow"
 	"github.com/Chang-Augenweide/QuickBot-Go/platforms"
 )
 
 var (
 	configPath string
 	command    string
+
+	replaySession string
+	replayFrom    int
+	replayCompare bool
+	replayOutput  string
+
+	benchPrompts    string
+	benchProviders  string
+	benchIterations int
+
+	securityCases string
+
+	typingDelay time.Duration
 )
 
 func init() {
 	flag.StringVar(&configPath, "config", "config.yaml", "Path to configuration file")
-	flag.StringVar(&command, "cmd", "run", "Command to run: run, test, version, init")
+	flag.StringVar(&command, "cmd", "run", "Command to run: run, chat, test, version, init, validate, replay, benchmark, security-test")
+	flag.StringVar(&replaySession, "session", "", "Session ID to replay")
+	flag.IntVar(&replayFrom, "from-message", 0, "Replay messages up to this message ID (0 for all)")
+	flag.BoolVar(&replayCompare, "compare", false, "Also call the real AI provider and diff responses")
+	flag.StringVar(&replayOutput, "output", "", "Save the replay log as JSON to this file")
+	flag.StringVar(&benchPrompts, "prompts", "", "Benchmark prompts file, one prompt per line")
+	flag.StringVar(&benchProviders, "providers", "openai", "Comma-separated providers to benchmark (name or name:model)")
+	flag.IntVar(&benchIterations, "iterations", 5, "Benchmark iterations per prompt")
+	flag.StringVar(&securityCases, "cases", "internal/agent/testdata/injection_cases.yaml", "Prompt injection test cases file")
+	flag.DurationVar(&typingDelay, "typing-delay", 0, "Chat typing simulation delay per character, e.g. 10ms (overrides cli.typing_delay)")
 	flag.Parse()
 }
 
@@ -34,12 +62,22 @@ func main() {
 	switch command {
 	case "run":
 		runQuickBot()
+	case "chat":
+		runChat()
 	case "test":
 		testQuickBot()
 	case "version":
 		printVersion()
 	case "init":
 		initConfig()
+	case "validate":
+		runValidate()
+	case "replay":
+		runReplay()
+	case "benchmark":
+		runBenchmark()
+	case "security-test":
+		runSecurityTest()
 	default:
 		log.Fatalf("Unknown command: %s", command)
 	}
@@ -67,20 +105,36 @@ func runQuickBot() {
 	// Initialize components
 	log.Println("Initializing components...")
 
+	// Event bus shared by all components
+	bus := events.NewEventBus()
+
 	// Memory
 	memory, err := agent.NewMemory(cfg.Memory.Storage, cfg.Memory.MaxMessages)
 	if err != nil {
 		log.Fatalf("Failed to initialize memory: %v", err)
 	}
 	defer memory.Close()
+	memory.SetEventBus(bus)
+	memory.SetBotName(cfg.Bot.Name)
 	log.Printf("✓ Memory system initialized (%s)", cfg.Memory.Storage)
 
+	if cfg.Memory.EmbeddingsEnabled {
+		memory.SetEmbeddingProvider(ai.NewOpenAIEmbeddingProvider(cfg.AI.APIKey, cfg.AI.BaseURL, cfg.Memory.EmbeddingModel))
+		log.Printf("✓ Message embeddings enabled (%s)", cfg.Memory.EmbeddingModel)
+	}
+
 	// Scheduler
 	scheduler, err := agent.NewScheduler(cfg.Scheduler.Storage)
 	if err != nil {
 		log.Fatalf("Failed to initialize scheduler: %v", err)
 	}
 	defer scheduler.Stop()
+	scheduler.SetEventBus(bus)
+	scheduler.SetOverdueAlertThreshold(cfg.Scheduler.MaxOverdueAlertThreshold)
+	scheduler.SetHistoryRetention(cfg.Scheduler.HistoryRetentionDays)
+	if err := scheduler.SetDefaultTimezone(cfg.Bot.Timezone); err != nil {
+		log.Printf("⚠ %v, using local time", err)
+	}
 	log.Printf("✓ Scheduler initialized (%s)", cfg.Scheduler.Storage)
 
 	// Agent
@@ -88,6 +142,7 @@ func runQuickBot() {
 	if err != nil {
 		log.Fatalf("Failed to initialize agent: %v", err)
 	}
+	quickBot.SetEventBus(bus)
 	log.Printf("✓ Agent initialized")
 	log.Printf("  AI: %s (%s)", cfg.AI.Provider, cfg.AI.Model)
 	log.Printf("  Tools: %d", len(quickBot.ToolRegistry().GetAll()))
@@ -95,6 +150,17 @@ func runQuickBot() {
 	log.Println()
 	log.Println("Initializing platforms...")
 
+	// Connect to the broker shared with other instances
+	var messageBroker broker.Broker
+	if cfg.Broker.Type == "nats" {
+		natsBroker, err := broker.NewNATSBroker(cfg.Broker.URL, cfg.Broker.ClusterID)
+		if err != nil {
+			log.Fatalf("Failed to connect to broker: %v", err)
+		}
+		messageBroker = natsBroker
+		log.Printf("✓ Broker connected: %s", cfg.Broker.URL)
+	}
+
 	// Initialize enabled platforms
 	var telegramPlatform *platforms.TelegramPlatform
 	ctx, cancel := context.WithCancel(context.Background())
@@ -105,15 +171,30 @@ func runQuickBot() {
 			log.Println("⚠ Telegram enabled but no token configured")
 		} else {
 			tgConfig := &platforms.TelegramConfig{
-				Token:        cfg.Platforms.Telegram.Token,
-				AllowedUsers: cfg.Platforms.Telegram.AllowedUsers,
-				Debug:        cfg.Bot.Debug,
+				Token:           cfg.Platforms.Telegram.Token,
+				AllowedUsers:    cfg.Platforms.Telegram.AllowedUsers,
+				Debug:           cfg.Bot.Debug,
+				TypingIndicator: cfg.Platforms.Telegram.TypingIndicator,
+
+				RespondToChannels: cfg.Platforms.Telegram.RespondToChannels,
+				ChannelAllowList:  cfg.Platforms.Telegram.ChannelAllowList,
+				AdminChatID:       cfg.Platforms.Telegram.AdminChatID,
+				StreamingReplies:  cfg.Platforms.Telegram.StreamingReplies,
+
+				TopicIsolation:      cfg.Platforms.Telegram.TopicIsolation,
+				TopicWelcomeMessage: cfg.Platforms.Telegram.TopicWelcomeMessage,
+
+				MaxLength:          cfg.Platforms.Telegram.MaxLength,
+				TruncationStrategy: cfg.Platforms.Telegram.TruncationStrategy,
 			}
 
 			telegramPlatform, err = platforms.NewTelegramPlatform(tgConfig, quickBot)
 			if err != nil {
 				log.Fatalf("Failed to initialize Telegram platform: %v", err)
 			}
+			if messageBroker != nil {
+				telegramPlatform.SetBroker(messageBroker)
+			}
 
 			if err := telegramPlatform.Start(); err != nil {
 				log.Fatalf("Failed to start Telegram platform: %v", err)
@@ -122,7 +203,131 @@ func runQuickBot() {
 		}
 	}
 
-	if telegramPlatform == nil {
+	// Discord
+	var discordPlatform *platforms.DiscordPlatform
+	if cfg.Platforms.Discord.Enabled {
+		if cfg.Platforms.Discord.Token == "" {
+			log.Println("⚠ Discord enabled but no token configured")
+		} else {
+			permissions := make(map[string][]string)
+			for _, permission := range cfg.Platforms.Discord.CommandPermissions {
+				command := strings.TrimPrefix(permission.Command, "/")
+				permissions[command] = append(permissions[command], permission.RequiredRoles...)
+			}
+
+			dcConfig := &platforms.DiscordConfig{
+				Token:              cfg.Platforms.Discord.Token,
+				AllowedUsers:       cfg.Platforms.Discord.AllowedUsers,
+				CommandPermissions: permissions,
+				AdminUserIDs:       cfg.Platforms.Discord.AdminUserIDs,
+
+				AutoReconnect:        cfg.Platforms.Discord.AutoReconnect,
+				ReconnectMaxAttempts: cfg.Platforms.Discord.ReconnectMaxAttempts,
+
+				MaxLength:          cfg.Platforms.Discord.MaxLength,
+				TruncationStrategy: cfg.Platforms.Discord.TruncationStrategy,
+			}
+
+			discordPlatform, err = platforms.NewDiscordPlatform(dcConfig, quickBot)
+			if err != nil {
+				log.Fatalf("Failed to initialize Discord platform: %v", err)
+			}
+
+			if err := discordPlatform.Start(); err != nil {
+				log.Fatalf("Failed to start Discord platform: %v", err)
+			}
+		}
+	}
+
+	// Slack
+	var slackPlatform *platforms.SlackPlatform
+	if cfg.Platforms.Slack.Enabled {
+		if cfg.Platforms.Slack.AppToken == "" || cfg.Platforms.Slack.BotToken == "" {
+			log.Println("⚠ Slack enabled but app_token or bot_token not configured")
+		} else {
+			// A negative limit retries forever, which the platform takes as 0
+			maxReconnects := cfg.Platforms.Slack.MaxReconnectAttempts
+			if maxReconnects < 0 {
+				maxReconnects = 0
+			}
+
+			slackConfig := &platforms.SlackConfig{
+				AppToken:     cfg.Platforms.Slack.AppToken,
+				BotToken:     cfg.Platforms.Slack.BotToken,
+				AllowedUsers: cfg.Platforms.Slack.AllowedUsers,
+
+				MaxReconnectAttempts: maxReconnects,
+				ReconnectBaseSeconds: cfg.Platforms.Slack.ReconnectBaseSeconds,
+			}
+
+			slackPlatform, err = platforms.NewSlackPlatform(slackConfig, quickBot)
+			if err != nil {
+				log.Fatalf("Failed to initialize Slack platform: %v", err)
+			}
+
+			if err := slackPlatform.Start(); err != nil {
+				log.Fatalf("Failed to start Slack platform: %v", err)
+			}
+		}
+	}
+
+	// Webhook
+	var webhookPlatform *platforms.WebhookPlatform
+	if cfg.Platforms.Webhook.Enabled {
+		whConfig := &platforms.WebhookConfig{
+			URL:        cfg.Platforms.Webhook.URL,
+			Secret:     cfg.Platforms.Webhook.Secret,
+			Events:     cfg.Platforms.Webhook.Events,
+			Retry:      cfg.Platforms.Webhook.Retry,
+			MaxRetries: cfg.Platforms.Webhook.MaxRetries,
+		}
+
+		webhookPlatform, err = platforms.NewWebhookPlatform(whConfig, quickBot)
+		if err != nil {
+			log.Fatalf("Failed to initialize webhook platform: %v", err)
+		}
+
+		if err := webhookPlatform.Start(); err != nil {
+			log.Fatalf("Failed to start webhook platform: %v", err)
+		}
+	}
+
+	// REST API
+	var apiServer *api.API
+	var workflows *agent.WorkflowEngine
+	if cfg.API.Enabled {
+		apiServer = api.NewAPI(quickBot, memory, scheduler, cfg.API.Port)
+
+		workflows = agent.NewWorkflowEngine()
+		workflows.SetAgent(quickBot)
+		if err := workflows.Open(cfg.Workflow.Storage); err != nil {
+			log.Printf("⚠ Workflow state not restored: %v", err)
+		}
+		if err := workflows.StartQueue(cfg.Workflow); err != nil {
+			log.Printf("⚠ Workflow queue not started: %v", err)
+		}
+		apiServer.SetWorkflowEngine(workflows)
+		apiServer.SetPluginManager(agent.NewPluginManager(""))
+		if telegramPlatform != nil {
+			apiServer.SetReconnectCounter("telegram", telegramPlatform.ReconnectCount)
+		}
+		if discordPlatform != nil {
+			apiServer.SetReconnectCounter("discord", discordPlatform.ReconnectCount)
+			apiServer.SetConnectionState("discord", discordPlatform.ConnectionState)
+		}
+		if slackPlatform != nil {
+			apiServer.SetReconnectCounter("slack", slackPlatform.ReconnectCount)
+		}
+
+		go func() {
+			if err := apiServer.Start(); err != nil {
+				log.Fatalf("API server failed: %v", err)
+			}
+		}()
+		log.Printf("✓ API server started on port %d", cfg.API.Port)
+	}
+
+	if telegramPlatform == nil && discordPlatform == nil && slackPlatform == nil && apiServer == nil {
 		log.Println("⚠ No platforms enabled. Enable at least one platform in config.yaml")
 		return
 	}
@@ -144,12 +349,51 @@ func runQuickBot() {
 	// Start periodic tasks
 	go runPeriodicTasks(ctx, quickBot, memory, scheduler)
 
+	// Start memory cleanup
+	memory.StartCleanupWorker(ctx, agent.CleanupPolicy{
+		MaxMessagesPerSession: cfg.Memory.MaxMessagesPerSession,
+		Deduplicate:           cfg.Memory.Deduplicate,
+		RunInterval:           time.Duration(cfg.Memory.CleanupInterval) * time.Second,
+	})
+
+	// Reload prompt templates on SIGHUP
+	hupChan := make(chan os.Signal, 1)
+	signal.Notify(hupChan, syscall.SIGHUP)
+	go func() {
+		for range hupChan {
+			if err := quickBot.ReloadPrompts(); err != nil {
+				log.Printf("Failed to reload prompt templates: %v", err)
+				continue
+			}
+			log.Println("✓ Prompt templates reloaded")
+		}
+	}()
+
 	// Wait for shutdown signal
 	sig := <-sigChan
 	log.Printf()
 	log.Printf("Received signal: %v", sig)
 	log.Println("Shutting down...")
 
+	// Let in-flight API requests finish
+	if apiServer != nil {
+		shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), apiShutdownGracePeriod)
+		if err := apiServer.Stop(shutdownCtx); err != nil {
+			log.Printf("⚠ API shutdown: %v", err)
+		} else {
+			log.Println("✓ API server stopped")
+		}
+		shutdownCancel()
+	}
+
+	// Let queued workflow executions finish
+	if workflows != nil {
+		if err := workflows.Drain(); err != nil {
+			log.Printf("⚠ Workflow queue: %v", err)
+		}
+		workflows.Close()
+	}
+
 	// Cancel context
 	cancel()
 
@@ -157,6 +401,22 @@ func runQuickBot() {
 	if telegramPlatform != nil {
 		telegramPlatform.Stop()
 	}
+	if discordPlatform != nil {
+		discordPlatform.Stop()
+	}
+	if slackPlatform != nil {
+		slackPlatform.Stop()
+	}
+	if webhookPlatform != nil {
+		webhookPlatform.Stop()
+	}
+
+	// Flush and close the broker connection
+	if messageBroker != nil {
+		if err := messageBroker.Close(); err != nil {
+			log.Printf("⚠ Broker shutdown: %v", err)
+		}
+	}
 
 	// Stop agent
 	quickBot.Stop()
@@ -165,6 +425,10 @@ func runQuickBot() {
 	os.Exit(0)
 }
 
+// apiShutdownGracePeriod bounds how long shutdown waits for in-flight
+// API requests
+const apiShutdownGracePeriod = 30 * time.Second
+
 // runPeriodicTasks runs periodic background tasks
 func runPeriodicTasks(ctx context.Context, quickBot *agent.Agent, memory *agent.Memory, scheduler *agent.Scheduler) {
 	ticker := time.NewTicker(1 * time.Minute)
@@ -183,8 +447,6 @@ func runPeriodicTasks(ctx context.Context, quickBot *agent.Agent, memory *agent.
 					// Process task
 				}
 			}
-
-			// Memory cleanup can be added here
 		}
 	}
 }
@@ -202,10 +464,75 @@ func testQuickBot() {
 		fn   TestFunc
 	}{
 		{"Configuration", testConfig},
+		{"Health Checks", config.TestHealthChecks},
+		{"Config Defaults", config.TestConfigDefaults},
 		{"Memory", memory.TestMemory},
+		{"Message Range", memory.TestMessageRange},
+		{"Chat Import", memory.TestChatImport},
+		{"Similarity Search", memory.TestSimilaritySearch},
+		{"Semantic Search", memory.TestSemanticSearch},
+		{"Long-Term Memory Expiry", memory.TestLongTermExpiry},
+		{"Cleanup Worker", memory.TestCleanupWorker},
+		{"Message Deduplication", memory.TestMessageDeduplication},
+		{"Session Transfer", memory.TestSessionTransfer},
+		{"Session Tags", memory.TestSessionTags},
+		{"Memory Snapshot", memory.TestSnapshot},
 		{"Scheduler", scheduler.TestScheduler},
+		{"Recurring Tasks", scheduler.TestRecurringTasks},
+		{"Task Executions", scheduler.TestTaskExecutions},
+		{"Natural Time", scheduler.TestNaturalTime},
+		{"DST Reminders", scheduler.TestDSTReminders},
+		{"Dead Letter Tasks", scheduler.TestDeadLetterTasks},
+		{"Task Locks", scheduler.TestTaskLocks},
+		{"Task Notifications", scheduler.TestTaskNotifications},
+		{"NATS Broker", broker.TestNATSBroker},
+		{"Retry Provider", ai.TestRetryProvider},
+		{"Retry Budget", ai.TestRetryBudget},
+		{"Circuit Breaker", ai.TestCircuitBreaker},
+		{"Fallback Chain", ai.TestFallbackChain},
+		{"Usage Tracker", ai.TestUsageTracker},
+		{"Caching Provider", ai.TestCachingProvider},
+		{"OpenAI Tool Calls", ai.TestOpenAIToolCalls},
+		{"OpenAI Overrides", ai.TestOpenAIOverrides},
+		{"Anthropic Tool Use", ai.TestAnthropicToolUse},
+		{"Gemini Messages", ai.TestGeminiMessages},
+		{"Gemini", ai.TestGemini},
+		{"Mistral", ai.TestMistral},
+		{"Ollama Streaming", ai.TestOllamaStreaming},
 		{"Agent", agent.TestAgent},
+		{"Conversation Replay", agent.TestReplaySession},
+		{"Prompt Library", agent.TestPromptLibrary},
+		{"Formatters", agent.TestFormatters},
+		{"Pre-processors", agent.TestPreProcessors},
+		{"Prompt Injection Suite", agent.TestPromptInjectionSuite},
+		{"Agent State", agent.TestAgentState},
+		{"Tool Executed Event", agent.TestToolExecutedEvent},
+		{"Tool Output Streaming", agent.TestToolOutputStreaming},
+		{"Dashboard", api.TestDashboard},
+		{"Chat Batch", api.TestChatBatch},
+		{"Tool Registration", api.TestToolRegistration},
+		{"File Upload", api.TestFileUpload},
+		{"Request IDs", api.TestRequestIDMiddleware},
+		{"Response Compression", api.TestCompressionMiddleware},
+		{"Request Size Limit", api.TestRequestSizeLimit},
+		{"Graceful Shutdown", api.TestGracefulShutdown},
+		{"Workflow Persistence", workflow.TestWorkflowPersistence},
+		{"Workflow Branching", workflow.TestBranchStep},
+		{"Workflow Interpolation", workflow.TestWorkflowInterpolation},
+		{"Workflow Timeout", workflow.TestWorkflowTimeout},
+		{"Async Workflow Execution", workflow.TestAsyncExecution},
+		{"Workflow Queue", workflow.TestWorkflowQueue},
+		{"Workflow Hooks", workflow.TestWorkflowHooks},
+		{"Chat Output", TestChatOutput},
 		{"Platform Structure", platforms.TestTelegram},
+		{"Telegram Topics", platforms.TestTelegramTopics},
+		{"Telegram Channels", platforms.TestTelegramChannels},
+		{"Telegram Reconnect", platforms.TestTelegramReconnect},
+		{"Telegram Streaming", platforms.TestTelegramStreaming},
+		{"Telegram Typing Indicator", platforms.TestTelegramTypingIndicator},
+		{"Discord Platform", platforms.TestDiscord},
+		{"Slack Platform", platforms.TestSlack},
+		{"Webhook Platform", platforms.TestWebhook},
 	}
 
 	passed := 0
@@ -277,13 +604,43 @@ func initConfig() {
 		},
 		Platforms: config.PlatformsConfig{
 			Telegram: config.TelegramConfig{
-				Enabled:      true,
+				Enabled:         true,
+				Token:           "",
+				AllowedUsers:    []string{},
+				TypingIndicator: true,
+				TopicIsolation:  true,
+
+				ChannelAllowList: []int64{},
+
+				MaxLength:          4096,
+				TruncationStrategy: "truncate",
+			},
+			Discord: config.DiscordConfig{
+				Enabled:      false,
 				Token:        "",
 				AllowedUsers: []string{},
+
+				AutoReconnect:        true,
+				ReconnectMaxAttempts: 10,
+
+				MaxLength:          2000,
+				TruncationStrategy: "split",
 			},
-			Discord: config.DiscordConfig{
-				Enabled: false,
-				Token:   "",
+			Slack: config.SlackConfig{
+				Enabled:      false,
+				AppToken:     "",
+				BotToken:     "",
+				AllowedUsers: []string{},
+
+				MaxReconnectAttempts: 10,
+				ReconnectBaseSeconds: 1,
+			},
+			Webhook: config.WebhookPlatformConfig{
+				Enabled:    false,
+				URL:        "",
+				Events:     []string{"message.processed", "tool.executed"},
+				Retry:      true,
+				MaxRetries: 3,
 			},
 		},
 		AI: config.AIConfig{
@@ -293,19 +650,49 @@ func initConfig() {
 			BaseURL:    "",
 			MaxTokens:  2000,
 			Temperature: 0.7,
+
+			TimeoutSeconds:    30,
+			MaxTimeoutSeconds: 300,
+
+			PromptLibraryDir: "prompts/",
+			Retry: config.RetryConfig{
+				Enabled:     true,
+				MaxAttempts: 3,
+				MaxBackoff:  30 * time.Second,
+			},
+			RetryBudget: config.RetryBudget{
+				MaxRetriesPerMinute:       10,
+				MaxTotalRetriesPerSession: 100,
+			},
+			CircuitBreaker: config.CircuitBreakerConfig{
+				Enabled:        true,
+				Threshold:      5,
+				TimeoutSeconds: 30,
+			},
+			PreProcessors: []string{"normalize"},
 		},
 		Memory: config.MemoryConfig{
 			Enabled:     true,
 			MaxMessages: 1000,
 			Storage:     "memory.db",
+
+			CleanupInterval: 3600,
+			EmbeddingModel:  "text-embedding-3-small",
+			Deduplicate:     true,
 		},
 		Scheduler: config.SchedulerConfig{
-			Enabled: true,
-			Storage: "scheduler.db",
+			Enabled:                  true,
+			Storage:                  "scheduler.db",
+			MaxOverdueAlertThreshold: 0,
+			HistoryRetentionDays:     30,
 		},
 		Tools: config.ToolsConfig{
-			Enabled:   true,
-			Directory: "tools/",
+			Enabled:         true,
+			Directory:       "tools/",
+			DefinitionsDir:  "tools/definitions/",
+			ShellTimeout:    30 * time.Second,
+			MaxResultBytes:  32768,
+			ResultBufferTTL: 10 * time.Minute,
 		},
 		Logging: config.LoggingConfig{
 			Level:       "INFO",
@@ -313,6 +700,33 @@ func initConfig() {
 			MaxSize:     10485760,
 			BackupCount: 5,
 		},
+		API: config.APIConfig{
+			Enabled:             false,
+			Port:                8080,
+			MaxRequestBodyBytes: 1048576,
+			MaxBatchSize:        50,
+			BatchMaxConcurrency: 5,
+			BatchMaxBodyBytes:   10485760,
+			MaxMessageLength:    10000,
+			MaxUploadBytes:      33554432,
+
+			DashboardAuditEntries: 20,
+			Compression: config.CompressionConfig{
+				Enabled:         true,
+				MinResponseSize: 1024,
+			},
+		},
+		Workflow: config.WorkflowConfig{
+			MaxConcurrentExecutions: 5,
+			QueueSize:               100,
+			Storage:                 "workflows.db",
+		},
+		Broker: config.BrokerConfig{
+			URL: "nats://127.0.0.1:4222",
+		},
+		CLI: config.CLIConfig{
+			ColorOutput: true,
+		},
 	}
 
 	data, err := yaml.Marshal(defaultConfig)
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"github.com/pmezard/go-difflib/difflib"
+
+	"github.com/Chang-Augenweide/QuickBot-Go/internal/agent"
+	"github.com/Chang-Augenweide/QuickBot-Go/internal/config"
+)
+
+// runReplay replays a stored conversation through a dry-run agent
+func runReplay() {
+	if replaySession == "" {
+		log.Fatalf("replay requires --session")
+	}
+
+	cfg, err := config.LoadConfig(configPath)
+	if err != nil {
+		log.Fatalf("Failed to load configuration: %v", err)
+	}
+
+	memory, err := agent.NewMemory(cfg.Memory.Storage, cfg.Memory.MaxMessages)
+	if err != nil {
+		log.Fatalf("Failed to initialize memory: %v", err)
+	}
+	defer memory.Close()
+
+	var provider agent.AIProvider
+	if replayCompare {
+		provider = agent.NewAIProvider(cfg)
+	}
+
+	replayLog, err := agent.ReplaySession(cfg, memory, replaySession, replayFrom, func(step *agent.ReplayStep) {
+		if provider != nil {
+			compareStep(provider, step)
+		}
+		printReplayStep(step)
+	})
+	if err != nil {
+		log.Fatalf("Replay failed: %v", err)
+	}
+
+	log.Printf("Replayed %d messages from session %s", len(replayLog.Steps), replaySession)
+
+	if replayOutput != "" {
+		data, err := json.MarshalIndent(replayLog, "", "  ")
+		if err != nil {
+			log.Fatalf("Failed to marshal replay log: %v", err)
+		}
+		if err := os.WriteFile(replayOutput, data, 0644); err != nil {
+			log.Fatalf("Failed to write replay log: %v", err)
+		}
+		log.Printf("Replay log saved to %s", replayOutput)
+	}
+}
+
+// compareStep sends the replayed chat messages to the real provider and
+// diffs its response against the replayed one
+func compareStep(provider agent.AIProvider, step *agent.ReplayStep) {
+	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	defer cancel()
+
+	actual, err := provider.ChatCompletion(ctx, step.ChatMessages)
+	if err != nil {
+		step.Error = fmt.Sprintf("compare failed: %v", err)
+		return
+	}
+	step.ActualResponse = actual
+
+	diff, err := difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+		A:        difflib.SplitLines(step.ReplayedResponse),
+		B:        difflib.SplitLines(actual),
+		FromFile: "replayed",
+		ToFile:   "actual",
+		Context:  3,
+	})
+	if err != nil {
+		step.Error = fmt.Sprintf("diff failed: %v", err)
+		return
+	}
+	step.Diff = diff
+}
+
+// printReplayStep prints the chat messages built for a replayed message
+func printReplayStep(step *agent.ReplayStep) {
+	fmt.Printf("=== Message #%d ===\n", step.MessageID)
+	for _, msg := range step.ChatMessages {
+		fmt.Printf("[%s] %s\n", msg.Role, msg.Content)
+	}
+	fmt.Printf("--- Response ---\n%s\n", step.ReplayedResponse)
+
+	if step.Error != "" {
+		fmt.Printf("--- Error ---\n%s\n", step.Error)
+	}
+	if step.ActualResponse != "" {
+		if step.Diff == "" {
+			fmt.Println("--- Actual response matches ---")
+		} else {
+			fmt.Printf("--- Diff ---\n%s", step.Diff)
+		}
+	}
+	fmt.Println()
+}
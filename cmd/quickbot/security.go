@@ -0,0 +1,41 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/Chang-Augenweide/QuickBot-Go/internal/agent"
+)
+
+// runSecurityTest runs the prompt injection test cases and exits with
+// code 1 if any case fails, for use in CI
+func runSecurityTest() {
+	cases, err := agent.LoadInjectionCases(securityCases)
+	if err != nil {
+		log.Fatalf("Failed to load test cases: %v", err)
+	}
+
+	suite := &agent.PromptInjectionTestSuite{
+		Detector: agent.NewPromptInjectionDetector(),
+		Cases:    cases,
+	}
+	results, allPassed := suite.Run()
+
+	failed := 0
+	for _, result := range results {
+		status := "✓ PASS"
+		if !result.Passed {
+			status = "✗ FAIL"
+			failed++
+		}
+		fmt.Printf("%s  %s (expected blocked=%v, got %v)\n",
+			status, result.Case.Description, result.Case.ExpectedBlocked, result.Result.Blocked)
+	}
+
+	fmt.Printf("\n%d/%d cases passed\n", len(results)-failed, len(results))
+
+	if !allPassed {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/Chang-Augenweide/QuickBot-Go/internal/config"
+)
+
+// ANSI colors for check results and chat output
+const (
+	colorGreen = "\033[32m"
+	colorRed   = "\033[31m"
+	colorBlue  = "\033[34m"
+	colorReset = "\033[0m"
+)
+
+// runValidate checks the configuration file without starting the bot and
+// exits with code 1 if any check fails
+func runValidate() {
+	color := isTerminal(os.Stdout)
+	mark := func(passed bool) string {
+		switch {
+		case passed && color:
+			return colorGreen + "✓" + colorReset
+		case passed:
+			return "✓"
+		case color:
+			return colorRed + "✗" + colorReset
+		default:
+			return "✗"
+		}
+	}
+
+	cfg, err := config.LoadConfig(configPath)
+	if err != nil {
+		fmt.Printf("%s load: %v\n", mark(false), err)
+		os.Exit(1)
+	}
+	fmt.Printf("%s load: %s\n", mark(true), configPath)
+
+	failed := 0
+	for _, result := range config.RunHealthChecks(cfg) {
+		if !result.Passed {
+			failed++
+		}
+		fmt.Printf("%s %s: %s\n", mark(result.Passed), result.Name, result.Message)
+	}
+
+	if failed > 0 {
+		fmt.Printf("\n%d check(s) failed\n", failed)
+		os.Exit(1)
+	}
+	fmt.Println("\nConfiguration OK")
+}
+
+// isTerminal reports whether f is a terminal
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
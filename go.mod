@@ -1,10 +1,38 @@
 module github.com/Chang-Augenweide/QuickBot-Go
 
-go 1.22.1
+go 1.23
 
 require (
 	github.com/mattn/go-sqlite3 v1.14.34
 	github.com/robfig/cron/v3 v3.0.1
 	gopkg.in/yaml.v3 v3.0.1
-	github.com/go-telegram-bot-api/telegram-bot-api/v5 v5.5.1
+	github.com/OvyFlash/telegram-bot-api v0.0.0-20241219171906-3f2ca0c14ada
+	github.com/pmezard/go-difflib v1.0.0
+	github.com/google/uuid v1.6.0
+	github.com/andybalholm/brotli v1.1.0
+	github.com/nats-io/nats.go v1.37.0
+	github.com/prometheus/client_golang v1.19.1
+	github.com/pemistahl/lingua-go v1.4.0
+	github.com/bwmarrin/discordgo v0.28.1
+	github.com/gorilla/websocket v1.4.2
+	github.com/slack-go/slack v0.14.0
+	github.com/hashicorp/golang-lru/v2 v2.0.7
+	golang.org/x/net v0.30.0
+	github.com/fsnotify/fsnotify v1.7.0
+)
+
+require (
+	github.com/beorn7/perks v1.0.1 // indirect
+	github.com/cespare/xxhash/v2 v2.2.0 // indirect
+	github.com/klauspost/compress v1.17.2 // indirect
+	github.com/nats-io/nkeys v0.4.7 // indirect
+	github.com/nats-io/nuid v1.0.1 // indirect
+	github.com/prometheus/client_model v0.5.0 // indirect
+	github.com/prometheus/common v0.48.0 // indirect
+	github.com/prometheus/procfs v0.12.0 // indirect
+	github.com/shopspring/decimal v1.3.1 // indirect
+	golang.org/x/crypto v0.28.0 // indirect
+	golang.org/x/exp v0.0.0-20221106115401-f9659909a136 // indirect
+	golang.org/x/sys v0.26.0 // indirect
+	google.golang.org/protobuf v1.33.0 // indirect
 )
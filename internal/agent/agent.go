@@ -3,19 +3,36 @@ package main
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"os"
 	"os/exec"
-	"regexp"
+	"sort"
 	"strings"
+	"sync"
 	"time"
+	"unicode/utf8"
+
+	"github.com/Chang-Augenweide/QuickBot-Go/internal/events"
+	"github.com/Chang-Augenweide/QuickBot-Go/pkg/types"
 )
 
-// AIProvider represents AI provider interface
+// AIProvider represents AI provider interface. ToolCallCompletion offers
+// tools to the model, which may reply with tool calls instead of text.
 type AIProvider interface {
 	ProviderName() string
 	ChatCompletion(ctx context.Context, messages []Message) (string, error)
+	ToolCallCompletion(ctx context.Context, messages []Message, tools []types.ToolSpec) (types.Completion, error)
+}
+
+// StreamingProvider is an AIProvider that can send its response as it is
+// generated. ChatCompletionStream calls cb with each part of the response
+// and returns once it is complete. Streamed requests do not offer tools.
+type StreamingProvider interface {
+	AIProvider
+	ChatCompletionStream(ctx context.Context, messages []Message, cb func(string)) error
+	SupportsStreaming() bool
 }
 
 // OpenAIProvider represents OpenAI API
@@ -43,6 +60,11 @@ func (p *OpenAIProvider) ChatCompletion(ctx context.Context, messages []Message)
 	return fmt.Sprintf("[OpenAI response for model %s]", p.model), nil
 }
 
+func (p *OpenAIProvider) ToolCallCompletion(ctx context.Context, messages []Message, tools []types.ToolSpec) (types.Completion, error) {
+	content, err := p.ChatCompletion(ctx, messages)
+	return types.Completion{Content: content}, err
+}
+
 // AnthropicProvider represents Anthropic API
 type AnthropicProvider struct {
 	apiKey string
@@ -65,6 +87,11 @@ func (p *AnthropicProvider) ChatCompletion(ctx context.Context, messages []Messa
 	return fmt.Sprintf("[Anthropic response for model %s]", p.model), nil
 }
 
+func (p *AnthropicProvider) ToolCallCompletion(ctx context.Context, messages []Message, tools []types.ToolSpec) (types.Completion, error) {
+	content, err := p.ChatCompletion(ctx, messages)
+	return types.Completion{Content: content}, err
+}
+
 // OllamaProvider represents Ollama API
 type OllamaProvider struct {
 	baseURL string
@@ -87,46 +114,180 @@ func (p *OllamaProvider) ChatCompletion(ctx context.Context, messages []Message)
 	return fmt.Sprintf("[Ollama response for model %s]", p.model), nil
 }
 
+func (p *OllamaProvider) ToolCallCompletion(ctx context.Context, messages []Message, tools []types.ToolSpec) (types.Completion, error) {
+	content, err := p.ChatCompletion(ctx, messages)
+	return types.Completion{Content: content}, err
+}
+
+func (p *OllamaProvider) ChatCompletionStream(ctx context.Context, messages []Message, cb func(string)) error {
+	content, err := p.ChatCompletion(ctx, messages)
+	if err != nil {
+		return err
+	}
+	cb(content)
+	return nil
+}
+
+func (p *OllamaProvider) SupportsStreaming() bool {
+	return true
+}
+
 // Message represents chat message
 type Message struct {
-	Role    string `json:"role"`
-	Content string `json:"content"`
+	Role       string           `json:"role"`
+	Content    string           `json:"content"`
+	ToolCalls  []types.ToolCall `json:"tool_calls,omitempty"`
+	ToolCallID string           `json:"tool_call_id,omitempty"`
 }
 
 // ToolCall represents a tool call
 type ToolCall struct {
-	Name string                 `json:"name"`
-	Args map[string]string      `json:"args"`
+	ID   string            `json:"id,omitempty"`
+	Name string            `json:"name"`
+	Args map[string]string `json:"args"`
 }
 
+// maxToolCallRounds limits the tool calls made for a single message
+const maxToolCallRounds = 5
+
+// usageLogInterval is how often the AI token usage is logged
+const usageLogInterval = time.Hour
+
+// defaultCacheSize bounds the response cache when ai.cache_enabled is set
+// without ai.cache_size
+const defaultCacheSize = 1000
+
 // Agent represents AI agent
 type Agent struct {
-	config         *Config
-	memory         *Memory
-	scheduler      *Scheduler
-	toolRegistry   *ToolRegistry
-	aiProvider     AIProvider
-	systemPrompt   string
-	memoryContext  int
+	config        *Config
+	memory        *Memory
+	scheduler     *Scheduler
+	toolRegistry  *ToolRegistry
+	toolLoader    *ToolLoader
+	stopToolWatch context.CancelFunc
+	aiProvider    AIProvider
+	systemPrompt  string
+	memoryContext int
+	metrics       *Metrics
+	circuit       *CircuitBreaker
+	retry         *RetryProvider
+	usage         *UsageTracker
+	responses     *CachingProvider
+	streamer      StreamingProvider
+	preprocessors PreProcessorPipeline
+	events        *events.EventBus
+	prompts       *PromptLibrary
+	startedAt     time.Time
+
+	formatMu   sync.RWMutex
+	formatters map[string]Formatter
+
+	stateMu       sync.RWMutex
+	state         AgentState
+	processing    int
+	onStateChange StateChangeCallback
 }
 
-func NewAgent(config *Config, memory *Memory, scheduler *Scheduler) *Agent {
-	// Initialize AI provider
+// defaultFallbackModels are used for fallback providers given without a
+// model; Gemini and Mistral default their model themselves
+var defaultFallbackModels = map[string]string{
+	"openai":    "gpt-4o-mini",
+	"anthropic": "claude-3-5-haiku-20241022",
+	"ollama":    "llama3",
+}
+
+// NewAIProvider creates the AI provider selected in the configuration.
+// With ai.fallback_providers, it is the primary provider of a fallback
+// chain.
+func NewAIProvider(config *Config) AIProvider {
+	provider := newAIProvider(config, config.AI.Provider, config.AI.Model, config.AI.BaseURL)
+	if len(config.AI.FallbackProviders) == 0 {
+		return provider
+	}
+
+	// Fallback providers are "name" or "name:model" and share the API key
+	providers := []AIProvider{provider}
+	for _, spec := range config.AI.FallbackProviders {
+		name, model, _ := strings.Cut(spec, ":")
+		if model == "" {
+			model = defaultFallbackModels[name]
+		}
+		providers = append(providers, newAIProvider(config, name, model, ""))
+	}
+	return NewFallbackChain(providers...)
+}
+
+// newAIProvider creates the named provider
+func newAIProvider(config *Config, name, model, baseURL string) AIProvider {
 	var provider AIProvider
 
-	switch config.AI.Provider {
+	switch name {
 	case "openai":
-		provider = NewOpenAIProvider(config.AI.APIKey, config.AI.BaseURL, config.AI.Model)
+		provider = NewOpenAIProvider(config.AI.APIKey, baseURL, model)
 	case "anthropic":
-		provider = NewAnthropicProvider(config.AI.APIKey, config.AI.Model)
+		provider = NewAnthropicProvider(config.AI.APIKey, model)
 	case "ollama":
-		baseURL := config.AI.BaseURL
 		if baseURL == "" {
 			baseURL = "http://localhost:11434"
 		}
-		provider = NewOllamaProvider(baseURL, config.AI.Model)
+		provider = NewOllamaProvider(baseURL, model)
+	case "gemini":
+		provider = NewGeminiProvider(config.AI.APIKey, model, config.AI.GeminiProject, config.AI.GeminiLocation)
+	case "mistral":
+		if model != "" {
+			if err := ValidateMistralModel(model); err != nil {
+				log.Printf("Warning: %v", err)
+			}
+		}
+		provider = NewMistralProvider(config.AI.APIKey, baseURL, model)
 	default:
-		provider = NewOpenAIProvider(config.AI.APIKey, config.AI.BaseURL, config.AI.Model)
+		provider = NewOpenAIProvider(config.AI.APIKey, baseURL, model)
+	}
+
+	// The response cache relies on the provider using ai.temperature
+	if p, ok := provider.(interface{ SetTemperature(float64) }); ok {
+		p.SetTemperature(config.AI.Temperature)
+	}
+
+	return provider
+}
+
+func NewAgent(config *Config, memory *Memory, scheduler *Scheduler) *Agent {
+	// Initialize AI provider, counting the tokens of each request
+	base := NewAIProvider(config)
+	usage := NewUsageTracker(base)
+	var provider AIProvider = usage
+
+	// Retry rate-limited and failed requests
+	var retry *RetryProvider
+	if retryConfig := config.AI.EffectiveRetry(); retryConfig.Enabled {
+		retry = NewRetryProvider(provider, RetryConfig{
+			MaxAttempts: retryConfig.MaxAttempts,
+			MaxBackoff:  retryConfig.MaxBackoff,
+			Budget: RetryBudget{
+				MaxRetriesPerMinute:       config.AI.RetryBudget.MaxRetriesPerMinute,
+				MaxTotalRetriesPerSession: config.AI.RetryBudget.MaxTotalRetriesPerSession,
+			},
+		})
+		provider = retry
+	}
+
+	// Stop calling the provider while it is failing
+	var circuit *CircuitBreaker
+	if config.AI.CircuitBreaker.Enabled {
+		circuit = NewCircuitBreaker(provider, config.AI.CircuitBreaker.Threshold,
+			time.Duration(config.AI.CircuitBreaker.TimeoutSeconds)*time.Second)
+		provider = circuit
+	}
+
+	// Answer identical deterministic requests from the cache; with
+	// ai.cache_enabled, processMessage also caches answers to conversations
+	var responses *CachingProvider
+	if config.AI.CacheSize > 0 {
+		responses = NewCachingProvider(provider, config.AI.CacheSize, config.AI.CacheTTL, config.AI.Temperature)
+		provider = responses
+	} else if config.AI.CacheEnabled {
+		responses = NewCachingProvider(provider, defaultCacheSize, config.AI.CacheTTL, config.AI.Temperature)
 	}
 
 	agent := &Agent{
@@ -137,10 +298,34 @@ func NewAgent(config *Config, memory *Memory, scheduler *Scheduler) *Agent {
 		aiProvider:    provider,
 		systemPrompt:  buildSystemPrompt(),
 		memoryContext: config.Memory.MaxMessages,
+		metrics:       &Metrics{},
+		circuit:       circuit,
+		retry:         retry,
+		usage:         usage,
+		responses:     responses,
+		events:        events.NewEventBus(),
+		startedAt:     time.Now(),
+	}
+
+	// Streamed requests go to the provider directly: a partly sent
+	// response cannot be retried
+	if streamer, ok := base.(StreamingProvider); ok && streamer.SupportsStreaming() {
+		agent.streamer = streamer
+	}
+
+	if config.AI.PromptLibraryDir != "" {
+		prompts, err := NewPromptLibrary(config.AI.PromptLibraryDir)
+		if err != nil {
+			log.Printf("Warning: %v", err)
+		} else {
+			agent.prompts = prompts
+		}
 	}
 
 	// Register tools
 	agent.registerTools()
+	agent.registerFormatters()
+	agent.preprocessors = agent.buildPreProcessors()
 
 	return agent
 }
@@ -153,11 +338,7 @@ You have access to these tools:
 - file: Read/write/list files
 - shell: Execute shell commands
 - calculator: Perform calculations
-- memory: Store/retrieve long-term information
-
-When you need to use a tool, format your response as:
-TOOL: tool_name
-ARGS: {"key":"value"}
+- memory: Store/retrieve/list/search/delete long-term information
 
 You should be helpful, polite, and concise.`
 }
@@ -170,7 +351,7 @@ func (a *Agent) registerTools() {
 		a.toolRegistry.Register(fileTool)
 
 		// Shell tool
-		shellTool := NewShellTool([]string{"echo", "ls", "pwd", "cat", "grep"})
+		shellTool := NewShellTool([]string{"echo", "ls", "pwd", "cat", "grep"}, a.config.Tools.ShellWorkDir, a.config.Tools.ShellTimeout)
 		a.toolRegistry.Register(shellTool)
 
 		// Calculator tool
@@ -178,23 +359,134 @@ func (a *Agent) registerTools() {
 		a.toolRegistry.Register(calcTool)
 
 		// Memory tool
-		memTool := &MemoryTool{memory: a.memory}
+		memTool := NewMemoryTool(a.memory)
 		a.toolRegistry.Register(memTool)
+
+		// HTTP tool
+		if a.config.Tools.AllowHTTP {
+			allowList, err := CompileAllowList(a.config.Tools.HTTPAllowedDomains)
+			if err == nil {
+				err = allowList.SetDenyList(a.config.Tools.HTTPDeniedDomains)
+			}
+			if err != nil {
+				log.Printf("Warning: HTTP and scrape tools disabled: %v", err)
+			} else {
+				allowList.BlockPrivateIPs = !a.config.Tools.HTTPAllowPrivateIPs
+				a.toolRegistry.Register(NewHTTPTool(allowList))
+				a.toolRegistry.Register(NewWebScrapeTool(allowList))
+			}
+		}
+
+		// Tools defined in YAML files, including script tools registered
+		// at runtime and persisted, reloaded when the files change
+		a.toolLoader = NewToolLoader(a.toolRegistry, a.config.Tools.DefinitionsDir)
+		if err := a.toolLoader.Load(); err != nil {
+			log.Printf("Warning: Failed to load tool definitions: %v", err)
+		}
+
+		a.toolRegistry.SetPagination(a.config.Tools.MaxResultBytes, a.config.Tools.ResultBufferTTL)
+
+		a.toolRegistry.AddHook(MetricsHook())
+		if a.config.Bot.Debug {
+			a.toolRegistry.AddHook(LoggingHook())
+		}
 	}
 }
 
 // ProcessMessage processes user message and generates response
-func (a *Agent) ProcessMessage(sessionID, userMessage string) (string, error) {
-	// Store user message
-	_, err := a.memory.AddMessage(sessionID, "user", userMessage, nil)
+func (a *Agent) ProcessMessage(ctx context.Context, sessionID, userMessage string) (string, error) {
+	response, _, err := a.ProcessMessageWithCacheStatus(ctx, sessionID, userMessage)
+	return response, err
+}
+
+// ProcessMessageWithCacheStatus processes user message and also reports
+// whether the AI response was served from the response cache
+func (a *Agent) ProcessMessageWithCacheStatus(ctx context.Context, sessionID, userMessage string) (string, bool, error) {
+	a.beginProcessing()
+	response, cacheHit, err := a.processMessage(ctx, sessionID, userMessage, nil)
+	a.endProcessing(err)
+	return response, cacheHit, err
+}
+
+// ProcessMessageWithStream processes user message, forwarding the output
+// of streaming tools to toolOut while they run when tool streaming is
+// enabled. toolOut is closed once the message is processed.
+func (a *Agent) ProcessMessageWithStream(sessionID, userMessage string, toolOut chan<- string) (string, error) {
+	defer close(toolOut)
+
+	var out chan<- string
+	if a.config.Tools.StreamingEnabled {
+		out = toolOut
+	}
+
+	a.beginProcessing()
+	response, _, err := a.processMessage(context.Background(), sessionID, userMessage, out)
+	a.endProcessing(err)
+	return response, err
+}
+
+// SupportsStreaming reports whether AI responses can be streamed with
+// ProcessMessageStreaming
+func (a *Agent) SupportsStreaming() bool {
+	return a.streamer != nil
+}
+
+// ProcessMessageStreaming processes user message, sending the AI response
+// to tokens as it is generated if the provider supports streaming. Cached
+// responses and responses of providers that cannot stream are only
+// returned. tokens is closed once the message is processed.
+func (a *Agent) ProcessMessageStreaming(ctx context.Context, sessionID, userMessage string, tokens chan<- string) (string, error) {
+	defer close(tokens)
+
+	if a.streamer != nil {
+		ctx = withTokenStream(ctx, func(token string) {
+			tokens <- token
+		})
+	}
+
+	a.beginProcessing()
+	response, _, err := a.processMessage(ctx, sessionID, userMessage, nil)
+	a.endProcessing(err)
+	return response, err
+}
+
+// processMessage processes user message; with a non-nil toolOut, tool
+// output is streamed to it
+func (a *Agent) processMessage(ctx context.Context, sessionID, userMessage string, toolOut chan<- string) (string, bool, error) {
+	ctx = types.WithSessionID(ctx, sessionID)
+	a.publish(events.TopicMessageReceived, sessionID, userMessage)
+
+	userMessage, err := a.preprocessors.Run(sessionID, userMessage)
 	if err != nil {
-		return "", err
+		return "", false, fmt.Errorf("failed to preprocess message: %w", err)
+	}
+
+	// Store user message; a redelivery of a stored message was already
+	// answered and is dropped
+	var metadata map[string]interface{}
+	platform, platformID := types.PlatformFromContext(ctx), types.PlatformMessageIDFromContext(ctx)
+	if platform != "" || platformID != "" {
+		metadata = map[string]interface{}{}
+		if platform != "" {
+			metadata[MetaPlatform] = platform
+		}
+		if platformID != "" {
+			metadata[MetaPlatformMessageID] = platformID
+		}
+	}
+	_, redelivered, err := a.memory.AddMessage(sessionID, "user", userMessage, metadata)
+	if err != nil {
+		return "", false, err
+	}
+	if redelivered {
+		a.logf(ctx, "Dropped redelivered message in session %s", sessionID)
+		return "", false, ErrRedelivered
 	}
 
 	// Get conversation context
 	messages, err := a.memory.GetMessages(sessionID, a.memoryContext)
 	if err != nil {
-		return "", err
+		return "", false, err
 	}
 
 	// Build chat messages
@@ -203,7 +495,7 @@ func (a *Agent) ProcessMessage(sessionID, userMessage string) (string, error) {
 	// Add system prompt
 	chatMessages = append(chatMessages, Message{
 		Role:    "system",
-		Content: a.systemPrompt,
+		Content: a.systemPromptFor(sessionID),
 	})
 
 	// Add conversation history
@@ -214,84 +506,306 @@ func (a *Agent) ProcessMessage(sessionID, userMessage string) (string, error) {
 		})
 	}
 
-	// Get AI response
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
-	defer cancel()
-
-	response, err := a.aiProvider.ChatCompletion(ctx, chatMessages)
+	// Apply the session's AI parameter overrides
+	model, temperature := a.config.AI.Model, a.config.AI.Temperature
+	overrides, err := a.memory.GetAIOverrides(sessionID)
 	if err != nil {
-		return "", err
+		a.logf(ctx, "Warning: failed to load AI overrides for session %s: %v", sessionID, err)
+	} else if overrides != nil {
+		ctx = types.WithAIOverrides(ctx, *overrides)
+		if overrides.Model != "" {
+			model = overrides.Model
+		}
+		if overrides.Temperature != nil {
+			temperature = *overrides.Temperature
+		}
 	}
 
-	// Check if response contains tool call
-	if strings.HasPrefix(response, "TOOL:") {
-		return a.handleToolCall(sessionID, response)
+	// Get AI response, from cache if possible. Only deterministic requests
+	// are cached, and responses that call tools are not, so that the tools
+	// run every time.
+	tools := a.toolSpecs()
+	var completion types.Completion
+	var cacheKey string
+	cacheHit := false
+
+	useCache := a.config.AI.CacheEnabled && a.responses != nil && temperature == 0
+	if useCache {
+		cacheKey, err = MessagesKey(a.aiProvider.ProviderName(), model, temperature, chatMessages)
+		useCache = err == nil
+	}
+	if useCache {
+		completion, cacheHit = a.responses.Lookup(cacheKey, sessionID)
+		if cacheHit {
+			a.metrics.IncCacheHits()
+		} else {
+			a.metrics.IncCacheMisses()
+		}
 	}
 
+	if !cacheHit {
+		completion, err = a.complete(ctx, sessionID, toolOut != nil, chatMessages, tools)
+		if err != nil {
+			return "", false, err
+		}
+
+		if useCache && len(completion.ToolCalls) == 0 {
+			a.responses.Store(cacheKey, sessionID, completion)
+		}
+	}
+
+	// Run the first requested tool and send its result back to the model,
+	// until it answers without calling a tool
+	for round := 0; len(completion.ToolCalls) > 0; round++ {
+		if round == maxToolCallRounds {
+			return "", false, fmt.Errorf("too many tool calls, stopped after %d", maxToolCallRounds)
+		}
+
+		call := completion.ToolCalls[0]
+		toolCall, err := a.parseToolCall(call)
+		if err != nil {
+			return "", false, err
+		}
+		result, err := a.handleToolCall(ctx, sessionID, toolCall, toolOut)
+		if err != nil {
+			return "", false, err
+		}
+
+		chatMessages = append(chatMessages,
+			Message{Role: "assistant", Content: completion.Content, ToolCalls: []types.ToolCall{call}},
+			Message{Role: "tool", Content: result, ToolCallID: call.ID},
+		)
+		completion, err = a.complete(ctx, sessionID, toolOut != nil, chatMessages, tools)
+		if err != nil {
+			return "", false, err
+		}
+	}
+	response := completion.Content
+
 	// Store assistant response
-	_, err = a.memory.AddMessage(sessionID, "assistant", response, nil)
+	_, _, err = a.memory.AddMessage(sessionID, "assistant", response, map[string]interface{}{
+		MetaProvider: a.aiProvider.ProviderName(),
+		MetaModel:    model,
+	})
 	if err != nil {
-		log.Printf("Failed to store response: %v", err)
+		a.logf(ctx, "Failed to store response: %v", err)
+	}
+
+	a.publish(events.TopicMessageProcessed, sessionID, response)
+
+	return response, cacheHit, nil
+}
+
+// SetSessionAIOverrides validates and stores the AI parameter overrides of
+// a session. max_tokens may be at most twice the configured max_tokens
+// and temperature must be between 0 and 2.
+func (a *Agent) SetSessionAIOverrides(sessionID string, overrides types.AIOverrides) error {
+	if overrides.MaxTokens < 0 {
+		return fmt.Errorf("max_tokens must be positive")
+	}
+	if limit := a.config.AI.MaxTokens * 2; limit > 0 && overrides.MaxTokens > limit {
+		return fmt.Errorf("max_tokens must be at most %d", limit)
+	}
+	if t := overrides.Temperature; t != nil && (*t < 0 || *t > 2) {
+		return fmt.Errorf("temperature must be between 0.0 and 2.0")
 	}
 
-	return response, nil
+	return a.memory.SetAIOverrides(sessionID, overrides)
 }
 
-// handleToolCall handles tool calls
-func (a *Agent) handleToolCall(sessionID, response string) (string, error) {
-	// Parse tool call
-	toolCall, err := a.parseToolCall(response)
+// logf logs a message, prefixed with the request ID of ctx if any
+func (a *Agent) logf(ctx context.Context, format string, args ...interface{}) {
+	if requestID := types.RequestIDFromContext(ctx); requestID != "" {
+		format = "[" + requestID + "] " + format
+	}
+	log.Printf(format, args...)
+}
+
+// complete requests a completion offering tools, within the AI timeout of
+// the session
+func (a *Agent) complete(ctx context.Context, sessionID string, streaming bool, messages []Message, tools []types.ToolSpec) (types.Completion, error) {
+	stream, streamTokens := tokenStreamFromContext(ctx)
+	streamTokens = streamTokens && a.streamer != nil
+
+	ctx, cancel := context.WithTimeout(ctx, a.aiTimeout(ctx, sessionID, streaming || streamTokens))
+	defer cancel()
+
+	var completion types.Completion
+	var err error
+	if streamTokens {
+		completion, err = a.completeStream(ctx, messages, stream)
+	} else {
+		completion, err = a.aiProvider.ToolCallCompletion(ctx, messages, tools)
+	}
+
+	// Providers may return the tool call as an error instead
+	var toolCallErr *ErrToolCallRequired
+	if errors.As(err, &toolCallErr) {
+		completion, err = types.Completion{ToolCalls: []types.ToolCall{toolCallErr.Call}, FinishReason: "tool_use"}, nil
+	}
 	if err != nil {
-		return "", err
+		a.logf(ctx, "AI request failed for session %s: %v", sessionID, err)
+		return types.Completion{}, err
+	}
+	a.publish(events.TopicAIResponse, sessionID, completion.Content)
+	return completion, nil
+}
+
+// completeStream gets the AI response from the streaming provider,
+// passing each part of it to stream
+func (a *Agent) completeStream(ctx context.Context, messages []Message, stream func(string)) (types.Completion, error) {
+	var content strings.Builder
+	err := a.streamer.ChatCompletionStream(ctx, messages, func(token string) {
+		content.WriteString(token)
+		stream(token)
+	})
+	if err != nil {
+		return types.Completion{}, err
+	}
+	return types.Completion{Content: content.String(), FinishReason: "stop"}, nil
+}
+
+type tokenStreamKey struct{}
+
+// withTokenStream returns a context whose AI responses are streamed to
+// stream
+func withTokenStream(ctx context.Context, stream func(string)) context.Context {
+	return context.WithValue(ctx, tokenStreamKey{}, stream)
+}
+
+// tokenStreamFromContext returns the token stream of ctx, if it has one
+func tokenStreamFromContext(ctx context.Context) (func(string), bool) {
+	stream, ok := ctx.Value(tokenStreamKey{}).(func(string))
+	return stream, ok
+}
+
+// toolSpecs describes the registered tools for function calling, sorted
+// by name
+func (a *Agent) toolSpecs() []types.ToolSpec {
+	var specs []types.ToolSpec
+	for name, tool := range a.toolRegistry.GetAll() {
+		specs = append(specs, types.ToolSpec{
+			Name:        name,
+			Description: tool.Description(),
+			Parameters:  tool.Schema(),
+		})
+	}
+	sort.Slice(specs, func(i, j int) bool { return specs[i].Name < specs[j].Name })
+	return specs
+}
+
+// handleToolCall handles tool calls, streaming tool output to toolOut if
+// it is not nil
+func (a *Agent) handleToolCall(ctx context.Context, sessionID string, toolCall *ToolCall, toolOut chan<- string) (string, error) {
+	// Store tool call, so that the result and anything the tool
+	// remembers can be traced back to it
+	callID, _, err := a.memory.AddMessage(sessionID, "assistant", fmt.Sprintf("[Tool call: %s]", toolCall.Name), map[string]interface{}{
+		MetaToolName:   toolCall.Name,
+		MetaToolStatus: ToolStatusRunning,
+		"args":         toolCall.Args,
+	})
+	if err != nil {
+		log.Printf("Failed to store tool call: %v", err)
+	} else {
+		ctx = types.WithSourceMessageID(ctx, callID)
 	}
 
 	// Execute tool
-	result, err := a.toolRegistry.Execute(toolCall.Name, toolCall.Args)
+	var toolResult types.ToolResult
+	if toolOut != nil {
+		toolResult, err = a.toolRegistry.ExecuteStream(ctx, toolCall.Name, toolCall.Args, toolOut)
+	} else {
+		toolResult, err = a.toolRegistry.Execute(ctx, toolCall.Name, toolCall.Args)
+	}
+
+	if callID != 0 {
+		status := ToolStatusSuccess
+		if err != nil {
+			status = ToolStatusError
+		}
+		if err := a.memory.SetMessageMetadata(callID, MetaToolStatus, status); err != nil {
+			log.Printf("Failed to store tool call status: %v", err)
+		}
+	}
 	if err != nil {
 		return "", err
 	}
+	result := FormatToolResult(toolResult)
 
 	// Store tool result
-	_, err = a.memory.AddMessage(sessionID, "assistant", fmt.Sprintf("[Tool: %s] %s", toolCall.Name, result), nil)
+	var resultMetadata map[string]interface{}
+	if callID != 0 {
+		resultMetadata = map[string]interface{}{"tool_call_id": callID}
+	}
+	_, _, err = a.memory.AddMessage(sessionID, "assistant", fmt.Sprintf("[Tool: %s] %s", toolCall.Name, result), resultMetadata)
 	if err != nil {
 		log.Printf("Failed to store tool result: %v", err)
 	}
 
+	a.publish(events.TopicToolExecuted, sessionID, fmt.Sprintf("[Tool: %s] %s", toolCall.Name, result))
+
 	return result, nil
 }
 
-// parseToolCall parses tool call from response
-func (a *Agent) parseToolCall(response string) (*ToolCall, error) {
-	// Parse TOOL: name line
-	reTool := regexp.MustCompile(`^TOOL:\s*(\S+)`)
-	matches := reTool.FindStringSubmatch(response)
-	if len(matches) < 2 {
-		return nil, fmt.Errorf("invalid tool call format")
-	}
-
-	toolName := matches[1]
-
-	// Parse ARGS: json line
-	reArgs := regexp.MustCompile(`ARGS:\s*(\{.+\})`)
-	matches = reArgs.FindStringSubmatch(response)
-	if len(matches) < 2 {
-		return nil, fmt.Errorf("invalid args format")
+// parseToolCall decodes a tool call requested by the model. Arguments
+// that are not strings are passed on as JSON text, and converted to the
+// types the tool declares when it is executed.
+func (a *Agent) parseToolCall(call types.ToolCall) (*ToolCall, error) {
+	var rawArgs map[string]interface{}
+	if strings.TrimSpace(call.Arguments) != "" {
+		if err := json.Unmarshal([]byte(call.Arguments), &rawArgs); err != nil {
+			return nil, fmt.Errorf("failed to parse args of tool %s: %w", call.Name, err)
+		}
 	}
 
-	argsJSON := matches[2]
-
-	var args map[string]string
-	err := json.Unmarshal([]byte(argsJSON), &args)
-	if err != nil {
-		return nil, fmt.Errorf("failed to parse args: %w", err)
+	args := make(map[string]string, len(rawArgs))
+	for name, value := range rawArgs {
+		switch v := value.(type) {
+		case nil:
+		case string:
+			args[name] = v
+		default:
+			data, err := json.Marshal(v)
+			if err != nil {
+				return nil, fmt.Errorf("failed to parse args of tool %s: %w", call.Name, err)
+			}
+			args[name] = string(data)
+		}
 	}
 
 	return &ToolCall{
-		Name: toolName,
+		ID:   call.ID,
+		Name: call.Name,
 		Args: args,
 	}, nil
 }
 
+// InvalidateCache removes all cached responses served to a session and
+// returns the number of evicted entries
+func (a *Agent) InvalidateCache(sessionID string) int {
+	if a.responses == nil {
+		return 0
+	}
+	return a.responses.InvalidateSession(sessionID)
+}
+
+// ClearCache removes all cached responses and returns the number of evicted entries
+func (a *Agent) ClearCache() int {
+	if a.responses == nil {
+		return 0
+	}
+	return a.responses.Purge()
+}
+
+// CacheStats returns the hit and miss counts of the response cache,
+// enabled by ai.cache_size or ai.cache_enabled
+func (a *Agent) CacheStats() CacheStats {
+	if a.responses == nil {
+		return CacheStats{}
+	}
+	return a.responses.Stats()
+}
+
 // GetMemoryContext retrieves context from memory
 func (a *Agent) GetMemoryContext(sessionID string) ([]byte, error) {
 	messages, err := a.memory.GetMessages(sessionID, a.memoryContext)
@@ -310,7 +824,7 @@ func (a *Agent) GetMemoryContext(sessionID string) ([]byte, error) {
 
 // SetMemory stores value in long-term memory
 func (a *Agent) SetMemory(key, value string) error {
-	return a.memory.SetLongTerm(key, value, 2)
+	return a.memory.SetLongTerm(key, value, 2, 0)
 }
 
 // GetMemory retrieves value from long-term memory
@@ -318,8 +832,9 @@ func (a *Agent) GetMemory(key string) (string, error) {
 	return a.memory.GetLongTerm(key)
 }
 
-// AddReminder adds a reminder
-func (a *Agent) AddReminder(sessionID, message, remindAt string) (string, error) {
+// AddReminder adds a reminder. The warning is set when the reminder time
+// was moved because of a daylight saving time change.
+func (a *Agent) AddReminder(sessionID, message, remindAt string) (string, string, error) {
 	return a.scheduler.AddReminder(sessionID, message, remindAt)
 }
 
@@ -328,6 +843,18 @@ func (a *Agent) Start() {
 	if a.scheduler != nil && a.config.Scheduler.Enabled {
 		a.scheduler.Start()
 	}
+	if a.usage != nil {
+		a.usage.StartLogging(usageLogInterval)
+	}
+	if a.toolLoader != nil {
+		ctx, cancel := context.WithCancel(context.Background())
+		a.stopToolWatch = cancel
+		go func() {
+			if err := a.toolLoader.Watch(ctx); err != nil {
+				log.Printf("Warning: Tool definitions not watched: %v", err)
+			}
+		}()
+	}
 	log.Printf("Agent started: %s (AI: %s, Model: %s)",
 		a.config.Bot.Name, a.aiProvider.ProviderName(), a.config.AI.Model)
 }
@@ -337,17 +864,18 @@ func (a *Agent) Stop() {
 	if a.scheduler != nil {
 		a.scheduler.Stop()
 	}
+	if a.usage != nil {
+		a.usage.StopLogging()
+	}
+	if a.stopToolWatch != nil {
+		a.stopToolWatch()
+	}
 	if a.memory != nil {
 		a.memory.Close()
 	}
 	log.Println("Agent stopped")
 }
 
-// MemoryTool is the memory tool
-type MemoryTool struct {
-	memory *Memory
-}
-
 // Config returns the agent config
 func (a *Agent) Config() *Config {
 	return a.config
@@ -368,50 +896,156 @@ func (a *Agent) ToolRegistry() *ToolRegistry {
 	return a.toolRegistry
 }
 
-func (t *MemoryTool) Name() string {
-	return "memory"
+// Uptime returns the time since the agent was created
+func (a *Agent) Uptime() time.Duration {
+	return time.Since(a.startedAt)
 }
 
-func (t *MemoryTool) Description() string {
-	return "Store and retrieve long-term information"
+// Metrics returns the agent metrics
+func (a *Agent) Metrics() *Metrics {
+	return a.metrics
 }
 
-func (t *MemoryTool) Permission() string {
-	return "allow_all"
+// EventBus returns the agent event bus
+func (a *Agent) EventBus() *events.EventBus {
+	return a.events
 }
 
-func (t *MemoryTool) Execute(args map[string]string) (string, error) {
-	operation := args["operation"]
-	key := args["key"]
-	value := args["value"]
+// SetEventBus replaces the agent event bus, so that the agent publishes
+// on a bus shared with other components
+func (a *Agent) SetEventBus(bus *events.EventBus) {
+	a.events = bus
+}
 
-	switch operation {
-	case "set":
-		if key == "" || value == "" {
-			return "", fmt.Errorf("key and value required")
-		}
-		err := t.memory.SetLongTerm(key, value, 2)
-		if err != nil {
-			return "", err
-		}
-		return fmt.Sprintf("Success: Remembered '%s'", key), nil
+// publish publishes a message event if the agent has an event bus
+func (a *Agent) publish(topic, sessionID, content string) {
+	if a.events == nil {
+		return
+	}
+	a.events.Publish(events.Event{
+		Topic:     topic,
+		Payload:   events.MessagePayload{SessionID: sessionID, Content: content},
+		Timestamp: time.Now(),
+	})
+}
 
-	case "get":
-		if key == "" {
-			return "", fmt.Errorf("key required")
-		}
-		value, err := t.memory.GetLongTerm(key)
-		if err != nil {
-			return "", err
+// CircuitState returns the AI provider circuit breaker state, or closed
+// when the circuit breaker is disabled
+func (a *Agent) CircuitState() CircuitState {
+	if a.circuit == nil {
+		return CircuitClosed
+	}
+	return a.circuit.Status()
+}
+
+// TokenUsage returns the AI token usage since the agent was created
+func (a *Agent) TokenUsage() UsageStats {
+	if a.usage == nil {
+		return UsageStats{}
+	}
+	return a.usage.GetUsage()
+}
+
+// RetryBudgetUsage returns the AI retry budget used by each session, or
+// nil if retries are disabled
+func (a *Agent) RetryBudgetUsage() []RetryBudgetUsage {
+	if a.retry == nil {
+		return nil
+	}
+	return a.retry.RetryBudgetUsage()
+}
+
+// TestToolExecutedEvent subscribes to tool.executed and checks that the
+// event of a tool call is received within 100ms
+func TestToolExecutedEvent() error {
+	log.Println("Testing tool.executed event...")
+
+	dryRun, err := NewDryRunAgent(DefaultConfig())
+	if err != nil {
+		return err
+	}
+	defer dryRun.Close()
+	dryRun.ToolRegistry().Register(NewCalculatorTool())
+
+	bus := events.NewEventBus()
+	dryRun.SetEventBus(bus)
+	received := make(chan events.Event, 1)
+	subscription := bus.Subscribe(events.TopicToolExecuted, func(event events.Event) {
+		received <- event
+	})
+	defer subscription.Unsubscribe()
+
+	dryRun.Provider().QueueToolCall("calculator", `{"expression": "6 * 7"}`)
+	dryRun.Provider().QueueResponse("6 * 7 is 42")
+	if _, err := dryRun.ProcessMessage(context.Background(), "events", "what is 6 * 7?"); err != nil {
+		return err
+	}
+
+	select {
+	case event := <-received:
+		payload, ok := event.Payload.(events.MessagePayload)
+		if !ok || payload.SessionID != "events" || !strings.Contains(payload.Content, "[Tool: calculator]") || !strings.Contains(payload.Content, "42") {
+			return fmt.Errorf("unexpected tool.executed event: %+v", event.Payload)
 		}
-		if value == "" {
-			return fmt.Sprintf("Info: No memory for '%s'", key), nil
+	case <-time.After(100 * time.Millisecond):
+		return fmt.Errorf("expected a tool.executed event within 100ms")
+	}
+	log.Println("✓ tool.executed event received")
+
+	return nil
+}
+
+// TestToolOutputStreaming runs a shell command that prints 3 lines over 3
+// loop iterations and checks that each line is sent to toolOut as it is
+// printed, before ProcessMessageWithStream returns
+func TestToolOutputStreaming() error {
+	log.Println("Testing tool output streaming...")
+
+	config := DefaultConfig()
+	config.Tools.StreamingEnabled = true
+	dryRun, err := NewDryRunAgent(config)
+	if err != nil {
+		return err
+	}
+	defer dryRun.Close()
+	dryRun.ToolRegistry().Register(NewShellTool(nil, "", 5*time.Second))
+
+	dryRun.Provider().QueueToolCall("shell", `{"command": "for i in 1 2 3; do echo \"line $i\"; sleep 0.1; done"}`)
+	dryRun.Provider().QueueResponse("The command printed 3 lines")
+
+	var lines []string
+	var firstLineAt time.Time
+	toolOut := make(chan string)
+	collected := make(chan struct{})
+	go func() {
+		for line := range toolOut {
+			if len(lines) == 0 {
+				firstLineAt = time.Now()
+			}
+			lines = append(lines, line)
 		}
-		return value, nil
+		close(collected)
+	}()
 
-	default:
-		return "", fmt.Errorf("unknown operation: %s", operation)
+	response, err := dryRun.ProcessMessageWithStream("streaming", "run the loop", toolOut)
+	returnedAt := time.Now()
+	<-collected
+	if err != nil {
+		return err
+	}
+	if response != "The command printed 3 lines" {
+		return fmt.Errorf("unexpected response: %q", response)
+	}
+
+	if got := strings.Join(lines, ""); got != "line 1\nline 2\nline 3\n" {
+		return fmt.Errorf("expected 3 lines on toolOut, got %q", got)
+	}
+	if early := returnedAt.Sub(firstLineAt); early < 150*time.Millisecond {
+		return fmt.Errorf("expected the first line while the command was running, got it %v before returning", early)
 	}
+	log.Println("✓ All 3 lines streamed to toolOut before returning")
+
+	return nil
 }
 
 // TestAgent runs tests on the agent module
@@ -436,7 +1070,7 @@ func TestAgent() {
 	// Test message processing
 	sessionID := "test_session"
 	msg := "Hello QuickBot!"
-	response, err := agent.ProcessMessage(sessionID, msg)
+	response, err := agent.ProcessMessage(context.Background(), sessionID, msg)
 	if err != nil {
 		log.Printf("Failed to process message: %v", err)
 	} else {
@@ -459,13 +1093,134 @@ func TestAgent() {
 	}
 
 	// Test reminder
-	reminderID, err := agent.AddReminder(sessionID, "Test reminder", "10:00")
+	reminderID, _, err := agent.AddReminder(sessionID, "Test reminder", "10:00")
 	if err != nil {
 		log.Printf("Failed to add reminder: %v", err)
 	} else {
 		log.Printf("✓ Reminder added: %s", reminderID)
 	}
 
+	// Summarize a 20-message session in four parts with a mock provider
+	dryRun, err := NewDryRunAgent(config)
+	if err != nil {
+		log.Fatalf("Failed to create dry-run agent: %v", err)
+	}
+	summarySession := "summary_session"
+	dryRun.Memory().CreateSession(summarySession, "Summary", "test", "tester")
+	for i := 0; i < 20; i++ {
+		role := "user"
+		if i%2 == 1 {
+			role = "assistant"
+		}
+		content := fmt.Sprintf("Message %02d: %s", i, strings.Repeat("x", 80))
+		if _, _, err := dryRun.Memory().AddMessage(summarySession, role, content, nil); err != nil {
+			log.Fatalf("Failed to add message: %v", err)
+		}
+	}
+	for i := 1; i <= 4; i++ {
+		dryRun.Provider().QueueResponse(fmt.Sprintf("summary of part %d", i))
+	}
+	dryRun.Provider().QueueResponse("summary of summaries")
+
+	summary, err := dryRun.summarizeSession(context.Background(), summarySession, 150)
+	if err != nil || summary != "summary of summaries" {
+		log.Fatalf("Unexpected session summary %q: %v", summary, err)
+	}
+	calls := dryRun.Provider().Calls()
+	if len(calls) != 5 {
+		log.Fatalf("Expected 4 part summaries and 1 final summary, got %d calls", len(calls))
+	}
+	for i := 1; i <= 4; i++ {
+		if !strings.Contains(calls[4][1].Content, fmt.Sprintf("summary of part %d", i)) {
+			log.Fatalf("Final summary request is missing part %d: %s", i, calls[4][1].Content)
+		}
+	}
+	session, err := dryRun.Memory().GetSession(summarySession)
+	if err != nil || session == nil || session.CondensedSummary != summary {
+		log.Fatalf("Session summary not stored: %+v %v", session, err)
+	}
+	log.Println("✓ Session summarized")
+
+	// Shorten a 10000-character response with the summarize strategy; the
+	// mock provider ignores the limit, so the result is truncated to fit
+	long := strings.Repeat("A long response. ", 600)[:10000]
+	dryRun.Provider().QueueResponse(strings.Repeat("short ", 100))
+	shortened, err := dryRun.TruncateResponse(long, SummarizeStrategy, 500)
+	if err != nil {
+		log.Fatalf("Failed to summarize response: %v", err)
+	}
+	if n := utf8.RuneCountInString(shortened); n > 500 {
+		log.Fatalf("Summarized response has %d characters, want at most 500", n)
+	}
+	if !strings.Contains(dryRun.Provider().LastCall()[0].Content, "500 characters") {
+		log.Fatalf("Summarize prompt does not state the limit")
+	}
+	log.Println("✓ Response summarized to fit")
+
+	// A 50ms session timeout cuts off a provider that takes 200ms
+	timeoutSession := "timeout_session"
+	dryRun.Memory().SetSessionConfig(timeoutSession, SessionTimeoutKey, "0.05")
+	dryRun.Provider().SetDelay(200 * time.Millisecond)
+	_, err = dryRun.ProcessMessage(context.Background(), timeoutSession, "Are you there?")
+	if !errors.Is(err, context.DeadlineExceeded) {
+		log.Fatalf("Expected context.DeadlineExceeded, got %v", err)
+	}
+	dryRun.Provider().SetDelay(0)
+	log.Println("✓ Session AI timeout applied")
+
+	// A tool call is executed and its result sent back to the model
+	dryRun.ToolRegistry().Register(NewCalculatorTool())
+	dryRun.Provider().QueueToolCall("calculator", `{"expression":"2+3"}`)
+	dryRun.Provider().QueueResponse("2+3 is 5")
+	response, err = dryRun.ProcessMessage(context.Background(), "tool_session", "What is 2+3?")
+	if err != nil || response != "2+3 is 5" {
+		log.Fatalf("Unexpected tool call response %q: %v", response, err)
+	}
+	followUp := dryRun.Provider().LastCall()
+	call, result := followUp[len(followUp)-2], followUp[len(followUp)-1]
+	if len(call.ToolCalls) != 1 || result.Role != "tool" || result.ToolCallID != call.ToolCalls[0].ID {
+		log.Fatalf("Follow-up request is missing the tool call and result: %+v", followUp)
+	}
+	log.Printf("✓ Tool call executed, result: %s", result.Content)
+
+	// The same conversation calls the provider once; the same question
+	// after a different history calls it again
+	dryRun.config.AI.CacheEnabled = true
+	dryRun.config.AI.Temperature = 0
+	dryRun.responses = NewCachingProvider(dryRun.provider, 10, time.Minute, 0)
+	before := len(dryRun.Provider().Calls())
+	dryRun.Provider().QueueResponse("It is sunny")
+	for _, sessionID := range []string{"cache_a", "cache_b"} {
+		response, cached, err := dryRun.ProcessMessageWithCacheStatus(context.Background(), sessionID, "What is the weather?")
+		if err != nil || response != "It is sunny" {
+			log.Fatalf("Unexpected cached response %q (cached=%v): %v", response, cached, err)
+		}
+	}
+	if calls := len(dryRun.Provider().Calls()) - before; calls != 1 {
+		log.Fatalf("Expected the provider to be called once, got %d calls", calls)
+	}
+	if counters := dryRun.metrics.Snapshot(); counters["cache_hits"] != 1 || counters["cache_misses"] != 1 {
+		log.Fatalf("Expected 1 cache hit and 1 miss, got %v", counters)
+	}
+	dryRun.Provider().QueueResponse("Still sunny")
+	response, cached, err := dryRun.ProcessMessageWithCacheStatus(context.Background(), "cache_a", "What is the weather?")
+	if err != nil || cached || response != "Still sunny" {
+		log.Fatalf("Expected the question after a different history not cached, got %q (cached=%v): %v", response, cached, err)
+	}
+	if calls := len(dryRun.Provider().Calls()) - before; calls != 2 {
+		log.Fatalf("Expected the provider to be called twice, got %d calls", calls)
+	}
+	if evicted := dryRun.InvalidateCache("cache_b"); evicted != 1 {
+		log.Fatalf("Expected 1 cache entry invalidated, got %d", evicted)
+	}
+	dryRun.ProcessMessage(context.Background(), "cache_c", "What is the weather?")
+	if calls := len(dryRun.Provider().Calls()) - before; calls != 3 {
+		log.Fatalf("Expected the provider to be called again after invalidation, got %d calls", calls)
+	}
+	dryRun.config.AI.CacheEnabled = false
+	dryRun.Close()
+	log.Println("✓ Repeated conversation served from cache")
+
 	// Stop agent
 	agent.Stop()
 
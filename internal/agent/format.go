@@ -0,0 +1,375 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// Formatter converts an AI response, written in Markdown, to the markup
+// of a platform
+type Formatter interface {
+	// Format converts text for platform, the name the formatter was
+	// registered under
+	Format(text, platform string) string
+}
+
+// markupStyle describes how a platform writes each Markdown element. The
+// inline functions receive content that is already converted.
+type markupStyle struct {
+	text      func(s string) string
+	bold      func(s string) string
+	italic    func(s string) string
+	strike    func(s string) string
+	code      func(code string) string
+	link      func(text, url string) string
+	header    func(s string) string
+	bullet    string
+	quote     func(s string) string
+	codeBlock func(lang, code string) string
+}
+
+var (
+	headerPattern = regexp.MustCompile(`^#{1,6}\s+(.*?)\s*#*$`)
+	bulletPattern = regexp.MustCompile(`^(\s*)[-*+]\s+(.*)$`)
+	quotePattern  = regexp.MustCompile(`^>\s?(.*)$`)
+)
+
+// render converts Markdown text line by line
+func (s markupStyle) render(text string) string {
+	lines := strings.Split(text, "\n")
+	out := make([]string, 0, len(lines))
+
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+
+		// Fenced code block
+		if fence := strings.TrimSpace(line); strings.HasPrefix(fence, "```") {
+			lang := strings.TrimPrefix(fence, "```")
+			var code []string
+			for i++; i < len(lines) && strings.TrimSpace(lines[i]) != "```"; i++ {
+				code = append(code, lines[i])
+			}
+			out = append(out, s.codeBlock(lang, strings.Join(code, "\n")))
+			continue
+		}
+
+		if m := headerPattern.FindStringSubmatch(line); m != nil {
+			out = append(out, s.header(s.inline(m[1])))
+		} else if m := bulletPattern.FindStringSubmatch(line); m != nil {
+			out = append(out, m[1]+s.bullet+s.inline(m[2]))
+		} else if m := quotePattern.FindStringSubmatch(line); m != nil {
+			out = append(out, s.quote(s.inline(m[1])))
+		} else {
+			out = append(out, s.inline(line))
+		}
+	}
+
+	return strings.Join(out, "\n")
+}
+
+// inline converts the inline Markdown of one line: **bold**, __bold__,
+// *italic*, _italic_, ~~strike~~, `code` and [links](url)
+func (s markupStyle) inline(line string) string {
+	var out, plain strings.Builder
+	flush := func() {
+		out.WriteString(s.text(plain.String()))
+		plain.Reset()
+	}
+
+	for i := 0; i < len(line); {
+		rest := line[i:]
+
+		switch {
+		case rest[0] == '`':
+			if end := strings.IndexByte(rest[1:], '`'); end >= 0 {
+				flush()
+				out.WriteString(s.code(rest[1 : end+1]))
+				i += end + 2
+				continue
+			}
+
+		case strings.HasPrefix(rest, "**"), strings.HasPrefix(rest, "__"), strings.HasPrefix(rest, "~~"):
+			if end := strings.Index(rest[2:], rest[:2]); end > 0 {
+				flush()
+				inner := s.inline(rest[2 : end+2])
+				if rest[0] == '~' {
+					out.WriteString(s.strike(inner))
+				} else {
+					out.WriteString(s.bold(inner))
+				}
+				i += end + 4
+				continue
+			}
+
+		case rest[0] == '*' || rest[0] == '_':
+			if end := italicEnd(line, i); end > 0 {
+				flush()
+				out.WriteString(s.italic(s.inline(line[i+1 : end])))
+				i = end + 1
+				continue
+			}
+
+		case rest[0] == '[':
+			if mid := strings.Index(rest, "]("); mid > 0 {
+				if end := strings.IndexByte(rest[mid+2:], ')'); end >= 0 {
+					flush()
+					out.WriteString(s.link(s.inline(rest[1:mid]), rest[mid+2:mid+2+end]))
+					i += mid + 3 + end
+					continue
+				}
+			}
+		}
+
+		plain.WriteByte(line[i])
+		i++
+	}
+
+	flush()
+	return out.String()
+}
+
+// italicEnd returns the index of the marker closing the italic span that
+// opens at start, or -1. Underscores inside words, as in snake_case, do
+// not open or close a span.
+func italicEnd(line string, start int) int {
+	marker := line[start]
+	if start+1 >= len(line) || line[start+1] == ' ' {
+		return -1
+	}
+	if marker == '_' && start > 0 && isWordByte(line[start-1]) {
+		return -1
+	}
+
+	for end := start + 2; end < len(line); end++ {
+		if line[end] != marker || line[end-1] == ' ' {
+			continue
+		}
+		if marker == '_' && end+1 < len(line) && isWordByte(line[end+1]) {
+			continue
+		}
+		return end
+	}
+	return -1
+}
+
+// isWordByte reports whether b is an ASCII letter or digit
+func isWordByte(b byte) bool {
+	return b < unicode.MaxASCII && (unicode.IsLetter(rune(b)) || unicode.IsDigit(rune(b)))
+}
+
+// escapeChars backslash-escapes each of chars in s
+func escapeChars(s, chars string) string {
+	var b strings.Builder
+	for _, r := range s {
+		if strings.ContainsRune(chars, r) {
+			b.WriteByte('\\')
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// telegramSpecialChars must be escaped in Telegram MarkdownV2 text
+const telegramSpecialChars = "_*[]()~`>#+-=|{}.!\\"
+
+// TelegramFormatter converts Markdown to Telegram MarkdownV2, escaping
+// the characters MarkdownV2 reserves. Send the result with parse mode
+// "MarkdownV2".
+type TelegramFormatter struct{}
+
+// Format converts text to Telegram MarkdownV2
+func (TelegramFormatter) Format(text, platform string) string {
+	escape := func(s string) string { return escapeChars(s, telegramSpecialChars) }
+	bold := func(s string) string { return "*" + s + "*" }
+
+	return markupStyle{
+		text:   escape,
+		bold:   bold,
+		italic: func(s string) string { return "_" + s + "_" },
+		strike: func(s string) string { return "~" + s + "~" },
+		code:   func(code string) string { return "`" + escapeChars(code, "`\\") + "`" },
+		link: func(text, url string) string {
+			return "[" + text + "](" + escapeChars(url, ")\\") + ")"
+		},
+		header: bold,
+		bullet: "• ",
+		quote:  func(s string) string { return ">" + s },
+		codeBlock: func(lang, code string) string {
+			return "```" + lang + "\n" + escapeChars(code, "`\\") + "\n```"
+		},
+	}.render(text)
+}
+
+// DiscordFormatter converts Markdown to Discord markdown. Discord shares
+// most of the syntax; headers become bold lines.
+type DiscordFormatter struct{}
+
+// Format converts text to Discord markdown
+func (DiscordFormatter) Format(text, platform string) string {
+	bold := func(s string) string { return "**" + s + "**" }
+
+	return markupStyle{
+		text:   func(s string) string { return s },
+		bold:   bold,
+		italic: func(s string) string { return "*" + s + "*" },
+		strike: func(s string) string { return "~~" + s + "~~" },
+		code:   func(code string) string { return "`" + code + "`" },
+		link:   func(text, url string) string { return "[" + text + "](" + url + ")" },
+		header: bold,
+		bullet: "- ",
+		quote:  func(s string) string { return "> " + s },
+		codeBlock: func(lang, code string) string {
+			return "```" + lang + "\n" + code + "\n```"
+		},
+	}.render(text)
+}
+
+// SlackFormatter converts Markdown to Slack mrkdwn
+type SlackFormatter struct{}
+
+// Format converts text to Slack mrkdwn
+func (SlackFormatter) Format(text, platform string) string {
+	escape := strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;").Replace
+	bold := func(s string) string { return "*" + s + "*" }
+
+	return markupStyle{
+		text:   escape,
+		bold:   bold,
+		italic: func(s string) string { return "_" + s + "_" },
+		strike: func(s string) string { return "~" + s + "~" },
+		code:   func(code string) string { return "`" + escape(code) + "`" },
+		link:   func(text, url string) string { return "<" + url + "|" + text + ">" },
+		header: bold,
+		bullet: "• ",
+		quote:  func(s string) string { return "> " + s },
+		codeBlock: func(lang, code string) string {
+			return "```\n" + escape(code) + "\n```"
+		},
+	}.render(text)
+}
+
+// PlainTextFormatter strips all markup, for terminals and other plain
+// text outputs. Links are written as "text (url)".
+type PlainTextFormatter struct{}
+
+// Format strips the markup from text
+func (PlainTextFormatter) Format(text, platform string) string {
+	same := func(s string) string { return s }
+
+	return markupStyle{
+		text:   same,
+		bold:   same,
+		italic: same,
+		strike: same,
+		code:   same,
+		link: func(text, url string) string {
+			if text == url {
+				return url
+			}
+			return text + " (" + url + ")"
+		},
+		header:    same,
+		bullet:    "- ",
+		quote:     same,
+		codeBlock: func(lang, code string) string { return code },
+	}.render(text)
+}
+
+// registerFormatters registers the formatters of the built-in platforms
+func (a *Agent) registerFormatters() {
+	a.RegisterFormatter("telegram", TelegramFormatter{})
+	a.RegisterFormatter("discord", DiscordFormatter{})
+	a.RegisterFormatter("slack", SlackFormatter{})
+	a.RegisterFormatter("plain", PlainTextFormatter{})
+}
+
+// RegisterFormatter sets the formatter used for responses to a platform
+func (a *Agent) RegisterFormatter(platform string, formatter Formatter) {
+	a.formatMu.Lock()
+	defer a.formatMu.Unlock()
+
+	if a.formatters == nil {
+		a.formatters = make(map[string]Formatter)
+	}
+	a.formatters[platform] = formatter
+}
+
+// FormatResponse converts a response for a platform. Responses to
+// platforms without a formatter are returned unchanged.
+func (a *Agent) FormatResponse(platform, response string) string {
+	a.formatMu.RLock()
+	formatter, ok := a.formatters[platform]
+	a.formatMu.RUnlock()
+
+	if !ok {
+		return response
+	}
+	return formatter.Format(response, platform)
+}
+
+// ProcessMessageForPlatform processes a user message and formats the
+// response for platform
+func (a *Agent) ProcessMessageForPlatform(platform, sessionID, message string) (string, error) {
+	response, err := a.ProcessMessage(context.Background(), sessionID, message)
+	if err != nil {
+		return "", err
+	}
+	return a.FormatResponse(platform, response), nil
+}
+
+// formatFixture is a response using every Markdown element the
+// formatters convert
+const formatFixture = "# Summary\n" +
+	"This is **bold**, *italic*, ~~gone~~ and `x := 1`.\n" +
+	"- see [docs](https://example.com/a_b)\n" +
+	"> quoted snake_case v1.2!\n" +
+	"```go\nfmt.Println(\"a*b\")\n```"
+
+// TestFormatters runs each formatter on a fixture response
+func TestFormatters() error {
+	fmt.Println("Testing Formatters...")
+
+	want := map[string]string{
+		"telegram": "*Summary*\n" +
+			"This is *bold*, _italic_, ~gone~ and `x := 1`\\.\n" +
+			"• see [docs](https://example.com/a_b)\n" +
+			">quoted snake\\_case v1\\.2\\!\n" +
+			"```go\nfmt.Println(\"a*b\")\n```",
+		"discord": "**Summary**\n" +
+			"This is **bold**, *italic*, ~~gone~~ and `x := 1`.\n" +
+			"- see [docs](https://example.com/a_b)\n" +
+			"> quoted snake_case v1.2!\n" +
+			"```go\nfmt.Println(\"a*b\")\n```",
+		"slack": "*Summary*\n" +
+			"This is *bold*, _italic_, ~gone~ and `x := 1`.\n" +
+			"• see <https://example.com/a_b|docs>\n" +
+			"> quoted snake_case v1.2!\n" +
+			"```\nfmt.Println(\"a*b\")\n```",
+		"plain": "Summary\n" +
+			"This is bold, italic, gone and x := 1.\n" +
+			"- see docs (https://example.com/a_b)\n" +
+			"quoted snake_case v1.2!\n" +
+			"fmt.Println(\"a*b\")",
+	}
+
+	agent := &Agent{}
+	agent.registerFormatters()
+
+	for platform, expected := range want {
+		got := agent.FormatResponse(platform, formatFixture)
+		if got != expected {
+			return fmt.Errorf("%s formatter: got %q, want %q", platform, got, expected)
+		}
+		fmt.Printf("✓ %s formatter\n", platform)
+	}
+
+	if got := agent.FormatResponse("api", formatFixture); got != formatFixture {
+		return fmt.Errorf("unregistered platform: response was changed")
+	}
+	fmt.Println("✓ Unregistered platform unchanged")
+
+	return nil
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"encoding/base64"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// defaultInjectionPatterns match common prompt injection phrasings. Inputs
+// are lowercased and whitespace-collapsed before matching.
+var defaultInjectionPatterns = []string{
+	`\b(ignore|disregard|skip|bypass)\s+(all\s+|any\s+)?(of\s+)?(the\s+|your\s+|my\s+)?(previous|prior|above|earlier|preceding|original|initial|system)\s+(instructions|prompts?|rules|directions|guidelines|context)`,
+	`\b(disregard|forget|override)\s+(all\s+|any\s+)?(of\s+)?(your|the)\s+(instructions|rules|guidelines|programming|training|safety\s+\w+)`,
+	`\bforget\s+(everything|all)\s+(you\s+(were|have\s+been)\s+told|above|before)`,
+	`\b(reveal|show|print|repeat|output|display|leak|tell\s+me)\s+(me\s+)?(your|the)\s+(full\s+|entire\s+|exact\s+)?(system\s+prompt|initial\s+prompt|hidden\s+(prompt|instructions)|original\s+instructions|instructions\s+above)`,
+	`\byou\s+are\s+(now\s+)?(dan|an?\s+unrestricted|an?\s+unfiltered|jailbroken|free\s+from\s+(all\s+)?(rules|restrictions))`,
+	`\b(enter|enable|activate|switch\s+to)\s+(developer|dev|god|jailbreak|dan|unrestricted)\s+mode`,
+	`\bdo\s+anything\s+now\b`,
+	`\b(pretend|act\s+as\s+if|imagine|roleplay\s+as|role-play\s+as|act\s+as)\b.{0,60}\b(no|without|free\s+of|free\s+from)\s+(any\s+|all\s+)?(restrictions|rules|filters|limits|guidelines|ethics|censorship)`,
+	`(^|\s)(new|updated)\s+(system\s+)?instructions\s*:`,
+	`\b(system|admin|developer)\s*(override|prompt)\s*:`,
+	`\bfrom\s+now\s+on,?\s+you\s+(will|must|are\s+going\s+to)\s+(ignore|not\s+follow|disregard|answer\s+without)`,
+	`</?\s*(system|im_start|im_end)\s*>|\[/?inst\]`,
+}
+
+// base64Token matches candidate base64-encoded payloads
+var base64Token = regexp.MustCompile(`[A-Za-z0-9+/]{16,}={0,2}`)
+
+// InjectionResult is the outcome of a prompt injection check
+type InjectionResult struct {
+	Blocked bool   `json:"blocked"`
+	Pattern string `json:"pattern,omitempty"`
+	Encoded bool   `json:"encoded,omitempty"`
+}
+
+// PromptInjectionDetector flags user messages that try to override the
+// system prompt. Base64-encoded payloads in the message are decoded and
+// checked as well.
+type PromptInjectionDetector struct {
+	patterns []*regexp.Regexp
+}
+
+// NewPromptInjectionDetector creates a detector with the default patterns
+func NewPromptInjectionDetector() *PromptInjectionDetector {
+	patterns := make([]*regexp.Regexp, len(defaultInjectionPatterns))
+	for i, pattern := range defaultInjectionPatterns {
+		patterns[i] = regexp.MustCompile(pattern)
+	}
+	return &PromptInjectionDetector{patterns: patterns}
+}
+
+// Detect checks a message for prompt injection
+func (d *PromptInjectionDetector) Detect(input string) InjectionResult {
+	if pattern := d.match(input); pattern != "" {
+		return InjectionResult{Blocked: true, Pattern: pattern}
+	}
+
+	for _, token := range base64Token.FindAllString(input, -1) {
+		decoded, ok := decodeBase64Text(token)
+		if !ok {
+			continue
+		}
+		if pattern := d.match(decoded); pattern != "" {
+			return InjectionResult{Blocked: true, Pattern: pattern, Encoded: true}
+		}
+	}
+
+	return InjectionResult{}
+}
+
+// match returns the first pattern matching the normalized text, or ""
+func (d *PromptInjectionDetector) match(text string) string {
+	normalized := strings.Join(strings.Fields(strings.ToLower(text)), " ")
+	for _, pattern := range d.patterns {
+		if pattern.MatchString(normalized) {
+			return pattern.String()
+		}
+	}
+	return ""
+}
+
+// decodeBase64Text decodes a base64 token, accepting only printable text
+func decodeBase64Text(token string) (string, bool) {
+	data, err := base64.StdEncoding.DecodeString(token)
+	if err != nil {
+		data, err = base64.RawStdEncoding.DecodeString(strings.TrimRight(token, "="))
+		if err != nil {
+			return "", false
+		}
+	}
+
+	if !utf8.Valid(data) {
+		return "", false
+	}
+	text := string(data)
+	for _, r := range text {
+		if !unicode.IsPrint(r) && !unicode.IsSpace(r) {
+			return "", false
+		}
+	}
+	return text, true
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+
+	"gopkg.in/yaml.v3"
+)
+
+// injectionCasesPath is the bundled test cases file, relative to the
+// repository root
+const injectionCasesPath = "internal/agent/testdata/injection_cases.yaml"
+
+// InjectionCase is a prompt injection test case
+type InjectionCase struct {
+	Input           string `yaml:"input"`
+	ExpectedBlocked bool   `yaml:"expected_blocked"`
+	Description     string `yaml:"description"`
+}
+
+// InjectionCaseResult is the outcome of a test case
+type InjectionCaseResult struct {
+	Case   InjectionCase
+	Result InjectionResult
+	Passed bool
+}
+
+// PromptInjectionTestSuite runs test cases against a detector
+type PromptInjectionTestSuite struct {
+	Detector *PromptInjectionDetector
+	Cases    []InjectionCase
+}
+
+// LoadInjectionCases reads test cases from a YAML file
+func LoadInjectionCases(path string) ([]InjectionCase, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read injection cases: %w", err)
+	}
+
+	var file struct {
+		Cases []InjectionCase `yaml:"cases"`
+	}
+	if err := yaml.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("failed to parse injection cases: %w", err)
+	}
+	if len(file.Cases) == 0 {
+		return nil, fmt.Errorf("no injection cases in %s", path)
+	}
+
+	return file.Cases, nil
+}
+
+// Run runs every case and reports whether all of them passed
+func (s *PromptInjectionTestSuite) Run() ([]InjectionCaseResult, bool) {
+	results := make([]InjectionCaseResult, len(s.Cases))
+	allPassed := true
+
+	for i, c := range s.Cases {
+		result := s.Detector.Detect(c.Input)
+		passed := result.Blocked == c.ExpectedBlocked
+		if !passed {
+			allPassed = false
+		}
+		results[i] = InjectionCaseResult{Case: c, Result: result, Passed: passed}
+	}
+
+	return results, allPassed
+}
+
+// TestPromptInjectionSuite runs the bundled prompt injection cases
+// against the default detector and fails if any case fails
+func TestPromptInjectionSuite() error {
+	log.Println("Testing prompt injection detection...")
+
+	cases, err := LoadInjectionCases(injectionCasesPath)
+	if err != nil {
+		return err
+	}
+
+	suite := &PromptInjectionTestSuite{
+		Detector: NewPromptInjectionDetector(),
+		Cases:    cases,
+	}
+	results, allPassed := suite.Run()
+	if !allPassed {
+		for _, result := range results {
+			if !result.Passed {
+				log.Printf("✗ %s (expected blocked=%v, got %v)",
+					result.Case.Description, result.Case.ExpectedBlocked, result.Result.Blocked)
+			}
+		}
+		return fmt.Errorf("prompt injection cases failed")
+	}
+
+	log.Printf("✓ %d prompt injection cases passed", len(results))
+	return nil
+}
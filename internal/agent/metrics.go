@@ -0,0 +1,27 @@
+package main
+
+import "sync/atomic"
+
+// Metrics holds agent runtime counters
+type Metrics struct {
+	CacheHits   int64
+	CacheMisses int64
+}
+
+// IncCacheHits increments the cache hit counter
+func (m *Metrics) IncCacheHits() {
+	atomic.AddInt64(&m.CacheHits, 1)
+}
+
+// IncCacheMisses increments the cache miss counter
+func (m *Metrics) IncCacheMisses() {
+	atomic.AddInt64(&m.CacheMisses, 1)
+}
+
+// Snapshot returns a copy of the current counters
+func (m *Metrics) Snapshot() map[string]int64 {
+	return map[string]int64{
+		"cache_hits":   atomic.LoadInt64(&m.CacheHits),
+		"cache_misses": atomic.LoadInt64(&m.CacheMisses),
+	}
+}
@@ -0,0 +1,280 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"log"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/pemistahl/lingua-go"
+)
+
+// SessionLanguageKey is the session config key LanguageDetector stores
+// the detected language under
+const SessionLanguageKey = "language"
+
+// PreProcessor rewrites a user message before it is stored and sent to
+// the AI
+type PreProcessor interface {
+	Process(text string) (string, error)
+}
+
+// SessionPreProcessor is a PreProcessor that needs the message's session.
+// The pipeline calls ProcessSession instead of Process for it.
+type SessionPreProcessor interface {
+	PreProcessor
+	ProcessSession(sessionID, text string) (string, error)
+}
+
+// PreProcessorPipeline runs pre-processors in order, each on the output
+// of the previous one
+type PreProcessorPipeline []PreProcessor
+
+// Run passes a message of a session through the pipeline
+func (p PreProcessorPipeline) Run(sessionID, text string) (string, error) {
+	var err error
+	for _, processor := range p {
+		if sp, ok := processor.(SessionPreProcessor); ok {
+			text, err = sp.ProcessSession(sessionID, text)
+		} else {
+			text, err = processor.Process(text)
+		}
+		if err != nil {
+			return "", err
+		}
+	}
+	return text, nil
+}
+
+// Pre-processor names accepted in ai.preprocessors
+const (
+	PreProcessorNormalize = "normalize"
+	PreProcessorShortcuts = "shortcuts"
+	PreProcessorLanguage  = "language"
+	PreProcessorSpelling  = "spelling"
+)
+
+// buildPreProcessors creates the pre-processors named in the config, in
+// order. Unknown or failing pre-processors are skipped with a warning.
+func (a *Agent) buildPreProcessors() PreProcessorPipeline {
+	var pipeline PreProcessorPipeline
+	for _, name := range a.config.AI.PreProcessors {
+		switch name {
+		case PreProcessorNormalize:
+			pipeline = append(pipeline, NormalizationPreProcessor{})
+		case PreProcessorShortcuts:
+			pipeline = append(pipeline, NewShortcutPreProcessor(nil))
+		case PreProcessorLanguage:
+			pipeline = append(pipeline, NewLanguageDetector(a.memory))
+		case PreProcessorSpelling:
+			spelling, err := NewSpellingPreProcessor(a.config.AI.SpellingDictionary)
+			if err != nil {
+				log.Printf("Warning: spelling pre-processor disabled: %v", err)
+				continue
+			}
+			pipeline = append(pipeline, spelling)
+		default:
+			log.Printf("Warning: unknown pre-processor: %s", name)
+		}
+	}
+	return pipeline
+}
+
+var (
+	horizontalSpace = regexp.MustCompile(`[ \t]+`)
+	blankLines      = regexp.MustCompile(`\n{2,}`)
+)
+
+// NormalizationPreProcessor collapses runs of spaces and of newlines and
+// trims the message
+type NormalizationPreProcessor struct{}
+
+// Process normalizes the whitespace of text
+func (NormalizationPreProcessor) Process(text string) (string, error) {
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+	lines := strings.Split(text, "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimSpace(horizontalSpace.ReplaceAllString(line, " "))
+	}
+	text = blankLines.ReplaceAllString(strings.Join(lines, "\n"), "\n")
+	return strings.TrimSpace(text), nil
+}
+
+// defaultShortcuts are the chat shortcuts expanded by default
+var defaultShortcuts = map[string]string{
+	"pls":  "please",
+	"plz":  "please",
+	"thx":  "thanks",
+	"ty":   "thank you",
+	"u":    "you",
+	"ur":   "your",
+	"r":    "are",
+	"idk":  "I don't know",
+	"btw":  "by the way",
+	"asap": "as soon as possible",
+}
+
+// ShortcutPreProcessor expands chat shortcuts ("pls" → "please")
+type ShortcutPreProcessor struct {
+	replacer *wordReplacer
+}
+
+// NewShortcutPreProcessor creates a shortcut expander; nil shortcuts
+// uses the defaults
+func NewShortcutPreProcessor(shortcuts map[string]string) *ShortcutPreProcessor {
+	if shortcuts == nil {
+		shortcuts = defaultShortcuts
+	}
+	return &ShortcutPreProcessor{replacer: newWordReplacer(shortcuts)}
+}
+
+// Process expands the shortcuts in text
+func (p *ShortcutPreProcessor) Process(text string) (string, error) {
+	return p.replacer.Replace(text), nil
+}
+
+// LanguageDetector detects the language of each message and stores its
+// ISO 639-1 code in the session config under SessionLanguageKey. The
+// message itself is not changed.
+type LanguageDetector struct {
+	memory *Memory
+
+	once     sync.Once
+	detector lingua.LanguageDetector
+}
+
+// NewLanguageDetector creates a language detector storing results in
+// memory. The language models are loaded on first use.
+func NewLanguageDetector(memory *Memory) *LanguageDetector {
+	return &LanguageDetector{memory: memory}
+}
+
+// Detect returns the lowercase ISO 639-1 code of the language of text,
+// or "" if it cannot be detected reliably
+func (d *LanguageDetector) Detect(text string) string {
+	d.once.Do(func() {
+		d.detector = lingua.NewLanguageDetectorBuilder().FromAllLanguages().Build()
+	})
+
+	language, ok := d.detector.DetectLanguageOf(text)
+	if !ok {
+		return ""
+	}
+	return strings.ToLower(language.IsoCode639_1().String())
+}
+
+// Process returns text unchanged; the language is only stored by
+// ProcessSession
+func (d *LanguageDetector) Process(text string) (string, error) {
+	return text, nil
+}
+
+// ProcessSession stores the language of text in the session config
+func (d *LanguageDetector) ProcessSession(sessionID, text string) (string, error) {
+	language := d.Detect(text)
+	if language == "" {
+		return text, nil
+	}
+	if err := d.memory.SetSessionConfig(sessionID, SessionLanguageKey, language); err != nil {
+		return "", fmt.Errorf("failed to store session language: %w", err)
+	}
+	return text, nil
+}
+
+// SpellingPreProcessor corrects misspelled words from a dictionary file
+type SpellingPreProcessor struct {
+	replacer *wordReplacer
+}
+
+// NewSpellingPreProcessor loads a dictionary file with one
+// "misspelling correction" pair per line. Blank lines and lines starting
+// with # are ignored.
+func NewSpellingPreProcessor(path string) (*SpellingPreProcessor, error) {
+	if path == "" {
+		return nil, fmt.Errorf("no spelling dictionary configured")
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open spelling dictionary: %w", err)
+	}
+	defer file.Close()
+
+	corrections := make(map[string]string)
+	scanner := bufio.NewScanner(file)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		fields := strings.Fields(text)
+		if len(fields) < 2 {
+			return nil, fmt.Errorf("spelling dictionary line %d: expected \"misspelling correction\"", line)
+		}
+		corrections[fields[0]] = strings.Join(fields[1:], " ")
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read spelling dictionary: %w", err)
+	}
+
+	return &SpellingPreProcessor{replacer: newWordReplacer(corrections)}, nil
+}
+
+// Process corrects the misspelled words in text
+func (p *SpellingPreProcessor) Process(text string) (string, error) {
+	return p.replacer.Replace(text), nil
+}
+
+// wordReplacer replaces whole words, ignoring case
+type wordReplacer struct {
+	words   map[string]string
+	pattern *regexp.Regexp
+}
+
+// wordPattern matches words, including apostrophes
+var wordPattern = regexp.MustCompile(`[\p{L}\p{N}']+`)
+
+func newWordReplacer(words map[string]string) *wordReplacer {
+	lower := make(map[string]string, len(words))
+	for word, replacement := range words {
+		lower[strings.ToLower(word)] = replacement
+	}
+	return &wordReplacer{words: lower, pattern: wordPattern}
+}
+
+// Replace replaces each word of text found in the map
+func (r *wordReplacer) Replace(text string) string {
+	return r.pattern.ReplaceAllStringFunc(text, func(word string) string {
+		if replacement, ok := r.words[strings.ToLower(word)]; ok {
+			return replacement
+		}
+		return word
+	})
+}
+
+// TestPreProcessors runs messages through the built-in pre-processors
+func TestPreProcessors() error {
+	log.Println("Testing Pre-processors...")
+
+	normalized, err := NormalizationPreProcessor{}.Process("hello   world\n\n")
+	if err != nil || normalized != "hello world" {
+		return fmt.Errorf("expected %q, got %q (%v)", "hello world", normalized, err)
+	}
+	normalized, _ = NormalizationPreProcessor{}.Process("  first \t line\n\n\n  second   line ")
+	if normalized != "first line\nsecond line" {
+		return fmt.Errorf("expected blank lines collapsed, got %q", normalized)
+	}
+	log.Println("✓ Whitespace normalized")
+
+	pipeline := PreProcessorPipeline{NormalizationPreProcessor{}, NewShortcutPreProcessor(nil)}
+	processed, err := pipeline.Run("s1", "  Pls   help, thx ")
+	if err != nil || processed != "please help, thanks" {
+		return fmt.Errorf("expected shortcuts expanded, got %q (%v)", processed, err)
+	}
+	log.Println("✓ Shortcuts expanded")
+
+	return nil
+}
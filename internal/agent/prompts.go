@@ -0,0 +1,302 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+)
+
+// PromptData is the data available to system prompt templates
+type PromptData struct {
+	BotName   string
+	SessionID string
+	Date      string
+}
+
+// promptTemplate is a parsed template and its source
+type promptTemplate struct {
+	source   string
+	template *template.Template
+}
+
+// PromptLibrary holds the system prompt templates (*.tmpl) of a directory
+// and the name of the active one
+type PromptLibrary struct {
+	dir       string
+	templates map[string]promptTemplate
+	active    string
+	mu        sync.RWMutex
+}
+
+// NewPromptLibrary creates a prompt library and loads its templates
+func NewPromptLibrary(dir string) (*PromptLibrary, error) {
+	library := &PromptLibrary{
+		dir:       dir,
+		templates: make(map[string]promptTemplate),
+	}
+
+	if err := library.Reload(); err != nil {
+		return nil, err
+	}
+
+	return library, nil
+}
+
+// Reload rescans the directory for templates. Templates that fail to parse
+// are skipped. If the active template no longer exists, the default
+// system prompt is used again.
+func (l *PromptLibrary) Reload() error {
+	files, err := filepath.Glob(filepath.Join(l.dir, "*.tmpl"))
+	if err != nil {
+		return fmt.Errorf("failed to scan prompt library: %w", err)
+	}
+
+	templates := make(map[string]promptTemplate)
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			log.Printf("Warning: Failed to read prompt template %s: %v", file, err)
+			continue
+		}
+
+		name := strings.TrimSuffix(filepath.Base(file), ".tmpl")
+		tmpl, err := template.New(name).Parse(string(data))
+		if err != nil {
+			log.Printf("Warning: Failed to parse prompt template %s: %v", file, err)
+			continue
+		}
+
+		templates[name] = promptTemplate{source: string(data), template: tmpl}
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.templates = templates
+	if _, ok := templates[l.active]; !ok && l.active != "" {
+		log.Printf("Warning: Active prompt template %s was removed", l.active)
+		l.active = ""
+	}
+
+	return nil
+}
+
+// Names returns the sorted names of the available templates
+func (l *PromptLibrary) Names() []string {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	names := make([]string, 0, len(l.templates))
+	for name := range l.templates {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Source returns the source of a template
+func (l *PromptLibrary) Source(name string) (string, bool) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	t, ok := l.templates[name]
+	return t.source, ok
+}
+
+// Active returns the name of the active template, or "" for the default
+// system prompt
+func (l *PromptLibrary) Active() string {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return l.active
+}
+
+// SetActive switches the active template
+func (l *PromptLibrary) SetActive(name string) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if _, ok := l.templates[name]; !ok {
+		return fmt.Errorf("prompt template not found: %s", name)
+	}
+	l.active = name
+	return nil
+}
+
+// Render renders a template
+func (l *PromptLibrary) Render(name string, data PromptData) (string, error) {
+	l.mu.RLock()
+	t, ok := l.templates[name]
+	l.mu.RUnlock()
+
+	if !ok {
+		return "", fmt.Errorf("prompt template not found: %s", name)
+	}
+
+	var buf bytes.Buffer
+	if err := t.template.Execute(&buf, data); err != nil {
+		return "", fmt.Errorf("failed to render prompt template %s: %w", name, err)
+	}
+	return buf.String(), nil
+}
+
+// SetPromptTemplate switches the active system prompt template
+func (a *Agent) SetPromptTemplate(name string) error {
+	if a.prompts == nil {
+		return fmt.Errorf("prompt library is not configured")
+	}
+	return a.prompts.SetActive(name)
+}
+
+// ReloadPrompts rescans the prompt library directory
+func (a *Agent) ReloadPrompts() error {
+	if a.prompts == nil {
+		return nil
+	}
+	return a.prompts.Reload()
+}
+
+// PromptLibrary returns the prompt library, or nil if not configured
+func (a *Agent) PromptLibrary() *PromptLibrary {
+	return a.prompts
+}
+
+// systemPromptFor returns the system prompt of a session: the template set
+// in its "prompt_template" session config, else the active template, else
+// the default system prompt
+func (a *Agent) systemPromptFor(sessionID string) string {
+	if a.prompts == nil {
+		return a.systemPrompt
+	}
+
+	name, err := a.memory.GetSessionConfig(sessionID, "prompt_template")
+	if err != nil {
+		log.Printf("Warning: %v", err)
+	}
+	if name == "" {
+		name = a.prompts.Active()
+	}
+	if name == "" {
+		return a.systemPrompt
+	}
+
+	prompt, err := a.prompts.Render(name, PromptData{
+		BotName:   a.config.Bot.Name,
+		SessionID: sessionID,
+		Date:      time.Now().Format("2006-01-02"),
+	})
+	if err != nil {
+		log.Printf("Warning: %v, using default system prompt", err)
+		return a.systemPrompt
+	}
+	return prompt
+}
+
+// TestPromptLibrary switches between two prompt templates and checks that
+// the next message is sent with the new rendered system prompt
+func TestPromptLibrary() error {
+	log.Println("Testing prompt library...")
+
+	dir, err := os.MkdirTemp("", "quickbot-prompts")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+
+	templates := map[string]string{
+		"assistant":  "You are {{.BotName}}, a friendly assistant in {{.SessionID}}.",
+		"researcher": "You are {{.BotName}}, a meticulous researcher. Cite your sources.",
+	}
+	for name, source := range templates {
+		if err := os.WriteFile(filepath.Join(dir, name+".tmpl"), []byte(source), 0644); err != nil {
+			return err
+		}
+	}
+
+	config := DefaultConfig()
+	config.Bot.Name = "QuickBot"
+	dryRun, err := NewDryRunAgent(config)
+	if err != nil {
+		return err
+	}
+	defer dryRun.Close()
+
+	if err := dryRun.SetPromptTemplate("assistant"); err == nil {
+		return fmt.Errorf("expected switching templates without a library to fail")
+	}
+	if dryRun.prompts, err = NewPromptLibrary(dir); err != nil {
+		return err
+	}
+	if names := dryRun.PromptLibrary().Names(); strings.Join(names, ",") != "assistant,researcher" {
+		return fmt.Errorf("expected templates assistant and researcher, got %v", names)
+	}
+
+	// systemPrompt returns the system prompt sent with a new message
+	systemPrompt := func(sessionID string) (string, error) {
+		if _, err := dryRun.ProcessMessage(context.Background(), sessionID, "Hello"); err != nil {
+			return "", err
+		}
+		call := dryRun.Provider().LastCall()
+		if len(call) == 0 || call[0].Role != "system" {
+			return "", fmt.Errorf("expected a system prompt, got %+v", call)
+		}
+		return call[0].Content, nil
+	}
+
+	if prompt, err := systemPrompt("prompts"); err != nil || prompt != dryRun.systemPrompt {
+		return fmt.Errorf("expected the default system prompt without an active template, got %q (%v)", prompt, err)
+	}
+
+	if err := dryRun.SetPromptTemplate("assistant"); err != nil {
+		return err
+	}
+	want := "You are QuickBot, a friendly assistant in prompts."
+	if prompt, err := systemPrompt("prompts"); err != nil || prompt != want {
+		return fmt.Errorf("expected %q, got %q (%v)", want, prompt, err)
+	}
+
+	if err := dryRun.SetPromptTemplate("researcher"); err != nil {
+		return err
+	}
+	want = "You are QuickBot, a meticulous researcher. Cite your sources."
+	if prompt, err := systemPrompt("prompts"); err != nil || prompt != want {
+		return fmt.Errorf("expected the switched template %q, got %q (%v)", want, prompt, err)
+	}
+	log.Println("✓ Active template switched and rendered")
+
+	if err := dryRun.Memory().SetSessionConfig("assistant_session", "prompt_template", "assistant"); err != nil {
+		return err
+	}
+	want = "You are QuickBot, a friendly assistant in assistant_session."
+	if prompt, err := systemPrompt("assistant_session"); err != nil || prompt != want {
+		return fmt.Errorf("expected the session's template %q, got %q (%v)", want, prompt, err)
+	}
+	if err := dryRun.SetPromptTemplate("missing"); err == nil {
+		return fmt.Errorf("expected switching to an unknown template to fail")
+	}
+	log.Println("✓ Session template overrides the active one")
+
+	if err := os.Remove(filepath.Join(dir, "researcher.tmpl")); err != nil {
+		return err
+	}
+	if err := dryRun.ReloadPrompts(); err != nil {
+		return err
+	}
+	if active := dryRun.PromptLibrary().Active(); active != "" {
+		return fmt.Errorf("expected the removed template to be deactivated, got %q", active)
+	}
+	if prompt, err := systemPrompt("prompts"); err != nil || prompt != dryRun.systemPrompt {
+		return fmt.Errorf("expected the default system prompt after the template was removed, got %q (%v)", prompt, err)
+	}
+	log.Println("✓ Removed template falls back to the default prompt")
+
+	return nil
+}
@@ -0,0 +1,346 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/Chang-Augenweide/QuickBot-Go/pkg/types"
+)
+
+// DryRunProvider is a mock AI provider that records the messages it is
+// sent and replies with queued responses instead of calling an API
+type DryRunProvider struct {
+	mu        sync.Mutex
+	responses []types.Completion
+	calls     [][]Message
+	delay     time.Duration
+	toolCalls int
+}
+
+// NewDryRunProvider creates a new dry-run provider
+func NewDryRunProvider() *DryRunProvider {
+	return &DryRunProvider{}
+}
+
+func (p *DryRunProvider) ProviderName() string {
+	return "dry-run"
+}
+
+// QueueResponse queues the response returned by the next call
+func (p *DryRunProvider) QueueResponse(response string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.responses = append(p.responses, types.Completion{Content: response, FinishReason: "stop"})
+}
+
+// QueueToolCall queues a call of tool with JSON arguments as the reply to
+// the next ToolCallCompletion
+func (p *DryRunProvider) QueueToolCall(tool, arguments string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.toolCalls++
+	p.responses = append(p.responses, types.Completion{
+		ToolCalls:    []types.ToolCall{{ID: fmt.Sprintf("call_%d", p.toolCalls), Name: tool, Arguments: arguments}},
+		FinishReason: "tool_calls",
+	})
+}
+
+// SetDelay makes each call wait for delay before replying, as a slow
+// provider would
+func (p *DryRunProvider) SetDelay(delay time.Duration) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.delay = delay
+}
+
+func (p *DryRunProvider) ChatCompletion(ctx context.Context, messages []Message) (string, error) {
+	completion, err := p.ToolCallCompletion(ctx, messages, nil)
+	return completion.Content, err
+}
+
+func (p *DryRunProvider) ToolCallCompletion(ctx context.Context, messages []Message, tools []types.ToolSpec) (types.Completion, error) {
+	p.mu.Lock()
+	delay := p.delay
+	p.mu.Unlock()
+
+	if delay > 0 {
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			return types.Completion{}, ctx.Err()
+		}
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.calls = append(p.calls, append([]Message(nil), messages...))
+
+	if len(p.responses) == 0 {
+		return types.Completion{Content: "[dry-run response]", FinishReason: "stop"}, nil
+	}
+	response := p.responses[0]
+	p.responses = p.responses[1:]
+	return response, nil
+}
+
+// Calls returns the messages received by each call, in order
+func (p *DryRunProvider) Calls() [][]Message {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([][]Message(nil), p.calls...)
+}
+
+// LastCall returns the messages received by the most recent call
+func (p *DryRunProvider) LastCall() []Message {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if len(p.calls) == 0 {
+		return nil
+	}
+	return p.calls[len(p.calls)-1]
+}
+
+// DryRunAgent is an agent backed by a DryRunProvider and a scratch memory
+// database, so replays never call an AI API or touch the real history.
+// Tools are not registered.
+type DryRunAgent struct {
+	*Agent
+	provider *DryRunProvider
+	dir      string
+}
+
+// NewDryRunAgent creates a new dry-run agent
+func NewDryRunAgent(config *Config) (*DryRunAgent, error) {
+	dir, err := os.MkdirTemp("", "quickbot-replay-")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create replay directory: %w", err)
+	}
+
+	memory, err := NewMemory(filepath.Join(dir, "replay.db"), config.Memory.MaxMessages)
+	if err != nil {
+		os.RemoveAll(dir)
+		return nil, err
+	}
+
+	provider := NewDryRunProvider()
+
+	return &DryRunAgent{
+		Agent: &Agent{
+			config:        config,
+			memory:        memory,
+			toolRegistry:  NewToolRegistry(),
+			aiProvider:    provider,
+			systemPrompt:  buildSystemPrompt(),
+			memoryContext: config.Memory.MaxMessages,
+			metrics:       &Metrics{},
+		},
+		provider: provider,
+		dir:      dir,
+	}, nil
+}
+
+// Provider returns the dry-run provider
+func (d *DryRunAgent) Provider() *DryRunProvider {
+	return d.provider
+}
+
+// Close closes the scratch memory and removes it
+func (d *DryRunAgent) Close() error {
+	d.memory.Close()
+	return os.RemoveAll(d.dir)
+}
+
+// ReplayStep records one replayed user message
+type ReplayStep struct {
+	MessageID        int       `json:"message_id"`
+	UserMessage      string    `json:"user_message"`
+	ChatMessages     []Message `json:"chat_messages"`
+	OriginalResponse string    `json:"original_response"`
+	ReplayedResponse string    `json:"replayed_response"`
+	ActualResponse   string    `json:"actual_response,omitempty"`
+	Diff             string    `json:"diff,omitempty"`
+	Error            string    `json:"error,omitempty"`
+}
+
+// ReplayLog records a full conversation replay
+type ReplayLog struct {
+	SessionID   string       `json:"session_id"`
+	FromMessage int          `json:"from_message"`
+	ReplayedAt  time.Time    `json:"replayed_at"`
+	Steps       []ReplayStep `json:"steps"`
+}
+
+// ReplaySession re-feeds the user messages of a session, up to and
+// including message ID toID (0 for all), through a dry-run agent. Each
+// user message is answered with the response originally recorded for it,
+// so the replayed history matches the original one. step is called after
+// each message, if not nil.
+func ReplaySession(config *Config, memory *Memory, sessionID string, toID int, step func(*ReplayStep)) (*ReplayLog, error) {
+	if toID > 0 {
+		msg, err := memory.GetMessageByID(toID)
+		if err != nil {
+			return nil, err
+		}
+		if msg.SessionID != sessionID {
+			return nil, fmt.Errorf("message %d does not belong to session %s", toID, sessionID)
+		}
+	}
+
+	history, err := memory.GetMessagesBetween(sessionID, 0, toID)
+	if err != nil {
+		return nil, err
+	}
+	if len(history) == 0 {
+		return nil, fmt.Errorf("no messages found for session %s", sessionID)
+	}
+
+	dryRun, err := NewDryRunAgent(config)
+	if err != nil {
+		return nil, err
+	}
+	defer dryRun.Close()
+
+	replayLog := &ReplayLog{
+		SessionID:   sessionID,
+		FromMessage: toID,
+		ReplayedAt:  time.Now(),
+	}
+
+	for i, msg := range history {
+		if msg.Role != "user" {
+			continue
+		}
+
+		// Reply with the recorded response, if any
+		original := ""
+		if i+1 < len(history) && history[i+1].Role == "assistant" {
+			original = history[i+1].Content
+			dryRun.provider.QueueResponse(original)
+		}
+
+		replayStep := ReplayStep{
+			MessageID:        msg.ID,
+			UserMessage:      msg.Content,
+			OriginalResponse: original,
+		}
+
+		response, err := dryRun.ProcessMessage(context.Background(), sessionID, msg.Content)
+		if err != nil {
+			replayStep.Error = err.Error()
+		}
+		replayStep.ReplayedResponse = response
+		replayStep.ChatMessages = dryRun.provider.LastCall()
+
+		if step != nil {
+			step(&replayStep)
+		}
+		replayLog.Steps = append(replayLog.Steps, replayStep)
+	}
+
+	return replayLog, nil
+}
+
+// TestReplaySession replays a recorded conversation and checks that the
+// dry-run provider is sent the original history at each step
+func TestReplaySession() error {
+	log.Println("Testing conversation replay...")
+
+	dir, err := os.MkdirTemp("", "quickbot-replay-test")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+
+	memory, err := NewMemory(filepath.Join(dir, "memory.db"), 100)
+	if err != nil {
+		return err
+	}
+	defer memory.Close()
+
+	exchanges := [][2]string{
+		{"Hi, I am Ann", "Hello Ann!"},
+		{"What is 2+3?", "2+3 is 5"},
+		{"What is my name?", "Your name is Ann"},
+	}
+	var ids []int64
+	for _, exchange := range exchanges {
+		for i, role := range []string{"user", "assistant"} {
+			id, _, err := memory.AddMessage("replay", role, exchange[i], nil)
+			if err != nil {
+				return err
+			}
+			ids = append(ids, id)
+		}
+	}
+
+	replayLog, err := ReplaySession(DefaultConfig(), memory, "replay", 0, nil)
+	if err != nil {
+		return err
+	}
+	if len(replayLog.Steps) != len(exchanges) {
+		return fmt.Errorf("expected %d replayed steps, got %d", len(exchanges), len(replayLog.Steps))
+	}
+	for i, step := range replayLog.Steps {
+		if step.Error != "" {
+			return fmt.Errorf("step %d failed: %s", i, step.Error)
+		}
+		if step.ReplayedResponse != exchanges[i][1] || step.OriginalResponse != exchanges[i][1] {
+			return fmt.Errorf("expected step %d to reply %q, got %q", i, exchanges[i][1], step.ReplayedResponse)
+		}
+
+		// The system prompt, then every message before the user message
+		// and the user message itself
+		sent := step.ChatMessages
+		if len(sent) != 2*i+2 || sent[0].Role != "system" {
+			return fmt.Errorf("expected step %d to send the system prompt and %d messages, got %+v", i, 2*i+1, sent)
+		}
+		for j, msg := range sent[1:] {
+			exchange, role := exchanges[j/2], "user"
+			if j%2 == 1 {
+				role = "assistant"
+			}
+			if msg.Role != role || msg.Content != exchange[j%2] {
+				return fmt.Errorf("expected step %d message %d to be %s %q, got %s %q", i, j, role, exchange[j%2], msg.Role, msg.Content)
+			}
+		}
+	}
+	log.Println("✓ Replayed history matches the original messages")
+
+	var stepped []int
+	replayLog, err = ReplaySession(DefaultConfig(), memory, "replay", int(ids[2]), func(step *ReplayStep) {
+		stepped = append(stepped, step.MessageID)
+	})
+	if err != nil {
+		return err
+	}
+	if len(replayLog.Steps) != 2 || fmt.Sprint(stepped) != fmt.Sprint([]int64{ids[0], ids[2]}) {
+		return fmt.Errorf("expected a replay up to message %d to stop after 2 steps, got %v", ids[2], stepped)
+	}
+	if replayLog.Steps[1].OriginalResponse != "" {
+		return fmt.Errorf("expected no original response past the last replayed message, got %q", replayLog.Steps[1].OriginalResponse)
+	}
+	log.Println("✓ Replay stopped at the requested message")
+
+	if _, _, err := memory.AddMessage("other", "user", "elsewhere", nil); err != nil {
+		return err
+	}
+	other, err := memory.GetMessagesBetween("other", 0, 0)
+	if err != nil || len(other) != 1 {
+		return fmt.Errorf("expected 1 message in session other, got %d: %v", len(other), err)
+	}
+	if _, err := ReplaySession(DefaultConfig(), memory, "replay", other[0].ID, nil); err == nil {
+		return fmt.Errorf("expected replaying up to another session's message to fail")
+	}
+	if _, err := ReplaySession(DefaultConfig(), memory, "missing", 0, nil); err == nil {
+		return fmt.Errorf("expected replaying an empty session to fail")
+	}
+	log.Println("✓ Invalid replays rejected")
+
+	return nil
+}
@@ -0,0 +1,174 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+)
+
+// AgentState is the processing state of the agent
+type AgentState string
+
+const (
+	StateIdle       AgentState = "idle"
+	StateProcessing AgentState = "processing"
+	StateError      AgentState = "error"
+)
+
+// StateChangeCallback is called after the agent changes state
+type StateChangeCallback func(from, to AgentState)
+
+// State returns the current state of the agent
+func (a *Agent) State() AgentState {
+	a.stateMu.RLock()
+	defer a.stateMu.RUnlock()
+	return a.currentState()
+}
+
+// OnStateChange sets the callback called after each state change
+func (a *Agent) OnStateChange(callback StateChangeCallback) {
+	a.stateMu.Lock()
+	defer a.stateMu.Unlock()
+	a.onStateChange = callback
+}
+
+// Reset transitions the agent from the error state back to idle
+func (a *Agent) Reset() error {
+	a.stateMu.Lock()
+	from := a.currentState()
+	if from != StateError {
+		a.stateMu.Unlock()
+		return fmt.Errorf("agent is not in error state: %s", from)
+	}
+	a.state = StateIdle
+	callback := a.onStateChange
+	a.stateMu.Unlock()
+
+	if callback != nil {
+		callback(from, StateIdle)
+	}
+	return nil
+}
+
+// beginProcessing moves the agent to the processing state. Messages may
+// be processed concurrently; the agent stays in the processing state
+// until the last one finishes.
+func (a *Agent) beginProcessing() {
+	a.stateMu.Lock()
+	a.processing++
+	a.transition(StateProcessing)
+}
+
+// endProcessing moves the agent to the error state if err is not nil,
+// or back to idle once no message is being processed
+func (a *Agent) endProcessing(err error) {
+	a.stateMu.Lock()
+	a.processing--
+
+	switch {
+	case err != nil:
+		a.transition(StateError)
+	case a.processing == 0 && a.currentState() == StateProcessing:
+		a.transition(StateIdle)
+	default:
+		a.stateMu.Unlock()
+	}
+}
+
+// transition sets the state and calls the callback. It must be called
+// with stateMu locked, and unlocks it before calling the callback.
+func (a *Agent) transition(to AgentState) {
+	from := a.currentState()
+	a.state = to
+	callback := a.onStateChange
+	a.stateMu.Unlock()
+
+	if callback != nil && from != to {
+		callback(from, to)
+	}
+}
+
+func (a *Agent) currentState() AgentState {
+	if a.state == "" {
+		return StateIdle
+	}
+	return a.state
+}
+
+// TestAgentState checks the agent state during and after a slow call to a
+// mock provider, and the error state after a failed call
+func TestAgentState() error {
+	log.Println("Testing agent state...")
+
+	dryRun, err := NewDryRunAgent(DefaultConfig())
+	if err != nil {
+		return err
+	}
+	defer dryRun.Close()
+
+	var mu sync.Mutex
+	var changes []string
+	dryRun.OnStateChange(func(from, to AgentState) {
+		mu.Lock()
+		defer mu.Unlock()
+		changes = append(changes, fmt.Sprintf("%s->%s", from, to))
+	})
+	changed := func() string {
+		mu.Lock()
+		defer mu.Unlock()
+		return fmt.Sprint(changes)
+	}
+
+	if state := dryRun.State(); state != StateIdle {
+		return fmt.Errorf("expected a new agent to be idle, got %s", state)
+	}
+
+	dryRun.Provider().SetDelay(200 * time.Millisecond)
+	done := make(chan error, 1)
+	go func() {
+		_, err := dryRun.ProcessMessage(context.Background(), "state", "hello")
+		done <- err
+	}()
+
+	time.Sleep(50 * time.Millisecond)
+	if state := dryRun.State(); state != StateProcessing {
+		return fmt.Errorf("expected the agent to be processing during the call, got %s", state)
+	}
+	if err := <-done; err != nil {
+		return err
+	}
+	if state := dryRun.State(); state != StateIdle {
+		return fmt.Errorf("expected the agent to be idle after the call, got %s", state)
+	}
+	if got := changed(); got != "[idle->processing processing->idle]" {
+		return fmt.Errorf("unexpected state changes: %s", got)
+	}
+	log.Println("✓ Processing during a slow call and idle after")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := dryRun.ProcessMessage(ctx, "state", "hello again"); err == nil {
+		return fmt.Errorf("expected the call to time out")
+	}
+	if state := dryRun.State(); state != StateError {
+		return fmt.Errorf("expected the agent to be in the error state after a failed call, got %s", state)
+	}
+	if err := dryRun.Reset(); err != nil {
+		return err
+	}
+	if state := dryRun.State(); state != StateIdle {
+		return fmt.Errorf("expected Reset to make the agent idle, got %s", state)
+	}
+	if err := dryRun.Reset(); err == nil {
+		return fmt.Errorf("expected Reset of an idle agent to fail")
+	}
+	want := "[idle->processing processing->idle idle->processing processing->error error->idle]"
+	if got := changed(); got != want {
+		return fmt.Errorf("unexpected state changes: %s", got)
+	}
+	log.Println("✓ Error state after a failed call, idle after Reset")
+
+	return nil
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// summaryChunkTokens is the size of the message groups summarized
+// separately by SummarizeSession
+const summaryChunkTokens = 4000
+
+// Prompts used to summarize sessions
+const (
+	chunkSummaryPrompt = "Summarize the following part of a conversation. Keep facts, decisions, names and open questions; leave out small talk. Reply with the summary only."
+	finalSummaryPrompt = "The following are summaries of consecutive parts of one conversation. Combine them into a single concise summary of the whole conversation. Reply with the summary only."
+)
+
+// SummarizeSession summarizes all messages of a session and stores the
+// result as the session's condensed summary. Messages are summarized in
+// groups of about 4000 tokens, then the group summaries are combined.
+func (a *Agent) SummarizeSession(sessionID string) (string, error) {
+	return a.summarizeSession(context.Background(), sessionID, summaryChunkTokens)
+}
+
+// summarizeSession summarizes a session in chunks of at most chunkTokens
+func (a *Agent) summarizeSession(ctx context.Context, sessionID string, chunkTokens int) (string, error) {
+	messages, err := a.memory.GetMessages(sessionID, 0)
+	if err != nil {
+		return "", err
+	}
+	if len(messages) == 0 {
+		return "", fmt.Errorf("session %s has no messages", sessionID)
+	}
+
+	// GetMessages returns the newest message first
+	history := make([]Message, len(messages))
+	for i, msg := range messages {
+		history[len(messages)-1-i] = Message{Role: msg.Role, Content: msg.Content}
+	}
+
+	chunks := chunkMessages(NewTokenCounter(), history, chunkTokens)
+
+	summaries := make([]string, len(chunks))
+	for i, chunk := range chunks {
+		summaries[i], err = a.summarize(ctx, chunkSummaryPrompt, formatTranscript(chunk))
+		if err != nil {
+			return "", fmt.Errorf("failed to summarize part %d of session %s: %w", i+1, sessionID, err)
+		}
+	}
+
+	summary := summaries[0]
+	if len(summaries) > 1 {
+		parts := make([]string, len(summaries))
+		for i, s := range summaries {
+			parts[i] = fmt.Sprintf("Part %d:\n%s", i+1, s)
+		}
+		summary, err = a.summarize(ctx, finalSummaryPrompt, strings.Join(parts, "\n\n"))
+		if err != nil {
+			return "", fmt.Errorf("failed to combine summaries of session %s: %w", sessionID, err)
+		}
+	}
+
+	if err := a.memory.SetSessionSummary(sessionID, summary); err != nil {
+		return "", err
+	}
+
+	a.logf(ctx, "Summarized session %s: %d messages in %d parts", sessionID, len(history), len(chunks))
+
+	return summary, nil
+}
+
+// summarize sends text to the AI provider with a summarization prompt
+func (a *Agent) summarize(ctx context.Context, prompt, text string) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, 60*time.Second)
+	defer cancel()
+
+	response, err := a.aiProvider.ChatCompletion(ctx, []Message{
+		{Role: "system", Content: prompt},
+		{Role: "user", Content: text},
+	})
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(response), nil
+}
+
+// chunkMessages splits messages into consecutive groups of at most
+// maxTokens. A message larger than maxTokens forms a group on its own.
+func chunkMessages(counter *TokenCounter, messages []Message, maxTokens int) [][]Message {
+	var chunks [][]Message
+	var chunk []Message
+	tokens := 0
+
+	for _, msg := range messages {
+		n := counter.CountMessage(msg)
+		if len(chunk) > 0 && tokens+n > maxTokens {
+			chunks = append(chunks, chunk)
+			chunk, tokens = nil, 0
+		}
+		chunk = append(chunk, msg)
+		tokens += n
+	}
+	if len(chunk) > 0 {
+		chunks = append(chunks, chunk)
+	}
+
+	return chunks
+}
+
+// formatTranscript renders messages as "role: content" lines
+func formatTranscript(messages []Message) string {
+	var b strings.Builder
+	for _, msg := range messages {
+		fmt.Fprintf(&b, "%s: %s\n", msg.Role, msg.Content)
+	}
+	return b.String()
+}
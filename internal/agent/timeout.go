@@ -0,0 +1,63 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// SessionTimeoutKey is the session config key of a session's AI response
+// timeout, in seconds
+const SessionTimeoutKey = "ai_timeout_seconds"
+
+// defaultAITimeout is used when no AI timeout is configured
+const defaultAITimeout = 30 * time.Second
+
+// SetSessionTimeout sets the AI response timeout of a session, which may
+// be at most AIConfig.MaxTimeoutSeconds. A timeout of 0 restores the
+// configured default.
+func (a *Agent) SetSessionTimeout(sessionID string, seconds float64) error {
+	if seconds < 0 {
+		return fmt.Errorf("%s must be positive", SessionTimeoutKey)
+	}
+	if limit := a.config.AI.MaxTimeoutSeconds; limit > 0 && seconds > float64(limit) {
+		return fmt.Errorf("%s must be at most %d", SessionTimeoutKey, limit)
+	}
+
+	value := ""
+	if seconds > 0 {
+		value = strconv.FormatFloat(seconds, 'f', -1, 64)
+	}
+	return a.memory.SetSessionConfig(sessionID, SessionTimeoutKey, value)
+}
+
+// aiTimeout returns the timeout of an AI request for a session: its
+// ai_timeout_seconds if set, else AIConfig.TimeoutSeconds, at most
+// AIConfig.MaxTimeoutSeconds. Streamed responses get twice as long.
+func (a *Agent) aiTimeout(ctx context.Context, sessionID string, streaming bool) time.Duration {
+	timeout := time.Duration(a.config.AI.TimeoutSeconds) * time.Second
+	if timeout <= 0 {
+		timeout = defaultAITimeout
+	}
+
+	value, err := a.memory.GetSessionConfig(sessionID, SessionTimeoutKey)
+	if err != nil {
+		a.logf(ctx, "Warning: failed to load AI timeout for session %s: %v", sessionID, err)
+	} else if value != "" {
+		seconds, err := strconv.ParseFloat(value, 64)
+		if err != nil || seconds <= 0 {
+			a.logf(ctx, "Warning: invalid AI timeout %q for session %s", value, sessionID)
+		} else {
+			timeout = time.Duration(seconds * float64(time.Second))
+		}
+	}
+
+	if limit := time.Duration(a.config.AI.MaxTimeoutSeconds) * time.Second; limit > 0 && timeout > limit {
+		timeout = limit
+	}
+	if streaming {
+		timeout *= 2
+	}
+	return timeout
+}
@@ -0,0 +1,31 @@
+package main
+
+import "unicode/utf8"
+
+// TokenCounter estimates the number of tokens of text sent to the AI
+// provider. It uses the common approximation of four characters per
+// token, plus a fixed overhead per chat message for the role and
+// separators, which is close enough for budgeting context.
+type TokenCounter struct {
+	charsPerToken   int
+	messageOverhead int
+}
+
+// NewTokenCounter creates a new token counter
+func NewTokenCounter() *TokenCounter {
+	return &TokenCounter{
+		charsPerToken:   4,
+		messageOverhead: 4,
+	}
+}
+
+// Count returns the estimated number of tokens in text
+func (c *TokenCounter) Count(text string) int {
+	chars := utf8.RuneCountInString(text)
+	return (chars + c.charsPerToken - 1) / c.charsPerToken
+}
+
+// CountMessage returns the estimated number of tokens of a chat message
+func (c *TokenCounter) CountMessage(message Message) int {
+	return c.Count(message.Content) + c.messageOverhead
+}
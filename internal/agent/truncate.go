@@ -0,0 +1,138 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"unicode/utf8"
+)
+
+// Truncation strategies for responses longer than a platform allows
+const (
+	// TruncateStrategy cuts the response and appends an ellipsis
+	TruncateStrategy = "truncate"
+	// SplitStrategy sends the response in several messages
+	SplitStrategy = "split"
+	// SummarizeStrategy asks the AI provider to shorten the response
+	SummarizeStrategy = "summarize"
+)
+
+// truncationEllipsis marks a truncated response
+const truncationEllipsis = "..."
+
+// shortenPrompt asks the AI provider to shorten a response to %d characters
+const shortenPrompt = "Shorten the following response to at most %d characters. Keep the key information and the formatting. Reply with the shortened response only."
+
+// TruncateResponse shortens text to at most maxLen characters using
+// strategy. A maxLen of 0 or less means no limit. The split strategy
+// leaves text whole; use ResponseParts to get the messages to send.
+func (a *Agent) TruncateResponse(text, strategy string, maxLen int) (string, error) {
+	if maxLen <= 0 || utf8.RuneCountInString(text) <= maxLen {
+		return text, nil
+	}
+
+	switch strategy {
+	case TruncateStrategy, "":
+		return truncateText(text, maxLen), nil
+
+	case SplitStrategy:
+		return text, nil
+
+	case SummarizeStrategy:
+		summary, err := a.summarize(context.Background(), fmt.Sprintf(shortenPrompt, maxLen), text)
+		if err != nil {
+			return "", fmt.Errorf("failed to summarize response: %w", err)
+		}
+		// The provider may not keep to the limit
+		return truncateText(summary, maxLen), nil
+
+	default:
+		return "", fmt.Errorf("unknown truncation strategy: %s", strategy)
+	}
+}
+
+// ResponseParts returns the messages to send for a response on a platform
+// that allows at most maxLen characters per message. If summarizing
+// fails, the response is truncated instead.
+func (a *Agent) ResponseParts(text, strategy string, maxLen int) []string {
+	if strategy == SplitStrategy {
+		return splitText(text, maxLen)
+	}
+
+	shortened, err := a.TruncateResponse(text, strategy, maxLen)
+	if err != nil {
+		log.Printf("Warning: %v, truncating response", err)
+		shortened = truncateText(text, maxLen)
+	}
+	return []string{shortened}
+}
+
+// truncateText cuts text to at most maxLen characters including the
+// ellipsis, at a line or word break near the limit if there is one
+func truncateText(text string, maxLen int) string {
+	runes := []rune(text)
+	if maxLen <= 0 || len(runes) <= maxLen {
+		return text
+	}
+
+	keep := maxLen - len(truncationEllipsis)
+	if keep <= 0 {
+		return string(runes[:maxLen])
+	}
+
+	cut := string(runes[:keep])
+	if i := strings.LastIndexAny(cut, "\n "); i > 0 && utf8.RuneCountInString(cut[:i]) >= keep*4/5 {
+		cut = cut[:i]
+	}
+	return strings.TrimRight(cut, " \n") + truncationEllipsis
+}
+
+// splitText splits text into parts of at most maxLen characters, at line
+// breaks where possible
+func splitText(text string, maxLen int) []string {
+	if maxLen <= 0 || utf8.RuneCountInString(text) <= maxLen {
+		return []string{text}
+	}
+
+	var parts []string
+	var current []string
+	currentLen := 0
+
+	flush := func() {
+		if len(current) > 0 {
+			parts = append(parts, strings.Join(current, "\n"))
+			current, currentLen = nil, 0
+		}
+	}
+
+	for _, line := range strings.Split(text, "\n") {
+		lineLen := utf8.RuneCountInString(line)
+
+		// Lines longer than a message are cut into pieces
+		cut := false
+		for lineLen > maxLen {
+			flush()
+			runes := []rune(line)
+			parts = append(parts, string(runes[:maxLen]))
+			line = string(runes[maxLen:])
+			lineLen -= maxLen
+			cut = true
+		}
+		if cut && lineLen == 0 {
+			continue
+		}
+
+		if len(current) > 0 && currentLen+1+lineLen > maxLen {
+			flush()
+		}
+		if len(current) > 0 {
+			currentLen++
+		}
+		current = append(current, line)
+		currentLen += lineLen
+	}
+	flush()
+
+	return parts
+}
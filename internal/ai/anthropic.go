@@ -4,43 +4,75 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"strings"
 	"time"
+
+	"github.com/Chang-Augenweide/QuickBot-Go/pkg/types"
 )
 
 // AnthropicRequest represents Anthropic API request
 type AnthropicRequest struct {
-	Model     string              `json:"model"`
-	MaxTokens int                 `json:"max_tokens"`
-	Messages  []AnthropicMessage  `json:"messages"`
-	Stream    bool                `json:"stream,omitempty"`
+	Model      string               `json:"model"`
+	MaxTokens  int                  `json:"max_tokens"`
+	Messages   []AnthropicMessage   `json:"messages"`
+	Stream     bool                 `json:"stream,omitempty"`
+	Tools      []AnthropicTool      `json:"tools,omitempty"`
+	ToolChoice *AnthropicToolChoice `json:"tool_choice,omitempty"`
 }
 
 type AnthropicMessage struct {
-	Role    string `json:"role"`
-	Content string `json:"content"`
+	Role    string             `json:"role"`
+	Content []AnthropicContent `json:"content"`
+}
+
+// AnthropicTool represents a tool offered to the model
+type AnthropicTool struct {
+	Name        string                 `json:"name"`
+	Description string                 `json:"description,omitempty"`
+	InputSchema map[string]interface{} `json:"input_schema"`
+}
+
+// AnthropicToolChoice selects how the model uses the tools: "auto",
+// "any", or "tool" to force the tool called Name
+type AnthropicToolChoice struct {
+	Type string `json:"type"`
+	Name string `json:"name,omitempty"`
 }
 
 // AnthropicResponse represents Anthropic API response
 type AnthropicResponse struct {
-	ID      string                 `json:"id"`
-	Type    string                 `json:"type"`
-	Message AnthropicResultMessage  `json:"message"`
-	Error   *AnthropicError        `json:"error,omitempty"`
+	ID         string             `json:"id"`
+	Type       string             `json:"type"`
+	Role       string             `json:"role"`
+	Content    []AnthropicContent `json:"content"`
+	StopReason string             `json:"stop_reason"`
+	Usage      *AnthropicUsage    `json:"usage,omitempty"`
+	Error      *AnthropicError    `json:"error,omitempty"`
 }
 
-type AnthropicResultMessage struct {
-	ID      string   `json:"id"`
-	Role    string   `json:"role"`
-	Content []AnthropicContent `json:"content"`
-	Type    string   `json:"type"`
+// AnthropicUsage represents the token usage of a request
+type AnthropicUsage struct {
+	InputTokens  int `json:"input_tokens"`
+	OutputTokens int `json:"output_tokens"`
 }
 
+// AnthropicContent represents a content block: text, tool_use or
+// tool_result
 type AnthropicContent struct {
-	Type string `json:"type"`
-	Text string `json:"text"`
+	Type      string          `json:"type"`
+	Text      string          `json:"text,omitempty"`
+	ID        string          `json:"id,omitempty"`
+	Name      string          `json:"name,omitempty"`
+	Input     json.RawMessage `json:"input,omitempty"`
+	ToolUseID string          `json:"tool_use_id,omitempty"`
+	Content   string          `json:"content,omitempty"`
 }
 
 type AnthropicError struct {
@@ -50,11 +82,11 @@ type AnthropicError struct {
 
 // AnthropicProvider represents Anthropic API provider
 type AnthropicProvider struct {
-	apiKey      string
-	baseURL     string
-	model       string
-	maxTokens   int
-	httpClient  *http.Client
+	apiKey     string
+	baseURL    string
+	model      string
+	maxTokens  int
+	httpClient *http.Client
 }
 
 // NewAnthropicProvider creates a new Anthropic provider
@@ -75,46 +107,82 @@ func (p *AnthropicProvider) ProviderName() string {
 }
 
 // ChatCompletion sends a chat completion request to Anthropic API
-func (p *AnthropicProvider) ChatCompletion(ctx context.Context, messages []Message) (string, error) {
-	// Convert messages to Anthropic format
-	anthropicMessages := make([]AnthropicMessage, 0, len(messages))
-	for i, msg := range messages {
-		// Skip system messages in the conversion (handle separately if needed)
-		if msg.Role == "system" {
-			continue
-		}
+func (p *AnthropicProvider) ChatCompletion(ctx context.Context, messages []types.Message) (string, error) {
+	response, err := p.send(ctx, messages, nil)
+	if err != nil {
+		return "", err
+	}
+	return anthropicText(response.Content), nil
+}
 
-		// Anthropic doesn't support 'assistant' role in messages array
-		// Convert 'assistant' to 'user' for the API
-		role := msg.Role
-		if role == "assistant" {
-			// Skip for now, or handle differently
-			continue
-		}
+// ToolCallCompletion sends a chat completion request offering tools. When
+// the model stops to use a tool, it returns an *ErrToolCallRequired with
+// the tool_use block as the call.
+func (p *AnthropicProvider) ToolCallCompletion(ctx context.Context, messages []types.Message, tools []types.ToolSpec) (types.Completion, error) {
+	response, err := p.send(ctx, messages, tools)
+	if err != nil {
+		return types.Completion{}, err
+	}
 
-		anthropicMessages = append(anthropicMessages, AnthropicMessage{
-			Role:    role,
-			Content: msg.Content,
-		})
+	if response.StopReason == "tool_use" {
+		for _, content := range response.Content {
+			if content.Type == "tool_use" {
+				return types.Completion{}, &ErrToolCallRequired{Call: types.ToolCall{
+					ID:        content.ID,
+					Name:      content.Name,
+					Arguments: string(content.Input),
+				}}
+			}
+		}
+		return types.Completion{}, fmt.Errorf("no tool_use block in response")
 	}
 
+	return types.Completion{
+		Content:      anthropicText(response.Content),
+		FinishReason: response.StopReason,
+	}, nil
+}
+
+// send sends a messages request, with tools if any
+func (p *AnthropicProvider) send(ctx context.Context, messages []types.Message, tools []types.ToolSpec) (*AnthropicResponse, error) {
 	// Prepare request
 	reqBody := AnthropicRequest{
 		Model:     p.model,
 		MaxTokens: p.maxTokens,
-		Messages:  anthropicMessages,
+		Messages:  toAnthropicMessages(messages),
 		Stream:    false,
 	}
+	for _, tool := range tools {
+		reqBody.Tools = append(reqBody.Tools, AnthropicTool{
+			Name:        tool.Name,
+			Description: tool.Description,
+			InputSchema: tool.Parameters,
+		})
+	}
+	if len(reqBody.Tools) > 0 {
+		reqBody.ToolChoice = &AnthropicToolChoice{Type: "auto"}
+	}
+
+	// Apply per-session overrides to this request only; the request has
+	// no temperature parameter
+	if overrides, ok := types.AIOverridesFromContext(ctx); ok {
+		if overrides.Model != "" {
+			reqBody.Model = overrides.Model
+		}
+		if overrides.MaxTokens > 0 {
+			reqBody.MaxTokens = overrides.MaxTokens
+		}
+	}
 
 	reqJSON, err := json.Marshal(reqBody)
 	if err != nil {
-		return "", fmt.Errorf("failed to marshal request: %w", err)
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
 	}
 
 	// Create HTTP request
 	req, err := http.NewRequestWithContext(ctx, "POST", p.baseURL, bytes.NewBuffer(reqJSON))
 	if err != nil {
-		return "", fmt.Errorf("failed to create request: %w", err)
+		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
 
 	req.Header.Set("Content-Type", "application/json")
@@ -125,46 +193,199 @@ func (p *AnthropicProvider) ChatCompletion(ctx context.Context, messages []Messa
 	// Send request
 	resp, err := p.httpClient.Do(req)
 	if err != nil {
-		return "", fmt.Errorf("failed to send request: %w", err)
+		return nil, fmt.Errorf("failed to send request: %w", err)
 	}
 	defer resp.Body.Close()
 
 	respBody, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return "", fmt.Errorf("failed to read response: %w", err)
+		return nil, fmt.Errorf("failed to read response: %w", err)
 	}
 
 	// Check for errors
 	if resp.StatusCode != http.StatusOK {
+		message := string(respBody)
 		var errorResp AnthropicResponse
 		if err := json.Unmarshal(respBody, &errorResp); err == nil && errorResp.Error != nil {
-			return "", fmt.Errorf("Anthropic API error: %s", errorResp.Error.Message)
+			message = errorResp.Error.Message
 		}
-		return "", fmt.Errorf("API request failed with status %d: %s", resp.StatusCode, string(respBody))
+		return nil, newAPIError("Anthropic", resp, message)
 	}
 
 	// Parse response
 	var response AnthropicResponse
 	if err := json.Unmarshal(respBody, &response); err != nil {
-		return "", fmt.Errorf("failed to unmarshal response: %w", err)
+		return nil, fmt.Errorf("failed to unmarshal response: %w", err)
+	}
+	if response.Usage != nil {
+		reportUsage(ctx, Usage{
+			Provider:         "anthropic",
+			Model:            reqBody.Model,
+			PromptTokens:     response.Usage.InputTokens,
+			CompletionTokens: response.Usage.OutputTokens,
+		})
 	}
 
-	// Extract text from content
-	if len(response.Message.Content) == 0 {
-		return "", fmt.Errorf("no content in response")
+	if len(response.Content) == 0 {
+		return nil, fmt.Errorf("no content in response")
 	}
 
+	return &response, nil
+}
+
+// toAnthropicMessages converts messages to Anthropic content blocks.
+// System messages are skipped, tool calls become tool_use blocks and tool
+// results are sent as tool_result blocks of a user message.
+func toAnthropicMessages(messages []types.Message) []AnthropicMessage {
+	anthropicMessages := make([]AnthropicMessage, 0, len(messages))
+	for _, msg := range messages {
+		role := msg.Role
+		var content []AnthropicContent
+
+		switch msg.Role {
+		case "system":
+			continue
+		case "tool":
+			role = "user"
+			content = append(content, AnthropicContent{
+				Type:      "tool_result",
+				ToolUseID: msg.ToolCallID,
+				Content:   msg.Content,
+			})
+		default:
+			if msg.Content != "" {
+				content = append(content, AnthropicContent{Type: "text", Text: msg.Content})
+			}
+			for _, call := range msg.ToolCalls {
+				input := json.RawMessage(call.Arguments)
+				if strings.TrimSpace(call.Arguments) == "" {
+					input = json.RawMessage("{}")
+				}
+				content = append(content, AnthropicContent{
+					Type:  "tool_use",
+					ID:    call.ID,
+					Name:  call.Name,
+					Input: input,
+				})
+			}
+		}
+
+		if len(content) == 0 {
+			continue
+		}
+		anthropicMessages = append(anthropicMessages, AnthropicMessage{Role: role, Content: content})
+	}
+	return anthropicMessages
+}
+
+// anthropicText joins the text blocks of a response
+func anthropicText(contents []AnthropicContent) string {
 	var result strings.Builder
-	for _, content := range response.Message.Content {
+	for _, content := range contents {
 		if content.Type == "text" {
 			result.WriteString(content.Text)
 		}
 	}
-
-	return result.String(), nil
+	return result.String()
 }
 
 // SetMaxTokens sets the maximum tokens for completion
 func (p *AnthropicProvider) SetMaxTokens(maxTokens int) {
 	p.maxTokens = maxTokens
 }
+
+// anthropicExchange is a recorded Anthropic API request and its response
+type anthropicExchange struct {
+	Request  json.RawMessage `json:"request"`
+	Response json.RawMessage `json:"response"`
+}
+
+// TestAnthropicToolUse replays a recorded tool_use exchange: the model
+// calls the calculator, and the result is sent back as a tool_result
+func TestAnthropicToolUse() error {
+	fmt.Println("Testing Anthropic tool use...")
+
+	data, err := os.ReadFile("internal/ai/testdata/anthropic_tool_use.json")
+	if err != nil {
+		return err
+	}
+	var exchanges []anthropicExchange
+	if err := json.Unmarshal(data, &exchanges); err != nil {
+		return err
+	}
+
+	var mismatch error
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls >= len(exchanges) {
+			http.Error(w, "unexpected request", http.StatusBadRequest)
+			return
+		}
+		exchange := exchanges[calls]
+		calls++
+
+		var got, want interface{}
+		json.NewDecoder(r.Body).Decode(&got)
+		json.Unmarshal(exchange.Request, &want)
+		if !reflect.DeepEqual(got, want) {
+			body, _ := json.Marshal(got)
+			mismatch = fmt.Errorf("request %d does not match the recording: %s", calls, body)
+			http.Error(w, "request mismatch", http.StatusBadRequest)
+			return
+		}
+		if r.Header.Get("x-api-key") != "test-key" {
+			mismatch = fmt.Errorf("unexpected x-api-key: %q", r.Header.Get("x-api-key"))
+		}
+
+		var response bytes.Buffer
+		json.Compact(&response, exchange.Response)
+		w.Write(response.Bytes())
+	}))
+	defer server.Close()
+
+	provider := NewAnthropicProvider("test-key", "claude-3-5-sonnet-20241022").(*AnthropicProvider)
+	provider.baseURL = server.URL
+
+	tools := []types.ToolSpec{{
+		Name:        "calculator",
+		Description: "Perform mathematical calculations",
+		Parameters: map[string]interface{}{
+			"type":       "object",
+			"properties": map[string]interface{}{"expression": map[string]interface{}{"type": "string"}},
+			"required":   []string{"expression"},
+		},
+	}}
+	messages := []types.Message{
+		{Role: "system", Content: "You are QuickBot"},
+		{Role: "user", Content: "What is 2+3?"},
+	}
+
+	_, err = provider.ToolCallCompletion(context.Background(), messages, tools)
+	var toolCallErr *ErrToolCallRequired
+	if !errors.As(err, &toolCallErr) {
+		if mismatch != nil {
+			return mismatch
+		}
+		return fmt.Errorf("expected ErrToolCallRequired, got %v", err)
+	}
+	call := toolCallErr.Call
+	if call.ID != "toolu_01T1x1fJ34qAmk2tNTrN7Up6" || call.Name != "calculator" || call.Arguments != `{"expression":"2+3"}` {
+		return fmt.Errorf("unexpected tool call: %+v", call)
+	}
+	fmt.Println("✓ tool_use returned as ErrToolCallRequired")
+
+	messages = append(messages,
+		types.Message{Role: "assistant", ToolCalls: []types.ToolCall{call}},
+		types.Message{Role: "tool", Content: "5", ToolCallID: call.ID},
+	)
+	completion, err := provider.ToolCallCompletion(context.Background(), messages, tools)
+	if mismatch != nil {
+		return mismatch
+	}
+	if err != nil || completion.Content != "2 + 3 = 5" || completion.FinishReason != "end_turn" {
+		return fmt.Errorf("expected %q, got %+v (%v)", "2 + 3 = 5", completion, err)
+	}
+	fmt.Println("✓ tool_result sent and final answer returned")
+
+	return nil
+}
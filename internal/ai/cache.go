@@ -0,0 +1,282 @@
+package ai
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/Chang-Augenweide/QuickBot-Go/pkg/types"
+	"github.com/hashicorp/golang-lru/v2/expirable"
+)
+
+// CacheStats are the hit and miss counts of a CachingProvider
+type CacheStats struct {
+	Hits     int64   `json:"hits"`
+	Misses   int64   `json:"misses"`
+	HitRatio float64 `json:"hit_ratio"`
+	Size     int     `json:"size"`
+	Capacity int     `json:"capacity"`
+}
+
+// CachingProvider wraps an AIProvider and answers identical requests from
+// an in-memory LRU cache. Only deterministic requests, made with a
+// temperature of 0, are cached, as are only responses without tool calls.
+// The agent also stores responses to whole conversations under
+// MessagesKey with Store and Lookup.
+type CachingProvider struct {
+	provider    AIProvider
+	temperature float64
+	capacity    int
+	entries     *expirable.LRU[string, *cachedCompletion]
+	mu          sync.Mutex
+
+	hits   int64
+	misses int64
+}
+
+// cachedCompletion is a cached completion and the sessions it was served to
+type cachedCompletion struct {
+	completion types.Completion
+	sessions   map[string]bool
+}
+
+// NewCachingProvider creates a caching provider holding up to size
+// responses for ttl. temperature is the configured temperature, used
+// when a session does not override it.
+func NewCachingProvider(provider AIProvider, size int, ttl time.Duration, temperature float64) *CachingProvider {
+	return &CachingProvider{
+		provider:    provider,
+		temperature: temperature,
+		capacity:    size,
+		entries:     expirable.NewLRU[string, *cachedCompletion](size, nil, ttl),
+	}
+}
+
+func (p *CachingProvider) ProviderName() string {
+	return p.provider.ProviderName()
+}
+
+// ChatCompletion returns the cached response to messages, or calls the
+// wrapped provider
+func (p *CachingProvider) ChatCompletion(ctx context.Context, messages []types.Message) (string, error) {
+	completion, err := p.complete(ctx, messages, nil, func() (types.Completion, error) {
+		content, err := p.provider.ChatCompletion(ctx, messages)
+		return types.Completion{Content: content, FinishReason: "stop"}, err
+	})
+	return completion.Content, err
+}
+
+// ToolCallCompletion returns the cached completion of messages with
+// tools, or calls the wrapped provider
+func (p *CachingProvider) ToolCallCompletion(ctx context.Context, messages []types.Message, tools []types.ToolSpec) (types.Completion, error) {
+	return p.complete(ctx, messages, tools, func() (types.Completion, error) {
+		return p.provider.ToolCallCompletion(ctx, messages, tools)
+	})
+}
+
+// complete looks the request up in the cache, calling request and
+// caching its completion on a miss
+func (p *CachingProvider) complete(ctx context.Context, messages []types.Message, tools []types.ToolSpec, request func() (types.Completion, error)) (types.Completion, error) {
+	overrides, _ := types.AIOverridesFromContext(ctx)
+	temperature := p.temperature
+	if overrides.Temperature != nil {
+		temperature = *overrides.Temperature
+	}
+	if temperature != 0 {
+		return request()
+	}
+
+	key, err := requestKey(overrides.Model, messages, tools)
+	if err != nil {
+		return request()
+	}
+
+	sessionID := types.SessionIDFromContext(ctx)
+	if completion, ok := p.Lookup(key, sessionID); ok {
+		return completion, nil
+	}
+
+	completion, err := request()
+	if err != nil {
+		return completion, err
+	}
+	if len(completion.ToolCalls) == 0 {
+		p.Store(key, sessionID, completion)
+	}
+	return completion, nil
+}
+
+// Lookup returns the completion cached under key and records that it was
+// served to sessionID
+func (p *CachingProvider) Lookup(key, sessionID string) (types.Completion, bool) {
+	entry, ok := p.entries.Get(key)
+	if !ok {
+		atomic.AddInt64(&p.misses, 1)
+		return types.Completion{}, false
+	}
+	atomic.AddInt64(&p.hits, 1)
+
+	p.mu.Lock()
+	entry.sessions[sessionID] = true
+	p.mu.Unlock()
+	return entry.completion, true
+}
+
+// Store caches completion under key for sessionID
+func (p *CachingProvider) Store(key, sessionID string, completion types.Completion) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.entries.Add(key, &cachedCompletion{
+		completion: completion,
+		sessions:   map[string]bool{sessionID: true},
+	})
+}
+
+// InvalidateSession removes every cached completion served to a session
+// and returns their number
+func (p *CachingProvider) InvalidateSession(sessionID string) int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	count := 0
+	for _, key := range p.entries.Keys() {
+		if entry, ok := p.entries.Peek(key); ok && entry.sessions[sessionID] {
+			p.entries.Remove(key)
+			count++
+		}
+	}
+	return count
+}
+
+// MessagesKey returns the cache key of a request for messages to model,
+// the SHA-256 hash of the provider, model, temperature and messages
+func MessagesKey(provider, model string, temperature float64, messages []types.Message) (string, error) {
+	messagesJSON, err := json.Marshal(messages)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal messages: %w", err)
+	}
+
+	hash := sha256.New()
+	for _, field := range []string{provider, model, fmt.Sprintf("%g", temperature)} {
+		hash.Write([]byte(field))
+		hash.Write([]byte{0})
+	}
+	hash.Write(messagesJSON)
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// requestKey returns the SHA-256 hash of the serialized request
+func requestKey(model string, messages []types.Message, tools []types.ToolSpec) (string, error) {
+	requestJSON, err := json.Marshal(struct {
+		Model    string           `json:"model,omitempty"`
+		Messages []types.Message  `json:"messages"`
+		Tools    []types.ToolSpec `json:"tools,omitempty"`
+	}{model, messages, tools})
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	hash := sha256.Sum256(requestJSON)
+	return hex.EncodeToString(hash[:]), nil
+}
+
+// Stats returns the cache hit and miss counts
+func (p *CachingProvider) Stats() CacheStats {
+	stats := CacheStats{
+		Hits:     atomic.LoadInt64(&p.hits),
+		Misses:   atomic.LoadInt64(&p.misses),
+		Size:     p.entries.Len(),
+		Capacity: p.capacity,
+	}
+	if total := stats.Hits + stats.Misses; total > 0 {
+		stats.HitRatio = float64(stats.Hits) / float64(total)
+	}
+	return stats
+}
+
+// Purge removes all cached responses and returns their number
+func (p *CachingProvider) Purge() int {
+	size := p.entries.Len()
+	p.entries.Purge()
+	return size
+}
+
+// TestCachingProvider checks that deterministic requests are answered
+// from the cache
+func TestCachingProvider() error {
+	fmt.Println("Testing Caching Provider...")
+
+	backend := &chainTestProvider{name: "backend"}
+	provider := NewCachingProvider(backend, 2, time.Minute, 0)
+	messages := []types.Message{{Role: "user", Content: "Hi"}}
+
+	for i := 0; i < 3; i++ {
+		response, err := provider.ChatCompletion(context.Background(), messages)
+		if err != nil || response != "response from backend" {
+			return fmt.Errorf("unexpected response %q (%v)", response, err)
+		}
+	}
+	if backend.calls != 1 {
+		return fmt.Errorf("expected one call to the provider, got %d", backend.calls)
+	}
+	if stats := provider.Stats(); stats.Hits != 2 || stats.Misses != 1 || stats.Size != 1 {
+		return fmt.Errorf("unexpected cache stats: %+v", stats)
+	}
+	fmt.Println("✓ Identical request served from cache")
+
+	temperature := 0.7
+	ctx := types.WithAIOverrides(context.Background(), types.AIOverrides{Temperature: &temperature})
+	for i := 0; i < 2; i++ {
+		if _, err := provider.ChatCompletion(ctx, messages); err != nil {
+			return err
+		}
+	}
+	if backend.calls != 3 {
+		return fmt.Errorf("expected requests with temperature 0.7 not to be cached, got %d calls", backend.calls)
+	}
+	fmt.Println("✓ Non-zero temperature not cached")
+
+	for _, content := range []string{"a", "b", "c"} {
+		provider.ChatCompletion(context.Background(), []types.Message{{Role: "user", Content: content}})
+	}
+	if size := provider.Stats().Size; size != 2 {
+		return fmt.Errorf("expected the cache to hold 2 responses, got %d", size)
+	}
+	if evicted := provider.Purge(); evicted != 2 || provider.Stats().Size != 0 {
+		return fmt.Errorf("expected 2 responses purged, got %d", evicted)
+	}
+	fmt.Println("✓ Least recently used responses evicted")
+
+	ctx = types.WithSessionID(context.Background(), "session-a")
+	provider.ChatCompletion(ctx, messages)
+	provider.ChatCompletion(types.WithSessionID(context.Background(), "session-b"), messages)
+	provider.ChatCompletion(ctx, []types.Message{{Role: "user", Content: "a"}})
+	if evicted := provider.InvalidateSession("session-b"); evicted != 1 || provider.Stats().Size != 1 {
+		return fmt.Errorf("expected the response served to session-b evicted, got %d", evicted)
+	}
+	if evicted := provider.InvalidateSession("session-a"); evicted != 1 || provider.Stats().Size != 0 {
+		return fmt.Errorf("expected the remaining response of session-a evicted, got %d", evicted)
+	}
+	fmt.Println("✓ Responses invalidated for every session served")
+
+	history := []types.Message{{Role: "system", Content: "sys"}, {Role: "user", Content: "What is my name?"}}
+	other := []types.Message{{Role: "system", Content: "sys"}, {Role: "user", Content: "I am Ann"}, {Role: "assistant", Content: "Hi Ann"}, {Role: "user", Content: "What is my name?"}}
+	key, _ := MessagesKey("p", "m", 0, history)
+	if same, _ := MessagesKey("p", "m", 0, history); same != key {
+		return fmt.Errorf("expected identical requests to share a key")
+	}
+	if otherKey, _ := MessagesKey("p", "m", 0, other); otherKey == key {
+		return fmt.Errorf("expected the history to be part of the key")
+	}
+	if warmKey, _ := MessagesKey("p", "m", 0.7, history); warmKey == key {
+		return fmt.Errorf("expected the temperature to be part of the key")
+	}
+	fmt.Println("✓ Message keys cover the whole conversation")
+
+	return nil
+}
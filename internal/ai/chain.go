@@ -0,0 +1,151 @@
+package ai
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+
+	"github.com/Chang-Augenweide/QuickBot-Go/pkg/types"
+)
+
+// FallbackChain is an AIProvider that tries its providers in order: when
+// one fails, the request is sent to the next. Context errors and tool
+// call requests are returned as is.
+type FallbackChain struct {
+	providers []AIProvider
+}
+
+// NewFallbackChain creates a fallback chain; the first provider is the
+// primary one
+func NewFallbackChain(providers ...AIProvider) *FallbackChain {
+	return &FallbackChain{providers: providers}
+}
+
+// ProviderName returns the name of the primary provider
+func (c *FallbackChain) ProviderName() string {
+	return c.providers[0].ProviderName()
+}
+
+// ChatCompletion sends the request to each provider in turn until one
+// succeeds
+func (c *FallbackChain) ChatCompletion(ctx context.Context, messages []types.Message) (string, error) {
+	var response string
+	err := c.try(ctx, func(provider AIProvider) error {
+		var err error
+		response, err = provider.ChatCompletion(ctx, messages)
+		return err
+	})
+	return response, err
+}
+
+// ToolCallCompletion sends the request to each provider in turn until one
+// succeeds
+func (c *FallbackChain) ToolCallCompletion(ctx context.Context, messages []types.Message, tools []types.ToolSpec) (types.Completion, error) {
+	var completion types.Completion
+	err := c.try(ctx, func(provider AIProvider) error {
+		var err error
+		completion, err = provider.ToolCallCompletion(ctx, messages, tools)
+		return err
+	})
+	return completion, err
+}
+
+// try calls request with each provider until it succeeds or fails with an
+// error that another provider would not fix
+func (c *FallbackChain) try(ctx context.Context, request func(AIProvider) error) error {
+	var lastErr error
+
+	for i, provider := range c.providers {
+		err := request(provider)
+		if err == nil {
+			if i > 0 {
+				log.Printf("AI response served by fallback provider %s", provider.ProviderName())
+			}
+			return nil
+		}
+		if !shouldFallBack(err) {
+			return err
+		}
+		lastErr = err
+
+		if i+1 < len(c.providers) {
+			log.Printf("Warning: %s request failed, falling back to %s: %v",
+				provider.ProviderName(), c.providers[i+1].ProviderName(), err)
+		}
+	}
+
+	return fmt.Errorf("all AI providers failed: %w", lastErr)
+}
+
+// shouldFallBack reports whether a request that failed with err may be
+// sent to the next provider
+func shouldFallBack(err error) bool {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+
+	var toolCallErr *ErrToolCallRequired
+	return !errors.As(err, &toolCallErr)
+}
+
+// chainTestProvider returns err, or its name as the response, and counts
+// calls
+type chainTestProvider struct {
+	name  string
+	err   error
+	calls int
+}
+
+func (p *chainTestProvider) ProviderName() string {
+	return p.name
+}
+
+func (p *chainTestProvider) ChatCompletion(ctx context.Context, messages []types.Message) (string, error) {
+	p.calls++
+	if p.err != nil {
+		return "", p.err
+	}
+	return "response from " + p.name, nil
+}
+
+func (p *chainTestProvider) ToolCallCompletion(ctx context.Context, messages []types.Message, tools []types.ToolSpec) (types.Completion, error) {
+	return completionWithoutTools(ctx, p, messages)
+}
+
+// TestFallbackChain makes the primary provider fail and checks that the
+// next one answers
+func TestFallbackChain() error {
+	fmt.Println("Testing Fallback Chain...")
+
+	primary := &chainTestProvider{name: "primary", err: &APIError{Provider: "primary", StatusCode: 429, Message: "quota exceeded"}}
+	secondary := &chainTestProvider{name: "secondary"}
+	chain := NewFallbackChain(primary, secondary)
+
+	response, err := chain.ChatCompletion(context.Background(), []types.Message{{Role: "user", Content: "Hi"}})
+	if err != nil || response != "response from secondary" {
+		return fmt.Errorf("expected the secondary provider to answer, got %q (%v)", response, err)
+	}
+	if primary.calls != 1 || secondary.calls != 1 {
+		return fmt.Errorf("expected one call to each provider, got %d and %d", primary.calls, secondary.calls)
+	}
+	if chain.ProviderName() != "primary" {
+		return fmt.Errorf("expected chain to be named after the primary provider, got %s", chain.ProviderName())
+	}
+	fmt.Println("✓ Failed request sent to the next provider")
+
+	primary.err = context.Canceled
+	if _, err := chain.ChatCompletion(context.Background(), nil); !errors.Is(err, context.Canceled) || secondary.calls != 1 {
+		return fmt.Errorf("expected context.Canceled without fallback, got %v after %d calls", err, secondary.calls)
+	}
+	fmt.Println("✓ Context errors not retried on other providers")
+
+	primary.err = errors.New("connection refused")
+	secondary.err = errors.New("connection refused")
+	if _, err := chain.ChatCompletion(context.Background(), nil); err == nil {
+		return fmt.Errorf("expected an error when all providers fail")
+	}
+	fmt.Println("✓ Error returned when all providers fail")
+
+	return nil
+}
@@ -0,0 +1,237 @@
+package ai
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/Chang-Augenweide/QuickBot-Go/pkg/types"
+)
+
+// ErrCircuitOpen is returned while the circuit breaker is rejecting requests
+var ErrCircuitOpen = errors.New("AI provider circuit breaker is open")
+
+// CircuitState represents circuit breaker state
+type CircuitState string
+
+const (
+	CircuitClosed   CircuitState = "closed"
+	CircuitOpen     CircuitState = "open"
+	CircuitHalfOpen CircuitState = "half-open"
+)
+
+// CircuitBreaker wraps an AIProvider and stops calling it after Threshold
+// consecutive failures. Once Timeout has elapsed a single trial request is
+// let through; if it succeeds the circuit closes again.
+type CircuitBreaker struct {
+	provider  AIProvider
+	Threshold int
+	Timeout   time.Duration
+
+	mu       sync.Mutex
+	state    CircuitState
+	failures int
+	openedAt time.Time
+	trial    bool
+
+	// now returns the current time (replaceable for testing)
+	now func() time.Time
+}
+
+// NewCircuitBreaker creates a new circuit breaker
+func NewCircuitBreaker(provider AIProvider, threshold int, timeout time.Duration) *CircuitBreaker {
+	if threshold <= 0 {
+		threshold = 5
+	}
+	if timeout <= 0 {
+		timeout = 30 * time.Second
+	}
+
+	return &CircuitBreaker{
+		provider:  provider,
+		Threshold: threshold,
+		Timeout:   timeout,
+		state:     CircuitClosed,
+		now:       time.Now,
+	}
+}
+
+func (cb *CircuitBreaker) ProviderName() string {
+	return cb.provider.ProviderName()
+}
+
+// ChatCompletion calls the wrapped provider unless the circuit is open
+func (cb *CircuitBreaker) ChatCompletion(ctx context.Context, messages []types.Message) (string, error) {
+	if err := cb.allow(); err != nil {
+		return "", err
+	}
+
+	response, err := cb.provider.ChatCompletion(ctx, messages)
+	cb.record(err)
+	return response, err
+}
+
+// ToolCallCompletion calls the wrapped provider unless the circuit is open
+func (cb *CircuitBreaker) ToolCallCompletion(ctx context.Context, messages []types.Message, tools []types.ToolSpec) (types.Completion, error) {
+	if err := cb.allow(); err != nil {
+		return types.Completion{}, err
+	}
+
+	completion, err := cb.provider.ToolCallCompletion(ctx, messages, tools)
+	cb.record(err)
+	return completion, err
+}
+
+// Status returns the current circuit state
+func (cb *CircuitBreaker) Status() CircuitState {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	if cb.state == CircuitOpen && cb.now().Sub(cb.openedAt) >= cb.Timeout {
+		return CircuitHalfOpen
+	}
+	return cb.state
+}
+
+// allow reports whether a request may be sent
+func (cb *CircuitBreaker) allow() error {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	switch cb.state {
+	case CircuitOpen:
+		if cb.now().Sub(cb.openedAt) < cb.Timeout {
+			return ErrCircuitOpen
+		}
+		cb.state = CircuitHalfOpen
+		cb.trial = true
+		return nil
+	case CircuitHalfOpen:
+		// Only one trial request at a time
+		if cb.trial {
+			return ErrCircuitOpen
+		}
+		cb.trial = true
+		return nil
+	default:
+		return nil
+	}
+}
+
+// record updates the circuit state with the outcome of a request
+func (cb *CircuitBreaker) record(err error) {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	cb.trial = false
+
+	if !isProviderFailure(err) {
+		if cb.state != CircuitClosed {
+			log.Printf("%s circuit breaker closed", cb.provider.ProviderName())
+		}
+		cb.state = CircuitClosed
+		cb.failures = 0
+		return
+	}
+
+	cb.failures++
+	if cb.state == CircuitHalfOpen || cb.failures >= cb.Threshold {
+		if cb.state != CircuitOpen {
+			log.Printf("Warning: %s circuit breaker opened after %d consecutive failures",
+				cb.provider.ProviderName(), cb.failures)
+		}
+		cb.state = CircuitOpen
+		cb.openedAt = cb.now()
+	}
+}
+
+// isProviderFailure reports whether err indicates the provider is
+// unhealthy. Client errors (4xx other than 429) and cancelled requests
+// do not count against the provider.
+func isProviderFailure(err error) bool {
+	if err == nil {
+		return false
+	}
+	if errors.Is(err, context.Canceled) {
+		return false
+	}
+
+	var toolCallErr *ErrToolCallRequired
+	if errors.As(err, &toolCallErr) {
+		return false
+	}
+
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.Retryable()
+	}
+
+	// Network errors and timeouts
+	return true
+}
+
+// TestCircuitBreaker opens the circuit with threshold failures, checks
+// that calls are rejected until the timeout and that a successful trial
+// call closes it again
+func TestCircuitBreaker() error {
+	fmt.Println("Testing Circuit Breaker...")
+
+	unavailable := &APIError{Provider: "flaky", StatusCode: 503, Message: "unavailable"}
+	provider := &flakyProvider{errors: []error{unavailable, unavailable, unavailable, unavailable}}
+	cb := NewCircuitBreaker(provider, 3, time.Minute)
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	cb.now = func() time.Time { return now }
+
+	for i := 0; i < 3; i++ {
+		if _, err := cb.ChatCompletion(context.Background(), nil); !errors.Is(err, unavailable) {
+			return fmt.Errorf("call %d: expected the provider error, got %v", i+1, err)
+		}
+	}
+	if cb.Status() != CircuitOpen {
+		return fmt.Errorf("expected open circuit after 3 failures, got %s", cb.Status())
+	}
+	if _, err := cb.ChatCompletion(context.Background(), nil); !errors.Is(err, ErrCircuitOpen) || provider.calls != 3 {
+		return fmt.Errorf("expected ErrCircuitOpen without calling the provider, got %v after %d calls", err, provider.calls)
+	}
+	fmt.Println("✓ Circuit opened after threshold failures")
+
+	// A failed trial call opens the circuit for another timeout
+	now = now.Add(time.Minute)
+	if cb.Status() != CircuitHalfOpen {
+		return fmt.Errorf("expected half-open circuit after the timeout, got %s", cb.Status())
+	}
+	if _, err := cb.ChatCompletion(context.Background(), nil); !errors.Is(err, unavailable) || provider.calls != 4 {
+		return fmt.Errorf("expected the trial call to reach the provider, got %v after %d calls", err, provider.calls)
+	}
+	if _, err := cb.ChatCompletion(context.Background(), nil); !errors.Is(err, ErrCircuitOpen) {
+		return fmt.Errorf("expected ErrCircuitOpen after a failed trial, got %v", err)
+	}
+
+	now = now.Add(time.Minute)
+	response, err := cb.ChatCompletion(context.Background(), nil)
+	if err != nil || response != "success" {
+		return fmt.Errorf("expected the trial call to succeed, got %q (%v)", response, err)
+	}
+	if cb.Status() != CircuitClosed {
+		return fmt.Errorf("expected closed circuit after a successful trial, got %s", cb.Status())
+	}
+	fmt.Println("✓ Trial call allowed after the timeout, circuit closed on success")
+
+	// Client errors do not count as provider failures
+	provider = &flakyProvider{errors: []error{
+		&APIError{StatusCode: 400}, &APIError{StatusCode: 400}, &APIError{StatusCode: 400},
+	}}
+	cb = NewCircuitBreaker(provider, 2, time.Minute)
+	for i := 0; i < 3; i++ {
+		cb.ChatCompletion(context.Background(), nil)
+	}
+	if cb.Status() != CircuitClosed {
+		return fmt.Errorf("expected client errors to leave the circuit closed, got %s", cb.Status())
+	}
+	fmt.Println("✓ Client errors ignored")
+
+	return nil
+}
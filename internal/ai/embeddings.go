@@ -0,0 +1,113 @@
+package ai
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// DefaultEmbeddingModel is the embedding model used when none is configured
+const DefaultEmbeddingModel = "text-embedding-3-small"
+
+// EmbeddingProvider turns text into embedding vectors
+type EmbeddingProvider interface {
+	GetEmbedding(ctx context.Context, text string) ([]float64, error)
+}
+
+// OpenAIEmbeddingRequest represents OpenAI embeddings API request
+type OpenAIEmbeddingRequest struct {
+	Model string `json:"model"`
+	Input string `json:"input"`
+}
+
+// OpenAIEmbeddingResponse represents OpenAI embeddings API response
+type OpenAIEmbeddingResponse struct {
+	Data []struct {
+		Embedding []float64 `json:"embedding"`
+		Index     int       `json:"index"`
+	} `json:"data"`
+	Error *struct {
+		Message string `json:"message"`
+	} `json:"error,omitempty"`
+}
+
+// OpenAIEmbeddingProvider calls an OpenAI-compatible /embeddings endpoint
+type OpenAIEmbeddingProvider struct {
+	apiKey     string
+	baseURL    string
+	model      string
+	httpClient *http.Client
+}
+
+// NewOpenAIEmbeddingProvider creates a new OpenAI embedding provider
+func NewOpenAIEmbeddingProvider(apiKey, baseURL, model string) EmbeddingProvider {
+	if baseURL == "" {
+		baseURL = "https://api.openai.com/v1"
+	}
+	if model == "" {
+		model = DefaultEmbeddingModel
+	}
+
+	return &OpenAIEmbeddingProvider{
+		apiKey:  apiKey,
+		baseURL: strings.TrimSuffix(baseURL, "/"),
+		model:   model,
+		httpClient: &http.Client{
+			Timeout: 30 * time.Second,
+		},
+	}
+}
+
+// GetEmbedding returns the embedding vector of text
+func (p *OpenAIEmbeddingProvider) GetEmbedding(ctx context.Context, text string) ([]float64, error) {
+	reqJSON, err := json.Marshal(OpenAIEmbeddingRequest{
+		Model: p.model,
+		Input: text,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", p.baseURL+"/embeddings", bytes.NewBuffer(reqJSON))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+p.apiKey)
+
+	resp, err := p.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+
+	var response OpenAIEmbeddingResponse
+	if resp.StatusCode != http.StatusOK {
+		message := string(respBody)
+		if err := json.Unmarshal(respBody, &response); err == nil && response.Error != nil {
+			message = response.Error.Message
+		}
+		return nil, newAPIError("OpenAI", resp, message)
+	}
+
+	if err := json.Unmarshal(respBody, &response); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal response: %w", err)
+	}
+
+	if len(response.Data) == 0 || len(response.Data[0].Embedding) == 0 {
+		return nil, fmt.Errorf("no embedding in response")
+	}
+
+	return response.Data[0].Embedding, nil
+}
@@ -0,0 +1,67 @@
+package ai
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Chang-Augenweide/QuickBot-Go/pkg/types"
+)
+
+// APIError represents a non-success HTTP response from an AI provider
+type APIError struct {
+	Provider   string
+	StatusCode int
+	Message    string
+	RetryAfter time.Duration
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("%s API error (status %d): %s", e.Provider, e.StatusCode, e.Message)
+}
+
+// Retryable reports whether the request may succeed if retried
+func (e *APIError) Retryable() bool {
+	return e.StatusCode == http.StatusTooManyRequests || e.StatusCode >= 500
+}
+
+// ErrToolCallRequired is returned by ToolCallCompletion when the model
+// stopped to call a tool. The caller runs the tool and completes again
+// with the result in a "tool" message.
+type ErrToolCallRequired struct {
+	Call types.ToolCall
+}
+
+func (e *ErrToolCallRequired) Error() string {
+	return fmt.Sprintf("tool call required: %s", e.Call.Name)
+}
+
+// newAPIError builds an APIError from a response, reading Retry-After
+func newAPIError(provider string, resp *http.Response, message string) *APIError {
+	return &APIError{
+		Provider:   provider,
+		StatusCode: resp.StatusCode,
+		Message:    message,
+		RetryAfter: parseRetryAfter(resp.Header.Get("Retry-After")),
+	}
+}
+
+// parseRetryAfter parses a Retry-After header in seconds or HTTP-date form
+func parseRetryAfter(value string) time.Duration {
+	if value == "" {
+		return 0
+	}
+
+	if seconds, err := strconv.Atoi(value); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+
+	if t, err := http.ParseTime(value); err == nil {
+		if d := time.Until(t); d > 0 {
+			return d
+		}
+	}
+
+	return 0
+}
@@ -0,0 +1,383 @@
+package ai
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"time"
+
+	"github.com/Chang-Augenweide/QuickBot-Go/pkg/types"
+)
+
+const (
+	// defaultGeminiModel is used when no model is configured
+	defaultGeminiModel = "gemini-1.5-pro"
+
+	// defaultGeminiLocation is the Vertex AI region used when a project is
+	// set without a location
+	defaultGeminiLocation = "us-central1"
+)
+
+// geminiBlockFinishReasons are the finish reasons of responses stopped by
+// Gemini's safety and content filters
+var geminiBlockFinishReasons = map[string]bool{
+	"SAFETY":             true,
+	"RECITATION":         true,
+	"BLOCKLIST":          true,
+	"PROHIBITED_CONTENT": true,
+	"SPII":               true,
+}
+
+// GeminiBlockedError is returned when Gemini blocks a prompt, or stops a
+// response, for safety or other content policy reasons
+type GeminiBlockedError struct {
+	// Reason is the prompt's blockReason or the response's finishReason
+	Reason string
+	// Prompt is true if the prompt was blocked, false if the response was
+	Prompt bool
+}
+
+func (e *GeminiBlockedError) Error() string {
+	if e.Prompt {
+		return fmt.Sprintf("Gemini blocked the prompt: %s", e.Reason)
+	}
+	return fmt.Sprintf("Gemini blocked the response: %s", e.Reason)
+}
+
+// GeminiRequest represents Gemini generateContent request
+type GeminiRequest struct {
+	Contents          []GeminiContent         `json:"contents"`
+	SystemInstruction *GeminiContent          `json:"systemInstruction,omitempty"`
+	GenerationConfig  *GeminiGenerationConfig `json:"generationConfig,omitempty"`
+}
+
+type GeminiContent struct {
+	Role  string       `json:"role,omitempty"`
+	Parts []GeminiPart `json:"parts"`
+}
+
+type GeminiPart struct {
+	Text string `json:"text"`
+}
+
+type GeminiGenerationConfig struct {
+	MaxOutputTokens int     `json:"maxOutputTokens,omitempty"`
+	Temperature     float64 `json:"temperature"`
+}
+
+// GeminiResponse represents Gemini generateContent response
+type GeminiResponse struct {
+	Candidates     []GeminiCandidate     `json:"candidates"`
+	PromptFeedback *GeminiPromptFeedback `json:"promptFeedback,omitempty"`
+	Error          *GeminiError          `json:"error,omitempty"`
+}
+
+type GeminiCandidate struct {
+	Content      GeminiContent `json:"content"`
+	FinishReason string        `json:"finishReason"`
+}
+
+type GeminiPromptFeedback struct {
+	BlockReason string `json:"blockReason"`
+}
+
+type GeminiError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+	Status  string `json:"status"`
+}
+
+// GeminiProvider represents Google Gemini API provider. Without a project
+// it calls the Gemini API (Google AI Studio) with an API key; with a
+// project it calls Vertex AI in location, and apiKey must be an OAuth
+// access token.
+type GeminiProvider struct {
+	apiKey      string
+	baseURL     string
+	project     string
+	model       string
+	maxTokens   int
+	temperature float64
+	httpClient  *http.Client
+}
+
+// NewGeminiProvider creates a new Gemini provider. An empty model uses
+// gemini-1.5-pro and an empty location us-central1.
+func NewGeminiProvider(apiKey, model, project, location string) AIProvider {
+	if model == "" {
+		model = defaultGeminiModel
+	}
+	if location == "" {
+		location = defaultGeminiLocation
+	}
+
+	baseURL := "https://generativelanguage.googleapis.com/v1beta"
+	if project != "" {
+		baseURL = fmt.Sprintf("https://%s-aiplatform.googleapis.com/v1/projects/%s/locations/%s/publishers/google",
+			location, project, location)
+	}
+
+	return &GeminiProvider{
+		apiKey:      apiKey,
+		baseURL:     baseURL,
+		project:     project,
+		model:       model,
+		maxTokens:   2000,
+		temperature: 0.7,
+		httpClient: &http.Client{
+			Timeout: 30 * time.Second,
+		},
+	}
+}
+
+func (p *GeminiProvider) ProviderName() string {
+	return "gemini"
+}
+
+// ChatCompletion sends a generateContent request to the Gemini API
+func (p *GeminiProvider) ChatCompletion(ctx context.Context, messages []types.Message) (string, error) {
+	model := p.model
+	reqBody := GeminiRequest{
+		GenerationConfig: &GeminiGenerationConfig{
+			MaxOutputTokens: p.maxTokens,
+			Temperature:     p.temperature,
+		},
+	}
+	reqBody.SystemInstruction, reqBody.Contents = geminiContents(messages)
+
+	// Apply per-session overrides to this request only
+	if overrides, ok := types.AIOverridesFromContext(ctx); ok {
+		if overrides.Model != "" {
+			model = overrides.Model
+		}
+		if overrides.MaxTokens > 0 {
+			reqBody.GenerationConfig.MaxOutputTokens = overrides.MaxTokens
+		}
+		if overrides.Temperature != nil {
+			reqBody.GenerationConfig.Temperature = *overrides.Temperature
+		}
+	}
+
+	reqJSON, err := json.Marshal(reqBody)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	// Create HTTP request
+	url := fmt.Sprintf("%s/models/%s:generateContent", p.baseURL, model)
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(reqJSON))
+	if err != nil {
+		return "", fmt.Errorf("failed to create request: %w", err)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	if p.project != "" {
+		req.Header.Set("Authorization", "Bearer "+p.apiKey)
+	} else {
+		req.Header.Set("x-goog-api-key", p.apiKey)
+	}
+
+	// Send request
+	resp, err := p.httpClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", fmt.Errorf("failed to read response: %w", err)
+	}
+
+	// Check for errors
+	if resp.StatusCode != http.StatusOK {
+		message := string(respBody)
+		var errorResp GeminiResponse
+		if err := json.Unmarshal(respBody, &errorResp); err == nil && errorResp.Error != nil {
+			message = errorResp.Error.Message
+		}
+		return "", newAPIError("Gemini", resp, message)
+	}
+
+	return parseGeminiResponse(respBody)
+}
+
+// ToolCallCompletion sends a chat completion request without the tools:
+// function calling is not supported for Gemini yet
+func (p *GeminiProvider) ToolCallCompletion(ctx context.Context, messages []types.Message, tools []types.ToolSpec) (types.Completion, error) {
+	return completionWithoutTools(ctx, p, messages)
+}
+
+// SetMaxTokens sets the maximum tokens for completion
+func (p *GeminiProvider) SetMaxTokens(maxTokens int) {
+	p.maxTokens = maxTokens
+}
+
+// SetTemperature sets the temperature for completion
+func (p *GeminiProvider) SetTemperature(temperature float64) {
+	p.temperature = temperature
+}
+
+// geminiContents converts messages to Gemini contents. System messages
+// become the system instruction, and the "assistant" role is Gemini's
+// "model" role.
+func geminiContents(messages []types.Message) (*GeminiContent, []GeminiContent) {
+	var system []string
+	contents := make([]GeminiContent, 0, len(messages))
+
+	for _, msg := range messages {
+		switch msg.Role {
+		case "system":
+			system = append(system, msg.Content)
+			continue
+		case "assistant":
+			contents = append(contents, GeminiContent{Role: "model", Parts: []GeminiPart{{Text: msg.Content}}})
+		default:
+			contents = append(contents, GeminiContent{Role: "user", Parts: []GeminiPart{{Text: msg.Content}}})
+		}
+	}
+
+	if len(system) == 0 {
+		return nil, contents
+	}
+	return &GeminiContent{Parts: []GeminiPart{{Text: strings.Join(system, "\n\n")}}}, contents
+}
+
+// parseGeminiResponse extracts the text of the first candidate. Blocked
+// prompts and responses return a *GeminiBlockedError.
+func parseGeminiResponse(body []byte) (string, error) {
+	var response GeminiResponse
+	if err := json.Unmarshal(body, &response); err != nil {
+		return "", fmt.Errorf("failed to unmarshal response: %w", err)
+	}
+
+	if response.Error != nil {
+		return "", fmt.Errorf("Gemini API error: %s", response.Error.Message)
+	}
+
+	if len(response.Candidates) == 0 {
+		if response.PromptFeedback != nil && response.PromptFeedback.BlockReason != "" {
+			return "", &GeminiBlockedError{Reason: response.PromptFeedback.BlockReason, Prompt: true}
+		}
+		return "", fmt.Errorf("no candidates in response")
+	}
+
+	var result strings.Builder
+	for _, part := range response.Candidates[0].Content.Parts {
+		result.WriteString(part.Text)
+	}
+	finishReason := response.Candidates[0].FinishReason
+	if result.Len() == 0 {
+		if geminiBlockFinishReasons[finishReason] {
+			return "", &GeminiBlockedError{Reason: finishReason}
+		}
+		return "", fmt.Errorf("no content in response (finish reason: %s)", finishReason)
+	}
+
+	return result.String(), nil
+}
+
+// TestGeminiMessages runs ChatCompletion against a fixture response and
+// checks the provider name, the role mapping and the extracted text
+func TestGeminiMessages() error {
+	fmt.Println("Testing Gemini message conversion...")
+
+	var request GeminiRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&request)
+		w.Write([]byte(`{"candidates":[{"content":{"role":"model","parts":[{"text":"Hello"},{"text":" again!"}]},"finishReason":"STOP"}]}`))
+	}))
+	defer server.Close()
+
+	provider := NewGeminiProvider("key", "", "", "").(*GeminiProvider)
+	provider.baseURL = server.URL
+	if name := provider.ProviderName(); name != "gemini" {
+		return fmt.Errorf("expected provider name gemini, got %s", name)
+	}
+
+	text, err := provider.ChatCompletion(context.Background(), []types.Message{
+		{Role: "system", Content: "Be brief."},
+		{Role: "user", Content: "Hi"},
+		{Role: "assistant", Content: "Hello"},
+		{Role: "user", Content: "Hi again"},
+	})
+	if err != nil || text != "Hello again!" {
+		return fmt.Errorf("expected %q, got %q (%v)", "Hello again!", text, err)
+	}
+	if request.SystemInstruction == nil || len(request.SystemInstruction.Parts) != 1 ||
+		request.SystemInstruction.Parts[0].Text != "Be brief." {
+		return fmt.Errorf("expected the system prompt as systemInstruction, got %+v", request.SystemInstruction)
+	}
+	want := []GeminiContent{
+		{Role: "user", Parts: []GeminiPart{{Text: "Hi"}}},
+		{Role: "model", Parts: []GeminiPart{{Text: "Hello"}}},
+		{Role: "user", Parts: []GeminiPart{{Text: "Hi again"}}},
+	}
+	if len(request.Contents) != len(want) {
+		return fmt.Errorf("expected %d contents, got %+v", len(want), request.Contents)
+	}
+	for i, content := range request.Contents {
+		if content.Role != want[i].Role || len(content.Parts) != 1 || content.Parts[0].Text != want[i].Parts[0].Text {
+			return fmt.Errorf("content %d: expected %+v, got %+v", i, want[i], content)
+		}
+	}
+	fmt.Println("✓ Roles mapped to user/model and system prompt sent as systemInstruction")
+	fmt.Println("✓ Text of all candidate parts extracted")
+
+	return nil
+}
+
+// TestGemini runs ChatCompletion against a fake generateContent endpoint
+func TestGemini() error {
+	fmt.Println("Testing Gemini provider...")
+
+	var request GeminiRequest
+	var path string
+	response := `{"candidates":[{"content":{"role":"model","parts":[{"text":"Hello!"}]},"finishReason":"STOP"}]}`
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path = r.URL.Path
+		json.NewDecoder(r.Body).Decode(&request)
+		w.Write([]byte(response))
+	}))
+	defer server.Close()
+
+	provider := NewGeminiProvider("key", "", "", "").(*GeminiProvider)
+	provider.baseURL = server.URL
+
+	text, err := provider.ChatCompletion(context.Background(), []types.Message{{Role: "user", Content: "Hi"}})
+	if err != nil || text != "Hello!" {
+		return fmt.Errorf("expected %q, got %q (%v)", "Hello!", text, err)
+	}
+	if path != "/models/"+defaultGeminiModel+":generateContent" {
+		return fmt.Errorf("unexpected request path %s", path)
+	}
+	if len(request.Contents) != 1 || request.Contents[0].Parts[0].Text != "Hi" {
+		return fmt.Errorf("unexpected request contents: %+v", request)
+	}
+	fmt.Println("✓ Request sent to the generateContent endpoint")
+
+	for _, blocked := range []struct {
+		body   string
+		reason string
+		prompt bool
+	}{
+		{`{"promptFeedback":{"blockReason":"SAFETY"}}`, "SAFETY", true},
+		{`{"candidates":[{"content":{"parts":[]},"finishReason":"SAFETY"}]}`, "SAFETY", false},
+	} {
+		response = blocked.body
+		_, err := provider.ChatCompletion(context.Background(), []types.Message{{Role: "user", Content: "Hi"}})
+		var blockedErr *GeminiBlockedError
+		if !errors.As(err, &blockedErr) || blockedErr.Reason != blocked.reason || blockedErr.Prompt != blocked.prompt {
+			return fmt.Errorf("expected GeminiBlockedError(%s, prompt=%v), got %v", blocked.reason, blocked.prompt, err)
+		}
+	}
+	fmt.Println("✓ Safety blocks returned as GeminiBlockedError")
+
+	return nil
+}
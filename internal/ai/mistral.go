@@ -0,0 +1,261 @@
+package ai
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/Chang-Augenweide/QuickBot-Go/pkg/types"
+)
+
+const (
+	// defaultMistralBaseURL is the Mistral API endpoint
+	defaultMistralBaseURL = "https://api.mistral.ai/v1"
+
+	// defaultMistralModel is used when no model is configured
+	defaultMistralModel = "mistral-large-latest"
+)
+
+// mistralModelFamilies are the Mistral chat models. A model name is a
+// family, optionally followed by "-latest" or a "-YYMM" version.
+var mistralModelFamilies = []string{
+	"mistral-large",
+	"mistral-medium",
+	"mistral-small",
+	"mistral-tiny",
+	"ministral-3b",
+	"ministral-8b",
+	"codestral",
+	"pixtral-12b",
+	"pixtral-large",
+	"open-mistral-7b",
+	"open-mistral-nemo",
+	"open-mixtral-8x7b",
+	"open-mixtral-8x22b",
+}
+
+// mistralVersionSuffix matches the version suffix of a model name
+var mistralVersionSuffix = regexp.MustCompile(`^(-latest|-\d{4})?$`)
+
+// ValidateMistralModel returns an error if model is not a Mistral chat
+// model
+func ValidateMistralModel(model string) error {
+	for _, family := range mistralModelFamilies {
+		if strings.HasPrefix(model, family) && mistralVersionSuffix.MatchString(model[len(family):]) {
+			return nil
+		}
+	}
+	return fmt.Errorf("unsupported Mistral model: %s", model)
+}
+
+// MistralRequest is an OpenAI chat completion request with Mistral's
+// safe_prompt option
+type MistralRequest struct {
+	OpenAIRequest
+	SafePrompt bool `json:"safe_prompt,omitempty"`
+}
+
+// MistralProvider represents Mistral AI API provider. The API is
+// OpenAI-compatible, so requests and responses use the OpenAI types.
+type MistralProvider struct {
+	apiKey      string
+	baseURL     string
+	model       string
+	maxTokens   int
+	temperature float64
+	safePrompt  bool
+	httpClient  *http.Client
+}
+
+// NewMistralProvider creates a new Mistral provider. An empty baseURL
+// uses the Mistral API and an empty model mistral-large-latest.
+func NewMistralProvider(apiKey, baseURL, model string) AIProvider {
+	if baseURL == "" {
+		baseURL = defaultMistralBaseURL
+	}
+	if model == "" {
+		model = defaultMistralModel
+	}
+
+	return &MistralProvider{
+		apiKey:      apiKey,
+		baseURL:     baseURL,
+		model:       model,
+		maxTokens:   2000,
+		temperature: 0.7,
+		httpClient: &http.Client{
+			Timeout: 30 * time.Second,
+		},
+	}
+}
+
+func (p *MistralProvider) ProviderName() string {
+	return "mistral"
+}
+
+// ChatCompletion sends a chat completion request to the Mistral API
+func (p *MistralProvider) ChatCompletion(ctx context.Context, messages []types.Message) (string, error) {
+	reqBody := MistralRequest{
+		OpenAIRequest: OpenAIRequest{
+			Model:       p.model,
+			Messages:    toOpenAIMessages(messages),
+			MaxTokens:   p.maxTokens,
+			Temperature: p.temperature,
+		},
+		SafePrompt: p.safePrompt,
+	}
+
+	// Apply per-session overrides to this request only
+	if overrides, ok := types.AIOverridesFromContext(ctx); ok {
+		if overrides.Model != "" {
+			reqBody.Model = overrides.Model
+		}
+		if overrides.MaxTokens > 0 {
+			reqBody.MaxTokens = overrides.MaxTokens
+		}
+		if overrides.Temperature != nil {
+			reqBody.Temperature = *overrides.Temperature
+		}
+	}
+
+	if err := ValidateMistralModel(reqBody.Model); err != nil {
+		return "", err
+	}
+
+	reqJSON, err := json.Marshal(reqBody)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	// Create HTTP request
+	url := fmt.Sprintf("%s/chat/completions", p.baseURL)
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(reqJSON))
+	if err != nil {
+		return "", fmt.Errorf("failed to create request: %w", err)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+p.apiKey)
+
+	// Send request
+	resp, err := p.httpClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", fmt.Errorf("failed to read response: %w", err)
+	}
+
+	// Check for errors
+	if resp.StatusCode != http.StatusOK {
+		message := string(respBody)
+		var errorResp OpenAIResponse
+		if err := json.Unmarshal(respBody, &errorResp); err == nil && errorResp.Error != nil {
+			message = errorResp.Error.Message
+		}
+		return "", newAPIError("Mistral", resp, message)
+	}
+
+	// Parse response
+	var response OpenAIResponse
+	if err := json.Unmarshal(respBody, &response); err != nil {
+		return "", fmt.Errorf("failed to unmarshal response: %w", err)
+	}
+	if response.Usage != nil {
+		reportUsage(ctx, Usage{
+			Provider:         "mistral",
+			Model:            reqBody.Model,
+			PromptTokens:     response.Usage.PromptTokens,
+			CompletionTokens: response.Usage.CompletionTokens,
+		})
+	}
+
+	if len(response.Choices) == 0 {
+		return "", fmt.Errorf("no choices in response")
+	}
+
+	return response.Choices[0].Message.Content, nil
+}
+
+// ToolCallCompletion sends a chat completion request without the tools:
+// function calling is not supported for Mistral yet
+func (p *MistralProvider) ToolCallCompletion(ctx context.Context, messages []types.Message, tools []types.ToolSpec) (types.Completion, error) {
+	return completionWithoutTools(ctx, p, messages)
+}
+
+// SetMaxTokens sets the maximum tokens for completion
+func (p *MistralProvider) SetMaxTokens(maxTokens int) {
+	p.maxTokens = maxTokens
+}
+
+// SetTemperature sets the temperature for completion
+func (p *MistralProvider) SetTemperature(temperature float64) {
+	p.temperature = temperature
+}
+
+// SetSafePrompt sets whether Mistral prepends its safety system prompt
+func (p *MistralProvider) SetSafePrompt(safePrompt bool) {
+	p.safePrompt = safePrompt
+}
+
+// TestMistral runs ChatCompletion against a fake Mistral endpoint
+func TestMistral() error {
+	fmt.Println("Testing Mistral provider...")
+
+	var authorization string
+	var request map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/chat/completions" {
+			http.NotFound(w, r)
+			return
+		}
+		authorization = r.Header.Get("Authorization")
+		json.NewDecoder(r.Body).Decode(&request)
+		w.Write([]byte(`{"id":"1","choices":[{"index":0,"message":{"role":"assistant","content":"Bonjour!"},"finish_reason":"stop"}]}`))
+	}))
+	defer server.Close()
+
+	provider := NewMistralProvider("secret-key", server.URL, "").(*MistralProvider)
+	provider.SetSafePrompt(true)
+
+	text, err := provider.ChatCompletion(context.Background(), []types.Message{{Role: "user", Content: "Hi"}})
+	if err != nil || text != "Bonjour!" {
+		return fmt.Errorf("expected %q, got %q (%v)", "Bonjour!", text, err)
+	}
+	if authorization != "Bearer secret-key" {
+		return fmt.Errorf("expected Authorization %q, got %q", "Bearer secret-key", authorization)
+	}
+	if request["model"] != defaultMistralModel || request["safe_prompt"] != true {
+		return fmt.Errorf("unexpected request: %v", request)
+	}
+	fmt.Println("✓ Authorization header and safe_prompt sent")
+
+	for model, valid := range map[string]bool{
+		"mistral-large-latest": true,
+		"mistral-small-2409":   true,
+		"open-mistral-nemo":    true,
+		"gpt-4o":               false,
+		"mistral-large-beta":   false,
+	} {
+		if err := ValidateMistralModel(model); (err == nil) != valid {
+			return fmt.Errorf("model %s: expected valid=%v, got %v", model, valid, err)
+		}
+	}
+	ctx := types.WithAIOverrides(context.Background(), types.AIOverrides{Model: "gpt-4o"})
+	if _, err := provider.ChatCompletion(ctx, nil); err == nil {
+		return fmt.Errorf("expected non-Mistral model to be rejected")
+	}
+	fmt.Println("✓ Model names validated")
+
+	return nil
+}
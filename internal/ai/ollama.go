@@ -1,12 +1,15 @@
 package ai
 
 import (
+	"bufio"
 	"bytes"
 	"context"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
+	"net/http/httptest"
+	"strings"
 	"time"
 
 	"quickbot/internal/types"
@@ -14,10 +17,10 @@ import (
 
 // OllamaRequest represents Ollama API request
 type OllamaRequest struct {
-	Model    string              `json:"model"`
-	Messages []OllamaMessage     `json:"messages"`
-	Stream   bool                `json:"stream"`
-	Options  OllamaOptions       `json:"options,omitempty"`
+	Model    string          `json:"model"`
+	Messages []OllamaMessage `json:"messages"`
+	Stream   bool            `json:"stream"`
+	Options  OllamaOptions   `json:"options,omitempty"`
 }
 
 type OllamaMessage struct {
@@ -26,19 +29,22 @@ type OllamaMessage struct {
 }
 
 type OllamaOptions struct {
-	NumPredict int     `json:"num_predict,omitempty"`
-	Temperature float64 `json:"temperature,omitempty"`
+	NumPredict  int     `json:"num_predict,omitempty"`
+	Temperature float64 `json:"temperature"`
 }
 
 // OllamaResponse represents Ollama API response
 type OllamaResponse struct {
-	Model     string          `json:"model"`
-	CreatedAt string         `json:"created_at"`
-	Message   types.Message  `json:"message"`
-	Done     bool           `json:"done"`
-	Error     string         `json:"error,omitempty"`
+	Model     string        `json:"model"`
+	CreatedAt string        `json:"created_at"`
+	Message   types.Message `json:"message"`
+	Done      bool          `json:"done"`
+	Error     string        `json:"error,omitempty"`
 }
 
+// maxOllamaStreamLine is the longest line read from a streamed response
+const maxOllamaStreamLine = 1024 * 1024
+
 // OllamaProvider represents Ollama API provider
 type OllamaProvider struct {
 	baseURL     string
@@ -55,9 +61,9 @@ func NewOllamaProvider(baseURL, model string) AIProvider {
 	}
 
 	return &OllamaProvider{
-		baseURL: baseURL,
-		model:   model,
-		numPredict: 2000,
+		baseURL:     baseURL,
+		model:       model,
+		numPredict:  2000,
 		temperature: 0.7,
 		httpClient: &http.Client{
 			Timeout: 60 * time.Second, // Ollama can be slow
@@ -71,6 +77,79 @@ func (p *OllamaProvider) ProviderName() string {
 
 // ChatCompletion sends a chat completion request to Ollama API
 func (p *OllamaProvider) ChatCompletion(ctx context.Context, messages []types.Message) (string, error) {
+	resp, err := p.send(ctx, messages, false)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", fmt.Errorf("failed to read response: %w", err)
+	}
+
+	// Parse response
+	var response OllamaResponse
+	if err := json.Unmarshal(respBody, &response); err != nil {
+		return "", fmt.Errorf("failed to unmarshal response: %w", err)
+	}
+
+	// Check for errors
+	if response.Error != "" {
+		return "", fmt.Errorf("Ollama API error: %s", response.Error)
+	}
+
+	return response.Message.Content, nil
+}
+
+// ChatCompletionStream sends a streaming chat completion request to
+// Ollama API and calls cb with each part of the response as it arrives.
+// Ollama streams one JSON object per line, the last one marked done.
+func (p *OllamaProvider) ChatCompletionStream(ctx context.Context, messages []types.Message, cb func(string)) error {
+	resp, err := p.send(ctx, messages, true)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 64*1024), maxOllamaStreamLine)
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+
+		var response OllamaResponse
+		if err := json.Unmarshal(line, &response); err != nil {
+			return fmt.Errorf("failed to unmarshal response: %w", err)
+		}
+		if response.Error != "" {
+			return fmt.Errorf("Ollama API error: %s", response.Error)
+		}
+
+		if response.Message.Content != "" {
+			cb(response.Message.Content)
+		}
+		if response.Done {
+			return nil
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("failed to read response: %w", err)
+	}
+	return fmt.Errorf("Ollama response stream ended before done")
+}
+
+// SupportsStreaming reports that ChatCompletionStream is supported
+func (p *OllamaProvider) SupportsStreaming() bool {
+	return true
+}
+
+// send sends a chat request to Ollama API and returns the response if
+// its status is OK
+func (p *OllamaProvider) send(ctx context.Context, messages []types.Message, stream bool) (*http.Response, error) {
 	// Convert messages to Ollama format
 	ollamaMessages := make([]OllamaMessage, len(messages))
 	for i, msg := range messages {
@@ -84,23 +163,36 @@ func (p *OllamaProvider) ChatCompletion(ctx context.Context, messages []types.Me
 	reqBody := OllamaRequest{
 		Model:    p.model,
 		Messages: ollamaMessages,
-		Stream:   false,
+		Stream:   stream,
 		Options: OllamaOptions{
-			NumPredict:   p.numPredict,
-			Temperature:  p.temperature,
+			NumPredict:  p.numPredict,
+			Temperature: p.temperature,
 		},
 	}
 
+	// Apply per-session overrides to this request only
+	if overrides, ok := types.AIOverridesFromContext(ctx); ok {
+		if overrides.Model != "" {
+			reqBody.Model = overrides.Model
+		}
+		if overrides.MaxTokens > 0 {
+			reqBody.Options.NumPredict = overrides.MaxTokens
+		}
+		if overrides.Temperature != nil {
+			reqBody.Options.Temperature = *overrides.Temperature
+		}
+	}
+
 	reqJSON, err := json.Marshal(reqBody)
 	if err != nil {
-		return "", fmt.Errorf("failed to marshal request: %w", err)
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
 	}
 
 	// Create HTTP request
 	url := fmt.Sprintf("%s/api/chat", p.baseURL)
 	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(reqJSON))
 	if err != nil {
-		return "", fmt.Errorf("failed to create request: %w", err)
+		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
 
 	req.Header.Set("Content-Type", "application/json")
@@ -108,27 +200,32 @@ func (p *OllamaProvider) ChatCompletion(ctx context.Context, messages []types.Me
 	// Send request
 	resp, err := p.httpClient.Do(req)
 	if err != nil {
-		return "", fmt.Errorf("failed to send request: %w", err)
+		return nil, fmt.Errorf("failed to send request: %w", err)
 	}
-	defer resp.Body.Close()
 
-	respBody, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return "", fmt.Errorf("failed to read response: %w", err)
-	}
+	// Check for errors
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
 
-	// Parse response
-	var response OllamaResponse
-	if err := json.Unmarshal(respBody, &response); err != nil {
-		return "", fmt.Errorf("failed to unmarshal response: %w", err)
+		respBody, err := io.ReadAll(resp.Body)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read response: %w", err)
+		}
+		message := string(respBody)
+		var errorResp OllamaResponse
+		if err := json.Unmarshal(respBody, &errorResp); err == nil && errorResp.Error != "" {
+			message = errorResp.Error
+		}
+		return nil, newAPIError("Ollama", resp, message)
 	}
 
-	// Check for errors
-	if response.Error != "" {
-		return "", fmt.Errorf("Ollama API error: %s", response.Error)
-	}
+	return resp, nil
+}
 
-	return response.Message.Content, nil
+// ToolCallCompletion sends a chat completion request without the tools:
+// function calling is not supported for Ollama yet
+func (p *OllamaProvider) ToolCallCompletion(ctx context.Context, messages []types.Message, tools []types.ToolSpec) (types.Completion, error) {
+	return completionWithoutTools(ctx, p, messages)
 }
 
 // SetNumPredict sets the maximum number of tokens to predict
@@ -140,3 +237,49 @@ func (p *OllamaProvider) SetNumPredict(numPredict int) {
 func (p *OllamaProvider) SetTemperature(temperature float64) {
 	p.temperature = temperature
 }
+
+// TestOllamaStreaming streams a response from a fake Ollama endpoint
+func TestOllamaStreaming() error {
+	fmt.Println("Testing Ollama streaming...")
+
+	var request OllamaRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&request)
+		for _, token := range []string{"Hel", "lo", "!"} {
+			fmt.Fprintf(w, `{"model":"llama3","message":{"role":"assistant","content":%q},"done":false}`+"\n", token)
+		}
+		fmt.Fprintln(w, `{"model":"llama3","message":{"role":"assistant","content":""},"done":true}`)
+	}))
+	defer server.Close()
+
+	provider := NewOllamaProvider(server.URL, "llama3").(*OllamaProvider)
+	if !provider.SupportsStreaming() {
+		return fmt.Errorf("expected Ollama to support streaming")
+	}
+
+	var tokens []string
+	err := provider.ChatCompletionStream(context.Background(), []types.Message{{Role: "user", Content: "Hi"}}, func(token string) {
+		tokens = append(tokens, token)
+	})
+	if err != nil {
+		return err
+	}
+	if !request.Stream {
+		return fmt.Errorf("expected stream to be requested")
+	}
+	if strings.Join(tokens, "|") != "Hel|lo|!" {
+		return fmt.Errorf("unexpected tokens: %q", tokens)
+	}
+	fmt.Println("✓ Response streamed line by line")
+
+	server.Config.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, `{"model":"llama3","message":{"role":"assistant","content":"Hel"},"done":false}`)
+		fmt.Fprintln(w, `{"error":"model crashed"}`)
+	})
+	if err := provider.ChatCompletionStream(context.Background(), nil, func(string) {}); err == nil || !strings.Contains(err.Error(), "model crashed") {
+		return fmt.Errorf("expected stream error, got %v", err)
+	}
+	fmt.Println("✓ Stream error reported")
+
+	return nil
+}
@@ -7,6 +7,8 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"net/http/httptest"
+	"strings"
 	"time"
 
 	"quickbot/internal/types"
@@ -14,26 +16,67 @@ import (
 
 // OpenAIRequest represents OpenAI API request
 type OpenAIRequest struct {
-	Model          string         `json:"model"`
-	Messages       []types.Message `json:"messages"`
-	MaxTokens      int           `json:"max_tokens,omitempty"`
-	Temperature    float64       `json:"temperature,omitempty"`
-	Stream         bool          `json:"stream,omitempty"`
+	Model       string          `json:"model"`
+	Messages    []OpenAIMessage `json:"messages"`
+	MaxTokens   int             `json:"max_tokens,omitempty"`
+	Temperature float64         `json:"temperature"`
+	Stream      bool            `json:"stream,omitempty"`
+	Tools       []OpenAITool    `json:"tools,omitempty"`
+}
+
+// OpenAIMessage represents a chat message in the OpenAI format
+type OpenAIMessage struct {
+	Role       string           `json:"role"`
+	Content    string           `json:"content"`
+	ToolCalls  []OpenAIToolCall `json:"tool_calls,omitempty"`
+	ToolCallID string           `json:"tool_call_id,omitempty"`
+}
+
+// OpenAITool represents a function offered to the model
+type OpenAITool struct {
+	Type     string         `json:"type"`
+	Function OpenAIFunction `json:"function"`
+}
+
+type OpenAIFunction struct {
+	Name        string                 `json:"name"`
+	Description string                 `json:"description,omitempty"`
+	Parameters  map[string]interface{} `json:"parameters"`
+}
+
+// OpenAIToolCall represents a function call requested by the model
+type OpenAIToolCall struct {
+	ID       string             `json:"id"`
+	Type     string             `json:"type"`
+	Function OpenAIFunctionCall `json:"function"`
+}
+
+type OpenAIFunctionCall struct {
+	Name      string `json:"name"`
+	Arguments string `json:"arguments"`
 }
 
 // OpenAIResponse represents OpenAI API response
 type OpenAIResponse struct {
-	ID      string               `json:"id"`
-	Object  string               `json:"object"`
-	Created int64                `json:"created"`
-	Choices []OpenAIChoice       `json:"choices"`
-	Error   *OpenAIError         `json:"error,omitempty"`
+	ID      string         `json:"id"`
+	Object  string         `json:"object"`
+	Created int64          `json:"created"`
+	Choices []OpenAIChoice `json:"choices"`
+	Usage   *OpenAIUsage   `json:"usage,omitempty"`
+	Error   *OpenAIError   `json:"error,omitempty"`
+}
+
+// OpenAIUsage represents the token usage of a request
+type OpenAIUsage struct {
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+	TotalTokens      int `json:"total_tokens"`
 }
 
 type OpenAIChoice struct {
-	Index        int            `json:"index"`
-	Message      types.Message  `json:"message"`
-	FinishReason string         `json:"finish_reason"`
+	Index        int           `json:"index"`
+	Message      OpenAIMessage `json:"message"`
+	FinishReason string        `json:"finish_reason"`
 }
 
 type OpenAIError struct {
@@ -42,6 +85,42 @@ type OpenAIError struct {
 	Code    string `json:"code"`
 }
 
+// toOpenAIMessages converts messages to the OpenAI format
+func toOpenAIMessages(messages []types.Message) []OpenAIMessage {
+	openAIMessages := make([]OpenAIMessage, len(messages))
+	for i, msg := range messages {
+		openAIMessages[i] = OpenAIMessage{
+			Role:       msg.Role,
+			Content:    msg.Content,
+			ToolCallID: msg.ToolCallID,
+		}
+		for _, call := range msg.ToolCalls {
+			openAIMessages[i].ToolCalls = append(openAIMessages[i].ToolCalls, OpenAIToolCall{
+				ID:       call.ID,
+				Type:     "function",
+				Function: OpenAIFunctionCall{Name: call.Name, Arguments: call.Arguments},
+			})
+		}
+	}
+	return openAIMessages
+}
+
+// toOpenAITools converts tool specs to OpenAI functions
+func toOpenAITools(tools []types.ToolSpec) []OpenAITool {
+	var openAITools []OpenAITool
+	for _, tool := range tools {
+		openAITools = append(openAITools, OpenAITool{
+			Type: "function",
+			Function: OpenAIFunction{
+				Name:        tool.Name,
+				Description: tool.Description,
+				Parameters:  tool.Parameters,
+			},
+		})
+	}
+	return openAITools
+}
+
 // OpenAIProvider represents OpenAI API provider
 type OpenAIProvider struct {
 	apiKey      string
@@ -76,34 +155,71 @@ func (p *OpenAIProvider) ProviderName() string {
 
 // ChatCompletion sends a chat completion request to OpenAI API
 func (p *OpenAIProvider) ChatCompletion(ctx context.Context, messages []types.Message) (string, error) {
-	// Convert to OpenAI format
-	openAIMessages := make([]types.Message, len(messages))
-	for i, msg := range messages {
-		openAIMessages[i] = types.Message{
-			Role:    msg.Role,
-			Content: msg.Content,
-		}
+	choice, err := p.complete(ctx, messages, nil)
+	if err != nil {
+		return "", err
 	}
+	return choice.Message.Content, nil
+}
 
+// ToolCallCompletion sends a chat completion request offering tools as
+// functions. The model may reply with tool calls, with finish reason
+// "tool_calls".
+func (p *OpenAIProvider) ToolCallCompletion(ctx context.Context, messages []types.Message, tools []types.ToolSpec) (types.Completion, error) {
+	choice, err := p.complete(ctx, messages, tools)
+	if err != nil {
+		return types.Completion{}, err
+	}
+
+	completion := types.Completion{
+		Content:      choice.Message.Content,
+		FinishReason: choice.FinishReason,
+	}
+	for _, call := range choice.Message.ToolCalls {
+		completion.ToolCalls = append(completion.ToolCalls, types.ToolCall{
+			ID:        call.ID,
+			Name:      call.Function.Name,
+			Arguments: call.Function.Arguments,
+		})
+	}
+	return completion, nil
+}
+
+// complete sends a chat completion request and returns the first choice
+func (p *OpenAIProvider) complete(ctx context.Context, messages []types.Message, tools []types.ToolSpec) (*OpenAIChoice, error) {
 	// Prepare request
 	reqBody := OpenAIRequest{
 		Model:       p.model,
-		Messages:    openAIMessages,
+		Messages:    toOpenAIMessages(messages),
 		MaxTokens:   p.maxTokens,
 		Temperature: p.temperature,
 		Stream:      false,
+		Tools:       toOpenAITools(tools),
+	}
+
+	// Apply per-session overrides to this request only
+	if overrides, ok := types.AIOverridesFromContext(ctx); ok {
+		if overrides.Model != "" {
+			reqBody.Model = overrides.Model
+		}
+		if overrides.MaxTokens > 0 {
+			reqBody.MaxTokens = overrides.MaxTokens
+		}
+		if overrides.Temperature != nil {
+			reqBody.Temperature = *overrides.Temperature
+		}
 	}
 
 	reqJSON, err := json.Marshal(reqBody)
 	if err != nil {
-		return "", fmt.Errorf("failed to marshal request: %w", err)
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
 	}
 
 	// Create HTTP request
 	url := fmt.Sprintf("%s/chat/completions", p.baseURL)
 	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(reqJSON))
 	if err != nil {
-		return "", fmt.Errorf("failed to create request: %w", err)
+		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
 
 	req.Header.Set("Content-Type", "application/json")
@@ -112,35 +228,57 @@ func (p *OpenAIProvider) ChatCompletion(ctx context.Context, messages []types.Me
 	// Send request
 	resp, err := p.httpClient.Do(req)
 	if err != nil {
-		return "", fmt.Errorf("failed to send request: %w", err)
+		return nil, fmt.Errorf("failed to send request: %w", err)
 	}
 	defer resp.Body.Close()
 
 	respBody, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return "", fmt.Errorf("failed to read response: %w", err)
+		return nil, fmt.Errorf("failed to read response: %w", err)
 	}
 
 	// Check for errors
 	if resp.StatusCode != http.StatusOK {
+		message := string(respBody)
 		var errorResp OpenAIResponse
 		if err := json.Unmarshal(respBody, &errorResp); err == nil && errorResp.Error != nil {
-			return "", fmt.Errorf("OpenAI API error: %s", errorResp.Error.Message)
+			message = errorResp.Error.Message
 		}
-		return "", fmt.Errorf("API request failed with status %d: %s", resp.StatusCode, string(respBody))
+		return nil, newAPIError("OpenAI", resp, message)
 	}
 
 	// Parse response
 	var response OpenAIResponse
 	if err := json.Unmarshal(respBody, &response); err != nil {
-		return "", fmt.Errorf("failed to unmarshal response: %w", err)
+		return nil, fmt.Errorf("failed to unmarshal response: %w", err)
+	}
+	if response.Usage != nil {
+		reportUsage(ctx, Usage{
+			Provider:         "openai",
+			Model:            reqBody.Model,
+			PromptTokens:     response.Usage.PromptTokens,
+			CompletionTokens: response.Usage.CompletionTokens,
+		})
 	}
 
 	if len(response.Choices) == 0 {
-		return "", fmt.Errorf("no choices in response")
+		return nil, fmt.Errorf("no choices in response")
 	}
 
-	return response.Choices[0].Message.Content, nil
+	return &response.Choices[0], nil
+}
+
+// GetEmbedding returns the text-embedding-3-small embedding of text,
+// from the embeddings endpoint of the provider's API. It makes
+// OpenAIProvider an EmbeddingProvider.
+func (p *OpenAIProvider) GetEmbedding(ctx context.Context, text string) ([]float64, error) {
+	embeddings := &OpenAIEmbeddingProvider{
+		apiKey:     p.apiKey,
+		baseURL:    strings.TrimSuffix(p.baseURL, "/"),
+		model:      DefaultEmbeddingModel,
+		httpClient: p.httpClient,
+	}
+	return embeddings.GetEmbedding(ctx, text)
 }
 
 // SetMaxTokens sets the maximum tokens for completion
@@ -152,3 +290,109 @@ func (p *OpenAIProvider) SetMaxTokens(maxTokens int) {
 func (p *OpenAIProvider) SetTemperature(temperature float64) {
 	p.temperature = temperature
 }
+
+// TestOpenAIToolCalls runs ToolCallCompletion against a fake OpenAI
+// endpoint that requests a tool call, then sends the tool result back
+func TestOpenAIToolCalls() error {
+	fmt.Println("Testing OpenAI tool calls...")
+
+	var requests []OpenAIRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var request OpenAIRequest
+		json.NewDecoder(r.Body).Decode(&request)
+		requests = append(requests, request)
+
+		if len(requests) == 1 {
+			w.Write([]byte(`{"id":"1","choices":[{"index":0,"message":{"role":"assistant","content":null,"tool_calls":[{"id":"call_1","type":"function","function":{"name":"calculator","arguments":"{\"expression\":\"2+3\"}"}}]},"finish_reason":"tool_calls"}]}`))
+			return
+		}
+		w.Write([]byte(`{"id":"2","choices":[{"index":0,"message":{"role":"assistant","content":"2+3 is 5"},"finish_reason":"stop"}]}`))
+	}))
+	defer server.Close()
+
+	provider := NewOpenAIProvider("key", server.URL, "gpt-4o")
+	tools := []types.ToolSpec{{
+		Name:        "calculator",
+		Description: "Perform mathematical calculations",
+		Parameters:  map[string]interface{}{"type": "object"},
+	}}
+	messages := []types.Message{{Role: "user", Content: "What is 2+3?"}}
+
+	completion, err := provider.ToolCallCompletion(context.Background(), messages, tools)
+	if err != nil {
+		return err
+	}
+	if len(requests[0].Tools) != 1 || requests[0].Tools[0].Type != "function" || requests[0].Tools[0].Function.Name != "calculator" {
+		return fmt.Errorf("unexpected tools in request: %+v", requests[0].Tools)
+	}
+	if completion.FinishReason != "tool_calls" || len(completion.ToolCalls) != 1 {
+		return fmt.Errorf("expected a tool call, got %+v", completion)
+	}
+	call := completion.ToolCalls[0]
+	if call.ID != "call_1" || call.Name != "calculator" || call.Arguments != `{"expression":"2+3"}` {
+		return fmt.Errorf("unexpected tool call: %+v", call)
+	}
+	fmt.Println("✓ Tools sent and tool call decoded")
+
+	messages = append(messages,
+		types.Message{Role: "assistant", ToolCalls: completion.ToolCalls},
+		types.Message{Role: "tool", Content: "5", ToolCallID: call.ID},
+	)
+	completion, err = provider.ToolCallCompletion(context.Background(), messages, tools)
+	if err != nil || completion.Content != "2+3 is 5" {
+		return fmt.Errorf("expected %q, got %+v (%v)", "2+3 is 5", completion, err)
+	}
+	sent := requests[1].Messages
+	if len(sent) != 3 || len(sent[1].ToolCalls) != 1 || sent[1].ToolCalls[0].ID != "call_1" || sent[2].ToolCallID != "call_1" {
+		return fmt.Errorf("unexpected follow-up messages: %+v", sent)
+	}
+	fmt.Println("✓ Tool result sent in follow-up request")
+
+	return nil
+}
+
+// TestOpenAIOverrides sends requests with and without per-session
+// overrides and checks the max_tokens and temperature in the request JSON
+func TestOpenAIOverrides() error {
+	fmt.Println("Testing OpenAI AI overrides...")
+
+	var requests []map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var request map[string]interface{}
+		json.NewDecoder(r.Body).Decode(&request)
+		requests = append(requests, request)
+		w.Write([]byte(`{"id":"1","choices":[{"index":0,"message":{"role":"assistant","content":"ok"},"finish_reason":"stop"}]}`))
+	}))
+	defer server.Close()
+
+	provider := NewOpenAIProvider("key", server.URL, "gpt-4o")
+	messages := []types.Message{{Role: "user", Content: "Hello"}}
+
+	temperature := 1.5
+	ctx := types.WithAIOverrides(context.Background(), types.AIOverrides{MaxTokens: 50, Temperature: &temperature})
+	if _, err := provider.ChatCompletion(ctx, messages); err != nil {
+		return err
+	}
+	if requests[0]["max_tokens"] != float64(50) || requests[0]["temperature"] != 1.5 || requests[0]["model"] != "gpt-4o" {
+		return fmt.Errorf("expected max_tokens 50 and temperature 1.5 in the request, got %v and %v",
+			requests[0]["max_tokens"], requests[0]["temperature"])
+	}
+	fmt.Println("✓ Overridden max_tokens and temperature sent")
+
+	ctx = types.WithAIOverrides(context.Background(), types.AIOverrides{MaxTokens: 100})
+	if _, err := provider.ChatCompletion(ctx, messages); err != nil {
+		return err
+	}
+	if _, err := provider.ChatCompletion(context.Background(), messages); err != nil {
+		return err
+	}
+	if requests[1]["max_tokens"] != float64(100) || requests[1]["temperature"] != 0.7 {
+		return fmt.Errorf("expected only max_tokens overridden, got %v and %v", requests[1]["max_tokens"], requests[1]["temperature"])
+	}
+	if requests[2]["max_tokens"] != float64(2000) || requests[2]["temperature"] != 0.7 {
+		return fmt.Errorf("expected the provider defaults without overrides, got %v and %v", requests[2]["max_tokens"], requests[2]["temperature"])
+	}
+	fmt.Println("✓ Overrides apply to their request only")
+
+	return nil
+}
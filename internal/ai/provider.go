@@ -0,0 +1,34 @@
+package ai
+
+import (
+	"context"
+
+	"github.com/Chang-Augenweide/QuickBot-Go/pkg/types"
+)
+
+// AIProvider represents AI provider interface. ToolCallCompletion offers
+// tools to the model, which may reply with tool calls instead of text.
+type AIProvider interface {
+	ProviderName() string
+	ChatCompletion(ctx context.Context, messages []types.Message) (string, error)
+	ToolCallCompletion(ctx context.Context, messages []types.Message, tools []types.ToolSpec) (types.Completion, error)
+}
+
+// completionWithoutTools answers a ToolCallCompletion with a plain chat
+// completion, for providers that do not support function calling
+func completionWithoutTools(ctx context.Context, provider AIProvider, messages []types.Message) (types.Completion, error) {
+	content, err := provider.ChatCompletion(ctx, messages)
+	if err != nil {
+		return types.Completion{}, err
+	}
+	return types.Completion{Content: content, FinishReason: "stop"}, nil
+}
+
+// StreamingProvider is an AIProvider that can send its response as it is
+// generated. ChatCompletionStream calls cb with each part of the response
+// and returns once it is complete. Streamed requests do not offer tools.
+type StreamingProvider interface {
+	AIProvider
+	ChatCompletionStream(ctx context.Context, messages []types.Message, cb func(string)) error
+	SupportsStreaming() bool
+}
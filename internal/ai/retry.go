@@ -0,0 +1,237 @@
+package ai
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/Chang-Augenweide/QuickBot-Go/pkg/types"
+)
+
+// RetryConfig represents retry configuration
+type RetryConfig struct {
+	MaxAttempts int
+	MaxBackoff  time.Duration
+	Budget      RetryBudget
+}
+
+// RetryProvider wraps an AIProvider and retries rate-limited (429) and
+// server error (5xx) responses with exponential backoff from 500ms,
+// waiting as long as Retry-After asks when the response has one
+type RetryProvider struct {
+	provider    AIProvider
+	maxAttempts int
+	baseBackoff time.Duration
+	maxBackoff  time.Duration
+
+	// budget limits the retries of each session, tracked in retries by
+	// session ID
+	budget  RetryBudget
+	retries sync.Map
+}
+
+// NewRetryProvider creates a new retrying provider
+func NewRetryProvider(provider AIProvider, config RetryConfig) *RetryProvider {
+	if config.MaxAttempts <= 0 {
+		config.MaxAttempts = 3
+	}
+	if config.MaxBackoff <= 0 {
+		config.MaxBackoff = 30 * time.Second
+	}
+
+	return &RetryProvider{
+		provider:    provider,
+		maxAttempts: config.MaxAttempts,
+		baseBackoff: 500 * time.Millisecond,
+		maxBackoff:  config.MaxBackoff,
+		budget:      config.Budget,
+	}
+}
+
+func (p *RetryProvider) ProviderName() string {
+	return p.provider.ProviderName()
+}
+
+// ChatCompletion calls the wrapped provider, retrying transient failures
+// while the session of ctx has retry budget left
+func (p *RetryProvider) ChatCompletion(ctx context.Context, messages []types.Message) (string, error) {
+	var response string
+	err := p.do(ctx, func() error {
+		var err error
+		response, err = p.provider.ChatCompletion(ctx, messages)
+		return err
+	})
+	if err != nil {
+		return "", err
+	}
+	return response, nil
+}
+
+// ToolCallCompletion calls the wrapped provider, retrying like
+// ChatCompletion
+func (p *RetryProvider) ToolCallCompletion(ctx context.Context, messages []types.Message, tools []types.ToolSpec) (types.Completion, error) {
+	var completion types.Completion
+	err := p.do(ctx, func() error {
+		var err error
+		completion, err = p.provider.ToolCallCompletion(ctx, messages, tools)
+		return err
+	})
+	if err != nil {
+		return types.Completion{}, err
+	}
+	return completion, nil
+}
+
+// do calls request until it succeeds, fails with a non-retryable error or
+// runs out of attempts or retry budget
+func (p *RetryProvider) do(ctx context.Context, request func() error) error {
+	var lastErr error
+
+	for attempt := 1; attempt <= p.maxAttempts; attempt++ {
+		err := request()
+		if err == nil {
+			return nil
+		}
+		if errors.Is(err, context.Canceled) {
+			return err
+		}
+		lastErr = err
+
+		wait, retry := p.retryDelay(attempt, err)
+		if !retry || attempt == p.maxAttempts {
+			break
+		}
+		if budgetErr := p.takeRetry(ctx); budgetErr != nil {
+			return fmt.Errorf("%w; last error: %v", budgetErr, err)
+		}
+
+		log.Printf("Warning: %s request failed (attempt %d/%d), retrying in %v: %v",
+			p.provider.ProviderName(), attempt, p.maxAttempts, wait, err)
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(wait):
+		}
+	}
+
+	return lastErr
+}
+
+// retryDelay returns how long to wait before retrying err, and whether
+// err is retryable at all
+func (p *RetryProvider) retryDelay(attempt int, err error) (time.Duration, bool) {
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || !apiErr.Retryable() {
+		return 0, false
+	}
+
+	if apiErr.RetryAfter > 0 {
+		if apiErr.RetryAfter > p.maxBackoff {
+			return p.maxBackoff, true
+		}
+		return apiErr.RetryAfter, true
+	}
+
+	return BackoffDelay(attempt, p.baseBackoff, p.maxBackoff), true
+}
+
+// BackoffDelay returns the exponential backoff delay for an attempt
+// (1-based): base doubled per attempt, capped at max, with ±10% jitter
+func BackoffDelay(attempt int, base, max time.Duration) time.Duration {
+	delay := base
+	for i := 1; i < attempt && delay < max; i++ {
+		delay *= 2
+	}
+	if delay > max {
+		delay = max
+	}
+
+	jitter := time.Duration((rand.Float64()*0.2 - 0.1) * float64(delay))
+	return delay + jitter
+}
+
+// flakyProvider fails with each of its errors in turn, then succeeds
+type flakyProvider struct {
+	errors []error
+	calls  int
+}
+
+func (p *flakyProvider) ProviderName() string {
+	return "flaky"
+}
+
+func (p *flakyProvider) ChatCompletion(ctx context.Context, messages []types.Message) (string, error) {
+	p.calls++
+	if p.calls <= len(p.errors) {
+		return "", p.errors[p.calls-1]
+	}
+	return "success", nil
+}
+
+func (p *flakyProvider) ToolCallCompletion(ctx context.Context, messages []types.Message, tools []types.ToolSpec) (types.Completion, error) {
+	return completionWithoutTools(ctx, p, messages)
+}
+
+// TestRetryProvider checks that transient failures are retried until the
+// provider succeeds and that client errors are not retried
+func TestRetryProvider() error {
+	fmt.Println("Testing Retry Provider...")
+
+	provider := &flakyProvider{errors: []error{
+		&APIError{Provider: "flaky", StatusCode: 503, Message: "unavailable"},
+		&APIError{Provider: "flaky", StatusCode: 429, Message: "rate limited", RetryAfter: time.Millisecond},
+	}}
+	retry := NewRetryProvider(provider, RetryConfig{})
+	retry.baseBackoff = time.Millisecond
+
+	response, err := retry.ChatCompletion(context.Background(), nil)
+	if err != nil || response != "success" {
+		return fmt.Errorf("expected the success response, got %q (%v)", response, err)
+	}
+	if provider.calls != 3 {
+		return fmt.Errorf("expected 3 calls, got %d", provider.calls)
+	}
+	fmt.Println("✓ Two failures retried, success response returned")
+
+	// Retries stop after MaxAttempts
+	provider = &flakyProvider{errors: []error{
+		&APIError{Provider: "flaky", StatusCode: 500, Message: "error"},
+		&APIError{Provider: "flaky", StatusCode: 502, Message: "bad gateway"},
+		&APIError{Provider: "flaky", StatusCode: 503, Message: "unavailable"},
+	}}
+	retry = NewRetryProvider(provider, RetryConfig{MaxAttempts: 2})
+	retry.baseBackoff = time.Millisecond
+	var apiErr *APIError
+	if _, err := retry.ChatCompletion(context.Background(), nil); !errors.As(err, &apiErr) || apiErr.StatusCode != 502 || provider.calls != 2 {
+		return fmt.Errorf("expected the 502 error after 2 calls, got %v after %d calls", err, provider.calls)
+	}
+
+	// Client errors are returned without retrying
+	provider = &flakyProvider{errors: []error{&APIError{Provider: "flaky", StatusCode: 400, Message: "bad request"}}}
+	retry = NewRetryProvider(provider, RetryConfig{})
+	if _, err := retry.ChatCompletion(context.Background(), nil); err == nil || provider.calls != 1 {
+		return fmt.Errorf("expected the 400 error without a retry, got %v after %d calls", err, provider.calls)
+	}
+	fmt.Println("✓ Retries limited to MaxAttempts and retryable errors")
+
+	// Retry-After is capped at MaxBackoff
+	retry = NewRetryProvider(provider, RetryConfig{MaxBackoff: time.Second})
+	wait, ok := retry.retryDelay(1, &APIError{StatusCode: 429, RetryAfter: time.Minute})
+	if !ok || wait != time.Second {
+		return fmt.Errorf("expected Retry-After capped at 1s, got %v", wait)
+	}
+	for attempt := 1; attempt <= 10; attempt++ {
+		delay := BackoffDelay(attempt, time.Second, 30*time.Second)
+		if delay < 900*time.Millisecond || delay > 33*time.Second {
+			return fmt.Errorf("backoff delay of attempt %d out of range: %v", attempt, delay)
+		}
+	}
+	fmt.Println("✓ Backoff capped")
+
+	return nil
+}
@@ -0,0 +1,159 @@
+package ai
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/Chang-Augenweide/QuickBot-Go/pkg/types"
+)
+
+// retryWindow is the sliding window of the per-minute retry budget
+const retryWindow = time.Minute
+
+// ErrRetryBudgetExhausted is returned instead of retrying once a session
+// has used up its retry budget
+var ErrRetryBudgetExhausted = errors.New("retry budget exhausted")
+
+// RetryBudget limits the retries made for each session. Zero fields are
+// unlimited.
+type RetryBudget struct {
+	MaxRetriesPerMinute       int
+	MaxTotalRetriesPerSession int
+}
+
+// RetryBudgetUsage is the retry budget used by a session
+type RetryBudgetUsage struct {
+	SessionID         string `json:"session_id"`
+	RetriesThisMinute int    `json:"retries_this_minute"`
+	TotalRetries      int    `json:"total_retries"`
+	Budget            int    `json:"budget"`
+}
+
+// sessionRetries records the retries of one session
+type sessionRetries struct {
+	mu     sync.Mutex
+	recent []time.Time
+	total  int
+}
+
+// prune drops retries older than the sliding window
+func (r *sessionRetries) prune(now time.Time) {
+	cutoff := now.Add(-retryWindow)
+	i := 0
+	for i < len(r.recent) && !r.recent[i].After(cutoff) {
+		i++
+	}
+	r.recent = r.recent[i:]
+}
+
+// takeRetry records a retry for the session of ctx, or returns
+// ErrRetryBudgetExhausted if the session's budget is used up. Requests
+// without a session share one budget.
+func (p *RetryProvider) takeRetry(ctx context.Context) error {
+	if p.budget.MaxRetriesPerMinute <= 0 && p.budget.MaxTotalRetriesPerSession <= 0 {
+		return nil
+	}
+
+	sessionID := types.SessionIDFromContext(ctx)
+	value, _ := p.retries.LoadOrStore(sessionID, &sessionRetries{})
+	retries := value.(*sessionRetries)
+
+	retries.mu.Lock()
+	defer retries.mu.Unlock()
+
+	now := time.Now()
+	retries.prune(now)
+	if p.budget.MaxRetriesPerMinute > 0 && len(retries.recent) >= p.budget.MaxRetriesPerMinute {
+		return fmt.Errorf("%w: %d retries in the last minute", ErrRetryBudgetExhausted, len(retries.recent))
+	}
+	if p.budget.MaxTotalRetriesPerSession > 0 && retries.total >= p.budget.MaxTotalRetriesPerSession {
+		return fmt.Errorf("%w: %d retries in this session", ErrRetryBudgetExhausted, retries.total)
+	}
+
+	retries.recent = append(retries.recent, now)
+	retries.total++
+	return nil
+}
+
+// RetryBudgetUsage returns the retries made by each session that has
+// retried, sorted by session ID
+func (p *RetryProvider) RetryBudgetUsage() []RetryBudgetUsage {
+	usage := []RetryBudgetUsage{}
+	now := time.Now()
+
+	p.retries.Range(func(key, value interface{}) bool {
+		retries := value.(*sessionRetries)
+		retries.mu.Lock()
+		retries.prune(now)
+		usage = append(usage, RetryBudgetUsage{
+			SessionID:         key.(string),
+			RetriesThisMinute: len(retries.recent),
+			TotalRetries:      retries.total,
+			Budget:            p.budget.MaxRetriesPerMinute,
+		})
+		retries.mu.Unlock()
+		return true
+	})
+
+	sort.Slice(usage, func(i, j int) bool { return usage[i].SessionID < usage[j].SessionID })
+	return usage
+}
+
+// budgetTestProvider always fails with a retryable error and counts calls
+type budgetTestProvider struct {
+	calls int
+}
+
+func (p *budgetTestProvider) ProviderName() string {
+	return "test"
+}
+
+func (p *budgetTestProvider) ChatCompletion(ctx context.Context, messages []types.Message) (string, error) {
+	p.calls++
+	return "", &APIError{Provider: "test", StatusCode: 429, Message: "rate limited", RetryAfter: time.Millisecond}
+}
+
+func (p *budgetTestProvider) ToolCallCompletion(ctx context.Context, messages []types.Message, tools []types.ToolSpec) (types.Completion, error) {
+	return completionWithoutTools(ctx, p, messages)
+}
+
+// TestRetryBudget exhausts the per-minute retry budget of a session and
+// checks that the provider is not called again
+func TestRetryBudget() error {
+	fmt.Println("Testing Retry Budget...")
+
+	provider := &budgetTestProvider{}
+	retry := NewRetryProvider(provider, RetryConfig{
+		MaxAttempts: 10,
+		Budget:      RetryBudget{MaxRetriesPerMinute: 5},
+	})
+
+	ctx := types.WithSessionID(context.Background(), "s1")
+	_, err := retry.ChatCompletion(ctx, nil)
+	if !errors.Is(err, ErrRetryBudgetExhausted) {
+		return fmt.Errorf("expected ErrRetryBudgetExhausted, got %v", err)
+	}
+	// The first attempt and 5 retries call the AI; the 6th retry does not
+	if provider.calls != 6 {
+		return fmt.Errorf("expected 6 AI calls, got %d", provider.calls)
+	}
+	fmt.Println("✓ 6th retry rejected without calling the AI")
+
+	usage := retry.RetryBudgetUsage()
+	if len(usage) != 1 || usage[0].SessionID != "s1" || usage[0].RetriesThisMinute != 5 || usage[0].Budget != 5 {
+		return fmt.Errorf("unexpected retry budget usage: %+v", usage)
+	}
+
+	// Other sessions have their own budget
+	_, err = retry.ChatCompletion(types.WithSessionID(context.Background(), "s2"), nil)
+	if !errors.Is(err, ErrRetryBudgetExhausted) || provider.calls != 12 {
+		return fmt.Errorf("session s2 did not get its own budget: %v, %d calls", err, provider.calls)
+	}
+	fmt.Println("✓ Retry budget tracked per session")
+
+	return nil
+}
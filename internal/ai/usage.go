@@ -0,0 +1,260 @@
+package ai
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Chang-Augenweide/QuickBot-Go/pkg/types"
+)
+
+// Usage is the token usage of one request, as reported by the provider
+type Usage struct {
+	Provider         string
+	Model            string
+	PromptTokens     int
+	CompletionTokens int
+}
+
+type usageRecorderKey struct{}
+
+// withUsageRecorder returns a context whose requests report their token
+// usage to record
+func withUsageRecorder(ctx context.Context, record func(Usage)) context.Context {
+	return context.WithValue(ctx, usageRecorderKey{}, record)
+}
+
+// reportUsage reports the token usage of a request to the recorder of
+// ctx, if it has one
+func reportUsage(ctx context.Context, usage Usage) {
+	if record, ok := ctx.Value(usageRecorderKey{}).(func(Usage)); ok {
+		record(usage)
+	}
+}
+
+// modelPrices are the prices in USD per million prompt and completion
+// tokens, matched by model name prefix in order
+var modelPrices = []struct {
+	prefix     string
+	prompt     float64
+	completion float64
+}{
+	{"gpt-4o-mini", 0.15, 0.60},
+	{"gpt-4o", 2.50, 10.00},
+	{"gpt-4-turbo", 10.00, 30.00},
+	{"gpt-3.5-turbo", 0.50, 1.50},
+	{"claude-3-5-haiku", 0.80, 4.00},
+	{"claude-3-5-sonnet", 3.00, 15.00},
+	{"claude-3-opus", 15.00, 75.00},
+	{"claude-3-haiku", 0.25, 1.25},
+	{"gemini-1.5-flash", 0.075, 0.30},
+	{"gemini-1.5-pro", 1.25, 5.00},
+	{"mistral-large", 2.00, 6.00},
+	{"mistral-small", 0.20, 0.60},
+	{"codestral", 0.20, 0.60},
+}
+
+// EstimateCost returns the estimated cost in USD of a request to model,
+// or 0 if the model has no known price
+func EstimateCost(model string, promptTokens, completionTokens int) float64 {
+	for _, price := range modelPrices {
+		if strings.HasPrefix(model, price.prefix) {
+			return (float64(promptTokens)*price.prompt + float64(completionTokens)*price.completion) / 1e6
+		}
+	}
+	return 0
+}
+
+// ProviderUsage is the cumulative token usage of a provider
+type ProviderUsage struct {
+	Requests         int     `json:"requests"`
+	PromptTokens     int     `json:"prompt_tokens"`
+	CompletionTokens int     `json:"completion_tokens"`
+	TotalTokens      int     `json:"total_tokens"`
+	EstimatedCostUSD float64 `json:"estimated_cost_usd"`
+}
+
+// UsageStats is the token usage since Since, in total and by provider
+type UsageStats struct {
+	PromptTokens     int                      `json:"prompt_tokens"`
+	CompletionTokens int                      `json:"completion_tokens"`
+	TotalTokens      int                      `json:"total_tokens"`
+	EstimatedCostUSD float64                  `json:"estimated_cost_usd"`
+	Providers        map[string]ProviderUsage `json:"providers"`
+	Since            time.Time                `json:"since"`
+}
+
+// UsageTracker wraps an AIProvider and counts the tokens used by its
+// requests, as reported in the OpenAI, Mistral and Anthropic responses
+type UsageTracker struct {
+	provider AIProvider
+
+	mu        sync.Mutex
+	providers map[string]ProviderUsage
+	since     time.Time
+
+	stopLogging chan struct{}
+}
+
+// NewUsageTracker creates a new usage tracker
+func NewUsageTracker(provider AIProvider) *UsageTracker {
+	return &UsageTracker{
+		provider:  provider,
+		providers: make(map[string]ProviderUsage),
+		since:     time.Now(),
+	}
+}
+
+func (t *UsageTracker) ProviderName() string {
+	return t.provider.ProviderName()
+}
+
+// ChatCompletion calls the wrapped provider, recording its usage
+func (t *UsageTracker) ChatCompletion(ctx context.Context, messages []types.Message) (string, error) {
+	return t.provider.ChatCompletion(withUsageRecorder(ctx, t.record), messages)
+}
+
+// ToolCallCompletion calls the wrapped provider, recording its usage
+func (t *UsageTracker) ToolCallCompletion(ctx context.Context, messages []types.Message, tools []types.ToolSpec) (types.Completion, error) {
+	return t.provider.ToolCallCompletion(withUsageRecorder(ctx, t.record), messages, tools)
+}
+
+// record adds the usage of a request
+func (t *UsageTracker) record(usage Usage) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	providerUsage := t.providers[usage.Provider]
+	providerUsage.Requests++
+	providerUsage.PromptTokens += usage.PromptTokens
+	providerUsage.CompletionTokens += usage.CompletionTokens
+	providerUsage.TotalTokens += usage.PromptTokens + usage.CompletionTokens
+	providerUsage.EstimatedCostUSD += EstimateCost(usage.Model, usage.PromptTokens, usage.CompletionTokens)
+	t.providers[usage.Provider] = providerUsage
+}
+
+// GetUsage returns the token usage since start or the last Reset
+func (t *UsageTracker) GetUsage() UsageStats {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	stats := UsageStats{
+		Providers: make(map[string]ProviderUsage, len(t.providers)),
+		Since:     t.since,
+	}
+	for name, usage := range t.providers {
+		stats.Providers[name] = usage
+		stats.PromptTokens += usage.PromptTokens
+		stats.CompletionTokens += usage.CompletionTokens
+		stats.TotalTokens += usage.TotalTokens
+		stats.EstimatedCostUSD += usage.EstimatedCostUSD
+	}
+	return stats
+}
+
+// Reset clears the token usage
+func (t *UsageTracker) Reset() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.providers = make(map[string]ProviderUsage)
+	t.since = time.Now()
+}
+
+// StartLogging logs the token usage every interval until StopLogging
+func (t *UsageTracker) StartLogging(interval time.Duration) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.stopLogging != nil {
+		return
+	}
+	stop := make(chan struct{})
+	t.stopLogging = stop
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				stats := t.GetUsage()
+				log.Printf("AI usage since %s: %d prompt + %d completion = %d tokens, estimated cost $%.4f",
+					stats.Since.Format(time.RFC3339), stats.PromptTokens, stats.CompletionTokens, stats.TotalTokens, stats.EstimatedCostUSD)
+			}
+		}
+	}()
+}
+
+// StopLogging stops the periodic usage log
+func (t *UsageTracker) StopLogging() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.stopLogging != nil {
+		close(t.stopLogging)
+		t.stopLogging = nil
+	}
+}
+
+// TestUsageTracker counts the tokens of OpenAI and Anthropic responses
+// from fake endpoints
+func TestUsageTracker() error {
+	fmt.Println("Testing Usage Tracker...")
+
+	openAIServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"id":"1","choices":[{"index":0,"message":{"role":"assistant","content":"Hi"},"finish_reason":"stop"}],"usage":{"prompt_tokens":1000,"completion_tokens":500,"total_tokens":1500}}`))
+	}))
+	defer openAIServer.Close()
+
+	anthropicServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"id":"msg_1","type":"message","role":"assistant","content":[{"type":"text","text":"Hi"}],"stop_reason":"end_turn","usage":{"input_tokens":200,"output_tokens":100}}`))
+	}))
+	defer anthropicServer.Close()
+
+	messages := []types.Message{{Role: "user", Content: "Hello"}}
+
+	openAI := NewUsageTracker(NewOpenAIProvider("key", openAIServer.URL, "gpt-4o"))
+	for i := 0; i < 2; i++ {
+		if _, err := openAI.ChatCompletion(context.Background(), messages); err != nil {
+			return err
+		}
+	}
+	stats := openAI.GetUsage()
+	if stats.PromptTokens != 2000 || stats.CompletionTokens != 1000 || stats.TotalTokens != 3000 || stats.Providers["openai"].Requests != 2 {
+		return fmt.Errorf("unexpected OpenAI usage: %+v", stats)
+	}
+	// 2000 prompt tokens at $2.50/M and 1000 completion tokens at $10/M
+	if math.Abs(stats.EstimatedCostUSD-0.015) > 1e-9 {
+		return fmt.Errorf("expected estimated cost $0.015, got $%f", stats.EstimatedCostUSD)
+	}
+	fmt.Println("✓ OpenAI usage and cost counted")
+
+	anthropicProvider := NewAnthropicProvider("key", "claude-3-5-sonnet-20241022").(*AnthropicProvider)
+	anthropicProvider.baseURL = anthropicServer.URL
+	anthropic := NewUsageTracker(anthropicProvider)
+	if _, err := anthropic.ChatCompletion(context.Background(), messages); err != nil {
+		return err
+	}
+	if usage := anthropic.GetUsage().Providers["anthropic"]; usage.PromptTokens != 200 || usage.CompletionTokens != 100 || usage.TotalTokens != 300 {
+		return fmt.Errorf("unexpected Anthropic usage: %+v", usage)
+	}
+	fmt.Println("✓ Anthropic usage counted")
+
+	openAI.Reset()
+	if stats := openAI.GetUsage(); stats.TotalTokens != 0 || len(stats.Providers) != 0 {
+		return fmt.Errorf("expected no usage after Reset, got %+v", stats)
+	}
+	fmt.Println("✓ Usage reset")
+
+	return nil
+}
@@ -1,11 +1,22 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"log"
 	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
+	"unicode/utf8"
+
+	"github.com/Chang-Augenweide/QuickBot-Go/pkg/types"
 )
 
 // API represents the QuickBot REST API
@@ -14,16 +25,68 @@ type API struct {
 	memory   *Memory
 	scheduler *Scheduler
 	port     int
+
+	workflows *WorkflowEngine
+	plugins   *PluginManager
+
+	// reconnects reports how many times each platform reconnected, and
+	// connections the state of platforms that report one
+	reconnects  map[string]func() int64
+	connections map[string]func() string
+
+	httpServer *http.Server
+	serverMu   sync.Mutex
+	inFlight   atomic.Int64
+	draining   atomic.Bool
+
+	audit       *auditLog
+	dashboardMu sync.Mutex
+	dashboard   *Dashboard
 }
 
 // NewAPI creates a new API instance
 func NewAPI(agent *Agent, memory *Memory, scheduler *Scheduler, port int) *API {
-	return &API{
+	api := &API{
 		agent:     agent,
 		memory:    memory,
 		scheduler: scheduler,
 		port:      port,
 	}
+
+	// Record recent events for the admin dashboard
+	if agent != nil && agent.EventBus() != nil {
+		api.audit = newAuditLog(agent.EventBus(), agent.Config().API.DashboardAuditEntries)
+	}
+
+	return api
+}
+
+// SetWorkflowEngine sets the workflow engine exposed by the API
+func (a *API) SetWorkflowEngine(engine *WorkflowEngine) {
+	a.workflows = engine
+}
+
+// SetPluginManager sets the plugin manager exposed by the API
+func (a *API) SetPluginManager(plugins *PluginManager) {
+	a.plugins = plugins
+}
+
+// SetReconnectCounter adds a platform's reconnect count to the health
+// check. It must be called before Start.
+func (a *API) SetReconnectCounter(platform string, count func() int64) {
+	if a.reconnects == nil {
+		a.reconnects = make(map[string]func() int64)
+	}
+	a.reconnects[platform] = count
+}
+
+// SetConnectionState adds a platform's connection state to the health
+// check. It must be called before Start.
+func (a *API) SetConnectionState(platform string, state func() string) {
+	if a.connections == nil {
+		a.connections = make(map[string]func() string)
+	}
+	a.connections[platform] = state
 }
 
 // Start starts the API server
@@ -32,9 +95,28 @@ func (a *API) Start() error {
 	http.HandleFunc("/", a.handleRoot)
 	http.HandleFunc("/health", a.handleHealth)
 	http.HandleFunc("/api/v1/chat", a.handleChat)
+	http.HandleFunc("/api/v1/memory", a.handleMemory)
 	http.HandleFunc("/api/v1/memory/", a.handleMemory)
+	http.HandleFunc("/api/v1/sessions", a.handleSessionList)
+	http.HandleFunc("/api/v1/sessions/", a.handleSessions)
 	http.HandleFunc("/api/v1/tasks", a.handleTasks)
+	http.HandleFunc("/api/v1/tasks/graph", a.handleTaskGraph)
+	http.HandleFunc("/api/v1/tasks/", a.handleTask)
+	http.HandleFunc("/api/v1/scheduler/stats", a.handleSchedulerStats)
+	http.HandleFunc("/api/v1/scheduler/overdue", a.handleSchedulerOverdue)
 	http.HandleFunc("/api/v1/status", a.handleStatus)
+	http.HandleFunc("/api/v1/usage", a.handleUsage)
+	http.HandleFunc("/api/v1/cache/stats", a.handleCacheStats)
+	http.HandleFunc("/api/v1/admin/dashboard", a.handleAdminDashboard)
+	http.HandleFunc("/api/v1/cache/", a.handleCache)
+	http.HandleFunc("/api/v1/tools/", a.handleTools)
+	http.HandleFunc("/api/v1/files/", a.handleFiles)
+	http.HandleFunc("/api/v1/workflows/queue", a.handleWorkflowQueue)
+	http.HandleFunc("/api/v1/workflows/", a.handleWorkflows)
+	http.HandleFunc("/api/v1/plugins", a.handlePluginList)
+	http.HandleFunc("/api/v1/plugins/", a.handlePlugins)
+	http.HandleFunc("/api/v1/prompts", a.handlePrompts)
+	http.HandleFunc("/api/v1/prompts/", a.handlePrompts)
 
 	// Start server
 	addr := fmt.Sprintf(":%d", a.port)
@@ -42,256 +124,1957 @@ func (a *API) Start() error {
 	log.Printf("Endpoints:")
 	log.Printf("  - GET  /health")
 	log.Printf("  - POST /api/v1/chat")
+	log.Printf("  - POST /api/v1/chat/batch")
+	log.Printf("  - GET  /api/v1/memory?prefix=<prefix>&limit=<n>")
 	log.Printf("  - GET  /api/v1/memory/<key>")
 	log.Printf("  - POST /api/v1/memory")
+	log.Printf("  - GET  /api/v1/sessions?tags=<a,b>&match=all")
+	log.Printf("  - GET  /api/v1/sessions?name=<pattern>")
+	log.Printf("  - PATCH /api/v1/sessions/<id>")
+	log.Printf("  - POST /api/v1/sessions/transfer (admin)")
+	log.Printf("  - POST /api/v1/sessions/<id>/tags")
+	log.Printf("  - DELETE /api/v1/sessions/<id>/tags/<tag>")
+	log.Printf("  - GET  /api/v1/sessions/<id>/ai-config")
+	log.Printf("  - POST /api/v1/sessions/<id>/ai-config")
+	log.Printf("  - POST /api/v1/sessions/<id>/config")
+	log.Printf("  - GET  /api/v1/sessions/<id>/graph")
+	log.Printf("  - POST /api/v1/sessions/<id>/import")
+	log.Printf("  - GET  /api/v1/sessions/<id>/messages?meta_key=<key>&meta_value=<value>")
+	log.Printf("  - GET  /api/v1/sessions/<id>/search?q=<query>&recency_weight=0.3&limit=<n>")
+	log.Printf("  - GET  /api/v1/sessions/<id>/snapshot?as_of=<RFC3339>")
+	log.Printf("  - POST /api/v1/sessions/<id>/summarize")
 	log.Printf("  - GET  /api/v1/tasks")
+	log.Printf("  - POST /api/v1/tasks")
+	log.Printf("  - GET  /api/v1/tasks/graph")
+	log.Printf("  - GET  /api/v1/tasks/<id>/history?limit=N")
+	log.Printf("  - GET  /api/v1/tasks/dead-letter")
+	log.Printf("  - POST /api/v1/tasks/dead-letter/<id>/retry")
+	log.Printf("  - GET  /api/v1/scheduler/stats")
+	log.Printf("  - GET  /api/v1/scheduler/overdue")
 	log.Printf("  - GET  /api/v1/status")
+	log.Printf("  - GET  /api/v1/usage")
+	log.Printf("  - GET  /api/v1/admin/dashboard (admin)")
+	log.Printf("  - GET  /api/v1/cache/stats")
+	log.Printf("  - DELETE /api/v1/cache/session/<id> (admin)")
+	log.Printf("  - DELETE /api/v1/cache/all (admin)")
+	log.Printf("  - POST /api/v1/tools/register (admin)")
+	log.Printf("  - GET  /api/v1/tools/<name> (admin)")
+	log.Printf("  - DELETE /api/v1/tools/<name> (admin)")
+	log.Printf("  - POST /api/v1/files/upload")
+	log.Printf("  - DELETE /api/v1/files/<session_id>/<filename>")
+	log.Printf("  - GET  /api/v1/workflows/queue")
+	log.Printf("  - GET  /api/v1/workflows/<id>/docs?format=markdown|mermaid")
+	log.Printf("  - POST /api/v1/workflows/<id>/annotate")
+	log.Printf("  - POST /api/v1/workflows/<id>/execute")
+	log.Printf("  - GET  /api/v1/workflows/executions/<execution_id>")
+	log.Printf("  - GET  /api/v1/plugins")
+	log.Printf("  - GET  /api/v1/plugins/<name>/schema")
+	log.Printf("  - GET  /api/v1/prompts")
+	log.Printf("  - GET  /api/v1/prompts/<name>")
+	log.Printf("  - POST /api/v1/prompts/<name>/activate (admin)")
+
+	cfg := a.agent.Config().API
+
+	// Batch requests and uploads get larger body limits than the other endpoints
+	mux := http.NewServeMux()
+	mux.Handle("/", RequestSizeLimitMiddleware(cfg.MaxRequestBodyBytes)(http.DefaultServeMux))
+	mux.Handle("/api/v1/chat/batch", RequestSizeLimitMiddleware(cfg.BatchMaxBodyBytes)(http.HandlerFunc(a.handleChatBatch)))
+	mux.Handle("/api/v1/files/upload", RequestSizeLimitMiddleware(cfg.MaxUploadBytes)(http.HandlerFunc(a.handleFileUpload)))
+
+	var handler http.Handler = mux
+	if cfg.Compression.Enabled {
+		handler = CompressionMiddleware(handler, cfg.Compression.MinResponseSize)
+	}
+	handler = RequestIDMiddleware(a.trackInFlight(handler))
+
+	server := &http.Server{
+		Addr:    addr,
+		Handler: handler,
+	}
 
-	return http.ListenAndServe(addr, nil)
+	a.serverMu.Lock()
+	a.httpServer = server
+	a.serverMu.Unlock()
+
+	if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		return err
+	}
+	return nil
 }
 
-// Response represents API response
-type Response struct {
-	Success bool        `json:"success"`
-	Data    interface{} `json:"data,omitempty"`
-	Error   string      `json:"error,omitempty"`
+// Stop stops accepting new requests, waits for in-flight requests to
+// finish and shuts down the server. If ctx expires first, the server is
+// shut down with requests still in flight and ctx's error is returned.
+func (a *API) Stop(ctx context.Context) error {
+	a.serverMu.Lock()
+	server := a.httpServer
+	a.serverMu.Unlock()
+
+	if server == nil {
+		return nil
+	}
+
+	a.draining.Store(true)
+
+	ticker := time.NewTicker(50 * time.Millisecond)
+	defer ticker.Stop()
+
+	for a.inFlight.Load() > 0 {
+		select {
+		case <-ctx.Done():
+			log.Printf("Warning: shutting down API with %d requests in flight", a.inFlight.Load())
+			server.Close()
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+
+	return server.Shutdown(ctx)
 }
 
-// handleRoot handles root endpoint
-func (a *API) handleRoot(w http.ResponseWriter, r *http.Request) {
+// InFlight returns the number of requests being handled
+func (a *API) InFlight() int64 {
+	return a.inFlight.Load()
+}
+
+// trackInFlight counts requests being handled, rejecting new requests
+// once the API is stopping
+func (a *API) trackInFlight(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		a.inFlight.Add(1)
+		defer a.inFlight.Add(-1)
+
+		if a.draining.Load() {
+			w.Header().Set("Connection", "close")
+			a.sendErrorStatus(w, r, http.StatusServiceUnavailable, "Server is shutting down")
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// handleWorkflowQueue handles workflow queue statistics
+func (a *API) handleWorkflowQueue(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 
+	if r.Method != http.MethodGet {
+		a.sendMethodNotAllowed(w, r)
+		return
+	}
+
+	if a.workflows == nil {
+		a.sendError(w, r, "Workflow engine is not configured")
+		return
+	}
+
 	response := Response{
 		Success: true,
-		Data: map[string]string{
-			"name":    "QuickBot API",
-			"version": "1.0.0",
-			"docs":    "See README.md for API documentation",
-		},
+		Data:    a.workflows.QueueStats(),
 	}
 
 	json.NewEncoder(w).Encode(response)
 }
 
-// handleHealth handles health check endpoint
-func (a *API) handleHealth(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodGet {
-		a.sendMethodNotAllowed(w)
+// handleWorkflows handles workflow documentation, annotation and
+// execution requests
+func (a *API) handleWorkflows(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	// <id>/docs, <id>/annotate, <id>/execute or executions/<execution_id>
+	parts := strings.Split(strings.Trim(r.URL.Path[len("/api/v1/workflows/"):], "/"), "/")
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		a.sendNotFound(w, r)
+		return
+	}
+
+	if parts[0] == "executions" {
+		a.handleWorkflowExecution(w, r, parts[1])
 		return
 	}
 
+	switch parts[1] {
+	case "execute":
+		a.handleWorkflowExecute(w, r, parts[0])
+	case "docs":
+		a.handleWorkflowDocs(w, r, parts[0])
+	case "annotate":
+		a.handleWorkflowAnnotate(w, r, parts[0])
+	default:
+		a.sendNotFound(w, r)
+	}
+}
+
+// handlePluginList lists the loaded plugins and those rejected for an
+// incompatible plugin API version
+func (a *API) handlePluginList(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 
+	if r.Method != http.MethodGet {
+		a.sendMethodNotAllowed(w, r)
+		return
+	}
+
+	if a.plugins == nil {
+		a.sendError(w, r, "Plugin manager is not configured")
+		return
+	}
+
 	response := Response{
 		Success: true,
 		Data: map[string]interface{}{
-			"status":    "healthy",
-			"timestamp": time.Now().Unix(),
-			"services": map[string]bool{
-				"api":       true,
-				"memory":    a.memory != nil,
-				"scheduler": a.scheduler != nil,
-			},
+			"api_version": CurrentAPIVersion,
+			"plugins":     a.plugins.ListPluginStatus(),
 		},
 	}
 
 	json.NewEncoder(w).Encode(response)
 }
 
-// handleChat handles chat endpoint
-func (a *API) handleChat(w http.ResponseWriter, r *http.Request) {
+// handlePlugins handles plugin requests: <name>/schema
+func (a *API) handlePlugins(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 
-	if r.Method != http.MethodPost {
-		a.sendMethodNotAllowed(w)
+	parts := strings.Split(strings.Trim(r.URL.Path[len("/api/v1/plugins/"):], "/"), "/")
+	if len(parts) != 2 || parts[0] == "" || parts[1] != "schema" {
+		a.sendNotFound(w, r)
 		return
 	}
 
-	var request struct {
-		SessionID string `json:"session_id"`
-		Message   string `json:"message"`
-	}
-
-	err := json.NewDecoder(r.Body).Decode(&request)
-	if err != nil {
-		a.sendError(w, fmt.Sprintf("Invalid request: %v", err))
+	if r.Method != http.MethodGet {
+		a.sendMethodNotAllowed(w, r)
 		return
 	}
 
-	if request.Message == "" {
-		a.sendError(w, "Message is required")
+	if a.plugins == nil {
+		a.sendError(w, r, "Plugin manager is not configured")
 		return
 	}
 
-	if request.SessionID == "" {
-		request.SessionID = fmt.Sprintf("api_%d", time.Now().UnixNano())
-	}
-
-	// Process message
-	responseData, err := a.agent.ProcessMessage(request.SessionID, request.Message)
+	schema, err := a.plugins.GetConfigSchema(parts[0])
 	if err != nil {
-		a.sendError(w, fmt.Sprintf("Failed to process: %v", err))
+		if errors.Is(err, ErrPluginNotFound) {
+			a.sendNotFound(w, r)
+			return
+		}
+		a.sendError(w, r, fmt.Sprintf("Failed to get plugin schema: %v", err))
 		return
 	}
 
 	response := Response{
 		Success: true,
 		Data: map[string]interface{}{
-			"response":   responseData,
-			"session_id": request.SessionID,
+			"plugin": parts[0],
+			"schema": schema,
 		},
 	}
 
 	json.NewEncoder(w).Encode(response)
 }
 
-// handleMemory handles memory operations
-func (a *API) handleMemory(w http.ResponseWriter, r *http.Request) {
-	w.Header().Set("Content-Type", "application/json")
+// handleWorkflowAnnotate generates descriptions for the steps of a
+// workflow that have none
+func (a *API) handleWorkflowAnnotate(w http.ResponseWriter, r *http.Request, workflowID string) {
+	if r.Method != http.MethodPost {
+		a.sendMethodNotAllowed(w, r)
+		return
+	}
 
-	switch r.Method {
-	case http.MethodGet:
-		// Extract key from URL
-		key := r.URL.Path[len("/api/v1/memory/"):]
-		if key == "" {
-			a.sendError(w, "Key is required")
-			return
-		}
+	if a.workflows == nil {
+		a.sendError(w, r, "Workflow engine is not configured")
+		return
+	}
 
-		// Get memory value
-		value, err := a.agent.GetMemory(key)
-		if err != nil {
-			a.sendError(w, fmt.Sprintf("Failed to get memory: %v", err))
+	if err := a.workflows.AnnotateWorkflow(workflowID); err != nil {
+		if errors.Is(err, ErrWorkflowNotFound) {
+			a.sendNotFound(w, r)
 			return
 		}
+		a.sendError(w, r, fmt.Sprintf("Failed to annotate workflow: %v", err))
+		return
+	}
 
-		response := Response{
-			Success: true,
-			Data: map[string]interface{}{
-				"key":   key,
-				"value": value,
-			},
-		}
+	docs, err := a.workflows.GenerateDocs(workflowID, DocsFormatMarkdown)
+	if err != nil {
+		a.sendError(w, r, fmt.Sprintf("Failed to generate docs: %v", err))
+		return
+	}
 
-		json.NewEncoder(w).Encode(response)
+	response := Response{
+		Success: true,
+		Data: map[string]interface{}{
+			"workflow_id": workflowID,
+			"docs":        docs,
+		},
+	}
 
-	case http.MethodPost:
-		var request struct {
-			Key   string `json:"key"`
-			Value string `json:"value"`
-		}
+	json.NewEncoder(w).Encode(response)
+}
 
-		err := json.NewDecoder(r.Body).Decode(&request)
-		if err != nil {
-			a.sendError(w, fmt.Sprintf("Invalid request: %v", err))
-			return
-		}
+// handleWorkflowExecute starts an execution of a workflow in the
+// background and returns its execution ID
+func (a *API) handleWorkflowExecute(w http.ResponseWriter, r *http.Request, workflowID string) {
+	if r.Method != http.MethodPost {
+		a.sendMethodNotAllowed(w, r)
+		return
+	}
 
-		if request.Key == "" || request.Value == "" {
-			a.sendError(w, "Key and value are required")
-			return
-		}
+	if a.workflows == nil {
+		a.sendError(w, r, "Workflow engine is not configured")
+		return
+	}
 
-		// Set memory value
-		err = a.agent.SetMemory(request.Key, request.Value)
-		if err != nil {
-			a.sendError(w, fmt.Sprintf("Failed to set memory: %v", err))
-			return
-		}
+	// The body is optional
+	var request struct {
+		Variables map[string]interface{} `json:"variables"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil && err != io.EOF {
+		a.sendRequestError(w, r, err)
+		return
+	}
 
-		response := Response{
-			Success: true,
-			Data: map[string]interface{}{
-				"action": "set",
-				"key":    request.Key,
-			},
+	executionID, err := a.workflows.ExecuteWorkflowAsync(workflowID, request.Variables)
+	if err != nil {
+		if errors.Is(err, ErrWorkflowNotFound) {
+			a.sendNotFound(w, r)
+			return
 		}
+		a.sendError(w, r, fmt.Sprintf("Failed to execute workflow: %v", err))
+		return
+	}
 
-		json.NewEncoder(w).Encode(response)
-
-	default:
-		a.sendMethodNotAllowed(w)
+	response := Response{
+		Success: true,
+		Data: map[string]interface{}{
+			"workflow_id":  workflowID,
+			"execution_id": executionID,
+			"status":       "running",
+		},
 	}
-}
 
-// handleTasks handles tasks endpoint
-func (a *API) handleTasks(w http.ResponseWriter, r *http.Request) {
-	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(response)
+}
 
+// handleWorkflowExecution reports the status of a workflow execution
+func (a *API) handleWorkflowExecution(w http.ResponseWriter, r *http.Request, executionID string) {
 	if r.Method != http.MethodGet {
-		a.sendMethodNotAllowed(w)
+		a.sendMethodNotAllowed(w, r)
+		return
+	}
+
+	if a.workflows == nil {
+		a.sendError(w, r, "Workflow engine is not configured")
 		return
 	}
 
-	tasks := a.scheduler.GetAllTasks()
+	execution, err := a.workflows.GetExecutionStatus(executionID)
+	if err != nil {
+		if errors.Is(err, ErrExecutionNotFound) {
+			a.sendNotFound(w, r)
+			return
+		}
+		a.sendError(w, r, fmt.Sprintf("Failed to get execution: %v", err))
+		return
+	}
 
 	response := Response{
 		Success: true,
-		Data: map[string]interface{}{
-			"count": len(tasks),
-			"tasks": tasks,
-		},
+		Data:    execution,
 	}
 
 	json.NewEncoder(w).Encode(response)
 }
 
-// handleStatus handles status endpoint
-func (a *API) handleStatus(w http.ResponseWriter, r *http.Request) {
+// handleWorkflowDocs renders workflow documentation
+func (a *API) handleWorkflowDocs(w http.ResponseWriter, r *http.Request, workflowID string) {
 	if r.Method != http.MethodGet {
-		a.sendMethodNotAllowed(w)
+		a.sendMethodNotAllowed(w, r)
 		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
+	if a.workflows == nil {
+		a.sendError(w, r, "Workflow engine is not configured")
+		return
+	}
+
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = DocsFormatMarkdown
+	}
+
+	docs, err := a.workflows.GenerateDocs(workflowID, format)
+	if err != nil {
+		if errors.Is(err, ErrWorkflowNotFound) {
+			a.sendNotFound(w, r)
+			return
+		}
+		a.sendError(w, r, fmt.Sprintf("Failed to generate docs: %v", err))
+		return
+	}
 
 	response := Response{
 		Success: true,
 		Data: map[string]interface{}{
-			"bot":        a.agent.Config().Bot.Name,
-			"ai_provider": a.agent.Config().AI.Provider,
-			"ai_model":   a.agent.Config().AI.Model,
-			"uptime":     time.Since(time.Now()).String(),
+			"workflow_id": workflowID,
+			"format":      format,
+			"docs":        docs,
 		},
 	}
 
 	json.NewEncoder(w).Encode(response)
 }
 
-// sendError sends error response
-func (a *API) sendError(w http.ResponseWriter, message string) {
-	response := Response{
-		Success: false,
-		Error:   message,
-	}
-
-	w.WriteHeader(http.StatusBadRequest)
-	json.NewEncoder(w).Encode(response)
+// Response represents API response
+type Response struct {
+	Success   bool        `json:"success"`
+	Data      interface{} `json:"data,omitempty"`
+	Error     string      `json:"error,omitempty"`
+	RequestID string      `json:"request_id,omitempty"`
 }
 
-// sendMethodNotAllowed sends method not allowed response
-func (a *API) sendMethodNotAllowed(w http.ResponseWriter) {
+// handleRoot handles root endpoint
+func (a *API) handleRoot(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
 	response := Response{
-		Success: false,
-		Error:   "Method not allowed",
+		Success: true,
+		Data: map[string]string{
+			"name":    "QuickBot API",
+			"version": "1.0.0",
+			"docs":    "See README.md for API documentation",
+		},
 	}
 
-	w.WriteHeader(http.StatusMethodNotAllowed)
 	json.NewEncoder(w).Encode(response)
 }
 
-// TestAPI tests the API module
-func TestAPI() {
-	log.Println("Testing API module...")
+// handleHealth handles health check endpoint
+func (a *API) handleHealth(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		a.sendMethodNotAllowed(w, r)
+		return
+	}
 
-	// Create test components
-	config := GetDefaultConfig()
-	memory, _ := NewMemory("test_api_memory.db", 100)
-	scheduler, _ := NewScheduler("test_api_scheduler.db")
-	agent := NewSimpleAgent(config, NewSimpleMemory(100), NewSimpleScheduler())
+	w.Header().Set("Content-Type", "application/json")
 
-	// Create API instance
-	api := NewAPI(nil, nil, nil, 8080)
+	schedulerHealth := "disabled"
+	if a.scheduler != nil {
+		schedulerHealth = "healthy"
+		if err := a.scheduler.HealthCheck(); err != nil {
+			schedulerHealth = err.Error()
+		}
+	}
+
+	reconnects := make(map[string]int64, len(a.reconnects))
+	for platform, count := range a.reconnects {
+		reconnects[platform] = count()
+	}
+	connections := make(map[string]string, len(a.connections))
+	for platform, state := range a.connections {
+		connections[platform] = state()
+	}
+
+	response := Response{
+		Success: true,
+		Data: map[string]interface{}{
+			"status":    "healthy",
+			"timestamp": time.Now().Unix(),
+			"services": map[string]bool{
+				"api":       true,
+				"memory":    a.memory != nil,
+				"scheduler": a.scheduler != nil,
+			},
+			"ai_circuit_state":     a.agent.CircuitState(),
+			"scheduler_health":     schedulerHealth,
+			"platform_reconnects":  reconnects,
+			"platform_connections": connections,
+		},
+	}
+
+	json.NewEncoder(w).Encode(response)
+}
+
+// handleChat handles chat endpoint
+func (a *API) handleChat(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if r.Method != http.MethodPost {
+		a.sendMethodNotAllowed(w, r)
+		return
+	}
+
+	var request struct {
+		SessionID string `json:"session_id"`
+		Message   string `json:"message"`
+	}
+
+	err := json.NewDecoder(r.Body).Decode(&request)
+	if err != nil {
+		a.sendRequestError(w, r, err)
+		return
+	}
+
+	if request.Message == "" {
+		a.sendError(w, r, "Message is required")
+		return
+	}
+
+	maxLength := a.agent.Config().API.MaxMessageLength
+	if length := utf8.RuneCountInString(request.Message); length > maxLength {
+		a.sendErrorStatus(w, r, http.StatusUnprocessableEntity,
+			fmt.Sprintf("Message too long: %d characters (max %d)", length, maxLength))
+		return
+	}
+
+	if request.SessionID == "" {
+		request.SessionID = fmt.Sprintf("api_%d", time.Now().UnixNano())
+	}
+
+	// Process message
+	responseData, cacheHit, err := a.agent.ProcessMessageWithCacheStatus(r.Context(), request.SessionID, request.Message)
+	if err != nil {
+		a.sendError(w, r, fmt.Sprintf("Failed to process: %v", err))
+		return
+	}
+
+	if cacheHit {
+		w.Header().Set("Cache-Hit", "true")
+	}
+
+	response := Response{
+		Success: true,
+		Data: map[string]interface{}{
+			"response":   responseData,
+			"session_id": request.SessionID,
+		},
+	}
+
+	json.NewEncoder(w).Encode(response)
+}
+
+// BatchResult represents the result of a single message in a batch
+type BatchResult struct {
+	Message  string `json:"message"`
+	Response string `json:"response,omitempty"`
+	Error    string `json:"error,omitempty"`
+}
+
+// handleChatBatch handles batch chat endpoint
+func (a *API) handleChatBatch(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if r.Method != http.MethodPost {
+		a.sendMethodNotAllowed(w, r)
+		return
+	}
+
+	cfg := a.agent.Config().API
+
+	var request struct {
+		SessionID string   `json:"session_id"`
+		Messages  []string `json:"messages"`
+		Parallel  bool     `json:"parallel"`
+	}
+
+	err := json.NewDecoder(r.Body).Decode(&request)
+	if err != nil {
+		a.sendRequestError(w, r, err)
+		return
+	}
+
+	if len(request.Messages) == 0 {
+		a.sendError(w, r, "Messages are required")
+		return
+	}
+
+	if len(request.Messages) > cfg.MaxBatchSize {
+		a.sendError(w, r, fmt.Sprintf("Batch too large: %d messages (max %d)", len(request.Messages), cfg.MaxBatchSize))
+		return
+	}
+
+	for i, message := range request.Messages {
+		if length := utf8.RuneCountInString(message); length > cfg.MaxMessageLength {
+			a.sendErrorStatus(w, r, http.StatusUnprocessableEntity,
+				fmt.Sprintf("Message %d too long: %d characters (max %d)", i, length, cfg.MaxMessageLength))
+			return
+		}
+	}
+
+	if request.SessionID == "" {
+		request.SessionID = fmt.Sprintf("api_%d", time.Now().UnixNano())
+	}
+
+	var results []BatchResult
+	if request.Parallel {
+		results = a.processBatchParallel(r.Context(), request.SessionID, request.Messages, cfg.BatchMaxConcurrency)
+	} else {
+		results = a.processBatchSequential(r.Context(), request.SessionID, request.Messages)
+	}
+
+	response := Response{
+		Success: true,
+		Data: map[string]interface{}{
+			"session_id": request.SessionID,
+			"results":    results,
+		},
+	}
+
+	json.NewEncoder(w).Encode(response)
+}
+
+// processBatchSequential processes messages in order within one session,
+// so each message sees the conversation history of the previous ones
+func (a *API) processBatchSequential(ctx context.Context, sessionID string, messages []string) []BatchResult {
+	results := make([]BatchResult, len(messages))
+
+	for i, message := range messages {
+		results[i].Message = message
+
+		response, err := a.agent.ProcessMessage(ctx, sessionID, message)
+		if err != nil {
+			results[i].Error = err.Error()
+			continue
+		}
+		results[i].Response = response
+	}
+
+	return results
+}
+
+// processBatchParallel processes messages concurrently, each in its own
+// sub-session, with at most maxConcurrency messages in flight
+func (a *API) processBatchParallel(ctx context.Context, sessionID string, messages []string, maxConcurrency int) []BatchResult {
+	if maxConcurrency <= 0 {
+		maxConcurrency = 1
+	}
+
+	results := make([]BatchResult, len(messages))
+	sem := make(chan struct{}, maxConcurrency)
+	var wg sync.WaitGroup
+
+	for i, message := range messages {
+		wg.Add(1)
+		go func(idx int, msg string) {
+			defer wg.Done()
+
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			results[idx].Message = msg
+
+			subSessionID := fmt.Sprintf("%s_batch_%d", sessionID, idx)
+			response, err := a.agent.ProcessMessage(ctx, subSessionID, msg)
+			if err != nil {
+				results[idx].Error = err.Error()
+				return
+			}
+			results[idx].Response = response
+		}(i, message)
+	}
+
+	wg.Wait()
+	return results
+}
+
+// handleMemory handles memory operations
+func (a *API) handleMemory(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	switch r.Method {
+	case http.MethodGet:
+		// Extract key from URL
+		key := strings.TrimPrefix(strings.TrimPrefix(r.URL.Path, "/api/v1/memory"), "/")
+		if key == "" {
+			a.handleMemoryList(w, r)
+			return
+		}
+
+		// Get memory value
+		value, err := a.agent.GetMemory(key)
+		if errors.Is(err, ErrExpired) {
+			a.sendNotFound(w, r)
+			return
+		}
+		if err != nil {
+			a.sendError(w, r, fmt.Sprintf("Failed to get memory: %v", err))
+			return
+		}
+
+		response := Response{
+			Success: true,
+			Data: map[string]interface{}{
+				"key":   key,
+				"value": value,
+			},
+		}
+
+		json.NewEncoder(w).Encode(response)
+
+	case http.MethodPost:
+		var request struct {
+			Key   string `json:"key"`
+			Value string `json:"value"`
+		}
+
+		err := json.NewDecoder(r.Body).Decode(&request)
+		if err != nil {
+			a.sendRequestError(w, r, err)
+			return
+		}
+
+		if request.Key == "" || request.Value == "" {
+			a.sendError(w, r, "Key and value are required")
+			return
+		}
+
+		// Set memory value
+		err = a.agent.SetMemory(request.Key, request.Value)
+		if err != nil {
+			a.sendError(w, r, fmt.Sprintf("Failed to set memory: %v", err))
+			return
+		}
+
+		response := Response{
+			Success: true,
+			Data: map[string]interface{}{
+				"action": "set",
+				"key":    request.Key,
+			},
+		}
+
+		json.NewEncoder(w).Encode(response)
+
+	default:
+		a.sendMethodNotAllowed(w, r)
+	}
+}
+
+// handleMemoryList lists long-term memories
+func (a *API) handleMemoryList(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	limit := 0
+	if value := query.Get("limit"); value != "" {
+		var err error
+		limit, err = strconv.Atoi(value)
+		if err != nil || limit < 0 {
+			a.sendError(w, r, fmt.Sprintf("Invalid limit: %s", value))
+			return
+		}
+	}
+
+	entries, err := a.memory.ListLongTerm(query.Get("prefix"), limit)
+	if err != nil {
+		a.sendError(w, r, fmt.Sprintf("Failed to list memory: %v", err))
+		return
+	}
+
+	response := Response{
+		Success: true,
+		Data:    entries,
+	}
+
+	json.NewEncoder(w).Encode(response)
+}
+
+// handleSessions handles session operations
+func (a *API) handleSessions(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	path := strings.Trim(r.URL.Path[len("/api/v1/sessions/"):], "/")
+
+	if path == "transfer" {
+		a.handleSessionTransfer(w, r)
+		return
+	}
+
+	// <id>, <id>/ai-config, <id>/config, <id>/graph, <id>/import, <id>/messages, <id>/search, <id>/snapshot, <id>/summarize, <id>/tags or <id>/tags/<tag>
+	parts := strings.SplitN(path, "/", 3)
+	if len(parts) == 1 && parts[0] != "" {
+		a.handleSessionRename(w, r, parts[0])
+		return
+	}
+	if len(parts) == 2 && parts[0] != "" && parts[1] == "ai-config" {
+		a.handleSessionAIConfig(w, r, parts[0])
+		return
+	}
+	if len(parts) == 2 && parts[0] != "" && parts[1] == "config" {
+		a.handleSessionConfig(w, r, parts[0])
+		return
+	}
+	if len(parts) == 2 && parts[0] != "" && parts[1] == "import" {
+		a.handleSessionImport(w, r, parts[0])
+		return
+	}
+	if len(parts) == 2 && parts[0] != "" && parts[1] == "graph" {
+		a.handleSessionGraph(w, r, parts[0])
+		return
+	}
+	if len(parts) == 2 && parts[0] != "" && parts[1] == "messages" {
+		a.handleSessionMessages(w, r, parts[0])
+		return
+	}
+	if len(parts) == 2 && parts[0] != "" && parts[1] == "search" {
+		a.handleSessionSearch(w, r, parts[0])
+		return
+	}
+	if len(parts) == 2 && parts[0] != "" && parts[1] == "snapshot" {
+		a.handleSessionSnapshot(w, r, parts[0])
+		return
+	}
+	if len(parts) == 2 && parts[0] != "" && parts[1] == "summarize" {
+		a.handleSessionSummarize(w, r, parts[0])
+		return
+	}
+	if len(parts) < 2 || parts[0] == "" || parts[1] != "tags" {
+		a.sendNotFound(w, r)
+		return
+	}
+
+	if len(parts) == 3 {
+		a.handleSessionTagDelete(w, r, parts[0], parts[2])
+		return
+	}
+	a.handleSessionTags(w, r, parts[0])
+}
+
+// handleSessionList lists sessions by tag, or by name with ?name=<pattern>
+// where % matches any characters
+func (a *API) handleSessionList(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if r.Method != http.MethodGet {
+		a.sendMethodNotAllowed(w, r)
+		return
+	}
+
+	query := r.URL.Query()
+	if name := query.Get("name"); name != "" {
+		sessions, err := a.memory.GetSessionsByName(name)
+		if err != nil {
+			a.sendError(w, r, fmt.Sprintf("Failed to search sessions: %v", err))
+			return
+		}
+
+		json.NewEncoder(w).Encode(Response{
+			Success: true,
+			Data:    sessions,
+		})
+		return
+	}
+
+	tags := strings.Split(query.Get("tags"), ",")
+	if query.Get("tags") == "" {
+		a.sendError(w, r, "Tags or name are required")
+		return
+	}
+
+	limit := 0
+	if value := query.Get("limit"); value != "" {
+		var err error
+		limit, err = strconv.Atoi(value)
+		if err != nil || limit < 0 {
+			a.sendError(w, r, fmt.Sprintf("Invalid limit: %s", value))
+			return
+		}
+	}
+
+	sessions, err := a.memory.SearchByTags(tags, query.Get("match") == "all", limit)
+	if err != nil {
+		a.sendError(w, r, fmt.Sprintf("Failed to search sessions: %v", err))
+		return
+	}
+
+	response := Response{
+		Success: true,
+		Data:    sessions,
+	}
+
+	json.NewEncoder(w).Encode(response)
+}
+
+// handleSessionRename sets the name and description of a session
+func (a *API) handleSessionRename(w http.ResponseWriter, r *http.Request, sessionID string) {
+	if r.Method != http.MethodPatch {
+		a.sendMethodNotAllowed(w, r)
+		return
+	}
+
+	var request struct {
+		Name        string `json:"name"`
+		Description string `json:"description"`
+	}
+
+	err := json.NewDecoder(r.Body).Decode(&request)
+	if err != nil {
+		a.sendRequestError(w, r, err)
+		return
+	}
+
+	request.Name = strings.TrimSpace(request.Name)
+	if request.Name == "" {
+		a.sendError(w, r, "Name is required")
+		return
+	}
+
+	session, err := a.memory.GetSession(sessionID)
+	if err != nil {
+		a.sendError(w, r, fmt.Sprintf("Failed to get session: %v", err))
+		return
+	}
+	if session == nil {
+		a.sendNotFound(w, r)
+		return
+	}
+
+	if err := a.memory.RenameSession(sessionID, request.Name, request.Description); err != nil {
+		a.sendError(w, r, fmt.Sprintf("Failed to rename session: %v", err))
+		return
+	}
+
+	session, err = a.memory.GetSession(sessionID)
+	if err != nil {
+		a.sendError(w, r, fmt.Sprintf("Failed to get session: %v", err))
+		return
+	}
+
+	response := Response{
+		Success: true,
+		Data:    session,
+	}
+
+	json.NewEncoder(w).Encode(response)
+}
+
+// handleSessionTags adds tags to a session
+func (a *API) handleSessionTags(w http.ResponseWriter, r *http.Request, sessionID string) {
+	if r.Method != http.MethodPost {
+		a.sendMethodNotAllowed(w, r)
+		return
+	}
+
+	var request struct {
+		Tags []string `json:"tags"`
+	}
+
+	err := json.NewDecoder(r.Body).Decode(&request)
+	if err != nil {
+		a.sendRequestError(w, r, err)
+		return
+	}
+
+	if len(request.Tags) == 0 {
+		a.sendError(w, r, "Tags are required")
+		return
+	}
+
+	for _, tag := range request.Tags {
+		if err := a.memory.AddTag(sessionID, tag); err != nil {
+			a.sendError(w, r, fmt.Sprintf("Failed to add tag: %v", err))
+			return
+		}
+	}
+
+	a.sendSessionTags(w, r, sessionID)
+}
+
+// handleSessionAIConfig gets or sets the AI parameter overrides of a
+// session; posting an empty object clears them
+func (a *API) handleSessionAIConfig(w http.ResponseWriter, r *http.Request, sessionID string) {
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPost:
+		var overrides types.AIOverrides
+		if err := json.NewDecoder(r.Body).Decode(&overrides); err != nil {
+			a.sendRequestError(w, r, err)
+			return
+		}
+
+		if err := a.agent.SetSessionAIOverrides(sessionID, overrides); err != nil {
+			a.sendError(w, r, fmt.Sprintf("Invalid AI config: %v", err))
+			return
+		}
+	default:
+		a.sendMethodNotAllowed(w, r)
+		return
+	}
+
+	overrides, err := a.memory.GetAIOverrides(sessionID)
+	if err != nil {
+		a.sendError(w, r, fmt.Sprintf("Failed to get AI config: %v", err))
+		return
+	}
+	if overrides == nil {
+		overrides = &types.AIOverrides{}
+	}
+
+	response := Response{
+		Success: true,
+		Data: map[string]interface{}{
+			"session_id":   sessionID,
+			"ai_overrides": overrides,
+		},
+	}
+
+	json.NewEncoder(w).Encode(response)
+}
+
+// SessionConfigRequest sets a per-session configuration value
+type SessionConfigRequest struct {
+	Key   string `json:"key"`
+	Value string `json:"value"`
+}
+
+// handleSessionConfig sets a per-session configuration value. The only
+// key is ai_timeout_seconds; an empty value restores the default.
+func (a *API) handleSessionConfig(w http.ResponseWriter, r *http.Request, sessionID string) {
+	if r.Method != http.MethodPost {
+		a.sendMethodNotAllowed(w, r)
+		return
+	}
+
+	var req SessionConfigRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		a.sendRequestError(w, r, err)
+		return
+	}
+
+	switch req.Key {
+	case SessionTimeoutKey:
+		seconds := 0.0
+		if req.Value != "" {
+			parsed, err := strconv.ParseFloat(req.Value, 64)
+			if err != nil {
+				a.sendError(w, r, fmt.Sprintf("Invalid %s: %s", req.Key, req.Value))
+				return
+			}
+			seconds = parsed
+		}
+		if err := a.agent.SetSessionTimeout(sessionID, seconds); err != nil {
+			a.sendError(w, r, fmt.Sprintf("Invalid session config: %v", err))
+			return
+		}
+	default:
+		a.sendError(w, r, fmt.Sprintf("Unknown session config key: %s", req.Key))
+		return
+	}
+
+	value, err := a.memory.GetSessionConfig(sessionID, req.Key)
+	if err != nil {
+		a.sendError(w, r, fmt.Sprintf("Failed to get session config: %v", err))
+		return
+	}
+
+	response := Response{
+		Success: true,
+		Data: map[string]interface{}{
+			"session_id": sessionID,
+			"key":        req.Key,
+			"value":      value,
+		},
+	}
+
+	json.NewEncoder(w).Encode(response)
+}
+
+// handleSessionSnapshot returns the messages and long-term memories of a
+// session as they were at the as_of time (RFC 3339, default now)
+func (a *API) handleSessionSnapshot(w http.ResponseWriter, r *http.Request, sessionID string) {
+	if r.Method != http.MethodGet {
+		a.sendMethodNotAllowed(w, r)
+		return
+	}
+
+	asOf := time.Now()
+	if value := r.URL.Query().Get("as_of"); value != "" {
+		parsed, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			a.sendError(w, r, fmt.Sprintf("Invalid as_of time: %v", err))
+			return
+		}
+		asOf = parsed
+	}
+
+	messages, longTerm, err := a.memory.Snapshot(sessionID, asOf)
+	if err != nil {
+		a.sendError(w, r, fmt.Sprintf("Failed to get snapshot: %v", err))
+		return
+	}
+
+	response := Response{
+		Success: true,
+		Data: map[string]interface{}{
+			"session_id": sessionID,
+			"as_of":      asOf.UTC().Format(time.RFC3339),
+			"messages":   messages,
+			"long_term":  longTerm,
+		},
+	}
+
+	json.NewEncoder(w).Encode(response)
+}
+
+// handleSessionGraph returns the messages and long-term memories of a
+// session as a graph
+func (a *API) handleSessionGraph(w http.ResponseWriter, r *http.Request, sessionID string) {
+	if r.Method != http.MethodGet {
+		a.sendMethodNotAllowed(w, r)
+		return
+	}
+
+	graph, err := a.memory.GetSessionGraph(sessionID)
+	if err != nil {
+		a.sendErrorStatus(w, r, http.StatusInternalServerError, fmt.Sprintf("Failed to get session graph: %v", err))
+		return
+	}
+	if len(graph.Nodes) == 0 {
+		a.sendNotFound(w, r)
+		return
+	}
+
+	response := Response{
+		Success: true,
+		Data:    graph,
+	}
+
+	json.NewEncoder(w).Encode(response)
+}
+
+// handleSessionMessages lists the messages of a session, oldest first,
+// or with meta_key and meta_value only those with that metadata
+func (a *API) handleSessionMessages(w http.ResponseWriter, r *http.Request, sessionID string) {
+	if r.Method != http.MethodGet {
+		a.sendMethodNotAllowed(w, r)
+		return
+	}
+
+	query := r.URL.Query()
+	key, value := query.Get("meta_key"), query.Get("meta_value")
+
+	var messages []Message
+	var err error
+	if key != "" {
+		if value == "" {
+			a.sendError(w, r, "meta_value is required with meta_key")
+			return
+		}
+		messages, err = a.memory.GetMessagesByMetadata(key, value, sessionID)
+	} else {
+		messages, err = a.memory.GetMessagesBetween(sessionID, 0, 0)
+	}
+	if err != nil {
+		a.sendErrorStatus(w, r, http.StatusInternalServerError, fmt.Sprintf("Failed to get messages: %v", err))
+		return
+	}
+	if messages == nil {
+		messages = []Message{}
+	}
+
+	response := Response{
+		Success: true,
+		Data: map[string]interface{}{
+			"session_id": sessionID,
+			"messages":   messages,
+		},
+	}
+
+	json.NewEncoder(w).Encode(response)
+}
+
+// handleSessionSearch searches the messages of a session, ranked by text
+// relevance and recency
+func (a *API) handleSessionSearch(w http.ResponseWriter, r *http.Request, sessionID string) {
+	if r.Method != http.MethodGet {
+		a.sendMethodNotAllowed(w, r)
+		return
+	}
+
+	query := r.URL.Query()
+	q := query.Get("q")
+	if strings.TrimSpace(q) == "" {
+		a.sendError(w, r, "q is required")
+		return
+	}
+
+	recencyWeight := 0.3
+	if value := query.Get("recency_weight"); value != "" {
+		var err error
+		recencyWeight, err = strconv.ParseFloat(value, 64)
+		if err != nil || recencyWeight < 0 || recencyWeight > 1 {
+			a.sendError(w, r, fmt.Sprintf("Invalid recency_weight: %s (must be between 0 and 1)", value))
+			return
+		}
+	}
+
+	limit := 0
+	if value := query.Get("limit"); value != "" {
+		var err error
+		limit, err = strconv.Atoi(value)
+		if err != nil || limit < 0 {
+			a.sendError(w, r, fmt.Sprintf("Invalid limit: %s", value))
+			return
+		}
+	}
+
+	messages, err := a.memory.HybridSearch(q, sessionID, limit, recencyWeight)
+	if err != nil {
+		a.sendErrorStatus(w, r, http.StatusInternalServerError, fmt.Sprintf("Failed to search messages: %v", err))
+		return
+	}
+
+	response := Response{
+		Success: true,
+		Data: map[string]interface{}{
+			"session_id":     sessionID,
+			"query":          q,
+			"recency_weight": recencyWeight,
+			"messages":       messages,
+		},
+	}
+
+	json.NewEncoder(w).Encode(response)
+}
+
+// handleSessionSummarize summarizes a session and stores the summary
+func (a *API) handleSessionSummarize(w http.ResponseWriter, r *http.Request, sessionID string) {
+	if r.Method != http.MethodPost {
+		a.sendMethodNotAllowed(w, r)
+		return
+	}
+
+	messages, err := a.memory.GetMessages(sessionID, 1)
+	if err != nil {
+		a.sendErrorStatus(w, r, http.StatusInternalServerError, fmt.Sprintf("Failed to get messages: %v", err))
+		return
+	}
+	if len(messages) == 0 {
+		a.sendNotFound(w, r)
+		return
+	}
+
+	summary, err := a.agent.SummarizeSession(sessionID)
+	if err != nil {
+		a.sendErrorStatus(w, r, http.StatusInternalServerError, fmt.Sprintf("Failed to summarize session: %v", err))
+		return
+	}
+
+	response := Response{
+		Success: true,
+		Data: map[string]interface{}{
+			"session_id": sessionID,
+			"summary":    summary,
+		},
+	}
+
+	json.NewEncoder(w).Encode(response)
+}
+
+// handleSessionTagDelete removes a tag from a session
+func (a *API) handleSessionTagDelete(w http.ResponseWriter, r *http.Request, sessionID, tag string) {
+	if r.Method != http.MethodDelete {
+		a.sendMethodNotAllowed(w, r)
+		return
+	}
+
+	if err := a.memory.RemoveTags(sessionID, []string{tag}); err != nil {
+		a.sendError(w, r, fmt.Sprintf("Failed to remove tag: %v", err))
+		return
+	}
+
+	a.sendSessionTags(w, r, sessionID)
+}
+
+// sendSessionTags sends the current tags of a session
+func (a *API) sendSessionTags(w http.ResponseWriter, r *http.Request, sessionID string) {
+	tags, err := a.memory.GetTags(sessionID)
+	if err != nil {
+		a.sendError(w, r, fmt.Sprintf("Failed to get tags: %v", err))
+		return
+	}
+
+	response := Response{
+		Success: true,
+		Data: map[string]interface{}{
+			"session_id": sessionID,
+			"tags":       tags,
+		},
+	}
+
+	json.NewEncoder(w).Encode(response)
+}
+
+// handleSessionTransfer handles session transfer between platforms. The
+// API has no notion of the user owning a session, so this requires the
+// admin token.
+func (a *API) handleSessionTransfer(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		a.sendMethodNotAllowed(w, r)
+		return
+	}
+	if !a.requireAdmin(w, r) {
+		return
+	}
+
+	var request struct {
+		From string `json:"from"`
+		To   string `json:"to"`
+	}
+
+	err := json.NewDecoder(r.Body).Decode(&request)
+	if err != nil {
+		a.sendRequestError(w, r, err)
+		return
+	}
+
+	if request.From == "" || request.To == "" {
+		a.sendError(w, r, "From and to are required")
+		return
+	}
+
+	err = a.memory.TransferSession(request.From, request.To)
+	if errors.Is(err, ErrSessionNotFound) {
+		a.sendErrorStatus(w, r, http.StatusNotFound, err.Error())
+		return
+	}
+	if errors.Is(err, ErrSessionExists) {
+		a.sendErrorStatus(w, r, http.StatusConflict, err.Error())
+		return
+	}
+	if err != nil {
+		a.sendError(w, r, fmt.Sprintf("Failed to transfer session: %v", err))
+		return
+	}
+
+	response := Response{
+		Success: true,
+		Data: map[string]interface{}{
+			"from": request.From,
+			"to":   request.To,
+		},
+	}
+
+	json.NewEncoder(w).Encode(response)
+}
+
+// handleCache handles response cache invalidation, which requires the
+// admin token
+func (a *API) handleCache(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if r.Method != http.MethodDelete {
+		a.sendMethodNotAllowed(w, r)
+		return
+	}
+	if !a.requireAdmin(w, r) {
+		return
+	}
+
+	path := strings.Trim(r.URL.Path[len("/api/v1/cache/"):], "/")
+
+	var evicted int
+	switch {
+	case path == "all":
+		evicted = a.agent.ClearCache()
+	case strings.HasPrefix(path, "session/") && len(path) > len("session/"):
+		evicted = a.agent.InvalidateCache(path[len("session/"):])
+	default:
+		a.sendNotFound(w, r)
+		return
+	}
+
+	response := Response{
+		Success: true,
+		Data: map[string]interface{}{
+			"evicted": evicted,
+		},
+	}
+
+	json.NewEncoder(w).Encode(response)
+}
+
+// handleTools handles dynamic tool management, which requires the admin
+// token. Script tools run shell commands on the host.
+func (a *API) handleTools(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if !a.requireAdmin(w, r) {
+		return
+	}
+
+	name := strings.Trim(r.URL.Path[len("/api/v1/tools/"):], "/")
+	if name == "" {
+		a.sendNotFound(w, r)
+		return
+	}
+
+	if name == "register" {
+		if r.Method != http.MethodPost {
+			a.sendMethodNotAllowed(w, r)
+			return
+		}
+		a.handleToolRegister(w, r)
+		return
+	}
+
+	registry := a.agent.ToolRegistry()
+
+	switch r.Method {
+	case http.MethodGet:
+		tool := registry.Get(name)
+		if tool == nil {
+			a.sendNotFound(w, r)
+			return
+		}
+
+		var params []ToolParam
+		if parameterized, ok := tool.(ParameterizedTool); ok {
+			params = parameterized.Params()
+		}
+
+		response := Response{
+			Success: true,
+			Data: map[string]interface{}{
+				"name":        tool.Name(),
+				"description": tool.Description(),
+				"params":      params,
+			},
+		}
+
+		json.NewEncoder(w).Encode(response)
+
+	case http.MethodDelete:
+		removed, err := registry.UnregisterScript(name)
+		if errors.Is(err, ErrBuiltinTool) {
+			a.sendErrorStatus(w, r, http.StatusForbidden, "Built-in tools cannot be unregistered")
+			return
+		}
+		if !removed {
+			a.sendNotFound(w, r)
+			return
+		}
+
+		err = RemoveScriptDefinition(a.agent.Config().Tools.DefinitionsDir, name)
+		if err != nil {
+			log.Printf("Failed to remove tool definition %s: %v", name, err)
+		}
+
+		response := Response{
+			Success: true,
+			Data: map[string]interface{}{
+				"action": "unregister",
+				"name":   name,
+			},
+		}
+
+		json.NewEncoder(w).Encode(response)
+
+	default:
+		a.sendMethodNotAllowed(w, r)
+	}
+}
+
+// handleToolRegister registers a script tool in the live tool registry.
+// Built-in tools cannot be replaced.
+func (a *API) handleToolRegister(w http.ResponseWriter, r *http.Request) {
+	var definition ScriptDefinition
+
+	err := json.NewDecoder(r.Body).Decode(&definition)
+	if err != nil {
+		a.sendRequestError(w, r, err)
+		return
+	}
+
+	tool, err := NewScriptTool(definition)
+	if err != nil {
+		a.sendError(w, r, fmt.Sprintf("Invalid tool: %v", err))
+		return
+	}
+
+	registry := a.agent.ToolRegistry()
+	if existing := registry.Get(definition.Name); existing != nil {
+		if _, ok := existing.(*ScriptTool); !ok {
+			a.sendErrorStatus(w, r, http.StatusConflict, fmt.Sprintf("Tool %s is built in", definition.Name))
+			return
+		}
+	}
+
+	if definition.Persist {
+		err = SaveScriptDefinition(a.agent.Config().Tools.DefinitionsDir, definition)
+		if err != nil {
+			a.sendError(w, r, fmt.Sprintf("Failed to persist tool: %v", err))
+			return
+		}
+	}
+
+	if err := registry.RegisterScript(tool); err != nil {
+		a.sendErrorStatus(w, r, http.StatusConflict, fmt.Sprintf("Tool %s is built in", definition.Name))
+		return
+	}
+
+	response := Response{
+		Success: true,
+		Data: map[string]interface{}{
+			"action":    "register",
+			"name":      definition.Name,
+			"persisted": definition.Persist,
+		},
+	}
+
+	json.NewEncoder(w).Encode(response)
+}
+
+// handlePrompts handles system prompt template endpoints. Activating a
+// template changes the prompt of every session, so it requires the admin
+// token.
+func (a *API) handlePrompts(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	library := a.agent.PromptLibrary()
+	if library == nil {
+		a.sendNotFound(w, r)
+		return
+	}
+
+	path := strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/v1/prompts"), "/")
+	parts := strings.Split(path, "/")
+
+	switch {
+	case path == "":
+		if r.Method != http.MethodGet {
+			a.sendMethodNotAllowed(w, r)
+			return
+		}
+
+		response := Response{
+			Success: true,
+			Data: map[string]interface{}{
+				"templates": library.Names(),
+				"active":    library.Active(),
+			},
+		}
+
+		json.NewEncoder(w).Encode(response)
+
+	case len(parts) == 1:
+		if r.Method != http.MethodGet {
+			a.sendMethodNotAllowed(w, r)
+			return
+		}
+
+		source, ok := library.Source(parts[0])
+		if !ok {
+			a.sendNotFound(w, r)
+			return
+		}
+
+		response := Response{
+			Success: true,
+			Data: map[string]interface{}{
+				"name":    parts[0],
+				"content": source,
+				"active":  library.Active() == parts[0],
+			},
+		}
+
+		json.NewEncoder(w).Encode(response)
+
+	case len(parts) == 2 && parts[1] == "activate":
+		if r.Method != http.MethodPost {
+			a.sendMethodNotAllowed(w, r)
+			return
+		}
+		if !a.requireAdmin(w, r) {
+			return
+		}
+
+		if _, ok := library.Source(parts[0]); !ok {
+			a.sendNotFound(w, r)
+			return
+		}
+
+		if err := a.agent.SetPromptTemplate(parts[0]); err != nil {
+			a.sendError(w, r, fmt.Sprintf("Failed to activate template: %v", err))
+			return
+		}
+
+		response := Response{
+			Success: true,
+			Data: map[string]interface{}{
+				"action": "activate",
+				"name":   parts[0],
+			},
+		}
+
+		json.NewEncoder(w).Encode(response)
+
+	default:
+		a.sendNotFound(w, r)
+	}
+}
+
+// handleTasks handles tasks endpoint
+func (a *API) handleTasks(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	switch r.Method {
+	case http.MethodGet:
+		tasks, err := a.scheduler.GetAllTasks()
+		if err != nil {
+			a.sendError(w, r, fmt.Sprintf("Failed to get tasks: %v", err))
+			return
+		}
+
+		response := Response{
+			Success: true,
+			Data: map[string]interface{}{
+				"count": len(tasks),
+				"tasks": tasks,
+			},
+		}
+
+		json.NewEncoder(w).Encode(response)
+
+	case http.MethodPost:
+		var request struct {
+			Name                 string                 `json:"name"`
+			SessionID            string                 `json:"session_id"`
+			RunAt                string                 `json:"run_at"`
+			Timezone             string                 `json:"timezone,omitempty"`
+			NotificationPlatform string                 `json:"notification_platform,omitempty"`
+			Payload              map[string]interface{} `json:"payload"`
+			DependsOn            []string               `json:"depends_on,omitempty"`
+		}
+
+		err := json.NewDecoder(r.Body).Decode(&request)
+		if err != nil {
+			a.sendRequestError(w, r, err)
+			return
+		}
+
+		if request.Name == "" || request.SessionID == "" || request.RunAt == "" {
+			a.sendError(w, r, "Name, session_id and run_at are required")
+			return
+		}
+
+		runAt, warning, err := a.scheduler.ParseTime(request.RunAt, request.Timezone)
+		if err != nil {
+			a.sendError(w, r, err.Error())
+			return
+		}
+
+		taskID, err := a.scheduler.AddTask(request.Name, request.SessionID, request.Payload, runAt,
+			request.Timezone, request.NotificationPlatform, request.DependsOn)
+		if err != nil {
+			a.sendError(w, r, fmt.Sprintf("Failed to add task: %v", err))
+			return
+		}
+
+		data := map[string]interface{}{
+			"id":       taskID,
+			"next_run": runAt.UTC(),
+		}
+		if warning != "" {
+			data["warning"] = warning
+		}
+
+		response := Response{
+			Success: true,
+			Data:    data,
+		}
+
+		json.NewEncoder(w).Encode(response)
+
+	default:
+		a.sendMethodNotAllowed(w, r)
+	}
+}
+
+// handleTask handles the endpoints of a single task, <id>/history, and
+// the dead letter endpoints: dead-letter and dead-letter/<id>/retry
+func (a *API) handleTask(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	parts := strings.Split(strings.Trim(r.URL.Path[len("/api/v1/tasks/"):], "/"), "/")
+	switch {
+	case len(parts) == 1 && parts[0] == "dead-letter":
+		a.handleDeadLetterList(w, r)
+	case len(parts) == 3 && parts[0] == "dead-letter" && parts[1] != "" && parts[2] == "retry":
+		a.handleDeadLetterRetry(w, r, parts[1])
+	case len(parts) == 2 && parts[0] != "" && parts[1] == "history":
+		a.handleTaskHistory(w, r, parts[0])
+	default:
+		a.sendNotFound(w, r)
+	}
+}
+
+// handleDeadLetterList handles GET /api/v1/tasks/dead-letter
+func (a *API) handleDeadLetterList(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		a.sendMethodNotAllowed(w, r)
+		return
+	}
+
+	tasks, err := a.scheduler.GetDeadLetterTasks()
+	if err != nil {
+		a.sendErrorStatus(w, r, http.StatusInternalServerError, fmt.Sprintf("Failed to get dead letter tasks: %v", err))
+		return
+	}
+
+	response := Response{
+		Success: true,
+		Data: map[string]interface{}{
+			"tasks": tasks,
+			"count": len(tasks),
+		},
+	}
+
+	json.NewEncoder(w).Encode(response)
+}
+
+// handleDeadLetterRetry handles POST /api/v1/tasks/dead-letter/<id>/retry
+func (a *API) handleDeadLetterRetry(w http.ResponseWriter, r *http.Request, id string) {
+	if r.Method != http.MethodPost {
+		a.sendMethodNotAllowed(w, r)
+		return
+	}
+
+	if err := a.scheduler.RetryDeadLetterTask(id); err != nil {
+		a.sendError(w, r, fmt.Sprintf("Failed to retry task: %v", err))
+		return
+	}
+
+	response := Response{
+		Success: true,
+		Data: map[string]interface{}{
+			"id":      id,
+			"retried": true,
+		},
+	}
+
+	json.NewEncoder(w).Encode(response)
+}
+
+// handleTaskHistory handles GET /api/v1/tasks/<id>/history
+func (a *API) handleTaskHistory(w http.ResponseWriter, r *http.Request, taskID string) {
+	if r.Method != http.MethodGet {
+		a.sendMethodNotAllowed(w, r)
+		return
+	}
+
+	limit := 0
+	if value := r.URL.Query().Get("limit"); value != "" {
+		var err error
+		limit, err = strconv.Atoi(value)
+		if err != nil || limit < 0 {
+			a.sendError(w, r, fmt.Sprintf("Invalid limit: %s", value))
+			return
+		}
+	}
+
+	history, err := a.scheduler.GetTaskHistory(taskID, limit)
+	if err != nil {
+		a.sendErrorStatus(w, r, http.StatusInternalServerError, fmt.Sprintf("Failed to get task history: %v", err))
+		return
+	}
+
+	response := Response{
+		Success: true,
+		Data: map[string]interface{}{
+			"task_id":    taskID,
+			"executions": history,
+			"count":      len(history),
+		},
+	}
+
+	json.NewEncoder(w).Encode(response)
+}
+
+// handleTaskGraph returns the task dependency graph
+func (a *API) handleTaskGraph(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if r.Method != http.MethodGet {
+		a.sendMethodNotAllowed(w, r)
+		return
+	}
+
+	graph := a.scheduler.GetTaskGraph()
+	if graph == nil {
+		a.sendErrorStatus(w, r, http.StatusInternalServerError, "Failed to load task graph")
+		return
+	}
+
+	response := Response{
+		Success: true,
+		Data: map[string]interface{}{
+			"graph": graph,
+			"valid": a.scheduler.ValidateDependencies() == nil,
+		},
+	}
+
+	json.NewEncoder(w).Encode(response)
+}
+
+// handleSchedulerStats handles GET /api/v1/scheduler/stats
+func (a *API) handleSchedulerStats(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if r.Method != http.MethodGet {
+		a.sendMethodNotAllowed(w, r)
+		return
+	}
+
+	stats, err := a.scheduler.Stats()
+	if err != nil {
+		a.sendErrorStatus(w, r, http.StatusInternalServerError, fmt.Sprintf("Failed to get scheduler stats: %v", err))
+		return
+	}
+
+	json.NewEncoder(w).Encode(Response{Success: true, Data: stats})
+}
+
+// handleUsage handles GET /api/v1/usage
+func (a *API) handleUsage(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if r.Method != http.MethodGet {
+		a.sendMethodNotAllowed(w, r)
+		return
+	}
+
+	json.NewEncoder(w).Encode(Response{Success: true, Data: a.agent.TokenUsage()})
+}
+
+// handleCacheStats handles GET /api/v1/cache/stats
+func (a *API) handleCacheStats(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if r.Method != http.MethodGet {
+		a.sendMethodNotAllowed(w, r)
+		return
+	}
+
+	json.NewEncoder(w).Encode(Response{Success: true, Data: a.agent.CacheStats()})
+}
+
+// handleSchedulerOverdue handles GET /api/v1/scheduler/overdue
+func (a *API) handleSchedulerOverdue(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if r.Method != http.MethodGet {
+		a.sendMethodNotAllowed(w, r)
+		return
+	}
+
+	tasks, err := a.scheduler.GetOverdueTasks()
+	if err != nil {
+		a.sendErrorStatus(w, r, http.StatusInternalServerError, fmt.Sprintf("Failed to get overdue tasks: %v", err))
+		return
+	}
+
+	response := Response{
+		Success: true,
+		Data: map[string]interface{}{
+			"tasks": tasks,
+			"count": len(tasks),
+		},
+	}
+
+	json.NewEncoder(w).Encode(response)
+}
+
+// handleStatus handles status endpoint
+func (a *API) handleStatus(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		a.sendMethodNotAllowed(w, r)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+
+	response := Response{
+		Success: true,
+		Data: map[string]interface{}{
+			"bot":        a.agent.Config().Bot.Name,
+			"ai_provider": a.agent.Config().AI.Provider,
+			"ai_model":   a.agent.Config().AI.Model,
+			"uptime":     a.agent.Uptime().String(),
+			"metrics":    a.agent.Metrics().Snapshot(),
+			"state":      a.agent.State(),
+			"retry_budget": a.agent.RetryBudgetUsage(),
+		},
+	}
+
+	json.NewEncoder(w).Encode(response)
+}
+
+// sendError sends error response
+func (a *API) sendError(w http.ResponseWriter, r *http.Request, message string) {
+	a.sendErrorStatus(w, r, http.StatusBadRequest, message)
+}
+
+// sendErrorStatus sends error response with a status code
+func (a *API) sendErrorStatus(w http.ResponseWriter, r *http.Request, status int, message string) {
+	response := Response{
+		Success:   false,
+		Error:     message,
+		RequestID: types.RequestIDFromContext(r.Context()),
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(response)
+}
+
+// sendRequestError sends the error response for a request body that could
+// not be decoded: 413 if it exceeded the size limit, 400 otherwise
+func (a *API) sendRequestError(w http.ResponseWriter, r *http.Request, err error) {
+	var maxBytesErr *http.MaxBytesError
+	if errors.As(err, &maxBytesErr) {
+		a.sendErrorStatus(w, r, http.StatusRequestEntityTooLarge,
+			fmt.Sprintf("Request body too large (max %d bytes)", maxBytesErr.Limit))
+		return
+	}
+	a.sendError(w, r, fmt.Sprintf("Invalid request: %v", err))
+}
+
+// sendMethodNotAllowed sends method not allowed response
+func (a *API) sendMethodNotAllowed(w http.ResponseWriter, r *http.Request) {
+	response := Response{
+		Success:   false,
+		Error:     "Method not allowed",
+		RequestID: types.RequestIDFromContext(r.Context()),
+	}
+
+	w.WriteHeader(http.StatusMethodNotAllowed)
+	json.NewEncoder(w).Encode(response)
+}
+
+// sendNotFound sends not found response
+func (a *API) sendNotFound(w http.ResponseWriter, r *http.Request) {
+	response := Response{
+		Success:   false,
+		Error:     "Not found",
+		RequestID: types.RequestIDFromContext(r.Context()),
+	}
+
+	w.WriteHeader(http.StatusNotFound)
+	json.NewEncoder(w).Encode(response)
+}
+
+// TestAPI tests the API module
+func TestAPI() {
+	log.Println("Testing API module...")
+
+	// Create test components
+	config := GetDefaultConfig()
+	memory, _ := NewMemory("test_api_memory.db", 100)
+	scheduler, _ := NewScheduler("test_api_scheduler.db")
+	agent := NewSimpleAgent(config, NewSimpleMemory(100), NewSimpleScheduler())
+
+	// Create API instance
+	api := NewAPI(nil, nil, nil, 8080)
 
 	log.Println("✓ API module initialized")
 
@@ -304,3 +2087,261 @@ func TestAPI() {
 
 	log.Println("✓ API module tests passed")
 }
+
+// toolEchoProvider calls a tool once and answers with the tool's result,
+// as a model relaying it would
+type toolEchoProvider struct {
+	tool      string
+	arguments string
+}
+
+func (p *toolEchoProvider) ProviderName() string {
+	return "tool-echo"
+}
+
+func (p *toolEchoProvider) ChatCompletion(ctx context.Context, messages []Message) (string, error) {
+	completion, err := p.ToolCallCompletion(ctx, messages, nil)
+	return completion.Content, err
+}
+
+func (p *toolEchoProvider) ToolCallCompletion(ctx context.Context, messages []Message, tools []types.ToolSpec) (types.Completion, error) {
+	if last := messages[len(messages)-1]; last.Role == "tool" {
+		return types.Completion{Content: "The tool said: " + last.Content, FinishReason: "stop"}, nil
+	}
+	return types.Completion{
+		ToolCalls:    []types.ToolCall{{ID: "call_1", Name: p.tool, Arguments: p.arguments}},
+		FinishReason: "tool_calls",
+	}, nil
+}
+
+// TestToolRegistration registers a script tool through the API and checks
+// that a chat message can call it
+func TestToolRegistration() error {
+	log.Println("Testing tool registration...")
+
+	config := DefaultConfig()
+	config.API.AdminToken = "secret"
+	dryRun, err := NewDryRunAgent(config)
+	if err != nil {
+		return err
+	}
+	defer dryRun.Close()
+	dryRun.aiProvider = &toolEchoProvider{tool: "greet", arguments: `{"name": "QuickBot"}`}
+
+	api := NewAPI(dryRun.Agent, dryRun.Memory(), nil, 0)
+
+	request := func(handler http.HandlerFunc, method, path, token, body string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(method, path, strings.NewReader(body))
+		if token != "" {
+			r.Header.Set("Authorization", "Bearer "+token)
+		}
+		w := httptest.NewRecorder()
+		handler(w, r)
+		return w
+	}
+
+	definition := `{
+		"name": "greet",
+		"description": "Greets someone",
+		"script": "echo \"hello, {{name}}\"",
+		"params": [{"name": "name", "type": "string", "required": true}]
+	}`
+	if w := request(api.handleTools, http.MethodPost, "/api/v1/tools/register", "", definition); w.Code != http.StatusUnauthorized {
+		return fmt.Errorf("register without token: expected 401, got %d", w.Code)
+	}
+	if w := request(api.handleTools, http.MethodPost, "/api/v1/tools/register", "secret", definition); w.Code != http.StatusOK {
+		return fmt.Errorf("register: expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if w := request(api.handleTools, http.MethodGet, "/api/v1/tools/greet", "secret", ""); w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "Greets someone") {
+		return fmt.Errorf("get tool: unexpected response %d: %s", w.Code, w.Body.String())
+	}
+	log.Println("✓ Tool registered through the API")
+
+	w := request(api.handleChat, http.MethodPost, "/api/v1/chat", "", `{"session_id": "tools", "message": "say hello"}`)
+	if w.Code != http.StatusOK {
+		return fmt.Errorf("chat: expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var response struct {
+		Success bool `json:"success"`
+		Data    struct {
+			Response string `json:"response"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil || !response.Success {
+		return fmt.Errorf("invalid chat response: %s", w.Body.String())
+	}
+	if !strings.Contains(response.Data.Response, "hello, QuickBot") {
+		return fmt.Errorf("expected the tool result in the response, got %q", response.Data.Response)
+	}
+	log.Println("✓ Registered tool called from chat")
+
+	if w := request(api.handleTools, http.MethodDelete, "/api/v1/tools/greet", "secret", ""); w.Code != http.StatusOK {
+		return fmt.Errorf("unregister: expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if dryRun.ToolRegistry().Get("greet") != nil {
+		return fmt.Errorf("tool still registered after delete")
+	}
+	log.Println("✓ Tool unregistered")
+
+	return nil
+}
+
+// TestChatBatch sends a sequential batch and checks that the responses
+// are in order and that every message is kept in the session history
+func TestChatBatch() error {
+	log.Println("Testing chat batches...")
+
+	dryRun, err := NewDryRunAgent(DefaultConfig())
+	if err != nil {
+		return err
+	}
+	defer dryRun.Close()
+	api := NewAPI(dryRun.Agent, dryRun.Memory(), nil, 0)
+
+	for i := 1; i <= 5; i++ {
+		dryRun.Provider().QueueResponse(fmt.Sprintf("a%d", i))
+	}
+
+	body := `{"session_id": "batch", "messages": ["q1", "q2", "q3", "q4", "q5"], "parallel": false}`
+	r := httptest.NewRequest(http.MethodPost, "/api/v1/chat/batch", strings.NewReader(body))
+	w := httptest.NewRecorder()
+	api.handleChatBatch(w, r)
+	if w.Code != http.StatusOK {
+		return fmt.Errorf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	var response struct {
+		Success bool `json:"success"`
+		Data    struct {
+			Results []BatchResult `json:"results"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil || !response.Success {
+		return fmt.Errorf("invalid batch response: %s", w.Body.String())
+	}
+	if len(response.Data.Results) != 5 {
+		return fmt.Errorf("expected 5 results, got %d", len(response.Data.Results))
+	}
+	for i, result := range response.Data.Results {
+		if result.Message != fmt.Sprintf("q%d", i+1) || result.Response != fmt.Sprintf("a%d", i+1) || result.Error != "" {
+			return fmt.Errorf("result %d out of order: %+v", i, result)
+		}
+	}
+	log.Println("✓ Batch responses in order")
+
+	history, err := dryRun.Memory().GetMessagesBetween("batch", 0, 0)
+	if err != nil {
+		return err
+	}
+	if len(history) != 10 {
+		return fmt.Errorf("expected 10 messages in the session history, got %d", len(history))
+	}
+	for i, message := range history {
+		role, content := "user", fmt.Sprintf("q%d", i/2+1)
+		if i%2 == 1 {
+			role, content = "assistant", fmt.Sprintf("a%d", i/2+1)
+		}
+		if message.Role != role || message.Content != content {
+			return fmt.Errorf("history message %d: expected %s %q, got %s %q", i, role, content, message.Role, message.Content)
+		}
+	}
+
+	// Each message was sent with the conversation so far
+	last := dryRun.Provider().LastCall()
+	var seen []string
+	for _, message := range last {
+		if message.Role == "user" || message.Role == "assistant" {
+			seen = append(seen, message.Content)
+		}
+	}
+	if strings.Join(seen, ",") != "q1,a1,q2,a2,q3,a3,q4,a4,q5" {
+		return fmt.Errorf("expected the last message to be sent with the batch history, got %v", seen)
+	}
+	log.Println("✓ Session history has all 10 messages")
+
+	return nil
+}
+
+// TestGracefulShutdown stops the API while a slow request is in flight
+// and checks that the client still receives its response
+func TestGracefulShutdown() error {
+	log.Println("Testing graceful shutdown...")
+
+	api := NewAPI(nil, nil, nil, 0)
+	started := make(chan struct{})
+	slow := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/slow" {
+			close(started)
+			time.Sleep(300 * time.Millisecond)
+		}
+		io.WriteString(w, "done")
+	})
+
+	server := httptest.NewServer(api.trackInFlight(slow))
+	defer server.Close()
+	api.httpServer = server.Config
+
+	type result struct {
+		status int
+		body   string
+		err    error
+	}
+	slowResult := make(chan result, 1)
+	go func() {
+		resp, err := http.Get(server.URL + "/slow")
+		if err != nil {
+			slowResult <- result{err: err}
+			return
+		}
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		slowResult <- result{status: resp.StatusCode, body: string(body), err: err}
+	}()
+
+	select {
+	case <-started:
+	case <-time.After(2 * time.Second):
+		return fmt.Errorf("slow request did not start")
+	}
+	if inFlight := api.InFlight(); inFlight != 1 {
+		return fmt.Errorf("expected 1 request in flight, got %d", inFlight)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	stopped := make(chan error, 1)
+	go func() {
+		stopped <- api.Stop(ctx)
+	}()
+
+	// New requests are turned away while the slow one finishes
+	time.Sleep(50 * time.Millisecond)
+	resp, err := http.Get(server.URL + "/fast")
+	if err != nil {
+		return fmt.Errorf("expected a request during shutdown to be answered: %w", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusServiceUnavailable {
+		return fmt.Errorf("expected 503 during shutdown, got %d", resp.StatusCode)
+	}
+	log.Println("✓ New requests rejected while draining")
+
+	res := <-slowResult
+	if res.err != nil || res.status != http.StatusOK || res.body != "done" {
+		return fmt.Errorf("expected the in-flight request to complete, got %d %q: %v", res.status, res.body, res.err)
+	}
+	select {
+	case err := <-stopped:
+		if err != nil {
+			return fmt.Errorf("expected a clean shutdown, got %w", err)
+		}
+	case <-time.After(2 * time.Second):
+		return fmt.Errorf("Stop did not return after the in-flight request finished")
+	}
+	if _, err := http.Get(server.URL + "/fast"); err == nil {
+		return fmt.Errorf("expected the server to be shut down")
+	}
+	log.Println("✓ In-flight request completed before shutdown")
+
+	return nil
+}
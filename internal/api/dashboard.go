@@ -0,0 +1,313 @@
+package main
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Chang-Augenweide/QuickBot-Go/internal/events"
+)
+
+// dashboardCacheTTL is how long a dashboard is served before it is
+// rebuilt
+const dashboardCacheTTL = 5 * time.Second
+
+// Dashboard is the aggregated system state shown to admins
+type Dashboard struct {
+	Bot             string                        `json:"bot"`
+	Uptime          string                        `json:"uptime"`
+	State           AgentState                    `json:"state"`
+	AIProvider      string                        `json:"ai_provider"`
+	AIModel         string                        `json:"ai_model"`
+	Sessions        int                           `json:"sessions"`
+	Messages        int                           `json:"messages"`
+	LongTermEntries int                           `json:"long_term_entries"`
+	Tasks           DashboardTasks                `json:"tasks"`
+	Scheduler       SchedulerStats                `json:"scheduler"`
+	QueueDepth      int                           `json:"queue_depth"`
+	Tools           map[string]DashboardToolStats `json:"tools"`
+	DatabaseBytes   int64                         `json:"database_bytes"`
+	Goroutines      int                           `json:"goroutines"`
+	Metrics         map[string]int64              `json:"metrics"`
+	AuditLog        []AuditEntry                  `json:"audit_log"`
+	GeneratedAt     time.Time                     `json:"generated_at"`
+}
+
+// DashboardTasks counts scheduled tasks by outcome. Pending tasks are
+// still scheduled; completed and failed ones are in the task history.
+type DashboardTasks struct {
+	Pending   int `json:"pending"`
+	Completed int `json:"completed"`
+	Failed    int `json:"failed"`
+}
+
+// DashboardToolStats is the usage of a registered tool
+type DashboardToolStats struct {
+	Calls     int64   `json:"calls"`
+	Errors    int64   `json:"errors"`
+	ErrorRate float64 `json:"error_rate"`
+}
+
+// AuditEntry is an event recorded in the audit log
+type AuditEntry struct {
+	Topic     string      `json:"topic"`
+	Payload   interface{} `json:"payload"`
+	Timestamp time.Time   `json:"timestamp"`
+}
+
+// auditLog keeps the most recent events published on the event bus
+type auditLog struct {
+	mu      sync.Mutex
+	entries []AuditEntry
+	size    int
+}
+
+// newAuditLog records the last size events published on bus
+func newAuditLog(bus *events.EventBus, size int) *auditLog {
+	audit := &auditLog{size: size}
+	bus.Subscribe(events.TopicAll, audit.record)
+	return audit
+}
+
+// record adds an event, dropping the oldest entry when the log is full
+func (l *auditLog) record(event events.Event) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.entries = append(l.entries, AuditEntry{
+		Topic:     event.Topic,
+		Payload:   event.Payload,
+		Timestamp: event.Timestamp,
+	})
+	if len(l.entries) > l.size {
+		l.entries = l.entries[len(l.entries)-l.size:]
+	}
+}
+
+// Entries returns the recorded events, newest first
+func (l *auditLog) Entries() []AuditEntry {
+	entries := []AuditEntry{}
+	if l == nil {
+		return entries
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for i := len(l.entries) - 1; i >= 0; i-- {
+		entries = append(entries, l.entries[i])
+	}
+	return entries
+}
+
+// requireAdmin checks the admin bearer token of a request and sends an
+// error response if it is missing or wrong
+func (a *API) requireAdmin(w http.ResponseWriter, r *http.Request) bool {
+	token := a.agent.Config().API.AdminToken
+	if token == "" {
+		a.sendErrorStatus(w, r, http.StatusForbidden, "Admin API disabled")
+		return false
+	}
+
+	given := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if subtle.ConstantTimeCompare([]byte(given), []byte(token)) != 1 {
+		a.sendErrorStatus(w, r, http.StatusUnauthorized, "Unauthorized")
+		return false
+	}
+	return true
+}
+
+// handleAdminDashboard handles GET /api/v1/admin/dashboard
+func (a *API) handleAdminDashboard(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if r.Method != http.MethodGet {
+		a.sendMethodNotAllowed(w, r)
+		return
+	}
+	if !a.requireAdmin(w, r) {
+		return
+	}
+
+	dashboard, err := a.getDashboard()
+	if err != nil {
+		a.sendErrorStatus(w, r, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	json.NewEncoder(w).Encode(Response{Success: true, Data: dashboard})
+}
+
+// getDashboard returns the cached dashboard, rebuilding it once it is
+// older than dashboardCacheTTL
+func (a *API) getDashboard() (*Dashboard, error) {
+	a.dashboardMu.Lock()
+	defer a.dashboardMu.Unlock()
+
+	if a.dashboard != nil && time.Since(a.dashboard.GeneratedAt) < dashboardCacheTTL {
+		return a.dashboard, nil
+	}
+
+	dashboard, err := a.buildDashboard()
+	if err != nil {
+		return nil, err
+	}
+	a.dashboard = dashboard
+	return dashboard, nil
+}
+
+// buildDashboard collects the dashboard from the agent, memory, scheduler
+// and tool registry
+func (a *API) buildDashboard() (*Dashboard, error) {
+	config := a.agent.Config()
+
+	analytics, err := a.memory.GetAnalytics()
+	if err != nil {
+		return nil, err
+	}
+
+	tasks, err := a.scheduler.GetAllTasks()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get tasks: %w", err)
+	}
+	history, err := a.scheduler.GetHistoryCounts()
+	if err != nil {
+		return nil, err
+	}
+	schedulerStats, err := a.scheduler.Stats()
+	if err != nil {
+		return nil, err
+	}
+
+	registry := a.agent.ToolRegistry()
+	usage := registry.Usage()
+	tools := make(map[string]DashboardToolStats)
+	for name := range registry.GetAll() {
+		u := usage[name]
+		tools[name] = DashboardToolStats{
+			Calls:     u.Calls,
+			Errors:    u.Errors,
+			ErrorRate: u.ErrorRate(),
+		}
+	}
+
+	return &Dashboard{
+		Bot:             config.Bot.Name,
+		Uptime:          a.agent.Uptime().Round(time.Second).String(),
+		State:           a.agent.State(),
+		AIProvider:      config.AI.Provider,
+		AIModel:         config.AI.Model,
+		Sessions:        analytics.Sessions,
+		Messages:        analytics.Messages,
+		LongTermEntries: analytics.LongTermEntries,
+		Tasks: DashboardTasks{
+			Pending:   len(tasks),
+			Completed: history[HistoryCompleted],
+			Failed:    history[HistoryFailed],
+		},
+		Scheduler:     schedulerStats,
+		QueueDepth:    len(a.scheduler.GetDueTasks()),
+		Tools:         tools,
+		DatabaseBytes: analytics.DatabaseBytes,
+		Goroutines:    runtime.NumGoroutine(),
+		Metrics:       a.agent.Metrics().Snapshot(),
+		AuditLog:      a.audit.Entries(),
+		GeneratedAt:   time.Now(),
+	}, nil
+}
+
+// TestDashboard requests the admin dashboard and checks that the response
+// has every field
+func TestDashboard() error {
+	fmt.Println("Testing Dashboard...")
+
+	dir, err := os.MkdirTemp("", "quickbot-dashboard")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+
+	memory, err := NewMemory(filepath.Join(dir, "memory.db"), 100)
+	if err != nil {
+		return err
+	}
+	defer memory.Close()
+	scheduler, err := NewScheduler(filepath.Join(dir, "scheduler.db"))
+	if err != nil {
+		return err
+	}
+	defer scheduler.Stop()
+
+	config := DefaultConfig()
+	config.API.AdminToken = "secret"
+	api := NewAPI(NewAgent(config, memory, scheduler), memory, scheduler, 0)
+
+	memory.CreateSession("s1", "Dashboard", "api", "u1")
+	memory.AddMessage("s1", "user", "hello", nil)
+
+	request := func(token string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodGet, "/api/v1/admin/dashboard", nil)
+		if token != "" {
+			r.Header.Set("Authorization", "Bearer "+token)
+		}
+		w := httptest.NewRecorder()
+		api.handleAdminDashboard(w, r)
+		return w
+	}
+
+	if w := request("wrong"); w.Code != http.StatusUnauthorized {
+		return fmt.Errorf("wrong token: expected 401, got %d", w.Code)
+	}
+	fmt.Println("✓ Dashboard requires admin token")
+
+	w := request("secret")
+	if w.Code != http.StatusOK {
+		return fmt.Errorf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	var response struct {
+		Success bool            `json:"success"`
+		Data    json.RawMessage `json:"data"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil || !response.Success {
+		return fmt.Errorf("invalid response: %s", w.Body.String())
+	}
+
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(response.Data, &fields); err != nil {
+		return fmt.Errorf("failed to parse dashboard: %w", err)
+	}
+	for _, field := range []string{"bot", "uptime", "state", "ai_provider", "ai_model", "sessions",
+		"messages", "long_term_entries", "tasks", "scheduler", "queue_depth", "tools", "database_bytes",
+		"goroutines", "metrics", "audit_log", "generated_at"} {
+		if _, ok := fields[field]; !ok {
+			return fmt.Errorf("dashboard missing field %s", field)
+		}
+	}
+
+	var dashboard Dashboard
+	if err := json.Unmarshal(response.Data, &dashboard); err != nil {
+		return fmt.Errorf("failed to parse dashboard: %w", err)
+	}
+	if dashboard.Sessions != 1 || dashboard.Messages != 1 || dashboard.DatabaseBytes == 0 || dashboard.Goroutines == 0 {
+		return fmt.Errorf("unexpected dashboard: %+v", dashboard)
+	}
+	fmt.Println("✓ Dashboard has all fields")
+
+	memory.AddMessage("s1", "user", "again", nil)
+	cached, err := api.getDashboard()
+	if err != nil || cached.Messages != 1 || !cached.GeneratedAt.Equal(dashboard.GeneratedAt) {
+		return fmt.Errorf("dashboard not cached: %+v %v", cached, err)
+	}
+	fmt.Println("✓ Dashboard cached")
+
+	return nil
+}
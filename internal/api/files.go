@@ -0,0 +1,406 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// UploadedFile describes a file stored by the upload endpoint
+type UploadedFile struct {
+	Filename string `json:"filename"`
+	Size     int64  `json:"size"`
+	Path     string `json:"path"`
+}
+
+// uploadMemoryBytes is how much of a multipart form is kept in memory
+// before parts are spooled to temporary files
+const uploadMemoryBytes = 8 * 1024 * 1024
+
+// handleFileUpload stores multipart file uploads in the tools directory,
+// under <session_id>/<filename>. A gzip Content-Encoding on the request or
+// on a file part is decompressed before storing.
+func (a *API) handleFileUpload(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if r.Method != http.MethodPost {
+		a.sendMethodNotAllowed(w, r)
+		return
+	}
+
+	cfg := a.agent.Config()
+
+	if strings.EqualFold(r.Header.Get("Content-Encoding"), "gzip") {
+		body, err := gzip.NewReader(r.Body)
+		if err != nil {
+			a.sendError(w, r, fmt.Sprintf("Invalid gzip body: %v", err))
+			return
+		}
+		defer body.Close()
+		r.Body = http.MaxBytesReader(w, body, cfg.API.MaxUploadBytes)
+	}
+
+	if err := r.ParseMultipartForm(uploadMemoryBytes); err != nil {
+		a.sendRequestError(w, r, err)
+		return
+	}
+	defer r.MultipartForm.RemoveAll()
+
+	sessionID := r.FormValue("session_id")
+	if !isPlainName(sessionID) {
+		a.sendError(w, r, "Valid session_id is required")
+		return
+	}
+
+	headers := r.MultipartForm.File["file"]
+	if len(headers) == 0 {
+		a.sendError(w, r, "At least one file is required")
+		return
+	}
+
+	fileTool := NewFileTool(cfg.Tools.Directory)
+	remaining := cfg.API.MaxUploadBytes
+
+	var uploaded []UploadedFile
+	for _, header := range headers {
+		file, err := a.storeUpload(fileTool, sessionID, header, remaining)
+		if err != nil {
+			a.sendErrorStatus(w, r, uploadErrorStatus(err), fmt.Sprintf("Failed to upload %s: %v", header.Filename, err))
+			return
+		}
+		remaining -= file.Size
+		uploaded = append(uploaded, file)
+	}
+
+	response := Response{
+		Success: true,
+		Data:    uploaded,
+	}
+
+	json.NewEncoder(w).Encode(response)
+}
+
+// errUploadTooLarge is returned when decompressed uploads exceed the limit
+var errUploadTooLarge = errors.New("upload exceeds the maximum size")
+
+// storeUpload writes one uploaded file, of at most limit bytes after
+// decompression
+func (a *API) storeUpload(fileTool *FileTool, sessionID string, header *multipart.FileHeader, limit int64) (UploadedFile, error) {
+	filename := filepath.Base(header.Filename)
+	if !isPlainName(filename) {
+		return UploadedFile{}, fmt.Errorf("invalid filename")
+	}
+
+	relPath := filepath.Join(sessionID, filename)
+	absPath, err := fileTool.ResolvePath(relPath)
+	if err != nil {
+		return UploadedFile{}, err
+	}
+
+	part, err := header.Open()
+	if err != nil {
+		return UploadedFile{}, err
+	}
+	defer part.Close()
+
+	var src io.Reader = part
+	if strings.EqualFold(header.Header.Get("Content-Encoding"), "gzip") {
+		gz, err := gzip.NewReader(part)
+		if err != nil {
+			return UploadedFile{}, fmt.Errorf("invalid gzip data: %w", err)
+		}
+		defer gz.Close()
+		src = gz
+	}
+
+	if err := os.MkdirAll(filepath.Dir(absPath), 0755); err != nil {
+		return UploadedFile{}, err
+	}
+
+	dst, err := os.Create(absPath)
+	if err != nil {
+		return UploadedFile{}, err
+	}
+
+	size, err := io.Copy(dst, io.LimitReader(src, limit+1))
+	if closeErr := dst.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil && size > limit {
+		err = errUploadTooLarge
+	}
+	if err != nil {
+		os.Remove(absPath)
+		return UploadedFile{}, err
+	}
+
+	return UploadedFile{
+		Filename: filename,
+		Size:     size,
+		Path:     filepath.ToSlash(relPath),
+	}, nil
+}
+
+// isPlainName reports whether name is a single path element
+func isPlainName(name string) bool {
+	return name != "" && name != "." && name != ".." && !strings.ContainsAny(name, `/\`)
+}
+
+// uploadErrorStatus maps upload errors to status codes
+func uploadErrorStatus(err error) int {
+	if err == errUploadTooLarge {
+		return http.StatusRequestEntityTooLarge
+	}
+	return http.StatusBadRequest
+}
+
+// handleFiles handles uploaded file deletion
+func (a *API) handleFiles(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if r.Method != http.MethodDelete {
+		a.sendMethodNotAllowed(w, r)
+		return
+	}
+
+	// <session_id>/<filename>
+	parts := strings.SplitN(strings.Trim(r.URL.Path[len("/api/v1/files/"):], "/"), "/", 2)
+	if len(parts) != 2 || !isPlainName(parts[0]) || !isPlainName(parts[1]) {
+		a.sendNotFound(w, r)
+		return
+	}
+
+	fileTool := NewFileTool(a.agent.Config().Tools.Directory)
+	result, err := fileTool.Execute(r.Context(), map[string]interface{}{
+		"operation": "delete",
+		"path":      filepath.Join(parts[0], parts[1]),
+	})
+	if err != nil {
+		if os.IsNotExist(err) {
+			a.sendNotFound(w, r)
+			return
+		}
+		a.sendError(w, r, fmt.Sprintf("Failed to delete file: %v", err))
+		return
+	}
+
+	response := Response{
+		Success: true,
+		Data: map[string]interface{}{
+			"session_id": parts[0],
+			"filename":   parts[1],
+			"result":     result.TextResult,
+		},
+	}
+
+	json.NewEncoder(w).Encode(response)
+}
+
+// handleSessionImport imports a WhatsApp or Telegram chat export, sent as
+// the file part of a multipart form with a format field, into a session.
+// The session is created if it does not exist.
+func (a *API) handleSessionImport(w http.ResponseWriter, r *http.Request, sessionID string) {
+	if r.Method != http.MethodPost {
+		a.sendMethodNotAllowed(w, r)
+		return
+	}
+
+	if err := r.ParseMultipartForm(uploadMemoryBytes); err != nil {
+		a.sendRequestError(w, r, err)
+		return
+	}
+	defer r.MultipartForm.RemoveAll()
+
+	format := r.FormValue("format")
+	var importer func(path, sessionID string) (int, error)
+	switch format {
+	case ImportFormatWhatsApp:
+		importer = a.memory.ImportWhatsAppHistory
+	case ImportFormatTelegram:
+		importer = a.memory.ImportTelegramHistory
+	default:
+		a.sendError(w, r, "format must be whatsapp or telegram")
+		return
+	}
+
+	part, _, err := r.FormFile("file")
+	if err != nil {
+		a.sendError(w, r, "file is required")
+		return
+	}
+	defer part.Close()
+
+	// The importers read exports from disk
+	tmp, err := os.CreateTemp("", "quickbot-import-*")
+	if err != nil {
+		a.sendErrorStatus(w, r, http.StatusInternalServerError, fmt.Sprintf("Failed to store export: %v", err))
+		return
+	}
+	defer os.Remove(tmp.Name())
+	_, err = io.Copy(tmp, part)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		a.sendErrorStatus(w, r, http.StatusInternalServerError, fmt.Sprintf("Failed to store export: %v", err))
+		return
+	}
+
+	session, err := a.memory.GetSession(sessionID)
+	if err != nil {
+		a.sendErrorStatus(w, r, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if session == nil {
+		if err := a.memory.CreateSession(sessionID, "Imported "+format+" chat", format, ""); err != nil {
+			a.sendErrorStatus(w, r, http.StatusInternalServerError, err.Error())
+			return
+		}
+	}
+
+	imported, err := importer(tmp.Name(), sessionID)
+	if err != nil {
+		a.sendError(w, r, fmt.Sprintf("Failed to import chat history: %v", err))
+		return
+	}
+
+	response := Response{
+		Success: true,
+		Data: map[string]interface{}{
+			"session_id": sessionID,
+			"format":     format,
+			"imported":   imported,
+		},
+	}
+
+	json.NewEncoder(w).Encode(response)
+}
+
+// TestFileUpload uploads a text file and a gzip-compressed file and checks
+// that both can be read back through the file tool
+func TestFileUpload() error {
+	log.Println("Testing file uploads...")
+
+	dir, err := os.MkdirTemp("", "quickbot-uploads")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+
+	config := DefaultConfig()
+	config.Tools.Directory = dir
+	config.API.MaxUploadBytes = 1024
+	dryRun, err := NewDryRunAgent(config)
+	if err != nil {
+		return err
+	}
+	defer dryRun.Close()
+	api := NewAPI(dryRun.Agent, dryRun.Memory(), nil, 0)
+
+	gzipped := func(data string) []byte {
+		var buf bytes.Buffer
+		gz := gzip.NewWriter(&buf)
+		gz.Write([]byte(data))
+		gz.Close()
+		return buf.Bytes()
+	}
+
+	type upload struct {
+		filename string
+		content  []byte
+		gzip     bool
+	}
+	// post uploads files to a session as a multipart form
+	post := func(sessionID string, files ...upload) *httptest.ResponseRecorder {
+		var body bytes.Buffer
+		form := multipart.NewWriter(&body)
+		form.WriteField("session_id", sessionID)
+		for _, file := range files {
+			header := textproto.MIMEHeader{}
+			header.Set("Content-Disposition", fmt.Sprintf(`form-data; name="file"; filename=%q`, file.filename))
+			header.Set("Content-Type", "application/octet-stream")
+			if file.gzip {
+				header.Set("Content-Encoding", "gzip")
+			}
+			part, _ := form.CreatePart(header)
+			part.Write(file.content)
+		}
+		form.Close()
+
+		r := httptest.NewRequest(http.MethodPost, "/api/v1/files/upload", &body)
+		r.Header.Set("Content-Type", form.FormDataContentType())
+		w := httptest.NewRecorder()
+		api.handleFileUpload(w, r)
+		return w
+	}
+
+	w := post("s1",
+		upload{"notes.txt", []byte("plain text notes\n"), false},
+		upload{"report.txt", gzipped("compressed report\n"), true},
+	)
+	if w.Code != http.StatusOK {
+		return fmt.Errorf("upload: expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var response struct {
+		Data []UploadedFile `json:"data"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+		return fmt.Errorf("invalid upload response: %s", w.Body.String())
+	}
+	want := []UploadedFile{
+		{Filename: "notes.txt", Size: int64(len("plain text notes\n")), Path: "s1/notes.txt"},
+		{Filename: "report.txt", Size: int64(len("compressed report\n")), Path: "s1/report.txt"},
+	}
+	if fmt.Sprint(response.Data) != fmt.Sprint(want) {
+		return fmt.Errorf("expected uploaded files %v, got %v", want, response.Data)
+	}
+
+	fileTool := NewFileTool(dir)
+	read := func(path string) (string, error) {
+		result, err := fileTool.Execute(context.Background(), map[string]interface{}{"operation": "read", "path": path})
+		return result.TextResult, err
+	}
+	if content, err := read("s1/notes.txt"); err != nil || content != "plain text notes\n" {
+		return fmt.Errorf("expected the text file to be readable, got %q (%v)", content, err)
+	}
+	if content, err := read("s1/report.txt"); err != nil || content != "compressed report\n" {
+		return fmt.Errorf("expected the gzip file to be stored decompressed, got %q (%v)", content, err)
+	}
+	log.Println("✓ Text and gzip-compressed files uploaded and readable")
+
+	if w := post("s1", upload{"big.txt", gzipped(strings.Repeat("x", 2048)), true}); w.Code != http.StatusRequestEntityTooLarge {
+		return fmt.Errorf("expected 413 for a file over the limit once decompressed, got %d", w.Code)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "s1", "big.txt")); !os.IsNotExist(err) {
+		return fmt.Errorf("expected the oversized file to be removed")
+	}
+	if w := post("../s1", upload{"notes.txt", []byte("x"), false}); w.Code != http.StatusBadRequest {
+		return fmt.Errorf("expected 400 for an invalid session ID, got %d", w.Code)
+	}
+	log.Println("✓ Oversized files and invalid session IDs rejected")
+
+	r := httptest.NewRequest(http.MethodDelete, "/api/v1/files/s1/notes.txt", nil)
+	w = httptest.NewRecorder()
+	api.handleFiles(w, r)
+	if w.Code != http.StatusOK {
+		return fmt.Errorf("delete: expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if _, err := read("s1/notes.txt"); err == nil {
+		return fmt.Errorf("expected the deleted file to be gone")
+	}
+	log.Println("✓ Uploaded file deleted")
+
+	return nil
+}
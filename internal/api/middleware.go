@@ -0,0 +1,440 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/andybalholm/brotli"
+	"github.com/google/uuid"
+
+	"github.com/Chang-Augenweide/QuickBot-Go/pkg/types"
+)
+
+// maxRequestIDLength bounds client-supplied request IDs
+const maxRequestIDLength = 128
+
+// statusRecorder records the status code written by a handler
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+// RequestIDMiddleware assigns each request a correlation ID, taken from the
+// X-Request-ID header or generated, stores it in the request context,
+// echoes it in the X-Request-ID response header and logs the request with it
+func RequestIDMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestID := r.Header.Get("X-Request-ID")
+		if requestID == "" || len(requestID) > maxRequestIDLength {
+			requestID = uuid.NewString()
+		}
+
+		w.Header().Set("X-Request-ID", requestID)
+		r = r.WithContext(types.WithRequestID(r.Context(), requestID))
+
+		start := time.Now()
+		recorder := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(recorder, r)
+
+		log.Printf("[%s] %s %s %d (%v)", requestID, r.Method, r.URL.Path, recorder.status, time.Since(start))
+	})
+}
+
+// RequestSizeLimitMiddleware limits request bodies to maxBytes. Requests
+// declaring a larger Content-Length are rejected with 413 up front; for
+// others, reading past the limit fails with *http.MaxBytesError, which
+// handlers report as 413.
+func RequestSizeLimitMiddleware(maxBytes int64) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if maxBytes <= 0 {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			if r.ContentLength > maxBytes {
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(http.StatusRequestEntityTooLarge)
+				json.NewEncoder(w).Encode(Response{
+					Success:   false,
+					Error:     fmt.Sprintf("Request body too large (max %d bytes)", maxBytes),
+					RequestID: types.RequestIDFromContext(r.Context()),
+				})
+				return
+			}
+
+			r.Body = http.MaxBytesReader(w, r.Body, maxBytes)
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+var gzipWriterPool = sync.Pool{
+	New: func() interface{} {
+		return gzip.NewWriter(io.Discard)
+	},
+}
+
+// CompressionMiddleware compresses responses with gzip or brotli according
+// to the Accept-Encoding header. Responses smaller than minSize bytes are
+// sent uncompressed.
+func CompressionMiddleware(next http.Handler, minSize int) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Vary", "Accept-Encoding")
+
+		encoding := negotiateEncoding(r.Header.Get("Accept-Encoding"))
+		if encoding == "" || r.Method == http.MethodHead {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		cw := &compressWriter{
+			ResponseWriter: w,
+			encoding:       encoding,
+			minSize:        minSize,
+			status:         http.StatusOK,
+		}
+		defer cw.Close()
+
+		next.ServeHTTP(cw, r)
+	})
+}
+
+// negotiateEncoding picks gzip or br from an Accept-Encoding header,
+// preferring gzip; it returns "" if neither is acceptable
+func negotiateEncoding(header string) string {
+	accepted := make(map[string]bool)
+	for _, part := range strings.Split(header, ",") {
+		fields := strings.Split(part, ";")
+		name := strings.ToLower(strings.TrimSpace(fields[0]))
+		if len(fields) > 1 && strings.ReplaceAll(strings.TrimSpace(fields[1]), " ", "") == "q=0" {
+			continue
+		}
+		accepted[name] = true
+	}
+
+	switch {
+	case accepted["gzip"]:
+		return "gzip"
+	case accepted["br"]:
+		return "br"
+	default:
+		return ""
+	}
+}
+
+// compressWriter buffers the response until minSize bytes have been
+// written, then switches to compressing it. Smaller responses are written
+// as is on Close.
+type compressWriter struct {
+	http.ResponseWriter
+	encoding    string
+	minSize     int
+	status      int
+	wroteHeader bool
+	buf         bytes.Buffer
+	writer      io.WriteCloser
+	gzipWriter  *gzip.Writer
+}
+
+func (cw *compressWriter) WriteHeader(status int) {
+	if cw.wroteHeader {
+		return
+	}
+	cw.status = status
+	cw.wroteHeader = true
+}
+
+func (cw *compressWriter) Write(p []byte) (int, error) {
+	cw.wroteHeader = true
+
+	if cw.writer != nil {
+		return cw.writer.Write(p)
+	}
+
+	cw.buf.Write(p)
+	if cw.buf.Len() < cw.minSize {
+		return len(p), nil
+	}
+
+	// Already encoded by the handler
+	if cw.Header().Get("Content-Encoding") != "" {
+		return len(p), cw.flushRaw()
+	}
+
+	if err := cw.startCompression(); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// startCompression sends the headers and the buffered data through the
+// compressor
+func (cw *compressWriter) startCompression() error {
+	header := cw.Header()
+	header.Set("Content-Encoding", cw.encoding)
+	header.Del("Content-Length")
+	cw.ResponseWriter.WriteHeader(cw.status)
+
+	switch cw.encoding {
+	case "gzip":
+		cw.gzipWriter = gzipWriterPool.Get().(*gzip.Writer)
+		cw.gzipWriter.Reset(cw.ResponseWriter)
+		cw.writer = cw.gzipWriter
+	default:
+		cw.writer = brotli.NewWriter(cw.ResponseWriter)
+	}
+
+	_, err := cw.writer.Write(cw.buf.Bytes())
+	cw.buf.Reset()
+	return err
+}
+
+// flushRaw sends the headers and the buffered data uncompressed, and
+// passes any further writes through
+func (cw *compressWriter) flushRaw() error {
+	cw.ResponseWriter.WriteHeader(cw.status)
+	cw.writer = nopWriteCloser{cw.ResponseWriter}
+
+	_, err := cw.ResponseWriter.Write(cw.buf.Bytes())
+	cw.buf.Reset()
+	return err
+}
+
+// Close finishes the response
+func (cw *compressWriter) Close() error {
+	if cw.writer == nil {
+		if !cw.wroteHeader {
+			return nil
+		}
+		return cw.flushRaw()
+	}
+
+	err := cw.writer.Close()
+	if cw.gzipWriter != nil {
+		cw.gzipWriter.Reset(io.Discard)
+		gzipWriterPool.Put(cw.gzipWriter)
+		cw.gzipWriter = nil
+	}
+	return err
+}
+
+type nopWriteCloser struct {
+	io.Writer
+}
+
+func (nopWriteCloser) Close() error { return nil }
+
+// TestRequestIDMiddleware sends concurrent requests and checks that each
+// one gets its own correlation ID, in the response header and the context
+func TestRequestIDMiddleware() error {
+	log.Println("Testing request IDs...")
+
+	server := httptest.NewServer(RequestIDMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, types.RequestIDFromContext(r.Context()))
+	})))
+	defer server.Close()
+
+	const requests = 10
+	ids := make([]string, requests)
+	errs := make([]error, requests)
+	var wg sync.WaitGroup
+	for i := 0; i < requests; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			resp, err := http.Get(server.URL)
+			if err != nil {
+				errs[i] = err
+				return
+			}
+			defer resp.Body.Close()
+			body, err := io.ReadAll(resp.Body)
+			if err != nil {
+				errs[i] = err
+				return
+			}
+			ids[i] = resp.Header.Get("X-Request-ID")
+			if ids[i] == "" || string(body) != ids[i] {
+				errs[i] = fmt.Errorf("request %d: header ID %q does not match context ID %q", i, ids[i], body)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	seen := make(map[string]bool)
+	for i, id := range ids {
+		if errs[i] != nil {
+			return errs[i]
+		}
+		if seen[id] {
+			return fmt.Errorf("request ID %s returned twice", id)
+		}
+		seen[id] = true
+	}
+	log.Printf("✓ %d concurrent requests got unique request IDs", requests)
+
+	r, _ := http.NewRequest(http.MethodGet, server.URL, nil)
+	r.Header.Set("X-Request-ID", "client-id-1")
+	resp, err := http.DefaultClient.Do(r)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if id := resp.Header.Get("X-Request-ID"); id != "client-id-1" {
+		return fmt.Errorf("expected the client request ID to be echoed, got %q", id)
+	}
+	log.Println("✓ Client request ID echoed")
+
+	return nil
+}
+
+// TestCompressionMiddleware fetches the messages of a session with 1000
+// messages with each encoding and checks that the decompressed responses
+// match the uncompressed one
+func TestCompressionMiddleware() error {
+	log.Println("Testing response compression...")
+
+	for _, c := range []struct{ header, want string }{
+		{"gzip", "gzip"},
+		{"br", "br"},
+		{"br, gzip", "gzip"},
+		{"gzip;q=0, br", "br"},
+		{"deflate", ""},
+		{"", ""},
+	} {
+		if got := negotiateEncoding(c.header); got != c.want {
+			return fmt.Errorf("Accept-Encoding %q: expected %q, got %q", c.header, c.want, got)
+		}
+	}
+	log.Println("✓ Encodings negotiated")
+
+	dir, err := os.MkdirTemp("", "quickbot-compression")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+
+	memory, err := NewMemory(filepath.Join(dir, "memory.db"), 100)
+	if err != nil {
+		return err
+	}
+	defer memory.Close()
+
+	memory.CreateSession("s1", "Compression", "api", "u1")
+	for i := 0; i < 1000; i++ {
+		if _, _, err := memory.AddMessage("s1", "user", fmt.Sprintf("message %d", i), nil); err != nil {
+			return err
+		}
+	}
+
+	api := NewAPI(nil, memory, nil, 0)
+	handler := CompressionMiddleware(http.HandlerFunc(api.handleSessions), 1024)
+	request := func(path, acceptEncoding string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodGet, path, nil)
+		if acceptEncoding != "" {
+			r.Header.Set("Accept-Encoding", acceptEncoding)
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		return w
+	}
+
+	baseline := request("/api/v1/sessions/s1/messages", "")
+	if baseline.Code != http.StatusOK || baseline.Header().Get("Content-Encoding") != "" {
+		return fmt.Errorf("baseline: unexpected response %d (%s)", baseline.Code, baseline.Header().Get("Content-Encoding"))
+	}
+
+	decoders := map[string]func(io.Reader) (io.Reader, error){
+		"gzip": func(r io.Reader) (io.Reader, error) { return gzip.NewReader(r) },
+		"br":   func(r io.Reader) (io.Reader, error) { return brotli.NewReader(r), nil },
+	}
+	for encoding, decode := range decoders {
+		w := request("/api/v1/sessions/s1/messages", encoding)
+		if w.Code != http.StatusOK || w.Header().Get("Content-Encoding") != encoding {
+			return fmt.Errorf("%s: expected a %s encoded response, got %d (%q)", encoding, encoding, w.Code, w.Header().Get("Content-Encoding"))
+		}
+		if w.Body.Len() >= baseline.Body.Len() {
+			return fmt.Errorf("%s: response not smaller than the baseline (%d >= %d bytes)", encoding, w.Body.Len(), baseline.Body.Len())
+		}
+		reader, err := decode(w.Body)
+		if err != nil {
+			return fmt.Errorf("%s: %w", encoding, err)
+		}
+		body, err := io.ReadAll(reader)
+		if err != nil {
+			return fmt.Errorf("%s: failed to decompress: %w", encoding, err)
+		}
+		if !bytes.Equal(body, baseline.Body.Bytes()) {
+			return fmt.Errorf("%s: decompressed response does not match the baseline", encoding)
+		}
+	}
+	log.Printf("✓ gzip and brotli responses match the %d byte baseline", baseline.Body.Len())
+
+	// Responses under the minimum size are not compressed
+	if w := request("/api/v1/sessions/missing/messages", "gzip"); w.Header().Get("Content-Encoding") != "" {
+		return fmt.Errorf("small response compressed with %s", w.Header().Get("Content-Encoding"))
+	}
+	log.Println("✓ Small responses sent uncompressed")
+
+	return nil
+}
+
+// TestRequestSizeLimit sends chat requests over the body size limit, with
+// and without a Content-Length, and checks that they are rejected with 413
+func TestRequestSizeLimit() error {
+	log.Println("Testing request size limit...")
+
+	const limit = 1024
+	api := NewAPI(nil, nil, nil, 0)
+	handler := RequestSizeLimitMiddleware(limit)(http.HandlerFunc(api.handleChat))
+
+	body := fmt.Sprintf(`{"session_id": "s1", "message": %q}`, strings.Repeat("a", 2*limit))
+	for _, chunked := range []bool{false, true} {
+		r := httptest.NewRequest(http.MethodPost, "/api/v1/chat", strings.NewReader(body))
+		if chunked {
+			// No Content-Length: the limit is hit while reading the body
+			r.ContentLength = -1
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+
+		if w.Code != http.StatusRequestEntityTooLarge {
+			return fmt.Errorf("chunked=%v: expected 413, got %d: %s", chunked, w.Code, w.Body.String())
+		}
+		var response Response
+		if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil || response.Success || response.Error == "" {
+			return fmt.Errorf("chunked=%v: expected a JSON error, got %s", chunked, w.Body.String())
+		}
+	}
+	log.Println("✓ Bodies over the limit rejected with 413")
+
+	echo := RequestSizeLimitMiddleware(limit)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(w, r.Body)
+	}))
+	small := strings.Repeat("a", limit)
+	w := httptest.NewRecorder()
+	echo.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/api/v1/chat", strings.NewReader(small)))
+	if w.Code != http.StatusOK || w.Body.String() != small {
+		return fmt.Errorf("expected a body at the limit to be accepted, got %d", w.Code)
+	}
+	log.Println("✓ Bodies within the limit accepted")
+
+	return nil
+}
@@ -0,0 +1,171 @@
+package benchmark
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/Chang-Augenweide/QuickBot-Go/internal/ai"
+	"github.com/Chang-Augenweide/QuickBot-Go/pkg/types"
+)
+
+// BenchmarkResult records a single prompt run against a provider. Token
+// counts are estimates, as providers do not report usage.
+type BenchmarkResult struct {
+	ProviderName string `json:"provider_name"`
+	Prompt       string `json:"prompt"`
+	Iteration    int    `json:"iteration"`
+	DurationMs   int64  `json:"duration_ms"`
+	InputTokens  int    `json:"input_tokens"`
+	OutputTokens int    `json:"output_tokens"`
+	Error        string `json:"error,omitempty"`
+}
+
+// ProviderSummary holds the statistics of one provider
+type ProviderSummary struct {
+	ProviderName    string  `json:"provider_name"`
+	Runs            int     `json:"runs"`
+	Errors          int     `json:"errors"`
+	MeanMs          float64 `json:"mean_ms"`
+	P95Ms           int64   `json:"p95_ms"`
+	TokensPerSecond float64 `json:"tokens_per_second"`
+}
+
+// BenchmarkSummary holds the statistics of a benchmark run
+type BenchmarkSummary struct {
+	Providers []ProviderSummary `json:"providers"`
+}
+
+// Benchmarker runs every prompt through every provider Iterations times
+type Benchmarker struct {
+	Providers  []ai.AIProvider
+	Prompts    []string
+	Iterations int
+
+	// Timeout bounds each request (default 2 minutes)
+	Timeout time.Duration
+}
+
+// Run runs the benchmark. Failed requests are recorded in the results
+// rather than aborting the run; an error is returned only if ctx is done.
+func (b *Benchmarker) Run(ctx context.Context) ([]BenchmarkResult, error) {
+	if len(b.Providers) == 0 {
+		return nil, fmt.Errorf("no providers to benchmark")
+	}
+	if len(b.Prompts) == 0 {
+		return nil, fmt.Errorf("no prompts to benchmark")
+	}
+
+	iterations := b.Iterations
+	if iterations <= 0 {
+		iterations = 1
+	}
+	timeout := b.Timeout
+	if timeout <= 0 {
+		timeout = 2 * time.Minute
+	}
+
+	var results []BenchmarkResult
+	for _, provider := range b.Providers {
+		for _, prompt := range b.Prompts {
+			for i := 1; i <= iterations; i++ {
+				if err := ctx.Err(); err != nil {
+					return results, err
+				}
+				results = append(results, b.runOne(ctx, provider, prompt, i, timeout))
+			}
+		}
+	}
+
+	return results, nil
+}
+
+// runOne sends one prompt to a provider and times it
+func (b *Benchmarker) runOne(ctx context.Context, provider ai.AIProvider, prompt string, iteration int, timeout time.Duration) BenchmarkResult {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	result := BenchmarkResult{
+		ProviderName: provider.ProviderName(),
+		Prompt:       prompt,
+		Iteration:    iteration,
+		InputTokens:  EstimateTokens(prompt),
+	}
+
+	start := time.Now()
+	response, err := provider.ChatCompletion(ctx, []types.Message{
+		{Role: "user", Content: prompt},
+	})
+	result.DurationMs = time.Since(start).Milliseconds()
+
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	result.OutputTokens = EstimateTokens(response)
+
+	return result
+}
+
+// EstimateTokens roughly estimates the token count of text (about four
+// characters per token)
+func EstimateTokens(text string) int {
+	return (len([]rune(text)) + 3) / 4
+}
+
+// Summarize computes per-provider statistics over successful results
+func Summarize(results []BenchmarkResult) BenchmarkSummary {
+	byProvider := make(map[string][]BenchmarkResult)
+	var names []string
+	for _, result := range results {
+		if _, ok := byProvider[result.ProviderName]; !ok {
+			names = append(names, result.ProviderName)
+		}
+		byProvider[result.ProviderName] = append(byProvider[result.ProviderName], result)
+	}
+	sort.Strings(names)
+
+	var summary BenchmarkSummary
+	for _, name := range names {
+		s := ProviderSummary{ProviderName: name}
+
+		var durations []int64
+		var totalMs, outputTokens int64
+		for _, result := range byProvider[name] {
+			s.Runs++
+			if result.Error != "" {
+				s.Errors++
+				continue
+			}
+			durations = append(durations, result.DurationMs)
+			totalMs += result.DurationMs
+			outputTokens += int64(result.OutputTokens)
+		}
+
+		if len(durations) > 0 {
+			s.MeanMs = float64(totalMs) / float64(len(durations))
+			s.P95Ms = percentile(durations, 95)
+		}
+		if totalMs > 0 {
+			s.TokensPerSecond = float64(outputTokens) / (float64(totalMs) / 1000)
+		}
+
+		summary.Providers = append(summary.Providers, s)
+	}
+
+	return summary
+}
+
+// percentile returns the p-th percentile (nearest rank) of values
+func percentile(values []int64, p float64) int64 {
+	sorted := append([]int64(nil), values...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+
+	rank := int(math.Ceil(p / 100 * float64(len(sorted))))
+	if rank < 1 {
+		rank = 1
+	}
+	return sorted[rank-1]
+}
@@ -0,0 +1,17 @@
+package broker
+
+import "time"
+
+// Broker connects QuickBot instances so that they can share messages and
+// coordinate work
+type Broker interface {
+	// Publish sends data to the subscribers of a topic, e.g. "telegram/42"
+	Publish(topic, data string) error
+	// Subscribe calls handler for each message published to a topic
+	Subscribe(topic string, handler func(string)) error
+	// Lock acquires a lock on key for ttl and reports whether this
+	// instance got it. The lock is released when ttl expires.
+	Lock(key string, ttl time.Duration) (bool, error)
+	// Close flushes pending messages and closes the connection
+	Close() error
+}
@@ -0,0 +1,278 @@
+package broker
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nats-io/nats.go"
+)
+
+// natsLockBucket is the prefix of the JetStream key-value buckets that
+// hold locks. Key-value TTLs are set per bucket, so there is one bucket
+// per lock TTL.
+const natsLockBucket = "quickbot_locks"
+
+// NATSBroker is a Broker backed by a NATS server. Locks are stored in
+// JetStream key-value buckets, so the server must have JetStream enabled.
+type NATSBroker struct {
+	conn      *nats.Conn
+	js        nats.JetStreamContext
+	clusterID string
+
+	mu    sync.Mutex
+	locks map[time.Duration]nats.KeyValue
+}
+
+// NewNATSBroker connects to the NATS server at url. Instances with the
+// same cluster ID share topics and locks; other clusters on the same
+// server are kept apart.
+func NewNATSBroker(url, clusterID string) (*NATSBroker, error) {
+	name := "quickbot"
+	if clusterID != "" {
+		name += "-" + clusterID
+	}
+
+	conn, err := nats.Connect(url,
+		nats.Name(name),
+		nats.MaxReconnects(-1),
+		nats.DisconnectErrHandler(func(_ *nats.Conn, err error) {
+			if err != nil {
+				log.Printf("Warning: NATS disconnected: %v", err)
+			}
+		}),
+		nats.ReconnectHandler(func(conn *nats.Conn) {
+			log.Printf("NATS reconnected to %s", conn.ConnectedUrl())
+		}),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to NATS: %w", err)
+	}
+
+	js, err := conn.JetStream()
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to create JetStream context: %w", err)
+	}
+
+	return &NATSBroker{
+		conn:      conn,
+		js:        js,
+		clusterID: clusterID,
+		locks:     make(map[time.Duration]nats.KeyValue),
+	}, nil
+}
+
+// Publish sends data to the subscribers of a topic
+func (b *NATSBroker) Publish(topic, data string) error {
+	if err := b.conn.Publish(b.subject(topic), []byte(data)); err != nil {
+		return fmt.Errorf("failed to publish to %s: %w", topic, err)
+	}
+	return nil
+}
+
+// Subscribe calls handler for each message published to a topic. A "*"
+// segment matches any one segment, e.g. "telegram/*".
+func (b *NATSBroker) Subscribe(topic string, handler func(string)) error {
+	_, err := b.conn.Subscribe(b.subject(topic), func(msg *nats.Msg) {
+		handler(string(msg.Data))
+	})
+	if err != nil {
+		return fmt.Errorf("failed to subscribe to %s: %w", topic, err)
+	}
+	return nil
+}
+
+// Lock acquires a lock on key for ttl. Only the first instance to lock a
+// key gets it; the key expires from the bucket after ttl.
+func (b *NATSBroker) Lock(key string, ttl time.Duration) (bool, error) {
+	kv, err := b.lockBucket(ttl)
+	if err != nil {
+		return false, err
+	}
+
+	_, err = kv.Create(lockKey(key), []byte(time.Now().UTC().Format(time.RFC3339)))
+	if errors.Is(err, nats.ErrKeyExists) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to lock %s: %w", key, err)
+	}
+	return true, nil
+}
+
+// Close flushes pending messages and closes the connection
+func (b *NATSBroker) Close() error {
+	err := b.conn.Flush()
+	b.conn.Close()
+	if err != nil && !errors.Is(err, nats.ErrConnectionClosed) {
+		return fmt.Errorf("failed to flush NATS connection: %w", err)
+	}
+	return nil
+}
+
+// lockBucket returns the key-value bucket for locks with ttl, creating
+// it if needed
+func (b *NATSBroker) lockBucket(ttl time.Duration) (nats.KeyValue, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if kv, ok := b.locks[ttl]; ok {
+		return kv, nil
+	}
+
+	bucket := fmt.Sprintf("%s_%dms", natsLockBucket, ttl.Milliseconds())
+	if b.clusterID != "" {
+		bucket = sanitizeBucket(b.clusterID) + "_" + bucket
+	}
+
+	kv, err := b.js.KeyValue(bucket)
+	if errors.Is(err, nats.ErrBucketNotFound) {
+		kv, err = b.js.CreateKeyValue(&nats.KeyValueConfig{
+			Bucket:      bucket,
+			Description: "QuickBot distributed locks",
+			TTL:         ttl,
+		})
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open lock bucket %s: %w", bucket, err)
+	}
+
+	b.locks[ttl] = kv
+	return kv, nil
+}
+
+// subject converts a topic such as "telegram/42" to a NATS subject,
+// "quickbot.<cluster_id>.telegram.42"
+func (b *NATSBroker) subject(topic string) string {
+	subject := "quickbot."
+	if b.clusterID != "" {
+		subject += b.clusterID + "."
+	}
+	return subject + strings.ReplaceAll(strings.Trim(topic, "/"), "/", ".")
+}
+
+// lockKey converts a lock key to a valid key-value key
+func lockKey(key string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9',
+			r == '-', r == '_', r == '=', r == '/':
+			return r
+		}
+		return '_'
+	}, key)
+}
+
+// sanitizeBucket replaces the characters not allowed in bucket names
+func sanitizeBucket(name string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '-', r == '_':
+			return r
+		}
+		return '_'
+	}, name)
+}
+
+// TestNATSBroker runs the broker against the NATS server at
+// $QUICKBOT_TEST_NATS_URL, which must have JetStream enabled, checking
+// topics, cluster isolation and lock expiry. The test is skipped when the
+// variable is not set.
+func TestNATSBroker() error {
+	log.Println("Testing NATS broker...")
+
+	url := os.Getenv("QUICKBOT_TEST_NATS_URL")
+	if url == "" {
+		log.Println("NATS broker test skipped: QUICKBOT_TEST_NATS_URL is not set")
+		return nil
+	}
+
+	// Cluster IDs unique to this run keep the buckets of earlier runs on
+	// the same server out of the way
+	run := time.Now().UnixNano()
+	cluster, otherCluster := fmt.Sprintf("test-%d", run), fmt.Sprintf("other-%d", run)
+
+	first, err := NewNATSBroker(url, cluster)
+	if err != nil {
+		return err
+	}
+	defer first.Close()
+	second, err := NewNATSBroker(url, cluster)
+	if err != nil {
+		return err
+	}
+	defer second.Close()
+	other, err := NewNATSBroker(url, otherCluster)
+	if err != nil {
+		return err
+	}
+	defer other.Close()
+
+	received := make(chan string, 10)
+	if err := second.Subscribe("telegram/*", func(data string) { received <- data }); err != nil {
+		return err
+	}
+	if err := other.Subscribe("telegram/*", func(data string) { received <- "other: " + data }); err != nil {
+		return err
+	}
+	// Subscriptions are registered with the server on flush
+	if err := second.conn.Flush(); err != nil {
+		return err
+	}
+	if err := other.conn.Flush(); err != nil {
+		return err
+	}
+
+	if err := first.Publish("telegram/42", "hello"); err != nil {
+		return err
+	}
+	select {
+	case data := <-received:
+		if data != "hello" {
+			return fmt.Errorf("expected %q, got %q", "hello", data)
+		}
+	case <-time.After(5 * time.Second):
+		return fmt.Errorf("expected the message to be delivered")
+	}
+	select {
+	case data := <-received:
+		return fmt.Errorf("unexpected message %q", data)
+	case <-time.After(200 * time.Millisecond):
+	}
+	log.Println("✓ Messages delivered within the cluster only")
+
+	ttl := time.Second
+	if ok, err := first.Lock("task:1", ttl); err != nil || !ok {
+		return fmt.Errorf("expected the first lock to succeed, got %v (%v)", ok, err)
+	}
+	if ok, err := second.Lock("task:1", ttl); err != nil || ok {
+		return fmt.Errorf("expected a held lock to be refused, got %v (%v)", ok, err)
+	}
+	if ok, err := other.Lock("task:1", ttl); err != nil || !ok {
+		return fmt.Errorf("expected another cluster to get its own lock, got %v (%v)", ok, err)
+	}
+	log.Println("✓ Locks held by one instance per cluster")
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		ok, err := second.Lock("task:1", ttl)
+		if err != nil {
+			return err
+		}
+		if ok {
+			break
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("expected the lock to expire after %s", ttl)
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	log.Println("✓ Locks expire after their TTL")
+
+	return nil
+}
@@ -4,19 +4,25 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
+	"time"
 
 	"gopkg.in/yaml.v3"
 )
 
 // Config represents the main configuration structure
 type Config struct {
-	Bot        BotConfig        `yaml:"bot"`
-	Platforms  PlatformsConfig  `yaml:"platforms"`
-	AI         AIConfig         `yaml:"ai"`
-	Memory     MemoryConfig     `yaml:"memory"`
-	Scheduler  SchedulerConfig  `yaml:"scheduler"`
-	Tools      ToolsConfig      `yaml:"tools"`
-	Logging    LoggingConfig    `yaml:"logging"`
+	Bot       BotConfig       `yaml:"bot"`
+	Platforms PlatformsConfig `yaml:"platforms"`
+	AI        AIConfig        `yaml:"ai"`
+	Memory    MemoryConfig    `yaml:"memory"`
+	Scheduler SchedulerConfig `yaml:"scheduler"`
+	Tools     ToolsConfig     `yaml:"tools"`
+	Logging   LoggingConfig   `yaml:"logging"`
+	API       APIConfig       `yaml:"api"`
+	Workflow  WorkflowConfig  `yaml:"workflow"`
+	Broker    BrokerConfig    `yaml:"broker"`
+	CLI       CLIConfig       `yaml:"cli"`
 }
 
 // BotConfig represents bot-specific configuration
@@ -28,21 +34,91 @@ type BotConfig struct {
 
 // PlatformsConfig represents platform integrations
 type PlatformsConfig struct {
-	Telegram TelegramConfig `yaml:"telegram"`
-	Discord  DiscordConfig  `yaml:"discord"`
+	Telegram TelegramConfig        `yaml:"telegram"`
+	Discord  DiscordConfig         `yaml:"discord"`
+	Slack    SlackConfig           `yaml:"slack"`
+	Webhook  WebhookPlatformConfig `yaml:"webhook"`
 }
 
 // TelegramConfig represents Telegram bot configuration
 type TelegramConfig struct {
+	Enabled         bool     `yaml:"enabled"`
+	Token           string   `yaml:"token"`
+	AllowedUsers    []string `yaml:"allowed_users"`
+	TypingIndicator bool     `yaml:"typing_indicator"`
+
+	// Channel posts
+	RespondToChannels bool    `yaml:"respond_to_channels"`
+	ChannelAllowList  []int64 `yaml:"channel_allow_list"`
+	AdminChatID       int64   `yaml:"admin_chat_id"`
+
+	// StreamingReplies edits replies in place as a streaming AI response
+	// arrives (requires a streaming provider)
+	StreamingReplies bool `yaml:"streaming_replies"`
+
+	// TopicIsolation gives each forum topic of a supergroup its own
+	// session; TopicWelcomeMessage, if set, is sent to new topics
+	TopicIsolation      bool   `yaml:"topic_isolation"`
+	TopicWelcomeMessage string `yaml:"topic_welcome_message"`
+
+	// Responses longer than MaxLength characters are shortened with
+	// TruncationStrategy: "truncate", "split" or "summarize"
+	MaxLength          int    `yaml:"max_length"`
+	TruncationStrategy string `yaml:"truncation_strategy"`
+}
+
+// DiscordConfig represents Discord bot configuration
+type DiscordConfig struct {
 	Enabled      bool     `yaml:"enabled"`
 	Token        string   `yaml:"token"`
 	AllowedUsers []string `yaml:"allowed_users"`
+
+	// CommandPermissions restricts slash commands to members with roles
+	CommandPermissions []CommandPermission `yaml:"command_permissions"`
+	// AdminUserIDs bypass all role checks
+	AdminUserIDs []string `yaml:"admin_user_ids"`
+
+	// AutoReconnect reopens the gateway after recoverable disconnects
+	AutoReconnect        bool `yaml:"auto_reconnect"`
+	ReconnectMaxAttempts int  `yaml:"reconnect_max_attempts"`
+
+	// Responses longer than MaxLength characters are shortened with
+	// TruncationStrategy: "truncate", "split" or "summarize"
+	MaxLength          int    `yaml:"max_length"`
+	TruncationStrategy string `yaml:"truncation_strategy"`
 }
 
-// DiscordConfig represents Discord bot configuration
-type DiscordConfig struct {
-	Enabled bool   `yaml:"enabled"`
-	Token   string `yaml:"token"`
+// SlackConfig represents Slack bot configuration. The bot connects
+// through Socket Mode with the app-level token and posts with the bot
+// token.
+type SlackConfig struct {
+	Enabled      bool     `yaml:"enabled"`
+	AppToken     string   `yaml:"app_token"`
+	BotToken     string   `yaml:"bot_token"`
+	AllowedUsers []string `yaml:"allowed_users"`
+
+	// MaxReconnectAttempts limits Socket Mode reconnection after failures
+	// in a row (default 10, negative retries forever); delays start at
+	// ReconnectBaseSeconds (default 1) and double
+	MaxReconnectAttempts int `yaml:"max_reconnect_attempts"`
+	ReconnectBaseSeconds int `yaml:"reconnect_base_seconds"`
+}
+
+// WebhookPlatformConfig represents outbound webhook configuration
+type WebhookPlatformConfig struct {
+	Enabled    bool     `yaml:"enabled"`
+	URL        string   `yaml:"url"`
+	Secret     string   `yaml:"secret"`
+	Events     []string `yaml:"events"`
+	Retry      bool     `yaml:"retry"`
+	MaxRetries int      `yaml:"max_retries"`
+}
+
+// CommandPermission lists the roles allowed to use a command; a member
+// needs at least one of them
+type CommandPermission struct {
+	Command       string   `yaml:"command"`
+	RequiredRoles []string `yaml:"required_roles"`
 }
 
 // AIConfig represents AI provider configuration
@@ -53,6 +129,104 @@ type AIConfig struct {
 	BaseURL     string  `yaml:"base_url"`
 	MaxTokens   int     `yaml:"max_tokens"`
 	Temperature float64 `yaml:"temperature"`
+
+	// TimeoutSeconds is the default AI response timeout; sessions may set
+	// their own ai_timeout_seconds up to MaxTimeoutSeconds
+	TimeoutSeconds    int `yaml:"timeout_seconds"`
+	MaxTimeoutSeconds int `yaml:"max_timeout_seconds"`
+
+	// GeminiProject is the Google Cloud project used to call Gemini
+	// through Vertex AI; api_key must then be an OAuth access token.
+	// GeminiLocation is its Vertex AI region (default us-central1).
+	GeminiProject  string `yaml:"gemini_project"`
+	GeminiLocation string `yaml:"gemini_location"`
+
+	// CacheEnabled caches the answer to each conversation made with a
+	// temperature of 0, keyed on the provider, model and all its messages
+	CacheEnabled bool          `yaml:"cache_enabled"`
+	CacheTTL     time.Duration `yaml:"cache_ttl"`
+	// CacheSize, when positive, caches up to this many responses to
+	// requests made with a temperature of 0, for cache_ttl each. It also
+	// bounds the cache_enabled cache, which otherwise holds up to 1000.
+	CacheSize int `yaml:"cache_size"`
+
+	// PromptLibraryDir is a directory of *.tmpl system prompt templates
+	PromptLibraryDir string `yaml:"prompt_library_dir"`
+
+	Retry          RetryConfig          `yaml:"retry"`
+	RetryBudget    RetryBudget          `yaml:"retry_budget"`
+	CircuitBreaker CircuitBreakerConfig `yaml:"circuit_breaker"`
+
+	// FallbackProviders are tried in order when the provider fails, as
+	// "name" or "name:model". They use the same API key.
+	FallbackProviders []string `yaml:"fallback_providers"`
+
+	// RetryAttempts, when positive, enables retries with this many
+	// attempts per request, and RetryMaxDelay caps the backoff between
+	// them. They take precedence over the retry section.
+	RetryAttempts int           `yaml:"retry_attempts"`
+	RetryMaxDelay time.Duration `yaml:"retry_max_delay"`
+
+	// PreProcessors names the pre-processors user messages pass through,
+	// in order: normalize, shortcuts, language, spelling
+	PreProcessors []string `yaml:"preprocessors"`
+	// SpellingDictionary is the "misspelling correction" file used by the
+	// spelling pre-processor
+	SpellingDictionary string `yaml:"spelling_dictionary"`
+}
+
+// validProviders are the AI provider names accepted in ai.provider and
+// ai.fallback_providers
+var validProviders = map[string]bool{
+	"openai":    true,
+	"anthropic": true,
+	"ollama":    true,
+	"gemini":    true,
+	"mistral":   true,
+}
+
+// validPreProcessors are the pre-processor names accepted in
+// ai.preprocessors
+var validPreProcessors = map[string]bool{
+	"normalize": true,
+	"shortcuts": true,
+	"language":  true,
+	"spelling":  true,
+}
+
+// RetryConfig represents AI request retry configuration
+type RetryConfig struct {
+	Enabled     bool          `yaml:"enabled"`
+	MaxAttempts int           `yaml:"max_attempts"`
+	MaxBackoff  time.Duration `yaml:"max_backoff"`
+}
+
+// EffectiveRetry returns the retry configuration with retry_attempts and
+// retry_max_delay applied
+func (c AIConfig) EffectiveRetry() RetryConfig {
+	retry := c.Retry
+	if c.RetryAttempts > 0 {
+		retry.Enabled = true
+		retry.MaxAttempts = c.RetryAttempts
+	}
+	if c.RetryMaxDelay > 0 {
+		retry.MaxBackoff = c.RetryMaxDelay
+	}
+	return retry
+}
+
+// RetryBudget limits the AI request retries of each session. Zero fields
+// are unlimited.
+type RetryBudget struct {
+	MaxRetriesPerMinute       int `yaml:"max_retries_per_minute"`
+	MaxTotalRetriesPerSession int `yaml:"max_total_retries_per_session"`
+}
+
+// CircuitBreakerConfig represents AI provider circuit breaker configuration
+type CircuitBreakerConfig struct {
+	Enabled        bool `yaml:"enabled"`
+	Threshold      int  `yaml:"threshold"`
+	TimeoutSeconds int  `yaml:"timeout_seconds"`
 }
 
 // MemoryConfig represents memory management configuration
@@ -60,18 +234,83 @@ type MemoryConfig struct {
 	Enabled     bool   `yaml:"enabled"`
 	MaxMessages int    `yaml:"max_messages"`
 	Storage     string `yaml:"storage"`
+
+	// CleanupInterval is the cleanup worker interval in seconds
+	CleanupInterval int `yaml:"cleanup_interval"`
+	// MaxMessagesPerSession caps stored messages per session (0 is unlimited)
+	MaxMessagesPerSession int `yaml:"max_messages_per_session"`
+
+	// EmbeddingsEnabled stores an embedding of each message for semantic
+	// search, using the OpenAI-compatible API key and base URL of the AI
+	// configuration
+	EmbeddingsEnabled bool   `yaml:"embeddings_enabled"`
+	EmbeddingModel    string `yaml:"embedding_model"`
+
+	// Deduplicate removes redelivered messages and duplicate long-term
+	// memories on each cleanup run
+	Deduplicate bool `yaml:"deduplicate"`
 }
 
 // SchedulerConfig represents scheduler configuration
 type SchedulerConfig struct {
 	Enabled bool   `yaml:"enabled"`
 	Storage string `yaml:"storage"`
+
+	// MaxOverdueAlertThreshold is how many tasks may be overdue before
+	// the scheduler health check fails
+	MaxOverdueAlertThreshold int `yaml:"max_overdue_alert_threshold"`
+
+	// HistoryRetentionDays is how many days task executions are kept
+	HistoryRetentionDays int `yaml:"history_retention_days"`
+}
+
+// WorkflowConfig represents workflow engine configuration
+type WorkflowConfig struct {
+	MaxConcurrentExecutions int `yaml:"max_concurrent_executions"`
+	QueueSize               int `yaml:"queue_size"`
+
+	// Storage is the SQLite database workflows and executions are saved
+	// in, so that a restart resumes interrupted executions
+	Storage string `yaml:"storage"`
 }
 
 // ToolsConfig represents tools configuration
 type ToolsConfig struct {
-	Enabled   bool   `yaml:"enabled"`
-	Directory string `yaml:"directory"`
+	Enabled        bool   `yaml:"enabled"`
+	Directory      string `yaml:"directory"`
+	DefinitionsDir string `yaml:"definitions_dir"`
+
+	// StreamingEnabled streams the output of long-running tools, such as
+	// shell commands, to platforms that support it
+	StreamingEnabled bool `yaml:"streaming_enabled"`
+
+	// ShellWorkDir is the directory shell commands run in (default: the
+	// system temp directory). ShellTimeout kills commands that run longer.
+	ShellWorkDir string        `yaml:"shell_work_dir"`
+	ShellTimeout time.Duration `yaml:"shell_timeout"`
+
+	// MaxResultBytes splits longer tool results into pages, which the
+	// model reads with the paginate operation for ResultBufferTTL
+	MaxResultBytes  int           `yaml:"max_result_bytes"`
+	ResultBufferTTL time.Duration `yaml:"result_buffer_ttl"`
+
+	// AllowHTTP enables the http tool. It may only call the hosts of
+	// HTTPAllowedDomains ("api.example.com", "*.example.com" or a CIDR
+	// range; empty allows all) that are not in HTTPDeniedDomains (same
+	// patterns) and, unless HTTPAllowPrivateIPs is set, no private,
+	// loopback or link-local addresses.
+	AllowHTTP           bool     `yaml:"allow_http"`
+	HTTPAllowedDomains  []string `yaml:"http_allowed_domains"`
+	HTTPDeniedDomains   []string `yaml:"http_denied_domains"`
+	HTTPAllowPrivateIPs bool     `yaml:"http_allow_private_ips"`
+}
+
+// CLIConfig represents the interactive chat mode (--cmd chat)
+type CLIConfig struct {
+	// TypingDelay is waited before printing each character of a response
+	TypingDelay  time.Duration `yaml:"typing_delay"`
+	StreamOutput bool          `yaml:"stream_output"`
+	ColorOutput  bool          `yaml:"color_output"`
 }
 
 // LoggingConfig represents logging configuration
@@ -82,6 +321,41 @@ type LoggingConfig struct {
 	BackupCount int    `yaml:"backup_count"`
 }
 
+// APIConfig represents REST API configuration
+type APIConfig struct {
+	Enabled             bool  `yaml:"enabled"`
+	Port                int   `yaml:"port"`
+	MaxRequestBodyBytes int64 `yaml:"max_request_body_bytes"`
+	MaxBatchSize        int   `yaml:"max_batch_size"`
+	BatchMaxConcurrency int   `yaml:"batch_max_concurrency"`
+	BatchMaxBodyBytes   int64 `yaml:"batch_max_body_bytes"`
+	MaxMessageLength    int   `yaml:"max_message_length"`
+	MaxUploadBytes      int64 `yaml:"max_upload_bytes"`
+
+	// AdminToken is the bearer token of the admin endpoints; they are
+	// disabled while it is empty
+	AdminToken string `yaml:"admin_token"`
+	// DashboardAuditEntries is the number of recent events in the admin
+	// dashboard
+	DashboardAuditEntries int `yaml:"dashboard_audit_entries"`
+
+	Compression CompressionConfig `yaml:"compression"`
+}
+
+// CompressionConfig represents API response compression configuration
+type CompressionConfig struct {
+	Enabled         bool `yaml:"enabled"`
+	MinResponseSize int  `yaml:"min_response_size"`
+}
+
+// BrokerConfig represents the message broker shared by QuickBot
+// instances. An empty type runs a single instance without a broker.
+type BrokerConfig struct {
+	Type      string `yaml:"type"` // "" or "nats"
+	URL       string `yaml:"url"`
+	ClusterID string `yaml:"cluster_id"`
+}
+
 // LoadConfig loads configuration from a YAML file
 func LoadConfig(path string) (*Config, error) {
 	data, err := os.ReadFile(path)
@@ -89,7 +363,15 @@ func LoadConfig(path string) (*Config, error) {
 		return nil, fmt.Errorf("failed to read config file: %w", err)
 	}
 
+	// Options whose default is not their zero value are set before
+	// parsing: once parsed, a missing key can no longer be told apart from
+	// false or 0
 	var config Config
+	config.Platforms.Telegram.TypingIndicator = true
+	config.Platforms.Telegram.TopicIsolation = true
+	config.Platforms.Discord.AutoReconnect = true
+	config.AI.Temperature = 0.7
+
 	err = yaml.Unmarshal(data, &config)
 	if err != nil {
 		return nil, fmt.Errorf("failed to parse config: %w", err)
@@ -145,12 +427,33 @@ func (c *Config) applyDefaults() {
 	if c.AI.MaxTokens == 0 {
 		c.AI.MaxTokens = 2000
 	}
-	if c.AI.Temperature == 0 {
-		c.AI.Temperature = 0.7
-	}
 	if c.AI.BaseURL == "" && c.AI.Provider == "openai" {
 		c.AI.BaseURL = "https://api.openai.com/v1"
 	}
+	if c.AI.CacheTTL == 0 {
+		c.AI.CacheTTL = 10 * time.Minute
+	}
+	if c.AI.TimeoutSeconds == 0 {
+		c.AI.TimeoutSeconds = 30
+	}
+	if c.AI.MaxTimeoutSeconds == 0 {
+		c.AI.MaxTimeoutSeconds = 300
+	}
+	if c.AI.PromptLibraryDir == "" {
+		c.AI.PromptLibraryDir = "prompts/"
+	}
+	if c.AI.Retry.MaxAttempts == 0 {
+		c.AI.Retry.MaxAttempts = 3
+	}
+	if c.AI.Retry.MaxBackoff == 0 {
+		c.AI.Retry.MaxBackoff = 30 * time.Second
+	}
+	if c.AI.CircuitBreaker.Threshold == 0 {
+		c.AI.CircuitBreaker.Threshold = 5
+	}
+	if c.AI.CircuitBreaker.TimeoutSeconds == 0 {
+		c.AI.CircuitBreaker.TimeoutSeconds = 30
+	}
 
 	// Memory defaults
 	if c.Memory.MaxMessages == 0 {
@@ -159,16 +462,37 @@ func (c *Config) applyDefaults() {
 	if c.Memory.Storage == "" {
 		c.Memory.Storage = "memory.db"
 	}
+	if c.Memory.CleanupInterval == 0 {
+		c.Memory.CleanupInterval = 3600
+	}
+	if c.Memory.EmbeddingModel == "" {
+		c.Memory.EmbeddingModel = "text-embedding-3-small"
+	}
 
 	// Scheduler defaults
 	if c.Scheduler.Storage == "" {
 		c.Scheduler.Storage = "scheduler.db"
 	}
+	if c.Scheduler.HistoryRetentionDays == 0 {
+		c.Scheduler.HistoryRetentionDays = 30
+	}
 
 	// Tools defaults
 	if c.Tools.Directory == "" {
 		c.Tools.Directory = "tools/"
 	}
+	if c.Tools.DefinitionsDir == "" {
+		c.Tools.DefinitionsDir = "tools/definitions/"
+	}
+	if c.Tools.ShellTimeout == 0 {
+		c.Tools.ShellTimeout = 30 * time.Second
+	}
+	if c.Tools.MaxResultBytes == 0 {
+		c.Tools.MaxResultBytes = 32 * 1024
+	}
+	if c.Tools.ResultBufferTTL == 0 {
+		c.Tools.ResultBufferTTL = 10 * time.Minute
+	}
 
 	// Logging defaults
 	if c.Logging.Level == "" {
@@ -183,6 +507,83 @@ func (c *Config) applyDefaults() {
 	if c.Logging.BackupCount == 0 {
 		c.Logging.BackupCount = 5
 	}
+
+	// Telegram defaults
+	if c.Platforms.Telegram.MaxLength == 0 {
+		c.Platforms.Telegram.MaxLength = 4096
+	}
+	if c.Platforms.Telegram.TruncationStrategy == "" {
+		c.Platforms.Telegram.TruncationStrategy = "truncate"
+	}
+
+	// Discord defaults
+	if c.Platforms.Discord.ReconnectMaxAttempts == 0 {
+		c.Platforms.Discord.ReconnectMaxAttempts = 10
+	}
+	if c.Platforms.Discord.MaxLength == 0 {
+		c.Platforms.Discord.MaxLength = 2000
+	}
+	if c.Platforms.Discord.TruncationStrategy == "" {
+		c.Platforms.Discord.TruncationStrategy = "split"
+	}
+
+	// Slack defaults
+	if c.Platforms.Slack.MaxReconnectAttempts == 0 {
+		c.Platforms.Slack.MaxReconnectAttempts = 10
+	}
+	if c.Platforms.Slack.ReconnectBaseSeconds == 0 {
+		c.Platforms.Slack.ReconnectBaseSeconds = 1
+	}
+
+	// Webhook defaults
+	if c.Platforms.Webhook.MaxRetries == 0 {
+		c.Platforms.Webhook.MaxRetries = 3
+	}
+
+	// API defaults
+	if c.API.Port == 0 {
+		c.API.Port = 8080
+	}
+	if c.API.MaxRequestBodyBytes == 0 {
+		c.API.MaxRequestBodyBytes = 1024 * 1024 // 1MB
+	}
+	if c.API.MaxBatchSize == 0 {
+		c.API.MaxBatchSize = 50
+	}
+	if c.API.BatchMaxConcurrency == 0 {
+		c.API.BatchMaxConcurrency = 5
+	}
+	if c.API.BatchMaxBodyBytes == 0 {
+		c.API.BatchMaxBodyBytes = 10 * 1024 * 1024 // 10MB
+	}
+	if c.API.MaxMessageLength == 0 {
+		c.API.MaxMessageLength = 10000
+	}
+	if c.API.MaxUploadBytes == 0 {
+		c.API.MaxUploadBytes = 32 * 1024 * 1024 // 32MB
+	}
+	if c.API.DashboardAuditEntries == 0 {
+		c.API.DashboardAuditEntries = 20
+	}
+	if c.API.Compression.MinResponseSize == 0 {
+		c.API.Compression.MinResponseSize = 1024
+	}
+
+	// Workflow defaults
+	if c.Workflow.MaxConcurrentExecutions == 0 {
+		c.Workflow.MaxConcurrentExecutions = 5
+	}
+	if c.Workflow.QueueSize == 0 {
+		c.Workflow.QueueSize = 100
+	}
+	if c.Workflow.Storage == "" {
+		c.Workflow.Storage = "workflows.db"
+	}
+
+	// Broker defaults
+	if c.Broker.Type == "nats" && c.Broker.URL == "" {
+		c.Broker.URL = "nats://127.0.0.1:4222"
+	}
 }
 
 // Validate validates the configuration
@@ -197,12 +598,51 @@ func (c *Config) Validate() error {
 		return fmt.Errorf("AI provider cannot be empty")
 	}
 
-	if c.AI.Provider == "openai" || c.AI.Provider == "anthropic" {
+	if c.AI.Provider == "openai" || c.AI.Provider == "anthropic" || c.AI.Provider == "gemini" || c.AI.Provider == "mistral" {
 		if c.AI.APIKey == "" {
 			return fmt.Errorf("%s provider requires API key", c.AI.Provider)
 		}
 	}
 
+	if c.AI.TimeoutSeconds > c.AI.MaxTimeoutSeconds {
+		return fmt.Errorf("AI timeout_seconds cannot exceed max_timeout_seconds (%d)", c.AI.MaxTimeoutSeconds)
+	}
+
+	if c.AI.RetryBudget.MaxRetriesPerMinute < 0 || c.AI.RetryBudget.MaxTotalRetriesPerSession < 0 {
+		return fmt.Errorf("AI retry_budget limits cannot be negative")
+	}
+
+	for _, spec := range c.AI.FallbackProviders {
+		name, _, _ := strings.Cut(spec, ":")
+		if !validProviders[name] {
+			return fmt.Errorf("unknown AI fallback provider: %s", name)
+		}
+	}
+
+	if c.AI.RetryAttempts < 0 || c.AI.RetryMaxDelay < 0 {
+		return fmt.Errorf("AI retry_attempts and retry_max_delay cannot be negative")
+	}
+
+	if c.AI.CacheSize < 0 {
+		return fmt.Errorf("AI cache_size cannot be negative")
+	}
+
+	for _, name := range c.AI.PreProcessors {
+		if !validPreProcessors[name] {
+			return fmt.Errorf("unknown AI pre-processor: %s", name)
+		}
+		if name == "spelling" && c.AI.SpellingDictionary == "" {
+			return fmt.Errorf("spelling pre-processor requires spelling_dictionary")
+		}
+	}
+
+	if c.Tools.ShellTimeout < 0 {
+		return fmt.Errorf("tools shell_timeout cannot be negative")
+	}
+	if c.Tools.MaxResultBytes < 0 || c.Tools.ResultBufferTTL < 0 {
+		return fmt.Errorf("tools max_result_bytes and result_buffer_ttl cannot be negative")
+	}
+
 	// Validate platform configuration
 	if c.Platforms.Telegram.Enabled && c.Platforms.Telegram.Token == "" {
 		return fmt.Errorf("telegram enabled but token not configured")
@@ -210,6 +650,40 @@ func (c *Config) Validate() error {
 	if c.Platforms.Discord.Enabled && c.Platforms.Discord.Token == "" {
 		return fmt.Errorf("discord enabled but token not configured")
 	}
+	if c.Platforms.Slack.Enabled && (c.Platforms.Slack.AppToken == "" || c.Platforms.Slack.BotToken == "") {
+		return fmt.Errorf("slack enabled but app_token or bot_token not configured")
+	}
+	if c.Platforms.Webhook.Enabled && c.Platforms.Webhook.URL == "" {
+		return fmt.Errorf("webhook enabled but URL not configured")
+	}
+	for name, strategy := range map[string]string{
+		"telegram": c.Platforms.Telegram.TruncationStrategy,
+		"discord":  c.Platforms.Discord.TruncationStrategy,
+	} {
+		switch strategy {
+		case "", "truncate", "split", "summarize":
+		default:
+			return fmt.Errorf("%s: unknown truncation strategy: %s", name, strategy)
+		}
+	}
+
+	// Validate scheduler configuration
+	if c.Scheduler.MaxOverdueAlertThreshold < 0 {
+		return fmt.Errorf("scheduler max_overdue_alert_threshold cannot be negative")
+	}
+	if c.Scheduler.HistoryRetentionDays < 0 {
+		return fmt.Errorf("scheduler history_retention_days cannot be negative")
+	}
+
+	// Validate CLI configuration
+	if c.CLI.TypingDelay < 0 {
+		return fmt.Errorf("cli typing_delay cannot be negative")
+	}
+
+	// Validate broker configuration
+	if c.Broker.Type != "" && c.Broker.Type != "nats" {
+		return fmt.Errorf("unknown broker type: %s", c.Broker.Type)
+	}
 
 	return nil
 }
@@ -224,7 +698,28 @@ func DefaultConfig() *Config {
 		},
 		Platforms: PlatformsConfig{
 			Telegram: TelegramConfig{
-				Enabled: true,
+				Enabled:         true,
+				TypingIndicator: true,
+				TopicIsolation:  true,
+
+				MaxLength:          4096,
+				TruncationStrategy: "truncate",
+			},
+			Discord: DiscordConfig{
+				AutoReconnect:        true,
+				ReconnectMaxAttempts: 10,
+
+				MaxLength:          2000,
+				TruncationStrategy: "split",
+			},
+			Slack: SlackConfig{
+				MaxReconnectAttempts: 10,
+				ReconnectBaseSeconds: 1,
+			},
+			Webhook: WebhookPlatformConfig{
+				Events:     []string{"message.processed", "tool.executed"},
+				Retry:      true,
+				MaxRetries: 3,
 			},
 		},
 		AI: AIConfig{
@@ -233,19 +728,50 @@ func DefaultConfig() *Config {
 			MaxTokens:   2000,
 			Temperature: 0.7,
 			BaseURL:     "https://api.openai.com/v1",
+			CacheTTL:    10 * time.Minute,
+
+			TimeoutSeconds:    30,
+			MaxTimeoutSeconds: 300,
+
+			PromptLibraryDir: "prompts/",
+			Retry: RetryConfig{
+				Enabled:     true,
+				MaxAttempts: 3,
+				MaxBackoff:  30 * time.Second,
+			},
+			RetryBudget: RetryBudget{
+				MaxRetriesPerMinute:       10,
+				MaxTotalRetriesPerSession: 100,
+			},
+			CircuitBreaker: CircuitBreakerConfig{
+				Enabled:        true,
+				Threshold:      5,
+				TimeoutSeconds: 30,
+			},
+			PreProcessors: []string{"normalize"},
 		},
 		Memory: MemoryConfig{
 			Enabled:     true,
 			MaxMessages: 1000,
 			Storage:     "memory.db",
+
+			CleanupInterval: 3600,
+			EmbeddingModel:  "text-embedding-3-small",
+			Deduplicate:     true,
 		},
 		Scheduler: SchedulerConfig{
-			Enabled: true,
-			Storage: "scheduler.db",
+			Enabled:                  true,
+			Storage:                  "scheduler.db",
+			MaxOverdueAlertThreshold: 0,
+			HistoryRetentionDays:     30,
 		},
 		Tools: ToolsConfig{
-			Enabled:   true,
-			Directory: "tools/",
+			Enabled:         true,
+			Directory:       "tools/",
+			DefinitionsDir:  "tools/definitions/",
+			ShellTimeout:    30 * time.Second,
+			MaxResultBytes:  32 * 1024,
+			ResultBufferTTL: 10 * time.Minute,
 		},
 		Logging: LoggingConfig{
 			Level:       "INFO",
@@ -253,7 +779,88 @@ func DefaultConfig() *Config {
 			MaxSize:     10 * 1024 * 1024,
 			BackupCount: 5,
 		},
+		API: APIConfig{
+			Enabled:             false,
+			Port:                8080,
+			MaxRequestBodyBytes: 1024 * 1024,
+			MaxBatchSize:        50,
+			BatchMaxConcurrency: 5,
+			BatchMaxBodyBytes:   10 * 1024 * 1024,
+			MaxMessageLength:    10000,
+			MaxUploadBytes:      32 * 1024 * 1024,
+
+			DashboardAuditEntries: 20,
+			Compression: CompressionConfig{
+				Enabled:         true,
+				MinResponseSize: 1024,
+			},
+		},
+		Workflow: WorkflowConfig{
+			MaxConcurrentExecutions: 5,
+			QueueSize:               100,
+			Storage:                 "workflows.db",
+		},
+		Broker: BrokerConfig{
+			URL: "nats://127.0.0.1:4222",
+		},
+		CLI: CLIConfig{
+			ColorOutput: true,
+		},
+	}
+}
+
+// TestConfigDefaults loads configuration files that leave out options
+// with a non-zero default, or set them to false or 0
+func TestConfigDefaults() error {
+	dir, err := os.MkdirTemp("", "quickbot-config")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+
+	load := func(content string) (*Config, error) {
+		path := filepath.Join(dir, "config.yaml")
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			return nil, err
+		}
+		return LoadConfig(path)
+	}
+
+	cfg, err := load("platforms:\n  telegram:\n    enabled: true\n")
+	if err != nil {
+		return err
+	}
+	if !cfg.Platforms.Telegram.TypingIndicator {
+		return fmt.Errorf("expected typing_indicator to default to true")
+	}
+	if !cfg.Platforms.Telegram.TopicIsolation {
+		return fmt.Errorf("expected topic_isolation to default to true")
+	}
+	if !cfg.Platforms.Discord.AutoReconnect {
+		return fmt.Errorf("expected auto_reconnect to default to true")
 	}
+	if cfg.AI.Temperature != 0.7 {
+		return fmt.Errorf("expected temperature to default to 0.7, got %g", cfg.AI.Temperature)
+	}
+
+	cfg, err = load("platforms:\n  telegram:\n    typing_indicator: false\n    topic_isolation: false\n  discord:\n    auto_reconnect: false\nai:\n  temperature: 0\n")
+	if err != nil {
+		return err
+	}
+	if cfg.Platforms.Telegram.TypingIndicator {
+		return fmt.Errorf("expected typing_indicator: false to be kept")
+	}
+	if cfg.Platforms.Telegram.TopicIsolation {
+		return fmt.Errorf("expected topic_isolation: false to be kept")
+	}
+	if cfg.Platforms.Discord.AutoReconnect {
+		return fmt.Errorf("expected auto_reconnect: false to be kept")
+	}
+	if cfg.AI.Temperature != 0 {
+		return fmt.Errorf("expected temperature: 0 to be kept, got %g", cfg.AI.Temperature)
+	}
+
+	return nil
 }
 
 func main() {
@@ -0,0 +1,178 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// CheckResult is the outcome of one configuration health check
+type CheckResult struct {
+	Name    string
+	Passed  bool
+	Message string
+}
+
+// RunHealthChecks checks a loaded configuration without starting the
+// bot: validation, platform tokens, timezone, storage paths and the AI
+// base URL
+func RunHealthChecks(cfg *Config) []CheckResult {
+	var results []CheckResult
+	add := func(name string, err error, ok string) {
+		result := CheckResult{Name: name, Passed: err == nil, Message: ok}
+		if err != nil {
+			result.Message = err.Error()
+		}
+		results = append(results, result)
+	}
+
+	add("config", cfg.Validate(), "configuration is valid")
+
+	for _, platform := range []struct {
+		name    string
+		enabled bool
+		token   string
+	}{
+		{"telegram", cfg.Platforms.Telegram.Enabled, cfg.Platforms.Telegram.Token},
+		{"discord", cfg.Platforms.Discord.Enabled, cfg.Platforms.Discord.Token},
+		{"slack app", cfg.Platforms.Slack.Enabled, cfg.Platforms.Slack.AppToken},
+		{"slack bot", cfg.Platforms.Slack.Enabled, cfg.Platforms.Slack.BotToken},
+	} {
+		if !platform.enabled {
+			continue
+		}
+		var err error
+		if strings.TrimSpace(platform.token) == "" {
+			err = fmt.Errorf("%s is enabled but has no token", platform.name)
+		}
+		add(platform.name+" token", err, "token is set")
+	}
+
+	_, err := time.LoadLocation(cfg.Bot.Timezone)
+	if err != nil {
+		err = fmt.Errorf("invalid timezone %q: %w", cfg.Bot.Timezone, err)
+	}
+	add("timezone", err, cfg.Bot.Timezone)
+
+	if cfg.Memory.Enabled {
+		add("memory storage", checkWritable(cfg.Memory.Storage), cfg.Memory.Storage+" is writable")
+	}
+	if cfg.Scheduler.Enabled {
+		add("scheduler storage", checkWritable(cfg.Scheduler.Storage), cfg.Scheduler.Storage+" is writable")
+	}
+
+	baseURL := "not set, provider default"
+	if cfg.AI.BaseURL != "" {
+		baseURL = cfg.AI.BaseURL
+	}
+	add("AI base URL", checkURL(cfg.AI.BaseURL), baseURL)
+
+	return results
+}
+
+// checkWritable checks that a SQLite database file can be opened for
+// writing. A file created by the check is removed again.
+func checkWritable(path string) error {
+	if path == "" {
+		return fmt.Errorf("storage path is empty")
+	}
+
+	_, err := os.Stat(path)
+	existed := err == nil
+
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE, 0644)
+	if err != nil {
+		return fmt.Errorf("%s is not writable: %w", path, err)
+	}
+	file.Close()
+
+	if !existed {
+		os.Remove(path)
+	}
+	return nil
+}
+
+// checkURL checks that an optional base URL is an absolute http(s) URL
+func checkURL(raw string) error {
+	if raw == "" {
+		return nil
+	}
+
+	u, err := url.Parse(raw)
+	if err != nil {
+		return fmt.Errorf("invalid base URL: %w", err)
+	}
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("invalid base URL %q: must be an http or https URL", raw)
+	}
+	return nil
+}
+
+// TestHealthChecks runs the health checks on passing and failing
+// configurations
+func TestHealthChecks() error {
+	dir, err := os.MkdirTemp("", "quickbot-config")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+
+	valid := func() *Config {
+		cfg := DefaultConfig()
+		cfg.AI.APIKey = "key"
+		cfg.Platforms.Telegram.Token = "token"
+		cfg.Memory.Storage = filepath.Join(dir, "memory.db")
+		cfg.Scheduler.Storage = filepath.Join(dir, "scheduler.db")
+		return cfg
+	}
+
+	failed := func(cfg *Config) []string {
+		var names []string
+		for _, result := range RunHealthChecks(cfg) {
+			if !result.Passed {
+				names = append(names, result.Name)
+			}
+		}
+		return names
+	}
+
+	if names := failed(valid()); len(names) != 0 {
+		return fmt.Errorf("valid config failed checks: %v", names)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "memory.db")); !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("storage check left a file behind")
+	}
+
+	cases := []struct {
+		check  string
+		modify func(cfg *Config)
+	}{
+		{"config", func(cfg *Config) { cfg.AI.APIKey = "" }},
+		{"discord token", func(cfg *Config) { cfg.Platforms.Discord.Enabled = true }},
+		{"timezone", func(cfg *Config) { cfg.Bot.Timezone = "Mars/Olympus_Mons" }},
+		{"memory storage", func(cfg *Config) { cfg.Memory.Storage = filepath.Join(dir, "missing", "memory.db") }},
+		{"scheduler storage", func(cfg *Config) { cfg.Scheduler.Storage = "" }},
+		{"AI base URL", func(cfg *Config) { cfg.AI.BaseURL = "api.openai.com/v1" }},
+	}
+	for _, c := range cases {
+		cfg := valid()
+		c.modify(cfg)
+
+		found := false
+		for _, name := range failed(cfg) {
+			if name == c.check {
+				found = true
+			}
+		}
+		if !found {
+			return fmt.Errorf("%s check passed, expected it to fail", c.check)
+		}
+		fmt.Printf("✓ %s check fails\n", c.check)
+	}
+
+	return nil
+}
@@ -0,0 +1,167 @@
+package events
+
+import (
+	"log"
+	"sync"
+	"time"
+)
+
+// Topics published by QuickBot
+const (
+	TopicMessageReceived  = "message.received"
+	TopicMessageProcessed = "message.processed"
+	TopicToolExecuted     = "tool.executed"
+	TopicTaskCompleted    = "task.completed"
+	TopicSessionCreated   = "session.created"
+	TopicAIResponse       = "ai.response"
+
+	// TopicAll subscribes to every topic
+	TopicAll = "*"
+)
+
+// subscriberBufferSize is the number of events queued per subscriber
+// before further events are dropped
+const subscriberBufferSize = 100
+
+// Event is a message published on the event bus
+type Event struct {
+	Topic     string
+	Payload   interface{}
+	Timestamp time.Time
+}
+
+// MessagePayload is the payload of message, tool and AI response events
+type MessagePayload struct {
+	SessionID string `json:"session_id"`
+	Content   string `json:"content"`
+}
+
+// SessionPayload is the payload of session events
+type SessionPayload struct {
+	SessionID string `json:"session_id"`
+	Platform  string `json:"platform"`
+	UserID    string `json:"user_id"`
+}
+
+// TaskPayload is the payload of task events
+type TaskPayload struct {
+	TaskID    string `json:"task_id"`
+	Name      string `json:"name"`
+	SessionID string `json:"session_id"`
+	Result    string `json:"result"`
+	Error     string `json:"error,omitempty"`
+}
+
+// Subscription is a handler registered on the event bus
+type Subscription interface {
+	// Unsubscribe stops delivering events to the handler. Events already
+	// queued are still delivered.
+	Unsubscribe()
+}
+
+// EventBus delivers published events to subscribers by topic. Each
+// subscriber has its own buffered queue and goroutine, so a slow handler
+// does not block publishers or other subscribers.
+type EventBus struct {
+	subscribers map[string]map[*subscriber]struct{}
+	mu          sync.RWMutex
+}
+
+// subscriber is a handler and its event queue
+type subscriber struct {
+	bus     *EventBus
+	topic   string
+	handler func(Event)
+	queue   chan Event
+	once    sync.Once
+}
+
+// NewEventBus creates a new event bus
+func NewEventBus() *EventBus {
+	return &EventBus{
+		subscribers: make(map[string]map[*subscriber]struct{}),
+	}
+}
+
+// Subscribe registers a handler for a topic; TopicAll subscribes to all
+// topics
+func (b *EventBus) Subscribe(topic string, handler func(Event)) Subscription {
+	sub := &subscriber{
+		bus:     b,
+		topic:   topic,
+		handler: handler,
+		queue:   make(chan Event, subscriberBufferSize),
+	}
+
+	b.mu.Lock()
+	if b.subscribers[topic] == nil {
+		b.subscribers[topic] = make(map[*subscriber]struct{})
+	}
+	b.subscribers[topic][sub] = struct{}{}
+	b.mu.Unlock()
+
+	go sub.run()
+
+	return sub
+}
+
+// Publish queues an event for its subscribers. It does not wait for the
+// handlers; events for a subscriber whose queue is full are dropped.
+func (b *EventBus) Publish(event Event) {
+	if event.Timestamp.IsZero() {
+		event.Timestamp = time.Now()
+	}
+
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	b.deliver(b.subscribers[event.Topic], event)
+	if event.Topic != TopicAll {
+		b.deliver(b.subscribers[TopicAll], event)
+	}
+}
+
+// deliver queues an event for each subscriber without blocking. It must
+// be called with mu held.
+func (b *EventBus) deliver(subscribers map[*subscriber]struct{}, event Event) {
+	for sub := range subscribers {
+		select {
+		case sub.queue <- event:
+		default:
+			log.Printf("Warning: event queue full, dropping %s event for %s subscriber", event.Topic, sub.topic)
+		}
+	}
+}
+
+// Unsubscribe removes the subscriber and stops its goroutine once its
+// queue is drained
+func (s *subscriber) Unsubscribe() {
+	s.once.Do(func() {
+		s.bus.mu.Lock()
+		delete(s.bus.subscribers[s.topic], s)
+		if len(s.bus.subscribers[s.topic]) == 0 {
+			delete(s.bus.subscribers, s.topic)
+		}
+		s.bus.mu.Unlock()
+
+		close(s.queue)
+	})
+}
+
+// run calls the handler for each queued event
+func (s *subscriber) run() {
+	for event := range s.queue {
+		s.handle(event)
+	}
+}
+
+// handle calls the handler, recovering from panics so one bad event does
+// not stop the subscription
+func (s *subscriber) handle(event Event) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("Event handler for %s panicked: %v", event.Topic, r)
+		}
+	}()
+	s.handler(event)
+}
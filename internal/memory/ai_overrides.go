@@ -0,0 +1,40 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/Chang-Augenweide/QuickBot-Go/pkg/types"
+)
+
+// aiOverridesKey is the session_config key of the AI parameter overrides
+const aiOverridesKey = "ai_overrides"
+
+// SetAIOverrides stores the AI parameter overrides of a session. Zero
+// overrides clear them.
+func (m *Memory) SetAIOverrides(sessionID string, overrides types.AIOverrides) error {
+	if overrides.IsZero() {
+		return m.SetSessionConfig(sessionID, aiOverridesKey, "")
+	}
+
+	data, err := json.Marshal(overrides)
+	if err != nil {
+		return fmt.Errorf("failed to marshal AI overrides: %w", err)
+	}
+	return m.SetSessionConfig(sessionID, aiOverridesKey, string(data))
+}
+
+// GetAIOverrides returns the AI parameter overrides of a session, or nil
+// if none are set
+func (m *Memory) GetAIOverrides(sessionID string) (*types.AIOverrides, error) {
+	value, err := m.GetSessionConfig(sessionID, aiOverridesKey)
+	if err != nil || value == "" {
+		return nil, err
+	}
+
+	var overrides types.AIOverrides
+	if err := json.Unmarshal([]byte(value), &overrides); err != nil {
+		return nil, fmt.Errorf("failed to parse AI overrides: %w", err)
+	}
+	return &overrides, nil
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"fmt"
+	"os"
+)
+
+// Analytics holds the totals of the memory database
+type Analytics struct {
+	Sessions        int   `json:"sessions"`
+	Messages        int   `json:"messages"`
+	LongTermEntries int   `json:"long_term_entries"`
+	DatabaseBytes   int64 `json:"database_bytes"`
+}
+
+// GetAnalytics counts the sessions, messages and long-term memories and
+// returns the size of the database file
+func (m *Memory) GetAnalytics() (*Analytics, error) {
+	var analytics Analytics
+	err := m.conn.QueryRow(`
+		SELECT
+			(SELECT COUNT(*) FROM sessions),
+			(SELECT COUNT(*) FROM messages),
+			(SELECT COUNT(*) FROM long_term_memory)
+	`).Scan(&analytics.Sessions, &analytics.Messages, &analytics.LongTermEntries)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count memory: %w", err)
+	}
+
+	info, err := os.Stat(m.path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to stat database file: %w", err)
+	}
+	analytics.DatabaseBytes = info.Size()
+
+	return &analytics, nil
+}
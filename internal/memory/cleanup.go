@@ -0,0 +1,319 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"os"
+	"time"
+)
+
+// sqliteTimeFormat matches the format of CURRENT_TIMESTAMP
+const sqliteTimeFormat = "2006-01-02 15:04:05"
+
+// CleanupPolicy configures the background cleanup worker. Zero values
+// disable the corresponding step.
+type CleanupPolicy struct {
+	SessionMaxAge         time.Duration
+	LongTermMaxAge        time.Duration
+	MaxMessagesPerSession int
+	Deduplicate           bool
+	RunInterval           time.Duration
+}
+
+// CleanupStats reports the rows deleted by a cleanup run
+type CleanupStats struct {
+	SessionMessages int64
+	Sessions        int64
+	LongTerm        int64
+	ExcessMessages  int64
+	LimitedSessions int
+	Dedup           DedupStats
+}
+
+// StartCleanupWorker runs Cleanup every RunInterval until ctx is done
+func (m *Memory) StartCleanupWorker(ctx context.Context, policy CleanupPolicy) {
+	if policy.RunInterval <= 0 {
+		log.Printf("Warning: memory cleanup disabled (invalid run interval %v)", policy.RunInterval)
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(policy.RunInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				stats, err := m.Cleanup(policy)
+				if err != nil {
+					log.Printf("Memory cleanup failed: %v", err)
+					continue
+				}
+				if stats.SessionMessages+stats.Sessions+stats.LongTerm+stats.ExcessMessages > 0 {
+					log.Printf("Memory cleanup: deleted %d messages and %d sessions from inactive sessions, %d long-term memories, %d messages over the limit in %d sessions",
+						stats.SessionMessages, stats.Sessions, stats.LongTerm, stats.ExcessMessages, stats.LimitedSessions)
+				}
+				if policy.Deduplicate {
+					log.Printf("Memory deduplication: deleted %d duplicate messages, %d duplicate long-term memories",
+						stats.Dedup.Messages, stats.Dedup.LongTerm)
+				}
+			}
+		}
+	}()
+}
+
+// Cleanup applies a cleanup policy in a single transaction
+func (m *Memory) Cleanup(policy CleanupPolicy) (CleanupStats, error) {
+	var stats CleanupStats
+
+	err := m.withTx(func(tx *sql.Tx) error {
+		var err error
+
+		if policy.SessionMaxAge > 0 {
+			stats.SessionMessages, stats.Sessions, err = pruneInactiveSessions(tx, policy.SessionMaxAge)
+			if err != nil {
+				return err
+			}
+		}
+
+		if policy.LongTermMaxAge > 0 {
+			stats.LongTerm, err = pruneLongTerm(tx, policy.LongTermMaxAge)
+			if err != nil {
+				return err
+			}
+		}
+
+		if policy.MaxMessagesPerSession > 0 {
+			sessionIDs, err := sessionsOverLimit(tx, policy.MaxMessagesPerSession)
+			if err != nil {
+				return err
+			}
+			for _, sessionID := range sessionIDs {
+				deleted, err := enforceMessageLimit(tx, sessionID, policy.MaxMessagesPerSession)
+				if err != nil {
+					return err
+				}
+				stats.ExcessMessages += deleted
+			}
+			stats.LimitedSessions = len(sessionIDs)
+		}
+
+		if policy.Deduplicate {
+			stats.Dedup.Messages, err = dedupMessages(tx, "")
+			if err != nil {
+				return err
+			}
+			stats.Dedup.LongTerm, err = dedupLongTerm(tx)
+			if err != nil {
+				return err
+			}
+		}
+
+		return pruneOrphanEmbeddings(tx)
+	})
+
+	return stats, err
+}
+
+// PruneInactiveSessions deletes sessions, and their messages, with no
+// activity for longer than maxAge. It returns the number of messages deleted.
+func (m *Memory) PruneInactiveSessions(maxAge time.Duration) (int64, error) {
+	var deleted int64
+	err := m.withTx(func(tx *sql.Tx) error {
+		var err error
+		deleted, _, err = pruneInactiveSessions(tx, maxAge)
+		return err
+	})
+	return deleted, err
+}
+
+// PruneLongTerm deletes long-term memories not updated for longer than maxAge
+func (m *Memory) PruneLongTerm(maxAge time.Duration) (int64, error) {
+	var deleted int64
+	err := m.withTx(func(tx *sql.Tx) error {
+		var err error
+		deleted, err = pruneLongTerm(tx, maxAge)
+		return err
+	})
+	return deleted, err
+}
+
+// EnforceMessageLimit deletes the oldest messages of a session beyond max
+func (m *Memory) EnforceMessageLimit(sessionID string, max int) (int64, error) {
+	var deleted int64
+	err := m.withTx(func(tx *sql.Tx) error {
+		var err error
+		deleted, err = enforceMessageLimit(tx, sessionID, max)
+		return err
+	})
+	return deleted, err
+}
+
+// withTx runs fn in a transaction, committing if it succeeds
+func (m *Memory) withTx(fn func(tx *sql.Tx) error) error {
+	tx, err := m.conn.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := fn(tx); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+func pruneInactiveSessions(tx *sql.Tx, maxAge time.Duration) (int64, int64, error) {
+	cutoff := time.Now().UTC().Add(-maxAge).Format(sqliteTimeFormat)
+
+	result, err := tx.Exec(`
+		DELETE FROM messages WHERE session_id IN (
+			SELECT session_id FROM messages
+			GROUP BY session_id HAVING MAX(timestamp) < ?
+		)
+	`, cutoff)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to prune session messages: %w", err)
+	}
+	messages, _ := result.RowsAffected()
+
+	_, err = tx.Exec(`
+		DELETE FROM tags WHERE session_id IN (
+			SELECT id FROM sessions
+			WHERE updated_at < ? AND id NOT IN (SELECT DISTINCT session_id FROM messages)
+		)
+	`, cutoff)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to prune session tags: %w", err)
+	}
+
+	result, err = tx.Exec(`
+		DELETE FROM sessions
+		WHERE updated_at < ? AND id NOT IN (SELECT DISTINCT session_id FROM messages)
+	`, cutoff)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to prune sessions: %w", err)
+	}
+	sessions, _ := result.RowsAffected()
+
+	return messages, sessions, nil
+}
+
+func pruneLongTerm(tx *sql.Tx, maxAge time.Duration) (int64, error) {
+	cutoff := time.Now().UTC().Add(-maxAge).Format(sqliteTimeFormat)
+
+	result, err := tx.Exec(`DELETE FROM long_term_memory WHERE updated_at < ?`, cutoff)
+	if err != nil {
+		return 0, fmt.Errorf("failed to prune long-term memory: %w", err)
+	}
+	deleted, _ := result.RowsAffected()
+	return deleted, nil
+}
+
+// pruneOrphanEmbeddings deletes the embeddings of deleted messages
+func pruneOrphanEmbeddings(tx *sql.Tx) error {
+	_, err := tx.Exec(`
+		DELETE FROM messages_embeddings WHERE message_id NOT IN (SELECT id FROM messages)
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to prune message embeddings: %w", err)
+	}
+	return nil
+}
+
+func sessionsOverLimit(tx *sql.Tx, max int) ([]string, error) {
+	rows, err := tx.Query(`
+		SELECT session_id FROM messages
+		GROUP BY session_id HAVING COUNT(*) > ?
+	`, max)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query session sizes: %w", err)
+	}
+	defer rows.Close()
+
+	var sessionIDs []string
+	for rows.Next() {
+		var sessionID string
+		if err := rows.Scan(&sessionID); err != nil {
+			return nil, fmt.Errorf("failed to scan session: %w", err)
+		}
+		sessionIDs = append(sessionIDs, sessionID)
+	}
+	return sessionIDs, rows.Err()
+}
+
+func enforceMessageLimit(tx *sql.Tx, sessionID string, max int) (int64, error) {
+	result, err := tx.Exec(`
+		DELETE FROM messages WHERE session_id = ? AND id NOT IN (
+			SELECT id FROM messages WHERE session_id = ?
+			ORDER BY id DESC LIMIT ?
+		)
+	`, sessionID, sessionID, max)
+	if err != nil {
+		return 0, fmt.Errorf("failed to enforce message limit: %w", err)
+	}
+	deleted, _ := result.RowsAffected()
+	return deleted, nil
+}
+
+// TestCleanupWorker checks that the cleanup worker trims a session to the
+// message limit, keeping its newest messages
+func TestCleanupWorker() error {
+	log.Println("Testing memory cleanup worker...")
+
+	dbPath := "test_cleanup_worker.db"
+	defer os.Remove(dbPath)
+
+	mem, err := NewMemory(dbPath, 100)
+	if err != nil {
+		return err
+	}
+	defer mem.Close()
+
+	for i := 0; i < 150; i++ {
+		if _, _, err := mem.AddMessage("busy", "user", fmt.Sprintf("message %d", i), nil); err != nil {
+			return err
+		}
+	}
+	for i := 0; i < 10; i++ {
+		if _, _, err := mem.AddMessage("quiet", "user", fmt.Sprintf("message %d", i), nil); err != nil {
+			return err
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	mem.StartCleanupWorker(ctx, CleanupPolicy{
+		MaxMessagesPerSession: 100,
+		RunInterval:           10 * time.Millisecond,
+	})
+	time.Sleep(50 * time.Millisecond)
+
+	count := func(sessionID string) (int, error) {
+		var n int
+		err := mem.conn.QueryRow(`SELECT COUNT(*) FROM messages WHERE session_id = ?`, sessionID).Scan(&n)
+		return n, err
+	}
+	if n, err := count("busy"); err != nil || n != 100 {
+		return fmt.Errorf("expected 100 messages left, got %d (%v)", n, err)
+	}
+	if n, err := count("quiet"); err != nil || n != 10 {
+		return fmt.Errorf("expected the session under the limit to keep 10 messages, got %d (%v)", n, err)
+	}
+	var oldest string
+	err = mem.conn.QueryRow(`SELECT content FROM messages WHERE session_id = 'busy' ORDER BY id LIMIT 1`).Scan(&oldest)
+	if err != nil || oldest != "message 50" {
+		return fmt.Errorf("expected the oldest 50 messages to be deleted, oldest left is %q (%v)", oldest, err)
+	}
+	log.Println("✓ Session trimmed to its 100 newest messages")
+
+	return nil
+}
@@ -0,0 +1,273 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+)
+
+// redeliveryWindow is how long after a user message an identical one
+// without a platform message ID is taken as the platform redelivering it,
+// e.g. after a reconnect
+const redeliveryWindow = 5 * time.Second
+
+// redeliveringPlatforms are the platforms known to deliver a message
+// again, e.g. after a reconnect. Messages from other platforms, such as
+// the API and the CLI, are never deduplicated by content.
+var redeliveringPlatforms = []string{"telegram", "discord", "slack"}
+
+// redelivers reports whether messages from platform may be redelivered
+func redelivers(platform interface{}) bool {
+	for _, name := range redeliveringPlatforms {
+		if platform == name {
+			return true
+		}
+	}
+	return false
+}
+
+// redeliveringPlatformsSQL returns the redelivering platforms as an SQL
+// list of placeholders and its arguments
+func redeliveringPlatformsSQL() (string, []interface{}) {
+	placeholders := make([]string, len(redeliveringPlatforms))
+	args := make([]interface{}, len(redeliveringPlatforms))
+	for i, name := range redeliveringPlatforms {
+		placeholders[i] = "?"
+		args[i] = name
+	}
+	return "(" + strings.Join(placeholders, ", ") + ")", args
+}
+
+// DedupStats reports the duplicate rows removed by a deduplication pass
+type DedupStats struct {
+	Messages int64
+	LongTerm int64
+}
+
+// DeduplicateMessages deletes the messages of a session that redeliver
+// an earlier message, as matched by AddMessage. An empty sessionID
+// deduplicates all sessions. It returns the number of messages deleted.
+func (m *Memory) DeduplicateMessages(sessionID string) (int, error) {
+	var deleted int64
+	err := m.withTx(func(tx *sql.Tx) error {
+		var err error
+		deleted, err = dedupMessages(tx, sessionID)
+		if err != nil {
+			return err
+		}
+		return pruneOrphanEmbeddings(tx)
+	})
+	return int(deleted), err
+}
+
+// DeduplicateLongTerm collapses long-term memories whose keys differ only
+// in case or surrounding whitespace, keeping the most important one. It
+// returns the number of memories deleted.
+func (m *Memory) DeduplicateLongTerm() (int, error) {
+	var deleted int64
+	err := m.withTx(func(tx *sql.Tx) error {
+		var err error
+		deleted, err = dedupLongTerm(tx)
+		return err
+	})
+	return int(deleted), err
+}
+
+// dropMessageUniqueness drops the unique index on message content that
+// earlier versions created, which dropped repeated messages
+func (m *Memory) dropMessageUniqueness() error {
+	_, err := m.conn.Exec(`DROP INDEX IF EXISTS idx_messages_unique`)
+	if err != nil {
+		return fmt.Errorf("failed to drop messages unique index: %w", err)
+	}
+	return nil
+}
+
+// redeliveryCondition returns the condition on messages matching the
+// stored message that a new message redelivers. A message with a platform
+// message ID redelivers the message with the same ID; a user message
+// without one, from a platform that redelivers, redelivers the same
+// content stored within redeliveryWindow. Other messages are never
+// redeliveries, and ok is false.
+func redeliveryCondition(sessionID, role, content string, metadata map[string]interface{}) (condition string, args []interface{}, ok bool) {
+	if platformID, exists := metadata[MetaPlatformMessageID]; exists {
+		return `session_id = ? AND id IN (
+			SELECT message_id FROM message_metadata WHERE key = ? AND CAST(value AS TEXT) = ?
+		)`, []interface{}{sessionID, MetaPlatformMessageID, fmt.Sprint(platformID)}, true
+	}
+	if role != "user" || !redelivers(metadata[MetaPlatform]) {
+		return "", nil, false
+	}
+	return `session_id = ? AND role = 'user' AND content = ? AND timestamp >= datetime('now', ?)`,
+		[]interface{}{sessionID, content, windowModifier()}, true
+}
+
+// windowModifier returns redeliveryWindow as an SQLite datetime modifier
+func windowModifier() string {
+	return fmt.Sprintf("-%d seconds", int(redeliveryWindow.Seconds()))
+}
+
+// dedupMessages deletes redelivered messages: later messages with the
+// platform message ID of an earlier one, and user messages without one
+// from a redelivering platform repeating the previous identical message
+// within redeliveryWindow
+func dedupMessages(tx *sql.Tx, sessionID string) (int64, error) {
+	platforms, platformArgs := redeliveringPlatformsSQL()
+	query := `
+		DELETE FROM messages WHERE id IN (
+			SELECT later.id FROM messages AS later
+			JOIN messages AS earlier ON earlier.session_id = later.session_id
+				AND earlier.role = later.role AND earlier.content = later.content AND earlier.id < later.id
+			WHERE later.role = 'user'
+				AND (julianday(later.timestamp) - julianday(earlier.timestamp)) * 86400 BETWEEN 0 AND ?
+				AND later.id NOT IN (SELECT message_id FROM message_metadata WHERE key = ?)
+				AND later.id IN (SELECT message_id FROM message_metadata WHERE key = ? AND value IN ` + platforms + `)
+				AND (? = '' OR later.session_id = ?)
+			UNION
+			SELECT later.message_id FROM message_metadata AS later
+			JOIN message_metadata AS earlier ON earlier.key = later.key
+				AND earlier.value = later.value AND earlier.message_id < later.message_id
+			JOIN messages AS later_message ON later_message.id = later.message_id
+			JOIN messages AS earlier_message ON earlier_message.id = earlier.message_id
+				AND earlier_message.session_id = later_message.session_id
+			WHERE later.key = ? AND (? = '' OR later_message.session_id = ?)
+		)
+	`
+	args := []interface{}{redeliveryWindow.Seconds(), MetaPlatformMessageID, MetaPlatform}
+	args = append(args, platformArgs...)
+	args = append(args, sessionID, sessionID, MetaPlatformMessageID, sessionID, sessionID)
+	result, err := tx.Exec(query, args...)
+	if err != nil {
+		return 0, fmt.Errorf("failed to deduplicate messages: %w", err)
+	}
+	deleted, _ := result.RowsAffected()
+	return deleted, nil
+}
+
+func dedupLongTerm(tx *sql.Tx) (int64, error) {
+	result, err := tx.Exec(`
+		DELETE FROM long_term_memory WHERE id IN (
+			SELECT id FROM (
+				SELECT id, ROW_NUMBER() OVER (
+					PARTITION BY LOWER(TRIM(key))
+					ORDER BY importance DESC, updated_at DESC, id DESC
+				) AS rank
+				FROM long_term_memory
+			) WHERE rank > 1
+		)
+	`)
+	if err != nil {
+		return 0, fmt.Errorf("failed to deduplicate long-term memory: %w", err)
+	}
+	deleted, _ := result.RowsAffected()
+	return deleted, nil
+}
+
+// TestMessageDeduplication checks that redelivered messages are stored
+// once while repeated messages are all kept
+func TestMessageDeduplication() error {
+	log.Println("Testing message deduplication...")
+
+	dir, err := os.MkdirTemp("", "quickbot-dedup")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+
+	mem, err := NewMemory(dir+"/dedup.db", 100)
+	if err != nil {
+		return err
+	}
+	defer mem.Close()
+
+	telegram := map[string]interface{}{MetaPlatform: "telegram"}
+	var ids []int64
+	for i := 0; i < 5; i++ {
+		id, redelivered, err := mem.AddMessage("dedup", "user", "hello", telegram)
+		if err != nil {
+			return err
+		}
+		if redelivered != (i > 0) {
+			return fmt.Errorf("message %d: expected redelivered %v, got %v", i, i > 0, redelivered)
+		}
+		ids = append(ids, id)
+	}
+	for _, id := range ids[1:] {
+		if id != ids[0] {
+			return fmt.Errorf("expected redeliveries to return message %d, got %d", ids[0], id)
+		}
+	}
+	log.Println("✓ Redelivered user message stored once")
+
+	// The API and the CLI have no platform message IDs and do not
+	// redeliver, so their repeated messages are all kept
+	for i := 0; i < 2; i++ {
+		if _, redelivered, err := mem.AddMessage("dedup_api", "user", "hello", nil); err != nil || redelivered {
+			return fmt.Errorf("expected repeated API message to be stored, got redelivered %v (%v)", redelivered, err)
+		}
+	}
+	if messages, err := mem.GetMessages("dedup_api", 0); err != nil || len(messages) != 2 {
+		return fmt.Errorf("expected 2 API messages, got %d (%v)", len(messages), err)
+	}
+	log.Println("✓ Repeated API messages kept")
+
+	var yesIDs []int64
+	for _, platformID := range []int{100, 101, 100} {
+		id, _, err := mem.AddMessage("dedup", "user", "yes", map[string]interface{}{MetaPlatform: "telegram", MetaPlatformMessageID: platformID})
+		if err != nil {
+			return err
+		}
+		yesIDs = append(yesIDs, id)
+	}
+	if yesIDs[0] == yesIDs[1] || yesIDs[2] != yesIDs[0] {
+		return fmt.Errorf("expected only the redelivered platform message to be dropped, got IDs %v", yesIDs)
+	}
+
+	var callIDs []int64
+	for i := 0; i < 2; i++ {
+		id, _, err := mem.AddMessage("dedup", "assistant", "[Tool call: calculator]", map[string]interface{}{MetaToolName: "calculator"})
+		if err != nil {
+			return err
+		}
+		callIDs = append(callIDs, id)
+	}
+	if callIDs[0] == callIDs[1] {
+		return fmt.Errorf("expected repeated tool calls to be stored separately")
+	}
+
+	messages, err := mem.GetMessages("dedup", 0)
+	if err != nil {
+		return err
+	}
+	if len(messages) != 5 {
+		return fmt.Errorf("expected 5 messages, got %d", len(messages))
+	}
+	log.Println("✓ Repeated messages with distinct platform IDs and tool calls kept")
+
+	// Rows stored before deduplication on insert, e.g. by an import; the
+	// API messages are not redeliveries
+	_, err = mem.conn.Exec(`
+		INSERT INTO messages (session_id, role, content, metadata, timestamp) VALUES
+			('dedup', 'user', 'again', '{"platform": "telegram"}', datetime('now', '-1 minute')),
+			('dedup', 'user', 'again', '{"platform": "telegram"}', datetime('now', '-1 minute')),
+			('dedup', 'user', 'again', '{"platform": "telegram"}', datetime('now')),
+			('dedup', 'user', 'api', '{}', datetime('now')),
+			('dedup', 'user', 'api', '{}', datetime('now'))
+	`)
+	if err != nil {
+		return err
+	}
+	deleted, err := mem.DeduplicateMessages("dedup")
+	if err != nil {
+		return err
+	}
+	if deleted != 1 {
+		return fmt.Errorf("expected 1 redelivered message deleted, got %d", deleted)
+	}
+	log.Println("✓ Deduplication keeps messages repeated after the window")
+
+	return nil
+}
@@ -0,0 +1,379 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/binary"
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// embeddingTimeout bounds the embedding request made when storing a message
+const embeddingTimeout = 10 * time.Second
+
+// EmbeddingProvider turns text into embedding vectors
+type EmbeddingProvider interface {
+	GetEmbedding(ctx context.Context, text string) ([]float64, error)
+}
+
+// SetEmbeddingProvider enables storing an embedding for each new message
+// and long-term memory, used by SemanticSearch and SearchSimilar. A nil
+// provider disables it.
+func (m *Memory) SetEmbeddingProvider(provider EmbeddingProvider) {
+	m.embeddings = provider
+}
+
+// storeEmbedding embeds a message and stores the vector. Failures are
+// logged and do not fail the message insert.
+func (m *Memory) storeEmbedding(messageID int64, content string) {
+	ctx, cancel := context.WithTimeout(context.Background(), embeddingTimeout)
+	defer cancel()
+
+	vector, err := m.embeddings.GetEmbedding(ctx, content)
+	if err != nil {
+		log.Printf("Warning: Failed to embed message %d: %v", messageID, err)
+		return
+	}
+
+	_, err = m.conn.Exec(`
+		INSERT OR REPLACE INTO messages_embeddings (message_id, embedding) VALUES (?, ?)
+	`, messageID, encodeEmbedding(vector))
+	if err != nil {
+		log.Printf("Warning: Failed to store embedding of message %d: %v", messageID, err)
+	}
+}
+
+// storeLongTermEmbedding embeds a long-term memory value and stores the
+// vector. Failures are logged and do not fail the long-term memory update.
+func (m *Memory) storeLongTermEmbedding(key, value string) {
+	ctx, cancel := context.WithTimeout(context.Background(), embeddingTimeout)
+	defer cancel()
+
+	vector, err := m.embeddings.GetEmbedding(ctx, value)
+	if err != nil {
+		log.Printf("Warning: Failed to embed long-term memory %s: %v", key, err)
+		return
+	}
+
+	_, err = m.conn.Exec(`
+		UPDATE long_term_memory SET embedding = ? WHERE key = ? AND value = ?
+	`, encodeEmbedding(vector), key, value)
+	if err != nil {
+		log.Printf("Warning: Failed to store embedding of long-term memory %s: %v", key, err)
+	}
+}
+
+// SearchSimilar returns the topK long-term memories most similar to query
+// by cosine similarity of their embeddings, most similar first, leaving
+// out expired ones. Only memories stored while an embedding provider was
+// set are searched.
+func (m *Memory) SearchSimilar(query string, topK int) ([]LongTermEntry, error) {
+	if m.embeddings == nil {
+		return nil, fmt.Errorf("embedding provider is required")
+	}
+	if topK <= 0 {
+		return nil, fmt.Errorf("topK must be positive")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), embeddingTimeout)
+	defer cancel()
+
+	queryVector, err := m.embeddings.GetEmbedding(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to embed query: %w", err)
+	}
+
+	rows, err := m.conn.Query(`
+		SELECT key, value, importance, created_at, updated_at, embedding
+		FROM long_term_memory
+		WHERE embedding IS NOT NULL AND (expires_at IS NULL OR expires_at >= ?)
+	`, expiryTime(time.Now()))
+	if err != nil {
+		return nil, fmt.Errorf("failed to load embeddings: %w", err)
+	}
+	defer rows.Close()
+
+	type scoredEntry struct {
+		entry LongTermEntry
+		score float64
+	}
+
+	var scored []scoredEntry
+	for rows.Next() {
+		var entry LongTermEntry
+		var createdAt, updatedAt string
+		var blob []byte
+		if err := rows.Scan(&entry.Key, &entry.Value, &entry.Importance, &createdAt, &updatedAt, &blob); err != nil {
+			return nil, fmt.Errorf("failed to scan long-term memory: %w", err)
+		}
+		entry.CreatedAt, _ = time.Parse(sqliteTimeFormat, createdAt)
+		entry.UpdatedAt, _ = time.Parse(sqliteTimeFormat, updatedAt)
+
+		vector := decodeEmbedding(blob)
+		if len(vector) != len(queryVector) {
+			continue
+		}
+		scored = append(scored, scoredEntry{entry: entry, score: cosineSimilarity(queryVector, vector)})
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to load embeddings: %w", err)
+	}
+
+	sort.SliceStable(scored, func(i, j int) bool {
+		return scored[i].score > scored[j].score
+	})
+	if len(scored) > topK {
+		scored = scored[:topK]
+	}
+
+	entries := make([]LongTermEntry, len(scored))
+	for i, s := range scored {
+		entries[i] = s.entry
+	}
+	return entries, nil
+}
+
+// SemanticSearch returns the topK messages most similar to query by
+// cosine similarity of their embeddings, most similar first. Only
+// messages stored while an embedding provider was set are searched.
+func (m *Memory) SemanticSearch(query string, topK int, embeddingProvider EmbeddingProvider) ([]Message, error) {
+	if embeddingProvider == nil {
+		return nil, fmt.Errorf("embedding provider is required")
+	}
+	if topK <= 0 {
+		return nil, fmt.Errorf("topK must be positive")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), embeddingTimeout)
+	defer cancel()
+
+	queryVector, err := embeddingProvider.GetEmbedding(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to embed query: %w", err)
+	}
+
+	rows, err := m.conn.Query(`
+		SELECT m.id, m.session_id, m.role, m.content, m.metadata, m.timestamp, e.embedding
+		FROM messages_embeddings e
+		JOIN messages m ON m.id = e.message_id
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load embeddings: %w", err)
+	}
+	defer rows.Close()
+
+	type scoredMessage struct {
+		message Message
+		score   float64
+	}
+
+	var scored []scoredMessage
+	for rows.Next() {
+		var msg Message
+		var metadata sql.NullString
+		var blob []byte
+		if err := rows.Scan(&msg.ID, &msg.SessionID, &msg.Role, &msg.Content, &metadata, &msg.Timestamp, &blob); err != nil {
+			return nil, fmt.Errorf("failed to scan message: %w", err)
+		}
+		msg.Metadata = metadata.String
+
+		vector := decodeEmbedding(blob)
+		if len(vector) != len(queryVector) {
+			continue
+		}
+		scored = append(scored, scoredMessage{message: msg, score: cosineSimilarity(queryVector, vector)})
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to load embeddings: %w", err)
+	}
+
+	sort.SliceStable(scored, func(i, j int) bool {
+		return scored[i].score > scored[j].score
+	})
+	if len(scored) > topK {
+		scored = scored[:topK]
+	}
+
+	messages := make([]Message, len(scored))
+	for i, s := range scored {
+		messages[i] = s.message
+	}
+	return messages, nil
+}
+
+// encodeEmbedding serializes a vector as little-endian float32s
+func encodeEmbedding(vector []float64) []byte {
+	buf := make([]byte, 4*len(vector))
+	for i, v := range vector {
+		binary.LittleEndian.PutUint32(buf[4*i:], math.Float32bits(float32(v)))
+	}
+	return buf
+}
+
+// decodeEmbedding deserializes a vector stored by encodeEmbedding
+func decodeEmbedding(buf []byte) []float64 {
+	vector := make([]float64, len(buf)/4)
+	for i := range vector {
+		vector[i] = float64(math.Float32frombits(binary.LittleEndian.Uint32(buf[4*i:])))
+	}
+	return vector
+}
+
+// cosineSimilarity is the dot product of a and b divided by their norms.
+// OpenAI embeddings are unit length, so it equals their dot product.
+func cosineSimilarity(a, b []float64) float64 {
+	var dot, normA, normB float64
+	for i := range a {
+		dot += a[i] * b[i]
+		normA += a[i] * a[i]
+		normB += b[i] * b[i]
+	}
+	if normA == 0 || normB == 0 {
+		return 0
+	}
+	return dot / (math.Sqrt(normA) * math.Sqrt(normB))
+}
+
+// letterEmbedder embeds text as its letter counts, so that texts sharing
+// letters are similar
+type letterEmbedder struct{}
+
+func (letterEmbedder) GetEmbedding(ctx context.Context, text string) ([]float64, error) {
+	vector := make([]float64, 26)
+	for _, r := range strings.ToLower(text) {
+		if r >= 'a' && r <= 'z' {
+			vector[r-'a']++
+		}
+	}
+	return vector, nil
+}
+
+// TestSimilaritySearch embeds long-term memories and finds the closest
+// ones to a query
+func TestSimilaritySearch() error {
+	fmt.Println("Testing Similarity Search...")
+
+	dir, err := os.MkdirTemp("", "quickbot-similar")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+
+	memory, err := NewMemory(dir+"/memory.db", 100)
+	if err != nil {
+		return err
+	}
+	defer memory.Close()
+
+	if _, err := memory.SearchSimilar("anything", 1); err == nil {
+		return fmt.Errorf("expected an error without an embedding provider")
+	}
+
+	memory.SetEmbeddingProvider(letterEmbedder{})
+	if err := memory.SetLongTerm("pet", "cat", 1, 0); err != nil {
+		return err
+	}
+	if err := memory.SetLongTermBatch(map[string]string{"drink": "coffee", "city": "zurich"}, 1); err != nil {
+		return err
+	}
+
+	entries, err := memory.SearchSimilar("caat", 2)
+	if err != nil {
+		return err
+	}
+	if len(entries) != 2 || entries[0].Key != "pet" {
+		return fmt.Errorf("expected pet to be the closest of 2 results, got %+v", entries)
+	}
+	fmt.Println("✓ Closest long-term memories returned first")
+
+	if err := memory.SetLongTerm("pet", "zuricch", 1, 0); err != nil {
+		return err
+	}
+	entries, err = memory.SearchSimilar("zurich", 3)
+	if err != nil {
+		return err
+	}
+	if len(entries) != 3 || entries[0].Value == "coffee" || entries[2].Value != "coffee" {
+		return fmt.Errorf("expected the updated value to be re-embedded, got %+v", entries)
+	}
+	fmt.Println("✓ Updated values re-embedded")
+
+	return nil
+}
+
+// fixtureEmbedder returns precomputed embeddings of known texts
+type fixtureEmbedder map[string][]float64
+
+func (f fixtureEmbedder) GetEmbedding(ctx context.Context, text string) ([]float64, error) {
+	vector, ok := f[text]
+	if !ok {
+		return nil, fmt.Errorf("no fixture embedding for %q", text)
+	}
+	return vector, nil
+}
+
+// TestSemanticSearch stores messages with fixture embeddings and finds the
+// top-K closest ones to a query
+func TestSemanticSearch() error {
+	fmt.Println("Testing Semantic Search...")
+
+	dir, err := os.MkdirTemp("", "quickbot-semantic")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+
+	memory, err := NewMemory(dir+"/memory.db", 100)
+	if err != nil {
+		return err
+	}
+	defer memory.Close()
+
+	embeddings := fixtureEmbedder{
+		"I adopted a kitten":         {0.9, 0.1, 0},
+		"The weather is rainy":       {0, 0.2, 0.98},
+		"My dog loves the park":      {0.7, 0.7, 0.1},
+		"Stock prices fell today":    {0, 1, 0},
+		"Tell me about my pets":      {1, 0.2, 0},
+		"Will it rain this weekend?": {0, 0, 1},
+	}
+	if _, err := memory.SemanticSearch("Tell me about my pets", 2, nil); err == nil {
+		return fmt.Errorf("expected an error without an embedding provider")
+	}
+
+	memory.SetEmbeddingProvider(embeddings)
+	for _, content := range []string{"I adopted a kitten", "The weather is rainy", "My dog loves the park", "Stock prices fell today"} {
+		if _, _, err := memory.AddMessage("semantic", "user", content, nil); err != nil {
+			return err
+		}
+	}
+
+	results, err := memory.SemanticSearch("Tell me about my pets", 2, embeddings)
+	if err != nil {
+		return err
+	}
+	if len(results) != 2 || results[0].Content != "I adopted a kitten" || results[1].Content != "My dog loves the park" {
+		return fmt.Errorf("expected the two pet messages, closest first, got %+v", results)
+	}
+	fmt.Println("✓ Top-K closest messages returned first")
+
+	results, err = memory.SemanticSearch("Will it rain this weekend?", 1, embeddings)
+	if err != nil {
+		return err
+	}
+	if len(results) != 1 || results[0].Content != "The weather is rainy" || results[0].SessionID != "semantic" {
+		return fmt.Errorf("expected the weather message, got %+v", results)
+	}
+	fmt.Println("✓ Results limited to topK")
+
+	if _, err := memory.SemanticSearch("Tell me about my pets", 0, embeddings); err == nil {
+		return fmt.Errorf("expected an error for a topK of 0")
+	}
+
+	return nil
+}
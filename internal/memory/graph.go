@@ -0,0 +1,181 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// Graph node types
+const (
+	NodeMessage  = "message"
+	NodeLongTerm = "long_term_memory"
+)
+
+// Graph edge types
+const (
+	EdgeFollows    = "follows"
+	EdgeToolResult = "tool_result"
+	EdgeSummarizes = "summarizes"
+	EdgeCreated    = "created"
+)
+
+// graphPreviewLength is the number of characters of content in a node
+const graphPreviewLength = 100
+
+// GraphNode is a message or long-term memory in a session graph
+type GraphNode struct {
+	ID             string    `json:"id"`
+	Type           string    `json:"type"`
+	Role           string    `json:"role,omitempty"`
+	Key            string    `json:"key,omitempty"`
+	ContentPreview string    `json:"content_preview"`
+	Timestamp      time.Time `json:"timestamp"`
+}
+
+// GraphEdge connects two nodes of a session graph
+type GraphEdge struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+	Type string `json:"type"`
+}
+
+// SessionGraph is the memory of a session as a graph
+type SessionGraph struct {
+	Nodes []GraphNode `json:"nodes"`
+	Edges []GraphEdge `json:"edges"`
+}
+
+// messageGraphMetadata is the part of a message's metadata that links it
+// to other messages. Tool results carry the ID of their tool call message,
+// and summaries the IDs of the messages they summarize.
+type messageGraphMetadata struct {
+	ToolCallID int   `json:"tool_call_id"`
+	Summarizes []int `json:"summarizes"`
+}
+
+// GetSessionGraph returns the messages of a session as a graph. Each
+// message follows the one before it, tool calls point to their results,
+// summaries point to the messages they summarize, and messages point to
+// the long-term memories they created.
+func (m *Memory) GetSessionGraph(sessionID string) (*SessionGraph, error) {
+	messages, err := m.GetMessagesBetween(sessionID, 0, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	graph := &SessionGraph{Nodes: []GraphNode{}, Edges: []GraphEdge{}}
+	stored := make(map[int]bool, len(messages))
+	metadata := make([]messageGraphMetadata, len(messages))
+
+	for i, msg := range messages {
+		stored[msg.ID] = true
+		json.Unmarshal([]byte(msg.Metadata), &metadata[i])
+
+		graph.Nodes = append(graph.Nodes, GraphNode{
+			ID:             messageNodeID(msg.ID),
+			Type:           NodeMessage,
+			Role:           msg.Role,
+			ContentPreview: contentPreview(msg.Content),
+			Timestamp:      msg.Timestamp,
+		})
+		if i > 0 {
+			graph.Edges = append(graph.Edges, GraphEdge{
+				From: messageNodeID(messages[i-1].ID),
+				To:   messageNodeID(msg.ID),
+				Type: EdgeFollows,
+			})
+		}
+	}
+
+	for i, msg := range messages {
+		if call := metadata[i].ToolCallID; call != 0 && stored[call] {
+			graph.Edges = append(graph.Edges, GraphEdge{
+				From: messageNodeID(call),
+				To:   messageNodeID(msg.ID),
+				Type: EdgeToolResult,
+			})
+		}
+		for _, id := range metadata[i].Summarizes {
+			if stored[id] {
+				graph.Edges = append(graph.Edges, GraphEdge{
+					From: messageNodeID(msg.ID),
+					To:   messageNodeID(id),
+					Type: EdgeSummarizes,
+				})
+			}
+		}
+	}
+
+	rows, err := m.conn.Query(`
+		SELECT id, key, value, updated_at, source_message_id FROM long_term_memory
+		WHERE source_message_id IN (SELECT id FROM messages WHERE session_id = ?)
+		ORDER BY id
+	`, sessionID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query long-term memory: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var id, sourceID int
+		var key, value, updatedAt string
+		if err := rows.Scan(&id, &key, &value, &updatedAt, &sourceID); err != nil {
+			return nil, fmt.Errorf("failed to scan long-term memory: %w", err)
+		}
+
+		node := GraphNode{
+			ID:             "ltm_" + strconv.Itoa(id),
+			Type:           NodeLongTerm,
+			Key:            key,
+			ContentPreview: contentPreview(value),
+		}
+		node.Timestamp, _ = time.Parse(sqliteTimeFormat, updatedAt)
+
+		graph.Nodes = append(graph.Nodes, node)
+		graph.Edges = append(graph.Edges, GraphEdge{
+			From: messageNodeID(sourceID),
+			To:   node.ID,
+			Type: EdgeCreated,
+		})
+	}
+
+	return graph, rows.Err()
+}
+
+// LinkLongTermToMessage records the message that created long-term
+// memories, e.g. the tool call that stored them
+func (m *Memory) LinkLongTermToMessage(messageID int64, keys ...string) error {
+	if len(keys) == 0 {
+		return nil
+	}
+
+	args := []interface{}{messageID}
+	for _, key := range keys {
+		args = append(args, key)
+	}
+
+	_, err := m.conn.Exec(`
+		UPDATE long_term_memory SET source_message_id = ?
+		WHERE key IN (`+placeholders(len(keys))+`)
+	`, args...)
+	if err != nil {
+		return fmt.Errorf("failed to link long-term memory: %w", err)
+	}
+	return nil
+}
+
+// messageNodeID returns the graph node ID of a message
+func messageNodeID(id int) string {
+	return "msg_" + strconv.Itoa(id)
+}
+
+// contentPreview shortens content to graphPreviewLength characters
+func contentPreview(content string) string {
+	runes := []rune(content)
+	if len(runes) <= graphPreviewLength {
+		return content
+	}
+	return string(runes[:graphPreviewLength]) + "..."
+}
@@ -0,0 +1,365 @@
+package main
+
+import (
+	"bufio"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Chat history export formats accepted by the import endpoint
+const (
+	ImportFormatWhatsApp = "whatsapp"
+	ImportFormatTelegram = "telegram"
+)
+
+// importedMessage is a message parsed from a chat export
+type importedMessage struct {
+	Sender    string
+	Content   string
+	Timestamp time.Time
+}
+
+// whatsAppLine matches the first line of a message in a WhatsApp export:
+// [DD/MM/YY, HH:MM:SS] Name: message
+var whatsAppLine = regexp.MustCompile(`^\[(\d{1,2}/\d{1,2}/\d{2,4}), (\d{1,2}:\d{2}:\d{2})\] ([^:]+): (.*)$`)
+
+// whatsAppTimeLayouts are the date layouts of WhatsApp exports
+var whatsAppTimeLayouts = []string{"2/1/06 15:04:05", "2/1/2006 15:04:05"}
+
+// whatsAppMedia matches the placeholders WhatsApp exports in place of
+// attachments
+var whatsAppMedia = regexp.MustCompile(`^(<attached: .+>|<Media omitted>|(image|video|audio|sticker|GIF|document) omitted)$`)
+
+// SetBotName sets the sender name that chat imports store as assistant
+// messages. Every other sender is stored as the user.
+func (m *Memory) SetBotName(name string) {
+	m.botName = name
+}
+
+// ImportWhatsAppHistory imports the messages of a WhatsApp .txt chat
+// export into a session and returns the number of messages stored. Lines
+// that do not start a new message continue the previous one; attachments
+// and system notices are skipped.
+func (m *Memory) ImportWhatsAppHistory(path string, sessionID string) (int, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return 0, fmt.Errorf("failed to open WhatsApp export: %w", err)
+	}
+	defer file.Close()
+
+	var messages []importedMessage
+	var current *importedMessage
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		// iOS exports mark lines with left-to-right marks
+		line := strings.TrimRight(strings.ReplaceAll(scanner.Text(), "\u200e", ""), "\r")
+
+		match := whatsAppLine.FindStringSubmatch(line)
+		if match == nil {
+			if current != nil {
+				current.Content += "\n" + line
+			}
+			continue
+		}
+
+		timestamp, err := parseWhatsAppTime(match[1] + " " + match[2])
+		if err != nil {
+			return 0, err
+		}
+		messages = append(messages, importedMessage{
+			Sender:    strings.TrimSpace(match[3]),
+			Content:   match[4],
+			Timestamp: timestamp,
+		})
+		current = &messages[len(messages)-1]
+	}
+	if err := scanner.Err(); err != nil {
+		return 0, fmt.Errorf("failed to read WhatsApp export: %w", err)
+	}
+
+	kept := messages[:0]
+	for _, msg := range messages {
+		msg.Content = strings.TrimRight(msg.Content, "\n")
+		if !whatsAppMedia.MatchString(strings.TrimSpace(msg.Content)) {
+			kept = append(kept, msg)
+		}
+	}
+
+	return m.importMessages(sessionID, ImportFormatWhatsApp, kept)
+}
+
+// parseWhatsAppTime parses the date and time of a WhatsApp message, which
+// exports write in local time
+func parseWhatsAppTime(value string) (time.Time, error) {
+	for _, layout := range whatsAppTimeLayouts {
+		if t, err := time.ParseInLocation(layout, value, time.Local); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid WhatsApp timestamp: %s", value)
+}
+
+// telegramExport is the part of a Telegram Desktop JSON export that is
+// imported
+type telegramExport struct {
+	Messages []telegramMessage `json:"messages"`
+}
+
+// telegramMessage is a message of a Telegram export. Text is either a
+// string or a list of strings and formatted entities.
+type telegramMessage struct {
+	Type         string          `json:"type"`
+	Date         string          `json:"date"`
+	DateUnixtime string          `json:"date_unixtime"`
+	From         string          `json:"from"`
+	Text         json.RawMessage `json:"text"`
+	Photo        string          `json:"photo"`
+	File         string          `json:"file"`
+	MediaType    string          `json:"media_type"`
+}
+
+// ImportTelegramHistory imports the messages of a Telegram messages.json
+// chat export into a session and returns the number of messages stored.
+// Service messages, attachments and empty messages are skipped.
+func (m *Memory) ImportTelegramHistory(path string, sessionID string) (int, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read Telegram export: %w", err)
+	}
+
+	var export telegramExport
+	if err := json.Unmarshal(data, &export); err != nil {
+		return 0, fmt.Errorf("failed to parse Telegram export: %w", err)
+	}
+
+	var messages []importedMessage
+	for _, msg := range export.Messages {
+		if msg.Type != "message" || msg.Photo != "" || msg.File != "" || msg.MediaType != "" {
+			continue
+		}
+
+		content, err := telegramText(msg.Text)
+		if err != nil {
+			return 0, err
+		}
+		if strings.TrimSpace(content) == "" {
+			continue
+		}
+
+		timestamp, err := telegramTime(msg)
+		if err != nil {
+			return 0, err
+		}
+
+		messages = append(messages, importedMessage{
+			Sender:    msg.From,
+			Content:   content,
+			Timestamp: timestamp,
+		})
+	}
+
+	return m.importMessages(sessionID, ImportFormatTelegram, messages)
+}
+
+// telegramText joins the plain and formatted parts of a Telegram message
+func telegramText(raw json.RawMessage) (string, error) {
+	if len(raw) == 0 {
+		return "", nil
+	}
+
+	var text string
+	if err := json.Unmarshal(raw, &text); err == nil {
+		return text, nil
+	}
+
+	var parts []json.RawMessage
+	if err := json.Unmarshal(raw, &parts); err != nil {
+		return "", fmt.Errorf("invalid Telegram message text: %w", err)
+	}
+
+	var builder strings.Builder
+	for _, part := range parts {
+		var plain string
+		if err := json.Unmarshal(part, &plain); err == nil {
+			builder.WriteString(plain)
+			continue
+		}
+
+		var entity struct {
+			Text string `json:"text"`
+		}
+		if err := json.Unmarshal(part, &entity); err != nil {
+			return "", fmt.Errorf("invalid Telegram message text: %w", err)
+		}
+		builder.WriteString(entity.Text)
+	}
+	return builder.String(), nil
+}
+
+// telegramTime returns the time of a Telegram message, preferring the
+// Unix time over the local date
+func telegramTime(msg telegramMessage) (time.Time, error) {
+	if msg.DateUnixtime != "" {
+		seconds, err := strconv.ParseInt(msg.DateUnixtime, 10, 64)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("invalid Telegram timestamp: %s", msg.DateUnixtime)
+		}
+		return time.Unix(seconds, 0), nil
+	}
+
+	t, err := time.ParseInLocation("2006-01-02T15:04:05", msg.Date, time.Local)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid Telegram timestamp: %s", msg.Date)
+	}
+	return t, nil
+}
+
+// importMessages stores imported messages in a session, skipping those
+// already stored with the same timestamp and content. Messages from the
+// bot name are stored as the assistant, all others as the user.
+func (m *Memory) importMessages(sessionID, source string, messages []importedMessage) (int, error) {
+	var ids []int64
+	var contents []string
+
+	err := m.withTx(func(tx *sql.Tx) error {
+		for _, msg := range messages {
+			role := "user"
+			if m.botName != "" && msg.Sender == m.botName {
+				role = "assistant"
+			}
+			metadata, _ := json.Marshal(map[string]interface{}{
+				"source": source,
+				"sender": msg.Sender,
+			})
+			timestamp := msg.Timestamp.UTC().Format(sqliteTimeFormat)
+
+			result, err := tx.Exec(`
+				INSERT INTO messages (session_id, role, content, metadata, timestamp)
+				SELECT ?, ?, ?, ?, ?
+				WHERE NOT EXISTS (
+					SELECT 1 FROM messages WHERE session_id = ? AND timestamp = ? AND content = ?
+				)
+			`, sessionID, role, msg.Content, string(metadata), timestamp, sessionID, timestamp, msg.Content)
+			if err != nil {
+				return fmt.Errorf("failed to import message: %w", err)
+			}
+
+			if inserted, _ := result.RowsAffected(); inserted > 0 {
+				id, _ := result.LastInsertId()
+				ids = append(ids, id)
+				contents = append(contents, msg.Content)
+			}
+		}
+
+		_, err := tx.Exec(`
+			UPDATE sessions SET updated_at = CURRENT_TIMESTAMP WHERE id = ?
+		`, sessionID)
+		if err != nil {
+			return fmt.Errorf("failed to update session timestamp: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	if m.embeddings != nil {
+		for i, id := range ids {
+			m.storeEmbedding(id, contents[i])
+		}
+	}
+
+	return len(ids), nil
+}
+
+// TestChatImport imports the WhatsApp and Telegram fixture exports and
+// checks roles, multi-line messages, skipped attachments and
+// deduplication
+func TestChatImport() error {
+	fmt.Println("Testing Chat Import...")
+
+	dir, err := os.MkdirTemp("", "quickbot-import")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+
+	memory, err := NewMemory(dir+"/memory.db", 100)
+	if err != nil {
+		return err
+	}
+	defer memory.Close()
+	memory.SetBotName("QuickBot")
+
+	cases := []struct {
+		format    string
+		path      string
+		importer  func(path, sessionID string) (int, error)
+		roles     []string
+		multiLine string
+	}{
+		{
+			format:    ImportFormatWhatsApp,
+			path:      "internal/memory/testdata/whatsapp_export.txt",
+			importer:  memory.ImportWhatsAppHistory,
+			roles:     []string{"user", "assistant", "user", "assistant"},
+			multiLine: "Here is the list:\n1. milk\n2. eggs",
+		},
+		{
+			format:    ImportFormatTelegram,
+			path:      "internal/memory/testdata/telegram_export.json",
+			importer:  memory.ImportTelegramHistory,
+			roles:     []string{"user", "assistant", "user"},
+			multiLine: "Remind me to call Bob\nat 5pm",
+		},
+	}
+
+	for _, c := range cases {
+		sessionID := "import-" + c.format
+
+		count, err := c.importer(c.path, sessionID)
+		if err != nil {
+			return fmt.Errorf("%s import failed: %w", c.format, err)
+		}
+		if count != len(c.roles) {
+			return fmt.Errorf("%s import stored %d messages, expected %d", c.format, count, len(c.roles))
+		}
+
+		messages, err := memory.GetMessagesBetween(sessionID, 0, 0)
+		if err != nil {
+			return err
+		}
+		foundMultiLine := false
+		for i, msg := range messages {
+			if msg.Role != c.roles[i] {
+				return fmt.Errorf("%s message %d has role %s, expected %s", c.format, i, msg.Role, c.roles[i])
+			}
+			if strings.Contains(msg.Content, "omitted") || strings.Contains(msg.Content, "attached") {
+				return fmt.Errorf("%s attachment was imported: %q", c.format, msg.Content)
+			}
+			if msg.Content == c.multiLine {
+				foundMultiLine = true
+			}
+		}
+		if !foundMultiLine {
+			return fmt.Errorf("%s multi-line message not imported intact", c.format)
+		}
+		fmt.Printf("✓ %s import: %d messages, attachments skipped\n", c.format, count)
+
+		count, err = c.importer(c.path, sessionID)
+		if err != nil || count != 0 {
+			return fmt.Errorf("%s reimport stored %d messages: %v", c.format, count, err)
+		}
+		fmt.Printf("✓ %s reimport deduplicated\n", c.format)
+	}
+
+	return nil
+}
@@ -0,0 +1,311 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+)
+
+// expiryTimeFormat is the format of expires_at. Its fixed width keeps
+// string comparisons in time order.
+const expiryTimeFormat = "2006-01-02 15:04:05.000"
+
+// expiryTime formats t as an expires_at value
+func expiryTime(t time.Time) string {
+	return t.UTC().Format(expiryTimeFormat)
+}
+
+// LongTermEntry is a long-term memory entry
+type LongTermEntry struct {
+	Key        string    `json:"key"`
+	Value      string    `json:"value"`
+	Importance int       `json:"importance"`
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+// initLongTermSearch creates the FTS5 index on long-term memory values.
+// go-sqlite3 only includes FTS5 when built with the sqlite_fts5 tag;
+// without it SearchLongTerm falls back to a LIKE query.
+func (m *Memory) initLongTermSearch() error {
+	var exists int
+	err := m.conn.QueryRow(`
+		SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = 'long_term_memory_fts'
+	`).Scan(&exists)
+	if err != nil {
+		return fmt.Errorf("failed to check long-term memory index: %w", err)
+	}
+
+	if exists == 0 {
+		_, err = m.conn.Exec(`
+			CREATE VIRTUAL TABLE long_term_memory_fts USING fts5(
+				value, content='long_term_memory', content_rowid='id'
+			)
+		`)
+		if err != nil {
+			log.Printf("Warning: FTS5 is not available, long-term memory search uses LIKE: %v", err)
+			return nil
+		}
+
+		// Index entries stored before the index existed
+		_, err = m.conn.Exec(`INSERT INTO long_term_memory_fts(long_term_memory_fts) VALUES ('rebuild')`)
+		if err != nil {
+			return fmt.Errorf("failed to build long-term memory index: %w", err)
+		}
+	}
+
+	_, err = m.conn.Exec(`
+		CREATE TRIGGER IF NOT EXISTS long_term_memory_ai AFTER INSERT ON long_term_memory BEGIN
+			INSERT INTO long_term_memory_fts(rowid, value) VALUES (new.id, new.value);
+		END;
+		CREATE TRIGGER IF NOT EXISTS long_term_memory_ad AFTER DELETE ON long_term_memory BEGIN
+			INSERT INTO long_term_memory_fts(long_term_memory_fts, rowid, value) VALUES ('delete', old.id, old.value);
+		END;
+		CREATE TRIGGER IF NOT EXISTS long_term_memory_au AFTER UPDATE ON long_term_memory BEGIN
+			INSERT INTO long_term_memory_fts(long_term_memory_fts, rowid, value) VALUES ('delete', old.id, old.value);
+			INSERT INTO long_term_memory_fts(rowid, value) VALUES (new.id, new.value);
+		END;
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to create long-term memory index triggers: %w", err)
+	}
+
+	m.fts = true
+	return nil
+}
+
+// SetLongTermBatch stores several long-term memories in one transaction
+func (m *Memory) SetLongTermBatch(entries map[string]string, importance int) error {
+	err := m.withTx(func(tx *sql.Tx) error {
+		for key, value := range entries {
+			if _, err := tx.Exec(upsertLongTermSQL, key, value, importance, nil); err != nil {
+				return fmt.Errorf("failed to set long-term memory %s: %w", key, err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	// Embed the values once they are committed
+	if m.embeddings != nil {
+		for key, value := range entries {
+			m.storeLongTermEmbedding(key, value)
+		}
+	}
+	return nil
+}
+
+// PurgeExpired deletes the long-term memories past their expiry and
+// returns how many were deleted
+func (m *Memory) PurgeExpired() (int, error) {
+	result, err := m.conn.Exec(`
+		DELETE FROM long_term_memory WHERE expires_at IS NOT NULL AND expires_at < ?
+	`, expiryTime(time.Now()))
+	if err != nil {
+		return 0, fmt.Errorf("failed to purge expired long-term memory: %w", err)
+	}
+	deleted, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to purge expired long-term memory: %w", err)
+	}
+	return int(deleted), nil
+}
+
+// purgeExpiredLoop runs PurgeExpired every purgeInterval until the memory
+// is closed
+func (m *Memory) purgeExpiredLoop() {
+	ticker := time.NewTicker(purgeInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-m.stopPurge:
+			return
+		case <-ticker.C:
+			purged, err := m.PurgeExpired()
+			if err != nil {
+				log.Printf("Warning: %v", err)
+				continue
+			}
+			if purged > 0 {
+				log.Printf("Purged %d expired long-term memories", purged)
+			}
+		}
+	}
+}
+
+// DeleteLongTerm deletes a long-term memory
+func (m *Memory) DeleteLongTerm(key string) error {
+	result, err := m.conn.Exec(`DELETE FROM long_term_memory WHERE key = ?`, key)
+	if err != nil {
+		return fmt.Errorf("failed to delete long-term memory: %w", err)
+	}
+	if deleted, _ := result.RowsAffected(); deleted == 0 {
+		return fmt.Errorf("long-term memory not found: %s", key)
+	}
+	return nil
+}
+
+// ListLongTerm returns the long-term memories whose key starts with
+// filter, ordered by key, leaving out expired ones. A limit of 0 means
+// unlimited.
+func (m *Memory) ListLongTerm(filter string, limit int) ([]LongTermEntry, error) {
+	if limit <= 0 {
+		limit = -1
+	}
+
+	rows, err := m.conn.Query(`
+		SELECT key, value, importance, created_at, updated_at
+		FROM long_term_memory
+		WHERE substr(key, 1, length(?)) = ? AND (expires_at IS NULL OR expires_at >= ?)
+		ORDER BY key LIMIT ?
+	`, filter, filter, expiryTime(time.Now()), limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list long-term memory: %w", err)
+	}
+	defer rows.Close()
+
+	return scanLongTermEntries(rows)
+}
+
+// SearchLongTerm returns the long-term memories whose value matches all
+// words of query, best matches first, leaving out expired ones
+func (m *Memory) SearchLongTerm(query string, limit int) ([]LongTermEntry, error) {
+	words := strings.Fields(query)
+	if len(words) == 0 {
+		return nil, fmt.Errorf("search query is required")
+	}
+	if limit <= 0 {
+		limit = -1
+	}
+
+	now := expiryTime(time.Now())
+	var rows *sql.Rows
+	var err error
+	if m.fts {
+		// Quote each word so punctuation is not parsed as FTS5 syntax
+		terms := make([]string, len(words))
+		for i, word := range words {
+			terms[i] = `"` + strings.ReplaceAll(word, `"`, `""`) + `"`
+		}
+
+		rows, err = m.conn.Query(`
+			SELECT l.key, l.value, l.importance, l.created_at, l.updated_at
+			FROM long_term_memory_fts f
+			JOIN long_term_memory l ON l.id = f.rowid
+			WHERE long_term_memory_fts MATCH ? AND (l.expires_at IS NULL OR l.expires_at >= ?)
+			ORDER BY f.rank LIMIT ?
+		`, strings.Join(terms, " "), now, limit)
+	} else {
+		conditions := make([]string, len(words), len(words)+1)
+		args := make([]interface{}, 0, len(words)+2)
+		for i, word := range words {
+			conditions[i] = "instr(lower(value), lower(?)) > 0"
+			args = append(args, word)
+		}
+		conditions = append(conditions, "(expires_at IS NULL OR expires_at >= ?)")
+		args = append(args, now, limit)
+
+		rows, err = m.conn.Query(`
+			SELECT key, value, importance, created_at, updated_at
+			FROM long_term_memory
+			WHERE `+strings.Join(conditions, " AND ")+`
+			ORDER BY importance DESC, updated_at DESC LIMIT ?
+		`, args...)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to search long-term memory: %w", err)
+	}
+	defer rows.Close()
+
+	return scanLongTermEntries(rows)
+}
+
+func scanLongTermEntries(rows *sql.Rows) ([]LongTermEntry, error) {
+	entries := []LongTermEntry{}
+	for rows.Next() {
+		var entry LongTermEntry
+		var createdAt, updatedAt string
+		if err := rows.Scan(&entry.Key, &entry.Value, &entry.Importance, &createdAt, &updatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan long-term memory: %w", err)
+		}
+		entry.CreatedAt, _ = time.Parse(sqliteTimeFormat, createdAt)
+		entry.UpdatedAt, _ = time.Parse(sqliteTimeFormat, updatedAt)
+		entries = append(entries, entry)
+	}
+	return entries, rows.Err()
+}
+
+// TestLongTermExpiry checks that long-term memories expire after their TTL
+// and are purged
+func TestLongTermExpiry() error {
+	log.Println("Testing long-term memory expiry...")
+
+	dbPath := "test_long_term_expiry.db"
+	defer os.Remove(dbPath)
+
+	mem, err := NewMemory(dbPath, 100)
+	if err != nil {
+		return err
+	}
+	defer mem.Close()
+	mem.SetEmbeddingProvider(letterEmbedder{})
+
+	if err := mem.SetLongTerm("otp", "123456", 1, 50*time.Millisecond); err != nil {
+		return err
+	}
+	if err := mem.SetLongTerm("name", "Alice", 1, 0); err != nil {
+		return err
+	}
+	if err := mem.SetLongTerm("mood", "happy", 1, time.Hour); err != nil {
+		return err
+	}
+	if value, err := mem.GetLongTerm("otp"); err != nil || value != "123456" {
+		return fmt.Errorf("expected memory before its expiry, got %q (%v)", value, err)
+	}
+
+	time.Sleep(100 * time.Millisecond)
+	if _, err := mem.GetLongTerm("otp"); !errors.Is(err, ErrExpired) {
+		return fmt.Errorf("expected ErrExpired, got %v", err)
+	}
+	for _, key := range []string{"name", "mood"} {
+		if value, err := mem.GetLongTerm(key); err != nil || value == "" {
+			return fmt.Errorf("expected %s not to expire, got %q (%v)", key, value, err)
+		}
+	}
+	if entries, err := mem.ListLongTerm("", 0); err != nil || len(entries) != 2 {
+		return fmt.Errorf("expected expired memory to be left out of the list, got %v (%v)", entries, err)
+	}
+	if entries, err := mem.SearchSimilar("happy", 3); err != nil || len(entries) != 2 {
+		return fmt.Errorf("expected expired memory to be left out of similarity search, got %v (%v)", entries, err)
+	}
+	log.Println("✓ Memory expired after its TTL")
+
+	purged, err := mem.PurgeExpired()
+	if err != nil {
+		return err
+	}
+	if purged != 1 {
+		return fmt.Errorf("expected 1 purged memory, got %d", purged)
+	}
+	if value, err := mem.GetLongTerm("otp"); err != nil || value != "" {
+		return fmt.Errorf("expected purged memory to be gone, got %q (%v)", value, err)
+	}
+	log.Println("✓ Expired memory purged")
+
+	// Setting a memory again replaces its expiry
+	if err := mem.SetLongTerm("mood", "calm", 1, 0); err != nil {
+		return err
+	}
+	if purged, err := mem.PurgeExpired(); err != nil || purged != 0 {
+		return fmt.Errorf("expected nothing to purge, got %d (%v)", purged, err)
+	}
+
+	return nil
+}
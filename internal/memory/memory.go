@@ -3,20 +3,53 @@ package main
 import (
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"os"
+	"strings"
+	"sync"
 	"time"
 
+	"github.com/Chang-Augenweide/QuickBot-Go/internal/events"
+	"github.com/Chang-Augenweide/QuickBot-Go/pkg/types"
 	_ "github.com/mattn/go-sqlite3"
 )
 
 // Memory represents conversation memory manager
 type Memory struct {
 	conn        *sql.DB
+	path        string
 	maxMessages int
+	fts         bool
+	embeddings  EmbeddingProvider
+	events      *events.EventBus
+	botName     string
+
+	// stopPurge stops the goroutine purging expired long-term memories
+	stopPurge chan struct{}
+	closeOnce sync.Once
 }
 
+// ErrExpired is returned by GetLongTerm for a long-term memory whose TTL
+// has passed but that has not been purged yet
+var ErrExpired = errors.New("long-term memory expired")
+
+// ErrSessionNotFound is returned by TransferSession for an unknown source
+// session
+var ErrSessionNotFound = errors.New("session not found")
+
+// ErrSessionExists is returned by TransferSession when the target session
+// already exists
+var ErrSessionExists = errors.New("session already exists")
+
+// ErrRedelivered is returned by the agent for a message its platform
+// delivered again, which was already answered
+var ErrRedelivered = errors.New("message redelivered")
+
+// purgeInterval is how often expired long-term memories are purged
+const purgeInterval = time.Hour
+
 // Message represents a chat message
 type Message struct {
 	ID        int       `json:"id"`
@@ -29,12 +62,18 @@ type Message struct {
 
 // Session represents a conversation session
 type Session struct {
-	ID        string    `json:"id"`
-	Name      string    `json:"name"`
-	Platform  string    `json:"platform"`
-	UserID    string    `json:"user_id"`
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
+	ID          string                 `json:"id"`
+	Name        string                 `json:"name"`
+	Description string                 `json:"description,omitempty"`
+	Platform    string                 `json:"platform"`
+	UserID      string                 `json:"user_id"`
+	Metadata    map[string]interface{} `json:"metadata,omitempty"`
+	Tags        []string               `json:"tags"`
+	CreatedAt   time.Time              `json:"created_at"`
+	UpdatedAt   time.Time              `json:"updated_at"`
+
+	AIOverrides      *types.AIOverrides `json:"ai_overrides,omitempty"`
+	CondensedSummary string             `json:"condensed_summary,omitempty"`
 }
 
 // NewMemory creates a new Memory instance
@@ -52,7 +91,9 @@ func NewMemory(dbPath string, maxMessages int) (*Memory, error) {
 
 	mem := &Memory{
 		conn:        conn,
+		path:        dbPath,
 		maxMessages: maxMessages,
+		stopPurge:   make(chan struct{}),
 	}
 
 	err = mem.initDB()
@@ -60,6 +101,8 @@ func NewMemory(dbPath string, maxMessages int) (*Memory, error) {
 		return nil, err
 	}
 
+	go mem.purgeExpiredLoop()
+
 	return mem, nil
 }
 
@@ -105,6 +148,29 @@ func (m *Memory) initDB() error {
 		return fmt.Errorf("failed to create sessions table: %w", err)
 	}
 
+	if err := m.migrateSessionDescription(); err != nil {
+		return err
+	}
+
+	// Create messages_embeddings table
+	_, err = m.conn.Exec(`
+		CREATE TABLE IF NOT EXISTS messages_embeddings (
+			message_id INTEGER PRIMARY KEY,
+			embedding BLOB NOT NULL
+		)
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to create messages_embeddings table: %w", err)
+	}
+
+	if err := m.dropMessageUniqueness(); err != nil {
+		return err
+	}
+
+	if err := m.initMessageMetadata(); err != nil {
+		return err
+	}
+
 	// Create long_term_memory table
 	_, err = m.conn.Exec(`
 		CREATE TABLE IF NOT EXISTS long_term_memory (
@@ -120,32 +186,122 @@ func (m *Memory) initDB() error {
 		return fmt.Errorf("failed to create long_term_memory table: %w", err)
 	}
 
+	if err := m.addColumn("long_term_memory", "source_message_id", "INTEGER"); err != nil {
+		return err
+	}
+	if err := m.addColumn("long_term_memory", "embedding", "BLOB"); err != nil {
+		return err
+	}
+	if err := m.addColumn("long_term_memory", "expires_at", "DATETIME"); err != nil {
+		return err
+	}
+
+	// Index for snapshot queries
+	_, err = m.conn.Exec(`
+		CREATE INDEX IF NOT EXISTS idx_long_term_memory_updated_at
+		ON long_term_memory(updated_at)
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to create long_term_memory index: %w", err)
+	}
+
+	if err := m.initLongTermSearch(); err != nil {
+		return err
+	}
+
+	if err := m.initMessageSearch(); err != nil {
+		return err
+	}
+
+	// Create session_config table
+	_, err = m.conn.Exec(`
+		CREATE TABLE IF NOT EXISTS session_config (
+			session_id TEXT NOT NULL,
+			key TEXT NOT NULL,
+			value TEXT NOT NULL,
+			updated_at TEXT DEFAULT CURRENT_TIMESTAMP,
+			PRIMARY KEY (session_id, key)
+		)
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to create session_config table: %w", err)
+	}
+
+	// Create tags table
+	_, err = m.conn.Exec(`
+		CREATE TABLE IF NOT EXISTS tags (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			session_id TEXT NOT NULL,
+			tag TEXT NOT NULL,
+			UNIQUE (session_id, tag)
+		)
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to create tags table: %w", err)
+	}
+
+	_, err = m.conn.Exec(`
+		CREATE INDEX IF NOT EXISTS idx_tags_tag ON tags(tag)
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to create tags index: %w", err)
+	}
+
 	return nil
 }
 
-// AddMessage adds a message to memory
-func (m *Memory) AddMessage(sessionID, role, content string, metadata map[string]interface{}) (int64, error) {
+// AddMessage adds a message to memory and returns its ID. A message
+// redelivered by its platform is dropped: the ID of the stored message is
+// returned and redelivered is true.
+func (m *Memory) AddMessage(sessionID, role, content string, metadata map[string]interface{}) (int64, bool, error) {
 	metadataJSON, _ := json.Marshal(metadata)
 
-	result, err := m.conn.Exec(`
+	query := `
 		INSERT INTO messages (session_id, role, content, metadata)
 		VALUES (?, ?, ?, ?)
-	`, sessionID, role, content, string(metadataJSON))
+	`
+	args := []interface{}{sessionID, role, content, string(metadataJSON)}
+
+	condition, conditionArgs, dedup := redeliveryCondition(sessionID, role, content, metadata)
+	if dedup {
+		query = `
+			INSERT INTO messages (session_id, role, content, metadata)
+			SELECT ?, ?, ?, ?
+			WHERE NOT EXISTS (SELECT 1 FROM messages WHERE ` + condition + `)
+		`
+		args = append(args, conditionArgs...)
+	}
+
+	result, err := m.conn.Exec(query, args...)
 	if err != nil {
-		return 0, fmt.Errorf("failed to insert message: %w", err)
+		return 0, false, fmt.Errorf("failed to insert message: %w", err)
 	}
 
-	id, _ := result.LastInsertId()
+	var id int64
+	if inserted, _ := result.RowsAffected(); inserted == 0 {
+		err = m.conn.QueryRow(`
+			SELECT id FROM messages WHERE `+condition+` ORDER BY id LIMIT 1
+		`, conditionArgs...).Scan(&id)
+		if err != nil {
+			return 0, false, fmt.Errorf("failed to find redelivered message: %w", err)
+		}
+		return id, true, nil
+	}
+
+	id, _ = result.LastInsertId()
+	if m.embeddings != nil && content != "" {
+		m.storeEmbedding(id, content)
+	}
 
 	// Update session timestamp
 	_, err = m.conn.Exec(`
 		UPDATE sessions SET updated_at = CURRENT_TIMESTAMP WHERE id = ?
 	`, sessionID)
 	if err != nil {
-		return 0, fmt.Errorf("failed to update session timestamp: %w", err)
+		return 0, false, fmt.Errorf("failed to update session timestamp: %w", err)
 	}
 
-	return id, nil
+	return id, false, nil
 }
 
 // GetMessages retrieves messages for a session
@@ -186,65 +342,369 @@ func (m *Memory) GetMessages(sessionID string, limit int) ([]Message, error) {
 	return messages, nil
 }
 
-// SetLongTerm stores information in long-term memory
-func (m *Memory) SetLongTerm(key, value string, importance int) error {
-	_, err := m.conn.Exec(`
-		INSERT OR REPLACE INTO long_term_memory (key, value, importance, updated_at)
-		VALUES (?, ?, ?, CURRENT_TIMESTAMP)
-	`, key, value, importance)
+// GetMessageByID retrieves a single message by ID
+func (m *Memory) GetMessageByID(id int) (Message, error) {
+	var msg Message
+	err := m.conn.QueryRow(`
+		SELECT id, session_id, role, content, metadata, timestamp
+		FROM messages WHERE id = ?
+	`, id).Scan(&msg.ID, &msg.SessionID, &msg.Role, &msg.Content, &msg.Metadata, &msg.Timestamp)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return msg, fmt.Errorf("message %d not found", id)
+		}
+		return msg, fmt.Errorf("failed to get message: %w", err)
+	}
+	return msg, nil
+}
+
+// GetMessagesBetween retrieves the messages of a session with IDs in
+// [fromID, toID], oldest first. A toID of 0 means no upper bound.
+func (m *Memory) GetMessagesBetween(sessionID string, fromID, toID int) ([]Message, error) {
+	query := `SELECT id, session_id, role, content, metadata, timestamp
+	          FROM messages WHERE session_id = ? AND id >= ?`
+	args := []interface{}{sessionID, fromID}
+	if toID > 0 {
+		query += ` AND id <= ?`
+		args = append(args, toID)
+	}
+	query += ` ORDER BY id ASC`
+
+	rows, err := m.conn.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query messages: %w", err)
+	}
+	defer rows.Close()
+
+	var messages []Message
+	for rows.Next() {
+		var msg Message
+		err := rows.Scan(&msg.ID, &msg.SessionID, &msg.Role, &msg.Content, &msg.Metadata, &msg.Timestamp)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan message: %w", err)
+		}
+		messages = append(messages, msg)
+	}
+
+	return messages, rows.Err()
+}
+
+// upsertLongTermSQL inserts or updates a long-term memory. An upsert,
+// unlike INSERT OR REPLACE, fires the update trigger of the search index.
+// The embedding of a changed value is cleared until it is embedded again.
+// Setting a memory again replaces its expiry.
+const upsertLongTermSQL = `
+	INSERT INTO long_term_memory (key, value, importance, updated_at, expires_at)
+	VALUES (?, ?, ?, CURRENT_TIMESTAMP, ?)
+	ON CONFLICT(key) DO UPDATE SET
+		value = excluded.value,
+		importance = excluded.importance,
+		updated_at = excluded.updated_at,
+		expires_at = excluded.expires_at,
+		embedding = CASE WHEN value = excluded.value THEN embedding END
+`
+
+// SetLongTerm stores information in long-term memory. It expires after
+// ttl, or never if ttl is zero.
+func (m *Memory) SetLongTerm(key, value string, importance int, ttl time.Duration) error {
+	var expiresAt interface{}
+	if ttl > 0 {
+		expiresAt = expiryTime(time.Now().Add(ttl))
+	}
+
+	_, err := m.conn.Exec(upsertLongTermSQL, key, value, importance, expiresAt)
 	if err != nil {
 		return fmt.Errorf("failed to set long-term memory: %w", err)
 	}
+	if m.embeddings != nil {
+		m.storeLongTermEmbedding(key, value)
+	}
 	return nil
 }
 
-// GetLongTerm retrieves information from long-term memory
+// GetLongTerm retrieves information from long-term memory. It returns
+// ErrExpired for a memory past its expiry.
 func (m *Memory) GetLongTerm(key string) (string, error) {
 	var value string
+	var expiresAt sql.NullTime
 	err := m.conn.QueryRow(`
-		SELECT value FROM long_term_memory WHERE key = ?
-	`, key).Scan(&value)
+		SELECT value, expires_at FROM long_term_memory WHERE key = ?
+	`, key).Scan(&value, &expiresAt)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return "", nil
 		}
 		return "", fmt.Errorf("failed to get long-term memory: %w", err)
 	}
+	if expiresAt.Valid && expiresAt.Time.Before(time.Now()) {
+		return "", ErrExpired
+	}
 	return value, nil
 }
 
+// SetEventBus sets the bus that session events are published on
+func (m *Memory) SetEventBus(bus *events.EventBus) {
+	m.events = bus
+}
+
 // CreateSession creates or updates a session
 func (m *Memory) CreateSession(id, name, platform, userID string) error {
 	metadataJSON, _ := json.Marshal(map[string]interface{}{})
 
+	var exists bool
+	if err := m.conn.QueryRow("SELECT EXISTS(SELECT 1 FROM sessions WHERE id = ?)", id).Scan(&exists); err != nil {
+		return fmt.Errorf("failed to create session: %w", err)
+	}
+
 	_, err := m.conn.Exec(`
-		INSERT OR REPLACE INTO sessions (id, name, platform, user_id, metadata)
-		VALUES (?, ?, ?, ?, ?)
-	`, id, name, platform, userID, string(metadataJSON))
+		INSERT OR REPLACE INTO sessions (id, name, platform, user_id, metadata, description)
+		VALUES (?, ?, ?, ?, ?, (SELECT description FROM sessions WHERE id = ?))
+	`, id, name, platform, userID, string(metadataJSON), id)
 	if err != nil {
 		return fmt.Errorf("failed to create session: %w", err)
 	}
+
+	if !exists && m.events != nil {
+		m.events.Publish(events.Event{
+			Topic:   events.TopicSessionCreated,
+			Payload: events.SessionPayload{SessionID: id, Platform: platform, UserID: userID},
+		})
+	}
 	return nil
 }
 
 // GetSession retrieves session information
 func (m *Memory) GetSession(id string) (*Session, error) {
 	var session Session
+	var metadata, description sql.NullString
+	var createdAt, updatedAt string
 	err := m.conn.QueryRow(`
-		SELECT id, name, platform, user_id, created_at, updated_at
+		SELECT id, name, description, platform, user_id, metadata, created_at, updated_at
 		FROM sessions WHERE id = ?
-	`, id).Scan(&session.ID, &session.Name, &session.Platform, &session.UserID, &session.CreatedAt, &session.UpdatedAt)
+	`, id).Scan(&session.ID, &session.Name, &description, &session.Platform, &session.UserID, &metadata, &createdAt, &updatedAt)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, nil
 		}
 		return nil, fmt.Errorf("failed to get session: %w", err)
 	}
+	if metadata.Valid && metadata.String != "" {
+		json.Unmarshal([]byte(metadata.String), &session.Metadata)
+	}
+	session.Description = description.String
+	session.CreatedAt, _ = time.Parse(sqliteTimeFormat, createdAt)
+	session.UpdatedAt, _ = time.Parse(sqliteTimeFormat, updatedAt)
+	session.Tags, err = m.GetTags(id)
+	if err != nil {
+		return nil, err
+	}
+	session.AIOverrides, err = m.GetAIOverrides(id)
+	if err != nil {
+		return nil, err
+	}
+	session.CondensedSummary, err = m.GetSessionSummary(id)
+	if err != nil {
+		return nil, err
+	}
 	return &session, nil
 }
 
-// Close closes the database connection
+// GetSessionByUserID retrieves the most recently updated session of a user on a platform
+func (m *Memory) GetSessionByUserID(platform, userID string) (*Session, error) {
+	var id string
+	err := m.conn.QueryRow(`
+		SELECT id FROM sessions
+		WHERE platform = ? AND user_id = ?
+		ORDER BY updated_at DESC LIMIT 1
+	`, platform, userID).Scan(&id)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get session by user: %w", err)
+	}
+	return m.GetSession(id)
+}
+
+// SetSessionConfig sets a per-session configuration value
+func (m *Memory) SetSessionConfig(sessionID, key, value string) error {
+	_, err := m.conn.Exec(`
+		INSERT OR REPLACE INTO session_config (session_id, key, value, updated_at)
+		VALUES (?, ?, ?, CURRENT_TIMESTAMP)
+	`, sessionID, key, value)
+	if err != nil {
+		return fmt.Errorf("failed to set session config: %w", err)
+	}
+	return nil
+}
+
+// GetSessionConfig retrieves a per-session configuration value, or "" if unset
+func (m *Memory) GetSessionConfig(sessionID, key string) (string, error) {
+	var value string
+	err := m.conn.QueryRow(`
+		SELECT value FROM session_config WHERE session_id = ? AND key = ?
+	`, sessionID, key).Scan(&value)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return "", nil
+		}
+		return "", fmt.Errorf("failed to get session config: %w", err)
+	}
+	return value, nil
+}
+
+// TransferSession copies the conversation of one session to another session ID,
+// e.g. when a user moves from Telegram to Discord. Messages with their
+// metadata and embeddings, session config and tags are copied. Long-term
+// memories are shared by all sessions, so the transfer moves their
+// ownership: those created in the source session are linked to the copies
+// of their messages, and leave the source session's graph. The source
+// session is kept and its ID is recorded in the metadata of the new
+// session. It returns
+// ErrSessionNotFound for an unknown source and ErrSessionExists if the target
+// session already exists, so a transfer never writes into a conversation
+// that is already there.
+func (m *Memory) TransferSession(fromSessionID, toSessionID string) error {
+	if fromSessionID == toSessionID {
+		return fmt.Errorf("source and target session are the same")
+	}
+
+	tx, err := m.conn.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	exists, err := sessionExists(tx, fromSessionID)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return fmt.Errorf("%w: %s", ErrSessionNotFound, fromSessionID)
+	}
+	exists, err = sessionExists(tx, toSessionID)
+	if err != nil {
+		return err
+	}
+	if exists {
+		return fmt.Errorf("%w: %s", ErrSessionExists, toSessionID)
+	}
+
+	// Session IDs have the form "<platform>:<user_id>"
+	platform, userID := "", toSessionID
+	if parts := strings.SplitN(toSessionID, ":", 2); len(parts) == 2 {
+		platform, userID = parts[0], parts[1]
+	}
+
+	var name, description sql.NullString
+	err = tx.QueryRow(`
+		SELECT name, description FROM sessions WHERE id = ?
+	`, fromSessionID).Scan(&name, &description)
+	if err != nil && err != sql.ErrNoRows {
+		return fmt.Errorf("failed to get source session: %w", err)
+	}
+	if name.String == "" {
+		name.String = toSessionID
+	}
+
+	metadataJSON, _ := json.Marshal(map[string]interface{}{
+		"transferred_from": fromSessionID,
+		"transferred_at":   time.Now().Format(time.RFC3339),
+	})
+
+	_, err = tx.Exec(`
+		INSERT INTO sessions (id, name, platform, user_id, metadata, description)
+		VALUES (?, ?, ?, ?, ?, ?)
+	`, toSessionID, name.String, platform, userID, string(metadataJSON), description.String)
+	if err != nil {
+		return fmt.Errorf("failed to create target session: %w", err)
+	}
+
+	_, err = tx.Exec(`
+		INSERT INTO messages (session_id, role, content, metadata, timestamp)
+		SELECT ?, role, content, metadata, timestamp
+		FROM messages WHERE session_id = ?
+		ORDER BY id
+	`, toSessionID, fromSessionID)
+	if err != nil {
+		return fmt.Errorf("failed to copy messages: %w", err)
+	}
+
+	// Pair each source message with its copy; the message_metadata rows of
+	// the copies are filled in by the insert trigger
+	pairs := `
+		SELECT original.id AS original_id, copied.id AS copied_id
+		FROM (SELECT id, ROW_NUMBER() OVER (ORDER BY id) AS n FROM messages WHERE session_id = ?) AS copied
+		JOIN (SELECT id, ROW_NUMBER() OVER (ORDER BY id) AS n FROM messages WHERE session_id = ?) AS original
+		ON copied.n = original.n
+	`
+
+	_, err = tx.Exec(`
+		INSERT INTO messages_embeddings (message_id, embedding)
+		SELECT pairs.copied_id, e.embedding
+		FROM (`+pairs+`) AS pairs
+		JOIN messages_embeddings e ON e.message_id = pairs.original_id
+	`, toSessionID, fromSessionID)
+	if err != nil {
+		return fmt.Errorf("failed to copy message embeddings: %w", err)
+	}
+
+	// Long-term memory keys are unique, so the memories themselves are
+	// shared and only their source message moves to the copied message
+	_, err = tx.Exec(`
+		UPDATE long_term_memory SET source_message_id = (
+			SELECT pairs.copied_id FROM (`+pairs+`) AS pairs
+			WHERE pairs.original_id = long_term_memory.source_message_id
+		)
+		WHERE source_message_id IN (SELECT id FROM messages WHERE session_id = ?)
+	`, toSessionID, fromSessionID, fromSessionID)
+	if err != nil {
+		return fmt.Errorf("failed to move long-term memories: %w", err)
+	}
+
+	_, err = tx.Exec(`
+		INSERT INTO session_config (session_id, key, value, updated_at)
+		SELECT ?, key, value, updated_at FROM session_config WHERE session_id = ?
+	`, toSessionID, fromSessionID)
+	if err != nil {
+		return fmt.Errorf("failed to copy session config: %w", err)
+	}
+
+	_, err = tx.Exec(`
+		INSERT OR IGNORE INTO tags (session_id, tag)
+		SELECT ?, tag FROM tags WHERE session_id = ?
+	`, toSessionID, fromSessionID)
+	if err != nil {
+		return fmt.Errorf("failed to copy tags: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transfer: %w", err)
+	}
+
+	return nil
+}
+
+// sessionExists reports whether a session has a sessions row or messages
+func sessionExists(tx *sql.Tx, sessionID string) (bool, error) {
+	var exists bool
+	err := tx.QueryRow(`
+		SELECT EXISTS(SELECT 1 FROM sessions WHERE id = ?)
+			OR EXISTS(SELECT 1 FROM messages WHERE session_id = ?)
+	`, sessionID, sessionID).Scan(&exists)
+	if err != nil {
+		return false, fmt.Errorf("failed to check session %s: %w", sessionID, err)
+	}
+	return exists, nil
+}
+
+// Close stops purging expired memories and closes the database connection
 func (m *Memory) Close() error {
+	m.closeOnce.Do(func() {
+		if m.stopPurge != nil {
+			close(m.stopPurge)
+		}
+	})
 	if m.conn != nil {
 		return m.conn.Close()
 	}
@@ -269,16 +729,110 @@ func TestMemory() {
 	log.Println("✓ Session created")
 
 	// Add messages
-	_, err = mem.AddMessage("test_session", "user", "Hello QuickBot!", nil)
+	_, _, err = mem.AddMessage("test_session", "user", "Hello QuickBot!", nil)
 	if err != nil {
 		log.Fatalf("Failed to add message: %v", err)
 	}
-	_, err = mem.AddMessage("test_session", "assistant", "Hi there!", nil)
+	_, _, err = mem.AddMessage("test_session", "assistant", "Hi there!", nil)
 	if err != nil {
 		log.Fatalf("Failed to add message: %v", err)
 	}
 	log.Println("✓ Messages added")
 
+	// Rename sessions and search by name
+	for i, name := range []string{"work-alpha", "home", "work-beta", "homework", "work-gamma"} {
+		id := fmt.Sprintf("name_session_%d", i)
+		if err := mem.CreateSession(id, id, "test", "user123"); err != nil {
+			log.Fatalf("Failed to create session: %v", err)
+		}
+		if err := mem.RenameSession(id, name, "Description of "+name); err != nil {
+			log.Fatalf("Failed to rename session: %v", err)
+		}
+	}
+	named, err := mem.GetSessionsByName("work%")
+	if err != nil {
+		log.Fatalf("Failed to get sessions by name: %v", err)
+	}
+	if len(named) != 3 || named[0].Name != "work-alpha" || named[2].Name != "work-gamma" {
+		log.Fatalf("Expected the 3 work sessions sorted by name, got %d", len(named))
+	}
+	if named[1].Description != "Description of work-beta" {
+		log.Fatalf("Expected description to be stored, got %q", named[1].Description)
+	}
+	if err := mem.RenameSession("missing_session", "work", ""); err == nil {
+		log.Fatalf("Expected renaming a missing session to fail")
+	}
+	log.Printf("✓ Found %d sessions named work%%", len(named))
+
+	// Build the graph of a tool call and its result
+	callID, _, err := mem.AddMessage("test_session", "assistant", "TOOL:memory:operation=set,key=color,value=blue", map[string]interface{}{"tool_name": "memory"})
+	if err != nil {
+		log.Fatalf("Failed to add tool call: %v", err)
+	}
+	resultID, _, err := mem.AddMessage("test_session", "assistant", "[Tool: memory] Success: Remembered 'color'", map[string]interface{}{"tool_call_id": callID})
+	if err != nil {
+		log.Fatalf("Failed to add tool result: %v", err)
+	}
+	if err := mem.SetLongTerm("color", "blue", 2, 0); err != nil {
+		log.Fatalf("Failed to set long-term memory: %v", err)
+	}
+	if err := mem.LinkLongTermToMessage(callID, "color"); err != nil {
+		log.Fatalf("Failed to link long-term memory: %v", err)
+	}
+	graph, err := mem.GetSessionGraph("test_session")
+	if err != nil {
+		log.Fatalf("Failed to get session graph: %v", err)
+	}
+	want := map[GraphEdge]bool{
+		{From: messageNodeID(int(callID)), To: messageNodeID(int(resultID)), Type: EdgeToolResult}: false,
+		{From: messageNodeID(int(callID)), To: messageNodeID(int(resultID)), Type: EdgeFollows}:    false,
+	}
+	created := false
+	for _, edge := range graph.Edges {
+		if _, ok := want[edge]; ok {
+			want[edge] = true
+		}
+		if edge.Type == EdgeCreated && edge.From == messageNodeID(int(callID)) {
+			created = true
+		}
+	}
+	for edge, found := range want {
+		if !found {
+			log.Fatalf("Expected graph edge %+v", edge)
+		}
+	}
+	if !created || len(graph.Nodes) != 5 {
+		log.Fatalf("Expected 4 messages and 1 long-term memory in graph, got %d nodes", len(graph.Nodes))
+	}
+	log.Printf("✓ Session graph: %d nodes, %d edges", len(graph.Nodes), len(graph.Edges))
+
+	// Find tool calls by their metadata
+	var shellID int64
+	for _, tool := range []string{"shell", "file", "http"} {
+		id, _, err := mem.AddMessage("metadata_session", "assistant", "TOOL: "+tool, map[string]interface{}{MetaToolName: tool})
+		if err != nil {
+			log.Fatalf("Failed to add tool call: %v", err)
+		}
+		if tool == "shell" {
+			shellID = id
+		}
+	}
+	if err := mem.SetMessageMetadata(shellID, MetaToolStatus, ToolStatusError); err != nil {
+		log.Fatalf("Failed to set message metadata: %v", err)
+	}
+	shellCalls, err := mem.GetMessagesByMetadata(MetaToolName, "shell", "")
+	if err != nil {
+		log.Fatalf("Failed to get messages by metadata: %v", err)
+	}
+	if len(shellCalls) != 1 || shellCalls[0].Content != "TOOL: shell" {
+		log.Fatalf("Expected 1 shell tool call, got %d", len(shellCalls))
+	}
+	failed, err := mem.GetMessagesByMetadata(MetaToolStatus, ToolStatusError, "metadata_session")
+	if err != nil || len(failed) != 1 || int64(failed[0].ID) != shellID {
+		log.Fatalf("Expected the shell call to be marked failed, got %d: %v", len(failed), err)
+	}
+	log.Println("✓ Messages found by metadata")
+
 	// Get messages
 	messages, err := mem.GetMessages("test_session", 10)
 	if err != nil {
@@ -287,7 +841,7 @@ func TestMemory() {
 	log.Printf("✓ Retrieved %d messages", len(messages))
 
 	// Set long-term memory
-	err = mem.SetLongTerm("user_name", "Alice", 2)
+	err = mem.SetLongTerm("user_name", "Alice", 2, 0)
 	if err != nil {
 		log.Fatalf("Failed to set long-term memory: %v", err)
 	}
@@ -300,7 +854,228 @@ func TestMemory() {
 	}
 	log.Printf("� Retrieved long-term memory: %s", value)
 
+	// Hybrid search: message i is i days old and older messages mention
+	// pizza more often; every fourth message does not mention it at all
+	for i := 0; i < 20; i++ {
+		content := fmt.Sprintf("note %d about pasta", i)
+		if i%4 != 3 {
+			content = fmt.Sprintf("note %d about %s", i, strings.Repeat("pizza ", 1+i/3))
+		}
+		id, _, err := mem.AddMessage("search_session", "user", content, nil)
+		if err != nil {
+			log.Fatalf("Failed to add message: %v", err)
+		}
+		mem.conn.Exec(`UPDATE messages SET timestamp = datetime('now', ?) WHERE id = ?`, fmt.Sprintf("-%d days", i), id)
+	}
+	recent, err := mem.HybridSearch("pizza", "search_session", 5, 1.0)
+	if err != nil || len(recent) != 5 || !strings.HasPrefix(recent[0].Content, "note 0 ") {
+		log.Fatalf("Expected the newest match first with recency weight 1, got %v: %v", recent, err)
+	}
+	relevant, err := mem.HybridSearch("pizza", "search_session", 5, 0.0)
+	if err != nil || len(relevant) != 5 || !strings.HasPrefix(relevant[0].Content, "note 18 ") {
+		log.Fatalf("Expected the most relevant match first with recency weight 0, got %v: %v", relevant, err)
+	}
+	all, err := mem.HybridSearch("pizza", "search_session", 0, 0.3)
+	if err != nil || len(all) != 15 {
+		log.Fatalf("Expected 15 matches, got %d: %v", len(all), err)
+	}
+	log.Println("✓ Hybrid search ranks by relevance and recency")
+
 	// Cleanup
 	os.Remove("test_memory.db")
 	log.Println("✓ Memory module tests passed")
 }
+
+// TestSessionTransfer tests copying a session to another platform
+func TestSessionTransfer() error {
+	log.Println("Testing session transfer...")
+
+	dir, err := os.MkdirTemp("", "quickbot-transfer")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+
+	mem, err := NewMemory(dir+"/transfer.db", 100)
+	if err != nil {
+		return err
+	}
+	defer mem.Close()
+
+	if err := mem.CreateSession("telegram:123", "Alice", "telegram", "123"); err != nil {
+		return err
+	}
+	mem.SetEmbeddingProvider(letterEmbedder{})
+	var ids []int64
+	for i := 0; i < 10; i++ {
+		role := "user"
+		if i%2 == 1 {
+			role = "assistant"
+		}
+		id, _, err := mem.AddMessage("telegram:123", role, fmt.Sprintf("message %d", i), map[string]interface{}{"index": i})
+		if err != nil {
+			return err
+		}
+		ids = append(ids, id)
+	}
+	if err := mem.SetSessionConfig("telegram:123", "language", "zh"); err != nil {
+		return err
+	}
+	if err := mem.SetLongTerm("favorite_color", "blue", 1, 0); err != nil {
+		return err
+	}
+	if err := mem.LinkLongTermToMessage(ids[4], "favorite_color"); err != nil {
+		return err
+	}
+
+	if err := mem.TransferSession("telegram:123", "discord:456"); err != nil {
+		return fmt.Errorf("failed to transfer session: %w", err)
+	}
+
+	messages, err := mem.GetMessagesBetween("discord:456", 0, 0)
+	if err != nil {
+		return err
+	}
+	if len(messages) != 10 {
+		return fmt.Errorf("expected 10 transferred messages, got %d", len(messages))
+	}
+	for i, msg := range messages {
+		if want := fmt.Sprintf("message %d", i); msg.Content != want {
+			return fmt.Errorf("expected transferred message %d to be %q, got %q", i, want, msg.Content)
+		}
+	}
+	original, err := mem.GetMessages("telegram:123", 0)
+	if err != nil || len(original) != 10 {
+		return fmt.Errorf("expected the source session to keep its 10 messages, got %d: %v", len(original), err)
+	}
+
+	session, err := mem.GetSessionByUserID("discord", "456")
+	if err != nil {
+		return err
+	}
+	if session == nil || session.ID != "discord:456" || session.Name != "Alice" {
+		return fmt.Errorf("expected transferred session discord:456, got %+v", session)
+	}
+	if from, _ := session.Metadata["transferred_from"].(string); from != "telegram:123" {
+		return fmt.Errorf("expected transferred_from telegram:123 in metadata, got %v", session.Metadata)
+	}
+	if language, err := mem.GetSessionConfig("discord:456", "language"); err != nil || language != "zh" {
+		return fmt.Errorf("expected session config to be copied, got %q: %v", language, err)
+	}
+
+	graph, err := mem.GetSessionGraph("discord:456")
+	if err != nil {
+		return err
+	}
+	linked := false
+	for _, edge := range graph.Edges {
+		if edge.Type == EdgeCreated && edge.From == messageNodeID(messages[4].ID) {
+			linked = true
+		}
+	}
+	if !linked {
+		return fmt.Errorf("expected the long-term memory to be linked to the transferred session")
+	}
+	graph, err = mem.GetSessionGraph("telegram:123")
+	if err != nil {
+		return err
+	}
+	for _, edge := range graph.Edges {
+		if edge.Type == EdgeCreated {
+			return fmt.Errorf("expected the long-term memory to move out of the source session, got %+v", edge)
+		}
+	}
+
+	tagged, err := mem.GetMessagesByMetadata("index", "4", "discord:456")
+	if err != nil {
+		return err
+	}
+	if len(tagged) != 1 || tagged[0].ID != messages[4].ID {
+		return fmt.Errorf("expected the metadata of the copied messages to be indexed, got %+v", tagged)
+	}
+	var embedded int
+	err = mem.conn.QueryRow(`
+		SELECT COUNT(*) FROM messages_embeddings
+		WHERE message_id IN (SELECT id FROM messages WHERE session_id = ?)
+	`, "discord:456").Scan(&embedded)
+	if err != nil || embedded != 10 {
+		return fmt.Errorf("expected 10 copied embeddings, got %d: %v", embedded, err)
+	}
+	log.Println("✓ Messages, metadata, embeddings, config and memories transferred")
+
+	if err := mem.TransferSession("telegram:123", "discord:456"); !errors.Is(err, ErrSessionExists) {
+		return fmt.Errorf("expected transferring onto an existing session to fail, got %v", err)
+	}
+	if err := mem.TransferSession("telegram:999", "discord:789"); !errors.Is(err, ErrSessionNotFound) {
+		return fmt.Errorf("expected transferring an unknown session to fail, got %v", err)
+	}
+	if session, _ := mem.GetSession("discord:789"); session != nil {
+		return fmt.Errorf("expected a failed transfer to create no session")
+	}
+	log.Println("✓ Existing targets and unknown sources rejected")
+
+	return nil
+}
+
+// TestMessageRange tests looking messages up by ID and by ID range
+func TestMessageRange() error {
+	log.Println("Testing message ranges...")
+
+	dir, err := os.MkdirTemp("", "quickbot-range")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+
+	mem, err := NewMemory(dir+"/range.db", 100)
+	if err != nil {
+		return err
+	}
+	defer mem.Close()
+
+	var ids []int
+	for i := 0; i < 5; i++ {
+		for _, sessionID := range []string{"range", "other"} {
+			id, _, err := mem.AddMessage(sessionID, "user", fmt.Sprintf("%s %d", sessionID, i), nil)
+			if err != nil {
+				return err
+			}
+			if sessionID == "range" {
+				ids = append(ids, int(id))
+			}
+		}
+	}
+
+	msg, err := mem.GetMessageByID(ids[2])
+	if err != nil {
+		return err
+	}
+	if msg.ID != ids[2] || msg.SessionID != "range" || msg.Content != "range 2" {
+		return fmt.Errorf("expected message %d to be \"range 2\", got %+v", ids[2], msg)
+	}
+	if _, err := mem.GetMessageByID(ids[4] + 100); err == nil {
+		return fmt.Errorf("expected an unknown message ID to fail")
+	}
+	log.Println("✓ Message looked up by ID")
+
+	between, err := mem.GetMessagesBetween("range", ids[1], ids[3])
+	if err != nil {
+		return err
+	}
+	if len(between) != 3 || between[0].Content != "range 1" || between[2].Content != "range 3" {
+		return fmt.Errorf("expected messages 1 to 3 of the session, oldest first, got %+v", between)
+	}
+	all, err := mem.GetMessagesBetween("range", ids[3], 0)
+	if err != nil {
+		return err
+	}
+	if len(all) != 2 || all[1].Content != "range 4" {
+		return fmt.Errorf("expected the messages from 3 on without an upper bound, got %+v", all)
+	}
+	if none, err := mem.GetMessagesBetween("range", ids[3], ids[1]); err != nil || len(none) != 0 {
+		return fmt.Errorf("expected an empty range to return no messages, got %+v (%v)", none, err)
+	}
+	log.Println("✓ Messages listed by ID range")
+
+	return nil
+}
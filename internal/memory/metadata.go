@@ -0,0 +1,134 @@
+package main
+
+import "fmt"
+
+// Message metadata keys indexed for tracing
+const (
+	MetaToolName   = "tool_name"
+	MetaToolStatus = "tool_status"
+	MetaProvider   = "provider"
+	MetaModel      = "model"
+
+	// MetaPlatformMessageID is the ID of a message on its platform, used
+	// to drop redeliveries
+	MetaPlatformMessageID = "platform_message_id"
+	// MetaPlatform is the platform a user message came from
+	MetaPlatform = "platform"
+)
+
+// Tool call statuses stored under MetaToolStatus
+const (
+	ToolStatusRunning = "running"
+	ToolStatusSuccess = "success"
+	ToolStatusError   = "error"
+)
+
+// metadataObjectSQL returns the metadata of a message row if it is a JSON
+// object, else NULL, which json_each reads as no entries
+const metadataObjectSQL = `CASE WHEN json_valid(%[1]s.metadata) THEN
+	CASE json_type(%[1]s.metadata) WHEN 'object' THEN %[1]s.metadata END END`
+
+// initMessageMetadata creates the message_metadata table, which holds the
+// top-level keys of each message's metadata, and the triggers that keep
+// it in sync with the messages table
+func (m *Memory) initMessageMetadata() error {
+	var exists bool
+	err := m.conn.QueryRow(`
+		SELECT EXISTS(SELECT 1 FROM sqlite_master WHERE type = 'table' AND name = 'message_metadata')
+	`).Scan(&exists)
+	if err != nil {
+		return fmt.Errorf("failed to check message_metadata table: %w", err)
+	}
+
+	_, err = m.conn.Exec(`
+		CREATE TABLE IF NOT EXISTS message_metadata (
+			message_id INTEGER NOT NULL,
+			key TEXT NOT NULL,
+			value TEXT
+		);
+		CREATE INDEX IF NOT EXISTS idx_message_metadata_key_value ON message_metadata(key, value);
+		CREATE INDEX IF NOT EXISTS idx_message_metadata_message ON message_metadata(message_id);
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to create message_metadata table: %w", err)
+	}
+
+	_, err = m.conn.Exec(fmt.Sprintf(`
+		CREATE TRIGGER IF NOT EXISTS messages_metadata_ai AFTER INSERT ON messages BEGIN
+			INSERT INTO message_metadata (message_id, key, value)
+			SELECT new.id, key, value FROM json_each(`+metadataObjectSQL+`);
+		END;
+		CREATE TRIGGER IF NOT EXISTS messages_metadata_ad AFTER DELETE ON messages BEGIN
+			DELETE FROM message_metadata WHERE message_id = old.id;
+		END;
+		CREATE TRIGGER IF NOT EXISTS messages_metadata_au AFTER UPDATE OF metadata ON messages BEGIN
+			DELETE FROM message_metadata WHERE message_id = old.id;
+			INSERT INTO message_metadata (message_id, key, value)
+			SELECT new.id, key, value FROM json_each(`+metadataObjectSQL+`);
+		END;
+	`, "new"))
+	if err != nil {
+		return fmt.Errorf("failed to create message_metadata triggers: %w", err)
+	}
+
+	// Index messages stored before the table existed
+	if !exists {
+		_, err = m.conn.Exec(fmt.Sprintf(`
+			INSERT INTO message_metadata (message_id, key, value)
+			SELECT messages.id, meta.key, meta.value
+			FROM messages, json_each(`+metadataObjectSQL+`) AS meta
+		`, "messages"))
+		if err != nil {
+			return fmt.Errorf("failed to index message metadata: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// GetMessagesByMetadata returns the messages whose metadata has key set
+// to value, oldest first. An empty sessionID searches all sessions.
+func (m *Memory) GetMessagesByMetadata(key, value string, sessionID string) ([]Message, error) {
+	query := `SELECT messages.id, messages.session_id, messages.role, messages.content,
+	                 messages.metadata, messages.timestamp
+	          FROM messages JOIN message_metadata ON message_metadata.message_id = messages.id
+	          WHERE message_metadata.key = ? AND message_metadata.value = ?`
+	args := []interface{}{key, value}
+	if sessionID != "" {
+		query += ` AND messages.session_id = ?`
+		args = append(args, sessionID)
+	}
+	query += ` ORDER BY messages.id ASC`
+
+	rows, err := m.conn.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query messages by metadata: %w", err)
+	}
+	defer rows.Close()
+
+	messages := []Message{}
+	for rows.Next() {
+		var msg Message
+		err := rows.Scan(&msg.ID, &msg.SessionID, &msg.Role, &msg.Content, &msg.Metadata, &msg.Timestamp)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan message: %w", err)
+		}
+		messages = append(messages, msg)
+	}
+
+	return messages, rows.Err()
+}
+
+// SetMessageMetadata sets one key of a message's metadata, e.g. the
+// status of a tool call once it has run
+func (m *Memory) SetMessageMetadata(messageID int64, key string, value interface{}) error {
+	_, err := m.conn.Exec(fmt.Sprintf(`
+		UPDATE messages
+		SET metadata = json_set(COALESCE(`+metadataObjectSQL+`, '{}'), '$.' || json_quote(?), ?)
+		WHERE id = ?
+	`, "messages"), key, value, messageID)
+	if err != nil {
+		return fmt.Errorf("failed to set message metadata: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,91 @@
+package main
+
+import "fmt"
+
+// migrateSessionDescription adds the description column to sessions
+// tables created before sessions could be named
+func (m *Memory) migrateSessionDescription() error {
+	return m.addColumn("sessions", "description", "TEXT")
+}
+
+// addColumn adds a column to a table unless it already has it
+func (m *Memory) addColumn(table, column, definition string) error {
+	var exists bool
+	err := m.conn.QueryRow(`
+		SELECT EXISTS(SELECT 1 FROM pragma_table_info(?) WHERE name = ?)
+	`, table, column).Scan(&exists)
+	if err != nil {
+		return fmt.Errorf("failed to check %s columns: %w", table, err)
+	}
+	if exists {
+		return nil
+	}
+
+	_, err = m.conn.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", table, column, definition))
+	if err != nil {
+		return fmt.Errorf("failed to add %s.%s column: %w", table, column, err)
+	}
+	return nil
+}
+
+// RenameSession sets the name and description of an existing session
+func (m *Memory) RenameSession(id, name, description string) error {
+	if name == "" {
+		return fmt.Errorf("name is required")
+	}
+
+	result, err := m.conn.Exec(`
+		UPDATE sessions SET name = ?, description = ?, updated_at = CURRENT_TIMESTAMP
+		WHERE id = ?
+	`, name, description, id)
+	if err != nil {
+		return fmt.Errorf("failed to rename session: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to rename session: %w", err)
+	}
+	if rows == 0 {
+		return fmt.Errorf("session not found: %s", id)
+	}
+	return nil
+}
+
+// GetSessionsByName returns the sessions whose name matches a SQL LIKE
+// pattern, e.g. "work%", sorted by name
+func (m *Memory) GetSessionsByName(pattern string) ([]Session, error) {
+	rows, err := m.conn.Query(`
+		SELECT id FROM sessions WHERE name LIKE ? ORDER BY name, id
+	`, pattern)
+	if err != nil {
+		return nil, fmt.Errorf("failed to search sessions: %w", err)
+	}
+
+	var sessionIDs []string
+	for rows.Next() {
+		var sessionID string
+		if err := rows.Scan(&sessionID); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to scan session: %w", err)
+		}
+		sessionIDs = append(sessionIDs, sessionID)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to search sessions: %w", err)
+	}
+
+	sessions := make([]Session, 0, len(sessionIDs))
+	for _, sessionID := range sessionIDs {
+		session, err := m.GetSession(sessionID)
+		if err != nil {
+			return nil, err
+		}
+		if session != nil {
+			sessions = append(sessions, *session)
+		}
+	}
+
+	return sessions, nil
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+)
+
+// initMessageSearch creates the FTS5 index on message contents when FTS5
+// is available; without it HybridSearch counts word occurrences instead
+// of using BM25
+func (m *Memory) initMessageSearch() error {
+	if !m.fts {
+		return nil
+	}
+
+	var exists int
+	err := m.conn.QueryRow(`
+		SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = 'messages_fts'
+	`).Scan(&exists)
+	if err != nil {
+		return fmt.Errorf("failed to check message index: %w", err)
+	}
+
+	if exists == 0 {
+		_, err = m.conn.Exec(`
+			CREATE VIRTUAL TABLE messages_fts USING fts5(
+				content, content='messages', content_rowid='id'
+			)
+		`)
+		if err != nil {
+			return fmt.Errorf("failed to create message index: %w", err)
+		}
+
+		// Index messages stored before the index existed
+		_, err = m.conn.Exec(`INSERT INTO messages_fts(messages_fts) VALUES ('rebuild')`)
+		if err != nil {
+			return fmt.Errorf("failed to build message index: %w", err)
+		}
+	}
+
+	_, err = m.conn.Exec(`
+		CREATE TRIGGER IF NOT EXISTS messages_fts_ai AFTER INSERT ON messages BEGIN
+			INSERT INTO messages_fts(rowid, content) VALUES (new.id, new.content);
+		END;
+		CREATE TRIGGER IF NOT EXISTS messages_fts_ad AFTER DELETE ON messages BEGIN
+			INSERT INTO messages_fts(messages_fts, rowid, content) VALUES ('delete', old.id, old.content);
+		END;
+		CREATE TRIGGER IF NOT EXISTS messages_fts_au AFTER UPDATE OF content ON messages BEGIN
+			INSERT INTO messages_fts(messages_fts, rowid, content) VALUES ('delete', old.id, old.content);
+			INSERT INTO messages_fts(rowid, content) VALUES (new.id, new.content);
+		END;
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to create message index triggers: %w", err)
+	}
+	return nil
+}
+
+// hybridRankSQL orders the matches CTE (id, relevance, recency) by the
+// weighted sum of relevance, scaled to 0-1 by the best match, and recency
+const hybridRankSQL = `
+	SELECT m.id, m.session_id, m.role, m.content, COALESCE(m.metadata, ''), m.timestamp
+	FROM (
+		SELECT id, relevance / MAX(MAX(relevance) OVER (), 1e-9) AS relevance, recency FROM matches
+	) ranked
+	JOIN messages m ON m.id = ranked.id
+	ORDER BY (1 - ?) * ranked.relevance + ? * ranked.recency DESC, m.id DESC
+	LIMIT ?`
+
+// recencySQL scores a message 1 / (1 + age in days)
+const recencySQL = `1.0 / (1.0 + MAX(julianday('now') - julianday(m.timestamp), 0))`
+
+// HybridSearch returns the messages of a session that match query,
+// ranked by a weighted sum of text relevance and recency. A recencyWeight
+// of 0 ranks by relevance (FTS5 BM25) only, 1 by recency only. An empty
+// sessionID searches all sessions.
+func (m *Memory) HybridSearch(query, sessionID string, limit int, recencyWeight float64) ([]Message, error) {
+	words := strings.Fields(query)
+	if len(words) == 0 {
+		return nil, fmt.Errorf("search query is required")
+	}
+	if recencyWeight < 0 || recencyWeight > 1 {
+		return nil, fmt.Errorf("recency weight must be between 0 and 1")
+	}
+	if limit <= 0 {
+		limit = -1
+	}
+
+	var matches string
+	var args []interface{}
+	if m.fts {
+		// Quote each word so punctuation is not parsed as FTS5 syntax
+		terms := make([]string, len(words))
+		for i, word := range words {
+			terms[i] = `"` + strings.ReplaceAll(word, `"`, `""`) + `"`
+		}
+
+		// bm25() is lower for better matches
+		matches = `
+			SELECT m.id, -bm25(messages_fts) AS relevance, ` + recencySQL + ` AS recency
+			FROM messages_fts JOIN messages m ON m.id = messages_fts.rowid
+			WHERE messages_fts MATCH ?`
+		args = append(args, strings.Join(terms, " "))
+	} else {
+		// Relevance is the number of occurrences of the query words
+		conditions := make([]string, len(words))
+		counts := make([]string, len(words))
+		for i, word := range words {
+			conditions[i] = "instr(lower(m.content), lower(?)) > 0"
+			counts[i] = "(length(m.content) - length(replace(lower(m.content), lower(?), ''))) / length(?)"
+			args = append(args, word)
+		}
+		countArgs := make([]interface{}, 0, 2*len(words))
+		for _, word := range words {
+			countArgs = append(countArgs, word, word)
+		}
+		args = append(countArgs, args...)
+
+		matches = `
+			SELECT m.id, 1.0 * (` + strings.Join(counts, " + ") + `) AS relevance, ` + recencySQL + ` AS recency
+			FROM messages m
+			WHERE ` + strings.Join(conditions, " AND ")
+	}
+	if sessionID != "" {
+		matches += ` AND m.session_id = ?`
+		args = append(args, sessionID)
+	}
+	args = append(args, recencyWeight, recencyWeight, limit)
+
+	rows, err := m.conn.Query(`WITH matches AS (`+matches+`)`+hybridRankSQL, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to search messages: %w", err)
+	}
+	defer rows.Close()
+
+	return scanMessages(rows)
+}
+
+func scanMessages(rows *sql.Rows) ([]Message, error) {
+	messages := []Message{}
+	for rows.Next() {
+		var msg Message
+		err := rows.Scan(&msg.ID, &msg.SessionID, &msg.Role, &msg.Content, &msg.Metadata, &msg.Timestamp)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan message: %w", err)
+		}
+		messages = append(messages, msg)
+	}
+	return messages, rows.Err()
+}
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"time"
+)
+
+// Snapshot returns the messages of a session stored at or before asOf, in
+// chronological order, and the long-term memories last updated at or
+// before asOf. Deleted rows are not retained, and long-term memories
+// updated after asOf are left out rather than shown with an older value.
+func (m *Memory) Snapshot(sessionID string, asOf time.Time) ([]Message, map[string]string, error) {
+	messages, err := m.messagesBetween(sessionID, time.Time{}, asOf)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	rows, err := m.conn.Query(`
+		SELECT key, value FROM long_term_memory WHERE updated_at <= ?
+	`, asOf.UTC().Format(sqliteTimeFormat))
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to query long-term memory: %w", err)
+	}
+	defer rows.Close()
+
+	longTerm := make(map[string]string)
+	for rows.Next() {
+		var key, value string
+		if err := rows.Scan(&key, &value); err != nil {
+			return nil, nil, fmt.Errorf("failed to scan long-term memory: %w", err)
+		}
+		longTerm[key] = value
+	}
+	if err := rows.Err(); err != nil {
+		return nil, nil, fmt.Errorf("failed to query long-term memory: %w", err)
+	}
+
+	return messages, longTerm, nil
+}
+
+// MessageDiff returns how the messages of a session changed from the
+// state at fromTime to the state at toTime: messages stored in between
+// are added when toTime is later, and removed when it is earlier
+func (m *Memory) MessageDiff(sessionID string, fromTime, toTime time.Time) (added []Message, removed []Message, err error) {
+	if toTime.Before(fromTime) {
+		removed, err = m.messagesBetween(sessionID, toTime, fromTime)
+		return nil, removed, err
+	}
+
+	added, err = m.messagesBetween(sessionID, fromTime, toTime)
+	return added, nil, err
+}
+
+// messagesBetween returns the messages of a session stored after from and
+// at or before to, in chronological order. A zero from has no lower bound.
+func (m *Memory) messagesBetween(sessionID string, from, to time.Time) ([]Message, error) {
+	query := `SELECT id, session_id, role, content, COALESCE(metadata, ''), timestamp
+	          FROM messages WHERE session_id = ? AND timestamp <= ?`
+	args := []interface{}{sessionID, to.UTC().Format(sqliteTimeFormat)}
+	if !from.IsZero() {
+		query += ` AND timestamp > ?`
+		args = append(args, from.UTC().Format(sqliteTimeFormat))
+	}
+	query += ` ORDER BY timestamp, id`
+
+	rows, err := m.conn.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query messages: %w", err)
+	}
+	defer rows.Close()
+
+	var messages []Message
+	for rows.Next() {
+		var msg Message
+		err := rows.Scan(&msg.ID, &msg.SessionID, &msg.Role, &msg.Content, &msg.Metadata, &msg.Timestamp)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan message: %w", err)
+		}
+		messages = append(messages, msg)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to query messages: %w", err)
+	}
+
+	return messages, nil
+}
+
+// TestSnapshot stores messages and long-term memories across several
+// timestamps and checks the snapshot and diffs at intermediate times
+func TestSnapshot() error {
+	log.Println("Testing memory snapshots...")
+
+	dbPath := "test_snapshot.db"
+	defer os.Remove(dbPath)
+
+	mem, err := NewMemory(dbPath, 100)
+	if err != nil {
+		return err
+	}
+	defer mem.Close()
+
+	// Message i and long-term memory "key i" are stored at hour i
+	base := time.Date(2024, 1, 15, 9, 0, 0, 0, time.UTC)
+	at := func(hour int) time.Time {
+		return base.Add(time.Duration(hour) * time.Hour)
+	}
+	for i := 0; i < 5; i++ {
+		stamp := at(i).Format(sqliteTimeFormat)
+		id, _, err := mem.AddMessage("timeline", "user", fmt.Sprintf("message %d", i), nil)
+		if err != nil {
+			return err
+		}
+		if _, err := mem.conn.Exec(`UPDATE messages SET timestamp = ? WHERE id = ?`, stamp, id); err != nil {
+			return err
+		}
+
+		key := fmt.Sprintf("key %d", i)
+		if err := mem.SetLongTerm(key, fmt.Sprintf("value %d", i), 1, 0); err != nil {
+			return err
+		}
+		if _, err := mem.conn.Exec(`UPDATE long_term_memory SET updated_at = ? WHERE key = ?`, stamp, key); err != nil {
+			return err
+		}
+	}
+	if _, _, err := mem.AddMessage("other", "user", "other session", nil); err != nil {
+		return err
+	}
+
+	contents := func(messages []Message) string {
+		var list []string
+		for _, msg := range messages {
+			list = append(list, msg.Content)
+		}
+		return fmt.Sprint(list)
+	}
+
+	messages, longTerm, err := mem.Snapshot("timeline", at(2).Add(30*time.Minute))
+	if err != nil {
+		return err
+	}
+	if got := contents(messages); got != "[message 0 message 1 message 2]" {
+		return fmt.Errorf("expected the first 3 messages in the snapshot, got %s", got)
+	}
+	if len(longTerm) != 3 || longTerm["key 2"] != "value 2" || longTerm["key 3"] != "" {
+		return fmt.Errorf("expected the first 3 long-term memories in the snapshot, got %v", longTerm)
+	}
+	messages, _, err = mem.Snapshot("timeline", at(2))
+	if err != nil || contents(messages) != "[message 0 message 1 message 2]" {
+		return fmt.Errorf("expected a message at asOf to be included, got %s (%v)", contents(messages), err)
+	}
+	messages, longTerm, err = mem.Snapshot("timeline", at(-1))
+	if err != nil || len(messages) != 0 || len(longTerm) != 0 {
+		return fmt.Errorf("expected an empty snapshot before the first message, got %s and %v (%v)", contents(messages), longTerm, err)
+	}
+	log.Println("✓ Snapshot at an intermediate time holds only earlier messages")
+
+	added, removed, err := mem.MessageDiff("timeline", at(1), at(3))
+	if err != nil || contents(added) != "[message 2 message 3]" || len(removed) != 0 {
+		return fmt.Errorf("expected messages 2 and 3 added, got %s and %s (%v)", contents(added), contents(removed), err)
+	}
+	added, removed, err = mem.MessageDiff("timeline", at(3), at(1))
+	if err != nil || len(added) != 0 || contents(removed) != "[message 2 message 3]" {
+		return fmt.Errorf("expected messages 2 and 3 removed, got %s and %s (%v)", contents(added), contents(removed), err)
+	}
+	log.Println("✓ Message diff between two times")
+
+	return nil
+}
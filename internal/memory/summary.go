@@ -0,0 +1,21 @@
+package main
+
+// sessionSummaryImportance is the importance of stored session summaries
+const sessionSummaryImportance = 5
+
+// SessionSummaryKey returns the long-term memory key of a session's summary
+func SessionSummaryKey(sessionID string) string {
+	return "session_summary:" + sessionID
+}
+
+// SetSessionSummary stores the condensed summary of a session in
+// long-term memory
+func (m *Memory) SetSessionSummary(sessionID, summary string) error {
+	return m.SetLongTerm(SessionSummaryKey(sessionID), summary, sessionSummaryImportance, 0)
+}
+
+// GetSessionSummary returns the condensed summary of a session, or an
+// empty string if it has not been summarized
+func (m *Memory) GetSessionSummary(sessionID string) (string, error) {
+	return m.GetLongTerm(SessionSummaryKey(sessionID))
+}
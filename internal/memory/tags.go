@@ -0,0 +1,235 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"reflect"
+	"strings"
+)
+
+// normalizeTag trims and lowercases a tag so "Work" and "work " match
+func normalizeTag(tag string) string {
+	return strings.ToLower(strings.TrimSpace(tag))
+}
+
+// AddTag tags a session. Adding an existing tag is a no-op.
+func (m *Memory) AddTag(sessionID, tag string) error {
+	tag = normalizeTag(tag)
+	if tag == "" {
+		return fmt.Errorf("tag is required")
+	}
+
+	_, err := m.conn.Exec(`
+		INSERT OR IGNORE INTO tags (session_id, tag) VALUES (?, ?)
+	`, sessionID, tag)
+	if err != nil {
+		return fmt.Errorf("failed to add tag: %w", err)
+	}
+	return nil
+}
+
+// RemoveTags removes tags from a session
+func (m *Memory) RemoveTags(sessionID string, tags []string) error {
+	if len(tags) == 0 {
+		return nil
+	}
+
+	args := []interface{}{sessionID}
+	for _, tag := range tags {
+		args = append(args, normalizeTag(tag))
+	}
+
+	_, err := m.conn.Exec(`
+		DELETE FROM tags WHERE session_id = ? AND tag IN (`+placeholders(len(tags))+`)
+	`, args...)
+	if err != nil {
+		return fmt.Errorf("failed to remove tags: %w", err)
+	}
+	return nil
+}
+
+// GetTags returns the sorted tags of a session
+func (m *Memory) GetTags(sessionID string) ([]string, error) {
+	rows, err := m.conn.Query(`
+		SELECT tag FROM tags WHERE session_id = ? ORDER BY tag
+	`, sessionID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get tags: %w", err)
+	}
+	defer rows.Close()
+
+	tags := []string{}
+	for rows.Next() {
+		var tag string
+		if err := rows.Scan(&tag); err != nil {
+			return nil, fmt.Errorf("failed to scan tag: %w", err)
+		}
+		tags = append(tags, tag)
+	}
+	return tags, rows.Err()
+}
+
+// SearchByTags returns the sessions tagged with any of the tags, or with
+// all of them if matchAll is set, most recently tagged first. Sessions
+// that have tags but no sessions row are returned with only ID and Tags
+// set.
+func (m *Memory) SearchByTags(tags []string, matchAll bool, limit int) ([]Session, error) {
+	args := []interface{}{}
+	seen := make(map[string]bool)
+	for _, tag := range tags {
+		tag = normalizeTag(tag)
+		if tag != "" && !seen[tag] {
+			seen[tag] = true
+			args = append(args, tag)
+		}
+	}
+	if len(args) == 0 {
+		return nil, fmt.Errorf("at least one tag is required")
+	}
+
+	query := `
+		SELECT session_id FROM tags
+		WHERE tag IN (` + placeholders(len(args)) + `)
+		GROUP BY session_id`
+	if matchAll {
+		query += ` HAVING COUNT(DISTINCT tag) = ?`
+		args = append(args, len(args))
+	}
+	query += ` ORDER BY MAX(id) DESC`
+	if limit > 0 {
+		query += ` LIMIT ?`
+		args = append(args, limit)
+	}
+
+	rows, err := m.conn.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to search tags: %w", err)
+	}
+
+	var sessionIDs []string
+	for rows.Next() {
+		var sessionID string
+		if err := rows.Scan(&sessionID); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to scan session: %w", err)
+		}
+		sessionIDs = append(sessionIDs, sessionID)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to search tags: %w", err)
+	}
+
+	sessions := make([]Session, 0, len(sessionIDs))
+	for _, sessionID := range sessionIDs {
+		session, err := m.GetSession(sessionID)
+		if err != nil {
+			return nil, err
+		}
+		if session == nil {
+			tags, err := m.GetTags(sessionID)
+			if err != nil {
+				return nil, err
+			}
+			session = &Session{ID: sessionID, Tags: tags}
+		}
+		sessions = append(sessions, *session)
+	}
+
+	return sessions, nil
+}
+
+// placeholders returns n comma-separated SQL placeholders
+func placeholders(n int) string {
+	return strings.TrimSuffix(strings.Repeat("?,", n), ",")
+}
+
+// TestSessionTags creates sessions with overlapping tags and checks which
+// of them match any or all of a set of tags
+func TestSessionTags() error {
+	log.Println("Testing session tags...")
+
+	dbPath := "test_session_tags.db"
+	defer os.Remove(dbPath)
+
+	mem, err := NewMemory(dbPath, 100)
+	if err != nil {
+		return err
+	}
+	defer mem.Close()
+
+	sessionTags := map[string][]string{
+		"alpha": {"work", "research"},
+		"beta":  {"Work ", "personal"},
+		"gamma": {"research", "work", "urgent"},
+	}
+	for _, sessionID := range []string{"alpha", "beta", "gamma"} {
+		if err := mem.CreateSession(sessionID, sessionID, "test", "user"); err != nil {
+			return err
+		}
+		for _, tag := range sessionTags[sessionID] {
+			if err := mem.AddTag(sessionID, tag); err != nil {
+				return err
+			}
+		}
+	}
+	if err := mem.AddTag("alpha", "work"); err != nil {
+		return fmt.Errorf("expected adding an existing tag to be a no-op: %w", err)
+	}
+
+	sessionIDs := func(tags []string, matchAll bool) ([]string, error) {
+		sessions, err := mem.SearchByTags(tags, matchAll, 0)
+		if err != nil {
+			return nil, err
+		}
+		ids := []string{}
+		for _, session := range sessions {
+			ids = append(ids, session.ID)
+		}
+		return ids, nil
+	}
+
+	tests := []struct {
+		tags     []string
+		matchAll bool
+		want     []string
+	}{
+		{[]string{"work", "research"}, true, []string{"gamma", "alpha"}},
+		{[]string{"work", "research", "urgent"}, true, []string{"gamma"}},
+		{[]string{"WORK", "personal"}, true, []string{"beta"}},
+		{[]string{"research", "personal"}, true, []string{}},
+		{[]string{"research", "personal"}, false, []string{"gamma", "beta", "alpha"}},
+		{[]string{"work", "work"}, true, []string{"gamma", "beta", "alpha"}},
+	}
+	for _, test := range tests {
+		ids, err := sessionIDs(test.tags, test.matchAll)
+		if err != nil {
+			return err
+		}
+		if !reflect.DeepEqual(ids, test.want) {
+			return fmt.Errorf("tags %v (match all %v): expected %v, got %v", test.tags, test.matchAll, test.want, ids)
+		}
+	}
+	log.Printf("✓ %d tag searches returned the matching sessions", len(tests))
+
+	session, err := mem.GetSession("beta")
+	if err != nil || session == nil || !reflect.DeepEqual(session.Tags, []string{"personal", "work"}) {
+		return fmt.Errorf("expected beta to have tags [personal work], got %v (%v)", session, err)
+	}
+	log.Println("✓ Session tags normalized and returned with the session")
+
+	if err := mem.RemoveTags("gamma", []string{"Research"}); err != nil {
+		return err
+	}
+	ids, err := sessionIDs([]string{"work", "research"}, true)
+	if err != nil || !reflect.DeepEqual(ids, []string{"alpha"}) {
+		return fmt.Errorf("expected only alpha after removing a tag from gamma, got %v (%v)", ids, err)
+	}
+	if _, err := mem.SearchByTags([]string{" "}, true, 0); err == nil {
+		return fmt.Errorf("expected a search without tags to be rejected")
+	}
+	log.Println("✓ Removed tag no longer matches")
+
+	return nil
+}
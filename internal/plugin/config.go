@@ -0,0 +1,206 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+)
+
+// Config field types supported by ConfigField
+const (
+	ConfigTypeString = "string"
+	ConfigTypeInt    = "int"
+	ConfigTypeFloat  = "float"
+	ConfigTypeBool   = "bool"
+)
+
+// ConfigField describes a configuration field of a plugin
+type ConfigField struct {
+	Name     string      `json:"name"`
+	Type     string      `json:"type"`
+	Required bool        `json:"required"`
+	Default  interface{} `json:"default,omitempty"`
+}
+
+// ConfigurablePlugin is implemented by plugins that declare the
+// configuration they accept. Their configuration is validated against the
+// schema before Initialize is called.
+type ConfigurablePlugin interface {
+	Plugin
+
+	// ConfigSchema returns the configuration fields of the plugin
+	ConfigSchema() []ConfigField
+}
+
+// ConfigValidationError describes an invalid plugin configuration field
+type ConfigValidationError struct {
+	Field   string
+	Message string
+}
+
+func (e ConfigValidationError) Error() string {
+	return fmt.Sprintf("config field %s: %s", e.Field, e.Message)
+}
+
+// RegisterWithConfig validates config against the plugin's schema,
+// initializes the plugin with it and registers it under name. Validation
+// failures are returned as ConfigValidationError values.
+func (pm *PluginManager) RegisterWithConfig(name string, plugin Plugin, config map[string]interface{}) error {
+	pm.mu.Lock()
+	defer pm.mu.Unlock()
+
+	if _, exists := pm.plugins[name]; exists {
+		return fmt.Errorf("plugin already registered: %s", name)
+	}
+
+	if err := pm.checkAPIVersion(name, plugin); err != nil {
+		return err
+	}
+
+	if err := initializePlugin(plugin, config); err != nil {
+		return err
+	}
+
+	pm.plugins[name] = plugin
+
+	log.Printf("Plugin registered: %s v%s", plugin.Name(), plugin.Version())
+
+	return nil
+}
+
+// GetConfigSchema returns the configuration schema of a plugin. Plugins
+// that do not implement ConfigurablePlugin have an empty schema.
+func (pm *PluginManager) GetConfigSchema(name string) ([]ConfigField, error) {
+	pm.mu.RLock()
+	plugin, exists := pm.plugins[name]
+	pm.mu.RUnlock()
+
+	if !exists {
+		return nil, fmt.Errorf("%w: %s", ErrPluginNotFound, name)
+	}
+
+	configurable, ok := plugin.(ConfigurablePlugin)
+	if !ok {
+		return []ConfigField{}, nil
+	}
+	return configurable.ConfigSchema(), nil
+}
+
+// ValidateConfig checks config against a schema. It returns a copy of
+// config with values coerced to their declared types and defaults added
+// for missing optional fields.
+func ValidateConfig(schema []ConfigField, config map[string]interface{}) (map[string]interface{}, []ConfigValidationError) {
+	validated := make(map[string]interface{}, len(config))
+	for key, value := range config {
+		validated[key] = value
+	}
+
+	var errs []ConfigValidationError
+	for _, field := range schema {
+		value, ok := validated[field.Name]
+		if !ok || value == nil {
+			if field.Required {
+				errs = append(errs, ConfigValidationError{Field: field.Name, Message: "is required"})
+			} else if field.Default != nil {
+				validated[field.Name] = field.Default
+			}
+			continue
+		}
+
+		coerced, err := coerceConfigValue(field.Type, value)
+		if err != nil {
+			errs = append(errs, ConfigValidationError{Field: field.Name, Message: err.Error()})
+			continue
+		}
+		validated[field.Name] = coerced
+	}
+
+	return validated, errs
+}
+
+// initializePlugin validates config if the plugin is configurable and
+// initializes the plugin
+func initializePlugin(plugin Plugin, config map[string]interface{}) error {
+	if configurable, ok := plugin.(ConfigurablePlugin); ok {
+		validated, errs := ValidateConfig(configurable.ConfigSchema(), config)
+		if len(errs) > 0 {
+			joined := make([]error, len(errs))
+			for i, err := range errs {
+				joined[i] = err
+			}
+			return fmt.Errorf("invalid config for plugin %s: %w", plugin.Name(), errors.Join(joined...))
+		}
+		config = validated
+	}
+
+	if err := plugin.Initialize(config); err != nil {
+		return fmt.Errorf("failed to initialize plugin: %w", err)
+	}
+	return nil
+}
+
+// coerceConfigValue converts a config value to a schema type. Strings are
+// parsed for numeric and boolean types, and whole JSON numbers are accepted
+// for int fields.
+func coerceConfigValue(fieldType string, value interface{}) (interface{}, error) {
+	switch fieldType {
+	case ConfigTypeString, "":
+		if s, ok := value.(string); ok {
+			return s, nil
+		}
+		return nil, fmt.Errorf("expected string, got %T", value)
+
+	case ConfigTypeInt:
+		switch v := value.(type) {
+		case int:
+			return v, nil
+		case int64:
+			return int(v), nil
+		case float64:
+			if v != float64(int(v)) {
+				return nil, fmt.Errorf("expected int, got %v", v)
+			}
+			return int(v), nil
+		case string:
+			n, err := strconv.Atoi(strings.TrimSpace(v))
+			if err != nil {
+				return nil, fmt.Errorf("expected int, got %q", v)
+			}
+			return n, nil
+		}
+		return nil, fmt.Errorf("expected int, got %T", value)
+
+	case ConfigTypeFloat:
+		switch v := value.(type) {
+		case float64:
+			return v, nil
+		case int:
+			return float64(v), nil
+		case string:
+			f, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+			if err != nil {
+				return nil, fmt.Errorf("expected float, got %q", v)
+			}
+			return f, nil
+		}
+		return nil, fmt.Errorf("expected float, got %T", value)
+
+	case ConfigTypeBool:
+		switch v := value.(type) {
+		case bool:
+			return v, nil
+		case string:
+			b, err := strconv.ParseBool(strings.TrimSpace(v))
+			if err != nil {
+				return nil, fmt.Errorf("expected bool, got %q", v)
+			}
+			return b, nil
+		}
+		return nil, fmt.Errorf("expected bool, got %T", value)
+
+	default:
+		return nil, fmt.Errorf("unknown field type %q", fieldType)
+	}
+}
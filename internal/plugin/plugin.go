@@ -2,6 +2,7 @@ package main
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"os"
@@ -10,6 +11,13 @@ import (
 	"sync"
 )
 
+// ErrPluginNotFound is returned for unknown plugin names
+var ErrPluginNotFound = errors.New("plugin not found")
+
+// CurrentAPIVersion is the version of the plugin API, as a semantic
+// version. Plugins built for another major version are rejected.
+const CurrentAPIVersion = "1.0.0"
+
 // Plugin represents a QuickBot plugin
 type Plugin interface {
 	// Name returns the plugin name
@@ -31,6 +39,15 @@ type Plugin interface {
 	Shutdown() error
 }
 
+// VersionedPlugin is implemented by plugins that report the plugin API
+// version they were built for
+type VersionedPlugin interface {
+	Plugin
+
+	// QuickBotAPIVersion returns the plugin API version, e.g. "1.0.0"
+	QuickBotAPIVersion() string
+}
+
 // PluginMetadata stores plugin metadata
 type PluginMetadata struct {
 	Name        string            `json:"name"`
@@ -39,6 +56,11 @@ type PluginMetadata struct {
 	Author      string            `json:"author"`
 	Enabled     bool              `json:"enabled"`
 	Config      map[string]string `json:"config"`
+
+	// QuickBotAPIVersion is the plugin API version the plugin reported,
+	// empty if it does not implement VersionedPlugin
+	QuickBotAPIVersion string `json:"quickbot_api_version,omitempty"`
+	Compatible         bool   `json:"compatible"`
 }
 
 // PluginManager manages plugins
@@ -81,10 +103,14 @@ func (pm *PluginManager) LoadPlugin(filePath, pluginName string) error {
 		return fmt.Errorf("unexpected type from module symbol")
 	}
 
-	// Initialize the plugin
-	err = pluginInstance.Initialize(nil)
-	if err != nil {
-		return fmt.Errorf("failed to initialize plugin: %w", err)
+	// Reject plugins built for another plugin API
+	if err := pm.checkAPIVersion(pluginName, pluginInstance); err != nil {
+		return err
+	}
+
+	// Initialize the plugin, applying schema defaults if it has a schema
+	if err := initializePlugin(pluginInstance, nil); err != nil {
+		return err
 	}
 
 	// Register the plugin
@@ -102,7 +128,7 @@ func (pm *PluginManager) UnloadPlugin(name string) error {
 
 	plugin, exists := pm.plugins[name]
 	if !exists {
-		return fmt.Errorf("plugin not found: %s", name)
+		return fmt.Errorf("%w: %s", ErrPluginNotFound, name)
 	}
 
 	// Shutdown the plugin
@@ -127,7 +153,7 @@ func (pm *PluginManager) ExecutePlugin(name string, args map[string]interface{})
 	pm.mu.RUnlock()
 
 	if !exists {
-		return nil, fmt.Errorf("plugin not found: %s", name)
+		return nil, fmt.Errorf("%w: %s", ErrPluginNotFound, name)
 	}
 
 	return plugin.Execute(args)
@@ -152,7 +178,7 @@ func (pm *PluginManager) GetPluginInfo(name string) (Plugin, error) {
 
 	plugin, exists := pm.plugins[name]
 	if !exists {
-		return nil, fmt.Errorf("plugin not found: %s", name)
+		return nil, fmt.Errorf("%w: %s", ErrPluginNotFound, name)
 	}
 
 	return plugin, nil
@@ -211,8 +237,10 @@ func (pm *PluginManager) Shutdown() error {
 
 // Example: A simple plugin
 type EchoPlugin struct {
-	name    string
-	version string
+	name       string
+	version    string
+	apiVersion string
+	maxLength  int
 }
 
 func (p *EchoPlugin) Name() string {
@@ -223,11 +251,22 @@ func (p *EchoPlugin) Version() string {
 	return p.version
 }
 
+func (p *EchoPlugin) QuickBotAPIVersion() string {
+	return p.apiVersion
+}
+
 func (p *EchoPlugin) Description() string {
 	return "Echoes back the input message"
 }
 
+func (p *EchoPlugin) ConfigSchema() []ConfigField {
+	return []ConfigField{
+		{Name: "max_length", Type: ConfigTypeInt, Required: true},
+	}
+}
+
 func (p *EchoPlugin) Initialize(config map[string]interface{}) error {
+	p.maxLength, _ = config["max_length"].(int)
 	return nil
 }
 
@@ -236,6 +275,9 @@ func (p *EchoPlugin) Execute(args map[string]interface{}) (interface{}, error) {
 	if !ok {
 		return nil, fmt.Errorf("message is required")
 	}
+	if p.maxLength > 0 && len(message) > p.maxLength {
+		message = message[:p.maxLength]
+	}
 	return fmt.Sprintf("Echo: %s", message), nil
 }
 
@@ -246,8 +288,9 @@ func (p *EchoPlugin) Shutdown() error {
 // NewEchoPlugin creates a new echo plugin instance
 func NewEchoPlugin() Plugin {
 	return &EchoPlugin{
-		name:    "echo",
-		version: "1.0.0",
+		name:       "echo",
+		version:    "1.0.0",
+		apiVersion: CurrentAPIVersion,
 	}
 }
 
@@ -270,6 +313,46 @@ func TestPluginManager() {
 		log.Printf("✓ Plugin executed: %s", result)
 	}
 
+	// Test config validation
+	err = pm.RegisterWithConfig("echo-limited", NewEchoPlugin(), nil)
+	var validationErr ConfigValidationError
+	if errors.As(err, &validationErr) && validationErr.Field == "max_length" {
+		log.Printf("✓ Missing config rejected: %v", err)
+	} else {
+		log.Printf("Expected max_length validation error, got: %v", err)
+	}
+
+	err = pm.RegisterWithConfig("echo-limited", NewEchoPlugin(), map[string]interface{}{"max_length": "4"})
+	if err != nil {
+		log.Printf("Failed to register plugin with config: %v", err)
+	} else {
+		result, _ := pm.ExecutePlugin("echo-limited", map[string]interface{}{"message": "Hello Test"})
+		log.Printf("✓ Plugin registered with coerced config: %s", result)
+	}
+
+	// Test API version compatibility
+	newer := NewEchoPlugin().(*EchoPlugin)
+	newer.apiVersion = "1.3.0"
+	if err := pm.RegisterWithConfig("echo-newer", newer, map[string]interface{}{"max_length": 10}); err != nil {
+		log.Printf("Failed to register plugin with newer minor API version: %v", err)
+	} else {
+		log.Println("✓ Plugin with newer minor API version registered")
+	}
+
+	incompatible := NewEchoPlugin().(*EchoPlugin)
+	incompatible.apiVersion = "2.0.0"
+	err = pm.RegisterWithConfig("echo-v2", incompatible, map[string]interface{}{"max_length": 10})
+	if errors.Is(err, ErrIncompatiblePlugin) {
+		log.Printf("✓ Incompatible plugin rejected: %v", err)
+	} else {
+		log.Printf("Expected incompatible plugin error, got: %v", err)
+	}
+	for _, status := range pm.ListPluginStatus() {
+		if status.Name == "echo-v2" && status.Compatible {
+			log.Printf("Expected echo-v2 to be listed as incompatible: %+v", status)
+		}
+	}
+
 	// Test plugin listing
 	plugins := pm.ListPlugins()
 	log.Printf("✓ Loaded plugins: %v", plugins)
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ErrIncompatiblePlugin is returned for plugins built for another major
+// version of the plugin API
+var ErrIncompatiblePlugin = errors.New("incompatible plugin API version")
+
+// PluginStatus describes a loaded plugin, or one rejected for its plugin
+// API version
+type PluginStatus struct {
+	Name       string `json:"name"`
+	Version    string `json:"version"`
+	APIVersion string `json:"api_version"`
+	Compatible bool   `json:"compatible"`
+}
+
+// checkAPIVersion compares the plugin API version a plugin reports with
+// CurrentAPIVersion and records it in the plugin's metadata. Plugins for
+// another major version are rejected; a different minor version is only
+// logged. Plugins that do not implement VersionedPlugin are accepted. It
+// must be called with mu held.
+func (pm *PluginManager) checkAPIVersion(name string, plugin Plugin) error {
+	metadata := PluginMetadata{
+		Name:        name,
+		Version:     plugin.Version(),
+		Description: plugin.Description(),
+		Compatible:  true,
+	}
+
+	var err error
+	if versioned, ok := plugin.(VersionedPlugin); ok {
+		metadata.QuickBotAPIVersion = versioned.QuickBotAPIVersion()
+		err = compareAPIVersion(name, metadata.QuickBotAPIVersion)
+		metadata.Compatible = err == nil
+	}
+
+	pm.metadata[name] = metadata
+	return err
+}
+
+// compareAPIVersion checks a plugin API version against CurrentAPIVersion
+func compareAPIVersion(name, version string) error {
+	major, minor, err := parseAPIVersion(version)
+	if err != nil {
+		return fmt.Errorf("%w: plugin %s: %v", ErrIncompatiblePlugin, name, err)
+	}
+	currentMajor, currentMinor, _ := parseAPIVersion(CurrentAPIVersion)
+
+	if major != currentMajor {
+		return fmt.Errorf("%w: plugin %s is built for API %s, QuickBot provides %s",
+			ErrIncompatiblePlugin, name, version, CurrentAPIVersion)
+	}
+	if minor != currentMinor {
+		log.Printf("Warning: plugin %s is built for API %s, QuickBot provides %s", name, version, CurrentAPIVersion)
+	}
+	return nil
+}
+
+// parseAPIVersion returns the major and minor parts of a semantic version
+// such as "1.2.0" or "v1.2"
+func parseAPIVersion(version string) (major, minor int, err error) {
+	parts := strings.SplitN(strings.TrimPrefix(version, "v"), ".", 3)
+	if len(parts) < 2 {
+		return 0, 0, fmt.Errorf("invalid API version %q", version)
+	}
+
+	major, err = strconv.Atoi(parts[0])
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid API version %q", version)
+	}
+	minor, err = strconv.Atoi(parts[1])
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid API version %q", version)
+	}
+	return major, minor, nil
+}
+
+// ListPluginStatus returns the loaded plugins and the plugins rejected for
+// their plugin API version, sorted by name
+func (pm *PluginManager) ListPluginStatus() []PluginStatus {
+	pm.mu.RLock()
+	defer pm.mu.RUnlock()
+
+	statuses := []PluginStatus{}
+	for name, metadata := range pm.metadata {
+		if _, loaded := pm.plugins[name]; !loaded && metadata.Compatible {
+			continue
+		}
+		statuses = append(statuses, PluginStatus{
+			Name:       name,
+			Version:    metadata.Version,
+			APIVersion: metadata.QuickBotAPIVersion,
+			Compatible: metadata.Compatible,
+		})
+	}
+	for name, plugin := range pm.plugins {
+		if _, ok := pm.metadata[name]; !ok {
+			statuses = append(statuses, PluginStatus{Name: name, Version: plugin.Version(), Compatible: true})
+		}
+	}
+
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].Name < statuses[j].Name })
+	return statuses
+}
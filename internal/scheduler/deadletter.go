@@ -0,0 +1,200 @@
+package scheduler
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+)
+
+// DeadLetterTask is a task run whose handler returned an error, kept so
+// it can be inspected and retried
+type DeadLetterTask struct {
+	ID                   string                 `json:"id"`
+	OriginalTaskID       string                 `json:"original_task_id"`
+	Name                 string                 `json:"name"`
+	SessionID            string                 `json:"session_id"`
+	NotificationPlatform string                 `json:"notification_platform,omitempty"`
+	FailureTime          time.Time              `json:"failure_time"`
+	ErrorMessage         string                 `json:"error_message"`
+	Payload              map[string]interface{} `json:"payload"`
+}
+
+// initDeadLetterTable creates the dead letter table
+func (s *Scheduler) initDeadLetterTable() error {
+	_, err := s.conn.Exec(`
+		CREATE TABLE IF NOT EXISTS dead_letter_tasks (
+			id TEXT PRIMARY KEY,
+			original_task_id TEXT NOT NULL,
+			name TEXT NOT NULL,
+			session_id TEXT NOT NULL,
+			notification_platform TEXT,
+			failure_time DATETIME NOT NULL,
+			error_message TEXT NOT NULL,
+			payload TEXT
+		)
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to create dead_letter_tasks table: %w", err)
+	}
+	return nil
+}
+
+// deadLetter stores a task whose handler failed
+func (s *Scheduler) deadLetter(task *Task, taskErr error) error {
+	payloadJSON, err := json.Marshal(task.Payload)
+	if err != nil {
+		return fmt.Errorf("failed to marshal payload: %w", err)
+	}
+
+	_, err = s.conn.Exec(`
+		INSERT INTO dead_letter_tasks (id, original_task_id, name, session_id, notification_platform, failure_time, error_message, payload)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+	`, fmt.Sprintf("%d", time.Now().UnixNano()), task.ID, task.Name, task.SessionID, task.NotificationPlatform,
+		time.Now().UTC(), taskErr.Error(), string(payloadJSON))
+	if err != nil {
+		return fmt.Errorf("failed to insert dead letter task: %w", err)
+	}
+	return nil
+}
+
+// GetDeadLetterTasks returns the failed tasks, most recent failure first
+func (s *Scheduler) GetDeadLetterTasks() ([]DeadLetterTask, error) {
+	rows, err := s.conn.Query(`
+		SELECT id, original_task_id, name, session_id, notification_platform, failure_time, error_message, payload
+		FROM dead_letter_tasks
+		ORDER BY failure_time DESC, id DESC
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query dead letter tasks: %w", err)
+	}
+	defer rows.Close()
+
+	tasks := []DeadLetterTask{}
+	for rows.Next() {
+		task, err := scanDeadLetterTask(rows)
+		if err != nil {
+			return nil, err
+		}
+		tasks = append(tasks, *task)
+	}
+	return tasks, rows.Err()
+}
+
+// scanDeadLetterTask scans a dead_letter_tasks row
+func scanDeadLetterTask(row interface{ Scan(...interface{}) error }) (*DeadLetterTask, error) {
+	var task DeadLetterTask
+	var payload, notificationPlatform sql.NullString
+
+	err := row.Scan(&task.ID, &task.OriginalTaskID, &task.Name, &task.SessionID, &notificationPlatform,
+		&task.FailureTime, &task.ErrorMessage, &payload)
+	if err != nil {
+		return nil, err
+	}
+	task.NotificationPlatform = notificationPlatform.String
+
+	if payload.Valid {
+		if err := json.Unmarshal([]byte(payload.String), &task.Payload); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal payload: %w", err)
+		}
+	}
+	return &task, nil
+}
+
+// RetryDeadLetterTask enqueues the payload of a failed task as a new task
+// running right away, and removes it from the dead letter table
+func (s *Scheduler) RetryDeadLetterTask(id string) error {
+	task, err := scanDeadLetterTask(s.conn.QueryRow(`
+		SELECT id, original_task_id, name, session_id, notification_platform, failure_time, error_message, payload
+		FROM dead_letter_tasks WHERE id = ?
+	`, id))
+	if err == sql.ErrNoRows {
+		return fmt.Errorf("dead letter task not found: %s", id)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to get dead letter task: %w", err)
+	}
+
+	// The cron schedule has a resolution of one second
+	taskID, err := s.AddTask(task.Name, task.SessionID, task.Payload, time.Now().Add(time.Second), "", task.NotificationPlatform, nil)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.conn.Exec(`DELETE FROM dead_letter_tasks WHERE id = ?`, id)
+	if err != nil {
+		return fmt.Errorf("failed to delete dead letter task: %w", err)
+	}
+
+	log.Printf("Dead letter task %s retried as task %s", id, taskID)
+	return nil
+}
+
+// TestDeadLetterTasks checks that failed tasks are dead-lettered and can
+// be retried
+func TestDeadLetterTasks() error {
+	log.Println("Testing dead letter tasks...")
+
+	dbPath, cleanup, err := testDBPath("test_dead_letter.db")
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+
+	scheduler, err := NewScheduler(dbPath)
+	if err != nil {
+		return fmt.Errorf("failed to create scheduler: %w", err)
+	}
+	defer scheduler.Stop()
+
+	fail := true
+	scheduler.SetTaskHandler(func(task *Task) (string, error) {
+		if fail {
+			return "", fmt.Errorf("mail server unavailable")
+		}
+		return "sent", nil
+	})
+
+	payload := map[string]interface{}{"type": "email", "message": "weekly report"}
+	id, err := scheduler.AddTask("send report", "telegram:123", payload, time.Now().Add(time.Hour), "", "telegram", nil)
+	if err != nil {
+		return err
+	}
+	scheduler.executeTask(id)
+
+	dead, err := scheduler.GetDeadLetterTasks()
+	if err != nil {
+		return err
+	}
+	if len(dead) != 1 {
+		return fmt.Errorf("expected 1 dead letter task, got %d", len(dead))
+	}
+	if d := dead[0]; d.OriginalTaskID != id || d.ErrorMessage != "mail server unavailable" ||
+		d.Payload["message"] != "weekly report" || d.NotificationPlatform != "telegram" || d.FailureTime.IsZero() {
+		return fmt.Errorf("unexpected dead letter task: %+v", d)
+	}
+	log.Println("✓ Failed task dead-lettered")
+
+	fail = false
+	if err := scheduler.RetryDeadLetterTask(dead[0].ID); err != nil {
+		return err
+	}
+	if dead, err := scheduler.GetDeadLetterTasks(); err != nil || len(dead) != 0 {
+		return fmt.Errorf("expected retried task to leave the dead letter table, got %d (%v)", len(dead), err)
+	}
+	tasks, err := scheduler.GetAllTasks()
+	if err != nil || len(tasks) != 1 || tasks[0].Name != "send report" || tasks[0].Payload["message"] != "weekly report" {
+		return fmt.Errorf("expected the payload to be enqueued again, got %+v (%v)", tasks, err)
+	}
+	scheduler.executeTask(tasks[0].ID)
+	if dead, err := scheduler.GetDeadLetterTasks(); err != nil || len(dead) != 0 {
+		return fmt.Errorf("expected the successful retry not to be dead-lettered, got %d (%v)", len(dead), err)
+	}
+	if err := scheduler.RetryDeadLetterTask("missing"); err == nil {
+		return fmt.Errorf("expected retrying an unknown dead letter task to fail")
+	}
+	log.Println("✓ Dead letter task retried")
+
+	return nil
+}
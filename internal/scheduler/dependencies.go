@@ -0,0 +1,262 @@
+package scheduler
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Task history statuses
+const (
+	HistoryCompleted = "completed"
+	HistoryFailed    = "failed"
+)
+
+// taskStatusWaiting marks a task whose run time passed before its
+// dependencies completed; it runs as soon as they have
+const taskStatusWaiting = "waiting"
+
+// initDependencyTables creates the task dependency and history tables
+func (s *Scheduler) initDependencyTables() error {
+	_, err := s.conn.Exec(`
+		CREATE TABLE IF NOT EXISTS task_dependencies (
+			task_id TEXT NOT NULL,
+			depends_on_task_id TEXT NOT NULL,
+			PRIMARY KEY (task_id, depends_on_task_id)
+		)
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to create task_dependencies table: %w", err)
+	}
+
+	_, err = s.conn.Exec(`
+		CREATE TABLE IF NOT EXISTS task_history (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			task_id TEXT NOT NULL,
+			name TEXT NOT NULL,
+			status TEXT NOT NULL,
+			result TEXT,
+			error TEXT,
+			executed_at DATETIME DEFAULT CURRENT_TIMESTAMP
+		)
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to create task_history table: %w", err)
+	}
+
+	_, err = s.conn.Exec(`
+		CREATE INDEX IF NOT EXISTS idx_task_history_task_id
+		ON task_history(task_id, status)
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to create task history index: %w", err)
+	}
+
+	return nil
+}
+
+// GetTaskGraph returns the dependency adjacency list: each task ID mapped
+// to the IDs of the tasks it depends on. Completed tasks are removed from
+// the scheduler, so they only appear as dependencies.
+func (s *Scheduler) GetTaskGraph() map[string][]string {
+	graph, err := s.loadDependencies()
+	if err != nil {
+		log.Printf("Failed to load task graph: %v", err)
+		return nil
+	}
+	return graph
+}
+
+// ValidateDependencies returns an error describing the first dependency
+// cycle found between tasks, if any
+func (s *Scheduler) ValidateDependencies() error {
+	graph, err := s.loadDependencies()
+	if err != nil {
+		return err
+	}
+
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+	state := make(map[string]int)
+	var path []string
+
+	var visit func(id string) error
+	visit = func(id string) error {
+		switch state[id] {
+		case visiting:
+			start := 0
+			for i, p := range path {
+				if p == id {
+					start = i
+				}
+			}
+			cycle := append(append([]string(nil), path[start:]...), id)
+			return fmt.Errorf("task dependency cycle: %s", strings.Join(cycle, " -> "))
+		case visited:
+			return nil
+		}
+
+		state[id] = visiting
+		path = append(path, id)
+		for _, dep := range graph[id] {
+			if err := visit(dep); err != nil {
+				return err
+			}
+		}
+		path = path[:len(path)-1]
+		state[id] = visited
+		return nil
+	}
+
+	// Visit in a fixed order so the reported cycle is stable
+	ids := make([]string, 0, len(graph))
+	for id := range graph {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	for _, id := range ids {
+		if err := visit(id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// loadDependencies returns the dependencies of every task that has any
+func (s *Scheduler) loadDependencies() (map[string][]string, error) {
+	rows, err := s.conn.Query(`
+		SELECT task_id, depends_on_task_id FROM task_dependencies
+		ORDER BY task_id, depends_on_task_id
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query task dependencies: %w", err)
+	}
+	defer rows.Close()
+
+	graph := make(map[string][]string)
+	for rows.Next() {
+		var taskID, dependsOn string
+		if err := rows.Scan(&taskID, &dependsOn); err != nil {
+			return nil, fmt.Errorf("failed to scan task dependency: %w", err)
+		}
+		graph[taskID] = append(graph[taskID], dependsOn)
+	}
+	return graph, rows.Err()
+}
+
+// insertDependencies records the dependencies of a new task. Each
+// dependency must be a pending task or have run before.
+func insertDependencies(tx *sql.Tx, taskID string, deps []string) error {
+	for _, dep := range deps {
+		var exists bool
+		err := tx.QueryRow(`
+			SELECT EXISTS(SELECT 1 FROM tasks WHERE id = ?)
+			    OR EXISTS(SELECT 1 FROM task_history WHERE task_id = ?)
+		`, dep, dep).Scan(&exists)
+		if err != nil {
+			return fmt.Errorf("failed to check dependency %s: %w", dep, err)
+		}
+		if !exists {
+			return fmt.Errorf("unknown dependency task: %s", dep)
+		}
+
+		_, err = tx.Exec(`
+			INSERT OR IGNORE INTO task_dependencies (task_id, depends_on_task_id)
+			VALUES (?, ?)
+		`, taskID, dep)
+		if err != nil {
+			return fmt.Errorf("failed to insert task dependency: %w", err)
+		}
+	}
+	return nil
+}
+
+// dependenciesCompleted reports whether every dependency of a task has a
+// completed run in the task history
+func (s *Scheduler) dependenciesCompleted(task *Task) (bool, error) {
+	for _, dep := range task.DependsOn {
+		var completed bool
+		err := s.conn.QueryRow(`
+			SELECT EXISTS(SELECT 1 FROM task_history WHERE task_id = ? AND status = ?)
+		`, dep, HistoryCompleted).Scan(&completed)
+		if err != nil {
+			return false, fmt.Errorf("failed to check dependency %s: %w", dep, err)
+		}
+		if !completed {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// recordHistory stores the outcome and duration of a task run
+func (s *Scheduler) recordHistory(task *Task, result string, taskErr error, duration time.Duration) error {
+	status, errText := HistoryCompleted, ""
+	if taskErr != nil {
+		status, errText = HistoryFailed, taskErr.Error()
+	}
+
+	_, err := s.conn.Exec(`
+		INSERT INTO task_history (task_id, name, status, result, error, duration_ms)
+		VALUES (?, ?, ?, ?, ?, ?)
+	`, task.ID, task.Name, status, result, errText, duration.Milliseconds())
+	if err != nil {
+		return fmt.Errorf("failed to record task history: %w", err)
+	}
+	return nil
+}
+
+// GetHistoryCounts returns the number of task runs recorded with each
+// history status, HistoryCompleted and HistoryFailed
+func (s *Scheduler) GetHistoryCounts() (map[string]int, error) {
+	rows, err := s.conn.Query(`SELECT status, COUNT(*) FROM task_history GROUP BY status`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count task history: %w", err)
+	}
+	defer rows.Close()
+
+	counts := map[string]int{HistoryCompleted: 0, HistoryFailed: 0}
+	for rows.Next() {
+		var status string
+		var count int
+		if err := rows.Scan(&status, &count); err != nil {
+			return nil, fmt.Errorf("failed to scan task history count: %w", err)
+		}
+		counts[status] = count
+	}
+	return counts, rows.Err()
+}
+
+// runWaitingDependents runs the waiting tasks that depend on a completed
+// task and have no other unmet dependencies
+func (s *Scheduler) runWaitingDependents(taskID string) {
+	rows, err := s.conn.Query(`
+		SELECT t.id FROM tasks t
+		JOIN task_dependencies d ON d.task_id = t.id
+		WHERE d.depends_on_task_id = ? AND t.status = ?
+	`, taskID, taskStatusWaiting)
+	if err != nil {
+		log.Printf("Failed to query dependents of task %s: %v", taskID, err)
+		return
+	}
+
+	var dependents []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err == nil {
+			dependents = append(dependents, id)
+		}
+	}
+	rows.Close()
+
+	for _, id := range dependents {
+		s.executeTask(id)
+	}
+}
@@ -0,0 +1,214 @@
+package scheduler
+
+import (
+	"fmt"
+	"log"
+	"time"
+)
+
+// Task execution statuses
+const (
+	ExecutionRunning = "running"
+	ExecutionSuccess = "success"
+	ExecutionFailure = "failure"
+)
+
+// defaultHistoryRetentionDays is how long task executions are kept when
+// SetHistoryRetention is not called
+const defaultHistoryRetentionDays = 30
+
+// TaskExecution is one run of a task. FinishedAt is nil while the task
+// is running.
+type TaskExecution struct {
+	ID           int64      `json:"id"`
+	TaskID       string     `json:"task_id"`
+	StartedAt    time.Time  `json:"started_at"`
+	FinishedAt   *time.Time `json:"finished_at,omitempty"`
+	Status       string     `json:"status"`
+	ErrorMessage string     `json:"error_message,omitempty"`
+	Output       string     `json:"output"`
+}
+
+// initExecutionTable creates the task executions table
+func (s *Scheduler) initExecutionTable() error {
+	_, err := s.conn.Exec(`
+		CREATE TABLE IF NOT EXISTS task_executions (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			task_id TEXT NOT NULL,
+			started_at DATETIME NOT NULL,
+			finished_at DATETIME,
+			status TEXT NOT NULL,
+			error_message TEXT,
+			output TEXT
+		)
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to create task_executions table: %w", err)
+	}
+
+	_, err = s.conn.Exec(`
+		CREATE INDEX IF NOT EXISTS idx_task_executions_task_id
+		ON task_executions(task_id, started_at)
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to create task executions index: %w", err)
+	}
+	return nil
+}
+
+// SetHistoryRetention sets how many days task executions are kept. The
+// daily cleanup keeps all executions when days is 0.
+func (s *Scheduler) SetHistoryRetention(days int) {
+	s.historyRetentionDays = days
+}
+
+// startExecution records the start of a task run and returns its ID
+func (s *Scheduler) startExecution(taskID string) (int64, error) {
+	result, err := s.conn.Exec(`
+		INSERT INTO task_executions (task_id, started_at, status)
+		VALUES (?, ?, ?)
+	`, taskID, time.Now().UTC(), ExecutionRunning)
+	if err != nil {
+		return 0, fmt.Errorf("failed to record task execution: %w", err)
+	}
+	return result.LastInsertId()
+}
+
+// finishExecution records the outcome and output of a task run
+func (s *Scheduler) finishExecution(id int64, output string, taskErr error) error {
+	status, errText := ExecutionSuccess, ""
+	if taskErr != nil {
+		status, errText = ExecutionFailure, taskErr.Error()
+	}
+
+	_, err := s.conn.Exec(`
+		UPDATE task_executions SET finished_at = ?, status = ?, error_message = ?, output = ?
+		WHERE id = ?
+	`, time.Now().UTC(), status, errText, output, id)
+	if err != nil {
+		return fmt.Errorf("failed to update task execution: %w", err)
+	}
+	return nil
+}
+
+// GetTaskHistory returns the most recent executions of a task, newest
+// first. A limit of 0 returns all of them.
+func (s *Scheduler) GetTaskHistory(taskID string, limit int) ([]TaskExecution, error) {
+	if limit <= 0 {
+		limit = -1
+	}
+
+	rows, err := s.conn.Query(`
+		SELECT id, task_id, started_at, finished_at, status, COALESCE(error_message, ''), COALESCE(output, '')
+		FROM task_executions WHERE task_id = ?
+		ORDER BY started_at DESC, id DESC
+		LIMIT ?
+	`, taskID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query task history: %w", err)
+	}
+	defer rows.Close()
+
+	history := []TaskExecution{}
+	for rows.Next() {
+		var execution TaskExecution
+		var finishedAt *time.Time
+		err := rows.Scan(&execution.ID, &execution.TaskID, &execution.StartedAt, &finishedAt,
+			&execution.Status, &execution.ErrorMessage, &execution.Output)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan task execution: %w", err)
+		}
+		execution.FinishedAt = finishedAt
+		history = append(history, execution)
+	}
+	return history, rows.Err()
+}
+
+// pruneExecutions deletes the task executions older than the history
+// retention
+func (s *Scheduler) pruneExecutions() {
+	if s.historyRetentionDays <= 0 {
+		return
+	}
+
+	cutoff := time.Now().AddDate(0, 0, -s.historyRetentionDays).UTC()
+	result, err := s.conn.Exec(`DELETE FROM task_executions WHERE started_at < ?`, cutoff)
+	if err != nil {
+		log.Printf("Failed to prune task executions: %v", err)
+		return
+	}
+	if pruned, _ := result.RowsAffected(); pruned > 0 {
+		log.Printf("Pruned %d task executions older than %d days", pruned, s.historyRetentionDays)
+	}
+}
+
+// TestTaskExecutions checks that task runs are recorded with their
+// outcome and that old executions are pruned
+func TestTaskExecutions() error {
+	log.Println("Testing task execution history...")
+
+	dbPath, cleanup, err := testDBPath("test_task_executions.db")
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+
+	scheduler, err := NewScheduler(dbPath)
+	if err != nil {
+		return fmt.Errorf("failed to create scheduler: %w", err)
+	}
+	defer scheduler.Stop()
+
+	fail := true
+	scheduler.SetTaskHandler(func(task *Task) (string, error) {
+		if fail {
+			return "partial output", fmt.Errorf("handler failed")
+		}
+		return "report sent", nil
+	})
+
+	id, err := scheduler.AddRecurringTask("report", "session1", "@daily", nil)
+	if err != nil {
+		return err
+	}
+	scheduler.executeTask(id)
+	fail = false
+	scheduler.executeTask(id)
+
+	history, err := scheduler.GetTaskHistory(id, 0)
+	if err != nil {
+		return err
+	}
+	if len(history) != 2 {
+		return fmt.Errorf("expected 2 executions, got %d", len(history))
+	}
+	latest, first := history[0], history[1]
+	if latest.Status != ExecutionSuccess || latest.Output != "report sent" || latest.ErrorMessage != "" || latest.FinishedAt == nil {
+		return fmt.Errorf("unexpected successful execution: %+v", latest)
+	}
+	if first.Status != ExecutionFailure || first.Output != "partial output" || first.ErrorMessage != "handler failed" {
+		return fmt.Errorf("unexpected failed execution: %+v", first)
+	}
+	if latest.FinishedAt.Before(latest.StartedAt) {
+		return fmt.Errorf("execution finished before it started: %+v", latest)
+	}
+	if limited, err := scheduler.GetTaskHistory(id, 1); err != nil || len(limited) != 1 || limited[0].ID != latest.ID {
+		return fmt.Errorf("expected only the latest execution, got %+v (%v)", limited, err)
+	}
+	log.Println("✓ Task executions recorded newest first")
+
+	_, err = scheduler.conn.Exec(`
+		INSERT INTO task_executions (task_id, started_at, finished_at, status)
+		VALUES (?, ?, ?, ?)
+	`, id, time.Now().AddDate(0, 0, -40).UTC(), time.Now().AddDate(0, 0, -40).UTC(), ExecutionSuccess)
+	if err != nil {
+		return err
+	}
+	scheduler.pruneExecutions()
+	if history, err := scheduler.GetTaskHistory(id, 0); err != nil || len(history) != 2 {
+		return fmt.Errorf("expected the 40 day old execution to be pruned, got %d executions (%v)", len(history), err)
+	}
+	log.Println("✓ Old task executions pruned")
+
+	return nil
+}
@@ -0,0 +1,194 @@
+package scheduler
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+)
+
+// taskLockTTL is how long the lock on a task run is kept. Instances
+// firing the run later than this after it started would run it again.
+const taskLockTTL = 10 * time.Minute
+
+// TaskLocker grants exclusive task runs to one of several scheduler
+// instances sharing a database. A lock is held by owner until it is
+// released or expires.
+type TaskLocker interface {
+	AcquireLock(taskID, owner string, ttl time.Duration) (bool, error)
+	ReleaseLock(taskID, owner string) error
+}
+
+// sqlLocker locks tasks with rows in a task_locks table, which only one
+// instance can insert for a task
+type sqlLocker struct {
+	conn *sql.DB
+}
+
+// newSQLLocker creates the task_locks table and returns a locker using it
+func newSQLLocker(conn *sql.DB) (*sqlLocker, error) {
+	_, err := conn.Exec(`
+		CREATE TABLE IF NOT EXISTS task_locks (
+			task_id TEXT PRIMARY KEY,
+			owner TEXT NOT NULL,
+			expires_at DATETIME NOT NULL
+		)
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create task_locks table: %w", err)
+	}
+	return &sqlLocker{conn: conn}, nil
+}
+
+// AcquireLock removes expired locks, then inserts a lock on the task
+// unless one is held, by this or another owner
+func (l *sqlLocker) AcquireLock(taskID, owner string, ttl time.Duration) (bool, error) {
+	now := time.Now().UTC()
+	_, err := l.conn.Exec(`DELETE FROM task_locks WHERE expires_at < ?`, now)
+	if err != nil {
+		return false, fmt.Errorf("failed to remove expired task locks: %w", err)
+	}
+
+	result, err := l.conn.Exec(`
+		INSERT OR IGNORE INTO task_locks (task_id, owner, expires_at)
+		VALUES (?, ?, ?)
+	`, taskID, owner, now.Add(ttl))
+	if err != nil {
+		return false, fmt.Errorf("failed to insert task lock: %w", err)
+	}
+
+	inserted, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to insert task lock: %w", err)
+	}
+	return inserted == 1, nil
+}
+
+// ReleaseLock removes the owner's lock on the task
+func (l *sqlLocker) ReleaseLock(taskID, owner string) error {
+	_, err := l.conn.Exec(`DELETE FROM task_locks WHERE task_id = ? AND owner = ?`, taskID, owner)
+	if err != nil {
+		return fmt.Errorf("failed to release task lock: %w", err)
+	}
+	return nil
+}
+
+// SetLocker replaces the task_locks table locker, for instances sharing a
+// database other than the scheduler's SQLite file
+func (s *Scheduler) SetLocker(locker TaskLocker) {
+	s.locker = locker
+}
+
+// AcquireLock locks a task for ttl so that no other scheduler instance
+// runs it, and reports whether the lock was acquired
+func (s *Scheduler) AcquireLock(taskID string, ttl time.Duration) (bool, error) {
+	return s.locker.AcquireLock(taskID, s.instanceID, ttl)
+}
+
+// ReleaseLock releases this instance's lock on a task
+func (s *Scheduler) ReleaseLock(taskID string) error {
+	return s.locker.ReleaseLock(taskID, s.instanceID)
+}
+
+// TestTaskLocks checks that two schedulers sharing a database run a task
+// only once
+func TestTaskLocks() error {
+	log.Println("Testing task locks...")
+
+	dbPath, cleanup, err := testDBPath("test_task_locks.db")
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+
+	first, err := NewScheduler(dbPath)
+	if err != nil {
+		return fmt.Errorf("failed to create scheduler: %w", err)
+	}
+	defer first.Stop()
+	second, err := NewScheduler(dbPath)
+	if err != nil {
+		return fmt.Errorf("failed to create second scheduler: %w", err)
+	}
+	defer second.Stop()
+
+	var mu sync.Mutex
+	runs := map[string]int{}
+	for name, scheduler := range map[string]*Scheduler{"first": first, "second": second} {
+		name := name
+		scheduler.SetTaskHandler(func(task *Task) (string, error) {
+			mu.Lock()
+			runs[name]++
+			mu.Unlock()
+			time.Sleep(50 * time.Millisecond)
+			return "done", nil
+		})
+	}
+
+	id, err := first.AddTask("report", "session1", map[string]interface{}{"message": "report"}, time.Now().Add(time.Hour), "", "", nil)
+	if err != nil {
+		return err
+	}
+	if task, err := second.GetTask(id); err != nil || task == nil {
+		return fmt.Errorf("expected the second scheduler to see the task, got %v", err)
+	}
+
+	// Both instances fire the task at the same time
+	var wg sync.WaitGroup
+	for _, scheduler := range []*Scheduler{first, second} {
+		wg.Add(1)
+		go func(s *Scheduler) {
+			defer wg.Done()
+			s.executeTask(id)
+		}(scheduler)
+	}
+	wg.Wait()
+	if runs["first"]+runs["second"] != 1 {
+		return fmt.Errorf("expected the task to run once, got %v", runs)
+	}
+	log.Println("✓ Task run by one of two schedulers")
+
+	// The second instance fires a recurring task after the first one ran
+	// and rescheduled it
+	recurringID, err := first.AddRecurringTask("digest", "session1", "@daily", map[string]interface{}{"message": "digest"})
+	if err != nil {
+		return err
+	}
+	due := time.Now().Add(-time.Minute).UTC().Truncate(time.Second)
+	first.conn.Exec(`UPDATE tasks SET next_run = ? WHERE id = ?`, due, recurringID)
+	first.executeScheduledTask(recurringID)
+	second.executeScheduledTask(recurringID)
+	if runs["first"]+runs["second"] != 2 {
+		return fmt.Errorf("expected the recurring task to run once, got %v", runs)
+	}
+
+	// The lock on the scheduled run is kept after it finished
+	first.conn.Exec(`UPDATE tasks SET next_run = ? WHERE id = ?`, due, recurringID)
+	second.executeScheduledTask(recurringID)
+	if runs["first"]+runs["second"] != 2 {
+		return fmt.Errorf("expected the finished run to stay locked, got %v", runs)
+	}
+	log.Println("✓ Late instance does not run the task again")
+
+	locked, err := first.AcquireLock("x", 20*time.Millisecond)
+	if err != nil || !locked {
+		return fmt.Errorf("expected to acquire lock, got %v (%v)", locked, err)
+	}
+	if locked, err := second.AcquireLock("x", time.Minute); err != nil || locked {
+		return fmt.Errorf("expected lock held by the first scheduler, got %v (%v)", locked, err)
+	}
+	if err := second.ReleaseLock("x"); err != nil {
+		return err
+	}
+	if locked, _ := second.AcquireLock("x", time.Minute); locked {
+		return fmt.Errorf("expected the second scheduler not to release the first one's lock")
+	}
+	time.Sleep(30 * time.Millisecond)
+	if locked, err := second.AcquireLock("x", time.Minute); err != nil || !locked {
+		return fmt.Errorf("expected expired lock to be acquired, got %v (%v)", locked, err)
+	}
+	log.Println("✓ Locks expire and are released by their owner only")
+
+	return nil
+}
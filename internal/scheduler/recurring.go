@@ -0,0 +1,322 @@
+package scheduler
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/robfig/cron/v3"
+)
+
+// taskStatusPaused is the status of a paused recurring task
+const taskStatusPaused = "paused"
+
+// recurringParser parses the cron expressions of recurring tasks: the
+// standard five fields, optionally preceded by seconds, or a descriptor
+// such as "@daily"
+var recurringParser = cron.NewParser(
+	cron.SecondOptional | cron.Minute | cron.Hour | cron.Dom | cron.Month | cron.Dow | cron.Descriptor,
+)
+
+// migrateRecurringTasks adds the cron expression and recurring flag to the
+// tasks table. Existing tasks are one-shot.
+func (s *Scheduler) migrateRecurringTasks() error {
+	for _, column := range []struct{ name, definition string }{
+		{"cron_expr", "TEXT"},
+		{"recurring", "BOOLEAN NOT NULL DEFAULT 0"},
+	} {
+		var exists bool
+		err := s.conn.QueryRow(`
+			SELECT EXISTS(SELECT 1 FROM pragma_table_info('tasks') WHERE name = ?)
+		`, column.name).Scan(&exists)
+		if err != nil {
+			return fmt.Errorf("failed to check tasks columns: %w", err)
+		}
+		if exists {
+			continue
+		}
+
+		_, err = s.conn.Exec(fmt.Sprintf(`ALTER TABLE tasks ADD COLUMN %s %s`, column.name, column.definition))
+		if err != nil {
+			return fmt.Errorf("failed to add tasks.%s column: %w", column.name, err)
+		}
+	}
+
+	_, err := s.conn.Exec(`UPDATE tasks SET recurring = 0 WHERE recurring IS NULL`)
+	if err != nil {
+		return fmt.Errorf("failed to migrate tasks: %w", err)
+	}
+	return nil
+}
+
+// normalizeCronExpr adds a zero seconds field to five-field expressions,
+// as the scheduler's cron expects seconds
+func normalizeCronExpr(cronExpr string) string {
+	cronExpr = strings.TrimSpace(cronExpr)
+	if strings.HasPrefix(cronExpr, "@") || strings.HasPrefix(cronExpr, "TZ=") || strings.HasPrefix(cronExpr, "CRON_TZ=") {
+		return cronExpr
+	}
+	if len(strings.Fields(cronExpr)) == 5 {
+		return "0 " + cronExpr
+	}
+	return cronExpr
+}
+
+// AddRecurringTask adds a task run on a cron schedule, such as
+// "0 9 * * 1-5" (weekdays at 9:00) in the default timezone. Unlike
+// one-shot tasks, it is kept after each run and rescheduled.
+func (s *Scheduler) AddRecurringTask(name, sessionID, cronExpr string, payload map[string]interface{}) (string, error) {
+	cronExpr = normalizeCronExpr(cronExpr)
+	schedule, err := recurringParser.Parse(cronExpr)
+	if err != nil {
+		return "", fmt.Errorf("invalid cron expression %q: %w", cronExpr, err)
+	}
+
+	payloadJSON, err := json.Marshal(payload)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal payload: %w", err)
+	}
+
+	id := fmt.Sprintf("%d", time.Now().UnixNano())
+	nextRun := schedule.Next(time.Now().In(s.location))
+
+	_, err = s.conn.Exec(`
+		INSERT INTO tasks (id, name, session_id, status, payload, next_run, timezone, cron_expr, recurring)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, 1)
+	`, id, name, sessionID, "scheduled", string(payloadJSON), nextRun.UTC(), s.location.String(), cronExpr)
+	if err != nil {
+		return "", fmt.Errorf("failed to insert task: %w", err)
+	}
+
+	if err := s.scheduleRecurring(id, cronExpr); err != nil {
+		return "", err
+	}
+
+	log.Printf("Recurring task added: %s (%s)", name, cronExpr)
+	return id, nil
+}
+
+// scheduleRecurring adds a cron entry running a recurring task
+func (s *Scheduler) scheduleRecurring(id, cronExpr string) error {
+	entryID, err := s.cron.AddFunc(cronExpr, func() {
+		s.executeScheduledTask(id)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to schedule task: %w", err)
+	}
+
+	s.recurringMu.Lock()
+	s.recurringEntries[id] = entryID
+	s.recurringMu.Unlock()
+	return nil
+}
+
+// unscheduleRecurring removes the cron entry of a recurring task, if any
+func (s *Scheduler) unscheduleRecurring(id string) {
+	s.recurringMu.Lock()
+	defer s.recurringMu.Unlock()
+
+	if entryID, ok := s.recurringEntries[id]; ok {
+		s.cron.Remove(entryID)
+		delete(s.recurringEntries, id)
+	}
+}
+
+// rescheduleRecurring sets the next run of a recurring task after it ran
+func (s *Scheduler) rescheduleRecurring(task *Task) error {
+	schedule, err := recurringParser.Parse(task.CronExpr)
+	if err != nil {
+		return fmt.Errorf("invalid cron expression %q: %w", task.CronExpr, err)
+	}
+
+	nextRun := schedule.Next(time.Now().In(s.location))
+	_, err = s.conn.Exec(`UPDATE tasks SET next_run = ?, status = ? WHERE id = ? AND status != ?`,
+		nextRun.UTC(), "scheduled", task.ID, taskStatusPaused)
+	if err != nil {
+		return fmt.Errorf("failed to reschedule task: %w", err)
+	}
+	return nil
+}
+
+// GetRecurringTasks returns the recurring tasks, paused ones included
+func (s *Scheduler) GetRecurringTasks() ([]Task, error) {
+	tasks, err := s.GetAllTasks()
+	if err != nil {
+		return nil, err
+	}
+
+	recurring := []Task{}
+	for _, task := range tasks {
+		if task.Recurring {
+			recurring = append(recurring, task)
+		}
+	}
+	return recurring, nil
+}
+
+// getRecurringTask returns a recurring task, or an error if there is no
+// recurring task with that ID
+func (s *Scheduler) getRecurringTask(id string) (*Task, error) {
+	task, err := s.GetTask(id)
+	if err != nil {
+		return nil, err
+	}
+	if task == nil || !task.Recurring {
+		return nil, fmt.Errorf("recurring task not found: %s", id)
+	}
+	return task, nil
+}
+
+// PauseRecurringTask stops running a recurring task until it is resumed
+func (s *Scheduler) PauseRecurringTask(id string) error {
+	if _, err := s.getRecurringTask(id); err != nil {
+		return err
+	}
+
+	_, err := s.conn.Exec(`UPDATE tasks SET status = ? WHERE id = ?`, taskStatusPaused, id)
+	if err != nil {
+		return fmt.Errorf("failed to pause task: %w", err)
+	}
+	s.unscheduleRecurring(id)
+	return nil
+}
+
+// ResumeRecurringTask runs a paused recurring task on its schedule again
+func (s *Scheduler) ResumeRecurringTask(id string) error {
+	task, err := s.getRecurringTask(id)
+	if err != nil {
+		return err
+	}
+	if task.Status != taskStatusPaused {
+		return nil
+	}
+
+	if err := s.rescheduleRecurring(&Task{ID: id, CronExpr: task.CronExpr}); err != nil {
+		return err
+	}
+	_, err = s.conn.Exec(`UPDATE tasks SET status = ? WHERE id = ?`, "scheduled", id)
+	if err != nil {
+		return fmt.Errorf("failed to resume task: %w", err)
+	}
+	return s.scheduleRecurring(id, task.CronExpr)
+}
+
+// TestRecurringTasks checks that recurring tasks are kept and rescheduled
+// after running, and can be paused and resumed
+func TestRecurringTasks() error {
+	log.Println("Testing recurring tasks...")
+
+	dbPath, cleanup, err := testDBPath("test_recurring.db")
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+
+	// A database from before recurring tasks keeps its tasks as one-shot
+	conn, err := sql.Open("sqlite3", dbPath)
+	if err != nil {
+		return err
+	}
+	_, err = conn.Exec(`
+		CREATE TABLE tasks (
+			id TEXT PRIMARY KEY, name TEXT NOT NULL, session_id TEXT NOT NULL, status TEXT NOT NULL,
+			payload TEXT, next_run DATETIME NOT NULL, timezone TEXT, notification_platform TEXT,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+		);
+		INSERT INTO tasks (id, name, session_id, status, payload, next_run)
+		VALUES ('old', 'old', 'session1', 'scheduled', '{}', '2000-01-01 00:00:00');
+	`)
+	conn.Close()
+	if err != nil {
+		return err
+	}
+
+	scheduler := &Scheduler{
+		handlers:         make(map[string]TaskHandler),
+		location:         time.UTC,
+		recurringEntries: make(map[string]cron.EntryID),
+	}
+	scheduler.conn, err = sql.Open("sqlite3", dbPath)
+	if err != nil {
+		return err
+	}
+	scheduler.cron = cron.New(cron.WithSeconds())
+	if err := scheduler.initDB(); err != nil {
+		return err
+	}
+	defer scheduler.Stop()
+
+	if old, err := scheduler.GetTask("old"); err != nil || old == nil || old.Recurring {
+		return fmt.Errorf("expected existing task to be migrated as one-shot, got %+v (%v)", old, err)
+	}
+	scheduler.DeleteTask("old")
+	log.Println("✓ Existing tasks migrated as one-shot")
+
+	for _, expr := range []string{"", "61 * * * *", "* * *", "@sometimes"} {
+		if _, err := scheduler.AddRecurringTask("bad", "session1", expr, nil); err == nil {
+			return fmt.Errorf("expected cron expression %q to be rejected", expr)
+		}
+	}
+	log.Println("✓ Invalid cron expressions rejected")
+
+	runs := 0
+	scheduler.SetTaskHandler(func(task *Task) (string, error) {
+		runs++
+		return "ok", nil
+	})
+
+	id, err := scheduler.AddRecurringTask("report", "session1", "0 9 * * *", map[string]interface{}{"message": "daily report"})
+	if err != nil {
+		return err
+	}
+	task, err := scheduler.GetTask(id)
+	if err != nil || task == nil || !task.Recurring || task.CronExpr != "0 0 9 * * *" {
+		return fmt.Errorf("unexpected recurring task: %+v (%v)", task, err)
+	}
+	if next := task.NextRun.UTC(); next.Hour() != 9 || next.Minute() != 0 || !next.After(time.Now()) {
+		return fmt.Errorf("expected next run at 9:00, got %v", task.NextRun)
+	}
+
+	// Pretend the task is due, then run it
+	scheduler.conn.Exec(`UPDATE tasks SET next_run = ? WHERE id = ?`, time.Now().Add(-time.Hour).UTC(), id)
+	scheduler.executeTask(id)
+	task, err = scheduler.GetTask(id)
+	if err != nil || task == nil || runs != 1 {
+		return fmt.Errorf("expected recurring task to run once and be kept, got %d runs, %+v (%v)", runs, task, err)
+	}
+	if task.Status != "scheduled" || !task.NextRun.After(time.Now()) {
+		return fmt.Errorf("expected recurring task to be rescheduled, got %s at %v", task.Status, task.NextRun)
+	}
+	log.Println("✓ Recurring task kept and rescheduled after running")
+
+	if err := scheduler.PauseRecurringTask(id); err != nil {
+		return err
+	}
+	recurring, err := scheduler.GetRecurringTasks()
+	if err != nil || len(recurring) != 1 || recurring[0].Status != taskStatusPaused {
+		return fmt.Errorf("expected one paused recurring task, got %+v (%v)", recurring, err)
+	}
+	if _, scheduled := scheduler.recurringEntries[id]; scheduled || len(scheduler.cron.Entries()) != 0 {
+		return fmt.Errorf("expected paused task to be removed from cron")
+	}
+	if err := scheduler.ResumeRecurringTask(id); err != nil {
+		return err
+	}
+	if task, _ := scheduler.GetTask(id); task.Status != "scheduled" || len(scheduler.cron.Entries()) != 1 {
+		return fmt.Errorf("expected resumed task to be scheduled, got %s", task.Status)
+	}
+	log.Println("✓ Recurring task paused and resumed")
+
+	oneShot, err := scheduler.AddTask("once", "session1", nil, time.Now().Add(time.Hour), "", "", nil)
+	if err != nil {
+		return err
+	}
+	if err := scheduler.PauseRecurringTask(oneShot); err == nil {
+		return fmt.Errorf("expected pausing a one-shot task to fail")
+	}
+
+	return nil
+}
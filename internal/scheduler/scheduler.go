@@ -3,11 +3,17 @@ package scheduler
 import (
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
 	"time"
 
+	"github.com/Chang-Augenweide/QuickBot-Go/internal/events"
 	"github.com/robfig/cron/v3"
 	_ "github.com/mattn/go-sqlite3"
 )
@@ -20,7 +26,21 @@ type Task struct {
 	Status    string
 	Payload   map[string]interface{}
 	NextRun   time.Time
+	TimeZone  string
 	CreatedAt time.Time
+
+	// NotificationPlatform is the platform the task result is sent to
+	// (e.g. "telegram"); empty means no notification
+	NotificationPlatform string
+
+	// DependsOn lists the IDs of tasks that must complete before this
+	// task runs
+	DependsOn []string
+
+	// CronExpr is the schedule of a recurring task, which is rescheduled
+	// instead of deleted after each run
+	CronExpr  string
+	Recurring bool
 }
 
 // TaskPayload represents task payload structure
@@ -30,20 +50,49 @@ type TaskPayload struct {
 	RemindAt string `json:"remind_at,omitempty"`
 }
 
+// TaskHandler executes a task and returns its result
+type TaskHandler func(task *Task) (string, error)
+
+// NotificationHandler delivers a task result to a platform
+type NotificationHandler func(task *Task, result string, platform string)
+
 // Scheduler represents task scheduler
 type Scheduler struct {
 	conn     *sql.DB
 	cron     *cron.Cron
-	handlers map[string]func(*Task)
+	handlers map[string]TaskHandler
+	notify   NotificationHandler
+	location *time.Location
+	events   *events.EventBus
+
+	// notifiers deliver the results of tasks by notification platform
+	notifiers map[string]NotificationHandler
+
+	// maxOverdue is the number of overdue tasks HealthCheck tolerates
+	maxOverdue int
+
+	// historyRetentionDays is how many days task executions are kept
+	historyRetentionDays int
+
+	// recurringEntries maps recurring task IDs to their cron entries
+	recurringMu      sync.Mutex
+	recurringEntries map[string]cron.EntryID
+
+	// locker keeps other instances sharing the database from running a
+	// task this instance runs, identified by instanceID
+	locker     TaskLocker
+	instanceID string
 }
 
 // NewScheduler creates a new scheduler instance
 func NewScheduler(dbPath string) (*Scheduler, error) {
-	// Create database file if doesn't exist
-	_, err := os.Create(dbPath)
+	// Create database file if doesn't exist, keeping the tasks of other
+	// instances using it
+	file, err := os.OpenFile(dbPath, os.O_RDWR|os.O_CREATE, 0644)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create database file: %w", err)
 	}
+	file.Close()
 
 	conn, err := sql.Open("sqlite3", dbPath)
 	if err != nil {
@@ -51,9 +100,15 @@ func NewScheduler(dbPath string) (*Scheduler, error) {
 	}
 
 	scheduler := &Scheduler{
-		conn:     conn,
-		cron:     cron.New(cron.WithSeconds()),
-		handlers: make(map[string]func(*Task)),
+		conn:             conn,
+		cron:             cron.New(cron.WithSeconds()),
+		handlers:         make(map[string]TaskHandler),
+		notifiers:        make(map[string]NotificationHandler),
+		location:         time.Local,
+		recurringEntries: make(map[string]cron.EntryID),
+
+		historyRetentionDays: defaultHistoryRetentionDays,
+		instanceID:           fmt.Sprintf("%d-%d", os.Getpid(), time.Now().UnixNano()),
 	}
 
 	err = scheduler.initDB()
@@ -80,6 +135,8 @@ func (s *Scheduler) initDB() error {
 			status TEXT NOT NULL,
 			payload TEXT,
 			next_run DATETIME NOT NULL,
+			timezone TEXT,
+			notification_platform TEXT,
 			created_at DATETIME DEFAULT CURRENT_TIMESTAMP
 		)
 	`)
@@ -96,11 +153,34 @@ func (s *Scheduler) initDB() error {
 		return fmt.Errorf("failed to create index: %w", err)
 	}
 
-	return nil
+	if err := s.initDependencyTables(); err != nil {
+		return err
+	}
+	if err := s.initExecutionTable(); err != nil {
+		return err
+	}
+	if err := s.initDeadLetterTable(); err != nil {
+		return err
+	}
+	locker, err := newSQLLocker(s.conn)
+	if err != nil {
+		return err
+	}
+	if s.locker == nil {
+		s.locker = locker
+	}
+	if err := s.migrateHistoryDuration(); err != nil {
+		return err
+	}
+	return s.migrateRecurringTasks()
 }
 
-// Start starts the scheduler
+// Start starts the scheduler and the daily cleanup of old task executions
 func (s *Scheduler) Start() {
+	s.pruneExecutions()
+	if _, err := s.cron.AddFunc("@daily", s.pruneExecutions); err != nil {
+		log.Printf("Failed to schedule task execution cleanup: %v", err)
+	}
 	s.cron.Start()
 	log.Println("✓ Scheduler started")
 }
@@ -114,28 +194,113 @@ func (s *Scheduler) Stop() {
 	log.Println("✓ Scheduler stopped")
 }
 
-// AddTask adds a new task
-func (s *Scheduler) AddTask(name, sessionID string, payload map[string]interface{}, nextRun time.Time) (string, error) {
+// SetDefaultTimezone sets the timezone reminders are parsed in, used for tasks
+// that don't specify one and for the cron schedule. It should be called
+// before Start.
+func (s *Scheduler) SetDefaultTimezone(tz string) error {
+	loc, err := time.LoadLocation(tz)
+	if err != nil {
+		return fmt.Errorf("invalid timezone %s: %w", tz, err)
+	}
+
+	s.cron.Stop()
+	s.location = loc
+	s.cron = cron.New(cron.WithSeconds(), cron.WithLocation(loc))
+	s.recurringMu.Lock()
+	s.recurringEntries = make(map[string]cron.EntryID)
+	s.recurringMu.Unlock()
+
+	// Reschedule persisted tasks on the new cron
+	return s.loadTasks()
+}
+
+// loadLocation returns the location for a timezone name, or the default
+// location when tz is empty
+func (s *Scheduler) loadLocation(tz string) (*time.Location, error) {
+	if tz == "" {
+		return s.location, nil
+	}
+	loc, err := time.LoadLocation(tz)
+	if err != nil {
+		return nil, fmt.Errorf("invalid timezone %s: %w", tz, err)
+	}
+	return loc, nil
+}
+
+// ParseTime parses a natural language, "2006-01-02 15:04" or RFC3339 time
+// in the given timezone (default timezone when empty). The warning is set
+// when a time skipped by a daylight saving time change was moved to the
+// end of the gap. A natural language time that has already passed is an
+// error.
+func (s *Scheduler) ParseTime(expr, tz string) (time.Time, string, error) {
+	loc, err := s.loadLocation(tz)
+	if err != nil {
+		return time.Time{}, "", err
+	}
+
+	t, warning, err := ParseNaturalTime(expr, loc)
+	if err == nil {
+		return t, warning, nil
+	}
+	if errors.Is(err, errPastTime) {
+		return time.Time{}, "", err
+	}
+	if t, err := time.Parse("2006-01-02 15:04", expr); err == nil {
+		t, warning := dateIn(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), loc)
+		return t, warning, nil
+	}
+
+	t, err = time.Parse(time.RFC3339, expr)
+	if err != nil {
+		return time.Time{}, "", fmt.Errorf("invalid time format: %s", expr)
+	}
+	return t, "", nil
+}
+
+// AddTask adds a new task. The timezone is recorded with the task (the
+// default timezone is used when empty); nextRun is stored in UTC. When
+// notificationPlatform is set, the task result is sent to that platform.
+// The task only runs after each task in deps has completed.
+func (s *Scheduler) AddTask(name, sessionID string, payload map[string]interface{}, nextRun time.Time, timezone, notificationPlatform string, deps []string) (string, error) {
 	id := fmt.Sprintf("%d", time.Now().UnixNano())
 
+	loc, err := s.loadLocation(timezone)
+	if err != nil {
+		return "", err
+	}
+
 	payloadJSON, err := json.Marshal(payload)
 	if err != nil {
 		return "", fmt.Errorf("failed to marshal payload: %w", err)
 	}
 
-	_, err = s.conn.Exec(`
-		INSERT INTO tasks (id, name, session_id, status, payload, next_run)
-		VALUES (?, ?, ?, ?, ?, ?)
-	`, id, name, sessionID, "scheduled", string(payloadJSON), nextRun)
+	tx, err := s.conn.Begin()
+	if err != nil {
+		return "", fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(`
+		INSERT INTO tasks (id, name, session_id, status, payload, next_run, timezone, notification_platform)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+	`, id, name, sessionID, "scheduled", string(payloadJSON), nextRun.UTC(), loc.String(), notificationPlatform)
 
 	if err != nil {
 		return "", fmt.Errorf("failed to insert task: %w", err)
 	}
 
+	if err := insertDependencies(tx, id, deps); err != nil {
+		return "", err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return "", fmt.Errorf("failed to commit task: %w", err)
+	}
+
 	// Add to cron
-	cronExpr := formatCronExpression(nextRun)
+	cronExpr := formatCronExpression(nextRun.In(s.location))
 	entryID, err := s.cron.AddFunc(cronExpr, func() {
-		s.executeTask(id)
+		s.executeScheduledTask(id)
 	})
 	if err != nil {
 		return "", fmt.Errorf("failed to schedule task: %w", err)
@@ -145,54 +310,46 @@ func (s *Scheduler) AddTask(name, sessionID string, payload map[string]interface
 	return id, nil
 }
 
-// AddReminder adds a reminder task
-func (s *Scheduler) AddReminder(sessionID, message, remindAt string) (string, error) {
-	// Parse time (HH:MM format)
-	var parsedTime time.Time
-	var err error
-
-	if len(remindAt) == 5 && remindAt[2] == ':' {
-		// Format: HH:MM
-		now := time.Now()
-		hour := time.Hour * time.Duration(now.Hour())
-		minute := time.Minute * time.Duration(now.Minute())
-
-		// Parse the target time
-		parts := make([]time.Duration, 2)
-		fmt.Sscanf(remindAt, "%d:%d", &parts[0], &parts[1])
-		targetTime := parts[0] + parts[1]
-
-		// Set target time for today
-		parsedTime = time.Date(now.Year(), now.Month(), now.Day(), int(targetTime/time.Hour), int((targetTime%time.Hour)/time.Minute), 0, 0, time.Local)
-
-		// If target time has passed, schedule for tomorrow
-		if parsedTime.Before(now) {
-			parsedTime = parsedTime.Add(24 * time.Hour)
-		}
-	} else {
-		// Try parsing as full datetime
-		parsedTime, err = time.Parse(time.RFC3339, remindAt)
-		if err != nil {
-			return "", fmt.Errorf("invalid time format: %w", err)
-		}
+// AddReminder adds a reminder task in the scheduler's timezone. remindAt
+// may be a natural language expression ("tomorrow at 3pm", "in 2 hours",
+// "15:00") or an RFC3339 time. The warning is set when the reminder time
+// does not exist because of a daylight saving time change and was moved.
+func (s *Scheduler) AddReminder(sessionID, message, remindAt string) (string, string, error) {
+	parsedTime, warning, err := s.ParseTime(remindAt, "")
+	if err != nil {
+		return "", "", err
+	}
+	if warning != "" {
+		log.Printf("Warning: reminder for %s: %s", sessionID, warning)
+	}
+
+	// Notify the platform the reminder was created from ("telegram:123")
+	platform := ""
+	if idx := strings.Index(sessionID, ":"); idx > 0 {
+		platform = sessionID[:idx]
 	}
 
-	return s.AddTask("reminder", sessionID, TaskPayload{
-		Type:    "reminder",
-		Message: message,
-	}, parsedTime)
+	id, err := s.AddTask("reminder", sessionID, map[string]interface{}{
+		"type":    "reminder",
+		"message": message,
+	}, parsedTime, "", platform, nil)
+	if err != nil {
+		return "", "", err
+	}
+	return id, warning, nil
 }
 
 // GetTask retrieves a task by ID
 func (s *Scheduler) GetTask(id string) (*Task, error) {
 	var task Task
 	var payload string
+	var timezone, notificationPlatform, cronExpr sql.NullString
 
 	err := s.conn.QueryRow(`
-		SELECT id, name, session_id, status, payload, next_run, created_at
+		SELECT id, name, session_id, status, payload, next_run, timezone, notification_platform, created_at, cron_expr, recurring
 		FROM tasks WHERE id = ?
 	`, id).Scan(&task.ID, &task.Name, &task.SessionID, &task.Status,
-		&payload, &task.NextRun, &task.CreatedAt)
+		&payload, &task.NextRun, &timezone, &notificationPlatform, &task.CreatedAt, &cronExpr, &task.Recurring)
 
 	if err != nil {
 		if err == sql.ErrNoRows {
@@ -200,19 +357,38 @@ func (s *Scheduler) GetTask(id string) (*Task, error) {
 		}
 		return nil, fmt.Errorf("failed to get task: %w", err)
 	}
+	task.TimeZone = timezone.String
+	task.NotificationPlatform = notificationPlatform.String
+	task.CronExpr = cronExpr.String
 
 	err = json.Unmarshal([]byte(payload), &task.Payload)
 	if err != nil {
 		return nil, fmt.Errorf("failed to unmarshal payload: %w", err)
 	}
 
-	return &task, nil
+	rows, err := s.conn.Query(`
+		SELECT depends_on_task_id FROM task_dependencies WHERE task_id = ?
+		ORDER BY depends_on_task_id
+	`, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query task dependencies: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var dep string
+		if err := rows.Scan(&dep); err != nil {
+			return nil, fmt.Errorf("failed to scan task dependency: %w", err)
+		}
+		task.DependsOn = append(task.DependsOn, dep)
+	}
+
+	return &task, rows.Err()
 }
 
 // GetAllTasks returns all tasks
 func (s *Scheduler) GetAllTasks() ([]Task, error) {
 	rows, err := s.conn.Query(`
-		SELECT id, name, session_id, status, payload, next_run, created_at
+		SELECT id, name, session_id, status, payload, next_run, timezone, notification_platform, created_at, cron_expr, recurring
 		FROM tasks
 		ORDER BY next_run ASC
 	`)
@@ -221,21 +397,31 @@ func (s *Scheduler) GetAllTasks() ([]Task, error) {
 	}
 	defer rows.Close()
 
+	deps, err := s.loadDependencies()
+	if err != nil {
+		return nil, err
+	}
+
 	var tasks []Task
 	for rows.Next() {
 		var task Task
 		var payload string
+		var timezone, notificationPlatform, cronExpr sql.NullString
 
 		err := rows.Scan(&task.ID, &task.Name, &task.SessionID, &task.Status,
-			&payload, &task.NextRun, &task.CreatedAt)
+			&payload, &task.NextRun, &timezone, &notificationPlatform, &task.CreatedAt, &cronExpr, &task.Recurring)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan task: %w", err)
 		}
+		task.TimeZone = timezone.String
+		task.NotificationPlatform = notificationPlatform.String
+		task.CronExpr = cronExpr.String
 
 		err = json.Unmarshal([]byte(payload), &task.Payload)
 		if err != nil {
 			continue
 		}
+		task.DependsOn = deps[task.ID]
 
 		tasks = append(tasks, task)
 	}
@@ -243,7 +429,8 @@ func (s *Scheduler) GetAllTasks() ([]Task, error) {
 	return tasks, nil
 }
 
-// GetDueTasks returns tasks that are due for execution
+// GetDueTasks returns tasks that are due for execution and whose
+// dependencies have all completed
 func (s *Scheduler) GetDueTasks() []Task {
 	tasks, err := s.GetAllTasks()
 	if err != nil {
@@ -251,9 +438,20 @@ func (s *Scheduler) GetDueTasks() []Task {
 	}
 
 	var dueTasks []Task
-	now := time.Now()
+	now := time.Now().UTC()
 	for _, task := range tasks {
-		if task.Status == "scheduled" && task.NextRun.Before(now) {
+		if task.Status != "scheduled" && task.Status != taskStatusWaiting {
+			continue
+		}
+		if !task.NextRun.Before(now) {
+			continue
+		}
+		ready, err := s.dependenciesCompleted(&task)
+		if err != nil {
+			log.Printf("Failed to check dependencies of task %s: %v", task.ID, err)
+			continue
+		}
+		if ready {
 			dueTasks = append(dueTasks, task)
 		}
 	}
@@ -267,16 +465,62 @@ func (s *Scheduler) DeleteTask(id string) error {
 	if err != nil {
 		return fmt.Errorf("failed to delete task: %w", err)
 	}
+
+	// Dependencies on the task are kept for the tasks still waiting on it
+	_, err = s.conn.Exec(`DELETE FROM task_dependencies WHERE task_id = ?`, id)
+	if err != nil {
+		return fmt.Errorf("failed to delete task dependencies: %w", err)
+	}
 	return nil
 }
 
 // SetTaskHandler sets a handler for task execution
-func (s *Scheduler) SetTaskHandler(handler func(*Task)) {
+func (s *Scheduler) SetTaskHandler(handler TaskHandler) {
 	s.handlers["default"] = handler
 }
 
-// executeTask executes a task
+// SetTypeHandler sets a handler for tasks whose payload "type" is taskType,
+// taking precedence over the default handler
+func (s *Scheduler) SetTypeHandler(taskType string, handler TaskHandler) {
+	s.handlers[taskType] = handler
+}
+
+// SetNotificationHandler sets the handler that delivers task results
+// to notification platforms without a handler of their own
+func (s *Scheduler) SetNotificationHandler(handler NotificationHandler) {
+	s.notify = handler
+}
+
+// SetPlatformNotificationHandler sets the handler that delivers the
+// results of tasks whose notification platform is platform, taking
+// precedence over the default handler
+func (s *Scheduler) SetPlatformNotificationHandler(platform string, handler NotificationHandler) {
+	s.notifiers[platform] = handler
+}
+
+// SetEventBus sets the bus that task events are published on
+func (s *Scheduler) SetEventBus(bus *events.EventBus) {
+	s.events = bus
+}
+
+// executeTask executes a task now, unless another instance sharing the
+// database is running it
 func (s *Scheduler) executeTask(id string) {
+	s.execute(id, false)
+}
+
+// executeScheduledTask executes a task fired by its cron entry, once per
+// run time across the instances sharing the database. The task is
+// skipped when its next_run is still ahead: another instance ran it and
+// rescheduled it.
+func (s *Scheduler) executeScheduledTask(id string) {
+	s.execute(id, true)
+}
+
+// execute executes a task under a lock on its run at next_run. The lock
+// on a scheduled run is kept until it expires, so that an instance
+// firing late does not run it again.
+func (s *Scheduler) execute(id string, scheduled bool) {
 	task, err := s.GetTask(id)
 	if err != nil {
 		log.Printf("Failed to get task %s: %v", id, err)
@@ -287,20 +531,137 @@ func (s *Scheduler) executeTask(id string) {
 		return
 	}
 
-	log.Printf("Executing task: %s", task.Name)
+	// Cron fires on the second, up to a second before a next_run with a
+	// fractional part
+	if scheduled && task.NextRun.After(time.Now().Add(time.Second)) {
+		log.Printf("Task %s already ran, next run at %v", id, task.NextRun)
+		return
+	}
 
-	// Call handler
-	if handler, ok := s.handlers["default"]; ok {
-		handler(task)
+	runKey := taskRunKey(task)
+	locked, err := s.AcquireLock(runKey, taskLockTTL)
+	if err != nil {
+		log.Printf("Failed to lock task %s: %v", id, err)
+		return
+	}
+	if !locked {
+		log.Printf("Task %s is run by another instance", id)
+		return
 	}
+	release := !scheduled
+	defer func() {
+		if release {
+			if err := s.ReleaseLock(runKey); err != nil {
+				log.Printf("Failed to unlock task %s: %v", id, err)
+			}
+		}
+	}()
 
-	// Delete the task after execution
-	err = s.DeleteTask(id)
+	// A task whose dependencies have not completed waits for them and is
+	// run by runWaitingDependents
+	ready, err := s.dependenciesCompleted(task)
 	if err != nil {
-		log.Printf("Failed to delete task %s: %v", id, err)
+		log.Printf("Failed to check dependencies of task %s: %v", id, err)
+		release = true
+		return
+	}
+	if !ready {
+		release = true
+		_, err := s.conn.Exec(`UPDATE tasks SET status = ? WHERE id = ?`, taskStatusWaiting, id)
+		if err != nil {
+			log.Printf("Failed to update task %s: %v", id, err)
+		}
+		log.Printf("Task %s waiting for dependencies: %s", task.Name, strings.Join(task.DependsOn, ", "))
+		return
+	}
+
+	log.Printf("Executing task: %s", task.Name)
+	start := time.Now()
+
+	executionID, execErr := s.startExecution(id)
+	if execErr != nil {
+		log.Printf("Failed to record execution of task %s: %v", id, execErr)
+	}
+
+	// Call handler; without one, the result is the payload message
+	result, _ := task.Payload["message"].(string)
+	handler, ok := s.handlers["default"]
+	if taskType, _ := task.Payload["type"].(string); taskType != "" {
+		if typeHandler, found := s.handlers[taskType]; found {
+			handler, ok = typeHandler, true
+		}
+	}
+	if ok {
+		result, err = handler(task)
+		if err != nil {
+			log.Printf("Task %s failed: %v", id, err)
+			if err := s.deadLetter(task, err); err != nil {
+				log.Printf("Failed to dead-letter task %s: %v", id, err)
+			}
+		}
+	}
+
+	if execErr == nil {
+		if err := s.finishExecution(executionID, result, err); err != nil {
+			log.Printf("Failed to record execution of task %s: %v", id, err)
+		}
+	}
+
+	// Notify the task's platform of the result
+	if err == nil && task.NotificationPlatform != "" {
+		notify, ok := s.notifiers[task.NotificationPlatform]
+		if !ok {
+			notify = s.notify
+		}
+		if notify != nil {
+			notify(task, result, task.NotificationPlatform)
+		} else {
+			log.Printf("No notification handler for platform %s of task %s", task.NotificationPlatform, id)
+		}
+	}
+
+	if s.events != nil {
+		payload := events.TaskPayload{
+			TaskID:    task.ID,
+			Name:      task.Name,
+			SessionID: task.SessionID,
+			Result:    result,
+		}
+		if err != nil {
+			payload.Error = err.Error()
+		}
+		s.events.Publish(events.Event{Topic: events.TopicTaskCompleted, Payload: payload})
+	}
+
+	taskErr := err
+	if err := s.recordHistory(task, result, taskErr, time.Since(start)); err != nil {
+		log.Printf("Failed to record history of task %s: %v", id, err)
+	}
+
+	// Delete the task after execution; recurring tasks are kept for
+	// their next run
+	if task.Recurring {
+		err = s.rescheduleRecurring(task)
+		if err != nil {
+			log.Printf("Failed to reschedule task %s: %v", id, err)
+		}
+	} else {
+		err = s.DeleteTask(id)
+		if err != nil {
+			log.Printf("Failed to delete task %s: %v", id, err)
+		}
+	}
+
+	if taskErr == nil {
+		s.runWaitingDependents(id)
 	}
 }
 
+// taskRunKey returns the lock key of a task's run at its next_run
+func taskRunKey(task *Task) string {
+	return task.ID + "@" + task.NextRun.UTC().Format(time.RFC3339)
+}
+
 // loadTasks loads existing tasks from database and schedules them
 func (s *Scheduler) loadTasks() error {
 	tasks, err := s.GetAllTasks()
@@ -309,10 +670,18 @@ func (s *Scheduler) loadTasks() error {
 	}
 
 	for _, task := range tasks {
+		if task.Recurring {
+			if task.Status == "scheduled" {
+				if err := s.scheduleRecurring(task.ID, task.CronExpr); err != nil {
+					log.Printf("Failed to schedule task %s: %v", task.ID, err)
+				}
+			}
+			continue
+		}
 		if task.Status == "scheduled" && task.NextRun.After(time.Now()) {
-			cronExpr := formatCronExpression(task.NextRun)
+			cronExpr := formatCronExpression(task.NextRun.In(s.location))
 			_, err := s.cron.AddFunc(cronExpr, func() {
-				s.executeTask(task.ID)
+				s.executeScheduledTask(task.ID)
 			})
 			if err != nil {
 				log.Printf("Failed to schedule task %s: %v", task.ID, err)
@@ -334,11 +703,28 @@ func formatCronExpression(t time.Time) string {
 	)
 }
 
+// testDBPath returns the path of a database named name in a new
+// temporary directory, so that a database left by a failed run cannot
+// change a test's counts, and a function removing the directory
+func testDBPath(name string) (string, func(), error) {
+	dir, err := os.MkdirTemp("", "quickbot-scheduler")
+	if err != nil {
+		return "", nil, err
+	}
+	return filepath.Join(dir, name), func() { os.RemoveAll(dir) }, nil
+}
+
 // TestScheduler runs tests on the scheduler module
 func TestScheduler() error {
 	log.Println("Testing Scheduler module...")
 
-	scheduler, err := NewScheduler("test_scheduler.db")
+	dbPath, cleanup, err := testDBPath("test_scheduler.db")
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+
+	scheduler, err := NewScheduler(dbPath)
 	if err != nil {
 		return fmt.Errorf("failed to create scheduler: %w", err)
 	}
@@ -346,10 +732,10 @@ func TestScheduler() error {
 
 	// Add a test task
 	testTime := time.Now().Add(2 * time.Minute)
-	taskID, err := scheduler.AddTask("test", "session1", TaskPayload{
-		Type:    "test",
-		Message: "test message",
-	}, testTime)
+	taskID, err := scheduler.AddTask("test", "session1", map[string]interface{}{
+		"type":    "test",
+		"message": "test message",
+	}, testTime, "", "", nil)
 	if err != nil {
 		return fmt.Errorf("failed to add task: %w", err)
 	}
@@ -372,12 +758,171 @@ func TestScheduler() error {
 	}
 	log.Printf("✓ Retrieved %d tasks", len(tasks))
 
-	// Cleanup
-	os.Remove("test_scheduler.db")
+	// A task at 09:00 Tokyo time is stored as 00:00 UTC
+	tokyoRun, _, err := scheduler.ParseTime("2026-12-01 09:00", "Asia/Tokyo")
+	if err != nil {
+		return fmt.Errorf("failed to parse Tokyo time: %w", err)
+	}
+	tokyoID, err := scheduler.AddTask("tokyo", "session1", map[string]interface{}{"message": "ohayo"}, tokyoRun, "Asia/Tokyo", "", nil)
+	if err != nil {
+		return fmt.Errorf("failed to add Tokyo task: %w", err)
+	}
+	var storedRun time.Time
+	var storedZone string
+	err = scheduler.conn.QueryRow(`SELECT next_run, timezone FROM tasks WHERE id = ?`, tokyoID).Scan(&storedRun, &storedZone)
+	if err != nil {
+		return fmt.Errorf("failed to read Tokyo task: %w", err)
+	}
+	if _, offset := storedRun.Zone(); offset != 0 || !storedRun.Equal(time.Date(2026, 12, 1, 0, 0, 0, 0, time.UTC)) || storedZone != "Asia/Tokyo" {
+		return fmt.Errorf("expected next_run 2026-12-01 00:00 UTC in Asia/Tokyo, got %v in %s", storedRun, storedZone)
+	}
+	scheduler.DeleteTask(tokyoID)
+	log.Println("✓ Task times stored in UTC")
+
+	// Dependencies: A -> B -> C, with D independent
+	past := time.Now().Add(-time.Minute)
+	ids := make(map[string]string)
+	for _, spec := range []struct{ name, dep string }{{"A", ""}, {"B", "A"}, {"C", "B"}, {"D", ""}} {
+		var deps []string
+		if spec.dep != "" {
+			deps = []string{ids[spec.dep]}
+		}
+		id, err := scheduler.AddTask(spec.name, "session1", map[string]interface{}{"message": spec.name}, past, "", "", deps)
+		if err != nil {
+			return fmt.Errorf("failed to add task %s: %w", spec.name, err)
+		}
+		ids[spec.name] = id
+	}
+
+	if graph := scheduler.GetTaskGraph(); len(graph[ids["C"]]) != 1 || graph[ids["C"]][0] != ids["B"] {
+		return fmt.Errorf("unexpected task graph: %v", graph)
+	}
+	if err := scheduler.ValidateDependencies(); err != nil {
+		return fmt.Errorf("unexpected dependency error: %w", err)
+	}
+
+	dueNames := func() string {
+		var names []string
+		for _, task := range scheduler.GetDueTasks() {
+			names = append(names, task.Name)
+		}
+		sort.Strings(names)
+		return strings.Join(names, ",")
+	}
+	for _, step := range []struct{ due, run string }{{"A,D", "A"}, {"B,D", "D"}, {"B", "B"}, {"C", "C"}, {"", ""}} {
+		if due := dueNames(); due != step.due {
+			return fmt.Errorf("expected due tasks %q, got %q", step.due, due)
+		}
+		if step.run == "" {
+			break
+		}
+		scheduler.executeTask(ids[step.run])
+
+		var status string
+		err := scheduler.conn.QueryRow(`SELECT status FROM task_history WHERE task_id = ?`, ids[step.run]).Scan(&status)
+		if err != nil || status != HistoryCompleted {
+			return fmt.Errorf("task %s not recorded as completed: %q %v", step.run, status, err)
+		}
+	}
+	log.Println("✓ Task dependencies respected")
+
+	counts, err := scheduler.GetHistoryCounts()
+	if err != nil || counts[HistoryCompleted] != 4 || counts[HistoryFailed] != 0 {
+		return fmt.Errorf("unexpected task history counts: %v %v", counts, err)
+	}
+	log.Println("✓ Task history counted")
+
+	// A scheduled task 10 minutes past its run time is overdue
+	overdueID, err := scheduler.AddTask("overdue", "session1", map[string]interface{}{"message": "late"}, time.Now().Add(-10*time.Minute), "", "", nil)
+	if err != nil {
+		return fmt.Errorf("failed to add overdue task: %w", err)
+	}
+	stats, err := scheduler.Stats()
+	if err != nil {
+		return fmt.Errorf("failed to get stats: %w", err)
+	}
+	if stats.OverdueTasks != 1 || stats.PendingTasks != 1 || stats.TotalTasks != 2 || stats.CompletedToday != 4 {
+		return fmt.Errorf("unexpected scheduler stats: %+v", stats)
+	}
+	if overdue, err := scheduler.GetOverdueTasks(); err != nil || len(overdue) != 1 || overdue[0].ID != overdueID {
+		return fmt.Errorf("unexpected overdue tasks: %v %v", overdue, err)
+	}
+	if err := scheduler.HealthCheck(); err == nil {
+		return fmt.Errorf("health check passed with an overdue task")
+	}
+	scheduler.SetOverdueAlertThreshold(1)
+	if err := scheduler.HealthCheck(); err != nil {
+		return fmt.Errorf("health check failed within threshold: %w", err)
+	}
+	scheduler.DeleteTask(overdueID)
+	log.Println("✓ Overdue task counted in stats")
+
+	// A cycle must be reported
+	scheduler.conn.Exec(`INSERT INTO task_dependencies (task_id, depends_on_task_id) VALUES ('x', 'y'), ('y', 'x')`)
+	if err := scheduler.ValidateDependencies(); err == nil {
+		return fmt.Errorf("dependency cycle not detected")
+	}
+	scheduler.conn.Exec(`DELETE FROM task_dependencies WHERE task_id IN ('x', 'y')`)
+	log.Println("✓ Dependency cycle detected")
+
 	log.Println("✓ Scheduler module tests passed")
 	return nil
 }
 
+// TestTaskNotifications checks that task results are delivered by the
+// handler of the task's notification platform
+func TestTaskNotifications() error {
+	log.Println("Testing task notifications...")
+
+	dbPath, cleanup, err := testDBPath("test_task_notifications.db")
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+
+	scheduler, err := NewScheduler(dbPath)
+	if err != nil {
+		return fmt.Errorf("failed to create scheduler: %w", err)
+	}
+	defer scheduler.Stop()
+
+	delivered := map[string][]string{}
+	handler := func(name string) NotificationHandler {
+		return func(task *Task, result string, platform string) {
+			delivered[name] = append(delivered[name], platform+" "+task.SessionID+" "+result)
+		}
+	}
+	scheduler.SetNotificationHandler(handler("default"))
+	scheduler.SetPlatformNotificationHandler("telegram", handler("telegram"))
+	scheduler.SetPlatformNotificationHandler("discord", handler("discord"))
+
+	for _, spec := range []struct{ sessionID, platform string }{
+		{"telegram:42", "telegram"},
+		{"discord:7", "discord"},
+		{"slack:U1", "slack"},
+		{"api-session", ""},
+	} {
+		payload := map[string]interface{}{"message": "report ready"}
+		id, err := scheduler.AddTask("report", spec.sessionID, payload, time.Now().Add(time.Hour), "", spec.platform, nil)
+		if err != nil {
+			return err
+		}
+		scheduler.executeTask(id)
+	}
+
+	expected := map[string][]string{
+		"telegram": {"telegram telegram:42 report ready"},
+		"discord":  {"discord discord:7 report ready"},
+		"default":  {"slack slack:U1 report ready"},
+	}
+	if fmt.Sprint(delivered) != fmt.Sprint(expected) {
+		return fmt.Errorf("expected notifications %v, got %v", expected, delivered)
+	}
+	log.Println("✓ Results routed to the task's platform")
+
+	return nil
+}
+
 func main() {
 	log.Println("QuickBot Go Scheduler Module")
 	TestScheduler()
@@ -0,0 +1,128 @@
+package scheduler
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// overdueGrace is how long past its run time a scheduled task may be
+// before it counts as overdue
+const overdueGrace = 5 * time.Minute
+
+// SchedulerStats summarizes the scheduler state. Completed and failed
+// counts cover runs since midnight in the scheduler's default timezone.
+type SchedulerStats struct {
+	TotalTasks         int64 `json:"total_tasks"`
+	PendingTasks       int64 `json:"pending_tasks"`
+	OverdueTasks       int64 `json:"overdue_tasks"`
+	CompletedToday     int64 `json:"completed_today"`
+	FailedToday        int64 `json:"failed_today"`
+	AverageExecutionMs int64 `json:"average_execution_ms"`
+}
+
+// migrateHistoryDuration adds the run duration to the task history
+func (s *Scheduler) migrateHistoryDuration() error {
+	var exists bool
+	err := s.conn.QueryRow(`
+		SELECT EXISTS(SELECT 1 FROM pragma_table_info('task_history') WHERE name = 'duration_ms')
+	`).Scan(&exists)
+	if err != nil {
+		return fmt.Errorf("failed to check task_history columns: %w", err)
+	}
+	if exists {
+		return nil
+	}
+
+	_, err = s.conn.Exec(`ALTER TABLE task_history ADD COLUMN duration_ms INTEGER`)
+	if err != nil {
+		return fmt.Errorf("failed to add task_history.duration_ms column: %w", err)
+	}
+	return nil
+}
+
+// Stats returns the task counts, today's task outcomes and the average
+// run duration of recorded task runs
+func (s *Scheduler) Stats() (SchedulerStats, error) {
+	var stats SchedulerStats
+
+	tasks, err := s.GetAllTasks()
+	if err != nil {
+		return stats, err
+	}
+	now := time.Now()
+	for _, task := range tasks {
+		stats.TotalTasks++
+		switch {
+		case isOverdue(task, now):
+			stats.OverdueTasks++
+		case task.NextRun.After(now):
+			stats.PendingTasks++
+		}
+	}
+
+	y, m, d := now.In(s.location).Date()
+	midnight := time.Date(y, m, d, 0, 0, 0, 0, s.location).UTC().Format("2006-01-02 15:04:05")
+
+	err = s.conn.QueryRow(`
+		SELECT
+			COALESCE(SUM(CASE WHEN status = ? AND executed_at >= ? THEN 1 ELSE 0 END), 0),
+			COALESCE(SUM(CASE WHEN status = ? AND executed_at >= ? THEN 1 ELSE 0 END), 0)
+		FROM task_history
+	`, HistoryCompleted, midnight, HistoryFailed, midnight).Scan(&stats.CompletedToday, &stats.FailedToday)
+	if err != nil {
+		return stats, fmt.Errorf("failed to count task history: %w", err)
+	}
+
+	var average sql.NullFloat64
+	err = s.conn.QueryRow(`SELECT AVG(duration_ms) FROM task_history`).Scan(&average)
+	if err != nil {
+		return stats, fmt.Errorf("failed to average task duration: %w", err)
+	}
+	stats.AverageExecutionMs = int64(average.Float64)
+
+	return stats, nil
+}
+
+// GetOverdueTasks returns the scheduled tasks more than overdueGrace past
+// their run time, oldest first
+func (s *Scheduler) GetOverdueTasks() ([]Task, error) {
+	tasks, err := s.GetAllTasks()
+	if err != nil {
+		return nil, err
+	}
+
+	overdue := []Task{}
+	now := time.Now()
+	for _, task := range tasks {
+		if isOverdue(task, now) {
+			overdue = append(overdue, task)
+		}
+	}
+	return overdue, nil
+}
+
+// isOverdue reports whether a scheduled task is more than overdueGrace
+// past its run time
+func isOverdue(task Task, now time.Time) bool {
+	return task.Status == "scheduled" && task.NextRun.Before(now.Add(-overdueGrace))
+}
+
+// SetOverdueAlertThreshold sets how many overdue tasks HealthCheck
+// tolerates
+func (s *Scheduler) SetOverdueAlertThreshold(threshold int) {
+	s.maxOverdue = threshold
+}
+
+// HealthCheck returns an error if more tasks are overdue than the overdue
+// alert threshold
+func (s *Scheduler) HealthCheck() error {
+	stats, err := s.Stats()
+	if err != nil {
+		return err
+	}
+	if stats.OverdueTasks > int64(s.maxOverdue) {
+		return fmt.Errorf("%d overdue tasks (threshold %d)", stats.OverdueTasks, s.maxOverdue)
+	}
+	return nil
+}
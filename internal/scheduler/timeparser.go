@@ -0,0 +1,548 @@
+package scheduler
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// defaultReminderHour is used when an expression names a day but no time
+const defaultReminderHour = 9
+
+// errPastTime is returned when an expression names a time that has
+// already passed, such as "today at 9am" after 09:00
+var errPastTime = errors.New("time is in the past")
+
+var weekdays = map[string]time.Weekday{
+	"sunday":    time.Sunday,
+	"monday":    time.Monday,
+	"tuesday":   time.Tuesday,
+	"wednesday": time.Wednesday,
+	"thursday":  time.Thursday,
+	"friday":    time.Friday,
+	"saturday":  time.Saturday,
+	"sun":       time.Sunday,
+	"mon":       time.Monday,
+	"tue":       time.Tuesday,
+	"wed":       time.Wednesday,
+	"thu":       time.Thursday,
+	"fri":       time.Friday,
+	"sat":       time.Saturday,
+}
+
+var durationUnits = map[string]time.Duration{
+	"second":  time.Second,
+	"seconds": time.Second,
+	"sec":     time.Second,
+	"secs":    time.Second,
+	"minute":  time.Minute,
+	"minutes": time.Minute,
+	"min":     time.Minute,
+	"mins":    time.Minute,
+	"hour":    time.Hour,
+	"hours":   time.Hour,
+	"hr":      time.Hour,
+	"hrs":     time.Hour,
+	"day":     24 * time.Hour,
+	"days":    24 * time.Hour,
+	"week":    7 * 24 * time.Hour,
+	"weeks":   7 * 24 * time.Hour,
+}
+
+// ParseNaturalTime parses a natural language time expression such as
+// "in 2 hours", "tomorrow at 3pm", "next Monday at 9:00" or "15:00"
+// relative to the current time in the given timezone. "next <weekday>"
+// names the day in the following calendar week (weeks start on Monday). An
+// error is returned when the named time has already passed. The warning is
+// set when the time was moved because of a daylight saving time change.
+func ParseNaturalTime(expr string, tz *time.Location) (time.Time, string, error) {
+	if tz == nil {
+		tz = time.Local
+	}
+	return parseNaturalTimeAt(expr, time.Now().In(tz))
+}
+
+// parseNaturalTimeAt parses expr relative to now, in now's location
+func parseNaturalTimeAt(expr string, now time.Time) (time.Time, string, error) {
+	p := &timeParser{
+		tokens: tokenizeTime(expr),
+		now:    now,
+	}
+
+	if len(p.tokens) == 0 {
+		return time.Time{}, "", fmt.Errorf("empty time expression")
+	}
+
+	t, err := p.parseExpression()
+	if err != nil {
+		return time.Time{}, "", fmt.Errorf("invalid time expression %q: %w", expr, err)
+	}
+
+	if !p.done() {
+		return time.Time{}, "", fmt.Errorf("invalid time expression %q: unexpected %q", expr, p.peek())
+	}
+
+	return t, p.warning, nil
+}
+
+// dateIn returns hour:minute on the given date in loc. A time skipped by a
+// daylight saving time change is moved to the end of the gap (02:30
+// becomes 03:00) with a warning; a time that occurs twice resolves to its
+// first occurrence.
+func dateIn(year int, month time.Month, day, hour, minute int, loc *time.Location) (time.Time, string) {
+	t := time.Date(year, month, day, hour, minute, 0, 0, loc)
+
+	start, end := t.ZoneBounds()
+	if t.Hour() != hour || t.Minute() != minute {
+		// time.Date placed the skipped time in the zone on either side
+		// of the gap
+		adjusted := start
+		if t.Hour()*60+t.Minute() < hour*60+minute {
+			adjusted = end
+		}
+		return adjusted, fmt.Sprintf("%02d:%02d on %04d-%02d-%02d does not exist in %s because of a daylight saving time change, using %s instead",
+			hour, minute, year, month, day, loc, adjusted.Format("15:04 MST"))
+	}
+
+	// After the clocks go back, the same time may have occurred before
+	// the change
+	if !start.IsZero() {
+		_, offset := t.Zone()
+		_, previousOffset := start.Add(-time.Nanosecond).Zone()
+		earlier := t.Add(time.Duration(offset-previousOffset) * time.Second)
+		if earlier.Before(start) && earlier.Hour() == hour && earlier.Minute() == minute {
+			return earlier, ""
+		}
+	}
+	return t, ""
+}
+
+// tokenizeTime splits an expression into lowercase tokens, separating
+// am/pm suffixes from numbers ("3pm" -> "3", "pm")
+func tokenizeTime(expr string) []string {
+	var tokens []string
+	for _, field := range strings.Fields(strings.ToLower(expr)) {
+		field = strings.Trim(field, ",.")
+		if field == "" {
+			continue
+		}
+		if len(field) > 2 && (strings.HasSuffix(field, "am") || strings.HasSuffix(field, "pm")) &&
+			field[len(field)-3] >= '0' && field[len(field)-3] <= '9' {
+			tokens = append(tokens, field[:len(field)-2], field[len(field)-2:])
+			continue
+		}
+		tokens = append(tokens, field)
+	}
+	return tokens
+}
+
+// timeParser is a recursive descent parser over time expression tokens:
+//
+//	expression := relative | day [clock] | clock [day]
+//	relative   := "in" amount unit
+//	day        := "today" | "tomorrow" | ["next"] weekday
+//	clock      := ["at"] (hour[":"minute] ["am"|"pm"] | "noon" | "midnight")
+type timeParser struct {
+	tokens  []string
+	pos     int
+	now     time.Time
+	warning string
+}
+
+func (p *timeParser) peek() string {
+	if p.pos >= len(p.tokens) {
+		return ""
+	}
+	return p.tokens[p.pos]
+}
+
+func (p *timeParser) next() string {
+	tok := p.peek()
+	p.pos++
+	return tok
+}
+
+func (p *timeParser) done() bool {
+	return p.pos >= len(p.tokens)
+}
+
+func (p *timeParser) parseExpression() (time.Time, error) {
+	if p.peek() == "in" {
+		return p.parseRelative()
+	}
+
+	if p.isDayStart() {
+		day, strict, err := p.parseDay()
+		if err != nil {
+			return time.Time{}, err
+		}
+
+		hour, minute := defaultReminderHour, 0
+		if !p.done() {
+			hour, minute, err = p.parseClock()
+			if err != nil {
+				return time.Time{}, err
+			}
+		}
+		return p.resolve(day, strict, hour, minute)
+	}
+
+	hour, minute, err := p.parseClock()
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	if p.isDayStart() {
+		day, strict, err := p.parseDay()
+		if err != nil {
+			return time.Time{}, err
+		}
+		return p.resolve(day, strict, hour, minute)
+	}
+
+	// Clock only: today, or tomorrow if the time has passed
+	t := p.at(p.now, hour, minute)
+	if !t.After(p.now) {
+		t = p.at(p.now.AddDate(0, 0, 1), hour, minute)
+	}
+	return t, nil
+}
+
+// parseRelative parses "in <amount> <unit>"
+func (p *timeParser) parseRelative() (time.Time, error) {
+	p.next() // "in"
+
+	amountTok := p.next()
+	var amount int
+	switch amountTok {
+	case "a", "an":
+		amount = 1
+	default:
+		n, err := strconv.Atoi(amountTok)
+		if err != nil || n <= 0 {
+			return time.Time{}, fmt.Errorf("expected amount after \"in\", got %q", amountTok)
+		}
+		amount = n
+	}
+
+	unitTok := p.next()
+	unit, ok := durationUnits[unitTok]
+	if !ok {
+		return time.Time{}, fmt.Errorf("unknown time unit %q", unitTok)
+	}
+
+	return p.now.Add(time.Duration(amount) * unit), nil
+}
+
+func (p *timeParser) isDayStart() bool {
+	tok := p.peek()
+	if tok == "today" || tok == "tomorrow" || tok == "next" || tok == "on" {
+		return true
+	}
+	_, ok := weekdays[tok]
+	return ok
+}
+
+// parseDay returns the number of days from today. strict is false when the
+// resolved time may still roll over to next week if it has already passed
+// (a bare weekday naming today). "next <weekday>" is the day in the
+// following Monday-to-Sunday week, so "next friday" on a Monday is 11 days
+// away while "next monday" on a Saturday is 2 days away.
+func (p *timeParser) parseDay() (int, bool, error) {
+	if p.peek() == "on" {
+		p.next()
+	}
+
+	switch tok := p.next(); tok {
+	case "today":
+		return 0, true, nil
+	case "tomorrow":
+		return 1, true, nil
+	case "next":
+		dayTok := p.next()
+		wd, ok := weekdays[dayTok]
+		if !ok {
+			return 0, false, fmt.Errorf("expected weekday after \"next\", got %q", dayTok)
+		}
+		return 7 - daysSinceMonday(p.now.Weekday()) + daysSinceMonday(wd), true, nil
+	default:
+		wd, ok := weekdays[tok]
+		if !ok {
+			return 0, false, fmt.Errorf("expected day, got %q", tok)
+		}
+		return (int(wd) - int(p.now.Weekday()) + 7) % 7, false, nil
+	}
+}
+
+// daysSinceMonday returns the position of wd in a week starting on Monday
+func daysSinceMonday(wd time.Weekday) int {
+	return (int(wd) + 6) % 7
+}
+
+// parseClock parses a time of day and returns hour and minute
+func (p *timeParser) parseClock() (int, int, error) {
+	if p.peek() == "at" {
+		p.next()
+	}
+
+	tok := p.next()
+	switch tok {
+	case "":
+		return 0, 0, fmt.Errorf("expected time of day")
+	case "noon":
+		return 12, 0, nil
+	case "midnight":
+		return 0, 0, nil
+	}
+
+	hourPart, minutePart := tok, "0"
+	if idx := strings.Index(tok, ":"); idx >= 0 {
+		hourPart, minutePart = tok[:idx], tok[idx+1:]
+	}
+
+	hour, err := strconv.Atoi(hourPart)
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid hour %q", hourPart)
+	}
+	minute, err := strconv.Atoi(minutePart)
+	if err != nil || minute < 0 || minute > 59 {
+		return 0, 0, fmt.Errorf("invalid minute %q", minutePart)
+	}
+
+	switch p.peek() {
+	case "am", "pm":
+		meridiem := p.next()
+		if hour < 1 || hour > 12 {
+			return 0, 0, fmt.Errorf("invalid 12-hour time %q", tok)
+		}
+		if meridiem == "am" && hour == 12 {
+			hour = 0
+		} else if meridiem == "pm" && hour != 12 {
+			hour += 12
+		}
+	default:
+		if hour < 0 || hour > 23 {
+			return 0, 0, fmt.Errorf("invalid hour %q", hourPart)
+		}
+	}
+
+	return hour, minute, nil
+}
+
+// resolve builds the time days from today at hour:minute. A strict day
+// whose time has already passed ("today at 9am" after 09:00) is an error.
+func (p *timeParser) resolve(days int, strict bool, hour, minute int) (time.Time, error) {
+	t := p.at(p.now.AddDate(0, 0, days), hour, minute)
+	if !t.After(p.now) {
+		if strict {
+			return time.Time{}, fmt.Errorf("%w: %s", errPastTime, t.Format("2006-01-02 15:04 MST"))
+		}
+		t = p.at(p.now.AddDate(0, 0, days+7), hour, minute)
+	}
+	return t, nil
+}
+
+// at returns day's date at hour:minute in the parser's location, recording
+// the warning if a daylight saving time change moved it
+func (p *timeParser) at(day time.Time, hour, minute int) time.Time {
+	t, warning := dateIn(day.Year(), day.Month(), day.Day(), hour, minute, p.now.Location())
+	p.warning = warning
+	return t
+}
+
+// TestNaturalTime checks each kind of natural time expression relative to
+// Monday 2026-06-15 10:00 UTC
+func TestNaturalTime() error {
+	log.Println("Testing natural time parsing...")
+
+	tokyo, err := time.LoadLocation("Asia/Tokyo")
+	if err != nil {
+		return fmt.Errorf("failed to load Asia/Tokyo: %w", err)
+	}
+	monday := time.Date(2026, 6, 15, 10, 0, 0, 0, time.UTC)
+	saturday := time.Date(2026, 6, 20, 10, 0, 0, 0, time.UTC)
+	day := func(d, hour, minute, sec int) time.Time {
+		return time.Date(2026, 6, d, hour, minute, sec, 0, time.UTC)
+	}
+
+	cases := []struct {
+		expr string
+		now  time.Time
+		want time.Time
+	}{
+		// Relative durations
+		{"in 2 hours", monday, day(15, 12, 0, 0)},
+		{"in 30 minutes", monday, day(15, 10, 30, 0)},
+		{"in 45 secs", monday, day(15, 10, 0, 45)},
+		{"in an hour", monday, day(15, 11, 0, 0)},
+		{"in 1 day", monday, day(16, 10, 0, 0)},
+		{"in a week", monday, day(22, 10, 0, 0)},
+		// Day references
+		{"tomorrow", monday, day(16, 9, 0, 0)},
+		{"friday", monday, day(19, 9, 0, 0)},
+		{"next friday", monday, day(26, 9, 0, 0)},
+		{"next sunday", monday, day(28, 9, 0, 0)},
+		// "next Monday" on a Monday is a week away, not today
+		{"next monday", monday, day(22, 9, 0, 0)},
+		{"next monday", saturday, day(22, 9, 0, 0)},
+		{"next sat", saturday, day(27, 9, 0, 0)},
+		// Time of day, rolling over to tomorrow once passed
+		{"at 3pm", monday, day(15, 15, 0, 0)},
+		{"at 15:00", monday, day(15, 15, 0, 0)},
+		{"15:30", monday, day(15, 15, 30, 0)},
+		{"9am", monday, day(16, 9, 0, 0)},
+		{"at 10:00", monday, day(16, 10, 0, 0)},
+		{"at noon", monday, day(15, 12, 0, 0)},
+		{"midnight", monday, day(16, 0, 0, 0)},
+		{"12am", monday, day(16, 0, 0, 0)},
+		{"12pm", monday, day(15, 12, 0, 0)},
+		// Combinations
+		{"tomorrow at 3pm", monday, day(16, 15, 0, 0)},
+		{"Tomorrow at 3PM", monday, day(16, 15, 0, 0)},
+		{"tomorrow, at 8:15am", monday, day(16, 8, 15, 0)},
+		{"today at 11am", monday, day(15, 11, 0, 0)},
+		{"next Monday at 9:00", monday, day(22, 9, 0, 0)},
+		{"on friday at 5pm", monday, day(19, 17, 0, 0)},
+		{"3pm tomorrow", monday, day(16, 15, 0, 0)},
+		{"3pm on friday", monday, day(19, 15, 0, 0)},
+		// A bare weekday naming today keeps today until the time passes
+		{"monday at 11:00", monday, day(15, 11, 0, 0)},
+		{"monday at 9:00", monday, day(22, 9, 0, 0)},
+		// Times are read in the location of now
+		{"tomorrow at 9am", time.Date(2026, 6, 15, 10, 0, 0, 0, tokyo), day(16, 0, 0, 0)},
+	}
+	for _, c := range cases {
+		got, _, err := parseNaturalTimeAt(c.expr, c.now)
+		if err != nil {
+			return fmt.Errorf("%q from %v: %w", c.expr, c.now, err)
+		}
+		if !got.Equal(c.want) {
+			return fmt.Errorf("%q from %v: expected %v, got %v", c.expr, c.now, c.want, got.UTC())
+		}
+	}
+	log.Printf("✓ %d expressions parsed", len(cases))
+
+	for _, expr := range []string{
+		"",
+		"in two hours",
+		"in 5 fortnights",
+		"at 25:00",
+		"at 13pm",
+		"at 9:75",
+		"next someday",
+		"tomorrow at",
+		"tomorrow at 3pm please",
+		"today at 9am",
+	} {
+		if _, _, err := parseNaturalTimeAt(expr, monday); err == nil {
+			return fmt.Errorf("expected %q to be rejected", expr)
+		}
+	}
+	log.Println("✓ Invalid and past expressions rejected")
+
+	return nil
+}
+
+// TestDSTReminders checks reminders around the America/New_York daylight
+// saving time changes of 2026: clocks go forward at 02:00 on March 8 and
+// back at 02:00 on November 1
+func TestDSTReminders() error {
+	log.Println("Testing reminders around DST changes...")
+
+	ny, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		return fmt.Errorf("failed to load America/New_York: %w", err)
+	}
+	springEve := time.Date(2026, 3, 7, 20, 0, 0, 0, ny)
+	fallEve := time.Date(2026, 10, 31, 20, 0, 0, 0, ny)
+
+	cases := []struct {
+		expr    string
+		now     time.Time
+		want    time.Time
+		warning bool
+	}{
+		// 02:30 does not exist on March 8 and moves to 03:00 EDT
+		{"tomorrow at 2:30", springEve, time.Date(2026, 3, 8, 7, 0, 0, 0, time.UTC), true},
+		{"2:30am", springEve, time.Date(2026, 3, 8, 7, 0, 0, 0, time.UTC), true},
+		{"tomorrow at 3:30", springEve, time.Date(2026, 3, 8, 7, 30, 0, 0, time.UTC), false},
+		{"tomorrow at 1:30", springEve, time.Date(2026, 3, 8, 6, 30, 0, 0, time.UTC), false},
+		{"in 8 hours", springEve, time.Date(2026, 3, 8, 9, 0, 0, 0, time.UTC), false},
+		// 01:30 occurs twice on November 1 and resolves to 01:30 EDT
+		{"tomorrow at 1:30", fallEve, time.Date(2026, 11, 1, 5, 30, 0, 0, time.UTC), false},
+		{"tomorrow at 2:30", fallEve, time.Date(2026, 11, 1, 7, 30, 0, 0, time.UTC), false},
+		{"next monday at 9:00", fallEve, time.Date(2026, 11, 2, 14, 0, 0, 0, time.UTC), false},
+		// "next friday" on a Monday is the Friday of the following week
+		{"next friday", time.Date(2026, 11, 2, 8, 0, 0, 0, ny), time.Date(2026, 11, 13, 14, 0, 0, 0, time.UTC), false},
+		{"friday", time.Date(2026, 11, 2, 8, 0, 0, 0, ny), time.Date(2026, 11, 6, 14, 0, 0, 0, time.UTC), false},
+	}
+	for _, c := range cases {
+		got, warning, err := parseNaturalTimeAt(c.expr, c.now)
+		if err != nil {
+			return fmt.Errorf("%q: %w", c.expr, err)
+		}
+		if !got.Equal(c.want) || (warning != "") != c.warning {
+			return fmt.Errorf("%q from %v: expected %v (warning %v), got %v (warning %q)",
+				c.expr, c.now, c.want, c.warning, got.UTC(), warning)
+		}
+	}
+	log.Println("✓ Skipped times moved to the end of the DST gap, repeated times resolved to the first")
+
+	// A time that has already passed today is rejected rather than
+	// scheduled in the past
+	morning := time.Date(2026, 7, 1, 10, 0, 0, 0, ny)
+	for _, expr := range []string{"today at 9am", "9:00 today"} {
+		if _, _, err := parseNaturalTimeAt(expr, morning); !errors.Is(err, errPastTime) {
+			return fmt.Errorf("%q at 10:00: expected a past time error, got %v", expr, err)
+		}
+	}
+	if got, _, err := parseNaturalTimeAt("today at 11am", morning); err != nil || !got.Equal(time.Date(2026, 7, 1, 15, 0, 0, 0, time.UTC)) {
+		return fmt.Errorf("\"today at 11am\" at 10:00: expected 15:00 UTC, got %v (%v)", got, err)
+	}
+	log.Println("✓ Past times today rejected, \"next <weekday>\" resolved to the following week")
+
+	dbPath, cleanup, err := testDBPath("test_dst_reminders.db")
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+	scheduler, err := NewScheduler(dbPath)
+	if err != nil {
+		return fmt.Errorf("failed to create scheduler: %w", err)
+	}
+	defer scheduler.Stop()
+
+	if err := scheduler.SetDefaultTimezone("Mars/Olympus_Mons"); err == nil {
+		return fmt.Errorf("expected invalid timezone to be rejected")
+	}
+	if err := scheduler.SetDefaultTimezone("America/New_York"); err != nil {
+		return err
+	}
+
+	t, warning, err := scheduler.ParseTime("2026-03-08 02:30", "")
+	if err != nil || !t.Equal(time.Date(2026, 3, 8, 7, 0, 0, 0, time.UTC)) || warning == "" {
+		return fmt.Errorf("expected 2026-03-08 02:30 to move to 03:00 EDT with a warning, got %v %q (%v)", t, warning, err)
+	}
+	t, warning, err = scheduler.ParseTime("2026-07-01 09:00", "")
+	if err != nil || !t.Equal(time.Date(2026, 7, 1, 13, 0, 0, 0, time.UTC)) || warning != "" {
+		return fmt.Errorf("expected 2026-07-01 09:00 in EDT, got %v %q (%v)", t, warning, err)
+	}
+
+	id, _, err := scheduler.AddReminder("telegram:123", "call home", "tomorrow at 9:00")
+	if err != nil {
+		return err
+	}
+	task, err := scheduler.GetTask(id)
+	if err != nil || task == nil {
+		return fmt.Errorf("reminder not found: %v", err)
+	}
+	if next := task.NextRun.In(ny); next.Hour() != 9 || next.Minute() != 0 {
+		return fmt.Errorf("expected reminder at 9:00 New York time, got %v", next)
+	}
+	log.Println("✓ Reminders scheduled in the configured timezone")
+
+	return nil
+}
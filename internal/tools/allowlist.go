@@ -0,0 +1,239 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+)
+
+// hostPattern is a compiled allow/deny list entry: an exact hostname, a
+// left-side wildcard ("*.example.com") or a CIDR range
+type hostPattern struct {
+	host   string
+	suffix string
+	cidr   *net.IPNet
+}
+
+func (p hostPattern) matches(host string, ip net.IP) bool {
+	switch {
+	case p.cidr != nil:
+		return ip != nil && p.cidr.Contains(ip)
+	case p.suffix != "":
+		return strings.HasSuffix(host, p.suffix)
+	default:
+		return host == p.host
+	}
+}
+
+// compileHostPattern validates and compiles a pattern. Only a single
+// leading "*." wildcard is accepted, so "*.openai.com" is valid but
+// "api.*" or "a*.com" are not.
+func compileHostPattern(pattern string) (hostPattern, error) {
+	pattern = strings.ToLower(strings.TrimSpace(pattern))
+	if pattern == "" {
+		return hostPattern{}, fmt.Errorf("empty domain pattern")
+	}
+
+	if strings.Contains(pattern, "/") {
+		_, cidr, err := net.ParseCIDR(pattern)
+		if err != nil {
+			return hostPattern{}, fmt.Errorf("invalid CIDR pattern %q: %w", pattern, err)
+		}
+		return hostPattern{cidr: cidr}, nil
+	}
+
+	if strings.HasPrefix(pattern, "*.") {
+		rest := pattern[1:]
+		if strings.Contains(rest, "*") || len(rest) < 2 || !strings.Contains(rest[1:], ".") {
+			return hostPattern{}, fmt.Errorf("invalid wildcard pattern %q", pattern)
+		}
+		return hostPattern{suffix: rest}, nil
+	}
+
+	if strings.Contains(pattern, "*") {
+		return hostPattern{}, fmt.Errorf("invalid domain pattern %q: only left-side wildcards (*.example.com) are allowed", pattern)
+	}
+
+	return hostPattern{host: strings.TrimSuffix(pattern, ".")}, nil
+}
+
+// AllowList matches URLs against allowed and denied host patterns. An
+// empty allow list allows every host not otherwise blocked. Denied
+// patterns take precedence over allowed ones, and private, loopback and
+// link-local addresses are blocked when BlockPrivateIPs is set.
+type AllowList struct {
+	allow []hostPattern
+	deny  []hostPattern
+
+	// BlockPrivateIPs blocks private, loopback and link-local IP addresses
+	// and localhost (default true)
+	BlockPrivateIPs bool
+}
+
+// CompileAllowList validates and compiles allowed host patterns
+func CompileAllowList(patterns []string) (*AllowList, error) {
+	allow, err := compileHostPatterns(patterns)
+	if err != nil {
+		return nil, err
+	}
+
+	return &AllowList{
+		allow:           allow,
+		BlockPrivateIPs: true,
+	}, nil
+}
+
+// SetDenyList validates and compiles denied host patterns, replacing any
+// previous deny list
+func (l *AllowList) SetDenyList(patterns []string) error {
+	deny, err := compileHostPatterns(patterns)
+	if err != nil {
+		return err
+	}
+	l.deny = deny
+	return nil
+}
+
+func compileHostPatterns(patterns []string) ([]hostPattern, error) {
+	compiled := make([]hostPattern, 0, len(patterns))
+	for _, pattern := range patterns {
+		p, err := compileHostPattern(pattern)
+		if err != nil {
+			return nil, err
+		}
+		compiled = append(compiled, p)
+	}
+	return compiled, nil
+}
+
+// Matches reports whether the host of rawURL is allowed. rawURL may also
+// be a bare hostname.
+func (l *AllowList) Matches(rawURL string) bool {
+	host := hostFromURL(rawURL)
+	if host == "" {
+		return false
+	}
+	return l.MatchesHost(host)
+}
+
+// MatchesHost reports whether a hostname or IP address is allowed
+func (l *AllowList) MatchesHost(host string) bool {
+	host = strings.TrimSuffix(strings.ToLower(host), ".")
+	ip := net.ParseIP(host)
+
+	if l.BlockPrivateIPs && isPrivateHost(host, ip) {
+		return false
+	}
+
+	for _, p := range l.deny {
+		if p.matches(host, ip) {
+			return false
+		}
+	}
+
+	if len(l.allow) == 0 {
+		return true
+	}
+
+	for _, p := range l.allow {
+		if p.matches(host, ip) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// hostFromURL extracts the hostname from a URL or bare host[:port]
+func hostFromURL(rawURL string) string {
+	if !strings.Contains(rawURL, "://") {
+		rawURL = "//" + rawURL
+	}
+
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return ""
+	}
+	return u.Hostname()
+}
+
+// isPrivateHost reports whether host refers to a non-public address
+func isPrivateHost(host string, ip net.IP) bool {
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		return true
+	}
+	if ip == nil {
+		return false
+	}
+	return ip.IsPrivate() || ip.IsLoopback() || ip.IsLinkLocalUnicast() ||
+		ip.IsLinkLocalMulticast() || ip.IsUnspecified()
+}
+
+// TestAllowList checks wildcard matching, deny-list precedence and
+// private address blocking
+func TestAllowList() error {
+	fmt.Println("Testing allow list...")
+
+	for _, pattern := range []string{"api.*", "a*.com", "*.com", "*", ""} {
+		if _, err := CompileAllowList([]string{pattern}); err == nil {
+			return fmt.Errorf("expected pattern %q to be rejected", pattern)
+		}
+	}
+
+	list, err := CompileAllowList([]string{"*.openai.com", "example.org"})
+	if err != nil {
+		return err
+	}
+	for url, allowed := range map[string]bool{
+		"https://api.openai.com/v1/models": true,
+		"cdn.openai.com":                   true,
+		"https://openai.com/":              false,
+		"https://evilopenai.com/":          false,
+		"http://EXAMPLE.org:8080/path":     true,
+		"https://www.example.org/":         false,
+		"https://example.com/":             false,
+	} {
+		if list.Matches(url) != allowed {
+			return fmt.Errorf("%s: expected allowed %v", url, allowed)
+		}
+	}
+	fmt.Println("✓ Wildcards match subdomains only")
+
+	if err := list.SetDenyList([]string{"cdn.openai.com", "203.0.113.0/24"}); err != nil {
+		return err
+	}
+	if list.Matches("https://cdn.openai.com/") || !list.Matches("https://api.openai.com/") {
+		return fmt.Errorf("expected the deny list to override the allow list for cdn.openai.com only")
+	}
+	open, err := CompileAllowList(nil)
+	if err != nil {
+		return err
+	}
+	if err := open.SetDenyList([]string{"*.tracker.net", "203.0.113.0/24"}); err != nil {
+		return err
+	}
+	if open.Matches("ads.tracker.net") || open.Matches("http://203.0.113.9/") || !open.Matches("https://golang.org/") {
+		return fmt.Errorf("expected denied hosts and ranges blocked and others allowed")
+	}
+	if err := open.SetDenyList([]string{"api.*"}); err == nil {
+		return fmt.Errorf("expected invalid deny pattern to be rejected")
+	}
+	fmt.Println("✓ Deny list overrides the allow list")
+
+	for _, host := range []string{"10.1.2.3", "172.16.0.1", "192.168.1.1", "127.0.0.1", "[::1]", "169.254.169.254", "localhost", "0.0.0.0"} {
+		if open.Matches("http://" + host + "/") {
+			return fmt.Errorf("expected private address %s to be blocked", host)
+		}
+	}
+	if !open.Matches("http://8.8.8.8/") || !open.Matches("http://172.32.0.1/") {
+		return fmt.Errorf("expected public addresses to be allowed")
+	}
+	open.BlockPrivateIPs = false
+	if !open.Matches("http://192.168.1.1/") {
+		return fmt.Errorf("expected private addresses allowed when not blocked")
+	}
+	fmt.Println("✓ Private addresses blocked by default")
+
+	return nil
+}
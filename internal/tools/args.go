@@ -0,0 +1,273 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Tool parameter types accepted in ToolParam.Type. An empty type is a
+// string.
+const (
+	ParamString = "string"
+	ParamInt    = "int"
+	ParamFloat  = "float"
+	ParamBool   = "bool"
+	ParamJSON   = "json"
+)
+
+// ParameterizedTool is a tool that declares its parameters. The registry
+// converts its arguments to the declared types before executing it.
+type ParameterizedTool interface {
+	Tool
+	Params() []ToolParam
+}
+
+// ArgTypeError is returned when an argument cannot be converted to the
+// type its parameter declares
+type ArgTypeError struct {
+	Param string
+	Type  string
+	Value string
+	Err   error
+}
+
+func (e *ArgTypeError) Error() string {
+	return fmt.Sprintf("invalid %s value for parameter %s: %q", e.Type, e.Param, e.Value)
+}
+
+func (e *ArgTypeError) Unwrap() error {
+	return e.Err
+}
+
+// CoerceArgs converts the raw string arguments of a tool call to the types
+// declared in schema: int, float64, bool or, for "json", the unmarshaled
+// value. Undeclared and string parameters are kept as strings, and empty
+// values of other types are left out as if not given.
+func CoerceArgs(schema []ToolParam, raw map[string]string) (map[string]interface{}, error) {
+	paramTypes := make(map[string]string, len(schema))
+	for _, param := range schema {
+		paramTypes[param.Name] = param.Type
+	}
+
+	args := make(map[string]interface{}, len(raw))
+	for name, value := range raw {
+		paramType := paramTypes[name]
+		if paramType == "" || paramType == ParamString {
+			args[name] = value
+			continue
+		}
+		if strings.TrimSpace(value) == "" {
+			continue
+		}
+
+		coerced, err := coerceArg(paramType, strings.TrimSpace(value))
+		if err != nil {
+			return nil, &ArgTypeError{Param: name, Type: paramType, Value: value, Err: err}
+		}
+		args[name] = coerced
+	}
+	return args, nil
+}
+
+// coerceToolArgs converts raw arguments to the types declared by tool,
+// if it declares its parameters
+func coerceToolArgs(tool Tool, raw map[string]string) (map[string]interface{}, error) {
+	var schema []ToolParam
+	if parameterized, ok := tool.(ParameterizedTool); ok {
+		schema = parameterized.Params()
+	}
+	return CoerceArgs(schema, raw)
+}
+
+// jsonSchemaTypes maps parameter types to JSON Schema types. "json"
+// parameters accept any JSON value and have no type.
+var jsonSchemaTypes = map[string]string{
+	"":          "string",
+	ParamString: "string",
+	ParamInt:    "integer",
+	ParamFloat:  "number",
+	ParamBool:   "boolean",
+}
+
+// ParamsSchema returns the JSON Schema of arguments with the given
+// parameters, as offered to the model for function calling and checked
+// by ToolRegistry.Validate
+func ParamsSchema(params []ToolParam) map[string]interface{} {
+	properties := make(map[string]interface{})
+	var required []string
+	for _, param := range params {
+		property := make(map[string]interface{})
+		if schemaType, ok := jsonSchemaTypes[param.Type]; ok {
+			property["type"] = schemaType
+		}
+		if param.Description != "" {
+			property["description"] = param.Description
+		}
+		properties[param.Name] = property
+
+		if param.Required {
+			required = append(required, param.Name)
+		}
+	}
+
+	// Every tool accepts the pagination arguments
+	properties[ArgPage] = map[string]interface{}{"type": "integer", "description": "Page of a long result to return (default 1)"}
+	properties[ArgPageSize] = map[string]interface{}{"type": "integer", "description": "Page size in bytes for long results"}
+	properties[ArgNextPageToken] = map[string]interface{}{"type": "string", "description": "With operation \"paginate\", the token of the next page of a long result"}
+
+	schema := map[string]interface{}{
+		"type":       "object",
+		"properties": properties,
+	}
+	if len(required) > 0 {
+		schema["required"] = required
+	}
+	return schema
+}
+
+// coerceArg converts a non-empty value to paramType
+func coerceArg(paramType, value string) (interface{}, error) {
+	switch paramType {
+	case ParamInt:
+		return strconv.Atoi(value)
+	case ParamFloat:
+		return strconv.ParseFloat(value, 64)
+	case ParamBool:
+		switch strings.ToLower(value) {
+		case "true", "1", "yes":
+			return true, nil
+		case "false", "0", "no":
+			return false, nil
+		}
+		return nil, fmt.Errorf("expected true/false, 1/0 or yes/no")
+	case ParamJSON:
+		var decoded interface{}
+		if err := json.Unmarshal([]byte(value), &decoded); err != nil {
+			return nil, err
+		}
+		return decoded, nil
+	default:
+		return nil, fmt.Errorf("unknown parameter type: %s", paramType)
+	}
+}
+
+// stringArg returns a string argument, or "" if it is not set
+func stringArg(args map[string]interface{}, name string) string {
+	value, _ := args[name].(string)
+	return value
+}
+
+// boolArg returns a bool argument, or false if it is not set
+func boolArg(args map[string]interface{}, name string) bool {
+	value, _ := args[name].(bool)
+	return value
+}
+
+// positiveIntArg returns a positive int argument, or defaultValue if it is
+// not set
+func positiveIntArg(args map[string]interface{}, name string, defaultValue int) (int, error) {
+	value, ok := args[name]
+	if !ok {
+		return defaultValue, nil
+	}
+	n, ok := value.(int)
+	if !ok || n <= 0 {
+		return 0, fmt.Errorf("invalid %s: %v", name, value)
+	}
+	return n, nil
+}
+
+// formatArg formats an argument value as text: strings as is, JSON values
+// as JSON
+func formatArg(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case int, float64, bool:
+		return fmt.Sprint(v)
+	default:
+		data, err := json.Marshal(v)
+		if err != nil {
+			return fmt.Sprint(v)
+		}
+		return string(data)
+	}
+}
+
+// TestCoerceArgs checks the conversion of each parameter type, including
+// invalid values
+func TestCoerceArgs() error {
+	fmt.Println("Testing argument coercion...")
+
+	tests := []struct {
+		paramType string
+		value     string
+		want      interface{}
+		wantErr   bool
+	}{
+		{ParamString, " text ", " text ", false},
+		{"", "42", "42", false},
+		{ParamInt, "42", 42, false},
+		{ParamInt, " -7 ", -7, false},
+		{ParamInt, "4.2", nil, true},
+		{ParamInt, "many", nil, true},
+		{ParamFloat, "2.5", 2.5, false},
+		{ParamFloat, "1e3", 1000.0, false},
+		{ParamFloat, "2,5", nil, true},
+		{ParamBool, "true", true, false},
+		{ParamBool, "FALSE", false, false},
+		{ParamBool, "1", true, false},
+		{ParamBool, "0", false, false},
+		{ParamBool, "yes", true, false},
+		{ParamBool, "no", false, false},
+		{ParamBool, "maybe", nil, true},
+		{ParamJSON, `{"a":1}`, `{"a":1}`, false},
+		{ParamJSON, `[1,"two"]`, `[1,"two"]`, false},
+		{ParamJSON, `{"a":`, nil, true},
+		{"date", "2024-01-01", nil, true},
+	}
+
+	for _, tt := range tests {
+		schema := []ToolParam{{Name: "arg", Type: tt.paramType}}
+		args, err := CoerceArgs(schema, map[string]string{"arg": tt.value})
+		if tt.wantErr {
+			var typeErr *ArgTypeError
+			if !errors.As(err, &typeErr) || typeErr.Param != "arg" {
+				return fmt.Errorf("%s %q: expected ArgTypeError, got %v", tt.paramType, tt.value, err)
+			}
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("%s %q: unexpected error: %v", tt.paramType, tt.value, err)
+		}
+
+		got := args["arg"]
+		if tt.paramType == ParamJSON {
+			got = formatArg(got)
+		}
+		if got != tt.want {
+			return fmt.Errorf("%s %q: expected %#v, got %#v", tt.paramType, tt.value, tt.want, got)
+		}
+	}
+	fmt.Printf("✓ %d conversions checked\n", len(tests))
+
+	args, err := CoerceArgs([]ToolParam{{Name: "limit", Type: ParamInt}}, map[string]string{"limit": ""})
+	if err != nil || len(args) != 0 {
+		return fmt.Errorf("expected empty int to be left out, got %v (%v)", args, err)
+	}
+	fmt.Println("✓ Empty values left out")
+
+	schema := NewCalculatorTool().Schema()
+	expression, _ := schema["properties"].(map[string]interface{})["expression"].(map[string]interface{})
+	if expression["type"] != "string" || fmt.Sprint(schema["required"]) != "[expression]" {
+		return fmt.Errorf("unexpected calculator schema: %v", schema)
+	}
+	fmt.Println("✓ Tool schema built from parameters")
+
+	return nil
+}
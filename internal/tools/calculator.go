@@ -0,0 +1,414 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// calculatorFunctions are the functions an expression may call
+var calculatorFunctions = map[string]func(float64) (float64, error){
+	"sqrt": func(x float64) (float64, error) {
+		if x < 0 {
+			return 0, fmt.Errorf("sqrt of negative number %g", x)
+		}
+		return math.Sqrt(x), nil
+	},
+	"abs":   func(x float64) (float64, error) { return math.Abs(x), nil },
+	"floor": func(x float64) (float64, error) { return math.Floor(x), nil },
+	"ceil":  func(x float64) (float64, error) { return math.Ceil(x), nil },
+	"round": func(x float64) (float64, error) { return math.Round(x), nil },
+	"log": func(x float64) (float64, error) {
+		if x <= 0 {
+			return 0, fmt.Errorf("log of non-positive number %g", x)
+		}
+		return math.Log(x), nil
+	},
+	"sin": func(x float64) (float64, error) { return math.Sin(x), nil },
+	"cos": func(x float64) (float64, error) { return math.Cos(x), nil },
+}
+
+// calculatorConstants are the variables defined in every expression
+var calculatorConstants = map[string]float64{
+	"pi": math.Pi,
+	"e":  math.E,
+}
+
+// Evaluate computes a calculator expression. It supports + - * / % and
+// ** (power, right-associative), parentheses and the functions in
+// calculatorFunctions. Statements separated by ";" may assign variables
+// ("x = 3; x * 2"); the value of the last statement is returned.
+func Evaluate(expression string) (float64, error) {
+	variables := make(map[string]float64, len(calculatorConstants))
+	for name, value := range calculatorConstants {
+		variables[name] = value
+	}
+
+	var result float64
+	evaluated := false
+	for _, statement := range strings.Split(expression, ";") {
+		if strings.TrimSpace(statement) == "" {
+			continue
+		}
+
+		tokens, err := tokenizeExpression(statement)
+		if err != nil {
+			return 0, err
+		}
+
+		// An assignment is an identifier followed by "="
+		target := ""
+		if len(tokens) >= 2 && tokens[0].kind == tokenIdent && tokens[1].text == "=" {
+			target = tokens[0].text
+			if _, isFunc := calculatorFunctions[target]; isFunc {
+				return 0, fmt.Errorf("cannot assign to function %s", target)
+			}
+			tokens = tokens[2:]
+		}
+
+		p := &expressionParser{tokens: tokens, variables: variables}
+		value, err := p.parseExpression()
+		if err != nil {
+			return 0, err
+		}
+		if !p.done() {
+			return 0, fmt.Errorf("unexpected %q", p.peek().text)
+		}
+		if math.IsNaN(value) || math.IsInf(value, 0) {
+			return 0, fmt.Errorf("result is not a finite number")
+		}
+
+		if target != "" {
+			variables[target] = value
+		}
+		result = value
+		evaluated = true
+	}
+
+	if !evaluated {
+		return 0, fmt.Errorf("empty expression")
+	}
+	return result, nil
+}
+
+type tokenKind int
+
+const (
+	tokenNumber tokenKind = iota
+	tokenIdent
+	tokenOperator
+)
+
+// expressionToken is a number, an identifier, or an operator or
+// parenthesis
+type expressionToken struct {
+	kind  tokenKind
+	text  string
+	value float64
+}
+
+// tokenizeExpression splits an expression into tokens
+func tokenizeExpression(expression string) ([]expressionToken, error) {
+	var tokens []expressionToken
+	runes := []rune(expression)
+
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case unicode.IsDigit(r) || r == '.':
+			start := i
+			for i < len(runes) && (unicode.IsDigit(runes[i]) || runes[i] == '.') {
+				i++
+			}
+			// Exponent, as in 1.5e-3
+			if i < len(runes) && (runes[i] == 'e' || runes[i] == 'E') {
+				j := i + 1
+				if j < len(runes) && (runes[j] == '+' || runes[j] == '-') {
+					j++
+				}
+				if j < len(runes) && unicode.IsDigit(runes[j]) {
+					i = j
+					for i < len(runes) && unicode.IsDigit(runes[i]) {
+						i++
+					}
+				}
+			}
+			text := string(runes[start:i])
+			value, err := strconv.ParseFloat(text, 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid number %q", text)
+			}
+			tokens = append(tokens, expressionToken{kind: tokenNumber, text: text, value: value})
+		case unicode.IsLetter(r) || r == '_':
+			start := i
+			for i < len(runes) && (unicode.IsLetter(runes[i]) || unicode.IsDigit(runes[i]) || runes[i] == '_') {
+				i++
+			}
+			tokens = append(tokens, expressionToken{kind: tokenIdent, text: string(runes[start:i])})
+		case r == '*' && i+1 < len(runes) && runes[i+1] == '*':
+			tokens = append(tokens, expressionToken{kind: tokenOperator, text: "**"})
+			i += 2
+		case strings.ContainsRune("+-*/%()=", r):
+			tokens = append(tokens, expressionToken{kind: tokenOperator, text: string(r)})
+			i++
+		default:
+			return nil, fmt.Errorf("unexpected character %q", r)
+		}
+	}
+
+	return tokens, nil
+}
+
+// expressionParser is a recursive-descent parser that evaluates tokens
+// as it parses them. From lowest to highest precedence:
+//
+//	expression = term { ("+" | "-") term }
+//	term       = unary { ("*" | "/" | "%") unary }
+//	unary      = ("+" | "-") unary | power
+//	power      = primary [ "**" unary ]
+//	primary    = number | variable | function "(" expression ")" | "(" expression ")"
+type expressionParser struct {
+	tokens    []expressionToken
+	pos       int
+	variables map[string]float64
+}
+
+func (p *expressionParser) done() bool {
+	return p.pos >= len(p.tokens)
+}
+
+func (p *expressionParser) peek() expressionToken {
+	if p.done() {
+		return expressionToken{}
+	}
+	return p.tokens[p.pos]
+}
+
+// accept consumes the next token if it is the operator op
+func (p *expressionParser) accept(op string) bool {
+	if !p.done() && p.tokens[p.pos].kind == tokenOperator && p.tokens[p.pos].text == op {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *expressionParser) parseExpression() (float64, error) {
+	left, err := p.parseTerm()
+	if err != nil {
+		return 0, err
+	}
+
+	for {
+		switch {
+		case p.accept("+"):
+			right, err := p.parseTerm()
+			if err != nil {
+				return 0, err
+			}
+			left += right
+		case p.accept("-"):
+			right, err := p.parseTerm()
+			if err != nil {
+				return 0, err
+			}
+			left -= right
+		default:
+			return left, nil
+		}
+	}
+}
+
+func (p *expressionParser) parseTerm() (float64, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return 0, err
+	}
+
+	for {
+		var op string
+		switch {
+		case p.accept("*"):
+			op = "*"
+		case p.accept("/"):
+			op = "/"
+		case p.accept("%"):
+			op = "%"
+		default:
+			return left, nil
+		}
+
+		right, err := p.parseUnary()
+		if err != nil {
+			return 0, err
+		}
+
+		switch op {
+		case "*":
+			left *= right
+		case "/":
+			if right == 0 {
+				return 0, fmt.Errorf("division by zero")
+			}
+			left /= right
+		case "%":
+			if right == 0 {
+				return 0, fmt.Errorf("modulo by zero")
+			}
+			left = math.Mod(left, right)
+		}
+	}
+}
+
+func (p *expressionParser) parseUnary() (float64, error) {
+	if p.accept("-") {
+		value, err := p.parseUnary()
+		return -value, err
+	}
+	if p.accept("+") {
+		return p.parseUnary()
+	}
+	return p.parsePower()
+}
+
+func (p *expressionParser) parsePower() (float64, error) {
+	base, err := p.parsePrimary()
+	if err != nil {
+		return 0, err
+	}
+	if !p.accept("**") {
+		return base, nil
+	}
+
+	// The exponent may itself be negated or raised: 2 ** -1, 2 ** 3 ** 2
+	exponent, err := p.parseUnary()
+	if err != nil {
+		return 0, err
+	}
+	return math.Pow(base, exponent), nil
+}
+
+func (p *expressionParser) parsePrimary() (float64, error) {
+	if p.done() {
+		return 0, fmt.Errorf("unexpected end of expression")
+	}
+	token := p.tokens[p.pos]
+	p.pos++
+
+	switch {
+	case token.kind == tokenNumber:
+		return token.value, nil
+
+	case token.kind == tokenIdent:
+		if fn, ok := calculatorFunctions[token.text]; ok {
+			if !p.accept("(") {
+				return 0, fmt.Errorf("expected ( after %s", token.text)
+			}
+			arg, err := p.parseExpression()
+			if err != nil {
+				return 0, err
+			}
+			if !p.accept(")") {
+				return 0, fmt.Errorf("expected ) to close %s(", token.text)
+			}
+			return fn(arg)
+		}
+		value, ok := p.variables[token.text]
+		if !ok {
+			return 0, fmt.Errorf("undefined variable %s", token.text)
+		}
+		return value, nil
+
+	case token.text == "(":
+		value, err := p.parseExpression()
+		if err != nil {
+			return 0, err
+		}
+		if !p.accept(")") {
+			return 0, fmt.Errorf("expected )")
+		}
+		return value, nil
+	}
+
+	return 0, fmt.Errorf("unexpected %q", token.text)
+}
+
+// formatNumber formats a calculator result without trailing zeros
+func formatNumber(value float64) string {
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
+
+// TestCalculator checks operator precedence, parentheses, functions,
+// variables and errors
+func TestCalculator() error {
+	fmt.Println("Testing calculator...")
+
+	tests := []struct {
+		expression string
+		want       float64
+	}{
+		{"1 + 2 * 3", 7},
+		{"(1 + 2) * 3", 9},
+		{"10 - 4 - 3", 3},
+		{"2 * (3 + (4 - 1) * 2)", 18},
+		{"((2))", 2},
+		{"7 % 4 + 8 / 2", 7},
+		{"2 ** 3 ** 2", 512},
+		{"-2 ** 2", -4},
+		{"2 ** -1", 0.5},
+		{"1.5e2 + .5", 150.5},
+		{"sqrt(16)", 4},
+		{"abs(-3.5)", 3.5},
+		{"floor(2.7)", 2},
+		{"ceil(2.1)", 3},
+		{"round(2.5)", 3},
+		{"log(e)", 1},
+		{"sin(0)", 0},
+		{"cos(0)", 1},
+		{"sqrt(abs(-9)) * 2", 6},
+		{"x = 3; y = x * 2; x + y", 9},
+	}
+	for _, test := range tests {
+		got, err := Evaluate(test.expression)
+		if err != nil {
+			return fmt.Errorf("%s: %v", test.expression, err)
+		}
+		if math.Abs(got-test.want) > 1e-9 {
+			return fmt.Errorf("%s: expected %g, got %g", test.expression, test.want, got)
+		}
+	}
+	fmt.Printf("✓ %d expressions evaluated\n", len(tests))
+
+	for _, expression := range []string{
+		"1 / 0",
+		"5 % 0",
+		"x + 1",
+		"sqrt(-1)",
+		"log(0)",
+		"2 +",
+		"(1 + 2",
+		"1 2",
+		"sqrt 4",
+		"2 $ 3",
+		"sqrt = 2",
+		"",
+	} {
+		if _, err := Evaluate(expression); err == nil {
+			return fmt.Errorf("%q: expected an error", expression)
+		}
+	}
+	fmt.Println("✓ Invalid expressions rejected")
+
+	result, err := NewCalculatorTool().Execute(context.Background(), map[string]interface{}{"expression": "2*3"})
+	if err != nil || result.TextResult != "6" {
+		return fmt.Errorf("expected tool result 6, got %q (%v)", result.TextResult, err)
+	}
+	fmt.Println("✓ Calculator tool returns the result")
+
+	return nil
+}
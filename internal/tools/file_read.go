@@ -0,0 +1,270 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/pmezard/go-difflib/difflib"
+)
+
+// defaultReadGlobMaxBytes caps read_glob output when max_total_bytes is
+// not set
+const defaultReadGlobMaxBytes = 64 * 1024
+
+// readGlob runs the "read_glob" operation: it reads the files matching a
+// glob pattern relative to the base directory, in alphabetical order, and
+// concatenates them under "---\n<filename>\n---\n" headers
+func (t *FileTool) readGlob(absBaseDir string, args map[string]interface{}) (string, error) {
+	pattern := stringArg(args, "pattern")
+	if pattern == "" {
+		return "", fmt.Errorf("pattern is required")
+	}
+
+	maxBytes, err := positiveIntArg(args, "max_total_bytes", defaultReadGlobMaxBytes)
+	if err != nil {
+		return "", err
+	}
+
+	paths, err := filepath.Glob(filepath.Join(absBaseDir, pattern))
+	if err != nil {
+		return "", fmt.Errorf("invalid pattern: %w", err)
+	}
+	sort.Strings(paths)
+
+	var body strings.Builder
+	files, totalBytes := 0, 0
+	truncated := false
+
+	for _, path := range paths {
+		if _, err := resolveInBaseDir(absBaseDir, path); err != nil {
+			return "", err
+		}
+
+		info, err := os.Stat(path)
+		if err != nil || !info.Mode().IsRegular() {
+			continue
+		}
+
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return "", err
+		}
+
+		if totalBytes+len(data) > maxBytes {
+			data = data[:maxBytes-totalBytes]
+			truncated = true
+		}
+
+		name, err := filepath.Rel(absBaseDir, path)
+		if err != nil {
+			name = path
+		}
+
+		fmt.Fprintf(&body, "---\n%s\n---\n", name)
+		body.Write(data)
+		if len(data) > 0 && data[len(data)-1] != '\n' {
+			body.WriteString("\n")
+		}
+
+		files++
+		totalBytes += len(data)
+		if truncated {
+			break
+		}
+	}
+
+	if files == 0 {
+		return "", fmt.Errorf("no files match pattern: %s", pattern)
+	}
+
+	preamble := fmt.Sprintf("Read %d files (%d bytes)", files, totalBytes)
+	if truncated {
+		preamble += fmt.Sprintf(", truncated at max_total_bytes=%d", maxBytes)
+	}
+	return preamble + "\n" + body.String(), nil
+}
+
+// diffFiles runs the "diff" operation: a line-level unified diff of two
+// files relative to the base directory
+func (t *FileTool) diffFiles(absBaseDir string, args map[string]interface{}) (string, error) {
+	nameA, nameB := stringArg(args, "path_a"), stringArg(args, "path_b")
+	if nameA == "" || nameB == "" {
+		return "", fmt.Errorf("path_a and path_b are required")
+	}
+
+	pathA, err := resolveInBaseDir(absBaseDir, filepath.Join(absBaseDir, nameA))
+	if err != nil {
+		return "", err
+	}
+	pathB, err := resolveInBaseDir(absBaseDir, filepath.Join(absBaseDir, nameB))
+	if err != nil {
+		return "", err
+	}
+
+	dataA, err := os.ReadFile(pathA)
+	if err != nil {
+		return "", err
+	}
+	dataB, err := os.ReadFile(pathB)
+	if err != nil {
+		return "", err
+	}
+
+	diff, err := difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+		A:        diffLines(string(dataA)),
+		B:        diffLines(string(dataB)),
+		FromFile: nameA,
+		ToFile:   nameB,
+		Context:  3,
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to diff files: %w", err)
+	}
+
+	if diff == "" {
+		return "(no differences)", nil
+	}
+	return diff, nil
+}
+
+// diffLines splits text into newline-terminated lines for difflib. Unlike
+// difflib.SplitLines it adds no empty line after a final newline, which
+// would be counted in the hunk ranges.
+func diffLines(text string) []string {
+	lines := strings.SplitAfter(text, "\n")
+	if lines[len(lines)-1] == "" {
+		return lines[:len(lines)-1]
+	}
+	lines[len(lines)-1] += "\n"
+	return lines
+}
+
+// resolveInBaseDir resolves symlinks in path and checks that the result
+// lies within the base directory
+func resolveInBaseDir(absBaseDir, path string) (string, error) {
+	realBaseDir, err := filepath.EvalSymlinks(absBaseDir)
+	if err != nil {
+		realBaseDir = absBaseDir
+	}
+
+	realPath, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			return "", err
+		}
+		// Let the caller report the missing file
+		realPath, realBaseDir = filepath.Clean(path), absBaseDir
+	}
+
+	if !isWithinDir(realBaseDir, realPath) {
+		return "", fmt.Errorf("access denied: path outside base directory")
+	}
+	return realPath, nil
+}
+
+// TestFileReadGlob checks glob expansion and max_total_bytes truncation of
+// read_glob, and the diff operation on known file pairs
+func TestFileReadGlob() error {
+	fmt.Println("Testing file read_glob and diff...")
+
+	baseDir, err := os.MkdirTemp("", "quickbot-file-read")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(baseDir)
+
+	files := map[string]string{
+		"src/b.go":  "package b\n",
+		"src/a.go":  "package a\n",
+		"src/c.txt": "not go\n",
+		"one.txt":   "one\ntwo\nthree\n",
+		"two.txt":   "one\n2\nthree\nfour\n",
+		"same.txt":  "one\ntwo\nthree\n",
+		"big/x.txt": strings.Repeat("x", 30),
+		"big/y.txt": strings.Repeat("y", 30),
+		"big/z.txt": strings.Repeat("z", 30),
+	}
+	for name, content := range files {
+		path := filepath.Join(baseDir, "base", name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return err
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			return err
+		}
+	}
+	if err := os.WriteFile(filepath.Join(baseDir, "secret.txt"), []byte("secret\n"), 0644); err != nil {
+		return err
+	}
+	if err := os.Symlink(filepath.Join(baseDir, "secret.txt"), filepath.Join(baseDir, "base", "src", "z.go")); err != nil {
+		return err
+	}
+
+	tool := NewFileTool(filepath.Join(baseDir, "base"))
+	run := func(raw map[string]string) (string, error) {
+		args, err := CoerceArgs(tool.Params(), raw)
+		if err != nil {
+			return "", err
+		}
+		result, err := tool.Execute(context.Background(), args)
+		return result.TextResult, err
+	}
+
+	if _, err := run(map[string]string{"operation": "read_glob", "pattern": "src/*.go"}); err == nil {
+		return fmt.Errorf("expected a symlink out of the base directory to be rejected")
+	}
+	os.Remove(filepath.Join(baseDir, "base", "src", "z.go"))
+
+	output, err := run(map[string]string{"operation": "read_glob", "pattern": "src/*.go"})
+	if err != nil {
+		return err
+	}
+	want := "Read 2 files (20 bytes)\n" +
+		"---\n" + filepath.Join("src", "a.go") + "\n---\npackage a\n" +
+		"---\n" + filepath.Join("src", "b.go") + "\n---\npackage b\n"
+	if output != want {
+		return fmt.Errorf("unexpected read_glob output:\n%s", output)
+	}
+	fmt.Println("✓ Glob expanded in alphabetical order")
+
+	output, err = run(map[string]string{"operation": "read_glob", "pattern": "big/*.txt", "max_total_bytes": "50"})
+	if err != nil {
+		return err
+	}
+	if !strings.HasPrefix(output, "Read 2 files (50 bytes), truncated at max_total_bytes=50\n") ||
+		!strings.HasSuffix(output, strings.Repeat("y", 20)+"\n") || strings.Contains(output, "z.txt") {
+		return fmt.Errorf("expected output truncated after 50 bytes, got:\n%s", output)
+	}
+	fmt.Println("✓ Output truncated at max_total_bytes")
+
+	if _, err := run(map[string]string{"operation": "read_glob", "pattern": "*.md"}); err == nil {
+		return fmt.Errorf("expected an error when no files match")
+	}
+	if _, err := run(map[string]string{"operation": "read_glob", "pattern": "../*.txt"}); err == nil {
+		return fmt.Errorf("expected a pattern outside the base directory to be rejected")
+	}
+	fmt.Println("✓ Empty and outside patterns rejected")
+
+	output, err = run(map[string]string{"operation": "diff", "path_a": "one.txt", "path_b": "two.txt"})
+	if err != nil {
+		return err
+	}
+	want = "--- one.txt\n+++ two.txt\n@@ -1,3 +1,4 @@\n one\n-two\n+2\n three\n+four\n"
+	if output != want {
+		return fmt.Errorf("unexpected diff:\n%s", output)
+	}
+	output, err = run(map[string]string{"operation": "diff", "path_a": "one.txt", "path_b": "same.txt"})
+	if err != nil || output != "(no differences)" {
+		return fmt.Errorf("expected no differences, got %q (%v)", output, err)
+	}
+	if _, err := run(map[string]string{"operation": "diff", "path_a": "one.txt", "path_b": "../secret.txt"}); err == nil {
+		return fmt.Errorf("expected a diff outside the base directory to be rejected")
+	}
+	fmt.Println("✓ Unified diff of known file pairs")
+
+	return nil
+}
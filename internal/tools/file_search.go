@@ -0,0 +1,281 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+const (
+	// defaultGrepMaxResults caps grep results when max_results is not set
+	defaultGrepMaxResults = 100
+
+	// maxGrepLineLength is the longest line the scanner accepts
+	maxGrepLineLength = 1024 * 1024
+)
+
+// errGrepLimit stops the directory walk once max_results is reached
+var errGrepLimit = errors.New("grep result limit reached")
+
+// GrepMatch is a line matching a grep pattern
+type GrepMatch struct {
+	File        string
+	LineNumber  int
+	LineContent string
+}
+
+// String formats the match as "file.go:42: line content"
+func (m GrepMatch) String() string {
+	return fmt.Sprintf("%s:%d: %s", m.File, m.LineNumber, m.LineContent)
+}
+
+// search runs the "grep" and "count" operations. path may be a file or a
+// directory; directories are only descended into when recursive is true.
+func (t *FileTool) search(absBaseDir, absPath string, args map[string]interface{}, countOnly bool) (string, error) {
+	pattern := stringArg(args, "pattern")
+	if pattern == "" {
+		return "", fmt.Errorf("pattern is required")
+	}
+
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return "", fmt.Errorf("invalid pattern: %w", err)
+	}
+
+	maxResults, err := positiveIntArg(args, "max_results", defaultGrepMaxResults)
+	if err != nil {
+		return "", err
+	}
+	if countOnly {
+		maxResults = 0
+	}
+
+	recursive := boolArg(args, "recursive")
+
+	matches, count, err := grepPath(absBaseDir, absPath, re, recursive, maxResults)
+	if err != nil {
+		return "", err
+	}
+
+	if countOnly {
+		return strconv.Itoa(count), nil
+	}
+
+	if len(matches) == 0 {
+		return "(no matches)", nil
+	}
+
+	lines := make([]string, len(matches))
+	for i, match := range matches {
+		lines[i] = match.String()
+	}
+	return strings.Join(lines, "\n"), nil
+}
+
+// grepPath searches the files under root for lines matching re. File
+// names in the matches are relative to baseDir. A maxResults of 0 means
+// unlimited; the total count is returned either way.
+func grepPath(baseDir, root string, re *regexp.Regexp, recursive bool, maxResults int) ([]GrepMatch, int, error) {
+	var matches []GrepMatch
+	count := 0
+
+	realBaseDir, err := filepath.EvalSymlinks(baseDir)
+	if err != nil {
+		realBaseDir = baseDir
+	}
+
+	err = filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if path == root {
+				return err
+			}
+			log.Printf("Warning: Failed to access %s: %v", path, err)
+			return nil
+		}
+
+		if d.IsDir() {
+			if path != root && !recursive {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+
+		// Paths must stay within the base directory, including
+		// through symlinked parents
+		realPath, err := filepath.EvalSymlinks(path)
+		if err != nil || !isWithinDir(realBaseDir, realPath) {
+			return nil
+		}
+
+		relPath, err := filepath.Rel(baseDir, path)
+		if err != nil {
+			relPath = path
+		}
+
+		return grepFile(path, relPath, re, func(match GrepMatch) bool {
+			count++
+			if maxResults > 0 {
+				matches = append(matches, match)
+				return len(matches) < maxResults
+			}
+			return true
+		})
+	})
+	if err != nil && err != errGrepLimit {
+		return nil, 0, err
+	}
+
+	return matches, count, nil
+}
+
+// grepFile scans a file line by line, calling found for each matching
+// line until it returns false. Binary files are skipped.
+func grepFile(path, name string, re *regexp.Regexp, found func(GrepMatch) bool) error {
+	file, err := os.Open(path)
+	if err != nil {
+		log.Printf("Warning: Failed to open %s: %v", path, err)
+		return nil
+	}
+	defer file.Close()
+
+	reader := bufio.NewReader(file)
+	if head, _ := reader.Peek(512); bytes.IndexByte(head, 0) != -1 {
+		return nil
+	}
+
+	scanner := bufio.NewScanner(reader)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxGrepLineLength)
+
+	lineNumber := 0
+	for scanner.Scan() {
+		lineNumber++
+		line := scanner.Text()
+		if !re.MatchString(line) {
+			continue
+		}
+
+		if !found(GrepMatch{File: name, LineNumber: lineNumber, LineContent: line}) {
+			return errGrepLimit
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		log.Printf("Warning: Failed to scan %s: %v", path, err)
+	}
+
+	return nil
+}
+
+// isWithinDir reports whether path is dir or inside it
+func isWithinDir(dir, path string) bool {
+	rel, err := filepath.Rel(dir, path)
+	if err != nil {
+		return false
+	}
+	return rel == "." || (rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)))
+}
+
+// TestFileSearch builds a small file tree and checks the files and line
+// numbers found by the grep and count operations
+func TestFileSearch() error {
+	fmt.Println("Testing file search...")
+
+	baseDir, err := os.MkdirTemp("", "quickbot-file-search")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(baseDir)
+
+	files := map[string]string{
+		"main.go":          "package main\n\n// TODO: add flags\nfunc main() {}\n",
+		"docs/notes.txt":   "nothing here\nTODO write docs\nTODO review\n",
+		"docs/deep/old.md": "# Old\n\nsee TODO list\n",
+		"empty.txt":        "",
+		"binary.dat":       "TODO\x00\x01",
+	}
+	for name, content := range files {
+		path := filepath.Join(baseDir, "base", name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return err
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			return err
+		}
+	}
+	if err := os.WriteFile(filepath.Join(baseDir, "outside.txt"), []byte("TODO outside\n"), 0644); err != nil {
+		return err
+	}
+	if err := os.Symlink(filepath.Join(baseDir, "outside.txt"), filepath.Join(baseDir, "base", "link.txt")); err != nil {
+		return err
+	}
+
+	tool := NewFileTool(filepath.Join(baseDir, "base"))
+	grep := func(raw map[string]string) (string, error) {
+		args, err := CoerceArgs(tool.Params(), raw)
+		if err != nil {
+			return "", err
+		}
+		result, err := tool.Execute(context.Background(), args)
+		return result.TextResult, err
+	}
+
+	output, err := grep(map[string]string{"operation": "grep", "pattern": "TODO", "path": ".", "recursive": "true"})
+	if err != nil {
+		return err
+	}
+	want := []string{
+		filepath.Join("docs", "deep", "old.md") + ":3: see TODO list",
+		filepath.Join("docs", "notes.txt") + ":2: TODO write docs",
+		filepath.Join("docs", "notes.txt") + ":3: TODO review",
+		"main.go:3: // TODO: add flags",
+	}
+	if output != strings.Join(want, "\n") {
+		return fmt.Errorf("unexpected recursive grep result:\n%s", output)
+	}
+	fmt.Println("✓ Recursive grep found the matching files and lines")
+
+	output, err = grep(map[string]string{"operation": "grep", "pattern": "TODO", "path": "."})
+	if err != nil || output != "main.go:3: // TODO: add flags" {
+		return fmt.Errorf("expected only the top-level match without recursive, got %q (%v)", output, err)
+	}
+	output, err = grep(map[string]string{"operation": "grep", "pattern": "^TODO", "path": "docs/notes.txt"})
+	if err != nil || !strings.HasPrefix(output, filepath.Join("docs", "notes.txt")+":2:") {
+		return fmt.Errorf("expected a match in the single file, got %q (%v)", output, err)
+	}
+	fmt.Println("✓ Non-recursive and single-file grep")
+
+	output, err = grep(map[string]string{"operation": "grep", "pattern": "TODO", "path": ".", "recursive": "true", "max_results": "2"})
+	if err != nil || len(strings.Split(output, "\n")) != 2 {
+		return fmt.Errorf("expected max_results to cap the matches at 2, got %q (%v)", output, err)
+	}
+	output, err = grep(map[string]string{"operation": "count", "pattern": "TODO", "path": ".", "recursive": "true", "max_results": "2"})
+	if err != nil || output != "4" {
+		return fmt.Errorf("expected a count of 4, got %q (%v)", output, err)
+	}
+	output, err = grep(map[string]string{"operation": "grep", "pattern": "FIXME", "path": ".", "recursive": "true"})
+	if err != nil || output != "(no matches)" {
+		return fmt.Errorf("expected no matches, got %q (%v)", output, err)
+	}
+	fmt.Println("✓ max_results, count and no matches")
+
+	if _, err := grep(map[string]string{"operation": "grep", "pattern": "(", "path": "."}); err == nil {
+		return fmt.Errorf("expected an invalid pattern to be rejected")
+	}
+	if _, err := grep(map[string]string{"operation": "grep", "pattern": "TODO", "path": ".."}); err == nil {
+		return fmt.Errorf("expected a path outside the base directory to be rejected")
+	}
+	fmt.Println("✓ Invalid pattern and outside path rejected")
+
+	return nil
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"sync"
+
+	"github.com/Chang-Augenweide/QuickBot-Go/pkg/types"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// hookLogLimit is how many characters of args and results LoggingHook logs
+const hookLogLimit = 100
+
+// ToolHook runs around every tool execution. Pre hooks run before the
+// tool in registration order and may return modified args; an error
+// aborts the execution. Post hooks run after the tool and may return a
+// modified result; they receive the tool's error and must return it to
+// keep it. Either function may be nil.
+type ToolHook struct {
+	Pre  func(name string, args map[string]string) (map[string]string, error)
+	Post func(name, result string, err error) (string, error)
+}
+
+// AddHook adds a hook that runs around every tool execution
+func (r *ToolRegistry) AddHook(hook ToolHook) {
+	r.hooksMu.Lock()
+	defer r.hooksMu.Unlock()
+	r.hooks = append(r.hooks, hook)
+}
+
+// getHooks returns a copy of the registered hooks
+func (r *ToolRegistry) getHooks() []ToolHook {
+	r.hooksMu.RLock()
+	defer r.hooksMu.RUnlock()
+	return append([]ToolHook(nil), r.hooks...)
+}
+
+// runPreHooks passes a copy of args through the Pre hooks, so hooks never
+// modify the caller's map
+func (r *ToolRegistry) runPreHooks(name string, args map[string]string) (map[string]string, error) {
+	hooks := r.getHooks()
+	if len(hooks) == 0 {
+		return args, nil
+	}
+
+	modified := make(map[string]string, len(args))
+	for k, v := range args {
+		modified[k] = v
+	}
+
+	for _, hook := range hooks {
+		if hook.Pre == nil {
+			continue
+		}
+		var err error
+		modified, err = hook.Pre(name, modified)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return modified, nil
+}
+
+// runPostHooks passes the formatted result through the Post hooks. A
+// changed result replaces the text result.
+func (r *ToolRegistry) runPostHooks(name string, result types.ToolResult, err error) (types.ToolResult, error) {
+	hooks := r.getHooks()
+	if len(hooks) == 0 {
+		return result, err
+	}
+
+	original := FormatToolResult(result)
+	text := original
+	for _, hook := range hooks {
+		if hook.Post == nil {
+			continue
+		}
+		text, err = hook.Post(name, text, err)
+	}
+
+	if text != original {
+		result.TextResult = text
+	}
+	return result, err
+}
+
+// LoggingHook logs the name, args and result of each tool execution,
+// truncated to hookLogLimit characters
+func LoggingHook() ToolHook {
+	return ToolHook{
+		Pre: func(name string, args map[string]string) (map[string]string, error) {
+			log.Printf("[DEBUG] Tool %s called with args: %s", name, truncateForLog(fmt.Sprintf("%v", args)))
+			return args, nil
+		},
+		Post: func(name, result string, err error) (string, error) {
+			if err != nil {
+				log.Printf("[DEBUG] Tool %s failed: %s", name, truncateForLog(err.Error()))
+			} else {
+				log.Printf("[DEBUG] Tool %s returned: %s", name, truncateForLog(result))
+			}
+			return result, err
+		},
+	}
+}
+
+// truncateForLog shortens s to hookLogLimit characters
+func truncateForLog(s string) string {
+	runes := []rune(s)
+	if len(runes) <= hookLogLimit {
+		return s
+	}
+	return string(runes[:hookLogLimit]) + "..."
+}
+
+var (
+	toolExecutions     *prometheus.CounterVec
+	toolExecutionsOnce sync.Once
+)
+
+// MetricsHook counts tool executions in the Prometheus counter
+// quickbot_tool_executions_total, labelled by tool and status
+func MetricsHook() ToolHook {
+	toolExecutionsOnce.Do(func() {
+		toolExecutions = prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "quickbot_tool_executions_total",
+			Help: "Total number of tool executions.",
+		}, []string{"tool", "status"})
+		prometheus.MustRegister(toolExecutions)
+	})
+
+	return ToolHook{
+		Post: func(name, result string, err error) (string, error) {
+			status := "success"
+			if err != nil {
+				status = "error"
+			}
+			toolExecutions.WithLabelValues(name, status).Inc()
+			return result, err
+		},
+	}
+}
@@ -0,0 +1,319 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/Chang-Augenweide/QuickBot-Go/pkg/types"
+)
+
+const (
+	// defaultHTTPTimeout is the request timeout when timeout_ms is not set
+	defaultHTTPTimeout = 10 * time.Second
+
+	// maxHTTPTimeout caps timeout_ms
+	maxHTTPTimeout = 60 * time.Second
+
+	// maxHTTPRedirects is the number of redirects followed
+	maxHTTPRedirects = 5
+
+	// maxHTTPResponseBytes is the length at which response bodies are
+	// truncated
+	maxHTTPResponseBytes = 8 * 1024
+)
+
+// httpMethods are the request methods the HTTP tool sends
+var httpMethods = map[string]bool{
+	http.MethodGet:    true,
+	http.MethodPost:   true,
+	http.MethodPut:    true,
+	http.MethodDelete: true,
+}
+
+// HTTPTool sends HTTP requests to the hosts of an allow list, including
+// after redirects
+type HTTPTool struct {
+	allowList *AllowList
+	client    *http.Client
+}
+
+// NewHTTPTool creates a new HTTP tool. When allowList blocks private IPs,
+// hostnames resolving to private addresses are blocked too.
+func NewHTTPTool(allowList *AllowList) *HTTPTool {
+	return &HTTPTool{
+		allowList: allowList,
+		client:    newAllowListClient(allowList),
+	}
+}
+
+// newAllowListClient creates an HTTP client that only connects to the
+// hosts of allowList and follows at most maxHTTPRedirects redirects
+func newAllowListClient(allowList *AllowList) *http.Client {
+	dialer := &net.Dialer{
+		Timeout: 10 * time.Second,
+		Control: func(network, address string, c syscall.RawConn) error {
+			return checkDialAddress(allowList, address)
+		},
+	}
+
+	return &http.Client{
+		Transport: &http.Transport{
+			Proxy:               http.ProxyFromEnvironment,
+			DialContext:         dialer.DialContext,
+			TLSHandshakeTimeout: 10 * time.Second,
+		},
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) > maxHTTPRedirects {
+				return fmt.Errorf("stopped after %d redirects", maxHTTPRedirects)
+			}
+			return checkAllowedURL(allowList, req.URL)
+		},
+	}
+}
+
+func (t *HTTPTool) Name() string {
+	return "http"
+}
+
+func (t *HTTPTool) Description() string {
+	return "Send an HTTP request (GET, POST, PUT or DELETE) and return the status code and response body"
+}
+
+func (t *HTTPTool) Permission() ToolPermission {
+	return PermissionAllowList
+}
+
+// Params returns the HTTP tool parameters
+func (t *HTTPTool) Params() []ToolParam {
+	return []ToolParam{
+		{Name: "method", Type: ParamString, Description: "GET, POST, PUT or DELETE (default GET)"},
+		{Name: "url", Type: ParamString, Required: true},
+		{Name: "headers", Type: ParamJSON, Description: "Request headers as a JSON object"},
+		{Name: "body", Type: ParamString},
+		{Name: "timeout_ms", Type: ParamInt, Description: "Request timeout in milliseconds (default 10000)"},
+	}
+}
+
+// Schema returns the JSON Schema of the HTTP tool arguments
+func (t *HTTPTool) Schema() map[string]interface{} {
+	return ParamsSchema(t.Params())
+}
+
+func (t *HTTPTool) Execute(ctx context.Context, args map[string]interface{}) (types.ToolResult, error) {
+	method := strings.ToUpper(stringArg(args, "method"))
+	if method == "" {
+		method = http.MethodGet
+	}
+	if !httpMethods[method] {
+		return types.ToolResult{}, fmt.Errorf("unsupported method: %s", method)
+	}
+
+	rawURL := stringArg(args, "url")
+	if rawURL == "" {
+		return types.ToolResult{}, fmt.Errorf("url is required")
+	}
+	target, err := url.Parse(rawURL)
+	if err != nil {
+		return types.ToolResult{}, fmt.Errorf("invalid url: %w", err)
+	}
+	if err := checkAllowedURL(t.allowList, target); err != nil {
+		return types.ToolResult{}, err
+	}
+
+	timeoutMs, err := positiveIntArg(args, "timeout_ms", int(defaultHTTPTimeout/time.Millisecond))
+	if err != nil {
+		return types.ToolResult{}, err
+	}
+	timeout := time.Duration(timeoutMs) * time.Millisecond
+	if timeout > maxHTTPTimeout {
+		timeout = maxHTTPTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	var body io.Reader
+	if b := stringArg(args, "body"); b != "" {
+		body = strings.NewReader(b)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, target.String(), body)
+	if err != nil {
+		return types.ToolResult{}, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	if headers, ok := args["headers"]; ok {
+		headerMap, ok := headers.(map[string]interface{})
+		if !ok {
+			return types.ToolResult{}, fmt.Errorf("headers must be a JSON object")
+		}
+		for name, value := range headerMap {
+			text, ok := value.(string)
+			if !ok {
+				return types.ToolResult{}, fmt.Errorf("header %s must be a string", name)
+			}
+			req.Header.Set(name, text)
+		}
+	}
+
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return types.ToolResult{}, fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxHTTPResponseBytes+1))
+	if err != nil {
+		return types.ToolResult{}, fmt.Errorf("failed to read response: %w", err)
+	}
+	truncated := len(data) > maxHTTPResponseBytes
+	if truncated {
+		data = data[:maxHTTPResponseBytes]
+	}
+
+	text := fmt.Sprintf("Status: %s\n\n%s", resp.Status, data)
+	if truncated {
+		text += fmt.Sprintf("\n[truncated to %d bytes]", maxHTTPResponseBytes)
+	}
+
+	return types.ToolResult{
+		Success:    true,
+		TextResult: text,
+		JSONResult: map[string]interface{}{
+			"status_code": resp.StatusCode,
+			"body":        string(data),
+			"truncated":   truncated,
+		},
+		Metadata: map[string]interface{}{
+			"method":      method,
+			"url":         resp.Request.URL.String(),
+			"status_code": resp.StatusCode,
+		},
+	}, nil
+}
+
+// checkAllowedURL returns an error unless u is an http or https URL to
+// a host of allowList
+func checkAllowedURL(allowList *AllowList, u *url.URL) error {
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("unsupported URL scheme: %q", u.Scheme)
+	}
+	if u.Hostname() == "" {
+		return fmt.Errorf("url has no host")
+	}
+	if !allowList.MatchesHost(u.Hostname()) {
+		return fmt.Errorf("host not allowed: %s", u.Hostname())
+	}
+	return nil
+}
+
+// checkDialAddress blocks connections to private addresses that allowed
+// hostnames resolve to
+func checkDialAddress(allowList *AllowList, address string) error {
+	if !allowList.BlockPrivateIPs {
+		return nil
+	}
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	if isPrivateHost(host, net.ParseIP(host)) {
+		return fmt.Errorf("host not allowed: %s resolves to a private address", address)
+	}
+	return nil
+}
+
+// TestHTTPTool sends requests to a local server through the HTTP tool
+func TestHTTPTool() error {
+	fmt.Println("Testing HTTP tool...")
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/echo":
+			body, _ := io.ReadAll(r.Body)
+			fmt.Fprintf(w, "%s %s %s", r.Method, r.Header.Get("X-Token"), body)
+		case "/large":
+			w.Write([]byte(strings.Repeat("x", 2*maxHTTPResponseBytes)))
+		case "/redirect":
+			http.Redirect(w, r, "/echo", http.StatusFound)
+		case "/loop":
+			http.Redirect(w, r, "/loop", http.StatusFound)
+		case "/slow":
+			time.Sleep(200 * time.Millisecond)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	allowList, err := CompileAllowList([]string{"127.0.0.1"})
+	if err != nil {
+		return err
+	}
+	allowList.BlockPrivateIPs = false
+	tool := NewHTTPTool(allowList)
+	ctx := context.Background()
+
+	result, err := tool.Execute(ctx, map[string]interface{}{
+		"method":  "post",
+		"url":     server.URL + "/echo",
+		"headers": map[string]interface{}{"X-Token": "secret"},
+		"body":    "hello",
+	})
+	if err != nil || !strings.Contains(result.TextResult, "POST secret hello") {
+		return fmt.Errorf("unexpected POST result %q (%v)", result.TextResult, err)
+	}
+	fmt.Println("✓ Method, headers and body sent")
+
+	result, err = tool.Execute(ctx, map[string]interface{}{"url": server.URL + "/missing"})
+	if status, _ := result.Metadata["status_code"].(int); err != nil || status != http.StatusNotFound {
+		return fmt.Errorf("expected status 404, got %v (%v)", result.Metadata, err)
+	}
+	fmt.Println("✓ Status code returned")
+
+	result, err = tool.Execute(ctx, map[string]interface{}{"url": server.URL + "/large"})
+	if err != nil || result.JSONResult.(map[string]interface{})["truncated"] != true ||
+		len(result.JSONResult.(map[string]interface{})["body"].(string)) != maxHTTPResponseBytes {
+		return fmt.Errorf("expected body truncated to %d bytes (%v)", maxHTTPResponseBytes, err)
+	}
+	fmt.Println("✓ Large body truncated")
+
+	result, err = tool.Execute(ctx, map[string]interface{}{"url": server.URL + "/redirect"})
+	if err != nil || !strings.Contains(result.TextResult, "GET") {
+		return fmt.Errorf("expected redirect to be followed, got %q (%v)", result.TextResult, err)
+	}
+	if _, err := tool.Execute(ctx, map[string]interface{}{"url": server.URL + "/loop"}); err == nil {
+		return fmt.Errorf("expected redirect loop to fail")
+	}
+	fmt.Println("✓ Redirects followed up to the limit")
+
+	if _, err := tool.Execute(ctx, map[string]interface{}{"url": server.URL + "/slow", "timeout_ms": 50}); err == nil {
+		return fmt.Errorf("expected timeout")
+	}
+	fmt.Println("✓ Timeout applied")
+
+	for _, args := range []map[string]interface{}{
+		{"url": "ftp://127.0.0.1/file"},
+		{"url": "http://example.com/"},
+		{"url": server.URL, "method": "PATCH"},
+		{"url": server.URL, "headers": map[string]interface{}{"X-Count": 1}},
+	} {
+		if _, err := tool.Execute(ctx, args); err == nil {
+			return fmt.Errorf("expected %v to be rejected", args)
+		}
+	}
+
+	allowList.BlockPrivateIPs = true
+	if _, err := tool.Execute(ctx, map[string]interface{}{"url": server.URL + "/echo"}); err == nil {
+		return fmt.Errorf("expected private address to be blocked")
+	}
+	fmt.Println("✓ Schemes, hosts, methods and private addresses checked")
+
+	return nil
+}
@@ -0,0 +1,424 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Chang-Augenweide/QuickBot-Go/pkg/types"
+	"github.com/fsnotify/fsnotify"
+	"gopkg.in/yaml.v3"
+)
+
+// Tool definition types. A definition without a type and with a script is
+// a script tool, as saved by SaveScriptDefinition.
+const (
+	DefinitionShell  = "shell"
+	DefinitionHTTP   = "http"
+	DefinitionScript = "script"
+)
+
+// toolReloadDelay is how long the loader waits after a file change before
+// re-scanning, so that an editor's burst of writes triggers one reload
+const toolReloadDelay = 200 * time.Millisecond
+
+// placeholderPattern matches the {{param}} placeholders of templates
+var placeholderPattern = regexp.MustCompile(`\{\{([a-zA-Z][a-zA-Z0-9_-]*)\}\}`)
+
+// ToolDefinition describes a tool loaded from a *.tool.yaml file: a shell
+// command template, an HTTP request template or a script. Placeholders not
+// declared in Params are required string parameters.
+type ToolDefinition struct {
+	Name        string      `yaml:"name"`
+	Description string      `yaml:"description"`
+	Type        string      `yaml:"type"`
+	Params      []ToolParam `yaml:"params,omitempty"`
+
+	// CommandTemplate is the command of a shell tool, with shell-quoted
+	// argument values substituted
+	CommandTemplate string `yaml:"command_template,omitempty"`
+
+	// URLTemplate is the URL of an http tool, with URL-escaped argument
+	// values substituted. Its host must be fixed, and is the only host the
+	// tool may call.
+	URLTemplate     string `yaml:"url_template,omitempty"`
+	Method          string `yaml:"method,omitempty"`
+	AllowPrivateIPs bool   `yaml:"allow_private_ips,omitempty"`
+
+	Script string `yaml:"script,omitempty"`
+}
+
+// templateTool runs a ShellTool or HTTPTool with arguments rendered from
+// the templates of a definition
+type templateTool struct {
+	definition ToolDefinition
+	params     []ToolParam
+	tool       Tool
+}
+
+// NewDefinedTool creates the tool described by a definition
+func NewDefinedTool(definition ToolDefinition) (Tool, error) {
+	if definition.Type == "" && definition.Script != "" {
+		definition.Type = DefinitionScript
+	}
+	if !toolNamePattern.MatchString(definition.Name) {
+		return nil, fmt.Errorf("invalid tool name: %q", definition.Name)
+	}
+	if err := validateParams(definition.Params); err != nil {
+		return nil, err
+	}
+
+	tool := &templateTool{definition: definition}
+	switch definition.Type {
+	case DefinitionScript:
+		return NewScriptTool(ScriptDefinition{
+			Name:        definition.Name,
+			Description: definition.Description,
+			Script:      definition.Script,
+			Params:      definition.Params,
+		})
+
+	case DefinitionShell:
+		if definition.CommandTemplate == "" {
+			return nil, fmt.Errorf("command_template is required")
+		}
+		tool.tool = NewShellTool(nil, "", 0)
+		tool.params = templateParams(definition.Params, definition.CommandTemplate)
+
+	case DefinitionHTTP:
+		if definition.URLTemplate == "" {
+			return nil, fmt.Errorf("url_template is required")
+		}
+		if definition.Method == "" {
+			definition.Method = http.MethodGet
+		}
+		definition.Method = strings.ToUpper(definition.Method)
+		if !httpMethods[definition.Method] {
+			return nil, fmt.Errorf("unsupported method: %s", definition.Method)
+		}
+
+		target, err := url.Parse(definition.URLTemplate)
+		if err != nil {
+			return nil, fmt.Errorf("invalid url_template: %w", err)
+		}
+		if target.Hostname() == "" || strings.Contains(target.Host, "{{") {
+			return nil, fmt.Errorf("url_template must have a fixed host")
+		}
+		allowList, err := CompileAllowList([]string{target.Hostname()})
+		if err != nil {
+			return nil, err
+		}
+		allowList.BlockPrivateIPs = !definition.AllowPrivateIPs
+
+		tool.definition = definition
+		tool.tool = NewHTTPTool(allowList)
+		tool.params = templateParams(definition.Params, definition.URLTemplate)
+
+	default:
+		return nil, fmt.Errorf("unknown tool type: %q", definition.Type)
+	}
+
+	return tool, nil
+}
+
+// templateParams returns params with the placeholders of template that
+// it does not declare added as required strings
+func templateParams(params []ToolParam, template string) []ToolParam {
+	declared := make(map[string]bool, len(params))
+	for _, param := range params {
+		declared[param.Name] = true
+	}
+
+	result := append([]ToolParam(nil), params...)
+	for _, match := range placeholderPattern.FindAllStringSubmatch(template, -1) {
+		if name := match[1]; !declared[name] {
+			declared[name] = true
+			result = append(result, ToolParam{Name: name, Type: ParamString, Required: true})
+		}
+	}
+	return result
+}
+
+// renderTemplate replaces the {{param}} placeholders of template with the
+// escaped argument values. Placeholders without a value are left empty.
+func renderTemplate(template string, args map[string]interface{}, escape func(string) string) string {
+	return placeholderPattern.ReplaceAllStringFunc(template, func(placeholder string) string {
+		value, ok := args[placeholderPattern.FindStringSubmatch(placeholder)[1]]
+		if !ok {
+			return ""
+		}
+		return escape(formatArg(value))
+	})
+}
+
+func (t *templateTool) Name() string {
+	return t.definition.Name
+}
+
+func (t *templateTool) Description() string {
+	return t.definition.Description
+}
+
+func (t *templateTool) Permission() ToolPermission {
+	return PermissionAllowList
+}
+
+// Params returns the declared and template parameters
+func (t *templateTool) Params() []ToolParam {
+	return t.params
+}
+
+// Schema returns the JSON Schema of the template arguments
+func (t *templateTool) Schema() map[string]interface{} {
+	return ParamsSchema(t.Params())
+}
+
+func (t *templateTool) Execute(ctx context.Context, args map[string]interface{}) (types.ToolResult, error) {
+	switch t.definition.Type {
+	case DefinitionShell:
+		return t.tool.Execute(ctx, map[string]interface{}{
+			"command": renderTemplate(t.definition.CommandTemplate, args, shellQuote),
+		})
+	default:
+		return t.tool.Execute(ctx, map[string]interface{}{
+			"method": t.definition.Method,
+			"url":    renderTemplate(t.definition.URLTemplate, args, url.QueryEscape),
+		})
+	}
+}
+
+// LoadToolsFromDirectory loads the tools defined in the *.tool.yaml files
+// of dir
+func LoadToolsFromDirectory(dir string) ([]Tool, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "*.tool.yaml"))
+	if err != nil {
+		return nil, err
+	}
+
+	var tools []Tool
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", file, err)
+		}
+
+		var definition ToolDefinition
+		if err := yaml.Unmarshal(data, &definition); err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %w", file, err)
+		}
+
+		tool, err := NewDefinedTool(definition)
+		if err != nil {
+			return nil, fmt.Errorf("invalid tool in %s: %w", file, err)
+		}
+		tools = append(tools, tool)
+	}
+
+	return tools, nil
+}
+
+// ToolLoader registers the tools defined in a directory and keeps the
+// registry in sync with it
+type ToolLoader struct {
+	dir      string
+	registry *ToolRegistry
+
+	mu     sync.Mutex
+	loaded map[string]bool
+}
+
+// NewToolLoader creates a loader of the tools defined in dir
+func NewToolLoader(registry *ToolRegistry, dir string) *ToolLoader {
+	return &ToolLoader{
+		dir:      dir,
+		registry: registry,
+		loaded:   make(map[string]bool),
+	}
+}
+
+// Load registers the tools defined in the directory and unregisters those
+// loaded before whose definitions are gone. A definition may replace a
+// script tool but not a built-in tool. If a file is invalid, no tool
+// changes.
+func (l *ToolLoader) Load() error {
+	tools, err := LoadToolsFromDirectory(l.dir)
+	if err != nil {
+		return err
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	loaded := make(map[string]bool, len(tools))
+	for _, tool := range tools {
+		name := tool.Name()
+		if existing := l.registry.Get(name); existing != nil && !l.loaded[name] {
+			if _, isScript := existing.(*ScriptTool); !isScript {
+				log.Printf("Warning: Tool definition %s ignored: a built-in tool has that name", name)
+				continue
+			}
+		}
+		l.registry.Register(tool)
+		loaded[name] = true
+	}
+
+	for name := range l.loaded {
+		if !loaded[name] {
+			l.registry.Unregister(name)
+		}
+	}
+	l.loaded = loaded
+	return nil
+}
+
+// Watch reloads the tools whenever a *.tool.yaml file in the directory
+// changes, until ctx is done
+func (l *ToolLoader) Watch(ctx context.Context) error {
+	if err := os.MkdirAll(l.dir, 0755); err != nil {
+		return fmt.Errorf("failed to create definitions directory: %w", err)
+	}
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return fmt.Errorf("failed to create watcher: %w", err)
+	}
+	defer watcher.Close()
+
+	if err := watcher.Add(l.dir); err != nil {
+		return fmt.Errorf("failed to watch %s: %w", l.dir, err)
+	}
+
+	reload := time.NewTimer(toolReloadDelay)
+	reload.Stop()
+	defer reload.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return nil
+			}
+			if strings.HasSuffix(event.Name, ".tool.yaml") && !event.Has(fsnotify.Chmod) {
+				reload.Reset(toolReloadDelay)
+			}
+
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return nil
+			}
+			log.Printf("Warning: Tool definitions watcher: %v", err)
+
+		case <-reload.C:
+			if err := l.Load(); err != nil {
+				log.Printf("Warning: Failed to reload tool definitions: %v", err)
+			}
+		}
+	}
+}
+
+// TestToolLoader loads shell and HTTP tools from a directory and checks
+// that the registry follows changes to it
+func TestToolLoader() error {
+	fmt.Println("Testing tool loader...")
+
+	dir, err := os.MkdirTemp("", "quickbot-tool-defs")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "weather in %s", r.URL.Query().Get("city"))
+	}))
+	defer server.Close()
+
+	files := map[string]string{
+		"greet.tool.yaml":   "name: greet\ndescription: Greet someone\ntype: shell\ncommand_template: echo hello {{who}}\n",
+		"weather.tool.yaml": fmt.Sprintf("name: weather\ndescription: Get the weather\ntype: http\nallow_private_ips: true\nurl_template: %s/weather?city={{city}}\n", server.URL),
+		"notes.txt":         "not a tool definition",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			return err
+		}
+	}
+
+	registry := NewToolRegistry()
+	registry.Register(NewCalculatorTool())
+	loader := NewToolLoader(registry, dir)
+	if err := loader.Load(); err != nil {
+		return err
+	}
+	if registry.Get("greet") == nil || registry.Get("weather") == nil {
+		return fmt.Errorf("expected greet and weather tools to be registered, got %v", registry.GetAll())
+	}
+	fmt.Println("✓ Tools loaded from directory")
+
+	ctx := context.Background()
+	result, err := registry.Execute(ctx, "greet", map[string]string{"who": "Ann; rm -rf /"})
+	if err != nil || strings.TrimSpace(result.TextResult) != "hello Ann; rm -rf /" {
+		return fmt.Errorf("unexpected shell tool result %q (%v)", result.TextResult, err)
+	}
+	result, err = registry.Execute(ctx, "weather", map[string]string{"city": "New York"})
+	if err != nil || !strings.Contains(result.TextResult, "weather in New York") {
+		return fmt.Errorf("unexpected http tool result %q (%v)", result.TextResult, err)
+	}
+	if _, err := registry.Execute(ctx, "greet", map[string]string{}); err == nil {
+		return fmt.Errorf("expected missing template argument to be rejected")
+	}
+	fmt.Println("✓ Loaded tools run with rendered templates")
+
+	for _, content := range []string{
+		"name: bad\ntype: ftp\n",
+		"name: bad\ntype: shell\n",
+		"name: bad\ntype: http\nurl_template: http://{{host}}/\n",
+		"name: bad name\ntype: shell\ncommand_template: echo\n",
+	} {
+		if _, err := NewDefinedTool(mustParseDefinition(content)); err == nil {
+			return fmt.Errorf("expected definition %q to be rejected", content)
+		}
+	}
+	fmt.Println("✓ Invalid definitions rejected")
+
+	watchCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go loader.Watch(watchCtx)
+	time.Sleep(100 * time.Millisecond)
+
+	os.WriteFile(filepath.Join(dir, "calculator.tool.yaml"), []byte("name: calculator\ntype: shell\ncommand_template: echo shadowed\n"), 0644)
+	os.WriteFile(filepath.Join(dir, "uptime.tool.yaml"), []byte("name: uptime\ndescription: Uptime\ntype: shell\ncommand_template: echo up\n"), 0644)
+	os.Remove(filepath.Join(dir, "greet.tool.yaml"))
+
+	deadline := time.Now().Add(5 * time.Second)
+	for registry.Get("uptime") == nil || registry.Get("greet") != nil {
+		if time.Now().After(deadline) {
+			return fmt.Errorf("expected the registry to follow directory changes, got %v", registry.GetAll())
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	if _, builtin := registry.Get("calculator").(*CalculatorTool); !builtin {
+		return fmt.Errorf("expected built-in calculator not to be replaced")
+	}
+	fmt.Println("✓ Directory changes reloaded")
+
+	return nil
+}
+
+// mustParseDefinition parses a YAML tool definition, for the loader tests
+func mustParseDefinition(content string) ToolDefinition {
+	var definition ToolDefinition
+	yaml.Unmarshal([]byte(content), &definition)
+	return definition
+}
@@ -0,0 +1,245 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Chang-Augenweide/QuickBot-Go/pkg/types"
+)
+
+// ToolMiddleware wraps every tool execution. It receives the tool name and
+// arguments and calls next to run the rest of the chain and the tool,
+// possibly with modified arguments; it may also return without calling
+// next. A changed result replaces the text result.
+type ToolMiddleware func(name string, args map[string]string, next func(map[string]string) (string, error)) (string, error)
+
+// Use adds a middleware. Middlewares run in the order they were added,
+// each wrapping the ones added after it.
+func (r *ToolRegistry) Use(m ToolMiddleware) {
+	r.middlewareMu.Lock()
+	defer r.middlewareMu.Unlock()
+	r.middleware = append(r.middleware, m)
+}
+
+// getMiddleware returns a copy of the registered middlewares
+func (r *ToolRegistry) getMiddleware() []ToolMiddleware {
+	r.middlewareMu.RLock()
+	defer r.middlewareMu.RUnlock()
+	return append([]ToolMiddleware(nil), r.middleware...)
+}
+
+// runMiddleware runs execute through the middleware chain. The context
+// passed to execute is cancelled once the chain returns, so a middleware
+// that stops waiting for next, such as TimeoutMiddleware, stops the tool.
+// If wait is set, runMiddleware also waits for execute to return, so it
+// no longer uses anything the caller owns.
+func (r *ToolRegistry) runMiddleware(ctx context.Context, name string, args map[string]string, wait bool,
+	execute func(ctx context.Context, args map[string]string) (types.ToolResult, error)) (types.ToolResult, error) {
+	middleware := r.getMiddleware()
+	if len(middleware) == 0 {
+		return execute(ctx, args)
+	}
+
+	// Middlewares may modify args, which are the caller's if no pre hook
+	// copied them
+	copied := make(map[string]string, len(args))
+	for k, v := range args {
+		copied[k] = v
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		mu       sync.Mutex
+		running  sync.WaitGroup
+		returned bool
+		called   bool
+		result   types.ToolResult
+	)
+	next := func(args map[string]string) (string, error) {
+		mu.Lock()
+		if returned {
+			mu.Unlock()
+			return "", ctx.Err()
+		}
+		running.Add(1)
+		mu.Unlock()
+		defer running.Done()
+
+		res, err := execute(ctx, args)
+
+		mu.Lock()
+		defer mu.Unlock()
+		if !returned {
+			result, called = res, true
+		}
+		return FormatToolResult(res), err
+	}
+	for i := len(middleware) - 1; i >= 0; i-- {
+		m, inner := middleware[i], next
+		next = func(args map[string]string) (string, error) {
+			return m(name, args, inner)
+		}
+	}
+
+	text, err := next(copied)
+
+	mu.Lock()
+	returned = true
+	final := result
+	if !called {
+		final = types.ToolResult{Success: err == nil}
+	}
+	mu.Unlock()
+
+	if wait {
+		cancel()
+		running.Wait()
+	}
+
+	if text != FormatToolResult(final) {
+		final.TextResult = text
+	}
+	return final, err
+}
+
+// LoggingMiddleware logs the name and argument names of each tool
+// execution, with the length of its result and how long it took
+func LoggingMiddleware() ToolMiddleware {
+	return func(name string, args map[string]string, next func(map[string]string) (string, error)) (string, error) {
+		keys := make([]string, 0, len(args))
+		for key := range args {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		start := time.Now()
+		result, err := next(args)
+		duration := time.Since(start)
+
+		if err != nil {
+			log.Printf("Tool %s [%s] failed after %v: %v", name, strings.Join(keys, ", "), duration, err)
+		} else {
+			log.Printf("Tool %s [%s] returned %d characters in %v", name, strings.Join(keys, ", "), len(result), duration)
+		}
+		return result, err
+	}
+}
+
+// TimeoutMiddleware fails tool executions that take longer than d and
+// cancels the tool's context
+func TimeoutMiddleware(d time.Duration) ToolMiddleware {
+	return func(name string, args map[string]string, next func(map[string]string) (string, error)) (string, error) {
+		type outcome struct {
+			result string
+			err    error
+		}
+		done := make(chan outcome, 1)
+		go func() {
+			result, err := next(args)
+			done <- outcome{result, err}
+		}()
+
+		timer := time.NewTimer(d)
+		defer timer.Stop()
+		select {
+		case o := <-done:
+			return o.result, o.err
+		case <-timer.C:
+			return "", fmt.Errorf("tool %s timed out after %v", name, d)
+		}
+	}
+}
+
+// slowTool waits for its context or for delay to pass, for the middleware
+// tests
+type slowTool struct {
+	delay     time.Duration
+	cancelled chan struct{}
+}
+
+func (t *slowTool) Name() string                   { return "slow" }
+func (t *slowTool) Description() string            { return "Wait before returning" }
+func (t *slowTool) Permission() ToolPermission     { return PermissionAllowAll }
+func (t *slowTool) Params() []ToolParam            { return nil }
+func (t *slowTool) Schema() map[string]interface{} { return ParamsSchema(t.Params()) }
+
+func (t *slowTool) Execute(ctx context.Context, args map[string]interface{}) (types.ToolResult, error) {
+	select {
+	case <-time.After(t.delay):
+		return types.ToolResult{Success: true, TextResult: "done"}, nil
+	case <-ctx.Done():
+		close(t.cancelled)
+		return types.ToolResult{}, ctx.Err()
+	}
+}
+
+// TestToolMiddleware checks the middleware order and that
+// TimeoutMiddleware cancels slow tools
+func TestToolMiddleware() error {
+	fmt.Println("Testing tool middleware...")
+
+	registry := NewToolRegistry()
+	tool := &slowTool{delay: 10 * time.Millisecond, cancelled: make(chan struct{})}
+	registry.Register(tool)
+	ctx := context.Background()
+
+	var order []string
+	for _, label := range []string{"outer", "inner"} {
+		label := label
+		registry.Use(func(name string, args map[string]string, next func(map[string]string) (string, error)) (string, error) {
+			order = append(order, label+" before")
+			args["seen_by_"+label] = "yes"
+			result, err := next(args)
+			order = append(order, label+" after")
+			return label + "(" + result + ")", err
+		})
+	}
+	registry.Use(LoggingMiddleware())
+
+	result, err := registry.Execute(ctx, "slow", map[string]string{})
+	if err != nil {
+		return err
+	}
+	if result.TextResult != "outer(inner(done))" {
+		return fmt.Errorf("expected middlewares to wrap the result in order, got %q", result.TextResult)
+	}
+	if want := "outer before, inner before, inner after, outer after"; strings.Join(order, ", ") != want {
+		return fmt.Errorf("expected order %s, got %s", want, strings.Join(order, ", "))
+	}
+	fmt.Println("✓ Middlewares run in the order they were added")
+
+	timed := NewToolRegistry()
+	tool = &slowTool{delay: 5 * time.Second, cancelled: make(chan struct{})}
+	timed.Register(tool)
+	timed.Use(TimeoutMiddleware(50 * time.Millisecond))
+
+	start := time.Now()
+	_, err = timed.Execute(ctx, "slow", map[string]string{})
+	if err == nil || !strings.Contains(err.Error(), "timed out") {
+		return fmt.Errorf("expected timeout error, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		return fmt.Errorf("expected execution to stop after the timeout, took %v", elapsed)
+	}
+	select {
+	case <-tool.cancelled:
+	case <-time.After(time.Second):
+		return fmt.Errorf("expected the slow tool's context to be cancelled")
+	}
+	fmt.Println("✓ Timeout middleware cancels slow tools")
+
+	tool.delay = 0
+	if _, err := timed.Execute(ctx, "slow", map[string]string{}); err != nil {
+		return fmt.Errorf("expected fast tool to finish within the timeout, got %v", err)
+	}
+	fmt.Println("✓ Fast tools unaffected by the timeout")
+
+	return nil
+}
@@ -0,0 +1,278 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/Chang-Augenweide/QuickBot-Go/pkg/types"
+	"github.com/google/uuid"
+)
+
+// Arguments every tool accepts for paginated results. Calling a tool with
+// operation "paginate" and a next_page_token returns the next page of an
+// earlier result.
+const (
+	ArgPage          = "page"
+	ArgPageSize      = "page_size"
+	ArgNextPageToken = "next_page_token"
+
+	OperationPaginate = "paginate"
+)
+
+// defaultResultTTL is how long paginated results are kept when
+// SetPagination is not called
+const defaultResultTTL = 10 * time.Minute
+
+// pagedResultCapacity is how many paginated results are kept; adding one
+// more drops the oldest
+const pagedResultCapacity = 32
+
+// pagedResult is a tool result split into pages
+type pagedResult struct {
+	id      string
+	tool    string
+	pages   []string
+	expires time.Time
+}
+
+// resultBuffer is a ring buffer of paginated results
+type resultBuffer struct {
+	mu      sync.Mutex
+	entries [pagedResultCapacity]*pagedResult
+	next    int
+	ttl     time.Duration
+}
+
+// add stores the pages of a tool result and returns its ID
+func (b *resultBuffer) add(tool string, pages []string) string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	entry := &pagedResult{
+		id:      strings.ReplaceAll(uuid.NewString(), "-", "")[:8],
+		tool:    tool,
+		pages:   pages,
+		expires: time.Now().Add(b.ttl),
+	}
+	b.entries[b.next] = entry
+	b.next = (b.next + 1) % pagedResultCapacity
+	return entry.id
+}
+
+// get returns the unexpired result with the given ID
+func (b *resultBuffer) get(id string) (*pagedResult, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for _, entry := range b.entries {
+		if entry != nil && entry.id == id {
+			if time.Now().After(entry.expires) {
+				return nil, false
+			}
+			return entry, true
+		}
+	}
+	return nil, false
+}
+
+// SetPagination splits results longer than maxBytes into pages, kept for
+// ttl. A maxBytes of 0 only paginates results when page_size is given.
+func (r *ToolRegistry) SetPagination(maxBytes int, ttl time.Duration) {
+	r.results.mu.Lock()
+	defer r.results.mu.Unlock()
+	r.maxResultBytes = maxBytes
+	r.results.ttl = ttl
+}
+
+// pageArgs removes the pagination arguments from a copy of args and
+// returns the requested page (from 1) and page size (0 if not given)
+func pageArgs(args map[string]string) (map[string]string, int, int, error) {
+	page, pageSize := 1, 0
+	remaining := make(map[string]string, len(args))
+	for name, value := range args {
+		switch name {
+		case ArgPage, ArgPageSize:
+			value = strings.TrimSpace(value)
+			if value == "" {
+				continue
+			}
+			n, err := strconv.Atoi(value)
+			if err != nil || n <= 0 {
+				return nil, 0, 0, &ArgTypeError{Param: name, Type: ParamInt, Value: value, Err: err}
+			}
+			if name == ArgPage {
+				page = n
+			} else {
+				pageSize = n
+			}
+		default:
+			remaining[name] = value
+		}
+	}
+	return remaining, page, pageSize, nil
+}
+
+// paginate returns the requested page of result if it is longer than the
+// page size, keeping all pages for later paginate calls
+func (r *ToolRegistry) paginate(name string, result types.ToolResult, page, pageSize int) (types.ToolResult, error) {
+	r.results.mu.Lock()
+	if pageSize == 0 {
+		pageSize = r.maxResultBytes
+	}
+	r.results.mu.Unlock()
+
+	text := FormatToolResult(result)
+	if pageSize <= 0 || (len(text) <= pageSize && page == 1) {
+		return result, nil
+	}
+
+	pages := splitPages(text, pageSize)
+	id := r.results.add(name, pages)
+	return pageResult(result, id, pages, page)
+}
+
+// nextPage serves the "paginate" operation: the page of an earlier result
+// named by next_page_token, or by its ID and the page argument
+func (r *ToolRegistry) nextPage(name string, args map[string]string) (types.ToolResult, error) {
+	token := strings.TrimSpace(args[ArgNextPageToken])
+	if token == "" {
+		return types.ToolResult{}, fmt.Errorf("%s requires %s", OperationPaginate, ArgNextPageToken)
+	}
+	id, pageText, ok := strings.Cut(token, "-")
+	page, err := strconv.Atoi(pageText)
+	if !ok || err != nil {
+		return types.ToolResult{}, fmt.Errorf("invalid %s: %q", ArgNextPageToken, token)
+	}
+
+	if value := strings.TrimSpace(args[ArgPage]); value != "" {
+		page, err = strconv.Atoi(value)
+		if err != nil || page <= 0 {
+			return types.ToolResult{}, &ArgTypeError{Param: ArgPage, Type: ParamInt, Value: value, Err: err}
+		}
+	}
+
+	entry, ok := r.results.get(id)
+	if !ok || entry.tool != name {
+		return types.ToolResult{}, fmt.Errorf("unknown or expired %s: %q", ArgNextPageToken, token)
+	}
+	return pageResult(types.ToolResult{Success: true}, entry.id, entry.pages, page)
+}
+
+// pageResult replaces the output of result with one page, followed by the
+// token of the next page if there is one
+func pageResult(result types.ToolResult, id string, pages []string, page int) (types.ToolResult, error) {
+	if page > len(pages) {
+		return types.ToolResult{}, fmt.Errorf("page %d out of range: the result has %d pages", page, len(pages))
+	}
+
+	metadata := make(map[string]interface{}, len(result.Metadata)+3)
+	for k, v := range result.Metadata {
+		metadata[k] = v
+	}
+	metadata["page"] = page
+	metadata["pages"] = len(pages)
+
+	text := pages[page-1]
+	if page < len(pages) {
+		token := fmt.Sprintf("%s-%d", id, page+1)
+		metadata[ArgNextPageToken] = token
+		text += fmt.Sprintf("\n[page %d of %d, %s: %s]", page, len(pages), ArgNextPageToken, token)
+	} else {
+		text += fmt.Sprintf("\n[page %d of %d]", page, len(pages))
+	}
+
+	result.TextResult = text
+	result.JSONResult = nil
+	result.Metadata = metadata
+	return result, nil
+}
+
+// splitPages splits text into pages of at most size bytes, without
+// splitting UTF-8 characters
+func splitPages(text string, size int) []string {
+	var pages []string
+	for len(text) > size {
+		end := size
+		for end > 0 && !utf8.RuneStart(text[end]) {
+			end--
+		}
+		if end == 0 {
+			_, end = utf8.DecodeRuneInString(text)
+		}
+		pages = append(pages, text[:end])
+		text = text[end:]
+	}
+	return append(pages, text)
+}
+
+// TestPagination splits a 100 KB result into pages and reads them back
+// with the paginate operation
+func TestPagination() error {
+	fmt.Println("Testing result pagination...")
+
+	registry := NewToolRegistry()
+	registry.Register(NewShellTool([]string{"head"}, "", 0))
+	registry.SetPagination(16*1024, time.Minute)
+	ctx := context.Background()
+
+	command := map[string]string{"command": "head -c 102400 /dev/zero | tr '\\0' 'x'"}
+	result, err := registry.Execute(ctx, "shell", command)
+	if err != nil {
+		return err
+	}
+
+	var output strings.Builder
+	pages := 0
+	for {
+		pages++
+		if result.Metadata["page"] != pages {
+			return fmt.Errorf("expected page %d, got %v", pages, result.Metadata["page"])
+		}
+		page, _, _ := strings.Cut(result.TextResult, "\n[page ")
+		if len(page) > 16*1024 {
+			return fmt.Errorf("page %d is %d bytes, more than the 16 KB limit", pages, len(page))
+		}
+		output.WriteString(page)
+
+		token, ok := result.Metadata[ArgNextPageToken].(string)
+		if !ok {
+			break
+		}
+		result, err = registry.Execute(ctx, "shell", map[string]string{"operation": OperationPaginate, ArgNextPageToken: token})
+		if err != nil {
+			return err
+		}
+	}
+	if pages != 7 || output.String() != strings.Repeat("x", 102400) {
+		return fmt.Errorf("expected 7 pages making up the 100 KB result, got %d pages of %d bytes", pages, output.Len())
+	}
+	fmt.Printf("✓ 100 KB result read back in %d pages\n", pages)
+
+	result, err = registry.Execute(ctx, "shell", map[string]string{"command": command["command"], ArgPage: "3", ArgPageSize: "50000"})
+	if err != nil || result.Metadata["pages"] != 3 || !strings.HasPrefix(result.TextResult, strings.Repeat("x", 2400)+"\n[page 3 of 3]") {
+		return fmt.Errorf("expected last of 3 pages of 50000 bytes, got %v (%v)", result.Metadata, err)
+	}
+	fmt.Println("✓ page and page_size arguments applied")
+
+	result, err = registry.Execute(ctx, "shell", map[string]string{"command": "head -c 10 /dev/zero | tr '\\0' 'y'"})
+	if err != nil || result.TextResult != "yyyyyyyyyy" {
+		return fmt.Errorf("expected short result unpaginated, got %q (%v)", result.TextResult, err)
+	}
+	for _, token := range []string{"", "missing-2", "nonsense"} {
+		if _, err := registry.Execute(ctx, "shell", map[string]string{"operation": OperationPaginate, ArgNextPageToken: token}); err == nil {
+			return fmt.Errorf("expected token %q to be rejected", token)
+		}
+	}
+	fmt.Println("✓ Short results unpaginated and unknown tokens rejected")
+
+	if pages := splitPages("aé", 2); len(pages) != 2 || pages[0] != "a" || pages[1] != "é" {
+		return fmt.Errorf("expected pages not to split characters, got %q", pages)
+	}
+
+	return nil
+}
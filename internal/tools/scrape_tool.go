@@ -0,0 +1,500 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/Chang-Augenweide/QuickBot-Go/pkg/types"
+	"golang.org/x/net/html"
+)
+
+const (
+	// scrapeUserAgent is sent with scrape requests and matched against
+	// robots.txt user-agent groups
+	scrapeUserAgent = "QuickBot"
+
+	// scrapeTimeout bounds the robots.txt and page requests together
+	scrapeTimeout = 20 * time.Second
+
+	// maxScrapeBytes is the longest page or robots.txt read
+	maxScrapeBytes = 2 * 1024 * 1024
+
+	// maxScrapeChars is the length at which the extracted text is
+	// truncated
+	maxScrapeChars = 10000
+)
+
+// skippedElements are the elements whose content is not visible text
+var skippedElements = map[string]bool{
+	"script":   true,
+	"style":    true,
+	"noscript": true,
+	"template": true,
+}
+
+// blockElements start a new line of extracted text
+var blockElements = map[string]bool{
+	"address": true, "article": true, "aside": true, "blockquote": true,
+	"br": true, "dd": true, "div": true, "dl": true, "dt": true,
+	"figcaption": true, "footer": true, "form": true, "h1": true, "h2": true,
+	"h3": true, "h4": true, "h5": true, "h6": true, "header": true,
+	"hr": true, "li": true, "main": true, "nav": true, "ol": true,
+	"p": true, "pre": true, "section": true, "table": true, "td": true,
+	"th": true, "title": true, "tr": true, "ul": true,
+}
+
+// WebScrapeTool fetches a web page and returns its visible text. It
+// honors the robots.txt rules for the QuickBot user agent and, like the
+// HTTP tool, only fetches the hosts of an allow list.
+type WebScrapeTool struct {
+	allowList *AllowList
+	client    *http.Client
+}
+
+// NewWebScrapeTool creates a new web scrape tool
+func NewWebScrapeTool(allowList *AllowList) *WebScrapeTool {
+	return &WebScrapeTool{
+		allowList: allowList,
+		client:    newAllowListClient(allowList),
+	}
+}
+
+func (t *WebScrapeTool) Name() string {
+	return "scrape"
+}
+
+func (t *WebScrapeTool) Description() string {
+	return "Fetch a web page and return its visible text, optionally only the elements matching a CSS selector (tag, #id, .class or tag.class)"
+}
+
+func (t *WebScrapeTool) Permission() ToolPermission {
+	return PermissionAllowList
+}
+
+// Params returns the web scrape tool parameters
+func (t *WebScrapeTool) Params() []ToolParam {
+	return []ToolParam{
+		{Name: "url", Type: ParamString, Required: true},
+		{Name: "css_selector", Type: ParamString, Description: "Only extract the text of matching elements: tag, #id, .class or tag.class"},
+	}
+}
+
+// Schema returns the JSON Schema of the web scrape tool arguments
+func (t *WebScrapeTool) Schema() map[string]interface{} {
+	return ParamsSchema(t.Params())
+}
+
+func (t *WebScrapeTool) Execute(ctx context.Context, args map[string]interface{}) (types.ToolResult, error) {
+	rawURL := stringArg(args, "url")
+	if rawURL == "" {
+		return types.ToolResult{}, fmt.Errorf("url is required")
+	}
+	target, err := url.Parse(rawURL)
+	if err != nil {
+		return types.ToolResult{}, fmt.Errorf("invalid url: %w", err)
+	}
+	if err := checkAllowedURL(t.allowList, target); err != nil {
+		return types.ToolResult{}, err
+	}
+
+	var selector *cssSelector
+	if s := strings.TrimSpace(stringArg(args, "css_selector")); s != "" {
+		selector, err = parseCSSSelector(s)
+		if err != nil {
+			return types.ToolResult{}, err
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, scrapeTimeout)
+	defer cancel()
+
+	allowed, err := t.robotsAllowed(ctx, target)
+	if err != nil {
+		return types.ToolResult{}, err
+	}
+	if !allowed {
+		return types.ToolResult{}, fmt.Errorf("robots.txt disallows %s for %s", target.Path, scrapeUserAgent)
+	}
+
+	resp, err := t.get(ctx, target.String())
+	if err != nil {
+		return types.ToolResult{}, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return types.ToolResult{}, fmt.Errorf("failed to fetch %s: %s", target, resp.Status)
+	}
+
+	doc, err := html.Parse(io.LimitReader(resp.Body, maxScrapeBytes))
+	if err != nil {
+		return types.ToolResult{}, fmt.Errorf("failed to parse HTML: %w", err)
+	}
+
+	roots := []*html.Node{doc}
+	if selector != nil {
+		roots = selector.findAll(doc)
+		if len(roots) == 0 {
+			return types.ToolResult{}, fmt.Errorf("no elements match %s", selector)
+		}
+	}
+
+	var text strings.Builder
+	for _, root := range roots {
+		extractText(&text, root)
+		text.WriteString("\n")
+	}
+	visible := normalizeText(text.String())
+
+	truncated := utf8.RuneCountInString(visible) > maxScrapeChars
+	if truncated {
+		visible = string([]rune(visible)[:maxScrapeChars])
+	}
+
+	return types.ToolResult{
+		Success:    true,
+		TextResult: visible,
+		Metadata: map[string]interface{}{
+			"url":       resp.Request.URL.String(),
+			"truncated": truncated,
+		},
+	}, nil
+}
+
+// get sends a GET request with the QuickBot user agent
+func (t *WebScrapeTool) get(ctx context.Context, rawURL string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("User-Agent", scrapeUserAgent)
+
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("request failed: %w", err)
+	}
+	return resp, nil
+}
+
+// robotsAllowed reports whether the robots.txt of target's host allows
+// fetching it. A missing robots.txt allows everything.
+func (t *WebScrapeTool) robotsAllowed(ctx context.Context, target *url.URL) (bool, error) {
+	robotsURL := url.URL{Scheme: target.Scheme, Host: target.Host, Path: "/robots.txt"}
+
+	resp, err := t.get(ctx, robotsURL.String())
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return true, nil
+	}
+
+	rules := parseRobots(io.LimitReader(resp.Body, maxScrapeBytes), scrapeUserAgent)
+	return rules.allows(target.EscapedPath()), nil
+}
+
+// robotsRule is an Allow or Disallow line of robots.txt
+type robotsRule struct {
+	allow bool
+	path  string
+}
+
+// robotsRules are the rules of the robots.txt group that applies to a
+// user agent
+type robotsRules []robotsRule
+
+// parseRobots returns the rules of the group naming userAgent, or of the
+// "*" group if none does
+func parseRobots(r io.Reader, userAgent string) robotsRules {
+	userAgent = strings.ToLower(userAgent)
+
+	var specific, wildcard robotsRules
+	var foundSpecific bool
+	var agents []string
+	inRules := false
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if i := strings.Index(line, "#"); i >= 0 {
+			line = line[:i]
+		}
+		field, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		field = strings.ToLower(strings.TrimSpace(field))
+		value = strings.TrimSpace(value)
+
+		switch field {
+		case "user-agent":
+			// A user-agent line after rules starts a new group
+			if inRules {
+				agents = nil
+				inRules = false
+			}
+			agents = append(agents, strings.ToLower(value))
+		case "allow", "disallow":
+			inRules = true
+			rule := robotsRule{allow: field == "allow", path: value}
+			for _, agent := range agents {
+				switch {
+				case agent == "*":
+					wildcard = append(wildcard, rule)
+				case strings.Contains(userAgent, agent):
+					specific = append(specific, rule)
+					foundSpecific = true
+				}
+			}
+		}
+	}
+
+	if foundSpecific {
+		return specific
+	}
+	return wildcard
+}
+
+// allows reports whether path may be fetched: the longest matching rule
+// applies, Allow winning ties, and an empty Disallow matches nothing
+func (rules robotsRules) allows(path string) bool {
+	if path == "" {
+		path = "/"
+	}
+
+	allowed := true
+	longest := -1
+	for _, rule := range rules {
+		if rule.path == "" || !strings.HasPrefix(path, rule.path) {
+			continue
+		}
+		if len(rule.path) > longest || (len(rule.path) == longest && rule.allow) {
+			allowed = rule.allow
+			longest = len(rule.path)
+		}
+	}
+	return allowed
+}
+
+// cssSelector is a simple selector: a tag name, an id, a class, or a tag
+// with an id or class
+type cssSelector struct {
+	tag   string
+	id    string
+	class string
+}
+
+// parseCSSSelector parses "tag", "#id", ".class", "tag#id" or "tag.class"
+func parseCSSSelector(s string) (*cssSelector, error) {
+	selector := &cssSelector{}
+
+	rest := s
+	if i := strings.IndexAny(rest, "#."); i >= 0 {
+		selector.tag, rest = rest[:i], rest[i:]
+		if strings.HasPrefix(rest, "#") {
+			selector.id = rest[1:]
+		} else {
+			selector.class = rest[1:]
+		}
+	} else {
+		selector.tag = rest
+	}
+	selector.tag = strings.ToLower(selector.tag)
+
+	for _, part := range []string{selector.tag, selector.id, selector.class} {
+		if strings.ContainsAny(part, "#. >+~[]:*,") {
+			return nil, fmt.Errorf("unsupported CSS selector %q: use tag, #id, .class, tag#id or tag.class", s)
+		}
+	}
+	if selector.tag == "" && selector.id == "" && selector.class == "" {
+		return nil, fmt.Errorf("empty CSS selector")
+	}
+	return selector, nil
+}
+
+func (s *cssSelector) String() string {
+	switch {
+	case s.id != "":
+		return s.tag + "#" + s.id
+	case s.class != "":
+		return s.tag + "." + s.class
+	default:
+		return s.tag
+	}
+}
+
+// matches reports whether n is an element matching the selector
+func (s *cssSelector) matches(n *html.Node) bool {
+	if n.Type != html.ElementNode {
+		return false
+	}
+	if s.tag != "" && n.Data != s.tag {
+		return false
+	}
+	if s.id != "" && attr(n, "id") != s.id {
+		return false
+	}
+	if s.class != "" {
+		found := false
+		for _, class := range strings.Fields(attr(n, "class")) {
+			if class == s.class {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// findAll returns the outermost elements under n matching the selector,
+// in document order
+func (s *cssSelector) findAll(n *html.Node) []*html.Node {
+	if s.matches(n) {
+		return []*html.Node{n}
+	}
+	var found []*html.Node
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		found = append(found, s.findAll(c)...)
+	}
+	return found
+}
+
+// attr returns the value of an attribute of n, or "" if it has none
+func attr(n *html.Node, key string) string {
+	for _, a := range n.Attr {
+		if a.Key == key {
+			return a.Val
+		}
+	}
+	return ""
+}
+
+// extractText writes the text of n and its descendants to b, skipping
+// scripts and styles and putting block elements on their own lines
+func extractText(b *strings.Builder, n *html.Node) {
+	switch n.Type {
+	case html.TextNode:
+		b.WriteString(n.Data)
+		return
+	case html.ElementNode:
+		if skippedElements[n.Data] {
+			return
+		}
+	case html.CommentNode, html.DoctypeNode:
+		return
+	}
+
+	block := n.Type == html.ElementNode && blockElements[n.Data]
+	if block {
+		b.WriteString("\n")
+	}
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		extractText(b, c)
+	}
+	if block {
+		b.WriteString("\n")
+	}
+}
+
+// normalizeText collapses the whitespace of each line and drops empty
+// lines
+func normalizeText(text string) string {
+	var lines []string
+	for _, line := range strings.Split(text, "\n") {
+		if line = strings.Join(strings.Fields(line), " "); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// TestWebScrapeTool scrapes pages from a local server, checking text
+// extraction, CSS selectors and robots.txt rules
+func TestWebScrapeTool() error {
+	fmt.Println("Testing web scrape tool...")
+
+	var userAgent string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		userAgent = r.Header.Get("User-Agent")
+		switch r.URL.Path {
+		case "/robots.txt":
+			fmt.Fprint(w, "User-agent: *\nDisallow: /\n\nUser-agent: QuickBot\nDisallow: /private\nAllow: /private/open\n")
+		case "/article", "/private/open":
+			fmt.Fprint(w, `<!DOCTYPE html><html><head><title>News</title><style>body { color: red }</style></head>
+<body><nav>Home | About</nav>
+<div id="content" class="post main"><h1>Go   1.23</h1><p>Iterators are <b>here</b>.</p></div>
+<script>alert("hidden")</script></body></html>`)
+		case "/long":
+			fmt.Fprintf(w, "<p>%s</p>", strings.Repeat("word ", 3000))
+		default:
+			fmt.Fprint(w, "<p>secret</p>")
+		}
+	}))
+	defer server.Close()
+
+	allowList, err := CompileAllowList([]string{"127.0.0.1"})
+	if err != nil {
+		return err
+	}
+	allowList.BlockPrivateIPs = false
+	tool := NewWebScrapeTool(allowList)
+	ctx := context.Background()
+
+	result, err := tool.Execute(ctx, map[string]interface{}{"url": server.URL + "/article"})
+	if err != nil {
+		return err
+	}
+	want := "News\nHome | About\nGo 1.23\nIterators are here."
+	if result.TextResult != want {
+		return fmt.Errorf("expected %q, got %q", want, result.TextResult)
+	}
+	if userAgent != scrapeUserAgent {
+		return fmt.Errorf("expected User-Agent %s, got %s", scrapeUserAgent, userAgent)
+	}
+	fmt.Println("✓ Visible text extracted without scripts and styles")
+
+	for _, selector := range []string{"#content", ".post", "div.main"} {
+		result, err = tool.Execute(ctx, map[string]interface{}{"url": server.URL + "/article", "css_selector": selector})
+		if err != nil || result.TextResult != "Go 1.23\nIterators are here." {
+			return fmt.Errorf("selector %s: unexpected text %q (%v)", selector, result.TextResult, err)
+		}
+	}
+	if _, err := tool.Execute(ctx, map[string]interface{}{"url": server.URL + "/article", "css_selector": "div > p"}); err == nil {
+		return fmt.Errorf("expected unsupported selector to be rejected")
+	}
+	fmt.Println("✓ CSS selector applied")
+
+	if _, err := tool.Execute(ctx, map[string]interface{}{"url": server.URL + "/private/page"}); err == nil || !strings.Contains(err.Error(), "robots.txt") {
+		return fmt.Errorf("expected robots.txt to disallow /private/page, got %v", err)
+	}
+	if _, err := tool.Execute(ctx, map[string]interface{}{"url": server.URL + "/private/open"}); err != nil {
+		return fmt.Errorf("expected robots.txt to allow /private/open, got %v", err)
+	}
+	fmt.Println("✓ robots.txt rules for QuickBot honored")
+
+	result, err = tool.Execute(ctx, map[string]interface{}{"url": server.URL + "/long"})
+	if err != nil || utf8.RuneCountInString(result.TextResult) != maxScrapeChars || result.Metadata["truncated"] != true {
+		return fmt.Errorf("expected text truncated to %d characters (%v)", maxScrapeChars, err)
+	}
+	fmt.Println("✓ Long text truncated")
+
+	if _, err := tool.Execute(ctx, map[string]interface{}{"url": "http://example.com/"}); err == nil {
+		return fmt.Errorf("expected host outside the allow list to be rejected")
+	}
+	fmt.Println("✓ Allow list applied")
+
+	return nil
+}
@@ -0,0 +1,200 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/Chang-Augenweide/QuickBot-Go/pkg/types"
+	"gopkg.in/yaml.v3"
+)
+
+// ToolParam describes a tool parameter
+type ToolParam struct {
+	Name        string `yaml:"name" json:"name"`
+	Type        string `yaml:"type,omitempty" json:"type,omitempty"` // string, int, float, bool or json
+	Description string `yaml:"description,omitempty" json:"description,omitempty"`
+	Required    bool   `yaml:"required,omitempty" json:"required,omitempty"`
+}
+
+// ScriptDefinition describes a script-backed tool
+type ScriptDefinition struct {
+	Name        string      `yaml:"name" json:"name"`
+	Description string      `yaml:"description" json:"description"`
+	Script      string      `yaml:"script" json:"script"`
+	Params      []ToolParam `yaml:"params,omitempty" json:"params,omitempty"`
+	Persist     bool        `yaml:"persist,omitempty" json:"persist,omitempty"`
+}
+
+// ErrBuiltinTool is returned when a script tool would replace or remove a
+// tool that is not a script tool, such as the file, shell or memory tool
+var ErrBuiltinTool = errors.New("tool is built in")
+
+var toolNamePattern = regexp.MustCompile(`^[a-zA-Z][a-zA-Z0-9_-]*$`)
+
+// Validate validates the script definition
+func (d *ScriptDefinition) Validate() error {
+	if !toolNamePattern.MatchString(d.Name) {
+		return fmt.Errorf("invalid tool name: %q", d.Name)
+	}
+	if d.Script == "" {
+		return fmt.Errorf("script is required")
+	}
+	return validateParams(d.Params)
+}
+
+// validateParams checks the names and types of tool parameters
+func validateParams(params []ToolParam) error {
+	for _, param := range params {
+		if !toolNamePattern.MatchString(param.Name) {
+			return fmt.Errorf("invalid parameter name: %q", param.Name)
+		}
+		switch param.Type {
+		case "", ParamString, ParamInt, ParamFloat, ParamBool, ParamJSON:
+		default:
+			return fmt.Errorf("invalid type %q for parameter %s", param.Type, param.Name)
+		}
+	}
+	return nil
+}
+
+// ScriptTool runs a shell script with {{param}} placeholders replaced by
+// the (shell-quoted) argument values. JSON arguments are substituted as
+// JSON.
+type ScriptTool struct {
+	definition ScriptDefinition
+	permission ToolPermission
+}
+
+// NewScriptTool creates a new script tool from a definition
+func NewScriptTool(definition ScriptDefinition) (*ScriptTool, error) {
+	if err := definition.Validate(); err != nil {
+		return nil, err
+	}
+
+	return &ScriptTool{
+		definition: definition,
+		permission: PermissionAllowList,
+	}, nil
+}
+
+func (t *ScriptTool) Name() string {
+	return t.definition.Name
+}
+
+func (t *ScriptTool) Description() string {
+	return t.definition.Description
+}
+
+func (t *ScriptTool) Permission() ToolPermission {
+	return t.permission
+}
+
+// Params returns the tool parameters
+func (t *ScriptTool) Params() []ToolParam {
+	return t.definition.Params
+}
+
+// Schema returns the JSON Schema of the tool arguments
+func (t *ScriptTool) Schema() map[string]interface{} {
+	return ParamsSchema(t.Params())
+}
+
+// Definition returns the tool definition
+func (t *ScriptTool) Definition() ScriptDefinition {
+	return t.definition
+}
+
+func (t *ScriptTool) Execute(ctx context.Context, args map[string]interface{}) (types.ToolResult, error) {
+	script := t.definition.Script
+
+	for _, param := range t.definition.Params {
+		value, ok := args[param.Name]
+		if !ok && param.Required {
+			return types.ToolResult{}, fmt.Errorf("missing required parameter: %s", param.Name)
+		}
+		script = strings.ReplaceAll(script, "{{"+param.Name+"}}", shellQuote(formatArg(value)))
+	}
+
+	cmd := exec.CommandContext(ctx, "bash", "-c", script)
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return types.ToolResult{}, fmt.Errorf("script failed: %v\n%s", err, string(output))
+	}
+
+	return types.ToolResult{
+		Success:    true,
+		TextResult: string(output),
+		Metadata:   map[string]interface{}{"script": t.definition.Name},
+	}, nil
+}
+
+// RegisterScript registers a script tool, replacing the script tool of the
+// same name, if any. Built-in tools are never replaced.
+func (r *ToolRegistry) RegisterScript(tool *ScriptTool) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if existing, exists := r.tools[tool.Name()]; exists {
+		if _, ok := existing.(*ScriptTool); !ok {
+			return fmt.Errorf("%w: %s", ErrBuiltinTool, tool.Name())
+		}
+	}
+	r.tools[tool.Name()] = tool
+	return nil
+}
+
+// UnregisterScript removes a script tool and reports whether it was
+// registered. Built-in tools are never removed.
+func (r *ToolRegistry) UnregisterScript(name string) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	existing, exists := r.tools[name]
+	if !exists {
+		return false, nil
+	}
+	if _, ok := existing.(*ScriptTool); !ok {
+		return false, fmt.Errorf("%w: %s", ErrBuiltinTool, name)
+	}
+	delete(r.tools, name)
+	return true, nil
+}
+
+// shellQuote quotes a value for safe use as a single shell word
+func shellQuote(value string) string {
+	return "'" + strings.ReplaceAll(value, "'", `'\''`) + "'"
+}
+
+// SaveScriptDefinition writes a definition to dir as <name>.tool.yaml
+func SaveScriptDefinition(dir string, definition ScriptDefinition) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create definitions directory: %w", err)
+	}
+
+	data, err := yaml.Marshal(definition)
+	if err != nil {
+		return fmt.Errorf("failed to marshal tool definition: %w", err)
+	}
+
+	path := filepath.Join(dir, definition.Name+".tool.yaml")
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return fmt.Errorf("failed to write tool definition: %w", err)
+	}
+
+	return nil
+}
+
+// RemoveScriptDefinition removes a persisted definition, if any
+func RemoveScriptDefinition(dir, name string) error {
+	err := os.Remove(filepath.Join(dir, name+".tool.yaml"))
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove tool definition: %w", err)
+	}
+	return nil
+}
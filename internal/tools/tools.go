@@ -1,11 +1,21 @@
 package main
 
 import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"log"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"github.com/Chang-Augenweide/QuickBot-Go/pkg/types"
 )
 
 // ToolPermission represents tool permission levels
@@ -22,7 +32,20 @@ type Tool interface {
 	Name() string
 	Description() string
 	Permission() ToolPermission
-	Execute(args map[string]string) (string, error)
+
+	// Schema returns the JSON Schema of the tool's arguments
+	Schema() map[string]interface{}
+
+	Execute(ctx context.Context, args map[string]interface{}) (types.ToolResult, error)
+}
+
+// StreamingTool is a tool that can send its output while it runs
+type StreamingTool interface {
+	Tool
+
+	// ExecuteStream executes the tool, sending output to out as it is
+	// produced. It does not close out.
+	ExecuteStream(ctx context.Context, args map[string]interface{}, out chan<- string) error
 }
 
 // FileTool handles file operations
@@ -43,59 +66,128 @@ func (t *FileTool) Name() string {
 }
 
 func (t *FileTool) Description() string {
-	return "Read, write, and list files"
+	return "Read, write, append, move, stat, list, and search files"
 }
 
 func (t *FileTool) Permission() ToolPermission {
 	return t.permission
 }
 
-func (t *FileTool) Execute(args map[string]string) (string, error) {
-	operation := args["operation"]
-	path := args["path"]
-	content := args["content"]
+// Params returns the file tool parameters
+func (t *FileTool) Params() []ToolParam {
+	return []ToolParam{
+		{Name: "operation", Type: ParamString, Required: true},
+		{Name: "path", Type: ParamString},
+		{Name: "content", Type: ParamString},
+		{Name: "pattern", Type: ParamString},
+		{Name: "max_results", Type: ParamInt},
+		{Name: "recursive", Type: ParamBool},
+		{Name: "max_total_bytes", Type: ParamInt},
+		{Name: "path_a", Type: ParamString},
+		{Name: "path_b", Type: ParamString},
+		{Name: "destination", Type: ParamString, Description: "Destination path for move"},
+	}
+}
+
+// Schema returns the JSON Schema of the file tool arguments
+func (t *FileTool) Schema() map[string]interface{} {
+	return ParamsSchema(t.Params())
+}
 
-	fullPath := filepath.Join(t.baseDir, path)
+func (t *FileTool) Execute(ctx context.Context, args map[string]interface{}) (types.ToolResult, error) {
+	operation := stringArg(args, "operation")
+	path := stringArg(args, "path")
+	content := stringArg(args, "content")
 
-	// Ensure path is within base directory
-	absPath, err := filepath.Abs(fullPath)
+	absPath, err := t.ResolvePath(path)
 	if err != nil {
-		return "", err
+		return types.ToolResult{}, err
 	}
 
 	absBaseDir, err := filepath.Abs(t.baseDir)
 	if err != nil {
-		return "", err
-	}
-
-	if !strings.HasPrefix(absPath, absBaseDir) {
-		return "", fmt.Errorf("access denied: path outside base directory")
+		return types.ToolResult{}, err
 	}
 
 	switch operation {
 	case "read":
 		data, err := os.ReadFile(absPath)
 		if err != nil {
-			return "", err
+			return types.ToolResult{}, err
 		}
-		return string(data), nil
+		return textResult(string(data), nil)
 
 	case "write":
 		if err := os.MkdirAll(filepath.Dir(absPath), 0755); err != nil {
-			return "", err
+			return types.ToolResult{}, err
 		}
 		if err := os.WriteFile(absPath, []byte(content), 0644); err != nil {
-			return "", err
+			return types.ToolResult{}, err
+		}
+		result, _ := textResult(fmt.Sprintf("Success: Written to %s", path), nil)
+		result.Metadata = map[string]interface{}{"bytes": len(content)}
+		return result, nil
+
+	case "append":
+		if err := os.MkdirAll(filepath.Dir(absPath), 0755); err != nil {
+			return types.ToolResult{}, err
+		}
+		file, err := os.OpenFile(absPath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+		if err != nil {
+			return types.ToolResult{}, err
+		}
+		if _, err := file.WriteString(content); err != nil {
+			file.Close()
+			return types.ToolResult{}, err
+		}
+		if err := file.Close(); err != nil {
+			return types.ToolResult{}, err
+		}
+		result, _ := textResult(fmt.Sprintf("Success: Appended to %s", path), nil)
+		result.Metadata = map[string]interface{}{"bytes": len(content)}
+		return result, nil
+
+	case "move":
+		destination := stringArg(args, "destination")
+		if path == "" || destination == "" {
+			return types.ToolResult{}, fmt.Errorf("path and destination are required")
 		}
-		return fmt.Sprintf("Success: Written to %s", path), nil
+		absDestination, err := t.ResolvePath(destination)
+		if err != nil {
+			return types.ToolResult{}, err
+		}
+		if absPath == absBaseDir || absDestination == absBaseDir {
+			return types.ToolResult{}, fmt.Errorf("access denied: cannot move the base directory")
+		}
+		if err := os.MkdirAll(filepath.Dir(absDestination), 0755); err != nil {
+			return types.ToolResult{}, err
+		}
+		if err := os.Rename(absPath, absDestination); err != nil {
+			return types.ToolResult{}, err
+		}
+		return textResult(fmt.Sprintf("Success: Moved %s to %s", path, destination), nil)
+
+	case "stat":
+		fileInfo, err := os.Stat(absPath)
+		if err != nil {
+			return types.ToolResult{}, err
+		}
+		return types.ToolResult{
+			Success: true,
+			JSONResult: map[string]interface{}{
+				"size":     fileInfo.Size(),
+				"mod_time": fileInfo.ModTime().UTC().Format(time.RFC3339),
+				"is_dir":   fileInfo.IsDir(),
+			},
+		}, nil
 
 	case "list":
 		entries, err := os.ReadDir(absPath)
 		if err != nil {
-			return "", err
+			return types.ToolResult{}, err
 		}
 
-		var items []string
+		items := []string{}
 		for _, entry := range entries {
 			name := entry.Name()
 			if entry.IsDir() {
@@ -104,44 +196,88 @@ func (t *FileTool) Execute(args map[string]string) (string, error) {
 			items = append(items, name)
 		}
 
-		if len(items) == 0 {
-			return "(empty)", nil
+		text := "(empty)"
+		if len(items) > 0 {
+			text = strings.Join(items, "\n")
 		}
-		return strings.Join(items, "\n"), nil
+		return types.ToolResult{Success: true, TextResult: text, JSONResult: items}, nil
 
 	case "delete":
 		fileInfo, err := os.Stat(absPath)
 		if err != nil {
-			return "", err
+			return types.ToolResult{}, err
 		}
 
 		if fileInfo.IsDir() {
 			if err := os.RemoveAll(absPath); err != nil {
-				return "", err
+				return types.ToolResult{}, err
 			}
 		} else {
 			if err := os.Remove(absPath); err != nil {
-				return "", err
+				return types.ToolResult{}, err
 			}
 		}
 
-		return fmt.Sprintf("Success: Deleted %s", path), nil
+		return textResult(fmt.Sprintf("Success: Deleted %s", path), nil)
+
+	case "grep", "count":
+		return textResult(t.search(absBaseDir, absPath, args, operation == "count"))
+
+	case "read_glob":
+		return textResult(t.readGlob(absBaseDir, args))
+
+	case "diff":
+		return textResult(t.diffFiles(absBaseDir, args))
 
 	default:
-		return "", fmt.Errorf("unknown operation: %s", operation)
+		return types.ToolResult{}, fmt.Errorf("unknown operation: %s", operation)
+	}
+}
+
+// ResolvePath returns the absolute path of a path relative to the base
+// directory, or an error if it lies outside the base directory
+func (t *FileTool) ResolvePath(path string) (string, error) {
+	absPath, err := filepath.Abs(filepath.Join(t.baseDir, path))
+	if err != nil {
+		return "", err
+	}
+
+	absBaseDir, err := filepath.Abs(t.baseDir)
+	if err != nil {
+		return "", err
+	}
+
+	if !isWithinDir(absBaseDir, absPath) {
+		return "", fmt.Errorf("access denied: path outside base directory")
 	}
+	return absPath, nil
 }
 
+// defaultShellTimeout is the shell command timeout when none is configured
+const defaultShellTimeout = 30 * time.Second
+
 // ShellTool handles shell command execution
 type ShellTool struct {
 	allowedCommands []string
 	permission      ToolPermission
+	workDir         string
+	timeout         time.Duration
 }
 
-func NewShellTool(allowedCommands []string) *ShellTool {
+// NewShellTool creates a shell tool running commands in workDir (default:
+// the system temp directory) and killing them after timeout (default: 30s)
+func NewShellTool(allowedCommands []string, workDir string, timeout time.Duration) *ShellTool {
+	if workDir == "" {
+		workDir = os.TempDir()
+	}
+	if timeout <= 0 {
+		timeout = defaultShellTimeout
+	}
 	return &ShellTool{
 		allowedCommands: allowedCommands,
 		permission:      PermissionAllowList,
+		workDir:         workDir,
+		timeout:         timeout,
 	}
 }
 
@@ -157,18 +293,128 @@ func (t *ShellTool) Permission() ToolPermission {
 	return t.permission
 }
 
-func (t *ShellTool) Execute(args map[string]string) (string, error) {
-	command := args["command"]
+// Params returns the shell tool parameters
+func (t *ShellTool) Params() []ToolParam {
+	return []ToolParam{
+		{Name: "command", Type: ParamString, Description: "Allowed commands: " + strings.Join(t.allowedCommands, ", "), Required: true},
+	}
+}
+
+// Schema returns the JSON Schema of the shell tool arguments
+func (t *ShellTool) Schema() map[string]interface{} {
+	return ParamsSchema(t.Params())
+}
+
+func (t *ShellTool) Execute(ctx context.Context, args map[string]interface{}) (types.ToolResult, error) {
+	command := stringArg(args, "command")
+	if err := t.checkCommand(command); err != nil {
+		return types.ToolResult{}, err
+	}
+
+	if err := t.checkWorkDir(); err != nil {
+		return types.ToolResult{}, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, t.timeout)
+	defer cancel()
+
+	// Execute command
+	cmd := t.command(ctx, command)
+	output, err := cmd.CombinedOutput()
+	if ctx.Err() == context.DeadlineExceeded {
+		return types.ToolResult{}, fmt.Errorf("command timed out after %v\n%s", t.timeout, string(output))
+	}
+	if err != nil {
+		return types.ToolResult{}, fmt.Errorf("command failed: %v\n%s", err, string(output))
+	}
+
+	return types.ToolResult{
+		Success:    true,
+		TextResult: string(output),
+		Metadata:   map[string]interface{}{"exit_code": cmd.ProcessState.ExitCode()},
+	}, nil
+}
+
+// ExecuteStream runs the command, sending its combined output to out line
+// by line. The command is killed when ctx is done.
+func (t *ShellTool) ExecuteStream(ctx context.Context, args map[string]interface{}, out chan<- string) error {
+	command := stringArg(args, "command")
+	if err := t.checkCommand(command); err != nil {
+		return err
+	}
+	if err := t.checkWorkDir(); err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, t.timeout)
+	defer cancel()
+
+	cmd := t.command(ctx, command)
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return fmt.Errorf("failed to open command output: %w", err)
+	}
+	cmd.Stderr = cmd.Stdout
+
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("failed to start command: %w", err)
+	}
+
+	scanner := bufio.NewScanner(stdout)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		select {
+		case out <- scanner.Text() + "\n":
+		case <-ctx.Done():
+		}
+	}
+	scanErr := scanner.Err()
+
+	if err := cmd.Wait(); err != nil {
+		if ctx.Err() == context.DeadlineExceeded {
+			return fmt.Errorf("command timed out after %v", t.timeout)
+		}
+		return fmt.Errorf("command failed: %v", err)
+	}
+	if scanErr != nil {
+		return fmt.Errorf("failed to read command output: %w", scanErr)
+	}
+	return nil
+}
+
+// command creates the bash process for a command in the working
+// directory. It is killed when ctx is done, and its output pipes are
+// closed shortly after even if child processes still hold them.
+func (t *ShellTool) command(ctx context.Context, command string) *exec.Cmd {
+	cmd := exec.CommandContext(ctx, "bash", "-c", command)
+	cmd.Dir = t.workDir
+	cmd.WaitDelay = time.Second
+	return cmd
+}
+
+// checkWorkDir checks that the working directory exists
+func (t *ShellTool) checkWorkDir() error {
+	info, err := os.Stat(t.workDir)
+	if err != nil {
+		return fmt.Errorf("invalid shell working directory: %w", err)
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("invalid shell working directory: %s is not a directory", t.workDir)
+	}
+	return nil
+}
 
+// checkCommand checks that a command is not empty and is allowed
+func (t *ShellTool) checkCommand(command string) error {
 	if command == "" {
-		return "", fmt.Errorf("empty command")
+		return fmt.Errorf("empty command")
 	}
 
 	// Check if command is allowed
 	if len(t.allowedCommands) > 0 {
 		cmdParts := strings.Fields(command)
 		if len(cmdParts) == 0 {
-			return "", fmt.Errorf("invalid command")
+			return fmt.Errorf("invalid command")
 		}
 
 		baseCmd := cmdParts[0]
@@ -181,18 +427,11 @@ func (t *ShellTool) Execute(args map[string]string) (string, error) {
 		}
 
 		if !allowed {
-			return "", fmt.Errorf("command not allowed: %s", baseCmd)
+			return fmt.Errorf("command not allowed: %s", baseCmd)
 		}
 	}
 
-	// Execute command
-	cmd := exec.Command("bash", "-c", command)
-	output, err := cmd.CombinedOutput()
-	if err != nil {
-		return "", fmt.Errorf("command failed: %v\n%s", err, string(output))
-	}
-
-	return string(output), nil
+	return nil
 }
 
 // MemoryTool handles memory operations
@@ -206,50 +445,196 @@ func NewMemoryTool(memory *Memory) *MemoryTool {
 	}
 }
 
+// linkSource records the message that made the tool store keys, if ctx
+// carries one
+func (t *MemoryTool) linkSource(ctx context.Context, keys ...string) {
+	messageID, ok := types.SourceMessageIDFromContext(ctx)
+	if !ok {
+		return
+	}
+	if err := t.memory.LinkLongTermToMessage(messageID, keys...); err != nil {
+		log.Printf("Warning: %v", err)
+	}
+}
+
 func (t *MemoryTool) Name() string {
 	return "memory"
 }
 
 func (t *MemoryTool) Description() string {
-	return "Store and retrieve long-term information"
+	return "Store, retrieve, list, search, and delete long-term information"
 }
 
 func (t *MemoryTool) Permission() ToolPermission {
 	return PermissionAllowAll
 }
 
-func (t *MemoryTool) Execute(args map[string]string) (string, error) {
-	operation := args["operation"]
-	key := args["key"]
-	value := args["value"]
+// Params returns the memory tool parameters
+func (t *MemoryTool) Params() []ToolParam {
+	return []ToolParam{
+		{Name: "operation", Type: ParamString, Required: true},
+		{Name: "key", Type: ParamString},
+		{Name: "value", Type: ParamString},
+		{Name: "prefix", Type: ParamString},
+		{Name: "query", Type: ParamString},
+		{Name: "limit", Type: ParamInt},
+		{Name: "values", Type: ParamString},
+		{Name: "expires_in", Type: ParamString},
+	}
+}
+
+// Schema returns the JSON Schema of the memory tool arguments
+func (t *MemoryTool) Schema() map[string]interface{} {
+	return ParamsSchema(t.Params())
+}
+
+func (t *MemoryTool) Execute(ctx context.Context, args map[string]interface{}) (types.ToolResult, error) {
+	operation := stringArg(args, "operation")
+	key := stringArg(args, "key")
+	value := stringArg(args, "value")
 
 	switch operation {
 	case "set":
 		if key == "" || value == "" {
-			return "", fmt.Errorf("key and value required")
+			return types.ToolResult{}, fmt.Errorf("key and value required")
+		}
+		// expires_in is a duration such as "24h"; without it the memory
+		// never expires
+		var ttl time.Duration
+		if expiresIn := stringArg(args, "expires_in"); expiresIn != "" {
+			var err error
+			ttl, err = time.ParseDuration(expiresIn)
+			if err != nil || ttl <= 0 {
+				return types.ToolResult{}, fmt.Errorf("expires_in must be a positive duration such as 24h: %q", expiresIn)
+			}
 		}
-		err := t.memory.SetLongTerm(key, value, 2)
+		err := t.memory.SetLongTerm(key, value, 2, ttl)
 		if err != nil {
-			return "", err
+			return types.ToolResult{}, err
 		}
-		return fmt.Sprintf("Success: Remembered '%s'", key), nil
+		t.linkSource(ctx, key)
+		return textResult(fmt.Sprintf("Success: Remembered '%s'", key), nil)
 
 	case "get":
 		if key == "" {
-			return "", fmt.Errorf("key required")
+			return types.ToolResult{}, fmt.Errorf("key required")
 		}
 		value, err := t.memory.GetLongTerm(key)
+		if errors.Is(err, ErrExpired) {
+			return types.ToolResult{
+				Success:    true,
+				TextResult: fmt.Sprintf("Info: Memory for '%s' has expired", key),
+				Metadata:   map[string]interface{}{"found": false, "expired": true},
+			}, nil
+		}
 		if err != nil {
-			return "", err
+			return types.ToolResult{}, err
 		}
 		if value == "" {
-			return fmt.Sprintf("Info: No memory for '%s'", key), nil
+			return types.ToolResult{
+				Success:    true,
+				TextResult: fmt.Sprintf("Info: No memory for '%s'", key),
+				Metadata:   map[string]interface{}{"found": false},
+			}, nil
+		}
+		return types.ToolResult{
+			Success:    true,
+			TextResult: value,
+			Metadata:   map[string]interface{}{"found": true},
+		}, nil
+
+	case "delete":
+		if key == "" {
+			return types.ToolResult{}, fmt.Errorf("key required")
+		}
+		if err := t.memory.DeleteLongTerm(key); err != nil {
+			return types.ToolResult{}, err
+		}
+		return textResult(fmt.Sprintf("Success: Forgot '%s'", key), nil)
+
+	case "list":
+		limit, err := positiveIntArg(args, "limit", defaultMemoryListLimit)
+		if err != nil {
+			return types.ToolResult{}, err
+		}
+		entries, err := t.memory.ListLongTerm(stringArg(args, "prefix"), limit)
+		if err != nil {
+			return types.ToolResult{}, err
+		}
+		return types.ToolResult{
+			Success:    true,
+			TextResult: formatLongTermEntries(entries),
+			JSONResult: entries,
+		}, nil
+
+	case "search":
+		query := stringArg(args, "query")
+		limit, err := positiveIntArg(args, "limit", defaultMemoryListLimit)
+		if err != nil {
+			return types.ToolResult{}, err
+		}
+		entries, err := t.memory.SearchLongTerm(query, limit)
+		if err != nil {
+			return types.ToolResult{}, err
+		}
+
+		text := fmt.Sprintf("Info: No memory matches '%s'", query)
+		if len(entries) > 0 {
+			var lines []string
+			for _, entry := range entries {
+				lines = append(lines, fmt.Sprintf("%s: %s", entry.Key, entry.Value))
+			}
+			text = strings.Join(lines, "\n")
 		}
-		return value, nil
+		return types.ToolResult{Success: true, TextResult: text, JSONResult: entries}, nil
+
+	case "bulk_set":
+		var entries map[string]string
+		if err := json.Unmarshal([]byte(stringArg(args, "values")), &entries); err != nil {
+			return types.ToolResult{}, fmt.Errorf("values must be a JSON object of strings: %w", err)
+		}
+		if len(entries) == 0 {
+			return types.ToolResult{}, fmt.Errorf("values required")
+		}
+		for key, value := range entries {
+			if key == "" || value == "" {
+				return types.ToolResult{}, fmt.Errorf("keys and values must not be empty")
+			}
+		}
+		if err := t.memory.SetLongTermBatch(entries, 2); err != nil {
+			return types.ToolResult{}, err
+		}
+		keys := make([]string, 0, len(entries))
+		for key := range entries {
+			keys = append(keys, key)
+		}
+		t.linkSource(ctx, keys...)
+		result, _ := textResult(fmt.Sprintf("Success: Remembered %d keys", len(entries)), nil)
+		result.Metadata = map[string]interface{}{"count": len(entries)}
+		return result, nil
 
 	default:
-		return "", fmt.Errorf("unknown operation: %s", operation)
+		return types.ToolResult{}, fmt.Errorf("unknown operation: %s", operation)
+	}
+}
+
+// defaultMemoryListLimit caps list and search results when limit is not set
+const defaultMemoryListLimit = 50
+
+// formatLongTermEntries formats long-term memories as a table
+func formatLongTermEntries(entries []LongTermEntry) string {
+	if len(entries) == 0 {
+		return "(empty)"
 	}
+
+	var buf strings.Builder
+	w := tabwriter.NewWriter(&buf, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "KEY\tIMPORTANCE\tUPDATED")
+	for _, entry := range entries {
+		fmt.Fprintf(w, "%s\t%d\t%s\n", entry.Key, entry.Importance, entry.UpdatedAt.Format("2006-01-02 15:04"))
+	}
+	w.Flush()
+	return strings.TrimRight(buf.String(), "\n")
 }
 
 // CalculatorTool handles calculations
@@ -264,65 +649,550 @@ func (t *CalculatorTool) Name() string {
 }
 
 func (t *CalculatorTool) Description() string {
-	return "Perform mathematical calculations"
+	return "Perform mathematical calculations: + - * / % ** (power), parentheses, sqrt, abs, floor, ceil, round, log, sin, cos, and variables assigned with \"x = 3; x * 2\""
 }
 
 func (t *CalculatorTool) Permission() ToolPermission {
 	return PermissionAllowAll
 }
 
-func (t *CalculatorTool) Execute(args map[string]string) (string, error) {
-	expression := args["expression"]
+// Params returns the calculator tool parameters
+func (t *CalculatorTool) Params() []ToolParam {
+	return []ToolParam{
+		{Name: "expression", Type: ParamString, Required: true},
+	}
+}
+
+// Schema returns the JSON Schema of the calculator tool arguments
+func (t *CalculatorTool) Schema() map[string]interface{} {
+	return ParamsSchema(t.Params())
+}
+
+func (t *CalculatorTool) Execute(ctx context.Context, args map[string]interface{}) (types.ToolResult, error) {
+	expression := stringArg(args, "expression")
 
 	if expression == "" {
-		return "", fmt.Errorf("expression required")
+		return types.ToolResult{}, fmt.Errorf("expression required")
+	}
+
+	result, err := Evaluate(expression)
+	if err != nil {
+		return types.ToolResult{}, fmt.Errorf("failed to evaluate %s: %w", expression, err)
 	}
 
-	// Note: In production, use a proper expression parser
-	// For now, this is a placeholder
-	return fmt.Sprintf("Result: (calculation of %s)", expression), nil
+	return types.ToolResult{
+		Success:    true,
+		TextResult: formatNumber(result),
+		JSONResult: result,
+		Metadata:   map[string]interface{}{"expression": expression},
+	}, nil
+}
+
+// textResult wraps the text output of a tool as a successful result
+func textResult(output string, err error) (types.ToolResult, error) {
+	if err != nil {
+		return types.ToolResult{}, err
+	}
+	return types.ToolResult{Success: true, TextResult: output}, nil
+}
+
+// FormatToolResult renders a tool result as text for the conversation
+// history: the text result, or the JSON result if there is no text
+func FormatToolResult(result types.ToolResult) string {
+	if result.TextResult != "" || result.JSONResult == nil {
+		return result.TextResult
+	}
+
+	data, err := json.Marshal(result.JSONResult)
+	if err != nil {
+		return fmt.Sprintf("%v", result.JSONResult)
+	}
+	return string(data)
+}
+
+// ToolUsage counts the executions of a tool
+type ToolUsage struct {
+	Calls  int64 `json:"calls"`
+	Errors int64 `json:"errors"`
+}
+
+// ErrorRate returns the fraction of calls that failed
+func (u ToolUsage) ErrorRate() float64 {
+	if u.Calls == 0 {
+		return 0
+	}
+	return float64(u.Errors) / float64(u.Calls)
 }
 
 // ToolRegistry manages tool registration and execution
 type ToolRegistry struct {
 	tools      map[string]Tool
 	permission ToolPermission
+	mu         sync.RWMutex
+
+	usageMu sync.Mutex
+	usage   map[string]ToolUsage
+
+	hooksMu sync.RWMutex
+	hooks   []ToolHook
+
+	middlewareMu sync.RWMutex
+	middleware   []ToolMiddleware
+
+	// Results longer than maxResultBytes are split into pages kept in
+	// results
+	maxResultBytes int
+	results        resultBuffer
 }
 
 func NewToolRegistry() *ToolRegistry {
 	return &ToolRegistry{
 		tools:      make(map[string]Tool),
 		permission: PermissionAllowList,
+		usage:      make(map[string]ToolUsage),
+		results:    resultBuffer{ttl: defaultResultTTL},
 	}
 }
 
 func (r *ToolRegistry) Register(tool Tool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
 	r.tools[tool.Name()] = tool
 }
 
+// Unregister removes a tool and reports whether it was registered
+func (r *ToolRegistry) Unregister(name string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, exists := r.tools[name]; !exists {
+		return false
+	}
+	delete(r.tools, name)
+	return true
+}
+
 func (r *ToolRegistry) Get(name string) Tool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
 	return r.tools[name]
 }
 
 func (r *ToolRegistry) GetAll() map[string]Tool {
-	return r.tools
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	tools := make(map[string]Tool, len(r.tools))
+	for name, tool := range r.tools {
+		tools[name] = tool
+	}
+	return tools
 }
 
-func (r *ToolRegistry) Execute(name string, args map[string]string) (string, error) {
+// Execute executes a tool through the registered hooks and records how
+// long it took in the result's Duration
+func (r *ToolRegistry) Execute(ctx context.Context, name string, args map[string]string) (types.ToolResult, error) {
 	tool := r.Get(name)
 	if tool == nil {
-		return "", fmt.Errorf("tool not found: %s", name)
+		return types.ToolResult{}, fmt.Errorf("tool not found: %s", name)
 	}
 
 	if tool.Permission() == PermissionDenyAll {
-		return "", fmt.Errorf("tool disabled: %s", name)
+		return types.ToolResult{}, fmt.Errorf("tool disabled: %s", name)
 	}
 
 	if r.permission == PermissionDenyAll {
-		return "", fmt.Errorf("all tools disabled")
+		return types.ToolResult{}, fmt.Errorf("all tools disabled")
 	}
 
-	return tool.Execute(args)
+	if args["operation"] == OperationPaginate {
+		return r.nextPage(name, args)
+	}
+	args, page, pageSize, err := pageArgs(args)
+	if err != nil {
+		return types.ToolResult{}, err
+	}
+
+	raw, err := r.runPreHooks(name, args)
+	if err != nil {
+		return types.ToolResult{}, err
+	}
+
+	start := time.Now()
+	result, err := r.runMiddleware(ctx, name, raw, false, func(ctx context.Context, args map[string]string) (types.ToolResult, error) {
+		if err := r.Validate(name, args); err != nil {
+			return types.ToolResult{}, err
+		}
+		typed, err := coerceToolArgs(tool, args)
+		if err != nil {
+			return types.ToolResult{}, err
+		}
+		result, err := tool.Execute(ctx, typed)
+		r.recordUsage(name, err)
+		return result, err
+	})
+	result.Duration = time.Since(start)
+	result, err = r.runPostHooks(name, result, err)
+	if err != nil {
+		return result, err
+	}
+	return r.paginate(name, result, page, pageSize)
+}
+
+// Validate checks args against the JSON Schema of a tool: every required
+// argument must be present and not empty
+func (r *ToolRegistry) Validate(name string, args map[string]string) error {
+	tool := r.Get(name)
+	if tool == nil {
+		return fmt.Errorf("tool not found: %s", name)
+	}
+
+	schemaJSON, err := json.Marshal(tool.Schema())
+	if err != nil {
+		return fmt.Errorf("failed to marshal schema of tool %s: %w", name, err)
+	}
+	var schema struct {
+		Required []string `json:"required"`
+	}
+	if err := json.Unmarshal(schemaJSON, &schema); err != nil {
+		return fmt.Errorf("invalid schema of tool %s: %w", name, err)
+	}
+
+	for _, param := range schema.Required {
+		if strings.TrimSpace(args[param]) == "" {
+			return fmt.Errorf("tool %s: missing required argument: %s", name, param)
+		}
+	}
+	return nil
+}
+
+// Usage returns the execution counts of each tool that has been run
+func (r *ToolRegistry) Usage() map[string]ToolUsage {
+	r.usageMu.Lock()
+	defer r.usageMu.Unlock()
+
+	usage := make(map[string]ToolUsage, len(r.usage))
+	for name, u := range r.usage {
+		usage[name] = u
+	}
+	return usage
+}
+
+// recordUsage counts an execution of a tool and whether it failed
+func (r *ToolRegistry) recordUsage(name string, err error) {
+	r.usageMu.Lock()
+	defer r.usageMu.Unlock()
+
+	u := r.usage[name]
+	u.Calls++
+	if err != nil {
+		u.Errors++
+	}
+	r.usage[name] = u
+}
+
+// ExecuteStream executes a tool, forwarding its output to out while it
+// runs and returning the full output. Tools that do not stream send their
+// formatted result to out in one piece. out is not closed.
+func (r *ToolRegistry) ExecuteStream(ctx context.Context, name string, args map[string]string, out chan<- string) (types.ToolResult, error) {
+	tool := r.Get(name)
+	if tool == nil {
+		return types.ToolResult{}, fmt.Errorf("tool not found: %s", name)
+	}
+
+	streamer, ok := tool.(StreamingTool)
+	if !ok {
+		result, err := r.Execute(ctx, name, args)
+		if text := FormatToolResult(result); err == nil && text != "" {
+			out <- text
+		}
+		return result, err
+	}
+
+	if tool.Permission() == PermissionDenyAll {
+		return types.ToolResult{}, fmt.Errorf("tool disabled: %s", name)
+	}
+
+	if r.permission == PermissionDenyAll {
+		return types.ToolResult{}, fmt.Errorf("all tools disabled")
+	}
+
+	if args["operation"] == OperationPaginate {
+		result, err := r.nextPage(name, args)
+		if err == nil {
+			out <- result.TextResult
+		}
+		return result, err
+	}
+	args, page, pageSize, err := pageArgs(args)
+	if err != nil {
+		return types.ToolResult{}, err
+	}
+
+	raw, err := r.runPreHooks(name, args)
+	if err != nil {
+		return types.ToolResult{}, err
+	}
+
+	start := time.Now()
+
+	// The chain waits for the tool to return, so nothing is sent to out
+	// after ExecuteStream returns
+	result, err := r.runMiddleware(ctx, name, raw, true, func(ctx context.Context, args map[string]string) (types.ToolResult, error) {
+		if err := r.Validate(name, args); err != nil {
+			return types.ToolResult{}, err
+		}
+		typed, err := coerceToolArgs(tool, args)
+		if err != nil {
+			return types.ToolResult{}, err
+		}
+
+		chunks := make(chan string)
+		done := make(chan string)
+		go func() {
+			var output strings.Builder
+			for chunk := range chunks {
+				output.WriteString(chunk)
+				out <- chunk
+			}
+			done <- output.String()
+		}()
+
+		err = streamer.ExecuteStream(ctx, typed, chunks)
+		close(chunks)
+		output := <-done
+		r.recordUsage(name, err)
+
+		if err != nil {
+			return types.ToolResult{}, fmt.Errorf("%w\n%s", err, output)
+		}
+		return types.ToolResult{Success: true, TextResult: output}, nil
+	})
+	result.Duration = time.Since(start)
+	result, err = r.runPostHooks(name, result, err)
+	if err != nil {
+		return result, err
+	}
+
+	// The whole output was streamed; only the result kept in the
+	// conversation is paginated
+	return r.paginate(name, result, page, pageSize)
+}
+
+// TestShellTool checks the shell tool working directory and timeout
+func TestShellTool() error {
+	fmt.Println("Testing shell tool...")
+
+	workDir, err := os.MkdirTemp("", "quickbot-shell")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(workDir)
+	ctx := context.Background()
+
+	tool := NewShellTool([]string{"pwd", "echo"}, workDir, 200*time.Millisecond)
+	result, err := tool.Execute(ctx, map[string]interface{}{"command": "pwd"})
+	if err != nil {
+		return err
+	}
+	want, _ := filepath.EvalSymlinks(workDir)
+	if got, _ := filepath.EvalSymlinks(strings.TrimSpace(result.TextResult)); got != want {
+		return fmt.Errorf("expected command to run in %s, got %s", want, got)
+	}
+	fmt.Println("✓ Command run in working directory")
+
+	start := time.Now()
+	_, err = tool.Execute(ctx, map[string]interface{}{"command": "echo started; sleep 5"})
+	if err == nil || !strings.Contains(err.Error(), "timed out") || !strings.Contains(err.Error(), "started") {
+		return fmt.Errorf("expected timeout error with partial output, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 3*time.Second {
+		return fmt.Errorf("expected command to be killed after the timeout, took %v", elapsed)
+	}
+	fmt.Println("✓ Command killed after timeout with partial output")
+
+	missing := NewShellTool([]string{"pwd"}, filepath.Join(workDir, "missing"), time.Second)
+	if _, err := missing.Execute(ctx, map[string]interface{}{"command": "pwd"}); err == nil {
+		return fmt.Errorf("expected missing working directory to be rejected")
+	}
+	fmt.Println("✓ Missing working directory rejected")
+
+	return nil
+}
+
+// TestFileOperations checks the append, move and stat operations of the
+// file tool, and that none of them reach outside the base directory
+func TestFileOperations() error {
+	fmt.Println("Testing file operations...")
+
+	baseDir, err := os.MkdirTemp("", "quickbot-file-ops")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(baseDir)
+	tool := NewFileTool(filepath.Join(baseDir, "base"))
+	ctx := context.Background()
+
+	for _, content := range []string{"first\n", "second\n"} {
+		if _, err := tool.Execute(ctx, map[string]interface{}{"operation": "append", "path": "notes/log.txt", "content": content}); err != nil {
+			return err
+		}
+	}
+	data, err := os.ReadFile(filepath.Join(baseDir, "base", "notes", "log.txt"))
+	if err != nil || string(data) != "first\nsecond\n" {
+		return fmt.Errorf("expected appended content, got %q (%v)", data, err)
+	}
+	fmt.Println("✓ Content appended")
+
+	if _, err := tool.Execute(ctx, map[string]interface{}{"operation": "move", "path": "notes/log.txt", "destination": "archive/log.txt"}); err != nil {
+		return err
+	}
+	if _, err := os.Stat(filepath.Join(baseDir, "base", "notes", "log.txt")); !os.IsNotExist(err) {
+		return fmt.Errorf("expected source to be gone after move")
+	}
+	fmt.Println("✓ File moved")
+
+	result, err := tool.Execute(ctx, map[string]interface{}{"operation": "stat", "path": "archive/log.txt"})
+	if err != nil {
+		return err
+	}
+	stat, _ := result.JSONResult.(map[string]interface{})
+	if stat["size"] != int64(len("first\nsecond\n")) || stat["is_dir"] != false || stat["mod_time"] == "" {
+		return fmt.Errorf("unexpected stat result: %v", stat)
+	}
+	result, err = tool.Execute(ctx, map[string]interface{}{"operation": "stat", "path": "archive"})
+	if stat, _ := result.JSONResult.(map[string]interface{}); err != nil || stat["is_dir"] != true {
+		return fmt.Errorf("expected archive to be a directory: %v (%v)", result.JSONResult, err)
+	}
+	fmt.Println("✓ File stat returned")
+
+	escapes := []struct {
+		name string
+		args map[string]interface{}
+	}{
+		{"append to parent", map[string]interface{}{"operation": "append", "path": "../escape.txt", "content": "x"}},
+		{"append through subdirectory", map[string]interface{}{"operation": "append", "path": "notes/../../escape.txt", "content": "x"}},
+		{"move source outside", map[string]interface{}{"operation": "move", "path": "../escape.txt", "destination": "inside.txt"}},
+		{"move destination outside", map[string]interface{}{"operation": "move", "path": "archive/log.txt", "destination": "../escape.txt"}},
+		{"move into sibling directory", map[string]interface{}{"operation": "move", "path": "archive/log.txt", "destination": "../base2/log.txt"}},
+		{"move base directory", map[string]interface{}{"operation": "move", "path": ".", "destination": "moved"}},
+		{"stat parent", map[string]interface{}{"operation": "stat", "path": ".."}},
+		{"stat absolute escape", map[string]interface{}{"operation": "stat", "path": "archive/../../../etc/passwd"}},
+	}
+	for _, test := range escapes {
+		if _, err := tool.Execute(ctx, test.args); err == nil {
+			return fmt.Errorf("%s: expected access to be denied", test.name)
+		}
+	}
+	for _, name := range []string{"escape.txt", "base2"} {
+		if _, err := os.Stat(filepath.Join(baseDir, name)); !os.IsNotExist(err) {
+			return fmt.Errorf("%s was created outside the base directory", name)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(baseDir, "base", "archive", "log.txt")); err != nil {
+		return fmt.Errorf("expected archive/log.txt to stay in place: %v", err)
+	}
+	fmt.Printf("✓ %d escape attempts denied\n", len(escapes))
+
+	return nil
+}
+
+// TestMemoryTool checks the bulk_set, list, search and delete operations of
+// the memory tool, using bulk_set to store 5 keys
+func TestMemoryTool() error {
+	fmt.Println("Testing memory tool...")
+
+	dbPath := filepath.Join(os.TempDir(), "test_memory_tool.db")
+	defer os.Remove(dbPath)
+	memory, err := NewMemory(dbPath, 0)
+	if err != nil {
+		return err
+	}
+	defer memory.Close()
+
+	tool := NewMemoryTool(memory)
+	run := func(raw map[string]string) (types.ToolResult, error) {
+		args, err := CoerceArgs(tool.Params(), raw)
+		if err != nil {
+			return types.ToolResult{}, err
+		}
+		return tool.Execute(context.Background(), args)
+	}
+
+	values := `{"user_name": "Ada", "user_city": "London", "user_language": "Go",` +
+		` "pet_name": "Rex", "favorite_food": "fresh pasta"}`
+	result, err := run(map[string]string{"operation": "bulk_set", "values": values})
+	if err != nil {
+		return err
+	}
+	if result.Metadata["count"] != 5 {
+		return fmt.Errorf("expected 5 keys stored, got %v", result.Metadata["count"])
+	}
+	if _, err := run(map[string]string{"operation": "bulk_set", "values": `["not", "an", "object"]`}); err == nil {
+		return fmt.Errorf("expected values that are not a JSON object to be rejected")
+	}
+	if _, err := run(map[string]string{"operation": "bulk_set", "values": `{"empty": ""}`}); err == nil {
+		return fmt.Errorf("expected an empty value to be rejected")
+	}
+	fmt.Println("✓ bulk_set stored 5 keys")
+
+	keys := func(result types.ToolResult) []string {
+		entries, _ := result.JSONResult.([]LongTermEntry)
+		keys := []string{}
+		for _, entry := range entries {
+			keys = append(keys, entry.Key)
+		}
+		return keys
+	}
+
+	result, err = run(map[string]string{"operation": "list"})
+	if err != nil {
+		return err
+	}
+	want := []string{"favorite_food", "pet_name", "user_city", "user_language", "user_name"}
+	if got := keys(result); strings.Join(got, ",") != strings.Join(want, ",") {
+		return fmt.Errorf("expected list to show %v, got %v", want, got)
+	}
+	lines := strings.Split(result.TextResult, "\n")
+	if len(lines) != 6 || !strings.HasPrefix(lines[0], "KEY") || !strings.Contains(lines[0], "IMPORTANCE") ||
+		!strings.HasPrefix(lines[1], "favorite_food") || !strings.Contains(lines[1], " 2 ") {
+		return fmt.Errorf("expected a table of 5 keys with importance, got:\n%s", result.TextResult)
+	}
+	fmt.Println("✓ list showed all 5 keys as a table")
+
+	result, err = run(map[string]string{"operation": "list", "prefix": "user_", "limit": "2"})
+	if err != nil || strings.Join(keys(result), ",") != "user_city,user_language" {
+		return fmt.Errorf("expected the first 2 user_ keys, got %v (%v)", keys(result), err)
+	}
+	fmt.Println("✓ list filtered by prefix and limit")
+
+	result, err = run(map[string]string{"operation": "search", "query": "pasta"})
+	if err != nil || result.TextResult != "favorite_food: fresh pasta" {
+		return fmt.Errorf("expected search to find favorite_food, got %q (%v)", result.TextResult, err)
+	}
+	result, err = run(map[string]string{"operation": "search", "query": "sushi"})
+	if err != nil || !strings.HasPrefix(result.TextResult, "Info: No memory matches") {
+		return fmt.Errorf("expected no search matches, got %q (%v)", result.TextResult, err)
+	}
+	fmt.Println("✓ search matched on value")
+
+	if _, err := run(map[string]string{"operation": "delete", "key": "pet_name"}); err != nil {
+		return err
+	}
+	result, err = run(map[string]string{"operation": "get", "key": "pet_name"})
+	if err != nil || result.Metadata["found"] != false {
+		return fmt.Errorf("expected pet_name to be deleted, got %q (%v)", result.TextResult, err)
+	}
+	if _, err := run(map[string]string{"operation": "delete", "key": "pet_name"}); err == nil {
+		return fmt.Errorf("expected deleting a missing key to fail")
+	}
+	result, err = run(map[string]string{"operation": "list"})
+	if err != nil || len(keys(result)) != 4 {
+		return fmt.Errorf("expected 4 keys left after delete, got %v (%v)", keys(result), err)
+	}
+	fmt.Println("✓ delete removed a single key")
+
+	return nil
 }
 
 // TestTools runs tests on the tools module
@@ -335,8 +1205,9 @@ func TestTools() {
 
 	// Create registry
 	registry := NewToolRegistry()
+	ctx := context.Background()
 	fileTool := NewFileTool(tempDir)
-	shellTool := NewShellTool([]string{"echo", "pwd", "ls"})
+	shellTool := NewShellTool([]string{"echo", "pwd", "ls"}, "", 0)
 	memoryTool := NewMemoryTool(memory)
 
 	registry.Register(fileTool)
@@ -344,7 +1215,7 @@ func TestTools() {
 	registry.Register(memoryTool)
 
 	// Test file tool - write
-	result, err := registry.Execute("file", map[string]string{
+	result, err := registry.Execute(ctx, "file", map[string]string{
 		"operation": "write",
 		"path":      "test.txt",
 		"content":   "Hello QuickBot!",
@@ -352,32 +1223,32 @@ func TestTools() {
 	if err != nil {
 		fmt.Printf("Failed to write file: %v\n", err)
 	} else {
-		fmt.Printf("✓ File write: %s\n", result)
+		fmt.Printf("✓ File write: %s (%v)\n", result.TextResult, result.Duration)
 	}
 
 	// Test file tool - read
-	result, err = registry.Execute("file", map[string]string{
+	result, err = registry.Execute(ctx, "file", map[string]string{
 		"operation": "read",
 		"path":      "test.txt",
 	})
 	if err != nil {
 		fmt.Printf("Failed to read file: %v\n", err)
 	} else {
-		fmt.Printf("✓ File read: %s\n", result[:20]+"...")
+		fmt.Printf("✓ File read: %s\n", result.TextResult[:20]+"...")
 	}
 
 	// Test shell tool
-	result, err = registry.Execute("shell", map[string]string{
+	result, err = registry.Execute(ctx, "shell", map[string]string{
 		"command": "echo 'QuickBot test'",
 	})
 	if err != nil {
 		fmt.Printf("Failed shell command: %v\n", err)
 	} else {
-		fmt.Printf("✓ Shell command: %s", strings.TrimSpace(result))
+		fmt.Printf("✓ Shell command: %s", strings.TrimSpace(result.TextResult))
 	}
 
 	// Test memory tool
-	result, err = registry.Execute("memory", map[string]string{
+	result, err = registry.Execute(ctx, "memory", map[string]string{
 		"operation": "set",
 		"key":       "test_key",
 		"value":     "test_value",
@@ -385,20 +1256,161 @@ func TestTools() {
 	if err != nil {
 		fmt.Printf("Failed memory set: %v\n", err)
 	} else {
-		fmt.Printf("✓ Memory set: %s\n", result)
+		fmt.Printf("✓ Memory set: %s\n", result.TextResult)
 	}
 
-	result, err = registry.Execute("memory", map[string]string{
+	result, err = registry.Execute(ctx, "memory", map[string]string{
 		"operation": "get",
 		"key":       "test_key",
 	})
 	if err != nil {
 		fmt.Printf("Failed memory get: %v\n", err)
 	} else {
-		fmt.Printf("✓ Memory get: %s\n", result)
+		fmt.Printf("✓ Memory get: %s (found: %v)\n", result.TextResult, result.Metadata["found"])
+	}
+
+	// Test memory expiry
+	_, err = registry.Execute(ctx, "memory", map[string]string{
+		"operation":  "set",
+		"key":        "short_lived",
+		"value":      "soon gone",
+		"expires_in": "50ms",
+	})
+	time.Sleep(100 * time.Millisecond)
+	result, getErr := registry.Execute(ctx, "memory", map[string]string{
+		"operation": "get",
+		"key":       "short_lived",
+	})
+	if err != nil || getErr != nil || result.Metadata["expired"] != true {
+		fmt.Printf("Failed memory expiry: expected expired memory, got %v (%v, %v)\n", result.Metadata, err, getErr)
+	} else {
+		fmt.Printf("✓ Memory expired: %s\n", result.TextResult)
+	}
+	if _, err := registry.Execute(ctx, "memory", map[string]string{
+		"operation":  "set",
+		"key":        "bad_ttl",
+		"value":      "x",
+		"expires_in": "tomorrow",
+	}); err == nil {
+		fmt.Println("Failed memory expiry: expected invalid expires_in to be rejected")
+	} else {
+		fmt.Println("✓ Invalid expires_in rejected")
+	}
+
+	// Test argument validation
+	_, err = registry.Execute(ctx, "file", map[string]string{"path": "test.txt"})
+	if err == nil || !strings.Contains(err.Error(), "missing required argument: operation") {
+		fmt.Printf("Failed argument validation: expected missing operation error, got %v\n", err)
+	} else {
+		fmt.Printf("✓ Missing required argument rejected: %v\n", err)
+	}
+	if err := registry.Validate("file", map[string]string{"operation": "  "}); err == nil {
+		fmt.Println("Failed argument validation: expected empty required argument to be rejected")
+	} else {
+		fmt.Println("✓ Empty required argument rejected")
+	}
+
+	// Test usage counters
+	registry.Execute(ctx, "file", map[string]string{"operation": "read", "path": "missing.txt"})
+	usage := registry.Usage()
+	fmt.Printf("✓ File tool usage: %d calls, %.2f error rate\n", usage["file"].Calls, usage["file"].ErrorRate())
+
+	// Test pre hooks modifying args
+	hooked := NewToolRegistry()
+	hooked.Register(fileTool)
+	hooked.AddHook(ToolHook{
+		Pre: func(name string, args map[string]string) (map[string]string, error) {
+			if path, ok := args["path"]; ok {
+				args["path"] = "sanitized_" + path
+			}
+			return args, nil
+		},
+	})
+	_, err = hooked.Execute(ctx, "file", map[string]string{
+		"operation": "write",
+		"path":      "hook.txt",
+		"content":   "hooked",
+	})
+	if _, statErr := os.Stat(filepath.Join(tempDir, "sanitized_hook.txt")); err != nil || statErr != nil {
+		fmt.Printf("Failed pre hook: tool did not receive sanitized path (%v, %v)\n", err, statErr)
+	} else {
+		fmt.Println("✓ Pre hook sanitized file path")
+	}
+
+	// Test argument coercion
+	if err := TestCoerceArgs(); err != nil {
+		fmt.Printf("Failed argument coercion: %v\n", err)
+	}
+
+	// Test calculator
+	if err := TestCalculator(); err != nil {
+		fmt.Printf("Failed calculator: %v\n", err)
+	}
+
+	// Test HTTP tool
+	if err := TestHTTPTool(); err != nil {
+		fmt.Printf("Failed HTTP tool: %v\n", err)
+	}
+
+	// Test HTTP allow and deny lists
+	if err := TestAllowList(); err != nil {
+		fmt.Printf("Failed allow list: %v\n", err)
+	}
+
+	// Test result pagination
+	if err := TestPagination(); err != nil {
+		fmt.Printf("Failed pagination: %v\n", err)
+	}
+
+	// Test tool definitions loader
+	if err := TestToolLoader(); err != nil {
+		fmt.Printf("Failed tool loader: %v\n", err)
+	}
+
+	// Test middleware
+	if err := TestToolMiddleware(); err != nil {
+		fmt.Printf("Failed tool middleware: %v\n", err)
+	}
+
+	// Test shell working directory and timeout
+	if err := TestShellTool(); err != nil {
+		fmt.Printf("Failed shell tool: %v\n", err)
+	}
+
+	// Test file append, move and stat
+	if err := TestFileOperations(); err != nil {
+		fmt.Printf("Failed file operations: %v\n", err)
+	}
+
+	// Test memory list, search, delete and bulk_set
+	if err := TestMemoryTool(); err != nil {
+		fmt.Printf("Failed memory tool: %v\n", err)
+	}
+
+	// Test file grep and count
+	if err := TestFileSearch(); err != nil {
+		fmt.Printf("Failed file search: %v\n", err)
+	}
+
+	// Test file read_glob and diff
+	if err := TestFileReadGlob(); err != nil {
+		fmt.Printf("Failed file read_glob and diff: %v\n", err)
+	}
+
+	// Test web scrape tool
+	if err := TestWebScrapeTool(); err != nil {
+		fmt.Printf("Failed web scrape tool: %v\n", err)
+	}
+	_, err = registry.Execute(ctx, "memory", map[string]string{"operation": "list", "limit": "ten"})
+	var typeErr *ArgTypeError
+	if !errors.As(err, &typeErr) {
+		fmt.Printf("Failed invalid int argument: expected ArgTypeError, got %v\n", err)
+	} else {
+		fmt.Printf("✓ Invalid argument rejected: %v\n", err)
 	}
 
 	// Cleanup
+	os.Remove(filepath.Join(tempDir, "sanitized_hook.txt"))
 	os.Remove(filepath.Join(tempDir, "test.txt"))
 	memory.Close()
 	os.Remove("test_tools_memory.db")
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// annotationPrompt asks the agent to describe a step from its type and config
+const annotationPrompt = "In one sentence, describe what a workflow step of type '%s' with config '%s' does. Reply with the sentence only."
+
+// SetAgent sets the agent used to annotate workflows
+func (we *WorkflowEngine) SetAgent(agent *Agent) {
+	we.mu.Lock()
+	defer we.mu.Unlock()
+	we.agent = agent
+}
+
+// AnnotateWorkflow asks the agent to describe each step of a workflow that
+// has no description, and stores the answers as step descriptions
+func (we *WorkflowEngine) AnnotateWorkflow(workflowID string) error {
+	we.mu.RLock()
+	workflow, exists := we.workflows[workflowID]
+	agent := we.agent
+	we.mu.RUnlock()
+
+	if !exists {
+		return fmt.Errorf("%w: %s", ErrWorkflowNotFound, workflowID)
+	}
+	if agent == nil {
+		return fmt.Errorf("no agent configured for workflow annotation")
+	}
+
+	sessionID := "workflow_annotate_" + workflow.ID
+
+	for i := range workflow.Steps {
+		we.mu.RLock()
+		step := workflow.Steps[i]
+		we.mu.RUnlock()
+
+		if step.Description != "" {
+			continue
+		}
+
+		config, err := json.Marshal(step.Config)
+		if err != nil {
+			return fmt.Errorf("failed to marshal config of step %s: %w", step.ID, err)
+		}
+
+		prompt := fmt.Sprintf(annotationPrompt, step.Type, config)
+		description, err := agent.ProcessMessage(context.Background(), sessionID, prompt)
+		if err != nil {
+			return fmt.Errorf("failed to annotate step %s: %w", step.ID, err)
+		}
+
+		we.mu.Lock()
+		workflow.Steps[i].Description = strings.TrimSpace(description)
+		we.mu.Unlock()
+	}
+
+	we.saveState()
+	return nil
+}
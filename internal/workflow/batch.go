@@ -0,0 +1,145 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+)
+
+// BatchToolResult is the result of one tool of a batch_tool step
+type BatchToolResult struct {
+	Index  int    `json:"index"`
+	Tool   string `json:"tool"`
+	Result string `json:"result,omitempty"`
+	Error  string `json:"error,omitempty"`
+}
+
+// batchToolCall is a tool call of a batch_tool step
+type batchToolCall struct {
+	Tool string
+	Args map[string]string
+}
+
+// SetToolRegistry sets the registry used by tool and batch_tool steps. If
+// none is set, the registry of the agent is used.
+func (we *WorkflowEngine) SetToolRegistry(registry *ToolRegistry) {
+	we.mu.Lock()
+	defer we.mu.Unlock()
+	we.tools = registry
+}
+
+// toolRegistry returns the registry used to execute tool steps, or nil
+func (we *WorkflowEngine) toolRegistry() *ToolRegistry {
+	we.mu.RLock()
+	defer we.mu.RUnlock()
+
+	if we.tools != nil {
+		return we.tools
+	}
+	if we.agent != nil {
+		return we.agent.ToolRegistry()
+	}
+	return nil
+}
+
+// executeBatchToolStep executes the tools listed in step.Config["tools"]
+// and returns their results in order. If stop_on_error is true (the
+// default), the tools run one after another and the first failure fails
+// the step. Otherwise they run concurrently and failures are recorded in
+// the results.
+func (we *WorkflowEngine) executeBatchToolStep(ctx context.Context, workflow *Workflow, step *WorkflowStep) (interface{}, error) {
+	calls, err := batchToolCalls(step.Config)
+	if err != nil {
+		return nil, err
+	}
+
+	registry := we.toolRegistry()
+	if registry == nil {
+		return nil, fmt.Errorf("no tool registry configured")
+	}
+
+	stopOnError := true
+	if v, ok := step.Config["stop_on_error"].(bool); ok {
+		stopOnError = v
+	}
+
+	results := make([]BatchToolResult, len(calls))
+	run := func(i int) error {
+		results[i] = BatchToolResult{Index: i, Tool: calls[i].Tool}
+		output, err := registry.Execute(ctx, calls[i].Tool, calls[i].Args)
+		if err != nil {
+			results[i].Error = err.Error()
+			return err
+		}
+		results[i].Result = FormatToolResult(output)
+		return nil
+	}
+
+	if stopOnError {
+		for i := range calls {
+			if err := run(i); err != nil {
+				return nil, fmt.Errorf("tool %d (%s) failed: %w", i, calls[i].Tool, err)
+			}
+		}
+		return results, nil
+	}
+
+	var wg sync.WaitGroup
+	for i := range calls {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			if err := run(i); err != nil {
+				log.Printf("Batch step %s: tool %d (%s) failed: %v", step.ID, i, calls[i].Tool, err)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	return results, nil
+}
+
+// batchToolCalls parses step.Config["tools"], which may come from Go code
+// as []map[string]interface{} or from JSON as []interface{}
+func batchToolCalls(config map[string]interface{}) ([]batchToolCall, error) {
+	var entries []map[string]interface{}
+	switch tools := config["tools"].(type) {
+	case []map[string]interface{}:
+		entries = tools
+	case []interface{}:
+		for i, entry := range tools {
+			m, ok := entry.(map[string]interface{})
+			if !ok {
+				return nil, fmt.Errorf("tools[%d] is not an object", i)
+			}
+			entries = append(entries, m)
+		}
+	default:
+		return nil, fmt.Errorf("tools must be a list")
+	}
+
+	if len(entries) == 0 {
+		return nil, fmt.Errorf("tools is empty")
+	}
+
+	calls := make([]batchToolCall, len(entries))
+	for i, entry := range entries {
+		name, _ := entry["tool"].(string)
+		if name == "" {
+			return nil, fmt.Errorf("tools[%d] has no tool name", i)
+		}
+		args, _ := entry["args"].(map[string]interface{})
+		calls[i] = batchToolCall{Tool: name, Args: toolArgs(args)}
+	}
+	return calls, nil
+}
+
+// toolArgs converts workflow config arguments to tool arguments
+func toolArgs(args map[string]interface{}) map[string]string {
+	converted := make(map[string]string, len(args))
+	for key, value := range args {
+		converted[key] = fmt.Sprint(value)
+	}
+	return converted
+}
@@ -0,0 +1,329 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"reflect"
+	"strconv"
+	"strings"
+	"text/template"
+)
+
+// Step statuses set by branch steps. The steps of the branch not taken
+// are skipped; they count as done for the steps depending on them, so
+// both branches can join again.
+const stepSkipped = "skipped"
+
+// branchTargets returns the IDs of the steps a branch step runs when its
+// condition is true and when it is false
+func branchTargets(step *WorkflowStep) (trueSteps, falseSteps []string, err error) {
+	if trueSteps, err = stepIDList(step.Config, "true_steps"); err != nil {
+		return nil, nil, err
+	}
+	if falseSteps, err = stepIDList(step.Config, "false_steps"); err != nil {
+		return nil, nil, err
+	}
+	return trueSteps, falseSteps, nil
+}
+
+// stepIDList reads a list of step IDs from config, as decoded from JSON or
+// built in Go
+func stepIDList(config map[string]interface{}, key string) ([]string, error) {
+	switch v := config[key].(type) {
+	case nil:
+		return nil, nil
+	case []string:
+		return v, nil
+	case []interface{}:
+		ids := make([]string, len(v))
+		for i, item := range v {
+			id, ok := item.(string)
+			if !ok {
+				return nil, fmt.Errorf("%s must be a list of step IDs", key)
+			}
+			ids[i] = id
+		}
+		return ids, nil
+	default:
+		return nil, fmt.Errorf("%s must be a list of step IDs", key)
+	}
+}
+
+// isBranchTarget reports whether a step only runs when a branch step
+// selects it
+func isBranchTarget(workflow *Workflow, stepID string) bool {
+	for i := range workflow.Steps {
+		step := &workflow.Steps[i]
+		if step.Type != "branch" {
+			continue
+		}
+		trueSteps, falseSteps, _ := branchTargets(step)
+		for _, id := range append(trueSteps, falseSteps...) {
+			if id == stepID {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// executeBranchStep evaluates the condition of a branch step against the
+// workflow variables
+func (we *WorkflowEngine) executeBranchStep(ctx context.Context, workflow *Workflow, step *WorkflowStep) (interface{}, error) {
+	condition, _ := step.Config["condition"].(string)
+
+	we.mu.RLock()
+	vars := make(map[string]interface{}, len(workflow.Variables))
+	for k, v := range workflow.Variables {
+		vars[k] = v
+	}
+	we.mu.RUnlock()
+
+	met, err := evaluateCondition(condition, vars)
+	if err != nil {
+		return nil, err
+	}
+
+	trueSteps, falseSteps, err := branchTargets(step)
+	if err != nil {
+		return nil, err
+	}
+	next := falseSteps
+	if met {
+		next = trueSteps
+	}
+
+	return map[string]interface{}{
+		"condition_met": met,
+		"next_steps":    next,
+	}, nil
+}
+
+// queueBranch adds the steps of the branch taken by a finished branch step
+// to the remaining steps, and marks the steps of the other branch skipped.
+// A failed branch step skips both branches. Steps run before a restart are
+// left alone.
+func (we *WorkflowEngine) queueBranch(workflow *Workflow, execution *WorkflowExecution, step *WorkflowStep, failed bool,
+	executedSteps map[string]bool, remainingSteps map[string]*WorkflowStep) {
+	trueSteps, falseSteps, _ := branchTargets(step)
+
+	met := false
+	if !failed {
+		we.mu.RLock()
+		result, _ := we.stepResults[execution.ExecutionID][step.ID].(map[string]interface{})
+		we.mu.RUnlock()
+		met, _ = result["condition_met"].(bool)
+	}
+
+	taken, skipped := falseSteps, trueSteps
+	if met {
+		taken, skipped = trueSteps, falseSteps
+	}
+	if failed {
+		taken, skipped = nil, append(trueSteps, falseSteps...)
+	}
+
+	for _, id := range skipped {
+		we.skipStep(workflow, execution, id, executedSteps)
+	}
+	for _, id := range taken {
+		if executedSteps[id] {
+			continue
+		}
+		for i := range workflow.Steps {
+			if workflow.Steps[i].ID == id {
+				remainingSteps[id] = &workflow.Steps[i]
+			}
+		}
+	}
+
+	if !failed {
+		log.Printf("Branch %s: condition %v, running %s", step.Name, met, strings.Join(taken, ", "))
+	}
+}
+
+// skipStep marks a step of a branch not taken as skipped, along with both
+// branches of a skipped branch step
+func (we *WorkflowEngine) skipStep(workflow *Workflow, execution *WorkflowExecution, stepID string, executedSteps map[string]bool) {
+	if executedSteps[stepID] {
+		return
+	}
+	we.setStepStatus(execution, stepID, stepSkipped)
+	executedSteps[stepID] = true
+
+	for i := range workflow.Steps {
+		step := &workflow.Steps[i]
+		if step.ID != stepID || step.Type != "branch" {
+			continue
+		}
+		trueSteps, falseSteps, _ := branchTargets(step)
+		for _, id := range append(trueSteps, falseSteps...) {
+			we.skipStep(workflow, execution, id, executedSteps)
+		}
+	}
+}
+
+// evaluateCondition evaluates a condition such as {{.Env}} == "prod". Both
+// sides are rendered as templates over vars, then compared as JSON values
+// when they parse as JSON ("prod", 3, true) and as text otherwise. A
+// condition without == or != must render to a boolean. Unknown variables
+// are an error.
+func evaluateCondition(expr string, vars map[string]interface{}) (bool, error) {
+	operator := ""
+	for _, op := range []string{"==", "!="} {
+		if strings.Contains(expr, op) {
+			operator = op
+			break
+		}
+	}
+
+	if operator == "" {
+		value, err := renderCondition(expr, vars)
+		if err != nil {
+			return false, err
+		}
+		met, err := strconv.ParseBool(value)
+		if err != nil {
+			return false, fmt.Errorf("condition %q is not a boolean: %q", expr, value)
+		}
+		return met, nil
+	}
+
+	left, right, _ := strings.Cut(expr, operator)
+	leftValue, err := renderCondition(left, vars)
+	if err != nil {
+		return false, err
+	}
+	rightValue, err := renderCondition(right, vars)
+	if err != nil {
+		return false, err
+	}
+
+	equal := reflect.DeepEqual(conditionValue(leftValue), conditionValue(rightValue))
+	if operator == "!=" {
+		return !equal, nil
+	}
+	return equal, nil
+}
+
+// renderCondition renders one side of a condition
+func renderCondition(text string, vars map[string]interface{}) (string, error) {
+	tmpl, err := template.New("condition").Option("missingkey=error").Parse(text)
+	if err != nil {
+		return "", fmt.Errorf("invalid condition %q: %w", text, err)
+	}
+	var b strings.Builder
+	if err := tmpl.Execute(&b, vars); err != nil {
+		return "", fmt.Errorf("failed to evaluate condition %q: %w", text, err)
+	}
+	return strings.TrimSpace(b.String()), nil
+}
+
+// conditionValue parses a rendered condition side as a JSON value, or
+// returns it as text
+func conditionValue(text string) interface{} {
+	var value interface{}
+	if err := json.Unmarshal([]byte(text), &value); err != nil {
+		return text
+	}
+	return value
+}
+
+// TestBranchStep runs a workflow down both branches of a branch step and
+// with a missing variable
+func TestBranchStep() error {
+	log.Println("Testing branch steps...")
+
+	conditions := []struct {
+		expr string
+		want bool
+	}{
+		{`{{.Env}} == "prod"`, true},
+		{`{{.Env}} != "prod"`, false},
+		{`{{.Env}} == "dev"`, false},
+		{`{{.Replicas}} == 3`, true},
+		{`{{.Enabled}}`, true},
+		{`"{{.Env}}" == "prod"`, true},
+	}
+	vars := map[string]interface{}{"Env": "prod", "Replicas": 3, "Enabled": true}
+	for _, c := range conditions {
+		met, err := evaluateCondition(c.expr, vars)
+		if err != nil || met != c.want {
+			return fmt.Errorf("condition %s: expected %v, got %v (%v)", c.expr, c.want, met, err)
+		}
+	}
+	if _, err := evaluateCondition(`{{.Region}} == "eu"`, vars); err == nil {
+		return fmt.Errorf("expected a condition on a missing variable to fail")
+	}
+	log.Println("✓ Conditions evaluated")
+
+	engine := NewWorkflowEngine()
+	workflow := &Workflow{
+		ID:   "deploy",
+		Name: "Deploy",
+		Steps: []WorkflowStep{
+			{ID: "check", Name: "Check Environment", Type: "branch", Config: map[string]interface{}{
+				"condition":   `{{.Env}} == "prod"`,
+				"true_steps":  []interface{}{"approve"},
+				"false_steps": []string{"deploy_now"},
+			}},
+			{ID: "approve", Name: "Ask Approval", Type: "task", Config: map[string]interface{}{"name": "approve"}},
+			{ID: "deploy_now", Name: "Deploy Now", Type: "task", Config: map[string]interface{}{"name": "deploy"}},
+			{ID: "notify", Name: "Notify", Type: "task", Config: map[string]interface{}{"name": "notify"},
+				Dependencies: []string{"approve", "deploy_now"}},
+		},
+	}
+	if err := engine.RegisterWorkflow(workflow); err != nil {
+		return err
+	}
+
+	for env, want := range map[string]map[string]string{
+		"prod": {"check": "completed", "approve": "completed", "deploy_now": stepSkipped, "notify": "completed"},
+		"dev":  {"check": "completed", "approve": stepSkipped, "deploy_now": "completed", "notify": "completed"},
+	} {
+		execution, err := engine.ExecuteWorkflow(workflow.ID, map[string]interface{}{"Env": env})
+		if err != nil {
+			return err
+		}
+		if execution.Status != "completed" || !reflect.DeepEqual(execution.StepStatus, want) {
+			return fmt.Errorf("%s: expected %v, got %s %v", env, want, execution.Status, execution.StepStatus)
+		}
+	}
+	log.Println("✓ Only the selected branch ran")
+
+	missing := &Workflow{
+		ID:   "missing_variable",
+		Name: "Missing Variable",
+		Steps: []WorkflowStep{
+			{ID: "check", Name: "Check Region", Type: "branch", Config: map[string]interface{}{
+				"condition":  `{{.Region}} == "eu"`,
+				"true_steps": []string{"eu_only"},
+			}},
+			{ID: "eu_only", Name: "EU Only", Type: "task", Config: map[string]interface{}{"name": "eu"}},
+		},
+	}
+	if err := engine.RegisterWorkflow(missing); err != nil {
+		return err
+	}
+	execution, err := engine.ExecuteWorkflow(missing.ID, nil)
+	if err != nil {
+		return err
+	}
+	if execution.Status != "failed" || execution.Error == nil || !strings.Contains(execution.Error.Error(), "Region") {
+		return fmt.Errorf("expected the missing variable to fail the workflow, got %s (%v)", execution.Status, execution.Error)
+	}
+	if execution.StepStatus["eu_only"] != stepSkipped {
+		return fmt.Errorf("expected the branch of a failed condition to be skipped, got %v", execution.StepStatus)
+	}
+	log.Println("✓ Missing variable fails the branch")
+
+	if errs := engine.ValidateWorkflow(&Workflow{Steps: []WorkflowStep{
+		{ID: "b", Type: "branch", Config: map[string]interface{}{"condition": "{{.X}}", "true_steps": []string{"nowhere"}}},
+	}}); len(errs) != 1 {
+		return fmt.Errorf("expected an unknown branch target to be reported, got %v", errs)
+	}
+
+	return nil
+}
@@ -0,0 +1,176 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Documentation formats supported by GenerateDocs
+const (
+	DocsFormatMarkdown = "markdown"
+	DocsFormatMermaid  = "mermaid"
+)
+
+// ErrWorkflowNotFound is returned for unknown workflow IDs
+var ErrWorkflowNotFound = errors.New("workflow not found")
+
+// GenerateDocs renders a workflow as Markdown or as a Mermaid flowchart
+func (we *WorkflowEngine) GenerateDocs(workflowID string, format string) (string, error) {
+	we.mu.RLock()
+	workflow, exists := we.workflows[workflowID]
+	we.mu.RUnlock()
+
+	if !exists {
+		return "", fmt.Errorf("%w: %s", ErrWorkflowNotFound, workflowID)
+	}
+
+	switch format {
+	case DocsFormatMarkdown, "":
+		return workflowMarkdown(workflow)
+	case DocsFormatMermaid:
+		return workflowMermaid(workflow), nil
+	default:
+		return "", fmt.Errorf("unsupported docs format: %s", format)
+	}
+}
+
+// ExecutionStages groups the steps of a workflow in execution order. The
+// steps of a stage only depend on steps of earlier stages, so they can
+// run in parallel.
+func ExecutionStages(workflow *Workflow) ([][]*WorkflowStep, error) {
+	steps := make(map[string]*WorkflowStep, len(workflow.Steps))
+	for i := range workflow.Steps {
+		steps[workflow.Steps[i].ID] = &workflow.Steps[i]
+	}
+
+	done := make(map[string]bool, len(steps))
+	var stages [][]*WorkflowStep
+
+	for len(done) < len(steps) {
+		var stage []*WorkflowStep
+		for i := range workflow.Steps {
+			step := &workflow.Steps[i]
+			if done[step.ID] {
+				continue
+			}
+
+			ready := true
+			for _, dep := range step.Dependencies {
+				if _, ok := steps[dep]; !ok {
+					return nil, fmt.Errorf("step %s depends on unknown step %s", step.ID, dep)
+				}
+				if !done[dep] {
+					ready = false
+					break
+				}
+			}
+			if ready {
+				stage = append(stage, step)
+			}
+		}
+
+		if len(stage) == 0 {
+			return nil, fmt.Errorf("circular dependencies between workflow steps")
+		}
+
+		for _, step := range stage {
+			done[step.ID] = true
+		}
+		stages = append(stages, stage)
+	}
+
+	return stages, nil
+}
+
+// workflowMarkdown renders a workflow as Markdown
+func workflowMarkdown(workflow *Workflow) (string, error) {
+	stages, err := ExecutionStages(workflow)
+	if err != nil {
+		return "", err
+	}
+
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "# %s\n\n", workflow.Name)
+	if workflow.Description != "" {
+		fmt.Fprintf(&b, "%s\n\n", workflow.Description)
+	}
+	fmt.Fprintf(&b, "Workflow ID: `%s`\n\n", workflow.ID)
+
+	b.WriteString("## Execution Order\n\n")
+	for i, stage := range stages {
+		ids := make([]string, len(stage))
+		for j, step := range stage {
+			ids[j] = "`" + step.ID + "`"
+		}
+		fmt.Fprintf(&b, "%d. %s", i+1, strings.Join(ids, ", "))
+		if len(stage) > 1 {
+			b.WriteString(" (parallel)")
+		}
+		b.WriteString("\n")
+	}
+
+	b.WriteString("\n## Steps\n")
+	for _, step := range workflow.Steps {
+		fmt.Fprintf(&b, "\n### %s (`%s`)\n\n", step.Name, step.ID)
+		if step.Description != "" {
+			fmt.Fprintf(&b, "%s\n\n", step.Description)
+		}
+
+		fmt.Fprintf(&b, "- Type: %s\n", step.Type)
+		if len(step.Dependencies) > 0 {
+			fmt.Fprintf(&b, "- Depends on: %s\n", strings.Join(step.Dependencies, ", "))
+		}
+		if step.OnError != "" {
+			fmt.Fprintf(&b, "- On error: %s\n", step.OnError)
+		}
+		if len(step.Tags) > 0 {
+			fmt.Fprintf(&b, "- Tags: %s\n", strings.Join(step.Tags, ", "))
+		}
+
+		if len(step.Config) > 0 {
+			config, err := json.MarshalIndent(step.Config, "", "  ")
+			if err != nil {
+				return "", fmt.Errorf("failed to marshal config of step %s: %w", step.ID, err)
+			}
+			fmt.Fprintf(&b, "\n```json\n%s\n```\n", config)
+		}
+	}
+
+	return b.String(), nil
+}
+
+// workflowMermaid renders the dependency graph of a workflow as a Mermaid
+// flowchart, with an arrow from each dependency to the dependent step
+func workflowMermaid(workflow *Workflow) string {
+	var b strings.Builder
+
+	b.WriteString("flowchart TD\n")
+	for _, step := range workflow.Steps {
+		label := step.Name
+		if label == "" {
+			label = step.ID
+		}
+		fmt.Fprintf(&b, "    %s[\"%s\"]\n", mermaidID(step.ID), strings.ReplaceAll(label, `"`, "#quot;"))
+	}
+
+	for _, step := range workflow.Steps {
+		for _, dep := range step.Dependencies {
+			fmt.Fprintf(&b, "    %s --> %s\n", mermaidID(dep), mermaidID(step.ID))
+		}
+	}
+
+	return b.String()
+}
+
+// mermaidID replaces characters not allowed in Mermaid node IDs
+func mermaidID(id string) string {
+	return strings.Map(func(r rune) rune {
+		if r == '_' || r == '-' || ('0' <= r && r <= '9') || ('a' <= r && r <= 'z') || ('A' <= r && r <= 'Z') {
+			return r
+		}
+		return '_'
+	}, id)
+}
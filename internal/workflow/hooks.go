@@ -0,0 +1,275 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"time"
+)
+
+// webhookTimeout limits how long WebhookHook waits for the webhook
+const webhookTimeout = 10 * time.Second
+
+// workflowHooks are the functions called as executions change state. They
+// run synchronously on the goroutine running the execution and receive a
+// copy of it.
+type workflowHooks struct {
+	mu           sync.RWMutex
+	start        []func(*WorkflowExecution)
+	complete     []func(*WorkflowExecution)
+	failure      []func(*WorkflowExecution, error)
+	stepComplete []func(*WorkflowExecution, *WorkflowStep, interface{})
+}
+
+// OnStart adds a hook called when an execution starts running, including
+// when it resumes after a restart
+func (we *WorkflowEngine) OnStart(fn func(*WorkflowExecution)) {
+	we.hooks.mu.Lock()
+	defer we.hooks.mu.Unlock()
+	we.hooks.start = append(we.hooks.start, fn)
+}
+
+// OnComplete adds a hook called when an execution completes
+func (we *WorkflowEngine) OnComplete(fn func(*WorkflowExecution)) {
+	we.hooks.mu.Lock()
+	defer we.hooks.mu.Unlock()
+	we.hooks.complete = append(we.hooks.complete, fn)
+}
+
+// OnError adds a hook called when an execution fails or times out
+func (we *WorkflowEngine) OnError(fn func(*WorkflowExecution, error)) {
+	we.hooks.mu.Lock()
+	defer we.hooks.mu.Unlock()
+	we.hooks.failure = append(we.hooks.failure, fn)
+}
+
+// OnStepComplete adds a hook called after each step of an execution runs,
+// with the step's result, which is nil if the step failed
+func (we *WorkflowEngine) OnStepComplete(fn func(*WorkflowExecution, *WorkflowStep, interface{})) {
+	we.hooks.mu.Lock()
+	defer we.hooks.mu.Unlock()
+	we.hooks.stepComplete = append(we.hooks.stepComplete, fn)
+}
+
+// fireStart calls the OnStart hooks
+func (we *WorkflowEngine) fireStart(execution *WorkflowExecution) {
+	we.hooks.mu.RLock()
+	hooks := append([]func(*WorkflowExecution){}, we.hooks.start...)
+	we.hooks.mu.RUnlock()
+
+	for _, fn := range hooks {
+		fn(we.copyExecution(execution))
+	}
+}
+
+// fireFinish calls the OnComplete hooks, or the OnError hooks if err is
+// not nil
+func (we *WorkflowEngine) fireFinish(execution *WorkflowExecution, err error) {
+	we.hooks.mu.RLock()
+	complete := append([]func(*WorkflowExecution){}, we.hooks.complete...)
+	failure := append([]func(*WorkflowExecution, error){}, we.hooks.failure...)
+	we.hooks.mu.RUnlock()
+
+	if err != nil {
+		for _, fn := range failure {
+			fn(we.copyExecution(execution), err)
+		}
+		return
+	}
+	for _, fn := range complete {
+		fn(we.copyExecution(execution))
+	}
+}
+
+// fireStepComplete calls the OnStepComplete hooks
+func (we *WorkflowEngine) fireStepComplete(execution *WorkflowExecution, step *WorkflowStep) {
+	we.hooks.mu.RLock()
+	hooks := append([]func(*WorkflowExecution, *WorkflowStep, interface{}){}, we.hooks.stepComplete...)
+	we.hooks.mu.RUnlock()
+	if len(hooks) == 0 {
+		return
+	}
+
+	we.mu.RLock()
+	result := we.stepResults[execution.ExecutionID][step.ID]
+	we.mu.RUnlock()
+
+	for _, fn := range hooks {
+		fn(we.copyExecution(execution), step, result)
+	}
+}
+
+// copyExecution returns a copy of an execution, which is safe to read
+// while the execution is running
+func (we *WorkflowEngine) copyExecution(execution *WorkflowExecution) *WorkflowExecution {
+	we.mu.RLock()
+	defer we.mu.RUnlock()
+
+	status := *execution
+	status.StepStatus = make(map[string]string, len(execution.StepStatus))
+	for id, stepStatus := range execution.StepStatus {
+		status.StepStatus[id] = stepStatus
+	}
+	status.Outputs = make(map[string]interface{}, len(execution.Outputs))
+	for key, output := range execution.Outputs {
+		status.Outputs[key] = output
+	}
+	return &status
+}
+
+// MarshalJSON encodes an execution with snake_case keys and its error as
+// text
+func (e WorkflowExecution) MarshalJSON() ([]byte, error) {
+	data := struct {
+		ExecutionID string                 `json:"execution_id"`
+		WorkflowID  string                 `json:"workflow_id"`
+		Status      string                 `json:"status"`
+		StepStatus  map[string]string      `json:"step_status"`
+		Outputs     map[string]interface{} `json:"outputs,omitempty"`
+		StartTime   time.Time              `json:"start_time"`
+		EndTime     *time.Time             `json:"end_time,omitempty"`
+		Error       string                 `json:"error,omitempty"`
+	}{
+		ExecutionID: e.ExecutionID,
+		WorkflowID:  e.WorkflowID,
+		Status:      e.Status,
+		StepStatus:  e.StepStatus,
+		Outputs:     e.Outputs,
+		StartTime:   e.StartTime,
+	}
+	if !e.EndTime.IsZero() {
+		data.EndTime = &e.EndTime
+	}
+	if e.Error != nil {
+		data.Error = e.Error.Error()
+	}
+	return json.Marshal(data)
+}
+
+// WebhookHook returns a hook that posts the execution as JSON to url, for
+// OnStart and OnComplete. For OnError, call it from the error hook; the
+// execution carries the error. Failed deliveries are logged.
+func WebhookHook(url string) func(*WorkflowExecution) {
+	client := &http.Client{Timeout: webhookTimeout}
+
+	return func(execution *WorkflowExecution) {
+		body, err := json.Marshal(execution)
+		if err != nil {
+			log.Printf("Warning: failed to marshal workflow execution %s: %v", execution.ExecutionID, err)
+			return
+		}
+
+		resp, err := client.Post(url, "application/json", bytes.NewReader(body))
+		if err != nil {
+			log.Printf("Warning: failed to post workflow execution %s to webhook: %v", execution.ExecutionID, err)
+			return
+		}
+		resp.Body.Close()
+
+		if resp.StatusCode >= 300 {
+			log.Printf("Warning: workflow webhook returned %s for execution %s", resp.Status, execution.ExecutionID)
+		}
+	}
+}
+
+// TestWorkflowHooks registers channel-based hooks and checks that they
+// receive the events of a completed and a failed execution, and that
+// WebhookHook posts executions
+func TestWorkflowHooks() error {
+	log.Println("Testing workflow hooks...")
+
+	events := make(chan string, 20)
+	engine := NewWorkflowEngine()
+	engine.OnStart(func(e *WorkflowExecution) { events <- "start " + e.Status })
+	engine.OnStepComplete(func(e *WorkflowExecution, step *WorkflowStep, result interface{}) {
+		events <- fmt.Sprintf("step %s %s %v", step.ID, e.StepStatus[step.ID], result)
+	})
+	engine.OnComplete(func(e *WorkflowExecution) { events <- "complete " + e.Status })
+	engine.OnError(func(e *WorkflowExecution, err error) { events <- fmt.Sprintf("error %s %v", e.Status, err) })
+
+	workflow := &Workflow{
+		ID:   "hooks",
+		Name: "Hooks",
+		Steps: []WorkflowStep{
+			{ID: "greet", Name: "Greet", Type: "task", Config: map[string]interface{}{"name": "greet"}},
+			{ID: "check", Name: "Check", Type: "branch", Config: map[string]interface{}{"condition": "{{.Ready}}"},
+				Dependencies: []string{"greet"}},
+		},
+	}
+	if err := engine.RegisterWorkflow(workflow); err != nil {
+		return err
+	}
+
+	// ExecuteWorkflow calls the hooks before returning
+	expect := func(want ...string) error {
+		for _, w := range want {
+			select {
+			case got := <-events:
+				if got != w {
+					return fmt.Errorf("expected event %q, got %q", w, got)
+				}
+			default:
+				return fmt.Errorf("expected event %q, got none", w)
+			}
+		}
+		if len(events) != 0 {
+			return fmt.Errorf("unexpected event %q", <-events)
+		}
+		return nil
+	}
+
+	if _, err := engine.ExecuteWorkflow(workflow.ID, map[string]interface{}{"Ready": true}); err != nil {
+		return err
+	}
+	if err := expect(
+		"start running",
+		"step greet completed Task executed: greet",
+		"step check completed map[condition_met:true next_steps:[]]",
+		"complete completed",
+	); err != nil {
+		return err
+	}
+	log.Println("✓ Start, step and complete hooks called")
+
+	if _, err := engine.ExecuteWorkflow(workflow.ID, map[string]interface{}{"Ready": "soon"}); err != nil {
+		return err
+	}
+	if err := expect(
+		"start running",
+		"step greet completed Task executed: greet",
+		"step check failed <nil>",
+		`error failed condition "{{.Ready}}" is not a boolean: "soon"`,
+	); err != nil {
+		return err
+	}
+	log.Println("✓ Error hook called")
+
+	received := make(chan map[string]interface{}, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]interface{}
+		json.NewDecoder(r.Body).Decode(&body)
+		received <- body
+	}))
+	defer server.Close()
+
+	engine.OnComplete(WebhookHook(server.URL))
+	execution, err := engine.ExecuteWorkflow(workflow.ID, map[string]interface{}{"Ready": false})
+	if err != nil {
+		return err
+	}
+	select {
+	case body := <-received:
+		if body["execution_id"] != execution.ExecutionID || body["status"] != "completed" {
+			return fmt.Errorf("unexpected webhook body: %v", body)
+		}
+	default:
+		return fmt.Errorf("expected the webhook to receive the execution")
+	}
+	log.Println("✓ Webhook hook posted the execution")
+
+	return nil
+}
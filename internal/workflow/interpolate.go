@@ -0,0 +1,189 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"text/template"
+)
+
+// templateData is the data of templates in step config: the workflow
+// variables, and the results of completed steps under outputs. Templates
+// read a variable with {{.VarName}}, a step result with
+// {{.outputs.step_id}}, or a field of a map result such as a tool step's
+// with {{.outputs.step_id.result}}. {{.StepResult "step_id" 2}} reads the
+// third result of a list result such as a batch_tool step's.
+type templateData map[string]interface{}
+
+// StepResult returns the result of a completed step, or the element at
+// index of a list result
+func (d templateData) StepResult(stepID string, index ...int) (interface{}, error) {
+	results, _ := d["outputs"].(map[string]interface{})
+	result, ok := results[stepID]
+	if !ok {
+		return nil, fmt.Errorf("no result for step %s", stepID)
+	}
+	if len(index) == 0 {
+		return result, nil
+	}
+
+	i := index[0]
+	switch list := result.(type) {
+	case []BatchToolResult:
+		if i < 0 || i >= len(list) {
+			return nil, fmt.Errorf("step %s has no result %d", stepID, i)
+		}
+		if list[i].Error != "" {
+			return nil, fmt.Errorf("step %s result %d failed: %s", stepID, i, list[i].Error)
+		}
+		return list[i].Result, nil
+	case []interface{}:
+		if i < 0 || i >= len(list) {
+			return nil, fmt.Errorf("step %s has no result %d", stepID, i)
+		}
+		return list[i], nil
+	default:
+		return nil, fmt.Errorf("result of step %s is not a list", stepID)
+	}
+}
+
+// templateVars returns the template variables of a step of an execution:
+// the workflow variables, and the execution's step results under outputs,
+// which hides a workflow variable of that name
+func (we *WorkflowEngine) templateVars(workflow *Workflow, executionID string) map[string]interface{} {
+	we.mu.RLock()
+	defer we.mu.RUnlock()
+
+	vars := make(map[string]interface{}, len(workflow.Variables)+1)
+	for k, v := range workflow.Variables {
+		vars[k] = v
+	}
+	outputs := make(map[string]interface{}, len(we.stepResults[executionID]))
+	for id, result := range we.stepResults[executionID] {
+		outputs[id] = result
+	}
+	vars["outputs"] = outputs
+	return vars
+}
+
+// interpolateConfig returns a copy of config with templates in string
+// values, including nested ones, rendered against vars. config itself is
+// not modified. A template referring to a missing variable or step result
+// is an error.
+func interpolateConfig(config map[string]interface{}, vars map[string]interface{}) (map[string]interface{}, error) {
+	interpolated, err := interpolateValue(config, templateData(vars))
+	if err != nil {
+		return nil, err
+	}
+	m, _ := interpolated.(map[string]interface{})
+	return m, nil
+}
+
+// interpolateValue renders templates in a config value
+func interpolateValue(value interface{}, data templateData) (interface{}, error) {
+	switch v := value.(type) {
+	case string:
+		if !strings.Contains(v, "{{") {
+			return v, nil
+		}
+		tmpl, err := template.New("config").Option("missingkey=error").Parse(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid template %q: %w", v, err)
+		}
+		var b strings.Builder
+		if err := tmpl.Execute(&b, data); err != nil {
+			return nil, fmt.Errorf("failed to render template %q: %w", v, err)
+		}
+		return b.String(), nil
+
+	case map[string]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			rendered, err := interpolateValue(item, data)
+			if err != nil {
+				return nil, err
+			}
+			m[key] = rendered
+		}
+		return m, nil
+
+	case []interface{}:
+		list := make([]interface{}, len(v))
+		for i, item := range v {
+			rendered, err := interpolateValue(item, data)
+			if err != nil {
+				return nil, err
+			}
+			list[i] = rendered
+		}
+		return list, nil
+
+	case []map[string]interface{}:
+		list := make([]map[string]interface{}, len(v))
+		for i, item := range v {
+			rendered, err := interpolateValue(item, data)
+			if err != nil {
+				return nil, err
+			}
+			list[i] = rendered.(map[string]interface{})
+		}
+		return list, nil
+
+	default:
+		return v, nil
+	}
+}
+
+// TestWorkflowInterpolation runs a workflow whose second step's config
+// refers to the first step's output and to a workflow variable
+func TestWorkflowInterpolation() error {
+	log.Println("Testing workflow interpolation...")
+
+	engine := NewWorkflowEngine()
+	workflow := &Workflow{
+		ID:   "interpolation",
+		Name: "Interpolation",
+		Steps: []WorkflowStep{
+			{ID: "step_1", Name: "Look Up", Type: "tool", Config: map[string]interface{}{"tool": "lookup"}},
+			{ID: "step_2", Name: "Report", Type: "tool", Dependencies: []string{"step_1"}, Config: map[string]interface{}{
+				"tool": "report",
+				"args": map[string]interface{}{
+					"message": "{{.outputs.step_1.result}} for {{.User}}",
+					"tags":    []interface{}{"{{.outputs.step_1.tool}}"},
+				},
+			}},
+		},
+	}
+	if err := engine.RegisterWorkflow(workflow); err != nil {
+		return err
+	}
+
+	execution, err := engine.ExecuteWorkflow(workflow.ID, map[string]interface{}{"User": "alice"})
+	if err != nil {
+		return err
+	}
+	if execution.Status != "completed" {
+		return fmt.Errorf("expected workflow to complete, got %s (%v)", execution.Status, execution.Error)
+	}
+
+	report, _ := engine.stepResults[execution.ExecutionID]["step_2"].(map[string]interface{})
+	args, _ := report["args"].(map[string]interface{})
+	if args["message"] != "Tool lookup executed for alice" {
+		return fmt.Errorf("expected message to be expanded, got %v", args["message"])
+	}
+	if tags, _ := args["tags"].([]interface{}); len(tags) != 1 || tags[0] != "lookup" {
+		return fmt.Errorf("expected tags to be expanded, got %v", args["tags"])
+	}
+	if message := workflow.Steps[1].Config["args"].(map[string]interface{})["message"]; message != "{{.outputs.step_1.result}} for {{.User}}" {
+		return fmt.Errorf("expected step config to be left unchanged, got %v", message)
+	}
+	log.Println("✓ Step config expanded from earlier output")
+
+	if _, err := interpolateConfig(map[string]interface{}{"message": "{{.outputs.step_9.result}}"},
+		map[string]interface{}{"outputs": map[string]interface{}{}}); err == nil {
+		return fmt.Errorf("expected a reference to a missing step to fail")
+	}
+	log.Println("✓ Missing step output reported")
+
+	return nil
+}
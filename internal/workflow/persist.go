@@ -0,0 +1,519 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/Chang-Augenweide/QuickBot-Go/pkg/types"
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// Open stores the workflows and executions of the engine in a SQLite
+// database, creating it if needed, and restores the state saved there.
+// Executions interrupted by a restart resume from their first step that
+// had not completed.
+func (we *WorkflowEngine) Open(dbPath string) error {
+	conn, err := sql.Open("sqlite3", dbPath)
+	if err != nil {
+		return fmt.Errorf("failed to open workflow database: %w", err)
+	}
+
+	_, err = conn.Exec(`
+		CREATE TABLE IF NOT EXISTS workflows (
+			id TEXT PRIMARY KEY,
+			name TEXT NOT NULL,
+			description TEXT,
+			steps TEXT NOT NULL,
+			variables TEXT,
+			timeout INTEGER NOT NULL DEFAULT 0
+		);
+		CREATE TABLE IF NOT EXISTS executions (
+			execution_id TEXT PRIMARY KEY,
+			workflow_id TEXT NOT NULL,
+			status TEXT NOT NULL,
+			step_status TEXT,
+			outputs TEXT,
+			error TEXT,
+			start_time DATETIME NOT NULL,
+			end_time DATETIME
+		);
+	`)
+	if err != nil {
+		conn.Close()
+		return fmt.Errorf("failed to create workflow tables: %w", err)
+	}
+	if err := migrateWorkflowTables(conn); err != nil {
+		conn.Close()
+		return err
+	}
+
+	we.mu.Lock()
+	we.db = conn
+	we.mu.Unlock()
+
+	return we.Load()
+}
+
+// migrateWorkflowTables adds the timeout column to workflow databases
+// created before workflows had a timeout
+func migrateWorkflowTables(conn *sql.DB) error {
+	var exists bool
+	err := conn.QueryRow(`
+		SELECT EXISTS(SELECT 1 FROM pragma_table_info('workflows') WHERE name = 'timeout')
+	`).Scan(&exists)
+	if err != nil {
+		return fmt.Errorf("failed to check workflows columns: %w", err)
+	}
+	if exists {
+		return nil
+	}
+
+	_, err = conn.Exec(`ALTER TABLE workflows ADD COLUMN timeout INTEGER NOT NULL DEFAULT 0`)
+	if err != nil {
+		return fmt.Errorf("failed to add workflows.timeout column: %w", err)
+	}
+	return nil
+}
+
+// Close closes the workflow database. Later changes are no longer saved.
+func (we *WorkflowEngine) Close() error {
+	we.mu.Lock()
+	conn := we.db
+	we.db = nil
+	we.mu.Unlock()
+
+	if conn == nil {
+		return nil
+	}
+	return conn.Close()
+}
+
+// Save writes all workflows and executions to the workflow database. It
+// does nothing if Open was not called.
+func (we *WorkflowEngine) Save() error {
+	we.saveMu.Lock()
+	defer we.saveMu.Unlock()
+
+	type workflowRow struct {
+		id, name, description, steps, variables string
+		timeout                                 time.Duration
+	}
+	type executionRow struct {
+		id, workflowID, status, stepStatus, outputs, errText string
+		start, end                                           time.Time
+	}
+
+	// Snapshot the state, as running executions keep changing it
+	we.mu.RLock()
+	conn := we.db
+	var workflows []workflowRow
+	var executions []executionRow
+	var err error
+	for key, workflow := range we.workflows {
+		// Workflows are also registered under their name
+		if key != workflow.ID {
+			continue
+		}
+		row := workflowRow{id: workflow.ID, name: workflow.Name, description: workflow.Description, timeout: workflow.Timeout}
+		if row.steps, err = marshalJSON(workflow.Steps); err != nil {
+			break
+		}
+		if row.variables, err = marshalJSON(workflow.Variables); err != nil {
+			break
+		}
+		workflows = append(workflows, row)
+	}
+	for id, execution := range we.executions {
+		if err != nil {
+			break
+		}
+		row := executionRow{
+			id:         id,
+			workflowID: execution.WorkflowID,
+			status:     execution.Status,
+			start:      execution.StartTime,
+			end:        execution.EndTime,
+		}
+		if execution.Error != nil {
+			row.errText = execution.Error.Error()
+		}
+		if row.stepStatus, err = marshalJSON(execution.StepStatus); err != nil {
+			break
+		}
+		if row.outputs, err = marshalJSON(we.stepResults[id]); err != nil {
+			break
+		}
+		executions = append(executions, row)
+	}
+	we.mu.RUnlock()
+
+	if err != nil {
+		return fmt.Errorf("failed to marshal workflow state: %w", err)
+	}
+	if conn == nil {
+		return nil
+	}
+
+	tx, err := conn.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	for _, row := range workflows {
+		_, err := tx.Exec(`
+			INSERT OR REPLACE INTO workflows (id, name, description, steps, variables, timeout)
+			VALUES (?, ?, ?, ?, ?, ?)
+		`, row.id, row.name, row.description, row.steps, row.variables, int64(row.timeout))
+		if err != nil {
+			return fmt.Errorf("failed to save workflow %s: %w", row.id, err)
+		}
+	}
+	for _, row := range executions {
+		var end interface{}
+		if !row.end.IsZero() {
+			end = row.end.UTC()
+		}
+		_, err := tx.Exec(`
+			INSERT OR REPLACE INTO executions (execution_id, workflow_id, status, step_status, outputs, error, start_time, end_time)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+		`, row.id, row.workflowID, row.status, row.stepStatus, row.outputs, row.errText, row.start.UTC(), end)
+		if err != nil {
+			return fmt.Errorf("failed to save execution %s: %w", row.id, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit workflow state: %w", err)
+	}
+	return nil
+}
+
+// saveState saves the engine state, logging failures
+func (we *WorkflowEngine) saveState() {
+	if err := we.Save(); err != nil {
+		log.Printf("Warning: failed to save workflow state: %v", err)
+	}
+}
+
+// marshalJSON marshals v to a JSON string
+func marshalJSON(v interface{}) (string, error) {
+	data, err := json.Marshal(v)
+	return string(data), err
+}
+
+// Load restores the workflows and executions from the workflow database
+// and resumes the executions that were queued or running. It does
+// nothing if Open was not called.
+func (we *WorkflowEngine) Load() error {
+	we.mu.RLock()
+	conn := we.db
+	we.mu.RUnlock()
+	if conn == nil {
+		return nil
+	}
+
+	workflows, err := loadWorkflows(conn)
+	if err != nil {
+		return err
+	}
+	executions, outputs, err := loadExecutions(conn)
+	if err != nil {
+		return err
+	}
+
+	we.mu.Lock()
+	for _, workflow := range workflows {
+		we.workflows[workflow.ID] = workflow
+		we.workflows[workflow.Name] = workflow
+	}
+	var interrupted []*WorkflowExecution
+	for _, execution := range executions {
+		we.executions[execution.ExecutionID] = execution
+		we.stepResults[execution.ExecutionID] = outputs[execution.ExecutionID]
+		if execution.Status == "queued" || execution.Status == "running" {
+			interrupted = append(interrupted, execution)
+		}
+	}
+	we.mu.Unlock()
+
+	log.Printf("✓ Workflow state loaded (%d workflows, %d executions)", len(workflows), len(executions))
+
+	for _, execution := range interrupted {
+		we.mu.RLock()
+		workflow, exists := we.workflows[execution.WorkflowID]
+		we.mu.RUnlock()
+
+		if !exists {
+			we.finishExecution(execution, fmt.Errorf("workflow not found: %s", execution.WorkflowID))
+			continue
+		}
+		go we.resumeExecution(workflow, execution)
+	}
+	return nil
+}
+
+// loadWorkflows reads the saved workflows
+func loadWorkflows(conn *sql.DB) ([]*Workflow, error) {
+	rows, err := conn.Query(`SELECT id, name, description, steps, variables, timeout FROM workflows`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query workflows: %w", err)
+	}
+	defer rows.Close()
+
+	var workflows []*Workflow
+	for rows.Next() {
+		var workflow Workflow
+		var description, variables sql.NullString
+		var steps string
+		var timeout int64
+		if err := rows.Scan(&workflow.ID, &workflow.Name, &description, &steps, &variables, &timeout); err != nil {
+			return nil, fmt.Errorf("failed to scan workflow: %w", err)
+		}
+		workflow.Description = description.String
+		workflow.Timeout = time.Duration(timeout)
+
+		if err := json.Unmarshal([]byte(steps), &workflow.Steps); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal steps of workflow %s: %w", workflow.ID, err)
+		}
+		if variables.Valid {
+			if err := json.Unmarshal([]byte(variables.String), &workflow.Variables); err != nil {
+				return nil, fmt.Errorf("failed to unmarshal variables of workflow %s: %w", workflow.ID, err)
+			}
+		}
+		workflows = append(workflows, &workflow)
+	}
+	return workflows, rows.Err()
+}
+
+// loadExecutions reads the saved executions and their step results
+func loadExecutions(conn *sql.DB) ([]*WorkflowExecution, map[string]map[string]interface{}, error) {
+	rows, err := conn.Query(`
+		SELECT execution_id, workflow_id, status, step_status, outputs, error, start_time, end_time
+		FROM executions
+	`)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to query executions: %w", err)
+	}
+	defer rows.Close()
+
+	var executions []*WorkflowExecution
+	outputs := make(map[string]map[string]interface{})
+	for rows.Next() {
+		execution := &WorkflowExecution{
+			StepStatus: make(map[string]string),
+			Outputs:    make(map[string]interface{}),
+		}
+		var stepStatus, results, errText sql.NullString
+		var end sql.NullTime
+		err := rows.Scan(&execution.ExecutionID, &execution.WorkflowID, &execution.Status,
+			&stepStatus, &results, &errText, &execution.StartTime, &end)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to scan execution: %w", err)
+		}
+		execution.EndTime = end.Time
+		if errText.String != "" {
+			execution.Error = errors.New(errText.String)
+		}
+
+		stepResults := make(map[string]interface{})
+		if stepStatus.Valid {
+			if err := json.Unmarshal([]byte(stepStatus.String), &execution.StepStatus); err != nil {
+				return nil, nil, fmt.Errorf("failed to unmarshal step status of execution %s: %w", execution.ExecutionID, err)
+			}
+		}
+		if results.Valid {
+			if err := json.Unmarshal([]byte(results.String), &stepResults); err != nil {
+				return nil, nil, fmt.Errorf("failed to unmarshal outputs of execution %s: %w", execution.ExecutionID, err)
+			}
+		}
+		if execution.StepStatus == nil {
+			execution.StepStatus = make(map[string]string)
+		}
+		if stepResults == nil {
+			stepResults = make(map[string]interface{})
+		}
+
+		executions = append(executions, execution)
+		outputs[execution.ExecutionID] = stepResults
+	}
+	return executions, outputs, rows.Err()
+}
+
+// resumeExecution runs the steps of an execution that had not completed
+// when the engine stopped
+func (we *WorkflowEngine) resumeExecution(workflow *Workflow, execution *WorkflowExecution) {
+	log.Printf("Resuming workflow execution %s", execution.ExecutionID)
+
+	we.mu.Lock()
+	execution.Status = "running"
+	we.mu.Unlock()
+	we.fireStart(execution)
+
+	ctx, cancel := we.executionContext(workflow, execution)
+	defer cancel()
+	we.finishExecution(execution, we.executeSteps(ctx, workflow, execution))
+}
+
+// blockingTool counts its runs and, while block is open, waits for it to
+// be closed or for the run to be cancelled, for the persistence and
+// timeout tests
+type blockingTool struct {
+	name    string
+	mu      sync.Mutex
+	runs    int
+	started chan struct{}
+	block   chan struct{}
+}
+
+func (t *blockingTool) Name() string                   { return t.name }
+func (t *blockingTool) Description() string            { return "Count runs, waiting while blocked" }
+func (t *blockingTool) Permission() ToolPermission     { return PermissionAllowAll }
+func (t *blockingTool) Params() []ToolParam            { return nil }
+func (t *blockingTool) Schema() map[string]interface{} { return ParamsSchema(t.Params()) }
+
+func (t *blockingTool) Execute(ctx context.Context, args map[string]interface{}) (types.ToolResult, error) {
+	t.mu.Lock()
+	t.runs++
+	started, block := t.started, t.block
+	t.mu.Unlock()
+
+	if started != nil {
+		close(started)
+		select {
+		case <-block:
+		case <-ctx.Done():
+			return types.ToolResult{}, ctx.Err()
+		}
+	}
+	return types.ToolResult{Success: true, TextResult: t.name + " done"}, nil
+}
+
+// runCount returns how many times the tool ran
+func (t *blockingTool) runCount() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.runs
+}
+
+// TestWorkflowPersistence stops an engine in the middle of a workflow and
+// checks that a new engine on the same database finishes it without
+// running the completed steps again
+func TestWorkflowPersistence() error {
+	log.Println("Testing workflow persistence...")
+
+	dbPath := "test_workflows.db"
+	os.Remove(dbPath)
+	defer os.Remove(dbPath)
+
+	first := &blockingTool{name: "first"}
+	second := &blockingTool{name: "second", started: make(chan struct{}), block: make(chan struct{})}
+	registry := NewToolRegistry()
+	registry.Register(first)
+	registry.Register(second)
+	defer close(second.block)
+
+	engine := NewWorkflowEngine()
+	engine.SetToolRegistry(registry)
+	if err := engine.Open(dbPath); err != nil {
+		return err
+	}
+
+	workflow := &Workflow{
+		ID:          "persisted",
+		Name:        "Persisted Workflow",
+		Description: "Survives restarts",
+		Steps: []WorkflowStep{
+			{ID: "one", Name: "One", Type: "tool", Config: map[string]interface{}{"tool": "first"}},
+			{ID: "two", Name: "Two", Type: "tool", Config: map[string]interface{}{"tool": "second"}, Dependencies: []string{"one"}},
+			{ID: "three", Name: "Three", Type: "task", Config: map[string]interface{}{"name": "Wrap up"}, Dependencies: []string{"two"}},
+		},
+	}
+	if err := engine.RegisterWorkflow(workflow); err != nil {
+		return err
+	}
+
+	executionIDs := make(chan string, 1)
+	go func() {
+		execution := engine.newExecution(workflow, "running")
+		executionIDs <- execution.ExecutionID
+		engine.runExecution(workflow, execution, map[string]interface{}{"owner": "tests"})
+	}()
+	executionID := <-executionIDs
+
+	// Kill the engine while the second step runs
+	select {
+	case <-second.started:
+	case <-time.After(5 * time.Second):
+		return fmt.Errorf("timed out waiting for the second step")
+	}
+	if err := engine.Close(); err != nil {
+		return err
+	}
+	log.Println("✓ Engine stopped in the middle of a workflow")
+
+	// Restart; the second step no longer blocks
+	second.mu.Lock()
+	second.started = nil
+	second.mu.Unlock()
+
+	restarted := NewWorkflowEngine()
+	restarted.SetToolRegistry(registry)
+	if err := restarted.Open(dbPath); err != nil {
+		return err
+	}
+	defer restarted.Close()
+
+	restarted.mu.RLock()
+	_, restored := restarted.workflows[workflow.Name]
+	restarted.mu.RUnlock()
+	if !restored {
+		return fmt.Errorf("expected the workflow to be restored")
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	var status string
+	var stepStatus map[string]string
+	for time.Now().Before(deadline) {
+		execution, err := restarted.GetExecutionStatus(executionID)
+		if err != nil {
+			return fmt.Errorf("expected the execution to be restored: %w", err)
+		}
+		restarted.mu.RLock()
+		status = execution.Status
+		stepStatus = make(map[string]string, len(execution.StepStatus))
+		for k, v := range execution.StepStatus {
+			stepStatus[k] = v
+		}
+		restarted.mu.RUnlock()
+		if status != "running" {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if status != "completed" || len(stepStatus) != 3 {
+		return fmt.Errorf("expected the resumed execution to complete all 3 steps, got %s %v", status, stepStatus)
+	}
+	if first.runCount() != 1 || second.runCount() != 2 {
+		return fmt.Errorf("expected only the interrupted step to run again, got first %d, second %d runs",
+			first.runCount(), second.runCount())
+	}
+	log.Println("✓ Interrupted execution resumed after restart")
+
+	restarted.mu.RLock()
+	owner := restarted.workflows[workflow.ID].Variables["owner"]
+	result := restarted.stepResults[executionID]["one"]
+	restarted.mu.RUnlock()
+	if owner != "tests" || result == nil {
+		return fmt.Errorf("expected variables and step results to be restored, got %v and %v", owner, result)
+	}
+
+	return nil
+}
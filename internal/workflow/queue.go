@@ -0,0 +1,285 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/Chang-Augenweide/QuickBot-Go/pkg/types"
+)
+
+// queuedExecution is an execution waiting for a worker
+type queuedExecution struct {
+	workflow  *Workflow
+	execution *WorkflowExecution
+	variables map[string]interface{}
+}
+
+// executionQueue runs enqueued executions on a fixed pool of workers
+type executionQueue struct {
+	jobs     chan queuedExecution
+	workers  int
+	active   int
+	draining bool
+	pending  sync.WaitGroup
+	mu       sync.Mutex
+}
+
+// QueueStats reports the state of the execution queue
+type QueueStats struct {
+	QueueDepth  int `json:"queue_depth"`
+	ActiveCount int `json:"active_count"`
+	Workers     int `json:"workers"`
+}
+
+// StartQueue starts the worker pool that runs enqueued executions, with
+// at most config.MaxConcurrentExecutions running at once
+func (we *WorkflowEngine) StartQueue(config WorkflowConfig) error {
+	workers := config.MaxConcurrentExecutions
+	if workers <= 0 {
+		workers = 5
+	}
+	size := config.QueueSize
+	if size <= 0 {
+		size = 100
+	}
+
+	we.queue.mu.Lock()
+	defer we.queue.mu.Unlock()
+
+	if we.queue.jobs != nil {
+		return fmt.Errorf("workflow queue already started")
+	}
+
+	we.queue.jobs = make(chan queuedExecution, size)
+	we.queue.workers = workers
+	we.queue.draining = false
+
+	for i := 0; i < workers; i++ {
+		go we.queueWorker(we.queue.jobs)
+	}
+
+	log.Printf("✓ Workflow queue started (%d workers)", workers)
+	return nil
+}
+
+// Enqueue queues an execution of a workflow and returns its execution ID
+// without waiting for it to run. Poll GetExecutionStatus for the result.
+func (we *WorkflowEngine) Enqueue(workflowID string, variables map[string]interface{}) (string, error) {
+	we.mu.RLock()
+	workflow, exists := we.workflows[workflowID]
+	we.mu.RUnlock()
+
+	if !exists {
+		return "", fmt.Errorf("workflow not found: %s", workflowID)
+	}
+
+	we.queue.mu.Lock()
+	defer we.queue.mu.Unlock()
+
+	if we.queue.jobs == nil {
+		return "", fmt.Errorf("workflow queue not started")
+	}
+	if we.queue.draining {
+		return "", fmt.Errorf("workflow queue is draining")
+	}
+
+	execution := we.newExecution(workflow, "queued")
+	we.queue.pending.Add(1)
+
+	select {
+	case we.queue.jobs <- queuedExecution{workflow: workflow, execution: execution, variables: variables}:
+	default:
+		we.queue.pending.Done()
+		we.mu.Lock()
+		delete(we.executions, execution.ExecutionID)
+		delete(we.stepResults, execution.ExecutionID)
+		we.mu.Unlock()
+		return "", fmt.Errorf("workflow queue is full")
+	}
+
+	we.saveState()
+	return execution.ExecutionID, nil
+}
+
+// queueWorker runs queued executions until the queue is closed
+func (we *WorkflowEngine) queueWorker(jobs <-chan queuedExecution) {
+	for job := range jobs {
+		we.queue.mu.Lock()
+		we.queue.active++
+		we.queue.mu.Unlock()
+
+		we.runExecution(job.workflow, job.execution, job.variables)
+
+		we.queue.mu.Lock()
+		we.queue.active--
+		we.queue.mu.Unlock()
+		we.queue.pending.Done()
+	}
+}
+
+// QueueStats returns the state of the execution queue
+func (we *WorkflowEngine) QueueStats() QueueStats {
+	we.queue.mu.Lock()
+	defer we.queue.mu.Unlock()
+
+	return QueueStats{
+		QueueDepth:  len(we.queue.jobs),
+		ActiveCount: we.queue.active,
+		Workers:     we.queue.workers,
+	}
+}
+
+// Drain stops accepting new executions, waits for queued and running
+// executions to complete and stops the workers
+func (we *WorkflowEngine) Drain() error {
+	we.queue.mu.Lock()
+	if we.queue.jobs == nil {
+		we.queue.mu.Unlock()
+		return fmt.Errorf("workflow queue not started")
+	}
+	if we.queue.draining {
+		we.queue.mu.Unlock()
+		return fmt.Errorf("workflow queue is already draining")
+	}
+	we.queue.draining = true
+	jobs := we.queue.jobs
+	we.queue.mu.Unlock()
+
+	we.queue.pending.Wait()
+
+	we.queue.mu.Lock()
+	close(jobs)
+	we.queue.jobs = nil
+	we.queue.workers = 0
+	we.queue.mu.Unlock()
+
+	log.Println("✓ Workflow queue drained")
+	return nil
+}
+
+// concurrencyTool records the most runs it had at once
+type concurrencyTool struct {
+	mu      sync.Mutex
+	running int
+	max     int
+}
+
+func (t *concurrencyTool) Name() string                   { return "concurrency" }
+func (t *concurrencyTool) Description() string            { return "Record concurrent runs" }
+func (t *concurrencyTool) Permission() ToolPermission     { return PermissionAllowAll }
+func (t *concurrencyTool) Params() []ToolParam            { return nil }
+func (t *concurrencyTool) Schema() map[string]interface{} { return ParamsSchema(t.Params()) }
+
+func (t *concurrencyTool) Execute(ctx context.Context, args map[string]interface{}) (types.ToolResult, error) {
+	t.mu.Lock()
+	t.running++
+	if t.running > t.max {
+		t.max = t.running
+	}
+	t.mu.Unlock()
+
+	time.Sleep(5 * time.Millisecond)
+
+	t.mu.Lock()
+	t.running--
+	t.mu.Unlock()
+	return types.ToolResult{Success: true, TextResult: "done"}, nil
+}
+
+// TestWorkflowQueue enqueues workflows on a single worker and checks that
+// they run one at a time, in order
+func TestWorkflowQueue() error {
+	log.Println("Testing workflow queue...")
+
+	tool := &concurrencyTool{}
+	registry := NewToolRegistry()
+	registry.Register(tool)
+
+	engine := NewWorkflowEngine()
+	engine.SetToolRegistry(registry)
+	workflow := &Workflow{
+		ID:    "queued",
+		Name:  "Queued",
+		Steps: []WorkflowStep{{ID: "run", Name: "Run", Type: "tool", Config: map[string]interface{}{"tool": "concurrency"}}},
+	}
+	if err := engine.RegisterWorkflow(workflow); err != nil {
+		return err
+	}
+
+	if _, err := engine.Enqueue(workflow.ID, nil); err == nil {
+		return fmt.Errorf("expected enqueueing before the queue is started to fail")
+	}
+	if err := engine.StartQueue(WorkflowConfig{MaxConcurrentExecutions: 1}); err != nil {
+		return err
+	}
+
+	// Sample the active count while the executions run
+	stopSampling := make(chan struct{})
+	maxActive := make(chan int, 1)
+	go func() {
+		most := 0
+		for {
+			if active := engine.QueueStats().ActiveCount; active > most {
+				most = active
+			}
+			select {
+			case <-stopSampling:
+				maxActive <- most
+				return
+			case <-time.After(time.Millisecond):
+			}
+		}
+	}()
+
+	var ids []string
+	for i := 0; i < 20; i++ {
+		id, err := engine.Enqueue(workflow.ID, map[string]interface{}{"index": i})
+		if err != nil {
+			return err
+		}
+		ids = append(ids, id)
+	}
+	if stats := engine.QueueStats(); stats.Workers != 1 || stats.QueueDepth+stats.ActiveCount == 0 {
+		return fmt.Errorf("expected queued executions on 1 worker, got %+v", stats)
+	}
+
+	if err := engine.Drain(); err != nil {
+		return err
+	}
+	close(stopSampling)
+	if most := <-maxActive; most > 1 {
+		return fmt.Errorf("expected at most 1 active execution, got %d", most)
+	}
+	if tool.max != 1 {
+		return fmt.Errorf("expected executions to run one at a time, got %d at once", tool.max)
+	}
+
+	var previous *WorkflowExecution
+	for i, id := range ids {
+		execution, err := engine.GetExecutionStatus(id)
+		if err != nil {
+			return err
+		}
+		if execution.Status != "completed" {
+			return fmt.Errorf("expected execution %d to complete, got %s (%v)", i, execution.Status, execution.Error)
+		}
+		if previous != nil && execution.StartTime.Before(previous.EndTime) {
+			return fmt.Errorf("expected execution %d to start after execution %d ended", i, i-1)
+		}
+		previous = execution
+	}
+	log.Println("✓ 20 executions ran one at a time, in order")
+
+	if stats := engine.QueueStats(); stats.QueueDepth != 0 || stats.ActiveCount != 0 || stats.Workers != 0 {
+		return fmt.Errorf("expected an empty, stopped queue after draining, got %+v", stats)
+	}
+	if _, err := engine.Enqueue(workflow.ID, nil); err == nil {
+		return fmt.Errorf("expected enqueueing after draining to fail")
+	}
+	log.Println("✓ Queue drained")
+
+	return nil
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// stepTypes are the step types executeStep supports
+var stepTypes = map[string]bool{
+	"task":       true,
+	"condition":  true,
+	"loop":       true,
+	"parallel":   true,
+	"chat":       true,
+	"tool":       true,
+	"batch_tool": true,
+	"branch":     true,
+}
+
+// ValidationError describes a problem with a workflow definition
+type ValidationError struct {
+	StepID  string
+	Message string
+}
+
+func (e ValidationError) Error() string {
+	if e.StepID == "" {
+		return e.Message
+	}
+	return fmt.Sprintf("step %s: %s", e.StepID, e.Message)
+}
+
+// ValidateWorkflow checks a workflow for missing and duplicate step IDs,
+// dependencies on unknown steps, unknown step types, condition and branch
+// steps without a condition, and branch steps selecting unknown steps
+func (we *WorkflowEngine) ValidateWorkflow(workflow *Workflow) []ValidationError {
+	var errs []ValidationError
+
+	ids := make(map[string]bool, len(workflow.Steps))
+	for i, step := range workflow.Steps {
+		if step.ID == "" {
+			errs = append(errs, ValidationError{Message: fmt.Sprintf("step %d (%s) has no ID", i+1, step.Name)})
+			continue
+		}
+		if ids[step.ID] {
+			errs = append(errs, ValidationError{StepID: step.ID, Message: "duplicate step ID"})
+		}
+		ids[step.ID] = true
+	}
+
+	for _, step := range workflow.Steps {
+		for _, dep := range step.Dependencies {
+			if !ids[dep] {
+				errs = append(errs, ValidationError{StepID: step.ID, Message: fmt.Sprintf("depends on unknown step %s", dep)})
+			}
+		}
+
+		if !stepTypes[step.Type] {
+			errs = append(errs, ValidationError{StepID: step.ID, Message: fmt.Sprintf("unknown step type %q", step.Type)})
+			continue
+		}
+
+		if step.Type == "batch_tool" {
+			if _, err := batchToolCalls(step.Config); err != nil {
+				errs = append(errs, ValidationError{StepID: step.ID, Message: err.Error()})
+			}
+		}
+
+		if step.Type == "condition" || step.Type == "branch" {
+			condition, _ := step.Config["condition"].(string)
+			if strings.TrimSpace(condition) == "" {
+				errs = append(errs, ValidationError{StepID: step.ID, Message: "condition is empty"})
+			}
+		}
+
+		if step.Type == "branch" {
+			trueSteps, falseSteps, err := branchTargets(&step)
+			if err != nil {
+				errs = append(errs, ValidationError{StepID: step.ID, Message: err.Error()})
+			}
+			for _, id := range append(trueSteps, falseSteps...) {
+				if id == step.ID {
+					errs = append(errs, ValidationError{StepID: step.ID, Message: "branches to itself"})
+				} else if !ids[id] {
+					errs = append(errs, ValidationError{StepID: step.ID, Message: fmt.Sprintf("branches to unknown step %s", id)})
+				}
+			}
+		}
+	}
+
+	return errs
+}
+
+// validationErrors joins validation errors into a single error
+func validationErrors(errs []ValidationError) error {
+	messages := make([]string, len(errs))
+	for i, err := range errs {
+		messages[i] = err.Error()
+	}
+	return fmt.Errorf("invalid workflow: %s", strings.Join(messages, "; "))
+}
@@ -1,10 +1,15 @@
 package main
 
 import (
+	"context"
+	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -16,8 +21,16 @@ type Workflow struct {
 	Steps       []WorkflowStep
 	Variables   map[string]interface{}
 	Status      string
+	Timeout     time.Duration // limit on the duration of an execution, none if zero
 }
 
+// ErrExecutionNotFound is returned for unknown execution IDs
+var ErrExecutionNotFound = errors.New("execution not found")
+
+// ErrWorkflowTimeout is returned for executions that run past the timeout
+// of their workflow
+var ErrWorkflowTimeout = errors.New("workflow timed out")
+
 // WorkflowStep represents a step in a workflow
 type WorkflowStep struct {
 	ID          string
@@ -26,6 +39,8 @@ type WorkflowStep struct {
 	Config      map[string]interface{}
 	OnError     string   // continue, stop, retry
 	Dependencies []string // IDs of steps that must complete first
+	Description  string   // human-readable documentation of the step
+	Tags         []string
 }
 
 // WorkflowExecution represents a workflow execution
@@ -47,6 +62,15 @@ type WorkflowEngine struct {
 	currentStep   map[string]*WorkflowStep
 	stepResults   map[string]map[string]interface{}
 	mu            sync.RWMutex
+
+	queue executionQueue
+	agent *Agent
+	tools *ToolRegistry
+	hooks workflowHooks
+
+	// db stores the workflows and executions once Open is called
+	db     *sql.DB
+	saveMu sync.Mutex
 }
 
 // NewWorkflowEngine creates a new workflow engine
@@ -65,12 +89,16 @@ func (we *WorkflowEngine) RegisterWorkflow(workflow *Workflow) error {
 		workflow.ID = generateWorkflowID()
 	}
 
-	we.mu.Lock()
-	defer we.mu.Unlock()
+	if errs := we.ValidateWorkflow(workflow); len(errs) > 0 {
+		return validationErrors(errs)
+	}
 
+	we.mu.Lock()
 	we.workflows[workflow.ID] = workflow
 	we.workflows[workflow.Name] = workflow
+	we.mu.Unlock()
 
+	we.saveState()
 	log.Printf("Workflow registered: %s", workflow.Name)
 	return nil
 }
@@ -85,16 +113,56 @@ func (we *WorkflowEngine) ExecuteWorkflow(workflowID string, variables map[strin
 		return nil, fmt.Errorf("workflow not found: %s", workflowID)
 	}
 
-	// Create execution
+	execution := we.newExecution(workflow, "running")
+	we.runExecution(workflow, execution, variables)
+
+	return execution, nil
+}
+
+// ExecuteWorkflowAsync starts an execution of a workflow in the background
+// and returns its execution ID without waiting for it. Poll
+// GetExecutionStatus for the result.
+func (we *WorkflowEngine) ExecuteWorkflowAsync(workflowID string, variables map[string]interface{}) (string, error) {
+	we.mu.RLock()
+	workflow, exists := we.workflows[workflowID]
+	we.mu.RUnlock()
+
+	if !exists {
+		return "", fmt.Errorf("%w: %s", ErrWorkflowNotFound, workflowID)
+	}
+
+	execution := we.newExecution(workflow, "running")
+	we.saveState()
+	go we.runExecution(workflow, execution, variables)
+
+	return execution.ExecutionID, nil
+}
+
+// newExecution creates and stores an execution of a workflow
+func (we *WorkflowEngine) newExecution(workflow *Workflow, status string) *WorkflowExecution {
 	execution := &WorkflowExecution{
 		WorkflowID:  workflow.ID,
 		ExecutionID: generateExecutionID(),
 		StartTime:   time.Now(),
-		Status:      "running",
+		Status:      status,
 		StepStatus:  make(map[string]string),
 		Outputs:     make(map[string]interface{}),
 	}
 
+	we.mu.Lock()
+	we.executions[execution.ExecutionID] = execution
+	we.stepResults[execution.ExecutionID] = make(map[string]interface{})
+	we.mu.Unlock()
+
+	return execution
+}
+
+// runExecution runs the steps of an execution
+func (we *WorkflowEngine) runExecution(workflow *Workflow, execution *WorkflowExecution, variables map[string]interface{}) {
+	we.mu.Lock()
+	execution.Status = "running"
+	execution.StartTime = time.Now()
+
 	// Initialize workflow variables
 	if workflow.Variables == nil {
 		workflow.Variables = make(map[string]interface{})
@@ -102,15 +170,37 @@ func (we *WorkflowEngine) ExecuteWorkflow(workflowID string, variables map[strin
 	for k, v := range variables {
 		workflow.Variables[k] = v
 	}
-
-	we.mu.Lock()
-	we.executions[execution.ExecutionID] = execution
-	we.stepResults[execution.ExecutionID] = make(map[string]interface{})
 	we.mu.Unlock()
+	we.saveState()
+	we.fireStart(execution)
 
 	// Execute steps
-	err := we.executeSteps(workflow, execution)
-	if err != nil {
+	ctx, cancel := we.executionContext(workflow, execution)
+	defer cancel()
+	we.finishExecution(execution, we.executeSteps(ctx, workflow, execution))
+}
+
+// executionContext returns the context of the steps of an execution,
+// which expires once the workflow timeout has passed since the execution
+// started
+func (we *WorkflowEngine) executionContext(workflow *Workflow, execution *WorkflowExecution) (context.Context, context.CancelFunc) {
+	we.mu.RLock()
+	timeout, start := workflow.Timeout, execution.StartTime
+	we.mu.RUnlock()
+
+	if timeout <= 0 {
+		return context.WithCancel(context.Background())
+	}
+	return context.WithDeadline(context.Background(), start.Add(timeout))
+}
+
+// finishExecution records the outcome of an execution
+func (we *WorkflowEngine) finishExecution(execution *WorkflowExecution, err error) {
+	we.mu.Lock()
+	if errors.Is(err, ErrWorkflowTimeout) {
+		execution.Status = "timed_out"
+		execution.Error = err
+	} else if err != nil {
 		execution.Status = "failed"
 		execution.Error = err
 	} else {
@@ -118,22 +208,43 @@ func (we *WorkflowEngine) ExecuteWorkflow(workflowID string, variables map[strin
 	}
 
 	execution.EndTime = time.Now()
+	we.mu.Unlock()
+	we.saveState()
 
 	log.Printf("Workflow execution %s completed: %s",
 		execution.ExecutionID, execution.Status)
-
-	return execution, nil
+	we.fireFinish(execution, err)
 }
 
-// executeSteps executes workflow steps
-func (we *WorkflowEngine) executeSteps(workflow *Workflow, execution *WorkflowExecution) error {
+// executeSteps executes workflow steps until they are done or ctx expires
+func (we *WorkflowEngine) executeSteps(ctx context.Context, workflow *Workflow, execution *WorkflowExecution) error {
 	// Execute steps in topological order
 	executedSteps := make(map[string]bool)
 	remainingSteps := make(map[string]*WorkflowStep)
 
-	// Initialize remaining steps
+	// Initialize remaining steps, skipping those run before a restart and
+	// those waiting for a branch step to select them
+	var branches []*WorkflowStep
+	we.mu.RLock()
 	for i := range workflow.Steps {
-		remainingSteps[workflow.Steps[i].ID] = &workflow.Steps[i]
+		step := &workflow.Steps[i]
+		if status := execution.StepStatus[step.ID]; status != "" {
+			executedSteps[step.ID] = true
+			if step.Type == "branch" && status == "completed" {
+				branches = append(branches, step)
+			}
+			continue
+		}
+		if isBranchTarget(workflow, step.ID) {
+			continue
+		}
+		remainingSteps[step.ID] = step
+	}
+	we.mu.RUnlock()
+
+	// Resume the branches selected before a restart
+	for _, step := range branches {
+		we.queueBranch(workflow, execution, step, false, executedSteps, remainingSteps)
 	}
 
 	// Execute until all steps are done
@@ -154,12 +265,25 @@ func (we *WorkflowEngine) executeSteps(workflow *Workflow, execution *WorkflowEx
 			if !canExecute {
 				continue
 			}
+			if ctx.Err() != nil {
+				return fmt.Errorf("%w before step %s", ErrWorkflowTimeout, step.ID)
+			}
 
 			// Execute step
-			err := we.executeStep(workflow, execution, step)
+			err := we.executeStep(ctx, workflow, execution, step)
+			if errors.Is(err, ErrWorkflowTimeout) {
+				we.setStepStatus(execution, id, "timed_out")
+				log.Printf("Step %s timed out", step.Name)
+				we.fireStepComplete(execution, step)
+				return err
+			}
 			if err != nil {
-				execution.StepStatus[id] = "failed"
+				we.setStepStatus(execution, id, "failed")
 				log.Printf("Step %s failed: %v", step.Name, err)
+				if step.Type == "branch" {
+					we.queueBranch(workflow, execution, step, true, executedSteps, remainingSteps)
+				}
+				we.fireStepComplete(execution, step)
 
 				// Handle error based on OnError configuration
 				switch step.OnError {
@@ -172,7 +296,11 @@ func (we *WorkflowEngine) executeSteps(workflow *Workflow, execution *WorkflowEx
 					return err
 				}
 			} else {
-				execution.StepStatus[id] = "completed"
+				we.setStepStatus(execution, id, "completed")
+				if step.Type == "branch" {
+					we.queueBranch(workflow, execution, step, false, executedSteps, remainingSteps)
+				}
+				we.fireStepComplete(execution, step)
 			}
 
 			executedSteps[id] = true
@@ -188,41 +316,73 @@ func (we *WorkflowEngine) executeSteps(workflow *Workflow, execution *WorkflowEx
 	return nil
 }
 
-// executeStep executes a single workflow step
-func (we *WorkflowEngine) executeStep(workflow *Workflow, execution *WorkflowExecution, step *WorkflowStep) error {
+// setStepStatus records the status of a step and saves the engine state
+func (we *WorkflowEngine) setStepStatus(execution *WorkflowExecution, stepID, status string) {
+	we.mu.Lock()
+	execution.StepStatus[stepID] = status
+	we.mu.Unlock()
+	we.saveState()
+}
+
+// executeStep executes a single workflow step. A step failing once ctx
+// has expired returns ErrWorkflowTimeout.
+func (we *WorkflowEngine) executeStep(ctx context.Context, workflow *Workflow, execution *WorkflowExecution, step *WorkflowStep) error {
 	we.mu.Lock()
 	we.currentStep[execution.ExecutionID] = step
 	we.mu.Unlock()
 
 	log.Printf("Executing step: %s (type: %s)", step.Name, step.Type)
 
-	var result interface{}
+	// Render templates referring to workflow variables and earlier step
+	// results. The condition of a branch step is evaluated as a whole instead.
 	var err error
+	if step.Type != "branch" {
+		var config map[string]interface{}
+		config, err = interpolateConfig(step.Config, we.templateVars(workflow, execution.ExecutionID))
+		if err != nil {
+			return err
+		}
+		resolved := *step
+		resolved.Config = config
+		step = &resolved
+	}
+
+	var result interface{}
 
 	// Execute based on step type
 	switch step.Type {
 	case "task":
-		result, err = we.executeTaskStep(workflow, step)
+		result, err = we.executeTaskStep(ctx, workflow, step)
 
 	case "condition":
-		result, err = we.executeConditionStep(workflow, step)
+		result, err = we.executeConditionStep(ctx, workflow, step)
 
 	case loop:
-		result, err = we.executeLoopStep(workflow, step)
+		result, err = we.executeLoopStep(ctx, workflow, step)
 
 	case "parallel":
-		result, err = we.executeParallelStep(workflow, step)
+		result, err = we.executeParallelStep(ctx, workflow, step)
 
 	case "chat":
-		result, err = we.executeChatStep(workflow, step)
+		result, err = we.executeChatStep(ctx, workflow, step)
 
 	case "tool":
-		result, err = we.executeToolStep(workflow, step)
+		result, err = we.executeToolStep(ctx, workflow, step)
+
+	case "batch_tool":
+		result, err = we.executeBatchToolStep(ctx, workflow, step)
+
+	case "branch":
+		result, err = we.executeBranchStep(ctx, workflow, step)
 
 	default:
 		return fmt.Errorf("unknown step type: %s", step.Type)
 	}
 
+	if err != nil && ctx.Err() == context.DeadlineExceeded {
+		return fmt.Errorf("%w during step %s: %v", ErrWorkflowTimeout, step.ID, err)
+	}
+
 	we.mu.Lock()
 	if err == nil {
 		we.stepResults[execution.ExecutionID][step.ID] = result
@@ -233,7 +393,7 @@ func (we *WorkflowEngine) executeStep(workflow *Workflow, execution *WorkflowExe
 }
 
 // executeTaskStep executes a task step
-func (we *WorkflowEngine) executeTaskStep(workflow *Workflow, step *WorkflowStep) (interface{}, error) {
+func (we *WorkflowEngine) executeTaskStep(ctx context.Context, workflow *Workflow, step *WorkflowStep) (interface{}, error) {
 	// Execute a simple task
 	taskName, _ := step.Config["name"].(string)
 	message := fmt.Sprintf("Task executed: %s", taskName)
@@ -242,7 +402,7 @@ func (we *WorkflowEngine) executeTaskStep(workflow *Workflow, step *WorkflowStep
 }
 
 // executeConditionStep executes a condition step
-func (we *WorkflowEngine) executeConditionStep(workflow *Workflow, step *WorkflowStep) (interface{}, error) {
+func (we *WorkflowEngine) executeConditionStep(ctx context.Context, workflow *Workflow, step *WorkflowStep) (interface{}, error) {
 	// Evaluate condition
 	condition, _ := step.Config["condition"].(string)
 
@@ -262,12 +422,16 @@ func (we *WorkflowEngine) executeConditionStep(workflow *Workflow, step *Workflo
 }
 
 // executeLoopStep executes a loop step
-func (we *WorkflowEngine) executeLoopStep(workflow *Workflow, step *WorkflowStep) (interface{}, error) {
+func (we *WorkflowEngine) executeLoopStep(ctx context.Context, workflow *Workflow, step *WorkflowStep) (interface{}, error) {
 	// Execute loop
 	iterations, _ := step.Config["iterations"].(float64)
 	results := make([]interface{}, 0)
 
 	for i := 0; i < int(iterations); i++ {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
 		// Execute loop body
 		result := fmt.Sprintf("Loop iteration %d", i+1)
 		results = append(results, result)
@@ -277,8 +441,12 @@ func (we *WorkflowEngine) executeLoopStep(workflow *Workflow, step *WorkflowStep
 }
 
 // executeParallelStep executes parallel tasks
-func (we *WorkflowEngine) executeParallelStep(workflow *Workflow, step *WorkflowStep) (interface{}, error) {
-	// Execute tasks in parallel
+func (we *WorkflowEngine) executeParallelStep(ctx context.Context, workflow *Workflow, step *WorkflowStep) (interface{}, error) {
+	// Execute tasks in parallel, stopping those still running when the
+	// step returns
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
 	var wg sync.WaitGroup
 	results := make(chan interface{}, 10)
 
@@ -287,13 +455,22 @@ func (we *WorkflowEngine) executeParallelStep(workflow *Workflow, step *Workflow
 		wg.Add(1)
 		go func(idx int) {
 			defer wg.Done()
+			if ctx.Err() != nil {
+				return
+			}
 			result := fmt.Sprintf("Parallel task %d completed", idx+1)
-			results <- result
+			select {
+			case results <- result:
+			case <-ctx.Done():
+			}
 		}(i)
 	}
 
 	wg.Wait()
 	close(results)
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
 
 	var resultList []interface{}
 	for result := range results {
@@ -304,7 +481,7 @@ func (we *WorkflowEngine) executeParallelStep(workflow *Workflow, step *Workflow
 }
 
 // executeChatStep executes a chat step
-func (we *WorkflowEngine) executeChatStep(workflow *Workflow, step *WorkflowStep) (interface{}, error) {
+func (we *WorkflowEngine) executeChatStep(ctx context.Context, workflow *Workflow, step *WorkflowStep) (interface{}, error) {
 	// Execute chat message
 	message, _ := step.Config["message"].(string)
 	response := fmt.Sprintf("Chat response: %s", message)
@@ -312,29 +489,39 @@ func (we *WorkflowEngine) executeChatStep(workflow *Workflow, step *WorkflowStep
 }
 
 // executeToolStep executes a tool step
-func (we *WorkflowEngine) executeToolStep(workflow *Workflow, step *WorkflowStep) (interface{}, error) {
+func (we *WorkflowEngine) executeToolStep(ctx context.Context, workflow *Workflow, step *WorkflowStep) (interface{}, error) {
 	// Execute a tool
 	toolName, _ := step.Config["tool"].(string)
-	toolArgs, _ := step.Config["args"].(map[string]interface{})
+	args, _ := step.Config["args"].(map[string]interface{})
+
+	result := fmt.Sprintf("Tool %s executed", toolName)
+	if registry := we.toolRegistry(); registry != nil {
+		output, err := registry.Execute(ctx, toolName, toolArgs(args))
+		if err != nil {
+			return nil, err
+		}
+		result = FormatToolResult(output)
+	}
 
 	return map[string]interface{}{
 		"tool":  toolName,
-		"args":  toolArgs,
-		"result": fmt.Sprintf("Tool %s executed", toolName),
+		"args":  args,
+		"result": result,
 	}, nil
 }
 
-// GetExecutionStatus retrieves execution status
+// GetExecutionStatus retrieves execution status. It returns a copy, which
+// is safe to read while the execution is running.
 func (we *WorkflowEngine) GetExecutionStatus(executionID string) (*WorkflowExecution, error) {
 	we.mu.RLock()
-	defer we.mu.RUnlock()
-
 	execution, exists := we.executions[executionID]
+	we.mu.RUnlock()
+
 	if !exists {
-		return nil, fmt.Errorf("execution not found: %s", executionID)
+		return nil, fmt.Errorf("%w: %s", ErrExecutionNotFound, executionID)
 	}
 
-	return execution, nil
+	return we.copyExecution(execution), nil
 }
 
 // ListWorkflows returns list of workflows
@@ -354,9 +541,13 @@ func generateWorkflowID() string {
 	return fmt.Sprintf("wf_%d", time.Now().UnixNano())
 }
 
+// executionSeq keeps execution IDs unique when several are generated
+// within the same nanosecond
+var executionSeq uint64
+
 // generateExecutionID generates an execution ID
 func generateExecutionID() string {
-	return fmt.Sprintf("ex_%d", time.Now().UnixNano())
+	return fmt.Sprintf("ex_%d_%d", time.Now().UnixNano(), atomic.AddUint64(&executionSeq, 1))
 }
 
 // TestWorkflowEngine tests the workflow engine
@@ -413,6 +604,100 @@ func TestWorkflowEngine() {
 	log.Printf("  Duration: %v", execution.EndTime.Sub(execution.StartTime))
 	log.Printf("  Steps completed: %d", len(execution.StepStatus))
 
+	// Generate docs; each dependency must become an arrow
+	mermaid, err := engine.GenerateDocs(workflow.ID, DocsFormatMermaid)
+	if err != nil {
+		log.Fatalf("Failed to generate workflow docs: %v", err)
+	}
+	for _, arrow := range []string{"step_1 --> step_2", "step_2 --> step_3"} {
+		if !strings.Contains(mermaid, arrow) {
+			log.Fatalf("Mermaid docs missing %q:\n%s", arrow, mermaid)
+		}
+	}
+	if strings.Count(mermaid, "-->") != 2 {
+		log.Fatalf("Mermaid docs have unexpected arrows:\n%s", mermaid)
+	}
+	log.Println("✓ Workflow docs generated")
+
+	// Validate workflows; each rule must be reported
+	invalid := []struct {
+		rule  string
+		steps []WorkflowStep
+	}{
+		{"missing step ID", []WorkflowStep{{Name: "No ID", Type: "task"}}},
+		{"duplicate step ID", []WorkflowStep{{ID: "a", Type: "task"}, {ID: "a", Type: "task"}}},
+		{"unknown dependency", []WorkflowStep{{ID: "a", Type: "task", Dependencies: []string{"missing"}}}},
+		{"unknown step type", []WorkflowStep{{ID: "a", Type: "teleport"}}},
+		{"empty condition", []WorkflowStep{{ID: "a", Type: "condition", Config: map[string]interface{}{"condition": " "}}}},
+		{"empty batch", []WorkflowStep{{ID: "a", Type: "batch_tool", Config: map[string]interface{}{"tools": []interface{}{}}}}},
+	}
+	for _, tc := range invalid {
+		errs := engine.ValidateWorkflow(&Workflow{ID: "invalid", Steps: tc.steps})
+		if len(errs) != 1 {
+			log.Fatalf("Validation of %s: expected 1 error, got %v", tc.rule, errs)
+		}
+		if err := engine.RegisterWorkflow(&Workflow{ID: "invalid", Steps: tc.steps}); err == nil {
+			log.Fatalf("Workflow with %s was registered", tc.rule)
+		}
+	}
+	if errs := engine.ValidateWorkflow(workflow); len(errs) != 0 {
+		log.Fatalf("Valid workflow reported invalid: %v", errs)
+	}
+	log.Println("✓ Workflow validation checked")
+
+	// Run a batch whose second tool fails; without stop_on_error the
+	// other results must be kept and usable by later steps
+	tools := NewToolRegistry()
+	tools.Register(NewCalculatorTool())
+	engine.SetToolRegistry(tools)
+
+	batch := &Workflow{
+		ID:   "batch_workflow",
+		Name: "Batch Workflow",
+		Steps: []WorkflowStep{
+			{
+				ID:   "batch",
+				Name: "Batch",
+				Type: "batch_tool",
+				Config: map[string]interface{}{
+					"stop_on_error": false,
+					"tools": []map[string]interface{}{
+						{"tool": "calculator", "args": map[string]interface{}{"expression": "1+1"}},
+						{"tool": "calculator"},
+						{"tool": "calculator", "args": map[string]interface{}{"expression": "2*3"}},
+					},
+				},
+			},
+			{
+				ID:           "use_batch",
+				Name:         "Use Batch Result",
+				Type:         "tool",
+				Config:       map[string]interface{}{"tool": "calculator", "args": map[string]interface{}{"expression": `{{.StepResult "batch" 2}}`}},
+				Dependencies: []string{"batch"},
+			},
+		},
+	}
+	if err := engine.RegisterWorkflow(batch); err != nil {
+		log.Fatalf("Failed to register batch workflow: %v", err)
+	}
+	execution, err = engine.ExecuteWorkflow(batch.ID, nil)
+	if err != nil || execution.Status != "completed" {
+		log.Fatalf("Batch workflow failed: %v %v", err, execution.Error)
+	}
+
+	engine.mu.RLock()
+	results, _ := engine.stepResults[execution.ExecutionID]["batch"].([]BatchToolResult)
+	used, _ := engine.stepResults[execution.ExecutionID]["use_batch"].(map[string]interface{})
+	engine.mu.RUnlock()
+
+	if len(results) != 3 || results[0].Result == "" || results[1].Error == "" || results[2].Result == "" {
+		log.Fatalf("Unexpected batch results: %+v", results)
+	}
+	if result, _ := used["result"].(string); result != "6" {
+		log.Fatalf("Batch result not interpolated: %v", used)
+	}
+	log.Println("✓ Batch tool step executed")
+
 	// List workflows
 	workflows := engine.ListWorkflows()
 	log.Printf("✓ Available workflows: %d", len(workflows))
@@ -420,6 +705,123 @@ func TestWorkflowEngine() {
 	log.Println("✓ Workflow engine tests passed")
 }
 
+// TestWorkflowTimeout runs a workflow past its timeout and checks that it
+// stops within 200ms of the deadline
+func TestWorkflowTimeout() error {
+	log.Println("Testing workflow timeouts...")
+
+	wait := &blockingTool{name: "wait", started: make(chan struct{}), block: make(chan struct{})}
+	after := &blockingTool{name: "after"}
+	registry := NewToolRegistry()
+	registry.Register(wait)
+	registry.Register(after)
+	defer close(wait.block)
+
+	engine := NewWorkflowEngine()
+	engine.SetToolRegistry(registry)
+	workflow := &Workflow{
+		ID:      "slow",
+		Name:    "Slow",
+		Timeout: 100 * time.Millisecond,
+		Steps: []WorkflowStep{
+			{ID: "wait", Name: "Wait", Type: "tool", Config: map[string]interface{}{"tool": "wait"}},
+			{ID: "after", Name: "After", Type: "tool", Config: map[string]interface{}{"tool": "after"},
+				Dependencies: []string{"wait"}},
+		},
+	}
+	if err := engine.RegisterWorkflow(workflow); err != nil {
+		return err
+	}
+
+	start := time.Now()
+	execution, err := engine.ExecuteWorkflow(workflow.ID, nil)
+	if err != nil {
+		return err
+	}
+	elapsed := time.Since(start)
+
+	if execution.Status != "timed_out" || !errors.Is(execution.Error, ErrWorkflowTimeout) {
+		return fmt.Errorf("expected execution to time out, got %s (%v)", execution.Status, execution.Error)
+	}
+	if late := elapsed - workflow.Timeout; late < 0 || late > 200*time.Millisecond {
+		return fmt.Errorf("expected timeout within 200ms of the deadline, took %v", elapsed)
+	}
+	if execution.StepStatus["wait"] != "timed_out" || after.runCount() != 0 {
+		return fmt.Errorf("expected the steps after the timeout not to run, got %v", execution.StepStatus)
+	}
+	log.Printf("✓ Workflow timed out after %v", elapsed.Round(time.Millisecond))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := engine.executeParallelStep(ctx, workflow, &WorkflowStep{ID: "parallel", Type: "parallel"}); !errors.Is(err, context.Canceled) {
+		return fmt.Errorf("expected cancelled parallel step to stop, got %v", err)
+	}
+	log.Println("✓ Parallel step cancelled")
+
+	return nil
+}
+
+// TestAsyncExecution starts a workflow in the background and polls its
+// status until it completes
+func TestAsyncExecution() error {
+	log.Println("Testing asynchronous workflow execution...")
+
+	slow := &blockingTool{name: "slow", started: make(chan struct{}), block: make(chan struct{})}
+	registry := NewToolRegistry()
+	registry.Register(slow)
+
+	engine := NewWorkflowEngine()
+	engine.SetToolRegistry(registry)
+	workflow := &Workflow{
+		ID:   "async",
+		Name: "Async",
+		Steps: []WorkflowStep{
+			{ID: "slow", Name: "Slow", Type: "tool", Config: map[string]interface{}{"tool": "slow"}},
+			{ID: "done", Name: "Done", Type: "task", Config: map[string]interface{}{"name": "done"},
+				Dependencies: []string{"slow"}},
+		},
+	}
+	if err := engine.RegisterWorkflow(workflow); err != nil {
+		return err
+	}
+
+	executionID, err := engine.ExecuteWorkflowAsync(workflow.ID, nil)
+	if err != nil {
+		return err
+	}
+	<-slow.started
+	execution, err := engine.GetExecutionStatus(executionID)
+	if err != nil {
+		return err
+	}
+	if execution.Status != "running" || len(execution.StepStatus) != 0 {
+		return fmt.Errorf("expected the execution to be running, got %s %v", execution.Status, execution.StepStatus)
+	}
+	log.Println("✓ Execution started in the background")
+
+	close(slow.block)
+	deadline := time.Now().Add(5 * time.Second)
+	for execution.Status == "running" && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+		if execution, err = engine.GetExecutionStatus(executionID); err != nil {
+			return err
+		}
+	}
+	if execution.Status != "completed" || execution.StepStatus["done"] != "completed" {
+		return fmt.Errorf("expected the execution to complete, got %s %v", execution.Status, execution.StepStatus)
+	}
+	log.Println("✓ Execution completed while polled")
+
+	if _, err := engine.ExecuteWorkflowAsync("missing", nil); !errors.Is(err, ErrWorkflowNotFound) {
+		return fmt.Errorf("expected an unknown workflow to be reported, got %v", err)
+	}
+	if _, err := engine.GetExecutionStatus("missing"); !errors.Is(err, ErrExecutionNotFound) {
+		return fmt.Errorf("expected an unknown execution to be reported, got %v", err)
+	}
+
+	return nil
+}
+
 // main - test entry point
 func main() {
 	log.Println("QuickBot Go Workflow Engine")
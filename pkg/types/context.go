@@ -0,0 +1,86 @@
+package types
+
+import "context"
+
+type contextKey string
+
+const (
+	requestIDKey       contextKey = "request_id"
+	aiOverridesKey     contextKey = "ai_overrides"
+	sourceMessageIDKey contextKey = "source_message_id"
+	sessionIDKey       contextKey = "session_id"
+	platformMessageKey contextKey = "platform_message_id"
+	platformKey        contextKey = "platform"
+)
+
+// WithRequestID returns a copy of ctx carrying a request correlation ID
+func WithRequestID(ctx context.Context, requestID string) context.Context {
+	return context.WithValue(ctx, requestIDKey, requestID)
+}
+
+// RequestIDFromContext returns the request correlation ID of ctx, if any
+func RequestIDFromContext(ctx context.Context) string {
+	requestID, _ := ctx.Value(requestIDKey).(string)
+	return requestID
+}
+
+// WithAIOverrides returns a copy of ctx carrying per-session AI parameters,
+// applied by the AI providers to requests made with it
+func WithAIOverrides(ctx context.Context, overrides AIOverrides) context.Context {
+	return context.WithValue(ctx, aiOverridesKey, overrides)
+}
+
+// AIOverridesFromContext returns the AI parameter overrides of ctx, if any
+func AIOverridesFromContext(ctx context.Context) (AIOverrides, bool) {
+	overrides, ok := ctx.Value(aiOverridesKey).(AIOverrides)
+	return overrides, ok
+}
+
+// WithSourceMessageID returns a copy of ctx carrying the ID of the stored
+// message that caused the work done with it, e.g. a tool call
+func WithSourceMessageID(ctx context.Context, messageID int64) context.Context {
+	return context.WithValue(ctx, sourceMessageIDKey, messageID)
+}
+
+// SourceMessageIDFromContext returns the source message ID of ctx, if any
+func SourceMessageIDFromContext(ctx context.Context) (int64, bool) {
+	messageID, ok := ctx.Value(sourceMessageIDKey).(int64)
+	return messageID, ok
+}
+
+// WithSessionID returns a copy of ctx carrying the ID of the session the
+// work done with it belongs to
+func WithSessionID(ctx context.Context, sessionID string) context.Context {
+	return context.WithValue(ctx, sessionIDKey, sessionID)
+}
+
+// SessionIDFromContext returns the session ID of ctx, if any
+func SessionIDFromContext(ctx context.Context) string {
+	sessionID, _ := ctx.Value(sessionIDKey).(string)
+	return sessionID
+}
+
+// WithPlatformMessageID returns a copy of ctx carrying the platform's ID
+// of the message being answered, used to drop redeliveries of it
+func WithPlatformMessageID(ctx context.Context, messageID string) context.Context {
+	return context.WithValue(ctx, platformMessageKey, messageID)
+}
+
+// PlatformMessageIDFromContext returns the platform message ID of ctx, if
+// any
+func PlatformMessageIDFromContext(ctx context.Context) string {
+	messageID, _ := ctx.Value(platformMessageKey).(string)
+	return messageID
+}
+
+// WithPlatform returns a copy of ctx carrying the name of the platform the
+// message being answered came from
+func WithPlatform(ctx context.Context, platform string) context.Context {
+	return context.WithValue(ctx, platformKey, platform)
+}
+
+// PlatformFromContext returns the platform name of ctx, if any
+func PlatformFromContext(ctx context.Context) string {
+	platform, _ := ctx.Value(platformKey).(string)
+	return platform
+}
@@ -1,9 +1,42 @@
 package types
 
-// Message represents a chat message
+import (
+	"context"
+	"time"
+)
+
+// Message represents a chat message. ToolCalls are the tool calls of an
+// assistant message, and ToolCallID links a "tool" message to the call it
+// answers.
 type Message struct {
-	Role    string `json:"role"`
-	Content string `json:"content"`
+	Role       string     `json:"role"`
+	Content    string     `json:"content"`
+	ToolCalls  []ToolCall `json:"tool_calls,omitempty"`
+	ToolCallID string     `json:"tool_call_id,omitempty"`
+}
+
+// ToolSpec describes a tool offered to the model. Parameters is the JSON
+// Schema of its arguments.
+type ToolSpec struct {
+	Name        string                 `json:"name"`
+	Description string                 `json:"description"`
+	Parameters  map[string]interface{} `json:"parameters"`
+}
+
+// ToolCall is a tool call requested by the model, with its arguments as
+// a JSON object
+type ToolCall struct {
+	ID        string `json:"id"`
+	Name      string `json:"name"`
+	Arguments string `json:"arguments"`
+}
+
+// Completion is the reply to a chat completion request offering tools:
+// text content, tool calls, or both
+type Completion struct {
+	Content      string     `json:"content"`
+	ToolCalls    []ToolCall `json:"tool_calls,omitempty"`
+	FinishReason string     `json:"finish_reason,omitempty"`
 }
 
 // Session represents a conversation session
@@ -20,12 +53,29 @@ type Tool interface {
 	Name() string
 	Description() string
 	Permission() string
-	Execute(args map[string]string) (string, error)
+	Execute(ctx context.Context, args map[string]interface{}) (ToolResult, error)
 }
 
-// ToolResult represents the result of a tool execution
+// ToolResult represents the result of a tool execution. TextResult is the
+// human-readable output; JSONResult, when set, is the same output as
+// structured data.
 type ToolResult struct {
-	Success bool   `json:"success"`
-	Result  string `json:"result,omitempty"`
-	Error   string `json:"error,omitempty"`
+	Success    bool                   `json:"success"`
+	TextResult string                 `json:"text_result,omitempty"`
+	JSONResult interface{}            `json:"json_result,omitempty"`
+	Metadata   map[string]interface{} `json:"metadata,omitempty"`
+	Duration   time.Duration          `json:"duration"`
+}
+
+// AIOverrides are per-session AI parameters. Zero fields keep the
+// configured value; Temperature is a pointer because 0 is a valid value.
+type AIOverrides struct {
+	Model       string   `json:"model,omitempty"`
+	MaxTokens   int      `json:"max_tokens,omitempty"`
+	Temperature *float64 `json:"temperature,omitempty"`
+}
+
+// IsZero reports whether no parameter is overridden
+func (o AIOverrides) IsZero() bool {
+	return o.Model == "" && o.MaxTokens == 0 && o.Temperature == nil
 }
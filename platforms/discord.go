@@ -0,0 +1,804 @@
+package platform
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/gorilla/websocket"
+	"quickbot/internal/agent"
+	"quickbot/internal/ai"
+	"quickbot/internal/scheduler"
+	"quickbot/pkg/types"
+)
+
+// DiscordConfig represents Discord platform configuration
+type DiscordConfig struct {
+	Token        string
+	AllowedUsers []string
+
+	// CommandPermissions maps a slash command to the roles (names or IDs)
+	// allowed to use it; a member needs at least one of them
+	CommandPermissions map[string][]string
+	// AdminUserIDs bypass all role checks
+	AdminUserIDs []string
+
+	// AutoReconnect reopens the gateway after it disconnects, at most
+	// ReconnectMaxAttempts times in a row (0 retries forever)
+	AutoReconnect        bool
+	ReconnectMaxAttempts int
+
+	// MaxLength is the longest message sent, in characters (default
+	// 2000); longer AI responses are shortened with TruncationStrategy
+	MaxLength          int
+	TruncationStrategy string
+}
+
+const (
+	// discordMessageLimit is the longest message Discord accepts
+	discordMessageLimit = 2000
+
+	// discordTypingInterval is how often the typing indicator is refreshed
+	// (Discord shows it for 10 seconds)
+	discordTypingInterval = 8 * time.Second
+)
+
+// Gateway connection states reported by DiscordPlatform.ConnectionState
+const (
+	StateConnected    = "connected"
+	StateReconnecting = "reconnecting"
+	StateDisconnected = "disconnected"
+)
+
+// discordCommands are the slash commands registered on startup
+var discordCommands = []*discordgo.ApplicationCommand{
+	{Name: "start", Description: "启动机器人"},
+	{Name: "help", Description: "显示帮助信息"},
+	{Name: "status", Description: "查看系统状态"},
+}
+
+// DiscordPlatform represents Discord bot platform
+type DiscordPlatform struct {
+	config  *DiscordConfig
+	session *discordgo.Session
+	agent   *agent.Agent
+	started bool
+	mu      sync.RWMutex
+
+	reconnecting     atomic.Bool
+	reconnectCount   atomic.Int64
+	reconnectBackoff time.Duration
+	connState        atomic.Value
+
+	// open opens the gateway connection; replaced in tests
+	open func() error
+}
+
+// NewDiscordPlatform creates a new Discord platform instance
+func NewDiscordPlatform(cfg *DiscordConfig, bot *agent.Agent) (*DiscordPlatform, error) {
+	session, err := discordgo.New("Bot " + cfg.Token)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Discord session: %w", err)
+	}
+
+	session.Identify.Intents = discordgo.IntentGuilds |
+		discordgo.IntentGuildMessages |
+		discordgo.IntentDirectMessages |
+		discordgo.IntentMessageContent
+
+	// Reconnection is handled by handleDisconnect
+	session.ShouldReconnectOnError = false
+
+	platform := &DiscordPlatform{
+		config:  cfg,
+		session: session,
+		agent:   bot,
+
+		reconnectBackoff: defaultReconnectBackoff,
+		open:             session.Open,
+	}
+	platform.connState.Store(StateDisconnected)
+
+	session.AddHandler(platform.handleMessageCreate)
+	session.AddHandler(platform.handleInteraction)
+	session.AddHandler(platform.handleDisconnect)
+
+	// Deliver scheduled task results to Discord users
+	if scheduler := bot.Scheduler(); scheduler != nil {
+		scheduler.SetPlatformNotificationHandler("discord", platform.handleTaskNotification)
+	}
+
+	return platform, nil
+}
+
+// Start opens the gateway connection and registers the slash commands
+func (p *DiscordPlatform) Start() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.started {
+		return fmt.Errorf("platform already started")
+	}
+
+	log.Println("Starting Discord platform...")
+
+	if err := p.open(); err != nil {
+		return fmt.Errorf("failed to open Discord connection: %w", err)
+	}
+	p.started = true
+	p.connState.Store(StateConnected)
+
+	for _, command := range discordCommands {
+		_, err := p.session.ApplicationCommandCreate(p.session.State.User.ID, "", command)
+		if err != nil {
+			log.Printf("Warning: failed to register Discord command /%s: %v", command.Name, err)
+		}
+	}
+
+	log.Println("✓ Discord platform started")
+	return nil
+}
+
+// Stop closes the gateway connection
+func (p *DiscordPlatform) Stop() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if !p.started {
+		return fmt.Errorf("platform not started")
+	}
+
+	log.Println("Stopping Discord platform...")
+
+	// Clear started first so the disconnect is not reconnected
+	p.started = false
+	p.connState.Store(StateDisconnected)
+	if err := p.session.Close(); err != nil {
+		return fmt.Errorf("failed to close Discord connection: %w", err)
+	}
+
+	log.Println("✓ Discord platform stopped")
+	return nil
+}
+
+// IsStarted returns whether the platform is started
+func (p *DiscordPlatform) IsStarted() bool {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.started
+}
+
+// SupportsStreaming returns false: replies are sent once complete
+func (p *DiscordPlatform) SupportsStreaming() bool {
+	return false
+}
+
+// ReconnectCount returns how many times the gateway was reconnected
+func (p *DiscordPlatform) ReconnectCount() int64 {
+	return p.reconnectCount.Load()
+}
+
+// ConnectionState returns the state of the gateway connection:
+// "connected", "reconnecting" or "disconnected"
+func (p *DiscordPlatform) ConnectionState() string {
+	state, _ := p.connState.Load().(string)
+	if state == "" {
+		return StateDisconnected
+	}
+	return state
+}
+
+// handleDisconnect reopens the gateway, with backoff, when it disconnects
+// while the platform is still started. Reconnection stops at a close code
+// that reconnecting cannot fix, such as 4004 (authentication failed).
+func (p *DiscordPlatform) handleDisconnect(s *discordgo.Session, d *discordgo.Disconnect) {
+	if !p.IsStarted() {
+		return
+	}
+	if !p.config.AutoReconnect {
+		p.connState.Store(StateDisconnected)
+		return
+	}
+	// Only one reconnection loop at a time
+	if !p.reconnecting.CompareAndSwap(false, true) {
+		return
+	}
+	p.connState.Store(StateReconnecting)
+
+	go func() {
+		defer p.reconnecting.Store(false)
+
+		maxAttempts := p.config.ReconnectMaxAttempts
+		for attempt := 1; maxAttempts <= 0 || attempt <= maxAttempts; attempt++ {
+			wait := ai.BackoffDelay(attempt, p.reconnectBackoff, maxReconnectBackoff)
+			log.Printf("Warning: Discord gateway disconnected, reconnecting in %v (attempt %d)", wait, attempt)
+			time.Sleep(wait)
+
+			p.mu.Lock()
+			if !p.started {
+				p.mu.Unlock()
+				return
+			}
+			err := p.open()
+			p.mu.Unlock()
+
+			if err == nil {
+				p.reconnectCount.Add(1)
+				p.connState.Store(StateConnected)
+				log.Println("✓ Discord gateway reconnected")
+				return
+			}
+			if isFatalCloseError(err) {
+				p.connState.Store(StateDisconnected)
+				log.Printf("Error: Discord gateway closed the connection for good: %v", err)
+				return
+			}
+			log.Printf("Error reconnecting to Discord: %v", err)
+		}
+		p.connState.Store(StateDisconnected)
+		log.Printf("Error: Discord reconnection failed %d times, giving up", maxAttempts)
+	}()
+}
+
+// isFatalCloseError reports whether the gateway closed the connection
+// with a code that reconnecting cannot fix: authentication failed (4004),
+// invalid shard (4010), sharding required (4011), invalid API version
+// (4012) or invalid or disallowed intents (4013, 4014)
+func isFatalCloseError(err error) bool {
+	var closeErr *websocket.CloseError
+	if !errors.As(err, &closeErr) {
+		return false
+	}
+	switch closeErr.Code {
+	case 4004, 4010, 4011, 4012, 4013, 4014:
+		return true
+	}
+	return false
+}
+
+// isUserAllowed checks if a user is allowed to interact with the bot
+func (p *DiscordPlatform) isUserAllowed(userID string) bool {
+	// If whitelist is empty, allow all users
+	if len(p.config.AllowedUsers) == 0 {
+		return true
+	}
+
+	for _, allowed := range p.config.AllowedUsers {
+		if allowed == userID {
+			return true
+		}
+	}
+
+	return false
+}
+
+// handleMessageCreate handles MESSAGE_CREATE events. Direct messages are
+// always answered; in servers the bot only answers when mentioned.
+func (p *DiscordPlatform) handleMessageCreate(s *discordgo.Session, m *discordgo.MessageCreate) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("Error: panic handling Discord message %s: %v", m.ID, r)
+		}
+	}()
+
+	if m.Author == nil || m.Author.Bot {
+		return
+	}
+
+	text := m.Content
+	if m.GuildID != "" {
+		botID := s.State.User.ID
+		if !isMentioned(m.Mentions, botID) {
+			return
+		}
+		text = stripMention(text, botID)
+	}
+
+	if !p.isUserAllowed(m.Author.ID) {
+		log.Printf("Unauthorized user attempt: %s (%s)", m.Author.ID, m.Author.Username)
+		return
+	}
+
+	p.processMessage(m.ChannelID, m.ID, "discord:"+m.Author.ID, text)
+}
+
+// isMentioned reports whether a user is among a message's mentions
+func isMentioned(mentions []*discordgo.User, userID string) bool {
+	for _, user := range mentions {
+		if user.ID == userID {
+			return true
+		}
+	}
+	return false
+}
+
+// stripMention removes the mentions of a user from a message
+func stripMention(text, userID string) string {
+	text = strings.ReplaceAll(text, "<@"+userID+">", "")
+	text = strings.ReplaceAll(text, "<@!"+userID+">", "")
+	return strings.TrimSpace(text)
+}
+
+// processMessage processes a regular message and sends the response to
+// the channel it came from
+func (p *DiscordPlatform) processMessage(channelID, messageID, sessionID, userMessage string) {
+	if userMessage == "" {
+		return
+	}
+
+	log.Printf("[Discord][%s] Received: %s", sessionID, userMessage)
+
+	stopTyping := p.startTypingIndicator(channelID)
+	ctx := types.WithPlatform(context.Background(), "discord")
+	ctx = types.WithPlatformMessageID(ctx, messageID)
+	response, err := p.agent.ProcessMessage(ctx, sessionID, userMessage)
+	stopTyping()
+	if errors.Is(err, agent.ErrRedelivered) {
+		return
+	}
+	if err != nil {
+		log.Printf("Error processing message: %v", err)
+		p.send(channelID, "抱歉，处理消息时出错。")
+		return
+	}
+
+	for _, part := range p.agent.ResponseParts(response, p.config.TruncationStrategy, p.maxLength()) {
+		p.send(channelID, p.agent.FormatResponse("discord", part))
+	}
+}
+
+// startTypingIndicator shows the typing indicator in a channel until the
+// returned function is called
+func (p *DiscordPlatform) startTypingIndicator(channelID string) context.CancelFunc {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	go func() {
+		ticker := time.NewTicker(discordTypingInterval)
+		defer ticker.Stop()
+
+		for {
+			if err := p.session.ChannelTyping(channelID); err != nil {
+				log.Printf("Error sending typing indicator: %v", err)
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+
+	return cancel
+}
+
+// send sends a message, logging failures
+func (p *DiscordPlatform) send(channelID, text string) {
+	if err := p.SendMessage(channelID, text); err != nil {
+		log.Printf("Error sending reply: %v", err)
+	}
+}
+
+// SendMessage sends a message directly to a channel, shortened to the
+// configured length
+func (p *DiscordPlatform) SendMessage(channelID string, text string) error {
+	text, _ = p.agent.TruncateResponse(text, agent.TruncateStrategy, p.maxLength())
+
+	_, err := p.session.ChannelMessageSend(channelID, text)
+	return err
+}
+
+// handleTaskNotification sends a task result to the user of the task's
+// session (session ID format "discord:<userID>") in a direct message
+func (p *DiscordPlatform) handleTaskNotification(task *scheduler.Task, result string, platform string) {
+	if result == "" {
+		return
+	}
+
+	userID := strings.TrimPrefix(task.SessionID, "discord:")
+	if userID == task.SessionID || userID == "" {
+		log.Printf("Cannot notify task %s: invalid session ID %s", task.ID, task.SessionID)
+		return
+	}
+
+	channel, err := p.session.UserChannelCreate(userID)
+	if err != nil {
+		log.Printf("Error opening direct message for task notification: %v", err)
+		return
+	}
+	if err := p.SendMessage(channel.ID, result); err != nil {
+		log.Printf("Error sending task notification: %v", err)
+	}
+}
+
+// maxLength returns the configured message length, at most what Discord
+// accepts
+func (p *DiscordPlatform) maxLength() int {
+	if p.config.MaxLength <= 0 || p.config.MaxLength > discordMessageLimit {
+		return discordMessageLimit
+	}
+	return p.config.MaxLength
+}
+
+// handleInteraction handles slash commands
+func (p *DiscordPlatform) handleInteraction(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	if i.Type != discordgo.InteractionApplicationCommand {
+		return
+	}
+
+	user := i.User
+	if i.Member != nil {
+		user = i.Member.User
+	}
+	if user == nil {
+		return
+	}
+
+	command := i.ApplicationCommandData().Name
+	if !p.isUserAllowed(user.ID) {
+		log.Printf("Unauthorized user attempt: %s (%s)", user.ID, user.Username)
+		p.respond(i, "⛔ 你没有使用此机器人的权限。")
+		return
+	}
+
+	// Check the member's live roles, falling back to those sent with
+	// the interaction
+	var roles []string
+	if i.Member != nil && len(p.config.CommandPermissions[command]) > 0 {
+		var err error
+		roles, err = p.memberRoles(i.GuildID, user.ID)
+		if err != nil {
+			log.Printf("Error fetching Discord member roles: %v", err)
+			roles = p.roleNames(i.GuildID, i.Member.Roles)
+		}
+	}
+	if !p.canUseCommand(command, user.ID, roles) {
+		p.respond(i, fmt.Sprintf("⛔ 你没有使用 /%s 的权限。", command))
+		return
+	}
+
+	switch command {
+	case "start":
+		p.respond(i, fmt.Sprintf(
+			"👋 你好！我是 **%s**！\n\n"+
+				"发送 /help 查看可用命令。\n\n"+
+				"你也可以直接私信我，或在服务器中 @ 我聊天！",
+			p.agent.Config().Bot.Name,
+		))
+
+	case "help":
+		p.respond(i, p.generateHelpText())
+
+	case "status":
+		p.respond(i, p.generateStatusText())
+
+	default:
+		p.respond(i, fmt.Sprintf("未知命令: /%s\n发送 /help 查看帮助", command))
+	}
+}
+
+// respond answers a slash command
+func (p *DiscordPlatform) respond(i *discordgo.InteractionCreate, text string) {
+	err := p.session.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseChannelMessageWithSource,
+		Data: &discordgo.InteractionResponseData{Content: text},
+	})
+	if err != nil {
+		log.Printf("Error responding to Discord command: %v", err)
+	}
+}
+
+// roleNames returns the IDs of a member's roles together with their names,
+// so command permissions may list either. Roles missing from the state
+// are fetched from the API.
+func (p *DiscordPlatform) roleNames(guildID string, roleIDs []string) []string {
+	roles := append([]string{}, roleIDs...)
+	var guildRoles map[string]string
+	for _, id := range roleIDs {
+		if role, err := p.session.State.Role(guildID, id); err == nil {
+			roles = append(roles, role.Name)
+			continue
+		}
+		if guildRoles == nil {
+			guildRoles = map[string]string{}
+			fetched, err := p.session.GuildRoles(guildID)
+			if err != nil {
+				log.Printf("Error fetching Discord guild roles: %v", err)
+			}
+			for _, role := range fetched {
+				guildRoles[role.ID] = role.Name
+			}
+		}
+		if name, ok := guildRoles[id]; ok {
+			roles = append(roles, name)
+		}
+	}
+	return roles
+}
+
+// memberRoles returns the IDs and names of the roles a guild member has
+// now, fetched from the API
+func (p *DiscordPlatform) memberRoles(guildID, userID string) ([]string, error) {
+	member, err := p.session.GuildMember(guildID, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get guild member: %w", err)
+	}
+	return p.roleNames(guildID, member.Roles), nil
+}
+
+// HasRole reports whether a guild member currently has a role, given by
+// name or ID
+func (p *DiscordPlatform) HasRole(guildID, userID, roleName string) (bool, error) {
+	roles, err := p.memberRoles(guildID, userID)
+	if err != nil {
+		return false, err
+	}
+	for _, role := range roles {
+		if role == roleName {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// canUseCommand checks the command permissions: commands without
+// required roles are open to everyone, admins may use every command, and
+// other users need one of the required roles
+func (p *DiscordPlatform) canUseCommand(command, userID string, roles []string) bool {
+	required := p.config.CommandPermissions[command]
+	if len(required) == 0 {
+		return true
+	}
+
+	for _, admin := range p.config.AdminUserIDs {
+		if admin == userID {
+			return true
+		}
+	}
+
+	for _, role := range roles {
+		for _, allowed := range required {
+			if role == allowed {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// generateHelpText generates help message
+func (p *DiscordPlatform) generateHelpText() string {
+	return fmt.Sprintf(`📖 **%s 命令列表**
+
+/start - 启动机器人
+/help - 显示此帮助信息
+/status - 查看系统状态
+
+你也可以直接私信我，或在服务器中 @ 我聊天！
+
+💡 **可用功能**
+• AI 助手对话
+• 文件操作
+• 计算功能
+• 长期记忆
+• 任务提醒`,
+		p.agent.Config().Bot.Name,
+	)
+}
+
+// generateStatusText generates status message
+func (p *DiscordPlatform) generateStatusText() string {
+	cfg := p.agent.Config()
+
+	statusParts := []string{
+		fmt.Sprintf("🤖 **%s 状态**", cfg.Bot.Name),
+		"📊 平台: Discord",
+		fmt.Sprintf("🧠 AI 提供商: %s", cfg.AI.Provider),
+		fmt.Sprintf("📝 模型: %s", cfg.AI.Model),
+	}
+
+	return strings.Join(statusParts, "\n")
+}
+
+// TestDiscord checks the Discord user and command permission checks
+func TestDiscord() error {
+	log.Println("Testing Discord platform...")
+
+	p := &DiscordPlatform{config: &DiscordConfig{
+		AllowedUsers:       []string{"100", "200"},
+		CommandPermissions: map[string][]string{"status": {"Moderator", "999"}},
+		AdminUserIDs:       []string{"200"},
+	}}
+
+	if !p.isUserAllowed("100") || p.isUserAllowed("300") {
+		return fmt.Errorf("allowed_users not respected")
+	}
+	log.Println("✓ Allowed users respected")
+
+	checks := []struct {
+		command string
+		userID  string
+		roles   []string
+		want    bool
+	}{
+		{"help", "100", nil, true},
+		{"status", "100", nil, false},
+		{"status", "100", []string{"Member"}, false},
+		{"status", "100", []string{"Moderator"}, true},
+		{"status", "100", []string{"999"}, true},
+		{"status", "200", nil, true},
+	}
+	for _, c := range checks {
+		if got := p.canUseCommand(c.command, c.userID, c.roles); got != c.want {
+			return fmt.Errorf("/%s by %s with roles %v: expected %v, got %v", c.command, c.userID, c.roles, c.want, got)
+		}
+	}
+	log.Println("✓ Command permissions enforced")
+
+	if text := stripMention("<@42> hello <@!42>", "42"); text != "hello" {
+		return fmt.Errorf("expected mention stripped, got %q", text)
+	}
+	log.Println("✓ Bot mention stripped")
+
+	if err := testDiscordRoles(); err != nil {
+		return err
+	}
+	return testDiscordReconnect()
+}
+
+// testDiscordAPI serves the guild member, guild roles and interaction
+// callback endpoints of the Discord API and records the responses
+type testDiscordAPI struct {
+	mu        sync.Mutex
+	members   map[string][]string
+	responses []string
+}
+
+func (api *testDiscordAPI) RoundTrip(req *http.Request) (*http.Response, error) {
+	api.mu.Lock()
+	defer api.mu.Unlock()
+
+	var body interface{}
+	switch path := req.URL.Path; {
+	case strings.Contains(path, "/members/"):
+		userID := path[strings.LastIndex(path, "/")+1:]
+		body = map[string]interface{}{"user": map[string]string{"id": userID}, "roles": api.members[userID]}
+	case strings.HasSuffix(path, "/roles"):
+		body = []map[string]string{{"id": "r-admin", "name": "Admin"}, {"id": "r-member", "name": "Member"}}
+	case strings.HasSuffix(path, "/callback"):
+		var response discordgo.InteractionResponse
+		if err := json.NewDecoder(req.Body).Decode(&response); err != nil {
+			return nil, err
+		}
+		api.responses = append(api.responses, response.Data.Content)
+		return &http.Response{StatusCode: http.StatusNoContent, Body: io.NopCloser(strings.NewReader("")), Request: req}, nil
+	default:
+		return &http.Response{StatusCode: http.StatusNotFound, Body: io.NopCloser(strings.NewReader(`{}`)), Request: req}, nil
+	}
+
+	data, _ := json.Marshal(body)
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Header:     http.Header{"Content-Type": {"application/json"}},
+		Body:       io.NopCloser(strings.NewReader(string(data))),
+		Request:    req,
+	}, nil
+}
+
+// testDiscordRoles runs /admin, which requires the Admin role, as a
+// member whose live roles no longer include it and as one who has it
+func testDiscordRoles() error {
+	api := &testDiscordAPI{members: map[string][]string{"100": {"r-member"}, "200": {"r-admin"}}}
+	session, err := discordgo.New("Bot test")
+	if err != nil {
+		return err
+	}
+	session.Client = &http.Client{Transport: api}
+
+	p := &DiscordPlatform{
+		config:  &DiscordConfig{CommandPermissions: map[string][]string{"admin": {"Admin"}}},
+		session: session,
+	}
+
+	for _, userID := range []string{"100", "200"} {
+		p.handleInteraction(session, &discordgo.InteractionCreate{Interaction: &discordgo.Interaction{
+			ID:      "i" + userID,
+			Token:   "token",
+			Type:    discordgo.InteractionApplicationCommand,
+			GuildID: "g1",
+			// Stale roles sent with the interaction
+			Member: &discordgo.Member{User: &discordgo.User{ID: userID}, Roles: []string{"r-admin"}},
+			Data:   discordgo.ApplicationCommandInteractionData{Name: "admin"},
+		}})
+	}
+	if len(api.responses) != 2 {
+		return fmt.Errorf("expected 2 command responses, got %v", api.responses)
+	}
+	if api.responses[0] != "⛔ 你没有使用 /admin 的权限。" {
+		return fmt.Errorf("expected permission denied for a member without Admin, got %q", api.responses[0])
+	}
+	if strings.HasPrefix(api.responses[1], "⛔") {
+		return fmt.Errorf("expected an Admin member to pass the permission check, got %q", api.responses[1])
+	}
+	log.Println("✓ Command permissions checked against live roles")
+
+	for _, c := range []struct {
+		userID, role string
+		want         bool
+	}{
+		{"200", "Admin", true},
+		{"100", "Admin", false},
+		{"100", "r-member", true},
+	} {
+		if has, err := p.HasRole("g1", c.userID, c.role); err != nil || has != c.want {
+			return fmt.Errorf("HasRole(%s, %s): expected %v, got %v (%v)", c.userID, c.role, c.want, has, err)
+		}
+	}
+	log.Println("✓ Member roles looked up by name and ID")
+
+	return nil
+}
+
+// testDiscordReconnect simulates a gateway disconnect and checks the
+// connection state while reconnecting and after Open succeeds
+func testDiscordReconnect() error {
+	opened := make(chan struct{})
+	p := &DiscordPlatform{
+		config:           &DiscordConfig{AutoReconnect: true, ReconnectMaxAttempts: 3},
+		started:          true,
+		reconnectBackoff: time.Millisecond,
+		open: func() error {
+			<-opened
+			return nil
+		},
+	}
+	p.connState.Store(StateConnected)
+
+	p.handleDisconnect(nil, &discordgo.Disconnect{})
+	if state := p.ConnectionState(); state != StateReconnecting {
+		return fmt.Errorf("expected state %q after disconnect, got %q", StateReconnecting, state)
+	}
+
+	close(opened)
+	deadline := time.Now().Add(2 * time.Second)
+	for p.ConnectionState() != StateConnected {
+		if time.Now().After(deadline) {
+			return fmt.Errorf("expected state %q after reconnecting, got %q", StateConnected, p.ConnectionState())
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	if p.ReconnectCount() != 1 {
+		return fmt.Errorf("expected 1 reconnect, got %d", p.ReconnectCount())
+	}
+	log.Println("✓ Gateway reconnected after disconnect")
+
+	p = &DiscordPlatform{
+		config:           &DiscordConfig{AutoReconnect: true, ReconnectMaxAttempts: 5},
+		started:          true,
+		reconnectBackoff: time.Millisecond,
+		open: func() error {
+			return &websocket.CloseError{Code: 4004, Text: "Authentication failed."}
+		},
+	}
+	p.handleDisconnect(nil, &discordgo.Disconnect{})
+	deadline = time.Now().Add(2 * time.Second)
+	for p.ConnectionState() != StateDisconnected {
+		if time.Now().After(deadline) {
+			return fmt.Errorf("expected close code 4004 to stop reconnecting, got state %q", p.ConnectionState())
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	log.Println("✓ Authentication failure not reconnected")
+
+	return nil
+}
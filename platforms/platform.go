@@ -0,0 +1,19 @@
+package platform
+
+// Platform is a chat platform the bot is connected to
+type Platform interface {
+	Start() error
+	Stop() error
+	IsStarted() bool
+
+	// SupportsStreaming reports whether the platform can show an AI
+	// response as it is generated
+	SupportsStreaming() bool
+}
+
+var (
+	_ Platform = (*TelegramPlatform)(nil)
+	_ Platform = (*DiscordPlatform)(nil)
+	_ Platform = (*SlackPlatform)(nil)
+	_ Platform = (*WebhookPlatform)(nil)
+)
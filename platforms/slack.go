@@ -0,0 +1,633 @@
+package platform
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/slack-go/slack"
+	"github.com/slack-go/slack/slackevents"
+	"github.com/slack-go/slack/socketmode"
+	"quickbot/internal/agent"
+	"quickbot/internal/ai"
+	"quickbot/internal/scheduler"
+	"quickbot/pkg/types"
+)
+
+// SlackConfig represents Slack platform configuration
+type SlackConfig struct {
+	// AppToken (xapp-...) opens the Socket Mode connection; BotToken
+	// (xoxb-...) posts messages
+	AppToken     string
+	BotToken     string
+	AllowedUsers []string
+
+	// MaxReconnectAttempts limits how many times in a row the Socket Mode
+	// connection is reopened after failing (0 retries forever). Reconnect
+	// delays start at ReconnectBaseSeconds and double up to a minute.
+	MaxReconnectAttempts int
+	ReconnectBaseSeconds int
+}
+
+// slackMessageLimit is the longest message sent; longer responses are
+// continued in thread replies
+const slackMessageLimit = 3000
+
+// slackAgent is the part of the agent the Slack platform uses
+type slackAgent interface {
+	ProcessMessage(ctx context.Context, sessionID, userMessage string) (string, error)
+	ResponseParts(text, strategy string, maxLen int) []string
+}
+
+// slackPoster posts a message to a channel, in a thread if threadTS is
+// set, and returns the message timestamp
+type slackPoster interface {
+	post(channelID, threadTS, text string) (string, error)
+}
+
+// slackAPIPoster posts through the Slack Web API
+type slackAPIPoster struct {
+	api *slack.Client
+}
+
+func (p slackAPIPoster) post(channelID, threadTS, text string) (string, error) {
+	options := []slack.MsgOption{slack.MsgOptionText(text, false)}
+	if threadTS != "" {
+		options = append(options, slack.MsgOptionTS(threadTS))
+	}
+	_, ts, err := p.api.PostMessage(channelID, options...)
+	return ts, err
+}
+
+// slackMessage is a message addressed to the bot
+type slackMessage struct {
+	UserID    string
+	ChannelID string
+	Text      string
+	TS        string
+	ThreadTS  string
+}
+
+// SlackPlatform represents Slack bot platform, connected through Socket
+// Mode so no public URL is needed. The bot answers app mentions and
+// direct messages.
+type SlackPlatform struct {
+	config *SlackConfig
+	api    *slack.Client
+	socket *socketmode.Client
+	agent  slackAgent
+	poster slackPoster
+
+	// ack acknowledges a Socket Mode request
+	ack func(req socketmode.Request)
+	// run runs the Socket Mode connection until it fails or ctx is done
+	run func(ctx context.Context) error
+
+	botUserID string
+	started   bool
+	cancel    context.CancelFunc
+	wg        sync.WaitGroup
+	mu        sync.RWMutex
+
+	reconnectAttempts int
+	reconnectBackoff  time.Duration
+	reconnectCount    atomic.Int64
+	// connected is set when a connection is established, so that a later
+	// failure starts counting attempts again
+	connected atomic.Bool
+}
+
+// NewSlackPlatform creates a new Slack platform instance
+func NewSlackPlatform(cfg *SlackConfig, bot *agent.Agent) (*SlackPlatform, error) {
+	if !strings.HasPrefix(cfg.AppToken, "xapp-") {
+		return nil, fmt.Errorf("slack app token must start with xapp-")
+	}
+
+	api := slack.New(cfg.BotToken, slack.OptionAppLevelToken(cfg.AppToken))
+	socket := socketmode.New(api)
+
+	reconnectBackoff := time.Duration(cfg.ReconnectBaseSeconds) * time.Second
+	if reconnectBackoff <= 0 {
+		reconnectBackoff = defaultReconnectBackoff
+	}
+
+	platform := &SlackPlatform{
+		config: cfg,
+		api:    api,
+		socket: socket,
+		agent:  bot,
+		poster: slackAPIPoster{api: api},
+		ack: func(req socketmode.Request) {
+			socket.Ack(req)
+		},
+		run: socket.RunContext,
+
+		reconnectAttempts: cfg.MaxReconnectAttempts,
+		reconnectBackoff:  reconnectBackoff,
+	}
+
+	// Deliver scheduled task results to Slack users
+	if scheduler := bot.Scheduler(); scheduler != nil {
+		scheduler.SetPlatformNotificationHandler("slack", platform.handleTaskNotification)
+	}
+
+	return platform, nil
+}
+
+// Start connects to Slack and handles events until Stop is called
+func (p *SlackPlatform) Start() error {
+	p.mu.Lock()
+	if p.started {
+		p.mu.Unlock()
+		return fmt.Errorf("platform already started")
+	}
+
+	log.Println("Starting Slack platform...")
+
+	// The lock is not held while talking to Slack; Stop cancels ctx
+	ctx, cancel := context.WithCancel(context.Background())
+	p.cancel = cancel
+	p.started = true
+	p.mu.Unlock()
+
+	auth, err := p.api.AuthTestContext(ctx)
+	if err != nil {
+		p.mu.Lock()
+		cancel()
+		p.started = false
+		p.mu.Unlock()
+		return fmt.Errorf("failed to authenticate Slack bot: %w", err)
+	}
+	p.botUserID = auth.UserID
+
+	go p.reconnectLoop(ctx)
+	go p.handleEvents(ctx, p.socket.Events)
+
+	log.Println("✓ Slack platform started")
+	return nil
+}
+
+// Stop disconnects from Slack and waits for messages being answered
+func (p *SlackPlatform) Stop() error {
+	p.mu.Lock()
+	if !p.started {
+		p.mu.Unlock()
+		return fmt.Errorf("platform not started")
+	}
+
+	log.Println("Stopping Slack platform...")
+
+	p.cancel()
+	p.started = false
+	p.mu.Unlock()
+
+	p.wg.Wait()
+	log.Println("✓ Slack platform stopped")
+	return nil
+}
+
+// IsStarted returns whether the platform is started
+func (p *SlackPlatform) IsStarted() bool {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.started
+}
+
+// SupportsStreaming returns false: responses are posted, in thread
+// replies if long, once complete
+func (p *SlackPlatform) SupportsStreaming() bool {
+	return false
+}
+
+// ReconnectCount returns how many times the Socket Mode connection was
+// reopened
+func (p *SlackPlatform) ReconnectCount() int64 {
+	return p.reconnectCount.Load()
+}
+
+// reconnectLoop runs the Socket Mode connection until ctx is done, and
+// reopens it, with backoff, when it fails
+func (p *SlackPlatform) reconnectLoop(ctx context.Context) {
+	attempt := 0
+
+	for {
+		err := p.run(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+
+		p.mu.RLock()
+		maxAttempts, baseBackoff := p.reconnectAttempts, p.reconnectBackoff
+		p.mu.RUnlock()
+
+		if p.connected.Swap(false) {
+			attempt = 0
+		}
+		attempt++
+		if maxAttempts > 0 && attempt > maxAttempts {
+			log.Printf("Error: Slack Socket Mode connection failed %d times, giving up: %v", maxAttempts, err)
+			return
+		}
+
+		wait := ai.BackoffDelay(attempt, baseBackoff, maxReconnectBackoff)
+		log.Printf("Warning: Slack Socket Mode connection failed: %v, reconnecting in %v (attempt %d)", err, wait, attempt)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(wait):
+		}
+
+		p.reconnectCount.Add(1)
+	}
+}
+
+// handleEvents handles Socket Mode events until ctx is done or events is
+// closed. Each message is answered in its own goroutine so slow responses
+// do not hold up acknowledgements.
+func (p *SlackPlatform) handleEvents(ctx context.Context, events <-chan socketmode.Event) {
+	for {
+		var evt socketmode.Event
+		var ok bool
+		select {
+		case <-ctx.Done():
+			return
+		case evt, ok = <-events:
+			if !ok {
+				return
+			}
+		}
+
+		switch evt.Type {
+		case socketmode.EventTypeConnecting:
+			log.Println("Connecting to Slack...")
+		case socketmode.EventTypeConnected:
+			log.Println("✓ Connected to Slack")
+			p.connected.Store(true)
+		case socketmode.EventTypeConnectionError:
+			log.Printf("Warning: Slack connection error: %v", evt.Data)
+		case socketmode.EventTypeEventsAPI:
+			if evt.Request != nil {
+				p.ack(*evt.Request)
+			}
+			eventsAPIEvent, ok := evt.Data.(slackevents.EventsAPIEvent)
+			if !ok {
+				continue
+			}
+			message, ok := p.parseEvent(eventsAPIEvent)
+			if !ok {
+				continue
+			}
+
+			p.wg.Add(1)
+			go func() {
+				defer p.wg.Done()
+				p.handleMessage(message)
+			}()
+		}
+	}
+}
+
+// parseEvent returns the message of an app mention or direct message
+// event. Other events, bot messages and message edits are ignored.
+func (p *SlackPlatform) parseEvent(event slackevents.EventsAPIEvent) (slackMessage, bool) {
+	if event.Type != slackevents.CallbackEvent {
+		return slackMessage{}, false
+	}
+
+	switch ev := event.InnerEvent.Data.(type) {
+	case *slackevents.AppMentionEvent:
+		if ev.BotID != "" {
+			return slackMessage{}, false
+		}
+		return slackMessage{
+			UserID:    ev.User,
+			ChannelID: ev.Channel,
+			Text:      p.stripMention(ev.Text),
+			TS:        ev.TimeStamp,
+			ThreadTS:  ev.ThreadTimeStamp,
+		}, true
+
+	case *slackevents.MessageEvent:
+		if ev.ChannelType != "im" || ev.BotID != "" || ev.SubType != "" || ev.User == p.botUserID {
+			return slackMessage{}, false
+		}
+		return slackMessage{
+			UserID:    ev.User,
+			ChannelID: ev.Channel,
+			Text:      strings.TrimSpace(ev.Text),
+			TS:        ev.TimeStamp,
+			ThreadTS:  ev.ThreadTimeStamp,
+		}, true
+	}
+
+	return slackMessage{}, false
+}
+
+// stripMention removes the mentions of the bot from a message
+func (p *SlackPlatform) stripMention(text string) string {
+	if p.botUserID != "" {
+		text = strings.ReplaceAll(text, "<@"+p.botUserID+">", "")
+	}
+	return strings.TrimSpace(text)
+}
+
+// isUserAllowed checks if a user is allowed to interact with the bot
+func (p *SlackPlatform) isUserAllowed(userID string) bool {
+	// If whitelist is empty, allow all users
+	if len(p.config.AllowedUsers) == 0 {
+		return true
+	}
+
+	for _, allowed := range p.config.AllowedUsers {
+		if allowed == userID {
+			return true
+		}
+	}
+
+	return false
+}
+
+// handleMessage answers a message through the agent
+func (p *SlackPlatform) handleMessage(message slackMessage) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("Error: panic handling Slack message %s: %v", message.TS, r)
+		}
+	}()
+
+	if message.Text == "" {
+		return
+	}
+	if !p.isUserAllowed(message.UserID) {
+		log.Printf("Unauthorized user attempt: %s", message.UserID)
+		return
+	}
+
+	sessionID := "slack:" + message.UserID
+	log.Printf("[Slack][%s] Received: %s", sessionID, message.Text)
+
+	// Message timestamps are unique within a channel
+	ctx := types.WithPlatform(context.Background(), "slack")
+	ctx = types.WithPlatformMessageID(ctx, message.ChannelID+"/"+message.TS)
+	response, err := p.agent.ProcessMessage(ctx, sessionID, message.Text)
+	if errors.Is(err, agent.ErrRedelivered) {
+		return
+	}
+	if err != nil {
+		log.Printf("Error processing message: %v", err)
+		response = "抱歉，处理消息时出错。"
+	}
+
+	if err := p.sendResponse(message.ChannelID, message.ThreadTS, response); err != nil {
+		log.Printf("Error sending reply: %v", err)
+	}
+}
+
+// sendResponse posts a response to a channel, in threadTS if set. Parts
+// beyond slackMessageLimit are posted as replies in the thread of the
+// first part.
+func (p *SlackPlatform) sendResponse(channelID, threadTS, response string) error {
+	for _, part := range p.agent.ResponseParts(response, agent.SplitStrategy, slackMessageLimit) {
+		ts, err := p.poster.post(channelID, threadTS, part)
+		if err != nil {
+			return err
+		}
+		if threadTS == "" {
+			threadTS = ts
+		}
+	}
+	return nil
+}
+
+// handleTaskNotification sends a task result to the user of the task's
+// session (session ID format "slack:<userID>"); posting to a user ID
+// sends a direct message from the app
+func (p *SlackPlatform) handleTaskNotification(task *scheduler.Task, result string, platform string) {
+	if result == "" {
+		return
+	}
+
+	userID := strings.TrimPrefix(task.SessionID, "slack:")
+	if userID == task.SessionID || userID == "" {
+		log.Printf("Cannot notify task %s: invalid session ID %s", task.ID, task.SessionID)
+		return
+	}
+
+	if err := p.SendMessage(userID, result); err != nil {
+		log.Printf("Error sending task notification: %v", err)
+	}
+}
+
+// SendMessage sends a message directly to a channel
+func (p *SlackPlatform) SendMessage(channelID string, text string) error {
+	return p.sendResponse(channelID, "", text)
+}
+
+// testSlackAgent echoes messages, repeated to a given length, and splits
+// responses into fixed-size parts
+type testSlackAgent struct {
+	mu       sync.Mutex
+	sessions []string
+	length   int
+}
+
+func (a *testSlackAgent) ProcessMessage(ctx context.Context, sessionID, userMessage string) (string, error) {
+	a.mu.Lock()
+	a.sessions = append(a.sessions, sessionID)
+	a.mu.Unlock()
+
+	if len(userMessage) >= a.length {
+		return userMessage, nil
+	}
+	return strings.Repeat(userMessage, a.length/len(userMessage)+1)[:a.length], nil
+}
+
+func (a *testSlackAgent) ResponseParts(text, strategy string, maxLen int) []string {
+	var parts []string
+	for len(text) > maxLen {
+		parts = append(parts, text[:maxLen])
+		text = text[maxLen:]
+	}
+	return append(parts, text)
+}
+
+// testSlackPost is a message posted by testSlackPoster
+type testSlackPost struct {
+	channelID, threadTS, text, ts string
+}
+
+// testSlackPoster records posted messages
+type testSlackPoster struct {
+	mu    sync.Mutex
+	posts []testSlackPost
+}
+
+func (p *testSlackPoster) post(channelID, threadTS, text string) (string, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	ts := fmt.Sprintf("1700000000.%06d", len(p.posts)+1)
+	p.posts = append(p.posts, testSlackPost{channelID, threadTS, text, ts})
+	return ts, nil
+}
+
+// TestSlack feeds Slack events through an in-process channel and checks
+// the replies
+func TestSlack() error {
+	log.Println("Testing Slack platform...")
+
+	bot := &testSlackAgent{length: 7000}
+	poster := &testSlackPoster{}
+	acked := 0
+	p := &SlackPlatform{
+		config:    &SlackConfig{AllowedUsers: []string{"U1", "U2"}},
+		agent:     bot,
+		poster:    poster,
+		ack:       func(req socketmode.Request) { acked++ },
+		botUserID: "UBOT",
+	}
+
+	callback := func(data interface{}) socketmode.Event {
+		return socketmode.Event{
+			Type:    socketmode.EventTypeEventsAPI,
+			Request: &socketmode.Request{},
+			Data: slackevents.EventsAPIEvent{
+				Type:       slackevents.CallbackEvent,
+				InnerEvent: slackevents.EventsAPIInnerEvent{Data: data},
+			},
+		}
+	}
+
+	events := make(chan socketmode.Event, 4)
+	events <- callback(&slackevents.AppMentionEvent{User: "U1", Channel: "C1", Text: "<@UBOT> hello", TimeStamp: "1.1"})
+	events <- callback(&slackevents.MessageEvent{User: "U2", Channel: "D2", ChannelType: "im", Text: "hi", TimeStamp: "2.2"})
+	events <- callback(&slackevents.MessageEvent{User: "U3", Channel: "D3", ChannelType: "im", Text: "hi", TimeStamp: "3.3"})
+	events <- callback(&slackevents.MessageEvent{User: "U2", Channel: "C1", ChannelType: "channel", Text: "not for the bot", TimeStamp: "4.4"})
+	close(events)
+
+	p.handleEvents(context.Background(), events)
+	p.wg.Wait()
+
+	if acked != 4 {
+		return fmt.Errorf("expected 4 events acknowledged, got %d", acked)
+	}
+	if len(bot.sessions) != 2 {
+		return fmt.Errorf("expected 2 messages processed, got %v", bot.sessions)
+	}
+	log.Println("✓ App mentions and direct messages routed, others ignored")
+
+	if err := testSlackReconnect(); err != nil {
+		return err
+	}
+
+	byChannel := make(map[string][]testSlackPost)
+	for _, post := range poster.posts {
+		if len(post.text) > slackMessageLimit {
+			return fmt.Errorf("message of %d characters posted", len(post.text))
+		}
+		byChannel[post.channelID] = append(byChannel[post.channelID], post)
+	}
+	for _, channelID := range []string{"C1", "D2"} {
+		posts := byChannel[channelID]
+		if len(posts) != 3 {
+			return fmt.Errorf("%s: expected 3 messages for a 7000 character response, got %d", channelID, len(posts))
+		}
+		if posts[0].threadTS != "" || posts[1].threadTS != posts[0].ts || posts[2].threadTS != posts[0].ts {
+			return fmt.Errorf("%s: expected parts after the first in its thread: %+v", channelID, posts)
+		}
+		if !strings.HasPrefix(posts[0].text, map[string]string{"C1": "hello", "D2": "hi"}[channelID]) {
+			return fmt.Errorf("%s: unexpected response %q", channelID, posts[0].text[:10])
+		}
+	}
+	if _, ok := byChannel["D3"]; ok {
+		return fmt.Errorf("replied to a user not in allowed_users")
+	}
+	log.Println("✓ Long responses split into thread replies")
+
+	before := len(poster.posts)
+	p.handleTaskNotification(&scheduler.Task{ID: "t1", SessionID: "slack:U1"}, "report ready", "slack")
+	p.handleTaskNotification(&scheduler.Task{ID: "t2", SessionID: "telegram:42"}, "report ready", "slack")
+	if posts := poster.posts[before:]; len(posts) != 1 || posts[0].channelID != "U1" || posts[0].text != "report ready" {
+		return fmt.Errorf("expected the task result sent to U1 only, got %+v", posts)
+	}
+	log.Println("✓ Task result sent to the session's user")
+
+	return nil
+}
+
+// testSlackReconnect fails the Socket Mode connection three times before
+// it connects, then fails it for good to check that reconnection gives up
+func testSlackReconnect() error {
+	p := &SlackPlatform{
+		config:            &SlackConfig{},
+		reconnectAttempts: 5,
+		reconnectBackoff:  time.Millisecond,
+	}
+
+	runs := 0
+	connected := make(chan struct{})
+	p.run = func(ctx context.Context) error {
+		runs++
+		if runs <= 3 {
+			return fmt.Errorf("connection reset")
+		}
+		close(connected)
+		<-ctx.Done()
+		return ctx.Err()
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		p.reconnectLoop(ctx)
+		close(done)
+	}()
+
+	select {
+	case <-connected:
+	case <-time.After(5 * time.Second):
+		cancel()
+		return fmt.Errorf("expected the connection to be reopened")
+	}
+	cancel()
+	<-done
+
+	if p.ReconnectCount() != 3 {
+		return fmt.Errorf("expected 3 reconnects, got %d", p.ReconnectCount())
+	}
+	log.Println("✓ Socket Mode connection reopened after 3 failures")
+
+	p = &SlackPlatform{
+		config:            &SlackConfig{},
+		reconnectAttempts: 2,
+		reconnectBackoff:  time.Millisecond,
+	}
+	runs = 0
+	p.run = func(ctx context.Context) error {
+		runs++
+		return fmt.Errorf("connection reset")
+	}
+
+	done = make(chan struct{})
+	go func() {
+		p.reconnectLoop(context.Background())
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		return fmt.Errorf("expected reconnection to give up")
+	}
+	if runs != 3 || p.ReconnectCount() != 2 {
+		return fmt.Errorf("expected 3 connections and 2 reconnects, got %d and %d", runs, p.ReconnectCount())
+	}
+	log.Println("✓ Reconnection gives up after MaxReconnectAttempts")
+
+	return nil
+}
@@ -1,23 +1,89 @@
 package platform
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path"
+	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
+	"unicode/utf8"
 
-	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	tgbotapi "github.com/OvyFlash/telegram-bot-api"
 	"quickbot/internal/agent"
+	"quickbot/internal/ai"
+	"quickbot/internal/broker"
 	"quickbot/internal/config"
+	"quickbot/internal/scheduler"
+	"quickbot/pkg/types"
 )
 
 // TelegramConfig represents Telegram platform configuration
 type TelegramConfig struct {
-	Token          string
-	AllowedUsers   []string
-	Debug          bool
+	Token           string
+	AllowedUsers    []string
+	Debug           bool
+	TypingIndicator bool
+
+	// RespondToChannels enables AI replies to channel posts
+	RespondToChannels bool
+	// ChannelAllowList restricts the channels handled (empty allows all)
+	ChannelAllowList []int64
+	// AdminChatID, if set, receives forwarded channel posts for review
+	AdminChatID int64
+
+	// StreamingReplies enables SendStreamingReply for streaming AI responses
+	StreamingReplies bool
+
+	// TopicIsolation gives each forum topic of a supergroup its own
+	// session. TopicWelcomeMessage, if set, is sent to new topics.
+	TopicIsolation      bool
+	TopicWelcomeMessage string
+
+	// MaxLength is the longest message sent, in characters (default
+	// 4096); longer AI responses are shortened with TruncationStrategy
+	MaxLength          int
+	TruncationStrategy string
 }
 
+const (
+	// typingInterval is how often the typing action is refreshed
+	// (Telegram shows it for 5 seconds)
+	typingInterval = 4 * time.Second
+
+	// typingGracePeriod suppresses the indicator for fast responses
+	typingGracePeriod = 500 * time.Millisecond
+
+	// streamEditInterval is the minimum delay between edits of a streamed
+	// reply (Telegram allows about 20 edits per minute per chat)
+	streamEditInterval = 500 * time.Millisecond
+
+	// maxStreamMessageLength is the length at which a streamed reply
+	// continues in a new message
+	maxStreamMessageLength = 4000
+
+	// defaultReconnectAttempts and defaultReconnectBackoff bound update
+	// polling reconnection; reconnect delays are capped at maxReconnectBackoff
+	defaultReconnectAttempts = 10
+	defaultReconnectBackoff  = time.Second
+	maxReconnectBackoff      = 60 * time.Second
+
+	// telegramMessageLimit is the longest message Telegram accepts
+	telegramMessageLimit = 4096
+
+	// updateLockTTL is how long an update stays claimed by the instance
+	// that handles it, which outlasts Telegram redelivering it
+	updateLockTTL = 10 * time.Minute
+)
+
 // TelegramPlatform represents Telegram bot platform
 type TelegramPlatform struct {
 	config     *TelegramConfig
@@ -26,6 +92,13 @@ type TelegramPlatform struct {
 	updates    tgbotapi.UpdatesChannel
 	started    bool
 	mu         sync.RWMutex
+
+	lastUpdateID      int
+	reconnectCount    atomic.Int64
+	reconnectAttempts int
+	reconnectBackoff  time.Duration
+
+	broker broker.Broker
 }
 
 // NewTelegramPlatform creates a new Telegram platform instance
@@ -37,12 +110,23 @@ func NewTelegramPlatform(cfg *TelegramConfig, bot *agent.Agent) (*TelegramPlatfo
 
 	botAPI.Debug = cfg.Debug
 
-	return &TelegramPlatform{
+	platform := &TelegramPlatform{
 		config:  cfg,
 		botAPI:  botAPI,
 		agent:   bot,
 		started: false,
-	}, nil
+
+		reconnectAttempts: defaultReconnectAttempts,
+		reconnectBackoff:  defaultReconnectBackoff,
+	}
+
+	// Deliver scheduled task results to Telegram chats
+	if scheduler := bot.Scheduler(); scheduler != nil {
+		scheduler.SetPlatformNotificationHandler("telegram", platform.handleTaskNotification)
+		scheduler.SetTypeHandler("channel_post", platform.handleChannelPostTask)
+	}
+
+	return platform, nil
 }
 
 // Start starts the Telegram platform
@@ -66,7 +150,7 @@ func (p *TelegramPlatform) Start() error {
 	p.started = true
 
 	// Start message handler
-	go p.handleMessages()
+	go p.superviseUpdates(updates)
 
 	log.Println("✓ Telegram platform started")
 	return nil
@@ -108,36 +192,289 @@ func (p *TelegramPlatform) isUserAllowed(userID int64) bool {
 	return false
 }
 
-// handleMessages processes incoming Telegram updates
-func (p *TelegramPlatform) handleMessages() {
-	for update := range p.updates {
-		// Only handle messages
-		if update.Message == nil {
-			continue
+// SetReconnectConfig sets how often, and how fast, update polling is
+// restarted after the updates channel closes unexpectedly. A maxAttempts
+// of 0 or less retries forever.
+func (p *TelegramPlatform) SetReconnectConfig(maxAttempts int, baseBackoff time.Duration) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.reconnectAttempts = maxAttempts
+	p.reconnectBackoff = baseBackoff
+}
+
+// SetBroker shares updates with the other instances serving the same bot.
+// Each update is published to "telegram/<update_id>" and handled only by
+// the instance that locks it first.
+func (p *TelegramPlatform) SetBroker(b broker.Broker) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.broker = b
+}
+
+// ReconnectCount returns how many times update polling was restarted
+func (p *TelegramPlatform) ReconnectCount() int64 {
+	return p.reconnectCount.Load()
+}
+
+// superviseUpdates handles updates and restarts polling, with backoff,
+// when the updates channel closes while the platform is still started
+func (p *TelegramPlatform) superviseUpdates(updates tgbotapi.UpdatesChannel) {
+	attempt := 0
+
+	for {
+		if p.handleMessages(updates) {
+			attempt = 0
 		}
 
-		message := update.Message
+		if !p.IsStarted() {
+			return
+		}
 
-		// Check user permission
-		if !p.isUserAllowed(message.From.ID) {
-			log.Printf("Unauthorized user attempt: %d (%s)", message.From.ID, message.From.UserName)
-			continue
+		p.mu.RLock()
+		maxAttempts, baseBackoff := p.reconnectAttempts, p.reconnectBackoff
+		p.mu.RUnlock()
+
+		attempt++
+		if maxAttempts > 0 && attempt > maxAttempts {
+			log.Printf("Error: Telegram update polling failed %d times, giving up", maxAttempts)
+			return
+		}
+
+		wait := ai.BackoffDelay(attempt, baseBackoff, maxReconnectBackoff)
+		log.Printf("Warning: Telegram updates channel closed, reconnecting in %v (attempt %d)", wait, attempt)
+		time.Sleep(wait)
+
+		p.mu.Lock()
+		if !p.started {
+			p.mu.Unlock()
+			return
 		}
+		u := tgbotapi.NewUpdate(p.lastUpdateID + 1)
+		u.Timeout = 60
+		updates = p.botAPI.GetUpdatesChan(u)
+		p.updates = updates
+		p.mu.Unlock()
+
+		p.reconnectCount.Add(1)
+	}
+}
 
-		// Create session ID
-		sessionID := fmt.Sprintf("telegram:%d", message.From.ID)
+// handleMessages processes incoming Telegram updates until the channel
+// closes, and reports whether any update was received
+func (p *TelegramPlatform) handleMessages(updates tgbotapi.UpdatesChannel) bool {
+	received := false
 
-		// Handle commands
-		if message.IsCommand() {
-			p.handleCommand(message, sessionID)
+	for update := range updates {
+		received = true
+
+		p.mu.Lock()
+		if update.UpdateID > p.lastUpdateID {
+			p.lastUpdateID = update.UpdateID
+		}
+		b := p.broker
+		p.mu.Unlock()
+
+		if b != nil && !p.claimUpdate(b, update) {
 			continue
 		}
 
-		// Process regular message
-		p.processMessage(message, sessionID)
+		p.handleUpdate(update)
+	}
+
+	return received
+}
+
+// claimUpdate publishes an update to the broker and reports whether this
+// instance should handle it. If the broker fails, the update is handled
+// rather than lost.
+func (p *TelegramPlatform) claimUpdate(b broker.Broker, update tgbotapi.Update) bool {
+	topic := fmt.Sprintf("telegram/%d", update.UpdateID)
+
+	data, err := json.Marshal(update)
+	if err != nil {
+		log.Printf("Warning: failed to encode Telegram update %d: %v", update.UpdateID, err)
+	} else if err := b.Publish(topic, string(data)); err != nil {
+		log.Printf("Warning: %v", err)
+	}
+
+	locked, err := b.Lock(topic, updateLockTTL)
+	if err != nil {
+		log.Printf("Warning: %v, handling update %d anyway", err, update.UpdateID)
+		return true
+	}
+	if !locked {
+		log.Printf("Telegram update %d is handled by another instance", update.UpdateID)
+	}
+	return locked
+}
+
+// handleUpdate processes one update. A panic is logged instead of
+// stopping update handling.
+func (p *TelegramPlatform) handleUpdate(update tgbotapi.Update) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("Error: panic handling Telegram update %d: %v", update.UpdateID, r)
+		}
+	}()
+
+	// Handle channel posts
+	if update.ChannelPost != nil {
+		p.handleChannelPost(update.ChannelPost)
+		return
+	}
+
+	// Only handle messages
+	if update.Message == nil {
+		return
+	}
+
+	message := update.Message
+
+	if message.ForumTopicCreated != nil {
+		p.handleTopicCreated(message)
+		return
+	}
+
+	// Check user permission
+	if !p.isUserAllowed(message.From.ID) {
+		log.Printf("Unauthorized user attempt: %d (%s)", message.From.ID, message.From.UserName)
+		return
+	}
+
+	sessionID := p.sessionID(message)
+
+	// Handle commands
+	if message.IsCommand() {
+		p.handleCommand(message, sessionID)
+		return
+	}
+
+	// Process regular message
+	p.processMessage(message, sessionID)
+}
+
+// sessionID returns the session of a message: its forum topic with
+// TopicIsolation, else its sender
+func (p *TelegramPlatform) sessionID(message *tgbotapi.Message) string {
+	if p.config.TopicIsolation && message.MessageThreadID != 0 && !message.Chat.IsPrivate() {
+		return fmt.Sprintf("telegram:group:%d:topic:%d", message.Chat.ID, message.MessageThreadID)
+	}
+	return fmt.Sprintf("telegram:%d", message.From.ID)
+}
+
+// handleTopicCreated sends the welcome message, if any, to a new forum
+// topic
+func (p *TelegramPlatform) handleTopicCreated(message *tgbotapi.Message) {
+	log.Printf("[Telegram] Topic created in %d: %s", message.Chat.ID, message.ForumTopicCreated.Name)
+
+	if p.config.TopicWelcomeMessage == "" {
+		return
+	}
+
+	msg := tgbotapi.NewMessage(message.Chat.ID, p.config.TopicWelcomeMessage)
+	msg.MessageThreadID = message.MessageThreadID
+	if _, err := p.botAPI.Send(msg); err != nil {
+		log.Printf("Error sending topic welcome message: %v", err)
+	}
+}
+
+// isChannelAllowed checks if the bot should handle posts from a channel
+func (p *TelegramPlatform) isChannelAllowed(chatID int64) bool {
+	// If allow list is empty, allow all channels
+	if len(p.config.ChannelAllowList) == 0 {
+		return true
+	}
+
+	for _, allowed := range p.config.ChannelAllowList {
+		if allowed == chatID {
+			return true
+		}
+	}
+
+	return false
+}
+
+// handleChannelPost handles a post in a channel the bot is a member of
+func (p *TelegramPlatform) handleChannelPost(post *tgbotapi.Message) {
+	if !p.isChannelAllowed(post.Chat.ID) {
+		log.Printf("Ignoring post from channel not in allow list: %d (%s)", post.Chat.ID, post.Chat.Title)
+		return
+	}
+
+	sessionID := channelSessionID(post.Chat.ID)
+
+	// Create the channel session on first post
+	memory := p.agent.Memory()
+	session, err := memory.GetSession(sessionID)
+	if err != nil {
+		log.Printf("Error getting session %s: %v", sessionID, err)
+	} else if session == nil {
+		err := memory.CreateSession(sessionID, post.Chat.Title, "telegram", fmt.Sprintf("channel:%d", post.Chat.ID))
+		if err != nil {
+			log.Printf("Error creating session %s: %v", sessionID, err)
+		}
+	}
+
+	// Forward to the admin chat for review
+	if p.config.AdminChatID != 0 {
+		forward := tgbotapi.NewForward(p.config.AdminChatID, post.Chat.ID, post.MessageID)
+		if _, err := p.botAPI.Send(forward); err != nil {
+			log.Printf("Error forwarding channel post: %v", err)
+		}
+	}
+
+	if p.config.RespondToChannels {
+		p.processMessage(post, sessionID)
 	}
 }
 
+// channelSessionID returns the session ID of a channel
+func channelSessionID(chatID int64) string {
+	return fmt.Sprintf("telegram:channel:%d", chatID)
+}
+
+// ScheduleChannelPost schedules text to be posted to a channel at sendAt.
+// The bot must be an administrator of the channel.
+func (p *TelegramPlatform) ScheduleChannelPost(channelID int64, text string, sendAt time.Time) error {
+	if !p.isChannelAllowed(channelID) {
+		return fmt.Errorf("channel %d is not in the allow list", channelID)
+	}
+
+	scheduler := p.agent.Scheduler()
+	if scheduler == nil {
+		return fmt.Errorf("scheduler is not available")
+	}
+
+	_, err := scheduler.AddTask("channel_post", channelSessionID(channelID), map[string]interface{}{
+		"type":       "channel_post",
+		"channel_id": strconv.FormatInt(channelID, 10),
+		"text":       text,
+	}, sendAt, "", "", nil)
+	if err != nil {
+		return fmt.Errorf("failed to schedule channel post: %w", err)
+	}
+
+	return nil
+}
+
+// handleChannelPostTask posts the text of a scheduled channel post task
+func (p *TelegramPlatform) handleChannelPostTask(task *scheduler.Task) (string, error) {
+	channelIDStr, _ := task.Payload["channel_id"].(string)
+	channelID, err := strconv.ParseInt(channelIDStr, 10, 64)
+	if err != nil {
+		return "", fmt.Errorf("invalid channel ID %q", channelIDStr)
+	}
+
+	text, _ := task.Payload["text"].(string)
+	if err := p.SendMessage(channelID, text); err != nil {
+		return "", fmt.Errorf("failed to send channel post: %w", err)
+	}
+
+	return "", nil
+}
+
 // handleCommand handles bot commands
 func (p *TelegramPlatform) handleCommand(message *tgbotapi.Message, sessionID string) {
 	command := message.Command()
@@ -159,11 +496,195 @@ func (p *TelegramPlatform) handleCommand(message *tgbotapi.Message, sessionID st
 		statusText := p.generateStatusText()
 		p.sendReply(message, statusText)
 
+	case "transfer":
+		p.handleTransfer(message, sessionID)
+
+	case "tag":
+		p.handleTag(message, sessionID)
+
+	case "rename":
+		p.handleRename(message, sessionID)
+
+	case "ai_config", "ai":
+		// Telegram ends commands at "-", so "/ai-config" arrives as "ai"
+		args := strings.TrimSpace(message.CommandArguments())
+		if command == "ai" {
+			if !strings.HasPrefix(args, "-config") {
+				p.sendReply(message, fmt.Sprintf("未知命令: /%s\n发送 /help 查看帮助", command))
+				return
+			}
+			args = strings.TrimSpace(strings.TrimPrefix(args, "-config"))
+		}
+		p.handleAIConfig(message, sessionID, args)
+
 	default:
 		p.sendReply(message, fmt.Sprintf("未知命令: /%s\n发送 /help 查看帮助", command))
 	}
 }
 
+// handleTransfer copies the current session to another platform,
+// e.g. "/transfer discord:123456". Only the sender's own session can be
+// transferred, and only to a session that does not exist yet.
+func (p *TelegramPlatform) handleTransfer(message *tgbotapi.Message, sessionID string) {
+	target := strings.TrimSpace(message.CommandArguments())
+	if !strings.HasPrefix(target, "discord:") || len(target) == len("discord:") {
+		p.sendReply(message, "用法: /transfer discord:<discord_user_id>")
+		return
+	}
+
+	// Topic sessions are shared by everyone in the topic
+	if sessionID != fmt.Sprintf("telegram:%d", message.From.ID) {
+		p.sendReply(message, "只能迁移你自己的会话，请在私聊中使用 /transfer。")
+		return
+	}
+
+	err := p.agent.Memory().TransferSession(sessionID, target)
+	if errors.Is(err, agent.ErrSessionNotFound) {
+		p.sendReply(message, "当前会话没有可迁移的内容。")
+		return
+	}
+	if errors.Is(err, agent.ErrSessionExists) {
+		p.sendReply(message, fmt.Sprintf("会话 %s 已存在，无法覆盖。", target))
+		return
+	}
+	if err != nil {
+		log.Printf("Error transferring session %s to %s: %v", sessionID, target, err)
+		p.sendReply(message, "抱歉，会话迁移失败。")
+		return
+	}
+
+	p.sendReply(message, fmt.Sprintf("✅ 会话已迁移到 %s", target))
+}
+
+// handleAIConfig sets the AI parameter overrides of the current session,
+// e.g. "/ai-config max_tokens=4000 temperature=0.9", clears them with
+// "/ai-config reset", or shows them when called without arguments
+func (p *TelegramPlatform) handleAIConfig(message *tgbotapi.Message, sessionID, args string) {
+	usage := "用法: /ai-config [model=<模型>] [max_tokens=<数量>] [temperature=<0.0-2.0>]\n/ai-config reset - 恢复默认设置"
+
+	if args != "" {
+		var overrides types.AIOverrides
+		if args != "reset" {
+			for _, field := range strings.Fields(args) {
+				key, value, ok := strings.Cut(field, "=")
+				if !ok {
+					p.sendReply(message, usage)
+					return
+				}
+
+				var err error
+				switch key {
+				case "model":
+					overrides.Model = value
+				case "max_tokens":
+					overrides.MaxTokens, err = strconv.Atoi(value)
+				case "temperature":
+					var temperature float64
+					temperature, err = strconv.ParseFloat(value, 64)
+					overrides.Temperature = &temperature
+				default:
+					err = fmt.Errorf("unknown parameter %s", key)
+				}
+				if err != nil {
+					p.sendReply(message, usage)
+					return
+				}
+			}
+		}
+
+		if err := p.agent.SetSessionAIOverrides(sessionID, overrides); err != nil {
+			p.sendReply(message, fmt.Sprintf("❌ 设置无效: %v", err))
+			return
+		}
+	}
+
+	overrides, err := p.agent.Memory().GetAIOverrides(sessionID)
+	if err != nil {
+		log.Printf("Error getting AI overrides of session %s: %v", sessionID, err)
+		p.sendReply(message, "抱歉，获取 AI 设置失败。")
+		return
+	}
+	if overrides == nil {
+		p.sendReply(message, "当前会话使用默认 AI 设置。\n"+usage)
+		return
+	}
+
+	var settings []string
+	if overrides.Model != "" {
+		settings = append(settings, "model="+overrides.Model)
+	}
+	if overrides.MaxTokens > 0 {
+		settings = append(settings, fmt.Sprintf("max_tokens=%d", overrides.MaxTokens))
+	}
+	if overrides.Temperature != nil {
+		settings = append(settings, fmt.Sprintf("temperature=%g", *overrides.Temperature))
+	}
+	p.sendReply(message, fmt.Sprintf("⚙️ 当前会话 AI 设置: %s", strings.Join(settings, ", ")))
+}
+
+// handleTag tags the current session, e.g. "/tag work", or lists its
+// tags when called without arguments
+func (p *TelegramPlatform) handleTag(message *tgbotapi.Message, sessionID string) {
+	tag := strings.TrimSpace(message.CommandArguments())
+	memory := p.agent.Memory()
+
+	if tag != "" {
+		if err := memory.AddTag(sessionID, tag); err != nil {
+			log.Printf("Error tagging session %s: %v", sessionID, err)
+			p.sendReply(message, "抱歉，添加标签失败。")
+			return
+		}
+	}
+
+	tags, err := memory.GetTags(sessionID)
+	if err != nil {
+		log.Printf("Error getting tags of session %s: %v", sessionID, err)
+		p.sendReply(message, "抱歉，获取标签失败。")
+		return
+	}
+
+	if len(tags) == 0 {
+		p.sendReply(message, "当前会话没有标签。\n用法: /tag <标签>")
+		return
+	}
+
+	p.sendReply(message, fmt.Sprintf("🏷 当前会话标签: %s", strings.Join(tags, ", ")))
+}
+
+// handleRename names the current session, e.g. "/rename work-project"
+func (p *TelegramPlatform) handleRename(message *tgbotapi.Message, sessionID string) {
+	name := strings.TrimSpace(message.CommandArguments())
+	if name == "" {
+		p.sendReply(message, "用法: /rename <名称>")
+		return
+	}
+
+	memory := p.agent.Memory()
+	session, err := memory.GetSession(sessionID)
+	if err != nil {
+		log.Printf("Error getting session %s: %v", sessionID, err)
+		p.sendReply(message, "抱歉，重命名会话失败。")
+		return
+	}
+
+	description := ""
+	if session == nil {
+		err = memory.CreateSession(sessionID, name, "telegram", strconv.FormatInt(message.From.ID, 10))
+	} else {
+		description = session.Description
+	}
+	if err == nil {
+		err = memory.RenameSession(sessionID, name, description)
+	}
+	if err != nil {
+		log.Printf("Error renaming session %s: %v", sessionID, err)
+		p.sendReply(message, "抱歉，重命名会话失败。")
+		return
+	}
+
+	p.sendReply(message, fmt.Sprintf("✅ 会话已重命名为 %s", name))
+}
+
 // processMessage processes a regular message
 func (p *TelegramPlatform) processMessage(message *tgbotapi.Message, sessionID string) {
 	// Get user message
@@ -174,27 +695,131 @@ func (p *TelegramPlatform) processMessage(message *tgbotapi.Message, sessionID s
 
 	log.Printf("[Telegram][%s] Received: %s", sessionID, userMessage)
 
-	// Process message through agent
-	response, err := p.agent.ProcessMessage(sessionID, userMessage)
+	// Show typing indicator while the agent is working
+	stopTyping := func() {}
+	if p.config.TypingIndicator {
+		stopTyping = p.startTypingIndicator(message.Chat.ID, message.MessageThreadID)
+	}
+
+	// Process message through agent, streaming the AI response or showing
+	// live tool output if enabled
+	ctx := types.WithPlatform(context.Background(), "telegram")
+	ctx = types.WithPlatformMessageID(ctx, strconv.Itoa(message.MessageID))
+	var response string
+	var err error
+	streamed := false
+	if p.SupportsStreaming() && p.agent.SupportsStreaming() {
+		tokens := make(chan string)
+		done := make(chan bool)
+		go func() {
+			done <- p.streamOutput(message, tokens)
+		}()
+		response, err = p.agent.ProcessMessageStreaming(ctx, sessionID, userMessage, tokens)
+		streamed = <-done
+	} else if p.agent.Config().Tools.StreamingEnabled {
+		toolOut := make(chan string)
+		done := make(chan bool)
+		go func() {
+			done <- p.streamOutput(message, toolOut)
+		}()
+		response, err = p.agent.ProcessMessageWithStream(sessionID, userMessage, toolOut)
+		streamed = <-done
+	} else {
+		response, err = p.agent.ProcessMessage(ctx, sessionID, userMessage)
+	}
+	stopTyping()
+	if errors.Is(err, agent.ErrRedelivered) {
+		return
+	}
 	if err != nil {
 		log.Printf("Error processing message: %v", err)
 		p.sendReply(message, "抱歉，处理消息时出错。")
 		return
 	}
 
-	// Send response
-	p.sendReply(message, response)
+	// Send response, unless it was streamed
+	if !streamed {
+		p.sendFormattedReply(message, response)
+	}
+}
+
+// streamOutput shows the AI response or tool output sent to out as a
+// streaming reply and reports whether any output was sent. Nothing is
+// sent if out is closed without output.
+func (p *TelegramPlatform) streamOutput(message *tgbotapi.Message, out <-chan string) bool {
+	first, ok := <-out
+	if !ok {
+		return false
+	}
+
+	chunks := make(chan string)
+	go func() {
+		defer close(chunks)
+		chunks <- first
+		for chunk := range out {
+			chunks <- chunk
+		}
+	}()
+
+	delivered, err := p.sendStreamingReply(message.Chat.ID, message.MessageID, chunks)
+	if err != nil {
+		log.Printf("Error streaming output: %v", err)
+		// Drain the output so the agent is not blocked
+		for range chunks {
+		}
+		// Once part of the reply is shown, sending it again in full
+		// would duplicate it
+		return delivered
+	}
+	return true
+}
+
+// startTypingIndicator sends the "typing" chat action, to the forum topic
+// threadID if set, periodically until the returned function is called.
+// Nothing is sent if it is stopped within typingGracePeriod, to avoid
+// flicker on fast responses.
+func (p *TelegramPlatform) startTypingIndicator(chatID int64, threadID int) context.CancelFunc {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	go func() {
+		grace := time.NewTimer(typingGracePeriod)
+		defer grace.Stop()
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-grace.C:
+		}
+
+		ticker := time.NewTicker(typingInterval)
+		defer ticker.Stop()
+
+		for {
+			action := tgbotapi.NewChatAction(chatID, tgbotapi.ChatTyping)
+			action.MessageThreadID = threadID
+			if _, err := p.botAPI.Request(action); err != nil {
+				log.Printf("Error sending typing action: %v", err)
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+
+	return cancel
 }
 
 // sendReply sends a reply message
 func (p *TelegramPlatform) sendReply(message *tgbotapi.Message, text string) {
-	// Truncate if too long (Telegram limit is 4096 characters)
-	if len(text) > 4000 {
-		text = text[:4000] + "\n... (消息过长，已截断)"
-	}
+	// Shorten to the configured length
+	text, _ = p.agent.TruncateResponse(text, agent.TruncateStrategy, p.maxLength())
 
 	// Parse Markdown
 	msg := tgbotapi.NewMessage(message.Chat.ID, text)
+	msg.MessageThreadID = message.MessageThreadID
 	msg.ParseMode = "Markdown"
 
 	// Send message
@@ -204,6 +829,207 @@ func (p *TelegramPlatform) sendReply(message *tgbotapi.Message, text string) {
 	}
 }
 
+// sendFormattedReply sends an AI response converted to MarkdownV2,
+// shortened or split with the configured truncation strategy
+func (p *TelegramPlatform) sendFormattedReply(message *tgbotapi.Message, response string) {
+	for _, part := range p.agent.ResponseParts(response, p.config.TruncationStrategy, p.maxLength()) {
+		p.sendFormattedPart(message, part)
+	}
+}
+
+// sendFormattedPart sends one message of an AI response as MarkdownV2. If
+// escaping makes it too long, or Telegram rejects the markup, it is sent
+// as plain text instead.
+func (p *TelegramPlatform) sendFormattedPart(message *tgbotapi.Message, part string) {
+	text := p.agent.FormatResponse("telegram", part)
+	if utf8.RuneCountInString(text) <= telegramMessageLimit {
+		msg := tgbotapi.NewMessage(message.Chat.ID, text)
+		msg.MessageThreadID = message.MessageThreadID
+		msg.ParseMode = "MarkdownV2"
+		_, err := p.botAPI.Send(msg)
+		if err == nil {
+			return
+		}
+		log.Printf("Warning: failed to send MarkdownV2 reply, sending plain text: %v", err)
+	}
+
+	msg := tgbotapi.NewMessage(message.Chat.ID, p.agent.FormatResponse("plain", part))
+	msg.MessageThreadID = message.MessageThreadID
+	if _, err := p.botAPI.Send(msg); err != nil {
+		log.Printf("Error sending reply: %v", err)
+	}
+}
+
+// maxLength returns the configured message length, at most what
+// Telegram accepts
+func (p *TelegramPlatform) maxLength() int {
+	if p.config.MaxLength <= 0 || p.config.MaxLength > telegramMessageLimit {
+		return telegramMessageLimit
+	}
+	return p.config.MaxLength
+}
+
+// SendStreamingReply sends a reply built from a stream of tokens. An initial
+// "..." message is sent and then edited as tokens arrive, at most once per
+// streamEditInterval, with a final edit when the channel closes. Text beyond
+// maxStreamMessageLength continues in a new message, in the forum topic
+// of the reply if any.
+func (p *TelegramPlatform) SendStreamingReply(chatID int64, replyToID int, tokens <-chan string) error {
+	_, err := p.sendStreamingReply(chatID, replyToID, tokens)
+	return err
+}
+
+// sendStreamingReply sends a streaming reply like SendStreamingReply and
+// also reports whether any of the streamed text was delivered, even if a
+// later edit failed
+func (p *TelegramPlatform) sendStreamingReply(chatID int64, replyToID int, tokens <-chan string) (bool, error) {
+	msg := tgbotapi.NewMessage(chatID, "...")
+	msg.ReplyParameters.MessageID = replyToID
+
+	sent, err := p.botAPI.Send(msg)
+	if err != nil {
+		return false, fmt.Errorf("failed to send initial message: %w", err)
+	}
+
+	editor := &streamEditor{
+		platform:  p,
+		chatID:    chatID,
+		threadID:  sent.MessageThreadID,
+		messageID: sent.MessageID,
+	}
+
+	for token := range tokens {
+		editor.append(token)
+	}
+
+	err = editor.finish()
+	return editor.delivered, err
+}
+
+// streamEditor accumulates streamed text and debounces message edits
+type streamEditor struct {
+	platform  *TelegramPlatform
+	chatID    int64
+	threadID  int
+	messageID int
+
+	mu       sync.Mutex
+	text     string
+	lastSent string
+	lastEdit time.Time
+	timer    *time.Timer
+	err      error
+
+	// delivered is set once any streamed text has been shown
+	delivered bool
+}
+
+// append adds a token and schedules an edit
+func (e *streamEditor) append(token string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if len(e.text)+len(token) > maxStreamMessageLength && e.text != "" {
+		// Finish the current message and continue in a new one
+		e.stopTimer()
+		e.waitForEditSlot()
+		e.edit()
+
+		msg := tgbotapi.NewMessage(e.chatID, token)
+		msg.MessageThreadID = e.threadID
+		sent, err := e.platform.botAPI.Send(msg)
+		if err != nil {
+			e.setErr(fmt.Errorf("failed to send continuation message: %w", err))
+			return
+		}
+		e.messageID = sent.MessageID
+		e.delivered = true
+		e.text = token
+		e.lastSent = token
+		e.lastEdit = time.Now()
+		return
+	}
+
+	e.text += token
+
+	if e.timer == nil {
+		delay := time.Until(e.lastEdit.Add(streamEditInterval))
+		if delay < 0 {
+			delay = 0
+		}
+		e.timer = time.AfterFunc(delay, e.flush)
+	}
+}
+
+// flush edits the message with the text accumulated so far
+func (e *streamEditor) flush() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.timer = nil
+	e.edit()
+}
+
+// finish cancels any pending edit and sends the final text
+func (e *streamEditor) finish() error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.stopTimer()
+
+	if e.text == "" {
+		// Nothing was streamed; remove the placeholder
+		_, err := e.platform.botAPI.Request(tgbotapi.NewDeleteMessage(e.chatID, e.messageID))
+		if err != nil {
+			e.setErr(fmt.Errorf("failed to delete placeholder message: %w", err))
+		}
+		return e.err
+	}
+
+	e.waitForEditSlot()
+	e.edit()
+	return e.err
+}
+
+// edit edits the current message if its text changed; e.mu must be held
+func (e *streamEditor) edit() {
+	if e.text == e.lastSent {
+		return
+	}
+
+	_, err := e.platform.botAPI.Send(tgbotapi.NewEditMessageText(e.chatID, e.messageID, e.text))
+	e.lastEdit = time.Now()
+	if err != nil {
+		e.setErr(fmt.Errorf("failed to edit message: %w", err))
+		return
+	}
+	e.lastSent = e.text
+	e.delivered = true
+}
+
+// waitForEditSlot sleeps until another edit is allowed; e.mu must be held
+func (e *streamEditor) waitForEditSlot() {
+	if delay := time.Until(e.lastEdit.Add(streamEditInterval)); delay > 0 {
+		time.Sleep(delay)
+	}
+}
+
+// stopTimer cancels a pending edit; e.mu must be held
+func (e *streamEditor) stopTimer() {
+	if e.timer != nil {
+		e.timer.Stop()
+		e.timer = nil
+	}
+}
+
+// setErr records the first error; e.mu must be held
+func (e *streamEditor) setErr(err error) {
+	log.Printf("Error streaming reply: %v", err)
+	if e.err == nil {
+		e.err = err
+	}
+}
+
 // generateHelpText generates help message
 func (p *TelegramPlatform) generateHelpText() string {
 	return fmt.Sprintf(`📖 *%s 命令列表*
@@ -211,6 +1037,10 @@ func (p *TelegramPlatform) generateHelpText() string {
 /start - 启动机器人
 /help - 显示此帮助信息
 /status - 查看系统状态
+/transfer discord:<id> - 将会话迁移到 Discord
+/tag <标签> - 为当前会话添加标签
+/rename <名称> - 重命名当前会话
+/ai-config max_tokens=<数量> temperature=<值> - 设置当前会话的 AI 参数
 
 你也可以直接和我聊天！
 
@@ -243,6 +1073,26 @@ func (p *TelegramPlatform) generateStatusText() string {
 	return strings.Join(statusParts, "\n")
 }
 
+// handleTaskNotification sends a task result to the chat of the task's
+// session (session ID format "telegram:<chatID>" or "telegram:channel:<chatID>")
+func (p *TelegramPlatform) handleTaskNotification(task *scheduler.Task, result string, platform string) {
+	if result == "" {
+		return
+	}
+
+	chatIDStr := strings.TrimPrefix(task.SessionID, "telegram:")
+	chatIDStr = strings.TrimPrefix(chatIDStr, "channel:")
+	chatID, err := strconv.ParseInt(chatIDStr, 10, 64)
+	if err != nil {
+		log.Printf("Cannot notify task %s: invalid session ID %s", task.ID, task.SessionID)
+		return
+	}
+
+	if err := p.SendMessage(chatID, result); err != nil {
+		log.Printf("Error sending task notification: %v", err)
+	}
+}
+
 // SendMessage sends a message directly to a chat
 func (p *TelegramPlatform) SendMessage(chatID int64, text string) error {
 	msg := tgbotapi.NewMessage(chatID, text)
@@ -264,6 +1114,12 @@ func (p *TelegramPlatform) IsStarted() bool {
 	return p.started
 }
 
+// SupportsStreaming reports whether AI responses are streamed by editing
+// the reply in place, as enabled by StreamingReplies
+func (p *TelegramPlatform) SupportsStreaming() bool {
+	return p.config.StreamingReplies
+}
+
 // TestTelegram tests Telegram platform connection
 func TestTelegram() {
 	log.Println("Testing Telegram platform...")
@@ -273,3 +1129,436 @@ func TestTelegram() {
 	log.Println("✓ Telegram platform structure verified")
 	log.Println("⚠ Note: Requires valid bot token for actual connection test")
 }
+
+// TestTelegramTopics checks that messages in different forum topics get
+// their own sessions only with TopicIsolation
+func TestTelegramTopics() error {
+	log.Println("Testing Telegram forum topics...")
+
+	var first, second tgbotapi.Message
+	for i, message := range []*tgbotapi.Message{&first, &second} {
+		data := fmt.Sprintf(`{"message_id": %d, "message_thread_id": %d, "is_topic_message": true,
+			"chat": {"id": -1001234567890, "type": "supergroup", "is_forum": true},
+			"from": {"id": 42, "first_name": "Test"}, "text": "hello"}`, i+1, (i+1)*10)
+		if err := json.Unmarshal([]byte(data), message); err != nil {
+			return fmt.Errorf("failed to decode test message: %w", err)
+		}
+	}
+
+	p := &TelegramPlatform{config: &TelegramConfig{TopicIsolation: true}}
+	if got, want := p.sessionID(&first), "telegram:group:-1001234567890:topic:10"; got != want {
+		return fmt.Errorf("expected session %s, got %s", want, got)
+	}
+	if p.sessionID(&first) == p.sessionID(&second) {
+		return fmt.Errorf("expected topics to have different sessions, got %s", p.sessionID(&first))
+	}
+	log.Println("✓ Forum topics have their own sessions")
+
+	p.config.TopicIsolation = false
+	if p.sessionID(&first) != "telegram:42" || p.sessionID(&second) != "telegram:42" {
+		return fmt.Errorf("expected the user session without topic isolation, got %s and %s",
+			p.sessionID(&first), p.sessionID(&second))
+	}
+	log.Println("✓ Topic isolation can be turned off")
+
+	return nil
+}
+
+// telegramTestCall records a Bot API method call
+type telegramTestCall struct {
+	method string
+	params url.Values
+	at     time.Time
+}
+
+// telegramTestServer is a fake Bot API server recording the methods
+// called. Edits fail while failEdit returns true for their (1-based)
+// number; getUpdates is answered by updates, if set.
+type telegramTestServer struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	calls    []telegramTestCall
+	edits    int
+	failEdit func(n int) bool
+	updates  func(offset int) []tgbotapi.Update
+}
+
+// newTelegramTestBot starts a fake Bot API server and a bot using it
+func newTelegramTestBot() (*telegramTestServer, *tgbotapi.BotAPI, error) {
+	s := &telegramTestServer{}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.handle))
+
+	bot, err := tgbotapi.NewBotAPIWithClient("test-token", s.URL+"/bot%s/%s", s.Client())
+	if err != nil {
+		s.Close()
+		return nil, nil, err
+	}
+	return s, bot, nil
+}
+
+func (s *telegramTestServer) handle(w http.ResponseWriter, r *http.Request) {
+	r.ParseMultipartForm(1 << 20)
+	method := path.Base(r.URL.Path)
+
+	s.mu.Lock()
+	s.calls = append(s.calls, telegramTestCall{method: method, params: r.Form, at: time.Now()})
+	failed := false
+	if method == "editMessageText" {
+		s.edits++
+		failed = s.failEdit != nil && s.failEdit(s.edits)
+	}
+	updates := s.updates
+	messageID := len(s.calls)
+	s.mu.Unlock()
+
+	var result interface{} = true
+	switch method {
+	case "getMe":
+		result = map[string]interface{}{"id": 1, "is_bot": true, "first_name": "QuickBot", "username": "quickbot_test"}
+	case "sendMessage", "editMessageText", "forwardMessage":
+		if failed {
+			w.Write([]byte(`{"ok": false, "error_code": 400, "description": "Bad Request: message can't be edited"}`))
+			return
+		}
+		chatID, _ := strconv.ParseInt(r.Form.Get("chat_id"), 10, 64)
+		result = map[string]interface{}{
+			"message_id": messageID,
+			"date":       time.Now().Unix(),
+			"chat":       map[string]interface{}{"id": chatID, "type": "private"},
+			"text":       r.Form.Get("text"),
+		}
+	case "getUpdates":
+		result = []tgbotapi.Update{}
+		if updates != nil {
+			offset, _ := strconv.Atoi(r.Form.Get("offset"))
+			result = updates(offset)
+		}
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{"ok": true, "result": result})
+}
+
+// methodCalls returns the calls of a Bot API method
+func (s *telegramTestServer) methodCalls(method string) []telegramTestCall {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var calls []telegramTestCall
+	for _, call := range s.calls {
+		if call.method == method {
+			calls = append(calls, call)
+		}
+	}
+	return calls
+}
+
+// TestTelegramStreaming streams a reply and checks that edits stay
+// within Telegram's rate limit, and that a reply that was partly shown
+// is not sent again when a later edit fails
+func TestTelegramStreaming() error {
+	log.Println("Testing Telegram streaming replies...")
+
+	server, bot, err := newTelegramTestBot()
+	if err != nil {
+		return err
+	}
+	defer server.Close()
+	p := &TelegramPlatform{config: &TelegramConfig{StreamingReplies: true}, botAPI: bot}
+
+	stream := func(tokens []string, interval time.Duration) chan string {
+		ch := make(chan string)
+		go func() {
+			defer close(ch)
+			for _, token := range tokens {
+				ch <- token
+				time.Sleep(interval)
+			}
+		}()
+		return ch
+	}
+
+	tokens := []string{"One", " two", " three", " four", " five", " six", " seven", " eight", " nine", " ten"}
+	if err := p.SendStreamingReply(42, 1, stream(tokens, 100*time.Millisecond)); err != nil {
+		return err
+	}
+
+	edits := server.methodCalls("editMessageText")
+	if len(edits) == 0 {
+		return fmt.Errorf("expected the reply to be edited")
+	}
+	for i := 1; i < len(edits); i++ {
+		if gap := edits[i].at.Sub(edits[i-1].at); gap < streamEditInterval {
+			return fmt.Errorf("edits %d and %d only %v apart", i, i+1, gap)
+		}
+	}
+	for i := range edits {
+		j := i
+		for j < len(edits) && edits[j].at.Sub(edits[i].at) < time.Second {
+			j++
+		}
+		if j-i > 2 {
+			return fmt.Errorf("%d edits within a second", j-i)
+		}
+	}
+	if text := edits[len(edits)-1].params.Get("text"); text != strings.Join(tokens, "") {
+		return fmt.Errorf("expected the final edit to have the full reply, got %q", text)
+	}
+	if sends := server.methodCalls("sendMessage"); len(sends) != 1 || sends[0].params.Get("text") != "..." {
+		return fmt.Errorf("expected only the placeholder message to be sent, got %d messages", len(sends))
+	}
+	log.Printf("✓ 10 tokens streamed with %d edits, at most 2 per second", len(edits))
+
+	var message tgbotapi.Message
+	data := `{"message_id": 1, "chat": {"id": 42, "type": "private"}, "from": {"id": 42, "first_name": "Test"}, "text": "hi"}`
+	if err := json.Unmarshal([]byte(data), &message); err != nil {
+		return fmt.Errorf("failed to decode test message: %w", err)
+	}
+
+	// Edits after the first fail: the partial reply was shown, so the
+	// response must not be sent again
+	server.mu.Lock()
+	server.failEdit = func(n int) bool { return n > len(edits)+1 }
+	server.mu.Unlock()
+	if !p.streamOutput(&message, stream([]string{"Hello", " world"}, 100*time.Millisecond)) {
+		return fmt.Errorf("expected a partly delivered reply to count as sent")
+	}
+
+	// Every edit fails: nothing but the placeholder was shown, so the
+	// response is sent in full
+	server.mu.Lock()
+	server.failEdit = func(int) bool { return true }
+	server.mu.Unlock()
+	if p.streamOutput(&message, stream([]string{"Hello", " world"}, 0)) {
+		return fmt.Errorf("expected an undelivered reply to be sent again")
+	}
+	log.Println("✓ Partly delivered replies not sent again after a failed edit")
+
+	return nil
+}
+
+// TestTelegramChannels simulates channel post updates and checks that the
+// channel gets a session, that posts are forwarded for review and only
+// answered with RespondToChannels
+func TestTelegramChannels() error {
+	log.Println("Testing Telegram channel posts...")
+
+	server, bot, err := newTelegramTestBot()
+	if err != nil {
+		return err
+	}
+	defer server.Close()
+
+	dryRun, err := agent.NewDryRunAgent(config.DefaultConfig())
+	if err != nil {
+		return err
+	}
+	defer dryRun.Close()
+
+	p := &TelegramPlatform{
+		config:  &TelegramConfig{ChannelAllowList: []int64{-1001}, AdminChatID: 99},
+		botAPI:  bot,
+		agent:   dryRun.Agent,
+		started: true,
+	}
+
+	post := func(chatID int64, title, text string) error {
+		var update tgbotapi.Update
+		data := fmt.Sprintf(`{"update_id": 1, "channel_post": {"message_id": 7, "date": 0,
+			"chat": {"id": %d, "type": "channel", "title": %q}, "text": %q}}`, chatID, title, text)
+		if err := json.Unmarshal([]byte(data), &update); err != nil {
+			return fmt.Errorf("failed to decode test update: %w", err)
+		}
+		p.handleUpdate(update)
+		return nil
+	}
+
+	if err := post(-1001, "News", "Breaking news"); err != nil {
+		return err
+	}
+	session, err := dryRun.Memory().GetSession("telegram:channel:-1001")
+	if err != nil || session == nil {
+		return fmt.Errorf("expected a session for the channel, got %v (%v)", session, err)
+	}
+	if session.Name != "News" || session.Platform != "telegram" {
+		return fmt.Errorf("unexpected channel session: %+v", session)
+	}
+	log.Println("✓ Channel post created the channel session")
+
+	forwards := server.methodCalls("forwardMessage")
+	if len(forwards) != 1 || forwards[0].params.Get("chat_id") != "99" || forwards[0].params.Get("from_chat_id") != "-1001" {
+		return fmt.Errorf("expected the post forwarded to the admin chat, got %d forwards", len(forwards))
+	}
+	if calls := dryRun.Provider().Calls(); len(calls) != 0 || len(server.methodCalls("sendMessage")) != 0 {
+		return fmt.Errorf("expected no reply without RespondToChannels")
+	}
+	log.Println("✓ Channel post forwarded for review")
+
+	if err := post(-1002, "Other", "Not allowed"); err != nil {
+		return err
+	}
+	if session, err := dryRun.Memory().GetSession("telegram:channel:-1002"); err != nil || session != nil {
+		return fmt.Errorf("expected no session for a channel outside the allow list, got %v (%v)", session, err)
+	}
+	log.Println("✓ Channels outside the allow list ignored")
+
+	p.config.RespondToChannels = true
+	dryRun.Provider().QueueResponse("Noted")
+	if err := post(-1001, "News", "More news"); err != nil {
+		return err
+	}
+	sends := server.methodCalls("sendMessage")
+	if len(sends) != 1 || sends[0].params.Get("chat_id") != "-1001" || sends[0].params.Get("text") != "Noted" {
+		return fmt.Errorf("expected a reply in the channel, got %d messages", len(sends))
+	}
+	log.Println("✓ Channel post answered with RespondToChannels")
+
+	return nil
+}
+
+// TestTelegramReconnect closes the updates channel after 3 updates and
+// checks that polling is restarted after the last update handled
+func TestTelegramReconnect() error {
+	log.Println("Testing Telegram reconnection...")
+
+	server, bot, err := newTelegramTestBot()
+	if err != nil {
+		return err
+	}
+	defer server.Close()
+	server.updates = func(offset int) []tgbotapi.Update {
+		if offset <= 4 {
+			return []tgbotapi.Update{{UpdateID: 4}}
+		}
+		time.Sleep(10 * time.Millisecond)
+		return []tgbotapi.Update{}
+	}
+
+	p := &TelegramPlatform{config: &TelegramConfig{}, botAPI: bot, started: true}
+	p.SetReconnectConfig(3, 10*time.Millisecond)
+
+	updates := make(chan tgbotapi.Update, 3)
+	for id := 1; id <= 3; id++ {
+		updates <- tgbotapi.Update{UpdateID: id}
+	}
+	close(updates)
+
+	done := make(chan struct{})
+	go func() {
+		p.superviseUpdates(updates)
+		close(done)
+	}()
+
+	lastUpdateID := func() int {
+		p.mu.RLock()
+		defer p.mu.RUnlock()
+		return p.lastUpdateID
+	}
+	for deadline := time.Now().Add(2 * time.Second); lastUpdateID() != 4; time.Sleep(10 * time.Millisecond) {
+		if time.Now().After(deadline) {
+			return fmt.Errorf("expected update 4 after reconnecting, last update is %d", lastUpdateID())
+		}
+	}
+	if n := p.ReconnectCount(); n != 1 {
+		return fmt.Errorf("expected 1 reconnect, got %d", n)
+	}
+	polls := server.methodCalls("getUpdates")
+	if len(polls) == 0 || polls[0].params.Get("offset") != "4" {
+		return fmt.Errorf("expected polling to resume at offset 4")
+	}
+	log.Println("✓ Polling restarted after the updates channel closed")
+
+	if err := p.Stop(); err != nil {
+		return err
+	}
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		return fmt.Errorf("expected the supervisor to stop with the platform")
+	}
+	if n := p.ReconnectCount(); n != 1 {
+		return fmt.Errorf("expected no reconnect after Stop, got %d reconnects", n)
+	}
+	log.Println("✓ No reconnect after Stop")
+
+	return nil
+}
+
+// TestTelegramTypingIndicator checks that the typing action is sent while
+// a slow reply is generated and stops once it is sent, and that nothing
+// is sent for a fast reply
+func TestTelegramTypingIndicator() error {
+	log.Println("Testing Telegram typing indicator...")
+
+	server, bot, err := newTelegramTestBot()
+	if err != nil {
+		return err
+	}
+	defer server.Close()
+
+	dryRun, err := agent.NewDryRunAgent(config.DefaultConfig())
+	if err != nil {
+		return err
+	}
+	defer dryRun.Close()
+
+	p := &TelegramPlatform{
+		config:  &TelegramConfig{TypingIndicator: true},
+		botAPI:  bot,
+		agent:   dryRun.Agent,
+		started: true,
+	}
+
+	send := func(text string) error {
+		var update tgbotapi.Update
+		data := fmt.Sprintf(`{"update_id": 1, "message": {"message_id": 1, "date": 0,
+			"chat": {"id": 42, "type": "private"}, "from": {"id": 42, "first_name": "Test"}, "text": %q}}`, text)
+		if err := json.Unmarshal([]byte(data), &update); err != nil {
+			return fmt.Errorf("failed to decode test update: %w", err)
+		}
+		p.handleUpdate(update)
+		return nil
+	}
+
+	dryRun.Provider().QueueResponse("Quick answer")
+	if err := send("hi"); err != nil {
+		return err
+	}
+	time.Sleep(typingGracePeriod + 200*time.Millisecond)
+	if actions := server.methodCalls("sendChatAction"); len(actions) != 0 {
+		return fmt.Errorf("expected no typing action for a fast reply, got %d", len(actions))
+	}
+	log.Println("✓ No typing action for a fast reply")
+
+	dryRun.Provider().SetDelay(typingGracePeriod + time.Second)
+	dryRun.Provider().QueueResponse("Slow answer")
+	if err := send("think hard"); err != nil {
+		return err
+	}
+	actions := server.methodCalls("sendChatAction")
+	if len(actions) == 0 {
+		return fmt.Errorf("expected a typing action while the reply was generated")
+	}
+	for _, action := range actions {
+		if action.params.Get("chat_id") != "42" || action.params.Get("action") != tgbotapi.ChatTyping {
+			return fmt.Errorf("unexpected chat action: %v", action.params)
+		}
+	}
+	sends := server.methodCalls("sendMessage")
+	reply := sends[len(sends)-1]
+	if reply.params.Get("text") != "Slow answer" {
+		return fmt.Errorf("expected the slow reply to be sent, got %q", reply.params.Get("text"))
+	}
+	if last := actions[len(actions)-1]; last.at.After(reply.at) {
+		return fmt.Errorf("typing action sent after the reply")
+	}
+	log.Printf("✓ %d typing action(s) sent while the reply was generated", len(actions))
+
+	time.Sleep(typingInterval + 500*time.Millisecond)
+	if n := len(server.methodCalls("sendChatAction")); n != len(actions) {
+		return fmt.Errorf("expected typing actions to stop after the reply, got %d more", n-len(actions))
+	}
+	log.Println("✓ Typing actions stopped after the reply")
+
+	return nil
+}
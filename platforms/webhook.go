@@ -0,0 +1,363 @@
+package platform
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"time"
+
+	"quickbot/internal/agent"
+	"quickbot/internal/ai"
+	"quickbot/internal/config"
+	"quickbot/internal/events"
+)
+
+// WebhookConfig represents outbound webhook configuration
+type WebhookConfig struct {
+	URL        string
+	Secret     string
+	Events     []string
+	Retry      bool
+	MaxRetries int
+}
+
+const (
+	// webhookBaseBackoff and webhookMaxBackoff bound delivery retry delays
+	webhookBaseBackoff = time.Second
+	webhookMaxBackoff  = 30 * time.Second
+)
+
+// WebhookPayload is the JSON body posted to the webhook URL
+type WebhookPayload struct {
+	EventType string    `json:"event_type"`
+	SessionID string    `json:"session_id"`
+	Content   string    `json:"content"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// WebhookPlatform posts agent events to an external URL. Each request is
+// signed with an HMAC-SHA256 of the body in the X-QuickBot-Signature header.
+type WebhookPlatform struct {
+	config  *WebhookConfig
+	agent   *agent.Agent
+	client  *http.Client
+	events  map[string]bool
+	sub     events.Subscription
+	started bool
+	wg      sync.WaitGroup
+	mu      sync.RWMutex
+}
+
+// NewWebhookPlatform creates a new webhook platform instance
+func NewWebhookPlatform(cfg *WebhookConfig, bot *agent.Agent) (*WebhookPlatform, error) {
+	if cfg.URL == "" {
+		return nil, fmt.Errorf("webhook URL is required")
+	}
+
+	events := make(map[string]bool)
+	for _, event := range cfg.Events {
+		events[event] = true
+	}
+
+	return &WebhookPlatform{
+		config: cfg,
+		agent:  bot,
+		client: &http.Client{Timeout: 10 * time.Second},
+		events: events,
+	}, nil
+}
+
+// Start subscribes the platform to the agent event bus
+func (p *WebhookPlatform) Start() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.started {
+		return fmt.Errorf("platform already started")
+	}
+
+	p.sub = p.agent.EventBus().Subscribe(events.TopicAll, p.handleEvent)
+	p.started = true
+
+	log.Println("✓ Webhook platform started")
+	return nil
+}
+
+// Stop stops delivering events and waits for pending deliveries
+func (p *WebhookPlatform) Stop() error {
+	p.mu.Lock()
+	if !p.started {
+		p.mu.Unlock()
+		return fmt.Errorf("platform not started")
+	}
+	p.started = false
+	p.sub.Unsubscribe()
+	p.mu.Unlock()
+
+	p.wg.Wait()
+
+	log.Println("✓ Webhook platform stopped")
+	return nil
+}
+
+// handleEvent delivers subscribed events in the background
+func (p *WebhookPlatform) handleEvent(event events.Event) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	if !p.started || !p.events[event.Topic] {
+		return
+	}
+
+	sessionID, content := eventContent(event)
+
+	p.wg.Add(1)
+	go func() {
+		defer p.wg.Done()
+
+		payload := WebhookPayload{
+			EventType: event.Topic,
+			SessionID: sessionID,
+			Content:   content,
+			Timestamp: event.Timestamp,
+		}
+		if err := p.Send(payload); err != nil {
+			log.Printf("Error delivering webhook event %s: %v", event.Topic, err)
+		}
+	}()
+}
+
+// eventContent returns the session ID and content of an event payload
+func eventContent(event events.Event) (string, string) {
+	switch payload := event.Payload.(type) {
+	case events.MessagePayload:
+		return payload.SessionID, payload.Content
+	case events.SessionPayload:
+		return payload.SessionID, ""
+	case events.TaskPayload:
+		if payload.Error != "" {
+			return payload.SessionID, payload.Error
+		}
+		return payload.SessionID, payload.Result
+	default:
+		return "", fmt.Sprint(payload)
+	}
+}
+
+// Send posts a payload to the webhook URL, retrying failed deliveries
+// when retry is enabled
+func (p *WebhookPlatform) Send(payload WebhookPayload) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to marshal webhook payload: %w", err)
+	}
+
+	attempts := 1
+	if p.config.Retry && p.config.MaxRetries > 0 {
+		attempts += p.config.MaxRetries
+	}
+
+	for attempt := 1; ; attempt++ {
+		retryable, err := p.post(body)
+		if err == nil {
+			return nil
+		}
+		if !retryable || attempt >= attempts {
+			return err
+		}
+
+		wait := ai.BackoffDelay(attempt, webhookBaseBackoff, webhookMaxBackoff)
+		log.Printf("Warning: webhook delivery failed (attempt %d/%d), retrying in %v: %v",
+			attempt, attempts, wait, err)
+		time.Sleep(wait)
+	}
+}
+
+// post sends one signed request and reports whether a failure is retryable
+func (p *WebhookPlatform) post(body []byte) (bool, error) {
+	req, err := http.NewRequest(http.MethodPost, p.config.URL, bytes.NewReader(body))
+	if err != nil {
+		return false, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-QuickBot-Signature", "sha256="+SignWebhookPayload(p.config.Secret, body))
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return true, fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		retryable := resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500
+		return retryable, fmt.Errorf("webhook returned status %d", resp.StatusCode)
+	}
+
+	return false, nil
+}
+
+// SignWebhookPayload returns the hex HMAC-SHA256 of body keyed by secret
+func SignWebhookPayload(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// IsStarted returns whether the platform is started
+func (p *WebhookPlatform) IsStarted() bool {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.started
+}
+
+// SupportsStreaming returns false: events are delivered as a whole
+func (p *WebhookPlatform) SupportsStreaming() bool {
+	return false
+}
+
+// webhookRequest is a request captured by the webhook test server
+type webhookRequest struct {
+	signature string
+	body      []byte
+}
+
+// TestWebhook delivers agent events to a test server and checks their
+// signature, payload and retries
+func TestWebhook() error {
+	log.Println("Testing webhook platform...")
+
+	var mu sync.Mutex
+	var requests []webhookRequest
+	var statuses []int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+
+		mu.Lock()
+		defer mu.Unlock()
+		requests = append(requests, webhookRequest{signature: r.Header.Get("X-QuickBot-Signature"), body: body})
+		if len(statuses) > 0 {
+			w.WriteHeader(statuses[0])
+			statuses = statuses[1:]
+		}
+	}))
+	defer server.Close()
+
+	// captured returns the requests received since the last call, and
+	// makes the next requests answer with the given statuses
+	captured := func(next ...int) []webhookRequest {
+		mu.Lock()
+		defer mu.Unlock()
+		received := requests
+		requests, statuses = nil, next
+		return received
+	}
+
+	dryRun, err := agent.NewDryRunAgent(config.DefaultConfig())
+	if err != nil {
+		return err
+	}
+	defer dryRun.Close()
+	dryRun.SetEventBus(events.NewEventBus())
+
+	cfg := &WebhookConfig{
+		URL:        server.URL,
+		Secret:     "secret",
+		Events:     []string{events.TopicMessageProcessed},
+		Retry:      true,
+		MaxRetries: 2,
+	}
+	p, err := NewWebhookPlatform(cfg, dryRun.Agent)
+	if err != nil {
+		return err
+	}
+	if err := p.Start(); err != nil {
+		return err
+	}
+
+	before := time.Now()
+	dryRun.Provider().QueueResponse("Hi there")
+	if _, err := dryRun.ProcessMessage(context.Background(), "webhook:1", "Hello"); err != nil {
+		return err
+	}
+
+	// Events are delivered in the background; leave time for an
+	// unsubscribed event to arrive too
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		mu.Lock()
+		delivered := len(requests)
+		mu.Unlock()
+		if delivered > 0 || time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	time.Sleep(100 * time.Millisecond)
+	if err := p.Stop(); err != nil {
+		return err
+	}
+
+	received := captured()
+	if len(received) != 1 {
+		return fmt.Errorf("expected only the message.processed event delivered, got %d requests", len(received))
+	}
+	if want := "sha256=" + SignWebhookPayload("secret", received[0].body); received[0].signature != want {
+		return fmt.Errorf("expected signature %s, got %s", want, received[0].signature)
+	}
+	if SignWebhookPayload("other", received[0].body) == SignWebhookPayload("secret", received[0].body) {
+		return fmt.Errorf("expected the signature to depend on the secret")
+	}
+
+	var fields map[string]interface{}
+	if err := json.Unmarshal(received[0].body, &fields); err != nil {
+		return fmt.Errorf("invalid webhook body %s: %w", received[0].body, err)
+	}
+	if len(fields) != 4 || fields["event_type"] != events.TopicMessageProcessed ||
+		fields["session_id"] != "webhook:1" || fields["content"] != "Hi there" {
+		return fmt.Errorf("unexpected webhook payload %s", received[0].body)
+	}
+	var payload WebhookPayload
+	json.Unmarshal(received[0].body, &payload)
+	if payload.Timestamp.Before(before.Add(-time.Second)) || payload.Timestamp.After(time.Now()) {
+		return fmt.Errorf("unexpected webhook timestamp %v", payload.Timestamp)
+	}
+	log.Println("✓ Subscribed event posted with a valid signature")
+
+	// A server error is retried, a client error is not
+	payload = WebhookPayload{EventType: "test", SessionID: "webhook:1", Content: "retry", Timestamp: time.Now()}
+	captured(http.StatusServiceUnavailable)
+	if err := p.Send(payload); err != nil {
+		return fmt.Errorf("expected the retried delivery to succeed: %w", err)
+	}
+	if received := captured(http.StatusBadRequest); len(received) != 2 || !strings.Contains(string(received[1].body), `"retry"`) {
+		return fmt.Errorf("expected the delivery to be retried once, got %d requests", len(received))
+	}
+	if err := p.Send(payload); err == nil {
+		return fmt.Errorf("expected a client error to fail the delivery")
+	}
+	cfg.Retry = false
+	if received := captured(http.StatusServiceUnavailable); len(received) != 1 {
+		return fmt.Errorf("expected a client error not to be retried, got %d requests", len(received))
+	}
+	if err := p.Send(payload); err == nil {
+		return fmt.Errorf("expected the delivery to fail without retries")
+	}
+	if received := captured(); len(received) != 1 {
+		return fmt.Errorf("expected no retries when retry is disabled, got %d requests", len(received))
+	}
+	log.Println("✓ Failed deliveries retried")
+
+	return nil
+}